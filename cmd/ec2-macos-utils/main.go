@@ -4,10 +4,18 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
 
 	"github.com/aws/ec2-macos-utils/internal/cmd"
 	"github.com/aws/ec2-macos-utils/internal/contextual"
-	"github.com/aws/ec2-macos-utils/internal/system"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/events"
+	"github.com/aws/ec2-macos-utils/pkg/progress"
+	"github.com/aws/ec2-macos-utils/pkg/system"
 )
 
 func main() {
@@ -20,9 +28,44 @@ func main() {
 		panic("no product associated with identified system")
 	}
 
-	ctx := contextual.WithProduct(context.Background(), p)
+	tracker := &events.Tracker{}
+	ctx, received := interruptible(context.Background())
+	ctx = contextual.WithProduct(ctx, p)
+	ctx = events.NewContext(ctx, events.NewBus(events.LogSubscriber{}, tracker))
+	ctx = progress.NewContext(ctx, progress.New(os.Stdout))
 
-	if err := cmd.MainCommand().ExecuteContext(ctx); err != nil {
-		os.Exit(1)
+	root := cmd.MainCommand()
+	executed, err := root.ExecuteContextC(ctx)
+	cmd.RecordRun(executed, err)
+	if sig, ok := received.Load().(syscall.Signal); ok {
+		// Report what had and hadn't completed so the caller doesn't have to inspect the system to find out
+		tracker.LogSummary()
+		os.Exit(128 + int(sig))
+	}
+	if err != nil {
+		// Errors are reported here rather than by cobra so that they're structured with the JSON output format
+		cmd.ReportError(root, err)
+		os.Exit(diskutil.Classify(err).ExitCode())
 	}
 }
+
+// interruptible creates a context that's cancelled when the process receives SIGINT or SIGTERM, which also
+// terminates the commands being run. The signal is stored in the returned value once received. Only the first
+// signal is handled so that a second one stops the process immediately.
+func interruptible(parent context.Context) (context.Context, *atomic.Value) {
+	ctx, cancel := context.WithCancel(parent)
+	received := &atomic.Value{}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		signal.Stop(sigs)
+
+		logrus.WithField("signal", sig).Warn("Interrupted, stopping the running operation...")
+		received.Store(sig)
+		cancel()
+	}()
+
+	return ctx, received
+}
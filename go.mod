@@ -9,7 +9,9 @@ require (
 	github.com/sirupsen/logrus v1.8.1
 	github.com/spf13/cobra v1.7.0
 	github.com/stretchr/testify v1.3.0
+	golang.org/x/sys v0.1.0
 	golang.org/x/tools v0.1.8
+	gopkg.in/yaml.v3 v3.0.1
 	howett.net/plist v0.0.0-20201203080718-1454fab16a06
 )
 
@@ -21,7 +23,5 @@ require (
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	golang.org/x/mod v0.5.1 // indirect
-	golang.org/x/sys v0.1.0 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,93 @@
+// Package asr provides the functionality necessary for restoring volumes from disk images and other volumes with
+// macOS's Apple Software Restore (asr) CLI.
+package asr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// RestoreOptions configures a restore.
+type RestoreOptions struct {
+	// Source is the path to the disk image, or the device node of the volume, to restore from.
+	Source string
+	// Target is the device node of the volume to restore onto (e.g. /dev/disk3s1).
+	Target string
+	// Erase erases the target and copies the source block for block, which is much faster than copying files.
+	Erase bool
+	// Progress is called with the percentage of the restore that's complete, when set.
+	Progress func(percent float64)
+}
+
+// Restore restores the source onto the target. Everything on the target is lost when erasing.
+func Restore(ctx context.Context, opts RestoreOptions) error {
+	if opts.Source == "" || opts.Target == "" {
+		return errors.New("asr: source and target are required")
+	}
+
+	// cmdRestore represents the command used for executing macOS's asr to restore a volume.
+	//   * restore - restore the source onto the target
+	//   * --source <source> - the disk image or volume to restore from
+	//   * --target <target> - the volume to restore onto
+	//   * --noprompt - don't prompt for confirmation before erasing the target
+	//   * --puppetstrings - print machine-readable progress
+	//   * --erase - erase the target and copy the source block for block
+	cmdRestore := []string{"asr", "restore", "--source", opts.Source, "--target", opts.Target, "--noprompt", "--puppetstrings"}
+	if opts.Erase {
+		cmdRestore = append(cmdRestore, "--erase")
+	}
+
+	// Restores copy the whole source so the system is kept awake until they finish.
+	out, err := util.ExecuteCommandLines(ctx, cmdRestore, "", nil, func(line string) {
+		if percent, ok := parseProgress(line); ok && opts.Progress != nil {
+			opts.Progress(percent)
+		}
+	}, util.PreventSleep())
+	if err != nil {
+		return fmt.Errorf("asr: failed to restore %s onto %s, stderr: [%s]: %w", opts.Source, opts.Target, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// ImageScan scans the disk image and adds the checksums asr needs to restore it block for block. Images created by
+// hdiutil outside of asr need to be scanned once before they're restored.
+func ImageScan(ctx context.Context, image string) error {
+	// cmdScan represents the command used for executing macOS's asr to scan a disk image.
+	//   * imagescan - checksum the image so it can be restored
+	//   * --source <image> - the disk image to scan
+	cmdScan := []string{"asr", "imagescan", "--source", image}
+
+	out, err := util.ExecuteCommand(ctx, cmdScan, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("asr: failed to scan %s, stderr: [%s]: %w", image, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// parseProgress parses the percentage from asr's progress lines (e.g. "PPRG\t42" or "PPRG\t42\t100", where the
+// second number is the total). Other lines, such as the "PINF" status messages, are ignored.
+func parseProgress(line string) (float64, bool) {
+	fields := strings.Split(strings.TrimSpace(line), "\t")
+	if len(fields) < 2 || fields[0] != "PPRG" {
+		return 0, false
+	}
+
+	done, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+	if err != nil {
+		return 0, false
+	}
+	if len(fields) > 2 {
+		if total, err := strconv.ParseFloat(strings.TrimSpace(fields[2]), 64); err == nil && total > 0 {
+			return done / total * 100, true
+		}
+	}
+
+	return done, true
+}
@@ -0,0 +1,37 @@
+package asr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		want   float64
+		wantOk bool
+	}{
+		{name: "percent", input: "PPRG\t42", want: 42, wantOk: true},
+		{name: "total", input: "PPRG\t25\t50\n", want: 50, wantOk: true},
+		{name: "info", input: "PINF\tValidating target...done"},
+		{name: "start", input: "XSTA\tstart\trestore"},
+		{name: "malformed", input: "PPRG\tdone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseProgress(tt.input)
+
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOk, ok)
+		})
+	}
+}
+
+func TestRestore_WithoutTarget(t *testing.T) {
+	err := Restore(context.Background(), RestoreOptions{Source: "/tmp/golden.dmg"})
+
+	assert.Error(t, err, "should require a target")
+}
@@ -0,0 +1,221 @@
+// Package aws provides a minimal client for the AWS APIs used by EC2 macOS Utils. Requests are signed with
+// Signature Version 4 using credentials from the environment or the instance profile.
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/imds"
+)
+
+// defaultTimeout is the timeout for individual API requests.
+const defaultTimeout = 30 * time.Second
+
+// Client sends signed requests to AWS APIs in a single region.
+type Client struct {
+	// Region is the AWS Region requests are sent to.
+	Region string
+	// Credentials provides the credentials used to sign requests.
+	Credentials CredentialsProvider
+	// HTTPClient is the client used to send requests.
+	HTTPClient *http.Client
+	// Endpoint overrides the endpoint of every service when set (e.g. for testing).
+	Endpoint string
+	// now gets the time used to sign requests.
+	now func() time.Time
+}
+
+// NewClient creates a new Client for the region with the default credentials.
+func NewClient(region string, metadata *imds.Client) *Client {
+	return &Client{
+		Region:      region,
+		Credentials: DefaultProvider(metadata),
+		HTTPClient:  &http.Client{Timeout: defaultTimeout},
+		now:         time.Now,
+	}
+}
+
+// NewClientFromMetadata creates a new Client for the region the instance is running in.
+func NewClientFromMetadata(ctx context.Context) (*Client, error) {
+	metadata := imds.NewClient()
+	region, err := metadata.Region(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("aws: cannot determine region: %w", err)
+	}
+
+	return NewClient(region, metadata), nil
+}
+
+// APIError is an error response returned by an AWS API.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Code identifies the type of error (e.g. "ResourceNotFoundException").
+	Code string
+	// Message describes the error.
+	Message string
+}
+
+// Error formats the API error.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("aws: %s (status %d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// endpoint gets the endpoint for the service.
+func (c *Client) endpoint(service string) string {
+	if c.Endpoint != "" {
+		return c.Endpoint
+	}
+
+	if service == iamService {
+		return iamEndpoint
+	}
+
+	return fmt.Sprintf("https://%s.%s.amazonaws.com", service, c.Region)
+}
+
+// signingRegion gets the region that requests to the service are signed for. IAM is a global service whose
+// requests are signed for us-east-1.
+func (c *Client) signingRegion(service string) string {
+	if service == iamService {
+		return iamRegion
+	}
+
+	return c.Region
+}
+
+// doJSON calls an operation of a service using the AWS JSON 1.1 protocol (e.g. Secrets Manager's
+// "secretsmanager.GetSecretValue") and decodes the response into out.
+func (c *Client) doJSON(ctx context.Context, service, target string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(service)+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+
+	resp, err := c.send(ctx, req, body, service)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return decodeJSONError(resp.StatusCode, data)
+	}
+	if out == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, out)
+}
+
+// doQuery calls an action of a service using the AWS Query protocol (e.g. SNS's "Publish") and decodes the XML
+// response into out.
+func (c *Client) doQuery(ctx context.Context, service, action, version string, params url.Values, out interface{}) error {
+	form := url.Values{}
+	for k, v := range params {
+		form[k] = v
+	}
+	form.Set("Action", action)
+	form.Set("Version", version)
+	body := []byte(form.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(service)+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	resp, err := c.send(ctx, req, body, service)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return decodeQueryError(resp.StatusCode, data)
+	}
+	if out == nil {
+		return nil
+	}
+
+	return xml.Unmarshal(data, out)
+}
+
+// send signs and sends the request.
+func (c *Client) send(ctx context.Context, req *http.Request, body []byte, service string) (*http.Response, error) {
+	creds, err := c.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	signRequest(req, body, creds, service, c.signingRegion(service), now())
+
+	return c.HTTPClient.Do(req)
+}
+
+// decodeJSONError decodes the error response of a JSON protocol API.
+func decodeJSONError(status int, data []byte) error {
+	var e struct {
+		Type    string `json:"__type"`
+		Message string `json:"message"`
+		Upper   string `json:"Message"`
+	}
+	apiErr := &APIError{StatusCode: status, Code: http.StatusText(status)}
+	if err := json.Unmarshal(data, &e); err == nil {
+		if e.Type != "" {
+			apiErr.Code = e.Type
+		}
+		apiErr.Message = e.Message
+		if apiErr.Message == "" {
+			apiErr.Message = e.Upper
+		}
+	}
+
+	return apiErr
+}
+
+// decodeQueryError decodes the error response of a Query protocol API.
+func decodeQueryError(status int, data []byte) error {
+	var e struct {
+		Error struct {
+			Code    string
+			Message string
+		}
+	}
+	apiErr := &APIError{StatusCode: status, Code: http.StatusText(status)}
+	if err := xml.Unmarshal(data, &e); err == nil {
+		if e.Error.Code != "" {
+			apiErr.Code = e.Error.Code
+		}
+		apiErr.Message = e.Error.Message
+	}
+
+	return apiErr
+}
@@ -0,0 +1,60 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+const (
+	// cloudWatchService is the signing name and endpoint prefix of Amazon CloudWatch.
+	cloudWatchService = "monitoring"
+	// cloudWatchVersion is the version of the CloudWatch Query API.
+	cloudWatchVersion = "2010-08-01"
+)
+
+// MetricDatum is a value of a CloudWatch metric.
+type MetricDatum struct {
+	// Name is the name of the metric.
+	Name string
+	// Value is the value of the metric.
+	Value float64
+	// Unit is the unit of the value (e.g. "Count" or "Percent"), CloudWatch uses "None" when it's empty.
+	Unit string
+	// Dimensions identify the metric's series (e.g. "InstanceId": "i-0123456789abcdef0").
+	Dimensions map[string]string
+}
+
+// PutMetricData publishes the values of the metrics in the namespace.
+func (c *Client) PutMetricData(ctx context.Context, namespace string, data []MetricDatum) error {
+	params := url.Values{}
+	params.Set("Namespace", namespace)
+	for i, d := range data {
+		prefix := fmt.Sprintf("MetricData.member.%d.", i+1)
+		params.Set(prefix+"MetricName", d.Name)
+		params.Set(prefix+"Value", strconv.FormatFloat(d.Value, 'g', -1, 64))
+		if d.Unit != "" {
+			params.Set(prefix+"Unit", d.Unit)
+		}
+
+		// Dimensions are sorted so that requests are the same for the same values.
+		names := make([]string, 0, len(d.Dimensions))
+		for name := range d.Dimensions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for j, name := range names {
+			dimension := fmt.Sprintf("%sDimensions.member.%d.", prefix, j+1)
+			params.Set(dimension+"Name", name)
+			params.Set(dimension+"Value", d.Dimensions[name])
+		}
+	}
+
+	if err := c.doQuery(ctx, cloudWatchService, "PutMetricData", cloudWatchVersion, params, nil); err != nil {
+		return fmt.Errorf("cannot put metric data in %s: %w", namespace, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_PutMetricData(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/monitoring/aws4_request"))
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "PutMetricData", r.PostForm.Get("Action"))
+		assert.Equal(t, "EC2MacOSUtils", r.PostForm.Get("Namespace"))
+		assert.Equal(t, "DiskHealthDegraded", r.PostForm.Get("MetricData.member.1.MetricName"))
+		assert.Equal(t, "2", r.PostForm.Get("MetricData.member.1.Value"))
+		assert.Equal(t, "Count", r.PostForm.Get("MetricData.member.1.Unit"))
+		assert.Equal(t, "Check", r.PostForm.Get("MetricData.member.1.Dimensions.member.1.Name"))
+		assert.Equal(t, "smart", r.PostForm.Get("MetricData.member.1.Dimensions.member.1.Value"))
+		assert.Equal(t, "InstanceId", r.PostForm.Get("MetricData.member.1.Dimensions.member.2.Name"))
+		assert.Equal(t, "0.5", r.PostForm.Get("MetricData.member.2.Value"))
+		assert.Empty(t, r.PostForm.Get("MetricData.member.2.Unit"))
+
+		w.Write([]byte(`<PutMetricDataResponse><ResponseMetadata><RequestId>e16fc4d3-9a04-11e0-9362-093a1cae5385</RequestId></ResponseMetadata></PutMetricDataResponse>`))
+	})
+
+	err := c.PutMetricData(context.Background(), "EC2MacOSUtils", []MetricDatum{
+		{Name: "DiskHealthDegraded", Value: 2, Unit: "Count", Dimensions: map[string]string{"InstanceId": "i-0123456789abcdef0", "Check": "smart"}},
+		{Name: "Ratio", Value: 0.5},
+	})
+
+	assert.NoError(t, err)
+}
+
+func TestClient_PutMetricData_Denied(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>not authorized to perform: cloudwatch:PutMetricData</Message></Error></ErrorResponse>`))
+	})
+
+	err := c.PutMetricData(context.Background(), "EC2MacOSUtils", []MetricDatum{{Name: "DiskHealthDegraded"}})
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "AccessDenied", apiErr.Code)
+}
@@ -0,0 +1,222 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/imds"
+)
+
+// ErrNoCredentials is returned when no provider in a chain has credentials.
+var ErrNoCredentials = errors.New("aws: no credentials found")
+
+// ErrNoRole is returned when the instance doesn't have an instance profile with a role attached, so there are no
+// instance profile credentials.
+var ErrNoRole = fmt.Errorf("%w: no IAM role is attached to the instance, attach an instance profile with a role", ErrNoCredentials)
+
+// DefaultExpiryWindow is how long before they expire that cached credentials are refreshed. The instance metadata
+// service makes new credentials available at least 5 minutes before the current ones expire.
+const DefaultExpiryWindow = 5 * time.Minute
+
+// Credentials are the AWS credentials used to sign requests.
+type Credentials struct {
+	// AccessKeyID identifies the credentials.
+	AccessKeyID string
+	// SecretAccessKey is the secret used to sign requests.
+	SecretAccessKey string
+	// SessionToken is the token for temporary credentials.
+	SessionToken string
+	// Expiration is when temporary credentials expire. The zero value means the credentials don't expire.
+	Expiration time.Time
+}
+
+// CredentialsProvider retrieves credentials for signing requests.
+type CredentialsProvider interface {
+	// Retrieve gets the current credentials.
+	Retrieve(ctx context.Context) (Credentials, error)
+}
+
+// EnvProvider retrieves credentials from the standard AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and
+// AWS_SESSION_TOKEN environment variables.
+type EnvProvider struct{}
+
+// Retrieve gets the credentials from the environment.
+func (EnvProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	creds := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("%w in the environment", ErrNoCredentials)
+	}
+
+	return creds, nil
+}
+
+// IMDSProvider retrieves the credentials of the instance profile from the instance metadata service.
+type IMDSProvider struct {
+	// Client is the client for the instance metadata service.
+	Client *imds.Client
+}
+
+// imdsCredentialsPath is the metadata path listing the instance profile's role and, below it, the role's
+// credentials.
+const imdsCredentialsPath = "/latest/meta-data/iam/security-credentials/"
+
+// imdsCredentials is the document returned by the instance metadata service for a role's credentials.
+type imdsCredentials struct {
+	Code            string
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	Token           string
+	Expiration      time.Time
+}
+
+// Retrieve gets the instance profile's credentials.
+func (p IMDSProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	roles, err := p.Client.Get(ctx, imdsCredentialsPath)
+	if errors.Is(err, imds.ErrNotFound) {
+		return Credentials{}, ErrNoRole
+	} else if err != nil {
+		return Credentials{}, fmt.Errorf("aws: cannot find instance profile: %w", err)
+	}
+	role := strings.TrimSpace(strings.SplitN(roles, "\n", 2)[0])
+	if role == "" {
+		return Credentials{}, ErrNoRole
+	}
+
+	doc, err := p.Client.Get(ctx, imdsCredentialsPath+role)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("aws: cannot get instance profile credentials: %w", err)
+	}
+
+	var c imdsCredentials
+	if err := json.Unmarshal([]byte(doc), &c); err != nil {
+		return Credentials{}, fmt.Errorf("aws: cannot decode instance profile credentials: %w", err)
+	}
+	if c.Code != "Success" {
+		return Credentials{}, fmt.Errorf("aws: instance profile credentials unavailable: %s", c.Code)
+	}
+
+	return Credentials{
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.Token,
+		Expiration:      c.Expiration,
+	}, nil
+}
+
+// CachingProvider caches the credentials retrieved by Provider until they're about to expire, so that temporary
+// credentials (e.g. the instance profile's) are only retrieved again when they need to be refreshed.
+type CachingProvider struct {
+	// Provider retrieves the credentials when there aren't any cached.
+	Provider CredentialsProvider
+	// ExpiryWindow is how long before they expire that the credentials are refreshed.
+	ExpiryWindow time.Duration
+
+	mu    sync.Mutex
+	creds *Credentials
+	now   func() time.Time
+}
+
+// NewCachingProvider creates a CachingProvider for the provider which refreshes credentials DefaultExpiryWindow before
+// they expire.
+func NewCachingProvider(provider CredentialsProvider) *CachingProvider {
+	return &CachingProvider{Provider: provider, ExpiryWindow: DefaultExpiryWindow, now: time.Now}
+}
+
+// Retrieve gets the cached credentials, refreshing them when they're about to expire. When refreshing fails, the
+// cached credentials are used until they actually expire.
+func (p *CachingProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	if p.creds != nil && (p.creds.Expiration.IsZero() || now.Before(p.creds.Expiration.Add(-p.ExpiryWindow))) {
+		return *p.creds, nil
+	}
+
+	creds, err := p.Provider.Retrieve(ctx)
+	if err != nil {
+		if p.creds != nil && now.Before(p.creds.Expiration) {
+			logrus.WithError(err).WithField("expiration", p.creds.Expiration).Warn("Unable to refresh AWS credentials, using the current ones until they expire")
+			return *p.creds, nil
+		}
+		return Credentials{}, err
+	}
+	p.creds = &creds
+
+	return creds, nil
+}
+
+// ChainProvider retrieves credentials from the first provider that has them.
+type ChainProvider []CredentialsProvider
+
+// Retrieve gets the credentials from the first provider that has them. A *ChainError with each provider's error is
+// returned when none of them do.
+func (c ChainProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	chainErr := &ChainError{}
+	for _, p := range c {
+		creds, err := p.Retrieve(ctx)
+		if err == nil {
+			return creds, nil
+		}
+		chainErr.Errs = append(chainErr.Errs, err)
+	}
+
+	return Credentials{}, chainErr
+}
+
+// ChainError is returned when no provider in a ChainProvider has credentials. It's ErrNoCredentials and each of the
+// providers' errors (e.g. ErrNoRole) for errors.Is and errors.As.
+type ChainError struct {
+	// Errs are the providers' errors, in order.
+	Errs []error
+}
+
+func (e *ChainError) Error() string {
+	msgs := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	return fmt.Sprintf("%s: [%s]", ErrNoCredentials, strings.Join(msgs, "; "))
+}
+
+func (e *ChainError) Is(target error) bool {
+	if target == ErrNoCredentials {
+		return true
+	}
+	for _, err := range e.Errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *ChainError) As(target interface{}) bool {
+	for _, err := range e.Errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DefaultProvider creates the provider used by default, which uses credentials from the environment before the
+// instance profile's credentials. The instance profile's credentials are cached until they need to be refreshed.
+func DefaultProvider(client *imds.Client) CredentialsProvider {
+	return ChainProvider{EnvProvider{}, NewCachingProvider(IMDSProvider{Client: client})}
+}
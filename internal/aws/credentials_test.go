@@ -0,0 +1,187 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/imds"
+)
+
+// staticProvider is a CredentialsProvider with fixed credentials.
+type staticProvider struct {
+	creds Credentials
+	err   error
+}
+
+func (p staticProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	return p.creds, p.err
+}
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "SECRET")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	creds, err := EnvProvider{}.Retrieve(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, creds)
+}
+
+func TestEnvProvider_Unset(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+
+	_, err := EnvProvider{}.Retrieve(context.Background())
+
+	assert.True(t, errors.Is(err, ErrNoCredentials))
+}
+
+func TestIMDSProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/api/token":
+			w.Write([]byte("token"))
+		case imdsCredentialsPath:
+			w.Write([]byte("ci-role\n"))
+		case imdsCredentialsPath + "ci-role":
+			w.Write([]byte(`{"Code":"Success","AccessKeyId":"ASIA","SecretAccessKey":"SECRET","Token":"TOKEN","Expiration":"2023-10-14T12:00:00Z"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := IMDSProvider{Client: &imds.Client{Endpoint: server.URL, HTTPClient: server.Client()}}
+	creds, err := p.Retrieve(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ASIA", creds.AccessKeyID)
+	assert.Equal(t, "SECRET", creds.SecretAccessKey)
+	assert.Equal(t, "TOKEN", creds.SessionToken)
+	assert.False(t, creds.Expiration.IsZero())
+}
+
+func TestIMDSProvider_NoRole(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/api/token" {
+			w.Write([]byte("token"))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	p := IMDSProvider{Client: &imds.Client{Endpoint: server.URL, HTTPClient: server.Client()}}
+	_, err := p.Retrieve(context.Background())
+
+	assert.True(t, errors.Is(err, ErrNoRole))
+	assert.True(t, errors.Is(err, ErrNoCredentials))
+}
+
+// sequenceProvider is a CredentialsProvider which returns each of its results in turn.
+type sequenceProvider struct {
+	results []staticProvider
+	calls   int
+}
+
+func (p *sequenceProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	r := p.results[p.calls]
+	p.calls++
+
+	return r.creds, r.err
+}
+
+func TestCachingProvider(t *testing.T) {
+	now := time.Date(2023, 10, 14, 12, 0, 0, 0, time.UTC)
+	first := Credentials{AccessKeyID: "ASIA1", SecretAccessKey: "SECRET", Expiration: now.Add(time.Hour)}
+	second := Credentials{AccessKeyID: "ASIA2", SecretAccessKey: "SECRET", Expiration: now.Add(2 * time.Hour)}
+	source := &sequenceProvider{results: []staticProvider{{creds: first}, {creds: second}}}
+	p := NewCachingProvider(source)
+	p.now = func() time.Time { return now }
+
+	creds, err := p.Retrieve(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, first, creds)
+
+	now = now.Add(50 * time.Minute)
+	creds, err = p.Retrieve(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, first, creds, "the credentials are cached until they're about to expire")
+
+	now = now.Add(5 * time.Minute)
+	creds, err = p.Retrieve(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, second, creds, "the credentials are refreshed within the expiry window")
+	assert.Equal(t, 2, source.calls)
+}
+
+func TestCachingProvider_RefreshFails(t *testing.T) {
+	now := time.Date(2023, 10, 14, 12, 0, 0, 0, time.UTC)
+	cached := Credentials{AccessKeyID: "ASIA1", SecretAccessKey: "SECRET", Expiration: now.Add(time.Hour)}
+	unavailable := errors.New("unavailable")
+	source := &sequenceProvider{results: []staticProvider{{creds: cached}, {err: unavailable}, {err: unavailable}}}
+	p := NewCachingProvider(source)
+	p.now = func() time.Time { return now }
+
+	_, err := p.Retrieve(context.Background())
+	assert.NoError(t, err)
+
+	now = now.Add(58 * time.Minute)
+	creds, err := p.Retrieve(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, cached, creds, "the current credentials are used until they expire")
+
+	now = now.Add(2 * time.Minute)
+	_, err = p.Retrieve(context.Background())
+	assert.True(t, errors.Is(err, unavailable))
+}
+
+func TestChainProvider(t *testing.T) {
+	want := Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}
+	chain := ChainProvider{
+		staticProvider{err: errors.New("unavailable")},
+		staticProvider{creds: want},
+	}
+
+	creds, err := chain.Retrieve(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, want, creds)
+}
+
+func TestChainProvider_Empty(t *testing.T) {
+	unavailable := errors.New("unavailable")
+	_, err := ChainProvider{staticProvider{err: unavailable}, staticProvider{err: &APIError{Code: "Throttling"}}}.Retrieve(context.Background())
+
+	assert.True(t, errors.Is(err, ErrNoCredentials))
+	assert.True(t, errors.Is(err, unavailable), "providers' errors should be matchable")
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr), "providers' errors should be matchable")
+	assert.Equal(t, "Throttling", apiErr.Code)
+}
+
+func TestDefaultProvider_NoRole(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/api/token" {
+			w.Write([]byte("token"))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	p := DefaultProvider(&imds.Client{Endpoint: server.URL, HTTPClient: server.Client()})
+	_, err := p.Retrieve(context.Background())
+
+	assert.True(t, errors.Is(err, ErrNoRole), "the instance profile's error should be matchable through the chain")
+	assert.True(t, errors.Is(err, ErrNoCredentials))
+}
@@ -0,0 +1,97 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+const (
+	// iamService is the signing name of AWS Identity and Access Management.
+	iamService = "iam"
+	// iamVersion is the version of the IAM Query API.
+	iamVersion = "2010-05-08"
+	// iamEndpoint is the global endpoint of IAM in the aws partition.
+	iamEndpoint = "https://iam.amazonaws.com"
+	// iamRegion is the region that requests to IAM's global endpoint are signed for.
+	iamRegion = "us-east-1"
+)
+
+// GroupUsers fetches the names of the IAM users in the group.
+func (c *Client) GroupUsers(ctx context.Context, group string) ([]string, error) {
+	var names []string
+	marker := ""
+	for {
+		params := url.Values{}
+		params.Set("GroupName", group)
+		if marker != "" {
+			params.Set("Marker", marker)
+		}
+		var out struct {
+			Users       []string `xml:"GetGroupResult>Users>member>UserName"`
+			IsTruncated bool     `xml:"GetGroupResult>IsTruncated"`
+			Marker      string   `xml:"GetGroupResult>Marker"`
+		}
+
+		if err := c.doQuery(ctx, iamService, "GetGroup", iamVersion, params, &out); err != nil {
+			return nil, fmt.Errorf("cannot get IAM group %s: %w", group, err)
+		}
+		names = append(names, out.Users...)
+		if !out.IsTruncated {
+			return names, nil
+		}
+		marker = out.Marker
+	}
+}
+
+// SSHPublicKeys fetches the IAM user's active SSH public keys in OpenSSH format. Inactive keys are skipped.
+func (c *Client) SSHPublicKeys(ctx context.Context, user string) ([]string, error) {
+	var ids []string
+	marker := ""
+	for {
+		params := url.Values{}
+		params.Set("UserName", user)
+		if marker != "" {
+			params.Set("Marker", marker)
+		}
+		var out struct {
+			Keys []struct {
+				ID     string `xml:"SSHPublicKeyId"`
+				Status string `xml:"Status"`
+			} `xml:"ListSSHPublicKeysResult>SSHPublicKeys>member"`
+			IsTruncated bool   `xml:"ListSSHPublicKeysResult>IsTruncated"`
+			Marker      string `xml:"ListSSHPublicKeysResult>Marker"`
+		}
+
+		if err := c.doQuery(ctx, iamService, "ListSSHPublicKeys", iamVersion, params, &out); err != nil {
+			return nil, fmt.Errorf("cannot list SSH public keys of IAM user %s: %w", user, err)
+		}
+		for _, k := range out.Keys {
+			if k.Status == "Active" {
+				ids = append(ids, k.ID)
+			}
+		}
+		if !out.IsTruncated {
+			break
+		}
+		marker = out.Marker
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		params := url.Values{}
+		params.Set("UserName", user)
+		params.Set("SSHPublicKeyId", id)
+		params.Set("Encoding", "SSH")
+		var out struct {
+			Body string `xml:"GetSSHPublicKeyResult>SSHPublicKey>SSHPublicKeyBody"`
+		}
+
+		if err := c.doQuery(ctx, iamService, "GetSSHPublicKey", iamVersion, params, &out); err != nil {
+			return nil, fmt.Errorf("cannot get SSH public key %s of IAM user %s: %w", id, user, err)
+		}
+		keys = append(keys, out.Body)
+	}
+
+	return keys, nil
+}
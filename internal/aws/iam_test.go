@@ -0,0 +1,56 @@
+package aws
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_GroupUsers(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.Contains(r.Header.Get("Authorization"), "/us-east-1/iam/aws4_request"))
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "GetGroup", r.PostForm.Get("Action"))
+		assert.Equal(t, "mac-users", r.PostForm.Get("GroupName"))
+
+		if r.PostForm.Get("Marker") == "" {
+			w.Write([]byte(`<GetGroupResponse><GetGroupResult><Users><member><UserName>alice</UserName></member></Users><IsTruncated>true</IsTruncated><Marker>page-2</Marker></GetGroupResult></GetGroupResponse>`))
+			return
+		}
+		w.Write([]byte(`<GetGroupResponse><GetGroupResult><Users><member><UserName>bob</UserName></member></Users><IsTruncated>false</IsTruncated></GetGroupResult></GetGroupResponse>`))
+	})
+
+	names, err := c.GroupUsers(context.Background(), "mac-users")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, names)
+}
+
+func TestClient_SSHPublicKeys(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "alice", r.PostForm.Get("UserName"))
+
+		switch r.PostForm.Get("Action") {
+		case "ListSSHPublicKeys":
+			w.Write([]byte(`<ListSSHPublicKeysResponse><ListSSHPublicKeysResult><SSHPublicKeys>` +
+				`<member><SSHPublicKeyId>APKAACTIVE</SSHPublicKeyId><Status>Active</Status></member>` +
+				`<member><SSHPublicKeyId>APKAINACTIVE</SSHPublicKeyId><Status>Inactive</Status></member>` +
+				`</SSHPublicKeys><IsTruncated>false</IsTruncated></ListSSHPublicKeysResult></ListSSHPublicKeysResponse>`))
+		case "GetSSHPublicKey":
+			assert.Equal(t, "APKAACTIVE", r.PostForm.Get("SSHPublicKeyId"))
+			assert.Equal(t, "SSH", r.PostForm.Get("Encoding"))
+			w.Write([]byte(`<GetSSHPublicKeyResponse><GetSSHPublicKeyResult><SSHPublicKey><SSHPublicKeyBody>ssh-ed25519 AAAA alice</SSHPublicKeyBody></SSHPublicKey></GetSSHPublicKeyResult></GetSSHPublicKeyResponse>`))
+		default:
+			t.Errorf("unexpected action %s", r.PostForm.Get("Action"))
+		}
+	})
+
+	keys, err := c.SSHPublicKeys(context.Background(), "alice")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ssh-ed25519 AAAA alice"}, keys, "inactive keys should be skipped")
+}
@@ -0,0 +1,210 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	// s3Service is the signing name and endpoint prefix of Amazon S3.
+	s3Service = "s3"
+	// s3Scheme is the scheme of S3 URIs (e.g. "s3://bucket/key").
+	s3Scheme = "s3://"
+	// bucketRegionHeader is the header that S3 responds with when a bucket is in another region than the request
+	// was sent to.
+	bucketRegionHeader = "X-Amz-Bucket-Region"
+)
+
+// Object is an S3 object being downloaded. Body must be closed once it has been read.
+type Object struct {
+	// Body is the content of the object.
+	Body io.ReadCloser
+	// Size is the size of the object in bytes, or -1 when S3 didn't report it.
+	Size int64
+	// ChecksumSHA256 is the base64-encoded SHA-256 checksum that the object was uploaded with, which is empty for
+	// objects that were uploaded without one. Objects uploaded in parts have a composite checksum of their parts'
+	// checksums (e.g. "<base64>-3") rather than the whole object's.
+	ChecksumSHA256 string
+	// ChecksumType is the type of the object's checksum, either "FULL_OBJECT" or "COMPOSITE", which is empty when S3
+	// didn't report it.
+	ChecksumType string
+}
+
+// IsS3URI checks if the string is an S3 URI (e.g. "s3://bucket/key").
+func IsS3URI(s string) bool {
+	return strings.HasPrefix(s, s3Scheme)
+}
+
+// ParseS3URI splits the S3 URI (e.g. "s3://bucket/path/to/key") into its bucket and key.
+func ParseS3URI(uri string) (bucket string, key string, err error) {
+	if !IsS3URI(uri) {
+		return "", "", fmt.Errorf("invalid S3 URI %q: expected s3://bucket/key", uri)
+	}
+	bucket, key, _ = strings.Cut(strings.TrimPrefix(uri, s3Scheme), "/")
+	if bucket == "" || key == "" {
+		return "", "", fmt.Errorf("invalid S3 URI %q: expected s3://bucket/key", uri)
+	}
+
+	return bucket, key, nil
+}
+
+// GetObject starts downloading the object with the key from the bucket. Buckets in other regions than the client's
+// are downloaded from their own region.
+func (c *Client) GetObject(ctx context.Context, bucket, key string) (*Object, error) {
+	resp, err := c.getObject(ctx, bucket, key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get s3://%s/%s: %w", bucket, key, err)
+	}
+
+	// S3 redirects requests for buckets in other regions, which are signed for the wrong region to follow as-is
+	if region := resp.Header.Get(bucketRegionHeader); resp.StatusCode != http.StatusOK && region != "" && region != c.Region {
+		resp.Body.Close()
+		regional := *c
+		regional.Region = region
+		if resp, err = regional.getObject(ctx, bucket, key); err != nil {
+			return nil, fmt.Errorf("cannot get s3://%s/%s: %w", bucket, key, err)
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("cannot get s3://%s/%s: %w", bucket, key, decodeS3Error(resp.StatusCode, data))
+	}
+
+	return &Object{
+		Body:           resp.Body,
+		Size:           resp.ContentLength,
+		ChecksumSHA256: resp.Header.Get("X-Amz-Checksum-Sha256"),
+		ChecksumType:   resp.Header.Get("X-Amz-Checksum-Type"),
+	}, nil
+}
+
+// getObject sends the GetObject request for the key in the bucket to the client's region.
+func (c *Client) getObject(ctx context.Context, bucket, key string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.objectEndpoint(bucket, key), nil)
+	if err != nil {
+		return nil, err
+	}
+	// S3 requires the payload's hash to be sent, and only returns the objects' checksums when asked for them
+	req.Header.Set("X-Amz-Content-Sha256", hashHex(nil))
+	req.Header.Set("X-Amz-Checksum-Mode", "ENABLED")
+
+	return c.send(ctx, req, nil, s3Service)
+}
+
+// PutObject uploads the data as the object with the key in the bucket. Buckets in other regions than the client's
+// are uploaded to in their own region.
+func (c *Client) PutObject(ctx context.Context, bucket, key string, data []byte) error {
+	sum := sha256.Sum256(data)
+	open := func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	return c.upload(ctx, bucket, key, open, int64(len(data)), sum[:])
+}
+
+// PutFile uploads the file at path as the object with the key in the bucket, streaming it rather than reading it
+// into memory so that large archives (e.g. sysdiagnose's) can be uploaded.
+func (c *Client) PutFile(ctx context.Context, bucket, key, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	open := func() (io.ReadCloser, error) {
+		return os.Open(path)
+	}
+
+	return c.upload(ctx, bucket, key, open, size, h.Sum(nil))
+}
+
+// upload uploads the content opened by open, which has the size and SHA-256 sum, as the object with the key in the
+// bucket. The content is opened again when S3 redirects the upload to the bucket's region.
+func (c *Client) upload(ctx context.Context, bucket, key string, open func() (io.ReadCloser, error), size int64, sum []byte) error {
+	resp, err := c.putObject(ctx, bucket, key, open, size, sum)
+	if err != nil {
+		return fmt.Errorf("cannot put s3://%s/%s: %w", bucket, key, err)
+	}
+
+	// Uploads to buckets in other regions are redirected like downloads are
+	if region := resp.Header.Get(bucketRegionHeader); resp.StatusCode != http.StatusOK && region != "" && region != c.Region {
+		resp.Body.Close()
+		regional := *c
+		regional.Region = region
+		if resp, err = regional.putObject(ctx, bucket, key, open, size, sum); err != nil {
+			return fmt.Errorf("cannot put s3://%s/%s: %w", bucket, key, err)
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("cannot put s3://%s/%s: %w", bucket, key, decodeS3Error(resp.StatusCode, data))
+	}
+
+	return nil
+}
+
+// putObject sends the PutObject request for the key in the bucket to the client's region.
+func (c *Client) putObject(ctx context.Context, bucket, key string, open func() (io.ReadCloser, error), size int64, sum []byte) (*http.Response, error) {
+	body, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.objectEndpoint(bucket, key), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	// S3 checks the uploaded content against the payload's hash, and stores its checksum for downloads to verify
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum))
+	req.Header.Set("X-Amz-Checksum-Sha256", base64.StdEncoding.EncodeToString(sum))
+
+	return c.send(ctx, req, nil, s3Service)
+}
+
+// objectEndpoint gets the URL of the object with the key in the bucket in the client's region.
+func (c *Client) objectEndpoint(bucket, key string) string {
+	// Keys are escaped like paths, except that their slashes are kept
+	escaped := strings.ReplaceAll(url.PathEscape(key), "%2F", "/")
+	if c.Endpoint != "" {
+		return fmt.Sprintf("%s/%s/%s", c.Endpoint, bucket, escaped)
+	}
+
+	return fmt.Sprintf("https://%s.%s.%s.amazonaws.com/%s", bucket, s3Service, c.Region, escaped)
+}
+
+// decodeS3Error decodes the error response of S3, whose root element is the error.
+func decodeS3Error(status int, data []byte) error {
+	var e struct {
+		Code    string
+		Message string
+	}
+	apiErr := &APIError{StatusCode: status, Code: http.StatusText(status)}
+	if err := xml.Unmarshal(data, &e); err == nil {
+		if e.Code != "" {
+			apiErr.Code = e.Code
+		}
+		apiErr.Message = e.Message
+	}
+
+	return apiErr
+}
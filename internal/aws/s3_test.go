@@ -0,0 +1,125 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseS3URI(t *testing.T) {
+	bucket, key, err := ParseS3URI("s3://images/macos/golden image.dmg")
+	assert.NoError(t, err)
+	assert.Equal(t, "images", bucket)
+	assert.Equal(t, "macos/golden image.dmg", key)
+
+	for _, uri := range []string{"https://images/golden.dmg", "s3://images", "s3://images/", "s3:///golden.dmg"} {
+		_, _, err = ParseS3URI(uri)
+		assert.Error(t, err, uri)
+	}
+}
+
+func TestClient_GetObject(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/images/macos/golden%20image.dmg", r.URL.EscapedPath())
+		assert.Equal(t, hashHex(nil), r.Header.Get("X-Amz-Content-Sha256"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/s3/aws4_request"))
+
+		w.Header().Set("X-Amz-Checksum-Sha256", "checksum")
+		w.Header().Set("X-Amz-Checksum-Type", "FULL_OBJECT")
+		w.Write([]byte("image"))
+	})
+
+	object, err := c.GetObject(context.Background(), "images", "macos/golden image.dmg")
+	assert.NoError(t, err)
+	defer object.Body.Close()
+
+	data, err := io.ReadAll(object.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "image", string(data))
+	assert.Equal(t, "checksum", object.ChecksumSHA256)
+	assert.Equal(t, "FULL_OBJECT", object.ChecksumType)
+}
+
+func TestClient_GetObject_OtherRegion(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "/eu-west-1/s3/") {
+			w.Header().Set(bucketRegionHeader, "eu-west-1")
+			w.WriteHeader(http.StatusMovedPermanently)
+			return
+		}
+		w.Write([]byte("image"))
+	})
+
+	object, err := c.GetObject(context.Background(), "images", "golden.dmg")
+	assert.NoError(t, err)
+	defer object.Body.Close()
+
+	data, err := io.ReadAll(object.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "image", string(data))
+}
+
+func TestClient_GetObject_NotFound(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+	})
+
+	_, err := c.GetObject(context.Background(), "images", "missing.dmg")
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "NoSuchKey", apiErr.Code)
+}
+
+func TestClient_PutObject(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/diagnostics/i-0123456789abcdef0/report%201.tar.gz", r.URL.EscapedPath())
+		assert.Equal(t, hashHex([]byte("report")), r.Header.Get("X-Amz-Content-Sha256"))
+		assert.NotEmpty(t, r.Header.Get("X-Amz-Checksum-Sha256"))
+
+		data, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "report", string(data))
+	})
+
+	err := c.PutObject(context.Background(), "diagnostics", "i-0123456789abcdef0/report 1.tar.gz", []byte("report"))
+	assert.NoError(t, err)
+}
+
+func TestClient_PutObject_Denied(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`))
+	})
+
+	err := c.PutObject(context.Background(), "diagnostics", "report.tar.gz", []byte("report"))
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "AccessDenied", apiErr.Code)
+}
+
+func TestClient_PutFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sysdiagnose.tar.gz")
+	assert.NoError(t, os.WriteFile(path, []byte("archive"), 0o600))
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/diagnostics/sysdiagnose.tar.gz", r.URL.EscapedPath())
+		assert.Equal(t, int64(7), r.ContentLength)
+		assert.Equal(t, hashHex([]byte("archive")), r.Header.Get("X-Amz-Content-Sha256"))
+
+		data, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "archive", string(data))
+	})
+
+	assert.NoError(t, c.PutFile(context.Background(), "diagnostics", "sysdiagnose.tar.gz", path))
+}
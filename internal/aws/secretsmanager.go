@@ -0,0 +1,133 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// secretsManagerService is the signing name and endpoint prefix of AWS Secrets Manager.
+const secretsManagerService = "secretsmanager"
+
+// Secret is the current value of a secret. Secrets hold either a string or binary value.
+type Secret struct {
+	// String is the string value of the secret.
+	String *string
+	// Binary is the binary value of the secret.
+	Binary []byte
+}
+
+// GetSecret fetches the current value of the secret with the given name or ARN.
+func (c *Client) GetSecret(ctx context.Context, secretID string) (*Secret, error) {
+	in := struct {
+		SecretID string `json:"SecretId"`
+	}{SecretID: secretID}
+	var out struct {
+		SecretString *string
+		SecretBinary []byte
+	}
+
+	if err := c.doJSON(ctx, secretsManagerService, "secretsmanager.GetSecretValue", in, &out); err != nil {
+		return nil, fmt.Errorf("cannot get secret %s: %w", secretID, err)
+	}
+
+	return &Secret{String: out.SecretString, Binary: out.SecretBinary}, nil
+}
+
+// GetSecretValue fetches the current string value of the secret with the given name or ARN.
+func (c *Client) GetSecretValue(ctx context.Context, secretID string) (string, error) {
+	secret, err := c.GetSecret(ctx, secretID)
+	if err != nil {
+		return "", err
+	}
+	if secret.String == nil {
+		return "", fmt.Errorf("secret %s has no string value", secretID)
+	}
+
+	return *secret.String, nil
+}
+
+// PutSecretValue stores a new current string value for the secret with the given name or ARN.
+func (c *Client) PutSecretValue(ctx context.Context, secretID string, value string) error {
+	in := struct {
+		SecretID     string `json:"SecretId"`
+		SecretString string
+	}{SecretID: secretID, SecretString: value}
+
+	if err := c.doJSON(ctx, secretsManagerService, "secretsmanager.PutSecretValue", in, nil); err != nil {
+		return fmt.Errorf("cannot put secret %s: %w", secretID, err)
+	}
+
+	return nil
+}
+
+// ErrSecretExists is returned by CreateSecret when there's already a secret with the name.
+var ErrSecretExists = errors.New("aws: secret already exists")
+
+// CreateSecret creates a secret with the given name, description, and string value. ErrSecretExists is returned when
+// the secret already exists, its value can be replaced with PutSecretValue.
+func (c *Client) CreateSecret(ctx context.Context, name string, description string, value string) error {
+	in := struct {
+		Name         string
+		Description  string `json:",omitempty"`
+		SecretString string
+	}{Name: name, Description: description, SecretString: value}
+
+	if err := c.doJSON(ctx, secretsManagerService, "secretsmanager.CreateSecret", in, nil); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Code == "ResourceExistsException" {
+			return fmt.Errorf("%w: %s", ErrSecretExists, name)
+		}
+
+		return fmt.Errorf("cannot create secret %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// SecretField gets a field of a secret whose value is a JSON object (e.g. {"password": "..."}). The secret's whole
+// value is returned when field is empty.
+func SecretField(value string, field string) (string, error) {
+	if field == "" {
+		return value, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %w", err)
+	}
+	v, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("secret has no field %q", field)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("secret field %q is not a string", field)
+	}
+
+	return s, nil
+}
+
+// SetSecretField sets a field of a secret whose value is a JSON object and returns the updated value. The other
+// fields are kept as-is. The new value replaces the secret's whole value when field is empty.
+func SetSecretField(value string, field string, fieldValue string) (string, error) {
+	if field == "" {
+		return fieldValue, nil
+	}
+
+	fields := map[string]interface{}{}
+	if value != "" {
+		if err := json.Unmarshal([]byte(value), &fields); err != nil {
+			return "", fmt.Errorf("secret is not a JSON object: %w", err)
+		}
+	}
+	fields[field] = fieldValue
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,141 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestClient creates a client which sends requests to the handler.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return &Client{
+		Region:      "us-east-1",
+		Credentials: staticProvider{creds: testCredentials},
+		HTTPClient:  server.Client(),
+		Endpoint:    server.URL,
+		now:         func() time.Time { return testTime },
+	}
+}
+
+func TestClient_GetSecretValue(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/secretsmanager/aws4_request"))
+
+		var in map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		assert.Equal(t, "ci/password", in["SecretId"])
+
+		w.Write([]byte(`{"Name":"ci/password","SecretString":"hunter2"}`))
+	})
+
+	value, err := c.GetSecretValue(context.Background(), "ci/password")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", value)
+}
+
+func TestClient_GetSecretValue_NotFound(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"Secrets Manager can't find the specified secret."}`))
+	})
+
+	_, err := c.GetSecretValue(context.Background(), "missing")
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "ResourceNotFoundException", apiErr.Code)
+}
+
+func TestClient_GetSecret_Binary(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Name":"ci/signing","SecretBinary":"AAECAw=="}`))
+	})
+
+	secret, err := c.GetSecret(context.Background(), "ci/signing")
+
+	assert.NoError(t, err)
+	assert.Nil(t, secret.String)
+	assert.Equal(t, []byte{0, 1, 2, 3}, secret.Binary)
+
+	_, err = c.GetSecretValue(context.Background(), "ci/signing")
+	assert.Error(t, err, "binary secrets have no string value")
+}
+
+func TestClient_PutSecretValue(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secretsmanager.PutSecretValue", r.Header.Get("X-Amz-Target"))
+
+		var in map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		assert.Equal(t, "rotated", in["SecretString"])
+
+		w.Write([]byte(`{}`))
+	})
+
+	assert.NoError(t, c.PutSecretValue(context.Background(), "ci/password", "rotated"))
+}
+
+func TestClient_CreateSecret(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secretsmanager.CreateSecret", r.Header.Get("X-Amz-Target"))
+
+		var in map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		assert.Equal(t, "ci/password", in["Name"])
+		assert.Equal(t, "hunter2", in["SecretString"])
+
+		w.Write([]byte(`{"Name":"ci/password"}`))
+	})
+
+	assert.NoError(t, c.CreateSecret(context.Background(), "ci/password", "", "hunter2"))
+}
+
+func TestClient_CreateSecret_Exists(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"ResourceExistsException","message":"The operation failed because the secret ci/password already exists."}`))
+	})
+
+	err := c.CreateSecret(context.Background(), "ci/password", "", "hunter2")
+
+	assert.True(t, errors.Is(err, ErrSecretExists), "existing secrets should be reported")
+}
+
+func TestSecretField(t *testing.T) {
+	value, err := SecretField(`{"username":"runner","password":"hunter2"}`, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", value)
+
+	value, err = SecretField("hunter2", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", value, "whole value returned without a field")
+
+	_, err = SecretField("hunter2", "password")
+	assert.Error(t, err, "plain secrets have no fields")
+}
+
+func TestSetSecretField(t *testing.T) {
+	value, err := SetSecretField(`{"username":"runner","password":"hunter2"}`, "password", "rotated")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"username":"runner","password":"rotated"}`, value)
+
+	value, err = SetSecretField("", "password", "rotated")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"password":"rotated"}`, value, "empty secrets become objects")
+
+	value, err = SetSecretField("hunter2", "", "rotated")
+	assert.NoError(t, err)
+	assert.Equal(t, "rotated", value)
+}
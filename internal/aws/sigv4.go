@@ -0,0 +1,148 @@
+package aws
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// signingAlgorithm is the name of the Signature Version 4 algorithm.
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	// amzDateFormat is the format of timestamps used when signing requests.
+	amzDateFormat = "20060102T150405Z"
+	// shortDateFormat is the format of the date in a signature's credential scope.
+	shortDateFormat = "20060102"
+)
+
+// signRequest signs the request with AWS Signature Version 4 by setting its X-Amz-Date, X-Amz-Security-Token (for
+// temporary credentials), and Authorization headers. The body must be the request's complete payload, unless the
+// payload's hash is already set in the X-Amz-Content-Sha256 header (e.g. for files streamed to S3).
+func signRequest(req *http.Request, body []byte, creds Credentials, service, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = hashHex(body)
+	}
+	canonicalHeaders, signedHeaders := canonicalizeHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{now.Format(shortDateFormat), region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), now.Format(shortDateFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalizeHeaders builds the canonical headers and the list of signed headers for the request. The host header
+// is always signed.
+func canonicalizeHeaders(req *http.Request) (string, string) {
+	headers := map[string]string{"host": req.Host}
+	if req.Host == "" {
+		headers["host"] = req.URL.Host
+	}
+	for k, v := range req.Header {
+		name := strings.ToLower(k)
+		if name == "authorization" || name == "user-agent" {
+			continue
+		}
+		values := make([]string, 0, len(v))
+		for _, s := range v {
+			values = append(values, strings.Join(strings.Fields(s), " "))
+		}
+		headers[name] = strings.Join(values, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	return canonical.String(), strings.Join(names, ";")
+}
+
+// canonicalURI gets the URI-encoded path of the URL.
+func canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+
+	return path
+}
+
+// canonicalQuery gets the query string of the URL with its parameters sorted and URI-encoded.
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var params []string
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+
+	return strings.Join(params, "&")
+}
+
+// uriEncode encodes the string as required by Signature Version 4, which differs from url.QueryEscape in encoding
+// spaces as %20.
+func uriEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// hashHex gets the hex-encoded SHA-256 hash of the data.
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 computes the HMAC-SHA256 of the data with the key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,46 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// snsService is the signing name and endpoint prefix of Amazon SNS.
+	snsService = "sns"
+	// snsVersion is the version of the SNS Query API.
+	snsVersion = "2010-03-31"
+)
+
+// Publish sends the message to the SNS topic with the given ARN and returns the ID of the published message. The
+// subject is used as the subject of email notifications and is left out when empty.
+func (c *Client) Publish(ctx context.Context, topicARN, subject, message string) (string, error) {
+	params := url.Values{}
+	params.Set("TopicArn", topicARN)
+	params.Set("Message", message)
+	if subject != "" {
+		params.Set("Subject", subject)
+	}
+	var out struct {
+		MessageID string `xml:"PublishResult>MessageId"`
+	}
+
+	if err := c.doQuery(ctx, snsService, "Publish", snsVersion, params, &out); err != nil {
+		return "", fmt.Errorf("cannot publish to %s: %w", topicARN, err)
+	}
+
+	return out.MessageID, nil
+}
+
+// RegionFromARN gets the AWS Region of the resource with the given ARN (e.g. "us-east-1" for
+// "arn:aws:sns:us-east-1:123456789012:alerts").
+func RegionFromARN(arn string) (string, error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[3] == "" {
+		return "", fmt.Errorf("invalid ARN %q", arn)
+	}
+
+	return parts[3], nil
+}
@@ -0,0 +1,54 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Publish(t *testing.T) {
+	const topic = "arn:aws:sns:us-east-1:123456789012:alerts"
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/sns/aws4_request"))
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "Publish", r.PostForm.Get("Action"))
+		assert.Equal(t, topic, r.PostForm.Get("TopicArn"))
+		assert.Equal(t, "grow failed", r.PostForm.Get("Subject"))
+		assert.Equal(t, `{"operation":"grow"}`, r.PostForm.Get("Message"))
+
+		w.Write([]byte(`<PublishResponse><PublishResult><MessageId>567910cd-659e-55d4-8ccb-5aaf14679dc0</MessageId></PublishResult></PublishResponse>`))
+	})
+
+	id, err := c.Publish(context.Background(), topic, "grow failed", `{"operation":"grow"}`)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "567910cd-659e-55d4-8ccb-5aaf14679dc0", id)
+}
+
+func TestClient_Publish_NotFound(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>NotFound</Code><Message>Topic does not exist</Message></Error></ErrorResponse>`))
+	})
+
+	_, err := c.Publish(context.Background(), "arn:aws:sns:us-east-1:123456789012:missing", "", "message")
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "NotFound", apiErr.Code)
+	assert.Equal(t, "Topic does not exist", apiErr.Message)
+}
+
+func TestRegionFromARN(t *testing.T) {
+	region, err := RegionFromARN("arn:aws:sns:eu-west-2:123456789012:alerts")
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-west-2", region)
+
+	_, err = RegionFromARN("alerts")
+	assert.Error(t, err)
+}
@@ -0,0 +1,157 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ssmService is the signing name and endpoint prefix of AWS Systems Manager.
+const ssmService = "ssm"
+
+// CommandInvocation is the status and output of a command sent to an instance with SendCommand.
+type CommandInvocation struct {
+	// Status is the status of the command (e.g. "InProgress", "Success", or "Failed").
+	Status string
+	// ResponseCode is the command's exit status, which is -1 until it finishes.
+	ResponseCode int
+	// StandardOutputContent is the first 24,000 characters of the command's standard output.
+	StandardOutputContent string
+	// StandardErrorContent is the first 8,000 characters of the command's standard error.
+	StandardErrorContent string
+}
+
+// SendCommand runs the shell commands on the instance with the AWS-RunShellScript document and returns the ID
+// of the command. The command is cancelled when it hasn't finished after timeoutSeconds.
+func (c *Client) SendCommand(ctx context.Context, instanceID string, commands []string, timeoutSeconds int) (string, error) {
+	in := struct {
+		DocumentName string
+		InstanceIDs  []string `json:"InstanceIds"`
+		Parameters   map[string][]string
+	}{
+		DocumentName: "AWS-RunShellScript",
+		InstanceIDs:  []string{instanceID},
+		Parameters: map[string][]string{
+			"commands":         commands,
+			"executionTimeout": {fmt.Sprint(timeoutSeconds)},
+		},
+	}
+	var out struct {
+		Command struct {
+			CommandID string `json:"CommandId"`
+		}
+	}
+
+	if err := c.doJSON(ctx, ssmService, "AmazonSSM.SendCommand", in, &out); err != nil {
+		return "", fmt.Errorf("cannot send command to %s: %w", instanceID, err)
+	}
+
+	return out.Command.CommandID, nil
+}
+
+// GetCommandInvocation fetches the status and output of the command sent to the instance.
+func (c *Client) GetCommandInvocation(ctx context.Context, commandID string, instanceID string) (*CommandInvocation, error) {
+	in := struct {
+		CommandID  string `json:"CommandId"`
+		InstanceID string `json:"InstanceId"`
+	}{CommandID: commandID, InstanceID: instanceID}
+	var out CommandInvocation
+
+	if err := c.doJSON(ctx, ssmService, "AmazonSSM.GetCommandInvocation", in, &out); err != nil {
+		return nil, fmt.Errorf("cannot get command %s on %s: %w", commandID, instanceID, err)
+	}
+
+	return &out, nil
+}
+
+// ErrParameterNotFound is returned by GetParameter when there's no parameter with the name.
+var ErrParameterNotFound = errors.New("aws: parameter not found")
+
+// GetParameter fetches the value of the Parameter Store parameter with the given name, decrypting SecureString
+// parameters. ErrParameterNotFound is returned when the parameter doesn't exist.
+func (c *Client) GetParameter(ctx context.Context, name string) (string, error) {
+	in := struct {
+		Name           string
+		WithDecryption bool
+	}{Name: name, WithDecryption: true}
+	var out struct {
+		Parameter struct {
+			Value string
+		}
+	}
+
+	if err := c.doJSON(ctx, ssmService, "AmazonSSM.GetParameter", in, &out); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Code == "ParameterNotFound" {
+			return "", fmt.Errorf("%w: %s", ErrParameterNotFound, name)
+		}
+
+		return "", fmt.Errorf("cannot get parameter %s: %w", name, err)
+	}
+
+	return out.Parameter.Value, nil
+}
+
+// PutParameter stores the value in the Parameter Store parameter with the given name as a SecureString, which is
+// encrypted with the account's default key. Existing parameters are overwritten.
+func (c *Client) PutParameter(ctx context.Context, name string, description string, value string) error {
+	in := struct {
+		Name        string
+		Description string `json:",omitempty"`
+		Value       string
+		Type        string
+		Overwrite   bool
+	}{Name: name, Description: description, Value: value, Type: "SecureString", Overwrite: true}
+
+	if err := c.doJSON(ctx, ssmService, "AmazonSSM.PutParameter", in, nil); err != nil {
+		return fmt.Errorf("cannot put parameter %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// Parameter is a Parameter Store parameter fetched with GetParametersByPath.
+type Parameter struct {
+	// Name is the parameter's full name (e.g. "/ec2-macos-utils/ephemeral-keys/i-0123456789abcdef0/ec2-user").
+	Name string
+	// Value is the parameter's value, decrypted for SecureString parameters.
+	Value string
+	// LastModified is when the parameter's value was last changed.
+	LastModified time.Time
+}
+
+// GetParametersByPath fetches the Parameter Store parameters directly under the path, decrypting SecureString
+// parameters. Parameters nested deeper under the path aren't included.
+func (c *Client) GetParametersByPath(ctx context.Context, path string) ([]Parameter, error) {
+	var params []Parameter
+	var token string
+	for {
+		in := struct {
+			Path           string
+			WithDecryption bool
+			NextToken      string `json:",omitempty"`
+		}{Path: path, WithDecryption: true, NextToken: token}
+		var out struct {
+			Parameters []struct {
+				Name             string
+				Value            string
+				LastModifiedDate float64
+			}
+			NextToken string
+		}
+
+		if err := c.doJSON(ctx, ssmService, "AmazonSSM.GetParametersByPath", in, &out); err != nil {
+			return nil, fmt.Errorf("cannot get parameters by path %s: %w", path, err)
+		}
+		for _, p := range out.Parameters {
+			sec := int64(p.LastModifiedDate)
+			nsec := int64((p.LastModifiedDate - float64(sec)) * float64(time.Second))
+			params = append(params, Parameter{Name: p.Name, Value: p.Value, LastModified: time.Unix(sec, nsec).UTC()})
+		}
+		if out.NextToken == "" {
+			return params, nil
+		}
+		token = out.NextToken
+	}
+}
@@ -0,0 +1,134 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_SendCommand(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "AmazonSSM.SendCommand", r.Header.Get("X-Amz-Target"))
+
+		var in struct {
+			DocumentName string
+			InstanceIds  []string
+			Parameters   map[string][]string
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		assert.Equal(t, "AWS-RunShellScript", in.DocumentName)
+		assert.Equal(t, []string{"i-0123456789abcdef0"}, in.InstanceIds)
+		assert.Equal(t, []string{"diskutil list -plist"}, in.Parameters["commands"])
+		assert.Equal(t, []string{"600"}, in.Parameters["executionTimeout"])
+
+		w.Write([]byte(`{"Command":{"CommandId":"0e3c2f4e-1234-5678-9abc-def012345678"}}`))
+	})
+
+	id, err := c.SendCommand(context.Background(), "i-0123456789abcdef0", []string{"diskutil list -plist"}, 600)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "0e3c2f4e-1234-5678-9abc-def012345678", id)
+}
+
+func TestClient_GetCommandInvocation(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "AmazonSSM.GetCommandInvocation", r.Header.Get("X-Amz-Target"))
+
+		w.Write([]byte(`{"Status":"Failed","ResponseCode":1,"StandardOutputContent":"","StandardErrorContent":"Could not find disk: disk9"}`))
+	})
+
+	inv, err := c.GetCommandInvocation(context.Background(), "0e3c2f4e-1234-5678-9abc-def012345678", "i-0123456789abcdef0")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &CommandInvocation{Status: "Failed", ResponseCode: 1, StandardErrorContent: "Could not find disk: disk9"}, inv)
+}
+
+func TestClient_GetParameter(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "AmazonSSM.GetParameter", r.Header.Get("X-Amz-Target"))
+
+		var in struct {
+			Name           string
+			WithDecryption bool
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		assert.Equal(t, "/ec2-macos-utils/automation", in.Name)
+		assert.True(t, in.WithDecryption, "SecureString parameters should be decrypted")
+
+		w.Write([]byte(`{"Parameter":{"Name":"/ec2-macos-utils/automation","Type":"String","Value":"disabled"}}`))
+	})
+
+	value, err := c.GetParameter(context.Background(), "/ec2-macos-utils/automation")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "disabled", value)
+}
+
+func TestClient_GetParameter_NotFound(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"ParameterNotFound"}`))
+	})
+
+	_, err := c.GetParameter(context.Background(), "/ec2-macos-utils/automation")
+
+	assert.True(t, errors.Is(err, ErrParameterNotFound), "missing parameters should be reported")
+}
+
+func TestClient_PutParameter(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "AmazonSSM.PutParameter", r.Header.Get("X-Amz-Target"))
+
+		var in struct {
+			Name      string
+			Value     string
+			Type      string
+			Overwrite bool
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		assert.Equal(t, "/ec2-macos-utils/automation", in.Name)
+		assert.Equal(t, "paused", in.Value)
+		assert.Equal(t, "SecureString", in.Type, "values should be encrypted")
+		assert.True(t, in.Overwrite)
+
+		w.Write([]byte(`{"Version":2,"Tier":"Standard"}`))
+	})
+
+	assert.NoError(t, c.PutParameter(context.Background(), "/ec2-macos-utils/automation", "", "paused"))
+}
+
+func TestClient_GetParametersByPath(t *testing.T) {
+	requests := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "AmazonSSM.GetParametersByPath", r.Header.Get("X-Amz-Target"))
+
+		var in struct {
+			Path           string
+			WithDecryption bool
+			NextToken      string
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		assert.Equal(t, "/ec2-macos-utils/ephemeral-keys", in.Path)
+		requests++
+		if in.NextToken == "" {
+			w.Write([]byte(`{"Parameters":[{"Name":"/ec2-macos-utils/ephemeral-keys/ec2-user","Value":"ssh-ed25519 AAAA","LastModifiedDate":1.7000000005E9}],"NextToken":"page-2"}`))
+			return
+		}
+		assert.Equal(t, "page-2", in.NextToken)
+		w.Write([]byte(`{"Parameters":[{"Name":"/ec2-macos-utils/ephemeral-keys/ci","Value":"ssh-rsa AAAA","LastModifiedDate":1700000100}]}`))
+	})
+
+	params, err := c.GetParametersByPath(context.Background(), "/ec2-macos-utils/ephemeral-keys")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests, "every page should be fetched")
+	assert.Equal(t, []Parameter{
+		{Name: "/ec2-macos-utils/ephemeral-keys/ec2-user", Value: "ssh-ed25519 AAAA", LastModified: time.Unix(1700000000, 500000000).UTC()},
+		{Name: "/ec2-macos-utils/ephemeral-keys/ci", Value: "ssh-rsa AAAA", LastModified: time.Unix(1700000100, 0).UTC()},
+	}, params)
+}
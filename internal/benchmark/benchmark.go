@@ -0,0 +1,247 @@
+// Package benchmark provides a simple disk I/O benchmark for validating the performance of a volume (e.g. the
+// throughput and IOPS provisioned for an EBS volume) from the instance.
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultFileSize is the default size of the file that the benchmark reads and writes (1 GiB).
+	DefaultFileSize = 1 << 30
+	// DefaultSequentialBlockSize is the default size of each sequential read and write (1 MiB).
+	DefaultSequentialBlockSize = 1 << 20
+	// DefaultRandomBlockSize is the default size of each random read and write (4 KiB).
+	DefaultRandomBlockSize = 4 << 10
+	// DefaultDuration is the default run duration of each timed test.
+	DefaultDuration = 10 * time.Second
+
+	// filePattern is the pattern of the benchmark file's name, which is removed once the benchmark finishes.
+	filePattern = ".ec2-macos-utils-benchmark-*"
+)
+
+// Test is one of the access patterns that the benchmark measures.
+type Test string
+
+const (
+	// TestSequentialWrite writes the file from start to end once.
+	TestSequentialWrite Test = "sequential-write"
+	// TestSequentialRead reads the file from start to end, starting over at the end, for the duration.
+	TestSequentialRead Test = "sequential-read"
+	// TestRandomRead reads blocks at random offsets in the file for the duration.
+	TestRandomRead Test = "random-read"
+	// TestRandomWrite writes blocks at random offsets in the file for the duration.
+	TestRandomWrite Test = "random-write"
+)
+
+// Config configures a benchmark run.
+type Config struct {
+	// Dir is the directory, on the volume being benchmarked, that the benchmark file is created in.
+	Dir string
+	// FileSize is the size of the benchmark file, which should be larger than any caches in front of the volume.
+	FileSize int64
+	// SequentialBlockSize is the size of each sequential read and write.
+	SequentialBlockSize int
+	// RandomBlockSize is the size of each random read and write.
+	RandomBlockSize int
+	// Duration is the run duration of each test other than the sequential write, which always writes the whole file.
+	Duration time.Duration
+}
+
+// Validate checks that the Config describes a benchmark that can be run.
+func (c Config) Validate() error {
+	switch {
+	case c.Dir == "":
+		return errors.New("benchmark: directory required")
+	case c.SequentialBlockSize <= 0 || c.RandomBlockSize <= 0:
+		return errors.New("benchmark: block sizes must be positive")
+	case c.FileSize < int64(c.SequentialBlockSize) || c.FileSize < int64(c.RandomBlockSize):
+		return fmt.Errorf("benchmark: file size %d is smaller than the block sizes", c.FileSize)
+	case c.Duration <= 0:
+		return errors.New("benchmark: duration must be positive")
+	}
+
+	return nil
+}
+
+// Latency summarizes the latencies of a test's operations in microseconds.
+type Latency struct {
+	Mean float64 `json:"mean_us"`
+	P50  float64 `json:"p50_us"`
+	P99  float64 `json:"p99_us"`
+	Max  float64 `json:"max_us"`
+}
+
+// Result is the outcome of a single test.
+type Result struct {
+	Test       Test    `json:"test"`
+	BlockSize  int     `json:"block_size"`
+	Operations int     `json:"operations"`
+	Bytes      int64   `json:"bytes"`
+	Seconds    float64 `json:"seconds"`
+	// Throughput is the rate that data was read or written in MiB per second.
+	Throughput float64 `json:"throughput_mibps"`
+	IOPS       float64 `json:"iops"`
+	Latency    Latency `json:"latency"`
+}
+
+// Report is the outcome of a benchmark run.
+type Report struct {
+	Dir      string   `json:"dir"`
+	FileSize int64    `json:"file_size"`
+	Direct   bool     `json:"direct_io"`
+	Results  []Result `json:"results"`
+}
+
+// Run benchmarks the volume holding cfg.Dir. A file of cfg.FileSize is written sequentially, then read sequentially,
+// read randomly, and written randomly, each for cfg.Duration. The file is opened for direct I/O where supported so
+// that the page cache doesn't hide the volume's performance. The file is removed once the run finishes.
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(cfg.Dir, filePattern)
+	if err != nil {
+		return nil, fmt.Errorf("benchmark: cannot create file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	report := &Report{Dir: cfg.Dir, FileSize: cfg.FileSize, Direct: true}
+	if err := setDirectIO(f); err != nil {
+		if !errors.Is(err, errDirectIOUnsupported) {
+			return nil, fmt.Errorf("benchmark: cannot enable direct I/O: %w", err)
+		}
+		report.Direct = false
+	}
+
+	b := &bench{f: f, cfg: cfg, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	for _, test := range []Test{TestSequentialWrite, TestSequentialRead, TestRandomRead, TestRandomWrite} {
+		result, err := b.run(ctx, test)
+		if err != nil {
+			return nil, fmt.Errorf("benchmark: %s failed: %w", test, err)
+		}
+		report.Results = append(report.Results, *result)
+	}
+
+	return report, nil
+}
+
+// bench runs the tests against the benchmark file.
+type bench struct {
+	f    *os.File
+	cfg  Config
+	rand *rand.Rand
+}
+
+// run runs the test and measures each of its operations.
+func (b *bench) run(ctx context.Context, test Test) (*Result, error) {
+	blockSize := b.cfg.SequentialBlockSize
+	if test == TestRandomRead || test == TestRandomWrite {
+		blockSize = b.cfg.RandomBlockSize
+	}
+	buf := make([]byte, blockSize)
+	if _, err := b.rand.Read(buf); err != nil {
+		return nil, err
+	}
+	// Only whole blocks are read and written so the file's tail, if any, is left out.
+	blocks := b.cfg.FileSize / int64(blockSize)
+
+	var latencies []time.Duration
+	var offset int64
+	start := time.Now()
+	deadline := start.Add(b.cfg.Duration)
+	for i := int64(0); ; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if test == TestSequentialWrite && i == blocks {
+			break
+		}
+		if test != TestSequentialWrite && !time.Now().Before(deadline) {
+			break
+		}
+
+		switch test {
+		case TestSequentialWrite, TestSequentialRead:
+			offset = (i % blocks) * int64(blockSize)
+		default:
+			offset = b.rand.Int63n(blocks) * int64(blockSize)
+		}
+
+		opStart := time.Now()
+		var err error
+		if test == TestSequentialWrite || test == TestRandomWrite {
+			_, err = b.f.WriteAt(buf, offset)
+		} else {
+			_, err = b.f.ReadAt(buf, offset)
+		}
+		if err != nil {
+			return nil, err
+		}
+		latencies = append(latencies, time.Since(opStart))
+	}
+
+	// Writes are only done once they're on the volume, so syncing is part of the test.
+	if test == TestSequentialWrite || test == TestRandomWrite {
+		if err := b.f.Sync(); err != nil {
+			return nil, err
+		}
+	}
+
+	return newResult(test, blockSize, latencies, time.Since(start)), nil
+}
+
+// newResult summarizes the operations of a test which ran for elapsed.
+func newResult(test Test, blockSize int, latencies []time.Duration, elapsed time.Duration) *Result {
+	r := &Result{
+		Test:       test,
+		BlockSize:  blockSize,
+		Operations: len(latencies),
+		Bytes:      int64(len(latencies)) * int64(blockSize),
+		Seconds:    elapsed.Seconds(),
+	}
+	if len(latencies) == 0 || elapsed <= 0 {
+		return r
+	}
+
+	r.Throughput = float64(r.Bytes) / (1 << 20) / r.Seconds
+	r.IOPS = float64(r.Operations) / r.Seconds
+
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, l := range sorted {
+		total += l
+	}
+	r.Latency = Latency{
+		Mean: microseconds(total / time.Duration(len(sorted))),
+		P50:  microseconds(percentile(sorted, 50)),
+		P99:  microseconds(percentile(sorted, 99)),
+		Max:  microseconds(sorted[len(sorted)-1]),
+	}
+
+	return r
+}
+
+// percentile gets the pth percentile of the sorted durations with the nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+// microseconds converts d to fractional microseconds.
+func microseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Microsecond)
+}
@@ -0,0 +1,82 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+
+	report, err := Run(context.Background(), Config{
+		Dir:                 dir,
+		FileSize:            64 << 10,
+		SequentialBlockSize: 4 << 10,
+		RandomBlockSize:     1 << 10,
+		Duration:            10 * time.Millisecond,
+	})
+
+	assert.NoError(t, err)
+	if assert.Len(t, report.Results, 4) {
+		assert.Equal(t, TestSequentialWrite, report.Results[0].Test)
+		assert.Equal(t, 16, report.Results[0].Operations, "the whole file should be written once")
+		assert.Equal(t, int64(64<<10), report.Results[0].Bytes)
+		for _, r := range report.Results {
+			assert.True(t, r.Operations > 0, "%s should have run", r.Test)
+			assert.True(t, r.Latency.P50 <= r.Latency.P99 && r.Latency.P99 <= r.Latency.Max, "%s latencies should be ordered", r.Test)
+		}
+		assert.Equal(t, 1<<10, report.Results[2].BlockSize)
+	}
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries, "the benchmark file should be removed")
+}
+
+func TestRun_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Run(ctx, Config{Dir: t.TempDir(), FileSize: 4 << 10, SequentialBlockSize: 4 << 10, RandomBlockSize: 4 << 10, Duration: time.Second})
+
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Config{Dir: "/Volumes/Data", FileSize: DefaultFileSize, SequentialBlockSize: DefaultSequentialBlockSize, RandomBlockSize: DefaultRandomBlockSize, Duration: DefaultDuration}
+	assert.NoError(t, valid.Validate())
+
+	tests := map[string]func(c *Config){
+		"without directory":       func(c *Config) { c.Dir = "" },
+		"without block size":      func(c *Config) { c.RandomBlockSize = 0 },
+		"file smaller than block": func(c *Config) { c.FileSize = 1024 },
+		"without duration":        func(c *Config) { c.Duration = 0 },
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := valid
+			modify(&c)
+
+			assert.Error(t, c.Validate())
+		})
+	}
+}
+
+func TestNewResult(t *testing.T) {
+	latencies := make([]time.Duration, 0, 100)
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Microsecond)
+	}
+
+	r := newResult(TestRandomRead, 4096, latencies, time.Second)
+
+	assert.Equal(t, 100, r.Operations)
+	assert.Equal(t, int64(409600), r.Bytes)
+	assert.Equal(t, 100.0, r.IOPS)
+	assert.Equal(t, Latency{Mean: 50.5, P50: 50, P99: 99, Max: 100}, r.Latency)
+}
@@ -0,0 +1,17 @@
+package benchmark
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// errDirectIOUnsupported is returned by setDirectIO when direct I/O isn't supported.
+var errDirectIOUnsupported = unix.ENOTSUP
+
+// setDirectIO turns off caching of the file's data with F_NOCACHE so that reads and writes go to the volume.
+func setDirectIO(f *os.File) error {
+	_, err := unix.FcntlInt(f.Fd(), unix.F_NOCACHE, 1)
+
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build !darwin
+
+package benchmark
+
+import (
+	"errors"
+	"os"
+)
+
+// errDirectIOUnsupported is returned by setDirectIO when direct I/O isn't supported.
+var errDirectIOUnsupported = errors.New("direct I/O is only supported on macOS")
+
+// setDirectIO isn't supported outside of macOS, the benchmark runs through the page cache instead.
+func setDirectIO(f *os.File) error {
+	return errDirectIOUnsupported
+}
@@ -11,4 +11,8 @@ var (
 
 	// Version is the latest version of the utility. This variable gets set at build-time.
 	Version string
+
+	// ReleasePublicKey is the base64-encoded Ed25519 key that release binaries are signed with, which self-updates
+	// verify downloads against. This variable gets set at build-time.
+	ReleasePublicKey string
 )
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/fetch"
+	"github.com/aws/ec2-macos-utils/internal/imds"
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+// applyDefaultTimeout is the default maximum run duration for converging the host with a document. Provisioning
+// volumes and creating users take much longer than the other tasks.
+const applyDefaultTimeout = 30 * time.Minute
+
+// applyReport is the change set of converging the host with a document.
+type applyReport struct {
+	// DryRun indicates that the changes were only checked and weren't made.
+	DryRun bool `json:"dry_run"`
+	// Changed indicates that at least one task made, or would have made, changes.
+	Changed bool `json:"changed"`
+	// Failed indicates that at least one task couldn't be applied.
+	Failed bool `json:"failed"`
+	// Tasks are the results of applying each task.
+	Tasks []driftResult `json:"tasks"`
+}
+
+// applyCommand creates a new command which converges the host with a document describing its desired state.
+func applyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply <document>",
+		Short: "converge the host with a document describing its desired state",
+		Long: strings.TrimSpace(`
+apply converges the host with a single YAML or JSON document
+describing its desired state, read from a file, an S3 URI
+(s3://bucket/key) downloaded with the instance's credentials, or
+stdin when the document is "-". The document has the same format
+as the configuration file: system settings and time sync, users,
+preferences, the firewall, power settings, interface MTUs, DNS and
+proxy settings, SSH keys and sshd settings, volumes, and mounts.
+
+Each section is applied in that order with the same tasks as the
+commands that manage it, so users exist before their preferences
+and keys are set, and volumes are provisioned before mounts are
+persisted. Sections that aren't in the document are left as they
+are. The power section applies its settings on top of the server
+settings, so an empty one (power: {}) applies the server settings.
+A task that fails doesn't stop the ones after it.
+
+The changes that were made are reported as JSON, unless another
+output format is selected, and the command fails when any task
+couldn't be applied. With --dry-run, the changes that would be
+made are reported instead.
+`),
+		Args:        cobra.ExactArgs(1),
+		Annotations: map[string]string{publishesEventsAnnotation: "true"},
+	}
+
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", applyDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Most sections (e.g. users, sshd, and mounts) require root permissions to check and apply.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			c, err := readApplyDocument(ctx, &fetch.Fetcher{}, args[0], cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			tasks, err := configTasks(ctx, c)
+			if err != nil {
+				return err
+			}
+			report := applyTasks(ctx, tasks, dryrun)
+
+			format := outputJSON
+			if f := cmd.Flags().Lookup("output"); f != nil && f.Changed {
+				format = outputFormat(cmd)
+			}
+			if err := printOutput(cmd.OutOrStdout(), format, report, func(w io.Writer) error {
+				return printDriftTable(w, report.Tasks)
+			}); err != nil {
+				return err
+			}
+			if report.Failed {
+				return errors.New("host couldn't be converged with the document")
+			}
+
+			return nil
+		})
+	}
+
+	// Keep other disk operations on the host from running alongside this one since volumes may be provisioned.
+	lockDiskOperation(cmd)
+
+	return cmd
+}
+
+// readApplyDocument reads the document from the file, the S3 URI, or stdin when it's "-". Unlike the configuration
+// file, missing documents are an error since they're given explicitly.
+func readApplyDocument(ctx context.Context, fetcher *fetch.Fetcher, path string, stdin io.Reader) (*config.Config, error) {
+	var data []byte
+	var err error
+	switch {
+	case path == "-":
+		data, err = io.ReadAll(stdin)
+	case aws.IsS3URI(path):
+		data, err = fetchS3Object(ctx, fetcher, path, "")
+	default:
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read document %s: %w", path, err)
+	}
+
+	c, err := config.DecodeTemplate(bytes.NewReader(data), templateVars(ctx, imds.NewCachedClient()))
+	if err != nil {
+		return nil, fmt.Errorf("cannot load document %s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// applyTasks applies each task, or only checks it when dryrun is set. Tasks that fail are reported rather than
+// stopping the remaining tasks.
+func applyTasks(ctx context.Context, tasks []task.Task, dryrun bool) *applyReport {
+	report := &applyReport{DryRun: dryrun, Tasks: []driftResult{}}
+	for _, t := range tasks {
+		log := logrus.WithField("task", t.Name())
+		result := driftResult{Task: t.Name(), Changes: []task.Change{}}
+
+		var changes []task.Change
+		var err error
+		if dryrun {
+			changes, err = t.Check(ctx)
+		} else {
+			changes, err = t.Apply(ctx)
+		}
+		switch {
+		case err != nil:
+			log.WithError(err).Error("Failed to apply task")
+			result.Error = err.Error()
+			report.Failed = true
+		case len(changes) == 0:
+			log.Info("Already configured, nothing to do")
+		case dryrun:
+			log.Warn("Would have applied changes")
+		default:
+			log.Info("Successfully applied changes")
+		}
+		if changes != nil {
+			result.Changes = changes
+			report.Changed = report.Changed || len(changes) != 0
+		}
+		report.Tasks = append(report.Tasks, result)
+	}
+
+	return report
+}
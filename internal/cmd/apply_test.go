@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/fetch"
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/internal/users"
+)
+
+func TestApplyTasks(t *testing.T) {
+	failed := &fakeTask{err: errors.New("pmset failed")}
+	changed := &fakeTask{changes: []task.Change{{Setting: "mtu.en0", Current: "1500", Desired: "9001"}}}
+
+	report := applyTasks(context.Background(), []task.Task{failed, changed}, false)
+
+	assert.True(t, report.Failed)
+	assert.True(t, report.Changed)
+	assert.True(t, changed.applied, "tasks after a failed task should still be applied")
+	assert.Equal(t, []driftResult{
+		{Task: "fake", Changes: []task.Change{}, Error: "pmset failed"},
+		{Task: "fake", Changes: changed.changes},
+	}, report.Tasks)
+}
+
+func TestApplyTasks_DryRun(t *testing.T) {
+	ft := &fakeTask{changes: []task.Change{{Setting: "sleep", Current: "1", Desired: "0"}}}
+
+	report := applyTasks(context.Background(), []task.Task{ft}, true)
+
+	assert.True(t, report.DryRun)
+	assert.True(t, report.Changed)
+	assert.False(t, report.Failed)
+	assert.False(t, ft.applied)
+}
+
+func TestReadApplyDocument_Stdin(t *testing.T) {
+	c, err := readApplyDocument(context.Background(), &fetch.Fetcher{}, "-", strings.NewReader(`{"users": [{"name": "runner"}], "power": {"sleep": "0"}}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []config.User{{Name: "runner"}}, c.Users)
+	assert.Equal(t, map[string]string{"sleep": "0"}, c.Power)
+}
+
+func TestReadApplyDocument_Missing(t *testing.T) {
+	_, err := readApplyDocument(context.Background(), &fetch.Fetcher{}, filepath.Join(t.TempDir(), "host.yaml"), nil)
+
+	assert.Error(t, err, "missing documents should be an error")
+}
+
+func TestUsersTask(t *testing.T) {
+	admin := true
+	tk := usersTask([]config.User{
+		{Name: "runner", Admin: &admin, AuthorizedKeys: []string{"ssh-ed25519 AAAA ci"}},
+		{Name: "build"},
+	}).(*task.Group)
+
+	assert.Len(t, tk.Tasks, 2)
+	runner := tk.Tasks[0].(*task.Group)
+	assert.Equal(t, &users.AccountTask{Options: users.CreateOptions{Name: "runner"}, Admin: &admin}, runner.Tasks[0])
+	assert.Equal(t, &users.AuthorizedKeysTask{Username: "runner", Keys: []string{"ssh-ed25519 AAAA ci"}}, runner.Tasks[1])
+	assert.Len(t, tk.Tasks[1].(*task.Group).Tasks, 1, "users without keys should only have an account task")
+}
+
+func TestVolumesTask(t *testing.T) {
+	persist := false
+	tk := volumesTask(nil, nil, []config.Volume{
+		{ID: "vol-0123456789abcdef0", MountPoint: "/Volumes/Data"},
+		{ID: "disk4", MountPoint: "/Volumes/Cache", Format: "JHFS+", Label: "Cache", Persist: &persist, DisableSpotlight: true},
+	}).(*task.Group)
+
+	assert.Equal(t, provisionVolume{id: "vol-0123456789abcdef0", mountPoint: "/Volumes/Data", format: "APFS", label: "Data", persist: true}, tk.Tasks[0].(*volumeTask).args)
+	assert.Equal(t, provisionVolume{id: "disk4", mountPoint: "/Volumes/Cache", format: "JHFS+", label: "Cache", disableSpotlight: true}, tk.Tasks[1].(*volumeTask).args)
+}
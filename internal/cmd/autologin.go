@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/users"
+)
+
+// autoLoginStatus is the state of automatic login reported by the user autologin status command.
+type autoLoginStatus struct {
+	Enabled bool   `json:"enabled"`
+	User    string `json:"user,omitempty"`
+}
+
+// userAutoLoginCommand creates a new command which groups the automatic login subcommands.
+func userAutoLoginCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "autologin",
+		Short: "manage automatic login",
+		Long: strings.TrimSpace(`
+autologin configures the login window to sign in as a user
+automatically after boot. CI tooling that depends on a GUI
+session (e.g. Xcode UI tests) needs an active login session to
+run. The user's password is stored, obfuscated, in /etc/kcpassword
+and is checked before it's stored. Automatic login isn't
+possible when FileVault is on.
+`),
+	}
+
+	cmd.AddCommand(userAutoLoginEnableCommand(), userAutoLoginDisableCommand(), userAutoLoginStatusCommand())
+
+	return cmd
+}
+
+// userAutoLoginEnableCommand creates a new command which enables automatic login for a user.
+func userAutoLoginEnableCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "enable <name>",
+		Short: "sign in as a user automatically after boot",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	src := passwordSource{}
+	var dryrun bool
+	var timeout time.Duration
+	addPasswordFlags(cmd, &src, "password")
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", userDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Writing /etc/kcpassword and the login window's preferences requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			if !src.isSet() {
+				return errors.New("one of --password-secret, --password-parameter, or --password-stdin is required")
+			}
+
+			u, err := lookupManagedUser(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			fileVault, err := users.FileVaultEnabled(ctx)
+			if err != nil {
+				return err
+			}
+			if fileVault {
+				return errors.New("automatic login isn't possible while FileVault is on")
+			}
+
+			password, err := src.read(ctx, cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+
+			if dryrun {
+				if err := users.Authenticate(ctx, u.Name, password); err != nil {
+					return err
+				}
+				logrus.WithField("user", u.Name).Warn("Would have enabled automatic login")
+				return nil
+			}
+
+			if err := users.EnableAutoLogin(ctx, u.Name, password); err != nil {
+				return err
+			}
+			logrus.WithField("user", u.Name).Info("Successfully enabled automatic login, it takes effect on the next boot")
+
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+// userAutoLoginDisableCommand creates a new command which disables automatic login.
+func userAutoLoginDisableCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "disable",
+		Short: "stop signing in automatically after boot",
+		Args:  cobra.NoArgs,
+	}
+
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", userDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Removing /etc/kcpassword and changing the login window's preferences requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			if dryrun {
+				logrus.Warn("Would have disabled automatic login")
+				return nil
+			}
+
+			if err := users.DisableAutoLogin(ctx); err != nil {
+				return err
+			}
+			logrus.Info("Successfully disabled automatic login")
+
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+// userAutoLoginStatusCommand creates a new command which reports the state of automatic login.
+func userAutoLoginStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "report whether automatic login is enabled",
+		Args:  cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", userDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			name, err := users.AutoLoginUser(ctx)
+			if err != nil {
+				return err
+			}
+			status := autoLoginStatus{Enabled: name != "", User: name}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), status, func(w io.Writer) error {
+				return printAutoLoginStatus(w, status)
+			})
+		})
+	}
+
+	return cmd
+}
+
+// printAutoLoginStatus writes the state of automatic login to w.
+func printAutoLoginStatus(w io.Writer, status autoLoginStatus) error {
+	if !status.Enabled {
+		_, err := fmt.Fprintln(w, "Automatic login: disabled")
+		return err
+	}
+	_, err := fmt.Fprintf(w, "Automatic login: enabled for %s\n", status.User)
+
+	return err
+}
@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintAutoLoginStatus(t *testing.T) {
+	var buf bytes.Buffer
+
+	assert.NoError(t, printAutoLoginStatus(&buf, autoLoginStatus{}))
+	assert.NoError(t, printAutoLoginStatus(&buf, autoLoginStatus{Enabled: true, User: "runner"}))
+	assert.Equal(t, "Automatic login: disabled\nAutomatic login: enabled for runner\n", buf.String())
+}
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/benchmark"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+)
+
+// benchmarkDefaultTimeout is the default maximum run duration for benchmarking a volume.
+const benchmarkDefaultTimeout = 30 * time.Minute
+
+// runBenchmark is a struct for holding all information passed into the benchmark command.
+type runBenchmark struct {
+	volume          string
+	size            string
+	blockSize       string
+	randomBlockSize string
+	duration        time.Duration
+	timeout         time.Duration
+}
+
+// benchmarkCommand creates a new command which benchmarks the I/O performance of a volume.
+func benchmarkCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "benchmark",
+		Short: "measure the I/O performance of a volume",
+		Long: strings.TrimSpace(`
+benchmark measures the throughput, IOPS, and latency of a
+volume so that the performance of EBS volume settings can be
+validated from the instance. The volume can be specified with
+its mount point or a path on it, or with its identifier (e.g.
+disk4s1) when it's mounted. A file of --size is written on
+the volume sequentially, then read sequentially, read at
+random offsets, and written at random offsets for --duration
+each. The page cache is bypassed so that the volume itself is
+measured. The file is removed once the benchmark finishes.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	benchmarkArgs := runBenchmark{}
+	cmd.PersistentFlags().StringVar(&benchmarkArgs.volume, "volume", "", "mount point, path, or identifier of the volume to benchmark")
+	cmd.PersistentFlags().StringVar(&benchmarkArgs.size, "size", humanize.IBytes(benchmark.DefaultFileSize), "size of the file to read and write (e.g. 512MiB, 4GiB)")
+	cmd.PersistentFlags().StringVar(&benchmarkArgs.blockSize, "block-size", humanize.IBytes(benchmark.DefaultSequentialBlockSize), "size of each sequential read and write")
+	cmd.PersistentFlags().StringVar(&benchmarkArgs.randomBlockSize, "random-block-size", humanize.IBytes(benchmark.DefaultRandomBlockSize), "size of each random read and write")
+	cmd.PersistentFlags().DurationVar(&benchmarkArgs.duration, "duration", benchmark.DefaultDuration, "run duration of each read and random write test (e.g. 10s, 1m)")
+	cmd.PersistentFlags().DurationVar(&benchmarkArgs.timeout, "timeout", benchmarkDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+	cmd.MarkPersistentFlagRequired("volume")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, benchmarkArgs.timeout, func(ctx context.Context) error {
+			var d diskutil.DiskUtil
+			if !filepath.IsAbs(benchmarkArgs.volume) {
+				product := contextual.Product(ctx)
+				if product == nil {
+					return errors.New("product required in context")
+				}
+
+				var err error
+				if d, err = diskutil.ForProduct(product); err != nil {
+					return err
+				}
+			}
+
+			report, err := runBenchmarkVolume(ctx, d, benchmarkArgs)
+			if err != nil {
+				return err
+			}
+
+			return printBenchmarkReport(cmd.OutOrStdout(), outputFormat(cmd), report)
+		})
+	}
+
+	return cmd
+}
+
+// runBenchmarkVolume resolves the volume to benchmark and runs the benchmark on it. Volume identifiers are resolved
+// to their mount point with utility, which is only required when the volume isn't given as an absolute path.
+func runBenchmarkVolume(ctx context.Context, utility diskutil.DiskUtil, args runBenchmark) (*benchmark.Report, error) {
+	cfg, err := args.config()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Dir, err = resolveBenchmarkDir(ctx, utility, args.volume)
+	if err != nil {
+		return nil, err
+	}
+
+	fs, err := mounts.Usage(cfg.Dir)
+	if err == nil && fs.AvailableBytes < uint64(cfg.FileSize) {
+		return nil, fmt.Errorf("volume at %s has %s available, %s is required", fs.MountPoint, humanize.IBytes(fs.AvailableBytes), humanize.IBytes(uint64(cfg.FileSize)))
+	} else if err != nil && !errors.Is(err, mounts.ErrUnsupported) {
+		return nil, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"dir":      cfg.Dir,
+		"size":     humanize.IBytes(uint64(cfg.FileSize)),
+		"duration": cfg.Duration,
+	}).Info("Benchmarking volume...")
+
+	return benchmark.Run(ctx, cfg)
+}
+
+// config parses the sizes of the benchmark's arguments into a benchmark.Config, without its directory.
+func (args runBenchmark) config() (benchmark.Config, error) {
+	cfg := benchmark.Config{Duration: args.duration}
+
+	sizes := []struct {
+		flag  string
+		value string
+		set   func(n uint64)
+	}{
+		{"size", args.size, func(n uint64) { cfg.FileSize = int64(n) }},
+		{"block-size", args.blockSize, func(n uint64) { cfg.SequentialBlockSize = int(n) }},
+		{"random-block-size", args.randomBlockSize, func(n uint64) { cfg.RandomBlockSize = int(n) }},
+	}
+	for _, s := range sizes {
+		n, err := humanize.ParseBytes(s.value)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid --%s: %w", s.flag, err)
+		}
+		s.set(n)
+	}
+
+	return cfg, nil
+}
+
+// resolveBenchmarkDir gets the directory to benchmark for the volume, which is either an absolute path to a
+// directory or a reference to a mounted volume that diskutil.Resolve accepts (e.g. its identifier or label).
+func resolveBenchmarkDir(ctx context.Context, utility diskutil.DiskUtil, volume string) (string, error) {
+	if filepath.IsAbs(volume) {
+		info, err := os.Stat(volume)
+		if err != nil {
+			return "", fmt.Errorf("cannot benchmark %s: %w", volume, err)
+		}
+		if !info.IsDir() {
+			return "", fmt.Errorf("cannot benchmark %s: not a directory", volume)
+		}
+
+		return volume, nil
+	}
+
+	id, err := diskutil.Resolve(ctx, utility, volume)
+	if err != nil {
+		return "", fmt.Errorf("invalid volume: %w", err)
+	}
+	disk, err := utility.Info(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("unable to get volume information: %w", err)
+	}
+	if disk.MountPoint == "" {
+		return "", fmt.Errorf("volume [%s] is not mounted", disk.DeviceIdentifier)
+	}
+
+	return disk.MountPoint, nil
+}
+
+// printBenchmarkReport writes the results of each of the report's tests to w.
+func printBenchmarkReport(w io.Writer, format string, report *benchmark.Report) error {
+	return printOutput(w, format, report, func(w io.Writer) error {
+		fmt.Fprintf(w, "%s (%s file", report.Dir, humanize.IBytes(uint64(report.FileSize)))
+		if !report.Direct {
+			fmt.Fprint(w, ", through the page cache")
+		}
+		fmt.Fprintln(w, ")")
+
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "TEST\tBLOCK\tMiB/s\tIOPS\tMEAN\tP99\tMAX")
+		for _, r := range report.Results {
+			fmt.Fprintf(tw, "%s\t%s\t%.1f\t%.0f\t%.0fus\t%.0fus\t%.0fus\n",
+				r.Test, humanize.IBytes(uint64(r.BlockSize)), r.Throughput, r.IOPS, r.Latency.Mean, r.Latency.P99, r.Latency.Max)
+		}
+
+		return tw.Flush()
+	})
+}
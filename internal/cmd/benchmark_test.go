@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/benchmark"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/diskutilfakes"
+)
+
+func TestRunBenchmarkVolume(t *testing.T) {
+	dir := t.TempDir()
+
+	report, err := runBenchmarkVolume(context.Background(), nil, runBenchmark{
+		volume:          dir,
+		size:            "64KiB",
+		blockSize:       "4KiB",
+		randomBlockSize: "1KiB",
+		duration:        10 * time.Millisecond,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, dir, report.Dir)
+	assert.Equal(t, int64(64<<10), report.FileSize)
+	assert.Len(t, report.Results, 4)
+}
+
+func TestRunBenchmarkVolume_WithInvalidSize(t *testing.T) {
+	_, err := runBenchmarkVolume(context.Background(), nil, runBenchmark{volume: t.TempDir(), size: "lots", blockSize: "4KiB", randomBlockSize: "4KiB", duration: time.Second})
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid --size")
+	}
+}
+
+func TestResolveBenchmarkDir(t *testing.T) {
+	ctx := context.Background()
+	fake := diskutilfakes.New(fakeBootDisk())
+
+	dir, err := resolveBenchmarkDir(ctx, fake, "disk3s1")
+	assert.NoError(t, err)
+	assert.Equal(t, "/", dir, "volume identifiers should resolve to their mount point")
+
+	dir, err = resolveBenchmarkDir(ctx, fake, "Macintosh HD")
+	assert.NoError(t, err)
+	assert.Equal(t, "/", dir, "volume labels should resolve to their mount point")
+
+	_, err = resolveBenchmarkDir(ctx, fake, "disk0s1")
+	assert.Error(t, err, "unmounted volumes can't be benchmarked")
+
+	file := t.TempDir() + "/file"
+	assert.NoError(t, os.WriteFile(file, nil, 0o600))
+	_, err = resolveBenchmarkDir(ctx, fake, file)
+	assert.Error(t, err, "files can't be benchmarked")
+}
+
+func TestPrintBenchmarkReport(t *testing.T) {
+	report := &benchmark.Report{
+		Dir:      "/Volumes/Data",
+		FileSize: 1 << 30,
+		Direct:   true,
+		Results: []benchmark.Result{
+			{Test: benchmark.TestRandomRead, BlockSize: 4096, Operations: 30000, Throughput: 11.7, IOPS: 3000, Latency: benchmark.Latency{Mean: 330, P99: 900, Max: 2100}},
+		},
+	}
+
+	var text bytes.Buffer
+	assert.NoError(t, printBenchmarkReport(&text, outputText, report))
+	assert.Equal(t, "/Volumes/Data (1.0 GiB file)\n"+
+		"TEST         BLOCK    MiB/s  IOPS  MEAN   P99    MAX\n"+
+		"random-read  4.0 KiB  11.7   3000  330us  900us  2100us\n", text.String())
+
+	var out bytes.Buffer
+	assert.NoError(t, printBenchmarkReport(&out, outputJSON, report))
+	assert.Contains(t, out.String(), `"throughput_mibps": 11.7`)
+	assert.Contains(t, out.String(), `"p99_us": 900`)
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/homebrew"
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/internal/users"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// brewDefaultTimeout is the default maximum run duration for checking and preparing the Homebrew environment.
+const brewDefaultTimeout = time.Minute
+
+// brewSettings is a struct for holding all information passed into the brew subcommands.
+type brewSettings struct {
+	user    string
+	prefix  string
+	timeout time.Duration
+}
+
+// brewCommand creates a new command which groups the Homebrew subcommands.
+func brewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "brew",
+		Short: "prepare the host for Homebrew",
+		Long: strings.TrimSpace(`
+brew prepares the host for installing with Homebrew as a user
+without it having to run with sudo. The directories Homebrew
+writes to in its prefix (/opt/homebrew on Apple silicon and
+/usr/local on Intel) and the user's download cache are created
+and given to the user and the admin group like Homebrew's
+installer does. On Apple silicon, Homebrew's environment is
+added to the user's ~/.zprofile so its executables are in the
+PATH of login shells, /usr/local/bin is already in the system's
+PATH on Intel.
+`),
+	}
+
+	cmd.AddCommand(brewCheckCommand(), brewPrepareCommand())
+
+	return cmd
+}
+
+// addBrewFlags adds the flags used to select the user and prefix to the command.
+func addBrewFlags(cmd *cobra.Command, args *brewSettings) {
+	cmd.PersistentFlags().StringVar(&args.user, "user", defaultSSHUser, "user that runs Homebrew")
+	cmd.PersistentFlags().StringVar(&args.prefix, "prefix", "", "Homebrew's prefix, detected from the processor's architecture when unset")
+	cmd.PersistentFlags().DurationVar(&args.timeout, "timeout", brewDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+}
+
+// brewCheckCommand creates a new command which reports what brew prepare would change.
+func brewCheckCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "report whether the host is prepared for Homebrew",
+		Args:  cobra.NoArgs,
+	}
+
+	brewArgs := brewSettings{}
+	addBrewFlags(cmd, &brewArgs)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runBrew(cmd, brewArgs, func(ctx context.Context, t task.Task) error {
+			return checkTask(ctx, cmd, t)
+		})
+	}
+
+	return cmd
+}
+
+// brewPrepareCommand creates a new command which prepares the Homebrew environment for the user.
+func brewPrepareCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prepare",
+		Short: "create Homebrew's directories and add it to the user's PATH",
+		Args:  cobra.NoArgs,
+	}
+
+	brewArgs := brewSettings{}
+	addBrewFlags(cmd, &brewArgs)
+	var dryrun bool
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+
+	// Creating directories in /opt and /usr/local, and giving them to the user, requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runBrew(cmd, brewArgs, func(ctx context.Context, t task.Task) error {
+			return applyTask(ctx, cmd, t, dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// runBrew runs fn with the task that prepares the Homebrew environment for the user.
+func runBrew(cmd *cobra.Command, args brewSettings, fn func(ctx context.Context, t task.Task) error) error {
+	return runUserCommand(cmd, args.timeout, func(ctx context.Context) error {
+		u, err := users.Lookup(ctx, args.user)
+		if err != nil {
+			return err
+		}
+
+		prefix := args.prefix
+		if prefix == "" {
+			appleSilicon, err := system.AppleSilicon(ctx)
+			if err != nil {
+				return err
+			}
+			prefix = homebrew.Prefix(appleSilicon)
+		}
+
+		return fn(ctx, task.NewGroup("brew", homebrew.NewDirectoriesTask(prefix, u), homebrew.NewProfileTask(prefix, u)))
+	})
+}
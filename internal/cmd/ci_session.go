@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/defaults"
+	"github.com/aws/ec2-macos-utils/internal/power"
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+// userCISessionCommand creates a new command which groups the unattended GUI session subcommands.
+func userCISessionCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ci-session",
+		Short: "keep a user's GUI session unlocked while idle",
+		Long: strings.TrimSpace(`
+ci-session keeps a user's GUI session usable by UI test
+automation after it has been idle. The user's screen saver is
+disabled, waking the display no longer asks for the user's
+password, and the display never sleeps. Locked sessions can't
+be unlocked by automation so tests fail after idle periods
+without these settings.
+`),
+	}
+
+	cmd.AddCommand(userCISessionCheckCommand(), userCISessionApplyCommand())
+
+	return cmd
+}
+
+// userCISessionCheckCommand creates a new command which reports drift from the unattended session settings.
+func userCISessionCheckCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check <name>",
+		Short: "report drift from the unattended session settings",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", userDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Reading the preferences of other users requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			u, err := lookupManagedUser(ctx, args[0])
+			if err != nil {
+				return err
+			}
+
+			return checkTask(ctx, cmd, ciSessionTask(u.Name))
+		})
+	}
+
+	return cmd
+}
+
+// userCISessionApplyCommand creates a new command which applies the unattended session settings.
+func userCISessionApplyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply <name>",
+		Short: "apply the unattended session settings",
+		Long: strings.TrimSpace(`
+apply disables the user's screen saver and session locking and
+disables display sleep. The settings take effect the next time
+the user signs in.
+`),
+		Args: cobra.ExactArgs(1),
+	}
+
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", userDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Writing the preferences of other users and the power settings requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			u, err := lookupManagedUser(ctx, args[0])
+			if err != nil {
+				return err
+			}
+
+			return applyTask(ctx, cmd, ciSessionTask(u.Name), dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// ciSessionTask builds the task which keeps the user's GUI session unlocked while it's idle. Display sleep is a
+// system-wide power setting rather than one of the user's preferences.
+func ciSessionTask(user string) task.Task {
+	return task.NewGroup("ci-session",
+		&defaults.Task{Settings: defaults.UnattendedSessionSettings(user)},
+		&power.Task{Desired: power.Settings{"displaysleep": "0"}},
+	)
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/fetch"
+	"github.com/aws/ec2-macos-utils/internal/imds"
+)
+
+// loadConfig loads the configuration file selected with the root command's --config flag. Commands run without the
+// root command (e.g. in tests) load the file at config.DefaultPath. Files at S3 URIs are downloaded first.
+func loadConfig(cmd *cobra.Command) (*config.Config, error) {
+	path := config.DefaultPath
+	if f := cmd.Flags().Lookup("config"); f != nil {
+		path = f.Value.String()
+	}
+
+	if aws.IsS3URI(path) {
+		return loadRemoteConfig(cmd.Context(), &fetch.Fetcher{}, path)
+	}
+
+	c, err := config.Load(path, templateVars(cmd.Context(), imds.NewCachedClient()))
+	if err != nil {
+		return nil, fmt.Errorf("cannot load config %s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// loadRemoteConfig downloads the configuration file at the S3 URI and loads it. Unlike local files, missing files
+// are an error since the URI was given explicitly.
+func loadRemoteConfig(ctx context.Context, fetcher *fetch.Fetcher, uri string) (*config.Config, error) {
+	data, err := fetchS3Object(ctx, fetcher, uri, "")
+	if err != nil {
+		return nil, fmt.Errorf("cannot download config %s: %w", uri, err)
+	}
+
+	c, err := config.DecodeTemplate(bytes.NewReader(data), templateVars(ctx, imds.NewCachedClient()))
+	if err != nil {
+		return nil, fmt.Errorf("cannot load config %s: %w", uri, err)
+	}
+
+	return c, nil
+}
+
+// instanceMetadata reads the instance metadata that configuration templates are rendered with.
+type instanceMetadata interface {
+	IdentityDocument(ctx context.Context) (*imds.IdentityDocument, error)
+	Tags(ctx context.Context) (map[string]string, error)
+}
+
+// templateVars gets the variables that configuration templates are rendered with from the instance metadata and the
+// system's host name. Instances without tags in metadata have no tags rather than failing.
+func templateVars(ctx context.Context, metadata instanceMetadata) config.VarsFunc {
+	return func() (*config.Vars, error) {
+		doc, err := metadata.IdentityDocument(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get instance identity document: %w", err)
+		}
+		tags, err := metadata.Tags(ctx)
+		if errors.Is(err, imds.ErrNotFound) {
+			tags = map[string]string{}
+		} else if err != nil {
+			return nil, fmt.Errorf("cannot get instance tags: %w", err)
+		}
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get hostname: %w", err)
+		}
+
+		return &config.Vars{
+			InstanceID:       doc.InstanceID,
+			InstanceType:     doc.InstanceType,
+			ImageID:          doc.ImageID,
+			AccountID:        doc.AccountID,
+			Region:           doc.Region,
+			AvailabilityZone: doc.AvailabilityZone,
+			Architecture:     doc.Architecture,
+			PrivateIP:        doc.PrivateIP,
+			Hostname:         hostname,
+			Tags:             tags,
+		}, nil
+	}
+}
+
+// fetchS3Object downloads the object at the S3 URI through a temporary file, validating its checksum when one is
+// given.
+func fetchS3Object(ctx context.Context, fetcher *fetch.Fetcher, uri, checksum string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "ec2-macos-utils-fetch-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "object")
+	if err := fetcher.Fetch(ctx, uri, path, checksum); err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(path)
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/fetch"
+)
+
+// configLintDefaultTimeout is the default maximum run duration for linting a configuration file.
+const configLintDefaultTimeout = time.Minute
+
+// lintReport is the outcome of linting a configuration file.
+type lintReport struct {
+	// File is the path or S3 URI of the configuration file.
+	File string `json:"file"`
+	// Valid indicates that no problems were found.
+	Valid bool `json:"valid"`
+	// Problems are the problems that were found.
+	Problems []config.Problem `json:"problems"`
+}
+
+// configCommand creates a new command which groups the configuration file subcommands.
+func configCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "manage the configuration file",
+		Long: strings.TrimSpace(`
+config groups subcommands for working with the configuration file
+that host configuration commands read their settings from.
+`),
+	}
+
+	cmd.AddCommand(configLintCommand())
+
+	return cmd
+}
+
+// configLintCommand creates a new command which validates a configuration file.
+func configLintCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lint [path]",
+		Short: "validate a configuration file",
+		Long: strings.TrimSpace(`
+lint validates a configuration file, or an apply document, before
+it's used (e.g. before it's baked into an AMI or read at first
+boot) and prints each problem with the line it's on. The file is
+read from the path or S3 URI given, or from the one selected with
+--config. Syntax errors, invalid templates, unknown keys, and
+values of the wrong type are reported first since the values can't
+be checked until they're fixed. Templates are then rendered with
+sample instance metadata and values are checked against what the
+commands accept (e.g. user names, OpenSSH public keys, preference types,
+proxies, sshd options, volume formats and labels, and mount
+points). The command fails when any problems are found.
+`),
+		Args: cobra.MaximumNArgs(1),
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", configLintDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			path := config.DefaultPath
+			if f := cmd.Flags().Lookup("config"); f != nil {
+				path = f.Value.String()
+			}
+			if len(args) == 1 {
+				path = args[0]
+			}
+
+			report, err := lintConfig(ctx, &fetch.Fetcher{}, path)
+			if err != nil {
+				return err
+			}
+			if err := printOutput(cmd.OutOrStdout(), outputFormat(cmd), report, func(w io.Writer) error {
+				return printLintReport(w, report)
+			}); err != nil {
+				return err
+			}
+			if !report.Valid {
+				return fmt.Errorf("%s: %d problem(s) found", path, len(report.Problems))
+			}
+
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+// lintConfig reads the configuration file at the path or S3 URI and lints it. Unlike loading the configuration,
+// missing files are an error since there's nothing to validate.
+func lintConfig(ctx context.Context, fetcher *fetch.Fetcher, path string) (*lintReport, error) {
+	var data []byte
+	var err error
+	if aws.IsS3URI(path) {
+		data, err = fetchS3Object(ctx, fetcher, path, "")
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config %s: %w", path, err)
+	}
+
+	problems := config.Lint(data)
+	if problems == nil {
+		problems = []config.Problem{}
+	}
+
+	return &lintReport{File: path, Valid: len(problems) == 0, Problems: problems}, nil
+}
+
+// printLintReport writes each problem prefixed with the file and line, as compilers do, to w.
+func printLintReport(w io.Writer, report *lintReport) error {
+	if report.Valid {
+		_, err := fmt.Fprintf(w, "%s: no problems found\n", report.File)
+		return err
+	}
+
+	for _, p := range report.Problems {
+		location := report.File
+		if p.Line != 0 {
+			location = fmt.Sprintf("%s:%d", location, p.Line)
+		}
+		msg := p.Message
+		if p.Path != "" {
+			msg = p.Path + ": " + msg
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s\n", location, msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/fetch"
+)
+
+func TestLintConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("ssh:\n  server:\n    client_alive_interval: -1\n"), 0600))
+
+	report, err := lintConfig(context.Background(), &fetch.Fetcher{}, path)
+
+	assert.NoError(t, err)
+	assert.False(t, report.Valid)
+	assert.Equal(t, []config.Problem{{Line: 3, Path: "ssh.server.client_alive_interval", Message: "interval can't be negative"}}, report.Problems)
+}
+
+func TestLintConfig_Missing(t *testing.T) {
+	_, err := lintConfig(context.Background(), &fetch.Fetcher{}, filepath.Join(t.TempDir(), "config.yaml"))
+
+	assert.Error(t, err, "missing files should be an error")
+}
+
+func TestPrintLintReport(t *testing.T) {
+	var buf bytes.Buffer
+	report := &lintReport{File: "config.yaml", Problems: []config.Problem{
+		{Line: 2, Message: "field remote_logn not found in type config.Setup"},
+		{Line: 7, Path: "users[0].name", Message: `invalid user name "Runner"`},
+	}}
+
+	assert.NoError(t, printLintReport(&buf, report))
+	assert.Equal(t, "config.yaml:2: field remote_logn not found in type config.Setup\n"+
+		"config.yaml:7: users[0].name: invalid user name \"Runner\"\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, printLintReport(&buf, &lintReport{File: "config.yaml", Valid: true}))
+	assert.Equal(t, "config.yaml: no problems found\n", buf.String())
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/imds"
+)
+
+// fakeInstanceMetadata serves an identity document and tags, failing with tagsErr when it's set.
+type fakeInstanceMetadata struct {
+	doc     imds.IdentityDocument
+	tags    map[string]string
+	tagsErr error
+}
+
+func (f *fakeInstanceMetadata) IdentityDocument(ctx context.Context) (*imds.IdentityDocument, error) {
+	return &f.doc, nil
+}
+
+func (f *fakeInstanceMetadata) Tags(ctx context.Context) (map[string]string, error) {
+	return f.tags, f.tagsErr
+}
+
+func TestTemplateVars(t *testing.T) {
+	metadata := &fakeInstanceMetadata{
+		doc:  imds.IdentityDocument{InstanceID: "i-0123456789abcdef0", InstanceType: "mac2.metal", Region: "us-west-2"},
+		tags: map[string]string{"team": "ci"},
+	}
+
+	vars, err := templateVars(context.Background(), metadata)()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "i-0123456789abcdef0", vars.InstanceID)
+	assert.Equal(t, "mac2.metal", vars.InstanceType)
+	assert.Equal(t, "us-west-2", vars.Region)
+	assert.Equal(t, map[string]string{"team": "ci"}, vars.Tags)
+	assert.NotEmpty(t, vars.Hostname)
+}
+
+func TestTemplateVars_TagsDisabled(t *testing.T) {
+	metadata := &fakeInstanceMetadata{tagsErr: imds.ErrNotFound}
+
+	vars, err := templateVars(context.Background(), metadata)()
+
+	assert.NoError(t, err, "instances without tags in metadata should have no tags")
+	assert.Empty(t, vars.Tags)
+}
@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/control"
+	"github.com/aws/ec2-macos-utils/internal/health"
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/internal/oplock"
+	"github.com/aws/ec2-macos-utils/internal/trim"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+)
+
+// controlDefaultTimeout is the default maximum run duration of each operation run through the control socket.
+const controlDefaultTimeout = 10 * time.Minute
+
+// controlCommand creates a new command which groups the control socket subcommands.
+func controlCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "control",
+		Short: "serve disk operations to other agents",
+	}
+
+	cmd.AddCommand(controlServeCommand())
+
+	return cmd
+}
+
+// controlServeCommand creates a new command which serves the disk operations on the control socket.
+func controlServeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "serve disk operations on a Unix domain socket",
+		Long: strings.TrimSpace(`
+serve runs until it's stopped, serving the disk operations
+over JSON-RPC 1.0 on a Unix domain socket that's only
+accessible by root. Other agents on the host (e.g. CI runners
+and MDM agents) can list disks, fetch disk information, grow
+containers, and provision volumes with the same behavior as
+the grow and volume provision commands, without running the
+CLI. Operations that modify disks are run one at a time.
+Disk information can be cached between operations with
+--cache-ttl, the cache is cleared whenever a disk is modified.
+Disk.Info also reports whether the disk's device supports
+TRIM and has it enabled.
+
+The methods are Disk.List, Disk.Info, Disk.Grow,
+Disk.Provision, and Disk.Status.
+`),
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{publishesEventsAnnotation: "true"},
+	}
+
+	var socket string
+	var timeout, cacheTTL time.Duration
+	cmd.PersistentFlags().StringVar(&socket, "socket", control.DefaultSocketPath, "path of the control socket")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", controlDefaultTimeout, "Set the timeout for each operation (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+	cmd.PersistentFlags().DurationVar(&cacheTTL, "cache-ttl", 0, "Set how long disk information is cached for between operations (e.g. 5s), 0s will disable the cache")
+
+	// Modifying disks and binding sockets in /var/run requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		product := contextual.Product(cmd.Context())
+		if product == nil {
+			return errors.New("product required in context")
+		}
+
+		d, err := diskutil.ForProduct(product)
+		if err != nil {
+			return err
+		}
+		if cacheTTL > 0 {
+			d = diskutil.Cached(d, cacheTTL)
+		}
+
+		svc := newControlService(cmd.Context(), d, mounts.NewManager(product), timeout)
+		logrus.WithField("socket", socket).Info("Serving control socket")
+
+		return control.Serve(cmd.Context(), socket, svc)
+	}
+
+	return cmd
+}
+
+// controlService implements the methods served on the control socket.
+type controlService struct {
+	// ctx is the context that operations are run in, which carries the events Bus.
+	ctx     context.Context
+	d       diskutil.DiskUtil
+	m       *mounts.Manager
+	timeout time.Duration
+	monitor *health.Monitor
+	// trimDevices fetches the TRIM status of the host's devices for the disk information.
+	trimDevices func(ctx context.Context) ([]trim.Device, error)
+
+	// mu serializes the operations which modify disks.
+	mu sync.Mutex
+}
+
+// newControlService creates a controlService which runs its operations with d in ctx.
+func newControlService(ctx context.Context, d diskutil.DiskUtil, m *mounts.Manager, timeout time.Duration) *controlService {
+	return &controlService{ctx: ctx, d: d, m: m, timeout: timeout, monitor: health.NewMonitor("control"), trimDevices: trim.Devices}
+}
+
+// context creates the context for an operation, limited by the service's timeout.
+func (s *controlService) context() (context.Context, context.CancelFunc) {
+	if s.timeout == 0 {
+		return context.WithCancel(s.ctx)
+	}
+
+	return context.WithTimeout(s.ctx, s.timeout)
+}
+
+// utility gets the DiskUtil for an operation, which doesn't make mutating changes in dry-run mode.
+func (s *controlService) utility(dryrun bool) diskutil.DiskUtil {
+	if dryrun {
+		return diskutil.Dryrun(s.d)
+	}
+
+	return s.d
+}
+
+// lock takes the disk operation lock for operations that aren't dry runs so that they can't interleave with the
+// CLI's, failing immediately when another process holds it. The returned function releases it.
+func (s *controlService) lock(ctx context.Context, dryrun bool) (unlock func(), err error) {
+	if dryrun {
+		return func() {}, nil
+	}
+
+	lock, err := oplock.Acquire(ctx, diskLockPath, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() { lock.Release() }, nil
+}
+
+// List lists the disks and partitions, classifying the kind of each disk.
+func (s *controlService) List(args *control.ListArgs, reply *types.SystemPartitions) error {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	partitions, err := s.d.List(ctx, args.Args)
+	if err != nil {
+		return err
+	}
+	if err := diskutil.ClassifyDisks(ctx, s.d, partitions); err != nil {
+		logrus.WithError(err).Warn("Unable to classify all disks")
+	}
+	*reply = *partitions
+
+	return nil
+}
+
+// Info fetches the information for a disk, including the TRIM status of its physical device.
+func (s *controlService) Info(args *control.InfoArgs, reply *types.DiskInfo) error {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	disk, err := s.d.Info(ctx, args.ID)
+	if err != nil {
+		return err
+	}
+	if devices, err := s.trimDevices(ctx); err != nil {
+		logrus.WithError(err).Warn("Unable to fetch TRIM status")
+	} else if err := trim.Resolve(ctx, s.d, disk, devices); err != nil {
+		logrus.WithError(err).Warn("Unable to resolve TRIM status")
+	}
+	*reply = *disk
+
+	return nil
+}
+
+// Grow grows an APFS container to its maximum size, like the grow command.
+func (s *controlService) Grow(args *control.GrowArgs, reply *control.GrowReply) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, cancel := s.context()
+	defer cancel()
+
+	unlock, err := s.lock(ctx, args.DryRun)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = s.grow(ctx, s.utility(args.DryRun), args, reply)
+	s.monitor.Record(err)
+
+	return err
+}
+
+// grow grows the container as described by Grow.
+func (s *controlService) grow(ctx context.Context, d diskutil.DiskUtil, args *control.GrowArgs, reply *control.GrowReply) error {
+	container, err := getTargetDiskInfo(ctx, d, args.ID)
+	if err != nil {
+		return err
+	}
+
+	err = diskutil.GrowContainer(ctx, d, container)
+	if errors.As(err, &diskutil.FreeSpaceError{}) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	reply.Grown = !args.DryRun
+	if args.DryRun {
+		return nil
+	}
+
+	reply.Disk, err = d.Info(ctx, container.ParentWholeDisk)
+
+	return err
+}
+
+// Provision prepares and mounts a data volume, like the volume provision command.
+func (s *controlService) Provision(args *control.ProvisionArgs, reply *types.DiskInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, cancel := s.context()
+	defer cancel()
+
+	unlock, err := s.lock(ctx, args.DryRun)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = runProvision(ctx, s.utility(args.DryRun), s.m, provisionArgs(args))
+	if err == nil && !args.DryRun {
+		var volume *types.DiskInfo
+		if volume, err = s.d.Info(ctx, args.MountPoint); err == nil {
+			*reply = *volume
+		}
+	}
+	s.monitor.Record(err)
+
+	return err
+}
+
+// PlanGrow computes the changes that Grow would make to the disks without making them.
+func (s *controlService) PlanGrow(args *control.GrowArgs, reply *diskutil.Plan) error {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	plan, err := planGrow(ctx, s.d, growContainer{id: args.ID})
+	if err != nil {
+		return err
+	}
+	*reply = *plan
+
+	return nil
+}
+
+// PlanProvision computes the changes that Provision would make to the disk without making them.
+func (s *controlService) PlanProvision(args *control.ProvisionArgs, reply *diskutil.Plan) error {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	plan, err := planProvision(ctx, s.d, provisionArgs(args))
+	if err != nil {
+		return err
+	}
+	*reply = *plan
+
+	return nil
+}
+
+// provisionArgs converts the arguments of Disk.Provision to those of the volume provision command, filling in the
+// default format and label.
+func provisionArgs(args *control.ProvisionArgs) provisionVolume {
+	format, label := args.Format, args.Label
+	if format == "" {
+		format = string(diskutil.FormatAPFS)
+	}
+	if label == "" {
+		label = "Data"
+	}
+
+	return provisionVolume{
+		dryrun:     args.DryRun,
+		format:     format,
+		id:         args.ID,
+		label:      label,
+		mountPoint: args.MountPoint,
+		persist:    args.Persist,
+	}
+}
+
+// Status reports the outcome of the operations run through the socket.
+func (s *controlService) Status(args *control.StatusArgs, reply *health.Status) error {
+	*reply = s.monitor.Status()
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/control"
+	"github.com/aws/ec2-macos-utils/internal/health"
+	"github.com/aws/ec2-macos-utils/internal/trim"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/diskutilfakes"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+)
+
+// fakeBootDisk is an EBS boot volume whose APFS container, disk3, holds the boot volume and has 50GB to grow into.
+func fakeBootDisk() diskutilfakes.Disk {
+	return diskutilfakes.Disk{
+		ID:        "disk0",
+		Size:      150_000_000_000,
+		Internal:  true,
+		MediaName: "Amazon Elastic Block Store",
+		Partitions: []diskutilfakes.Partition{
+			{Content: "EFI", Size: 209_715_200, VolumeName: "EFI"},
+			{Content: "Apple_APFS", Size: 100_000_000_000, Container: &diskutilfakes.Container{
+				ID:      "disk3",
+				Volumes: []diskutilfakes.Volume{{Name: "Macintosh HD", Size: 20_000_000_000, MountPoint: "/"}},
+			}},
+		},
+	}
+}
+
+func TestControlService_ListInfo(t *testing.T) {
+	svc := newControlService(context.Background(), diskutilfakes.New(fakeBootDisk()), nil, 0)
+
+	var partitions types.SystemPartitions
+	assert.NoError(t, svc.List(&control.ListArgs{}, &partitions))
+	assert.Contains(t, partitions.WholeDisks, "disk0")
+	assert.Equal(t, types.KindEBS, partitions.AllDisksAndPartitions[0].Kind)
+
+	svc.trimDevices = func(ctx context.Context) ([]trim.Device, error) {
+		return []trim.Device{{ID: "disk0", Status: types.TRIMStatus{Supported: true, Enabled: true}}}, nil
+	}
+	var disk types.DiskInfo
+	assert.NoError(t, svc.Info(&control.InfoArgs{ID: "/"}, &disk))
+	assert.Equal(t, "disk3s1", disk.DeviceIdentifier)
+	assert.Equal(t, &types.TRIMStatus{Supported: true, Enabled: true}, disk.TRIM)
+}
+
+func TestControlService_Grow(t *testing.T) {
+	fake := diskutilfakes.New(fakeBootDisk())
+	svc := newControlService(context.Background(), fake, nil, controlDefaultTimeout)
+
+	var reply control.GrowReply
+	assert.NoError(t, svc.Grow(&control.GrowArgs{ID: "root", DryRun: true}, &reply))
+	assert.False(t, reply.Grown, "nothing should be grown in dry-run mode")
+
+	reply = control.GrowReply{}
+	assert.NoError(t, svc.Grow(&control.GrowArgs{ID: "root"}, &reply))
+	assert.True(t, reply.Grown)
+	if assert.NotNil(t, reply.Disk) {
+		assert.Equal(t, "disk3", reply.Disk.DeviceIdentifier)
+		assert.Equal(t, uint64(150_000_000_000-209_715_200), reply.Disk.APFSContainerSize)
+	}
+
+	reply = control.GrowReply{}
+	assert.NoError(t, svc.Grow(&control.GrowArgs{ID: "root"}, &reply))
+	assert.False(t, reply.Grown, "there should be nothing left to grow into")
+
+	var status health.Status
+	assert.NoError(t, svc.Status(&control.StatusArgs{}, &status))
+	assert.Equal(t, 3, status.Runs)
+	assert.True(t, status.Healthy)
+}
+
+func TestControlService_GrowFailure(t *testing.T) {
+	svc := newControlService(context.Background(), diskutilfakes.New(fakeBootDisk()), nil, 0)
+
+	assert.Error(t, svc.Grow(&control.GrowArgs{ID: "disk9"}, &control.GrowReply{}))
+
+	var status health.Status
+	assert.NoError(t, svc.Status(&control.StatusArgs{}, &status))
+	assert.False(t, status.Healthy)
+	assert.Equal(t, 1, status.Failures)
+}
+
+func TestControlService_ProvisionDryRun(t *testing.T) {
+	fake := diskutilfakes.New(fakeBootDisk(), diskutilfakes.Disk{ID: "disk4", Size: 500_000_000_000})
+	svc := newControlService(context.Background(), fake, nil, 0)
+
+	var volume types.DiskInfo
+	assert.NoError(t, svc.Provision(&control.ProvisionArgs{ID: "disk4", MountPoint: "/Volumes/builds", DryRun: true}, &volume))
+	assert.Empty(t, volume.DeviceIdentifier, "no volume should be created in dry-run mode")
+
+	for _, c := range fake.Calls() {
+		assert.NotEqual(t, "EraseDisk", c.Method, "disks should never be erased in dry-run mode")
+	}
+}
+
+func TestControlService_Plan(t *testing.T) {
+	fake := diskutilfakes.New(fakeBootDisk(), diskutilfakes.Disk{ID: "disk4", Size: 500_000_000_000})
+	svc := newControlService(context.Background(), fake, nil, 0)
+
+	var plan diskutil.Plan
+	assert.NoError(t, svc.PlanGrow(&control.GrowArgs{ID: "root"}, &plan))
+	if assert.Len(t, plan.Actions, 2) {
+		assert.Equal(t, diskutil.ActionRepair, plan.Actions[0].Kind)
+		assert.Equal(t, diskutil.ActionResize, plan.Actions[1].Kind)
+	}
+
+	plan = diskutil.Plan{}
+	assert.NoError(t, svc.PlanProvision(&control.ProvisionArgs{ID: "disk4", MountPoint: "/Volumes/builds"}, &plan))
+	if assert.Len(t, plan.Actions, 2) {
+		assert.Equal(t, diskutil.ActionErase, plan.Actions[0].Kind)
+		assert.Equal(t, "disk4", plan.Actions[0].Device)
+		assert.Equal(t, `disk is blank, format it with a single APFS volume named "Data"`, plan.Actions[0].Reason)
+		assert.Equal(t, diskutil.ActionMount, plan.Actions[1].Kind)
+	}
+
+	for _, c := range fake.Calls() {
+		assert.Contains(t, []string{"Info", "List"}, c.Method, "planning shouldn't change any disks")
+	}
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/defaults"
+)
+
+// defaultsDefaultTimeout is the default maximum run duration for checking and applying preferences.
+const defaultsDefaultTimeout = time.Minute
+
+// defaultsCommand creates a new command which groups the preference management subcommands.
+func defaultsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "defaults",
+		Short: "manage preferences",
+		Long: strings.TrimSpace(`
+defaults manages the preferences listed in the defaults section
+of the configuration file with defaults(1). Each preference is
+identified by its domain and key and is set to a bool, int,
+string, or dict value. Preferences can be set for a specific user
+and restricted to the current host.
+`),
+	}
+
+	cmd.AddCommand(defaultsCheckCommand(), defaultsApplyCommand())
+
+	return cmd
+}
+
+// defaultsCheckCommand creates a new command which reports drift from the configured preferences.
+func defaultsCheckCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "report drift from the configured preferences",
+		Args:  cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", defaultsDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runDefaults(cmd, timeout, func(ctx context.Context, t *defaults.Task) error {
+			return checkTask(ctx, cmd, t)
+		})
+	}
+
+	return cmd
+}
+
+// defaultsApplyCommand creates a new command which applies the configured preferences.
+func defaultsApplyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "apply the configured preferences",
+		Args:  cobra.NoArgs,
+	}
+
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", defaultsDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Writing system-wide preferences and the preferences of other users requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runDefaults(cmd, timeout, func(ctx context.Context, t *defaults.Task) error {
+			return applyTask(ctx, cmd, t, dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// runDefaults builds the defaults task from the configuration and runs it with fn.
+func runDefaults(cmd *cobra.Command, timeout time.Duration, fn func(ctx context.Context, t *defaults.Task) error) error {
+	ctx := cmd.Context()
+	if timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	c, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	t, err := defaultsTask(c.Defaults)
+	if err != nil {
+		return err
+	}
+	if len(t.Settings) == 0 {
+		logrus.Info("No preferences configured, nothing to do")
+		return nil
+	}
+
+	if err := fn(ctx, t); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return errors.New("timeout exceeded")
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// defaultsTask builds the defaults task from the configured preferences.
+func defaultsTask(conf []config.Default) (*defaults.Task, error) {
+	t := &defaults.Task{}
+	for i, d := range conf {
+		domain := defaults.Domain{
+			Name:        d.Domain,
+			User:        d.User,
+			CurrentHost: d.CurrentHost,
+		}
+		s, err := defaults.NewSetting(domain, d.Key, defaults.Type(d.Type), d.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid preference %d in config: %w", i+1, err)
+		}
+		t.Settings = append(t.Settings, s)
+	}
+
+	return t, nil
+}
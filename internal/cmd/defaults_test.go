@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/defaults"
+)
+
+func TestDefaultsTask(t *testing.T) {
+	task, err := defaultsTask([]config.Default{
+		{Domain: "com.apple.screensaver", Key: "idleTime", Value: 0, CurrentHost: true, User: "ec2-user"},
+		{Domain: "/Library/Preferences/com.apple.loginwindow", Key: "DisableScreenLock", Type: "bool", Value: "YES"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []defaults.Setting{
+		{Domain: defaults.Domain{Name: "com.apple.screensaver", User: "ec2-user", CurrentHost: true}, Key: "idleTime", Type: defaults.TypeInt, Value: 0},
+		{Domain: defaults.Domain{Name: "/Library/Preferences/com.apple.loginwindow"}, Key: "DisableScreenLock", Type: defaults.TypeBool, Value: true},
+	}, task.Settings)
+}
+
+func TestDefaultsTask_Invalid(t *testing.T) {
+	_, err := defaultsTask([]config.Default{{Domain: "com.apple.finder", Key: "ShowPathbar", Type: "bool", Value: "sometimes"}})
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/devtools"
+	"github.com/aws/ec2-macos-utils/internal/softwareupdate"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// devtoolsDefaultTimeout is the default maximum run duration for developer tools commands. Downloading and installing
+// the Command Line Tools takes several minutes.
+const devtoolsDefaultTimeout = 30 * time.Minute
+
+// commandLineTools detects and installs the Command Line Tools.
+type commandLineTools interface {
+	// Version gets the version of the installed Command Line Tools, or devtools.ErrNotInstalled.
+	Version(ctx context.Context) (string, error)
+	// Find finds the newest Command Line Tools offered for the product.
+	Find(ctx context.Context, p *system.Product) (*softwareupdate.Update, error)
+	// Install installs the Command Line Tools update.
+	Install(ctx context.Context, update *softwareupdate.Update) error
+}
+
+// systemCommandLineTools manages the Command Line Tools on the system.
+type systemCommandLineTools struct{}
+
+func (systemCommandLineTools) Version(ctx context.Context) (string, error) {
+	return devtools.CommandLineToolsVersion(ctx)
+}
+
+func (systemCommandLineTools) Find(ctx context.Context, p *system.Product) (*softwareupdate.Update, error) {
+	return devtools.FindCommandLineTools(ctx, p)
+}
+
+func (systemCommandLineTools) Install(ctx context.Context, update *softwareupdate.Update) error {
+	return devtools.InstallCommandLineTools(ctx, update)
+}
+
+// devtoolsCommand creates a new command which groups the developer tools subcommands.
+func devtoolsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "devtools",
+		Short: "manage Xcode and the Command Line Tools",
+		Long: strings.TrimSpace(`
+devtools detects the installed developer tools, installs the
+Command Line Tools without the interactive prompt, and selects
+the active developer directory with xcode-select(1) so that
+bootstrap scripts don't need to script each of these steps.
+`),
+	}
+
+	cmd.AddCommand(devtoolsStatusCommand(), devtoolsInstallCommand(), devtoolsSelectCommand())
+
+	return cmd
+}
+
+// devtoolsStatusCommand creates a new command which reports the installed developer tools.
+func devtoolsStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "report the installed developer tools",
+		Long: strings.TrimSpace(`
+status reports the active developer directory, whether the
+Command Line Tools are installed, and the installations of
+Xcode in /Applications. The command fails when no developer
+directory is selected so that scripts can check for the tools
+with its exit status.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", devtoolsDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			status, err := devtools.GetStatus(ctx)
+			if err != nil {
+				return err
+			}
+
+			if err := printOutput(cmd.OutOrStdout(), outputFormat(cmd), status, func(w io.Writer) error {
+				return printDevtoolsStatus(w, status)
+			}); err != nil {
+				return err
+			}
+			if status.DeveloperDir == "" {
+				return errors.New("no developer directory is selected, install the Command Line Tools or Xcode")
+			}
+
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+// devtoolsInstallCommand creates a new command which installs the Command Line Tools.
+func devtoolsInstallCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "install the Command Line Tools",
+		Long: strings.TrimSpace(`
+install installs the newest Command Line Tools offered by
+softwareupdate(8) for the running release of macOS without
+prompting. Nothing is done when they're already installed
+unless --force is set, which installs the newest ones anyway.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	var dryrun, force bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&force, "force", false, "install the Command Line Tools even if they're already installed")
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", devtoolsDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Installing software with softwareupdate requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			product := contextual.Product(ctx)
+			if product == nil {
+				return errors.New("product required in context")
+			}
+
+			return installCommandLineTools(ctx, systemCommandLineTools{}, product, force, dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// installCommandLineTools installs the newest Command Line Tools for the product unless they're already installed
+// and force isn't set.
+func installCommandLineTools(ctx context.Context, clt commandLineTools, p *system.Product, force, dryrun bool) error {
+	version, err := clt.Version(ctx)
+	if err != nil && !errors.Is(err, devtools.ErrNotInstalled) {
+		return err
+	}
+	if version != "" && !force {
+		logrus.WithField("version", version).Info("Command Line Tools already installed, nothing to do")
+		return nil
+	}
+
+	logrus.Info("Finding the Command Line Tools...")
+	update, err := clt.Find(ctx, p)
+	if err != nil {
+		return err
+	}
+	if dryrun {
+		logrus.WithField("label", update.Label).Warn("Would have installed the Command Line Tools")
+		return nil
+	}
+
+	logrus.WithField("label", update.Label).Info("Installing the Command Line Tools...")
+	if err := clt.Install(ctx, update); err != nil {
+		return err
+	}
+	logrus.WithField("label", update.Label).Info("Successfully installed the Command Line Tools")
+
+	return nil
+}
+
+// devtoolsSelectCommand creates a new command which selects the active developer directory.
+func devtoolsSelectCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "select <path>",
+		Short: "select the active developer directory",
+		Long: strings.TrimSpace(`
+select sets the developer directory used by tools like xcrun and
+xcodebuild to an installation of Xcode (e.g.
+/Applications/Xcode_15.0.app) or to the Command Line Tools with
+the special path "clt". With --add-to-path, the tools in the
+developer directory's usr/bin are also added to the PATH of login
+shells with a file in /etc/paths.d, which is replaced when
+another developer directory is selected.
+`),
+		Args: cobra.ExactArgs(1),
+	}
+
+	var dryrun, addToPath bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().BoolVar(&addToPath, "add-to-path", false, "add the developer directory's tools to the PATH of login shells")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", devtoolsDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Changing the system's developer directory requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			path := args[0]
+			if path == "clt" {
+				path = devtools.CommandLineToolsPath
+			}
+
+			if err := selectDeveloperDir(ctx, path, dryrun); err != nil {
+				return err
+			}
+			if !addToPath {
+				return nil
+			}
+
+			return applyTask(ctx, cmd, devtools.PathsTask(developerDirFor(path)), dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// selectDeveloperDir selects the developer directory at path unless it's already selected.
+func selectDeveloperDir(ctx context.Context, path string, dryrun bool) error {
+	current, err := devtools.DeveloperDir(ctx)
+	if err != nil && !errors.Is(err, devtools.ErrNotInstalled) {
+		return err
+	}
+	if isDeveloperDir(current, path) {
+		logrus.WithField("developer_dir", current).Info("Developer directory already selected, nothing to do")
+		return nil
+	}
+	if dryrun {
+		logrus.WithFields(logrus.Fields{
+			"current": current,
+			"path":    path,
+		}).Warn("Would have selected developer directory")
+		return nil
+	}
+
+	if err := devtools.Select(ctx, path); err != nil {
+		return err
+	}
+	logrus.WithField("path", path).Info("Successfully selected developer directory")
+
+	return nil
+}
+
+// developerDirFor gets the developer directory that selecting path selects, which is inside of Xcode applications.
+func developerDirFor(path string) string {
+	path = filepath.Clean(path)
+	if strings.HasSuffix(path, ".app") {
+		return filepath.Join(path, "Contents", "Developer")
+	}
+
+	return path
+}
+
+// isDeveloperDir checks if the developer directory dir belongs to the Xcode application or developer directory at
+// path. Selecting Xcode applications selects the developer directory inside of them.
+func isDeveloperDir(dir, path string) bool {
+	path = filepath.Clean(path)
+
+	return dir == path || dir == filepath.Join(path, "Contents", "Developer")
+}
+
+// printDevtoolsStatus writes the developer tools' status to w.
+func printDevtoolsStatus(w io.Writer, status *devtools.Status) error {
+	dir := status.DeveloperDir
+	if dir == "" {
+		dir = "(none)"
+	}
+	clt := "not installed"
+	if status.CommandLineTools {
+		clt = status.CommandLineToolsVersion
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Developer directory:\t%s\n", dir)
+	fmt.Fprintf(tw, "Command Line Tools:\t%s\n", clt)
+	if len(status.Xcodes) == 0 {
+		fmt.Fprintf(tw, "Xcode:\t%s\n", "not installed")
+	}
+	for _, x := range status.Xcodes {
+		fmt.Fprintf(tw, "Xcode:\t%s (%s)\n", x.Version, x.Path)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/devtools"
+	"github.com/aws/ec2-macos-utils/internal/softwareupdate"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// fakeCommandLineTools records the Command Line Tools that were installed.
+type fakeCommandLineTools struct {
+	version   string
+	installed []string
+}
+
+func (f *fakeCommandLineTools) Version(ctx context.Context) (string, error) {
+	if f.version == "" {
+		return "", devtools.ErrNotInstalled
+	}
+
+	return f.version, nil
+}
+
+func (f *fakeCommandLineTools) Find(ctx context.Context, p *system.Product) (*softwareupdate.Update, error) {
+	return &softwareupdate.Update{Label: "Command Line Tools for Xcode-15.0", Version: "15.0"}, nil
+}
+
+func (f *fakeCommandLineTools) Install(ctx context.Context, update *softwareupdate.Update) error {
+	f.installed = append(f.installed, update.Label)
+
+	return nil
+}
+
+func TestInstallCommandLineTools(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		force   bool
+		dryrun  bool
+		want    []string
+	}{
+		{name: "missing", want: []string{"Command Line Tools for Xcode-15.0"}},
+		{name: "installed", version: "14.3.0.0.1.1679647830"},
+		{name: "installed with force", version: "14.3.0.0.1.1679647830", force: true, want: []string{"Command Line Tools for Xcode-15.0"}},
+		{name: "dry run", dryrun: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clt := &fakeCommandLineTools{version: tt.version}
+
+			err := installCommandLineTools(context.Background(), clt, &system.Product{Release: system.Ventura}, tt.force, tt.dryrun)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, clt.installed)
+		})
+	}
+}
+
+func TestIsDeveloperDir(t *testing.T) {
+	assert.True(t, isDeveloperDir("/Applications/Xcode.app/Contents/Developer", "/Applications/Xcode.app/"))
+	assert.True(t, isDeveloperDir(devtools.CommandLineToolsPath, devtools.CommandLineToolsPath))
+	assert.False(t, isDeveloperDir("/Applications/Xcode.app/Contents/Developer", "/Applications/Xcode_14.3.app"))
+	assert.False(t, isDeveloperDir("", devtools.CommandLineToolsPath))
+}
+
+func TestDeveloperDirFor(t *testing.T) {
+	assert.Equal(t, "/Applications/Xcode.app/Contents/Developer", developerDirFor("/Applications/Xcode.app/"))
+	assert.Equal(t, devtools.CommandLineToolsPath, developerDirFor(devtools.CommandLineToolsPath))
+}
+
+func TestPrintDevtoolsStatus(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := printDevtoolsStatus(&buf, &devtools.Status{
+		DeveloperDir:            devtools.CommandLineToolsPath,
+		CommandLineTools:        true,
+		CommandLineToolsVersion: "15.0.0.0.1.1694021235",
+		Xcodes:                  []devtools.Xcode{},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Developer directory:  /Library/Developer/CommandLineTools\nCommand Line Tools:   15.0.0.0.1.1694021235\nXcode:                not installed\n", buf.String())
+}
@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/diagnostics"
+	"github.com/aws/ec2-macos-utils/internal/imds"
+)
+
+const (
+	// diagnosticsDefaultDir is the default directory that archives of diagnostic reports are written to.
+	diagnosticsDefaultDir = "/usr/local/aws/ec2-macos-utils/diagnostics"
+	// diagnosticsDefaultSince is the default age of the oldest diagnostic reports that are collected.
+	diagnosticsDefaultSince = 7 * 24 * time.Hour
+	// diagnosticsDefaultTimeout is the default maximum run duration for collecting diagnostic reports.
+	diagnosticsDefaultTimeout = 5 * time.Minute
+	// sysdiagnoseDefaultTimeout is the default maximum run duration for running sysdiagnose and uploading its
+	// archive, which can be several hundred megabytes.
+	sysdiagnoseDefaultTimeout = 30 * time.Minute
+)
+
+// collectDiagnostics is a struct for holding all information passed into the diagnostics collect command.
+type collectDiagnostics struct {
+	dir        string
+	since      time.Duration
+	panicsOnly bool
+	users      bool
+	upload     string
+	timeout    time.Duration
+}
+
+// diagnosticsCollection is the result of the diagnostics collect command.
+type diagnosticsCollection struct {
+	Archive string `json:"archive"`
+	URI     string `json:"uri,omitempty"`
+	Reports int    `json:"reports"`
+	Panics  int    `json:"panics"`
+}
+
+// runSysdiagnose is a struct for holding all information passed into the diagnostics sysdiagnose command.
+type runSysdiagnose struct {
+	dir     string
+	upload  string
+	timeout time.Duration
+}
+
+// sysdiagnoseResult is the result of the diagnostics sysdiagnose command.
+type sysdiagnoseResult struct {
+	Archive string `json:"archive"`
+	URI     string `json:"uri,omitempty"`
+}
+
+// diagnosticsCommand creates a new command which groups the diagnostics subcommands.
+func diagnosticsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diagnostics",
+		Short: "collect diagnostic reports for triage",
+	}
+
+	cmd.AddCommand(diagnosticsCollectCommand(), diagnosticsSysdiagnoseCommand())
+
+	return cmd
+}
+
+// diagnosticsCollectCommand creates a new command which packages the recent kernel panic logs and crash reports.
+func diagnosticsCollectCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "collect",
+		Short: "package recent kernel panic logs and crash reports",
+		Long: strings.TrimSpace(`
+collect gathers the kernel panic logs and crash reports that
+macOS saved in /Library/Logs/DiagnosticReports within --since,
+including the reports already submitted to Apple, and packages
+them into a gzipped tar in --dir along with a manifest.json
+describing them. Users' crash reports are also collected with
+--user-reports. With --upload, the archive is uploaded to the
+S3 prefix (e.g. s3://bucket/diagnostics) with the instance
+profile's credentials so that hosts which rebooted unexpectedly
+can be triaged without logging in to them.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	collectArgs := collectDiagnostics{}
+	cmd.PersistentFlags().StringVar(&collectArgs.dir, "dir", diagnosticsDefaultDir, "directory that the archive is written to")
+	cmd.PersistentFlags().DurationVar(&collectArgs.since, "since", diagnosticsDefaultSince, "age of the oldest reports that are collected (e.g. 24h)")
+	cmd.PersistentFlags().BoolVar(&collectArgs.panicsOnly, "panics-only", false, "only collect kernel panic logs")
+	cmd.PersistentFlags().BoolVar(&collectArgs.users, "user-reports", false, "also collect the crash reports of users' processes")
+	cmd.PersistentFlags().StringVar(&collectArgs.upload, "upload", "", "S3 prefix that the archive is uploaded to (e.g. s3://bucket/diagnostics)")
+	cmd.PersistentFlags().DurationVar(&collectArgs.timeout, "timeout", diagnosticsDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Reading the reports of every process and user requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if collectArgs.upload != "" {
+			if _, _, err := aws.ParseS3URI(collectArgs.upload); err != nil {
+				return err
+			}
+		}
+
+		return runUserCommand(cmd, collectArgs.timeout, func(ctx context.Context) error {
+			collection, err := runCollectDiagnostics(ctx, collectArgs)
+			if err != nil {
+				return err
+			}
+
+			return printDiagnosticsCollection(cmd.OutOrStdout(), outputFormat(cmd), collection)
+		})
+	}
+
+	return cmd
+}
+
+// runCollectDiagnostics archives the reports in the directory and uploads the archive when asked to.
+func runCollectDiagnostics(ctx context.Context, args collectDiagnostics) (*diagnosticsCollection, error) {
+	dirs, err := diagnostics.Dirs(args.users)
+	if err != nil {
+		return nil, err
+	}
+	var kinds []diagnostics.Kind
+	if args.panicsOnly {
+		kinds = append(kinds, diagnostics.KindPanic)
+	}
+
+	now := time.Now()
+	m := &diagnostics.Manifest{Collected: now, Since: now.Add(-args.since)}
+	m.Reports, err = diagnostics.Find(dirs, m.Since, kinds...)
+	if err != nil {
+		return nil, err
+	}
+	m.Hostname, _ = os.Hostname()
+	metadata := imds.NewClient()
+	if m.InstanceID, err = metadata.InstanceID(ctx); err != nil {
+		logrus.WithError(err).Debug("Collecting diagnostics without the instance ID")
+	}
+	logrus.WithFields(logrus.Fields{"reports": len(m.Reports), "panics": m.Panics()}).Info("Found diagnostic reports")
+
+	host := m.InstanceID
+	if host == "" {
+		host = m.Hostname
+	}
+	var buf bytes.Buffer
+	if err := diagnostics.Archive(&buf, m); err != nil {
+		return nil, err
+	}
+
+	collection := &diagnosticsCollection{
+		Archive: filepath.Join(args.dir, diagnostics.ArchiveName(host, now)),
+		Reports: len(m.Reports),
+		Panics:  m.Panics(),
+	}
+	// Archives include the logs of every process so they're only readable by root.
+	if err := os.MkdirAll(args.dir, 0o700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(collection.Archive, buf.Bytes(), 0o600); err != nil {
+		return nil, err
+	}
+
+	if args.upload != "" {
+		if collection.URI, err = uploadDiagnostics(ctx, metadata, args.upload, collection.Archive); err != nil {
+			return nil, err
+		}
+	}
+
+	return collection, nil
+}
+
+// diagnosticsSysdiagnoseCommand creates a new command which runs sysdiagnose and keeps or uploads its archive.
+func diagnosticsSysdiagnoseCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sysdiagnose",
+		Short: "run sysdiagnose and collect its archive",
+		Long: strings.TrimSpace(`
+sysdiagnose runs sysdiagnose(1) without prompting, waits for it
+to write its archive of the system's logs and the state of its
+disks and network to --dir, and prints the archive's path. With
+--upload, the archive is uploaded to the S3 prefix (e.g.
+s3://bucket/diagnostics) with the instance profile's
+credentials, e.g. to attach it to a case with AWS Support.
+sysdiagnose takes several minutes and its archive can be
+several hundred megabytes.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	sysdiagnoseArgs := runSysdiagnose{}
+	cmd.PersistentFlags().StringVar(&sysdiagnoseArgs.dir, "dir", diagnosticsDefaultDir, "directory that the archive is written to")
+	cmd.PersistentFlags().StringVar(&sysdiagnoseArgs.upload, "upload", "", "S3 prefix that the archive is uploaded to (e.g. s3://bucket/diagnostics)")
+	cmd.PersistentFlags().DurationVar(&sysdiagnoseArgs.timeout, "timeout", sysdiagnoseDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// sysdiagnose requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if sysdiagnoseArgs.upload != "" {
+			if _, _, err := aws.ParseS3URI(sysdiagnoseArgs.upload); err != nil {
+				return err
+			}
+		}
+
+		return runUserCommand(cmd, sysdiagnoseArgs.timeout, func(ctx context.Context) error {
+			result, err := runSysdiagnoseCommand(ctx, sysdiagnoseArgs)
+			if err != nil {
+				return err
+			}
+
+			return printSysdiagnoseResult(cmd.OutOrStdout(), outputFormat(cmd), result)
+		})
+	}
+
+	return cmd
+}
+
+// runSysdiagnoseCommand runs sysdiagnose and uploads its archive when asked to.
+func runSysdiagnoseCommand(ctx context.Context, args runSysdiagnose) (*sysdiagnoseResult, error) {
+	metadata := imds.NewClient()
+	host, err := metadata.InstanceID(ctx)
+	if err != nil {
+		logrus.WithError(err).Debug("Naming the sysdiagnose archive without the instance ID")
+		if host, err = os.Hostname(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Archives include the logs of every process so they're only readable by root.
+	if err := os.MkdirAll(args.dir, 0o700); err != nil {
+		return nil, err
+	}
+	logrus.Info("Running sysdiagnose, this takes several minutes...")
+	archive, err := diagnostics.Sysdiagnose(ctx, args.dir, diagnostics.SysdiagnoseName(host, time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	result := &sysdiagnoseResult{Archive: archive}
+	logrus.WithField("archive", archive).Info("sysdiagnose finished")
+
+	if args.upload != "" {
+		if result.URI, err = uploadDiagnostics(ctx, metadata, args.upload, archive); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// uploadDiagnostics uploads the archive at path under the S3 prefix, with a client for the instance's region, and
+// returns the URI it was uploaded to.
+func uploadDiagnostics(ctx context.Context, metadata *imds.Client, prefix, path string) (string, error) {
+	bucket, key, err := aws.ParseS3URI(prefix)
+	if err != nil {
+		return "", err
+	}
+	key = strings.TrimSuffix(key, "/") + "/" + filepath.Base(path)
+
+	region, err := metadata.Region(ctx)
+	if err != nil {
+		return "", fmt.Errorf("cannot determine region for uploading: %w", err)
+	}
+	if err := aws.NewClient(region, metadata).PutFile(ctx, bucket, key, path); err != nil {
+		return "", err
+	}
+	uri := fmt.Sprintf("s3://%s/%s", bucket, key)
+	logrus.WithField("uri", uri).Info("Uploaded diagnostics")
+
+	return uri, nil
+}
+
+// printDiagnosticsCollection writes where the archive was written and uploaded to w.
+func printDiagnosticsCollection(w io.Writer, format string, collection *diagnosticsCollection) error {
+	return printOutput(w, format, collection, func(w io.Writer) error {
+		fmt.Fprintf(w, "collected %d reports (%d kernel panics) in %s\n", collection.Reports, collection.Panics, collection.Archive)
+		if collection.URI == "" {
+			return nil
+		}
+		_, err := fmt.Fprintf(w, "uploaded to %s\n", collection.URI)
+		return err
+	})
+}
+
+// printSysdiagnoseResult writes where the sysdiagnose archive was written and uploaded to w.
+func printSysdiagnoseResult(w io.Writer, format string, result *sysdiagnoseResult) error {
+	return printOutput(w, format, result, func(w io.Writer) error {
+		fmt.Fprintf(w, "sysdiagnose archive: %s\n", result.Archive)
+		if result.URI == "" {
+			return nil
+		}
+		_, err := fmt.Fprintf(w, "uploaded to %s\n", result.URI)
+		return err
+	})
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintDiagnosticsCollection(t *testing.T) {
+	collection := &diagnosticsCollection{
+		Archive: "/usr/local/aws/ec2-macos-utils/diagnostics/diagnostics-i-0123456789abcdef0-20230501T101500Z.tar.gz",
+		URI:     "s3://triage/diagnostics/diagnostics-i-0123456789abcdef0-20230501T101500Z.tar.gz",
+		Reports: 3,
+		Panics:  1,
+	}
+
+	var text bytes.Buffer
+	assert.NoError(t, printDiagnosticsCollection(&text, outputText, collection))
+	assert.Equal(t, "collected 3 reports (1 kernel panics) in /usr/local/aws/ec2-macos-utils/diagnostics/diagnostics-i-0123456789abcdef0-20230501T101500Z.tar.gz\n"+
+		"uploaded to s3://triage/diagnostics/diagnostics-i-0123456789abcdef0-20230501T101500Z.tar.gz\n", text.String())
+
+	collection.URI = ""
+	var js bytes.Buffer
+	assert.NoError(t, printDiagnosticsCollection(&js, outputJSON, collection))
+	assert.NotContains(t, js.String(), "uri", "archives that weren't uploaded shouldn't have a URI")
+}
+
+func TestPrintSysdiagnoseResult(t *testing.T) {
+	result := &sysdiagnoseResult{
+		Archive: "/usr/local/aws/ec2-macos-utils/diagnostics/sysdiagnose-i-0123456789abcdef0-20230501T101500Z.tar.gz",
+	}
+
+	var text bytes.Buffer
+	assert.NoError(t, printSysdiagnoseResult(&text, outputText, result))
+	assert.Equal(t, "sysdiagnose archive: /usr/local/aws/ec2-macos-utils/diagnostics/sysdiagnose-i-0123456789abcdef0-20230501T101500Z.tar.gz\n", text.String())
+}
@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/diskhealth"
+	"github.com/aws/ec2-macos-utils/internal/health"
+	"github.com/aws/ec2-macos-utils/internal/imds"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+)
+
+const (
+	// diskHealthDefaultTimeout is the default maximum run duration of a single check.
+	diskHealthDefaultTimeout = 10 * time.Minute
+	// diskHealthDefaultInterval is the default time between checks of the monitor.
+	diskHealthDefaultInterval = 5 * time.Minute
+	// diskHealthDefaultVerifyInterval is the default time between verifying the filesystems in the monitor.
+	diskHealthDefaultVerifyInterval = 24 * time.Hour
+)
+
+// diskHealthThresholds is a struct for holding the free space thresholds passed into the disk-health commands.
+type diskHealthThresholds struct {
+	minFreePercent float64
+	minFreeBytes   uint64
+}
+
+// addFlags adds the threshold flags to the command.
+func (t *diskHealthThresholds) addFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().Float64Var(&t.minFreePercent, "min-free-percent", diskhealth.DefaultMinFreePercent, "percentage of each filesystem's space that must be free, 0 will disable the check")
+	cmd.PersistentFlags().Uint64Var(&t.minFreeBytes, "min-free-bytes", 0, "bytes of each filesystem's space that must be free, 0 will disable the check")
+}
+
+// thresholds gets the thresholds for the checker.
+func (t diskHealthThresholds) thresholds() diskhealth.Thresholds {
+	return diskhealth.Thresholds{MinFreePercent: t.minFreePercent, MinFreeBytes: t.minFreeBytes}
+}
+
+// monitorDiskHealth is a struct for holding all information passed into the disk-health monitor command.
+type monitorDiskHealth struct {
+	thresholds     diskHealthThresholds
+	interval       time.Duration
+	verifyInterval time.Duration
+	cloudWatch     bool
+	namespace      string
+	snsTopicARN    string
+	command        string
+	listen         string
+}
+
+// diskHealthCommand creates a new command which groups the disk health subcommands.
+func diskHealthCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "disk-health",
+		Short: "check the health of the host's disks",
+		Long: strings.TrimSpace(`
+disk-health checks the SMART status of the whole disks, the
+results of verifying the mounted APFS filesystems, and the free
+space left in the filesystems, so that failing or full disks
+are noticed before the builds running on them fail. Disks that
+don't report a SMART status, like EBS volumes, are left out of
+the SMART check.
+`),
+	}
+
+	cmd.AddCommand(diskHealthCheckCommand(), diskHealthMonitorCommand())
+
+	return cmd
+}
+
+// diskHealthCheckCommand creates a new command which checks the health of the disks once.
+func diskHealthCheckCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "check the health of the disks once",
+		Long: strings.TrimSpace(`
+check runs the health checks once and prints their findings,
+failing when any of them found a problem. Filesystems are only
+verified with --verify since verifying reads all of their
+metadata.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	var thresholds diskHealthThresholds
+	var verify bool
+	var timeout time.Duration
+	thresholds.addFlags(cmd)
+	cmd.PersistentFlags().BoolVar(&verify, "verify", false, "also verify the mounted APFS filesystems")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", diskHealthDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Verifying filesystems with fsck_apfs requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			d, err := diskHealthDiskUtil(ctx)
+			if err != nil {
+				return err
+			}
+
+			r, err := diskhealth.NewChecker(d, thresholds.thresholds()).Check(ctx, verify)
+			if err != nil {
+				return err
+			}
+			if err := printDiskHealthReport(cmd.OutOrStdout(), outputFormat(cmd), r); err != nil {
+				return err
+			}
+			if r.Status == diskhealth.StatusDegraded {
+				return errors.New(r.Summary())
+			}
+
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+// diskHealthMonitorCommand creates a new command which checks the health of the disks on an interval and triggers
+// the configured hooks.
+func diskHealthMonitorCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "monitor",
+		Short: "check the health of the disks on an interval and raise alarms",
+		Long: strings.TrimSpace(`
+monitor runs until it's stopped, checking the health of the
+disks every --interval and verifying the filesystems every
+--verify-interval. With --cloudwatch, the number of problems
+found is published after every check as the DiskHealthDegraded
+metric, in total and for each kind of check, so that CloudWatch
+alarms can be set on it. When the problems found change (the
+disks degrade, recover, or different problems are found), the
+findings are published as JSON to --sns-topic-arn and given on
+stdin to --command, which also gets the status and summary in
+the EC2_MACOS_UTILS_DISK_HEALTH and
+EC2_MACOS_UTILS_DISK_HEALTH_SUMMARY environment variables.
+Alarms that fail are triggered again on the next check. The
+monitor's status is served at /healthz with --listen.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	monitorArgs := monitorDiskHealth{}
+	monitorArgs.thresholds.addFlags(cmd)
+	cmd.PersistentFlags().DurationVar(&monitorArgs.interval, "interval", diskHealthDefaultInterval, "time between checks (e.g. 30s, 5m)")
+	cmd.PersistentFlags().DurationVar(&monitorArgs.verifyInterval, "verify-interval", diskHealthDefaultVerifyInterval, "time between verifying the filesystems (e.g. 6h), 0s will disable verifying")
+	cmd.PersistentFlags().BoolVar(&monitorArgs.cloudWatch, "cloudwatch", false, "publish the DiskHealthDegraded metric to CloudWatch")
+	cmd.PersistentFlags().StringVar(&monitorArgs.namespace, "namespace", diskhealth.DefaultNamespace, "CloudWatch namespace that the metric is published in")
+	cmd.PersistentFlags().StringVar(&monitorArgs.snsTopicARN, "sns-topic-arn", "", "ARN of the SNS topic that alarms are published to")
+	cmd.PersistentFlags().StringVar(&monitorArgs.command, "command", "", "shell command that's run on alarms")
+	cmd.PersistentFlags().StringVar(&monitorArgs.listen, "listen", "", "loopback address to serve the monitor's status on")
+
+	// Verifying filesystems with fsck_apfs requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if monitorArgs.interval <= 0 {
+			return fmt.Errorf("interval must be positive, got %s", monitorArgs.interval)
+		}
+
+		d, err := diskHealthDiskUtil(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		m, err := newDiskHealthMonitor(cmd.Context(), d, monitorArgs)
+		if err != nil {
+			return err
+		}
+
+		if monitorArgs.listen == "" {
+			return m.Run(cmd.Context())
+		}
+
+		status := health.NewMonitor("disk-health")
+		m.OnRun = status.Record
+		go func() {
+			handlers := map[string]http.Handler{health.Path: status}
+			if err := health.Serve(cmd.Context(), monitorArgs.listen, handlers); err != nil {
+				logrus.WithError(err).Error("Failed to serve disk health status")
+			}
+		}()
+		logrus.WithField("address", monitorArgs.listen).Info("Serving disk health status")
+
+		return m.Run(cmd.Context())
+	}
+
+	return cmd
+}
+
+// diskHealthDiskUtil gets the DiskUtil for the product in the context.
+func diskHealthDiskUtil(ctx context.Context) (diskutil.DiskUtil, error) {
+	product := contextual.Product(ctx)
+	if product == nil {
+		return nil, errors.New("product required in context")
+	}
+
+	return diskutil.ForProduct(product)
+}
+
+// newDiskHealthMonitor creates a monitor which triggers the hooks configured by the arguments.
+func newDiskHealthMonitor(ctx context.Context, d diskutil.DiskUtil, args monitorDiskHealth) (*diskhealth.Monitor, error) {
+	m := &diskhealth.Monitor{
+		Checker:        diskhealth.NewChecker(d, args.thresholds.thresholds()),
+		Interval:       args.interval,
+		VerifyInterval: args.verifyInterval,
+	}
+
+	// The monitor lives as long as the daemon so its metadata lookups are cached.
+	metadata := imds.NewCachedClient()
+	var instanceID string
+	if args.cloudWatch || args.snsTopicARN != "" || args.command != "" {
+		var err error
+		if instanceID, err = metadata.InstanceID(ctx); err != nil {
+			logrus.WithError(err).Warn("Triggering disk health hooks without the instance ID")
+		}
+	}
+
+	if args.cloudWatch {
+		region, err := metadata.Region(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot determine region for CloudWatch: %w", err)
+		}
+		m.Metrics = append(m.Metrics, &diskhealth.CloudWatchHook{
+			Client:     aws.NewClient(region, metadata),
+			Namespace:  args.namespace,
+			InstanceID: instanceID,
+		})
+	}
+	if args.snsTopicARN != "" {
+		// Topics may be in another region than the instance so the client is created for the topic's region.
+		region, err := aws.RegionFromARN(args.snsTopicARN)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SNS topic: %w", err)
+		}
+		m.Alarms = append(m.Alarms, &diskhealth.SNSHook{
+			Client:     aws.NewClient(region, metadata),
+			TopicARN:   args.snsTopicARN,
+			InstanceID: instanceID,
+		})
+	}
+	if args.command != "" {
+		m.Alarms = append(m.Alarms, &diskhealth.CommandHook{Command: args.command, InstanceID: instanceID})
+	}
+
+	return m, nil
+}
+
+// printDiskHealthReport writes a table of the report's findings to w.
+func printDiskHealthReport(w io.Writer, format string, r *diskhealth.Report) error {
+	if r.Findings == nil {
+		r.Findings = []diskhealth.Finding{}
+	}
+
+	return printOutput(w, format, r, func(w io.Writer) error {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "CHECK\tTARGET\tSTATUS\tMESSAGE")
+		for _, f := range r.Findings {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Check, f.Target, f.Status, f.Message)
+		}
+
+		return tw.Flush()
+	})
+}
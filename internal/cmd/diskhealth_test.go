@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/diskhealth"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/diskutilfakes"
+)
+
+func TestPrintDiskHealthReport(t *testing.T) {
+	r := &diskhealth.Report{
+		Time:   time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC),
+		Status: diskhealth.StatusDegraded,
+		Findings: []diskhealth.Finding{
+			{Check: diskhealth.CheckSMART, Target: "disk0", Status: diskhealth.StatusOK, Message: "SMART status is Verified"},
+			{Check: diskhealth.CheckFreeSpace, Target: "/", Status: diskhealth.StatusDegraded, Message: "4.0% free is below the threshold of 10%"},
+		},
+	}
+
+	var text bytes.Buffer
+	assert.NoError(t, printDiskHealthReport(&text, outputText, r))
+	assert.Equal(t, "CHECK       TARGET  STATUS    MESSAGE\n"+
+		"smart       disk0   ok        SMART status is Verified\n"+
+		"free-space  /       degraded  4.0% free is below the threshold of 10%\n", text.String())
+
+	var js bytes.Buffer
+	assert.NoError(t, printDiskHealthReport(&js, outputJSON, &diskhealth.Report{Status: diskhealth.StatusOK}))
+	assert.Contains(t, js.String(), `"findings": []`, "reports without findings should have an empty list")
+}
+
+func TestNewDiskHealthMonitor_CommandOnly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	m, err := newDiskHealthMonitor(ctx, diskutilfakes.New(), monitorDiskHealth{interval: time.Minute, command: "true"})
+	assert.NoError(t, err)
+	assert.Empty(t, m.Metrics)
+	if assert.Len(t, m.Alarms, 1) {
+		assert.Equal(t, "command", m.Alarms[0].Name())
+	}
+
+	_, err = newDiskHealthMonitor(ctx, diskutilfakes.New(), monitorDiskHealth{interval: time.Minute, snsTopicARN: "alerts"})
+	assert.Error(t, err, "topics must be given by their ARN")
+}
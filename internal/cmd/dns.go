@@ -0,0 +1,362 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/network"
+)
+
+// dnsDefaultTimeout is the default maximum run duration for checking, applying, and verifying DNS settings.
+const dnsDefaultTimeout = time.Minute
+
+// dnsSettings is a struct for holding all information passed into the dns check and apply subcommands.
+type dnsSettings struct {
+	service       string
+	servers       []string
+	searchDomains []string
+	timeout       time.Duration
+}
+
+// serviceDNS is the DNS configuration of a network service reported by dns status.
+type serviceDNS struct {
+	Service string `json:"service"`
+	Device  string `json:"device"`
+	network.DNS
+}
+
+// dnsStatus is the DNS configuration reported by dns status.
+type dnsStatus struct {
+	// Services are the servers and search domains configured for each service.
+	Services []serviceDNS `json:"services"`
+	// Resolvers are the system's effective resolvers, which include the settings provided by DHCP.
+	Resolvers []network.Resolver `json:"resolvers"`
+}
+
+// dnsResolution is the outcome of resolving a host with dns verify.
+type dnsResolution struct {
+	Host      string   `json:"host"`
+	Addresses []string `json:"addresses"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// dnsCommand creates a new command which groups the DNS subcommands.
+func dnsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dns",
+		Short: "manage DNS servers and search domains",
+		Long: strings.TrimSpace(`
+dns manages the DNS servers and search domains of the system's
+network services with networksetup, e.g. to use corporate
+resolvers reachable over the VPC instead of the Amazon DNS
+server that DHCP provides. The settings are stored with each
+service, so they persist across reboots. Services without
+configured settings use the ones provided by DHCP.
+
+Settings are read from the network section's dns entries of the
+configuration file, keyed by service name (e.g. "Ethernet"), and
+can be overridden for a service with flags.
+`),
+	}
+
+	cmd.AddCommand(dnsStatusCommand(), dnsCheckCommand(), dnsApplyCommand(), dnsVerifyCommand())
+
+	return cmd
+}
+
+// addDNSFlags adds the flags used to override the configured settings to the command.
+func addDNSFlags(cmd *cobra.Command, args *dnsSettings) {
+	cmd.PersistentFlags().StringVar(&args.service, "service", "", "network service whose settings are overridden (e.g. Ethernet)")
+	cmd.PersistentFlags().StringSliceVar(&args.servers, "server", nil, "address of a DNS server for the service, may be repeated")
+	cmd.PersistentFlags().StringSliceVar(&args.searchDomains, "search-domain", nil, "search domain for the service, may be repeated")
+	cmd.PersistentFlags().DurationVar(&args.timeout, "timeout", dnsDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+}
+
+// dnsStatusCommand creates a new command which reports the DNS settings of each service and the system's resolvers.
+func dnsStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "report the DNS settings of each network service",
+		Args:  cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", dnsDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			status, err := getDNSStatus(ctx)
+			if err != nil {
+				return err
+			}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), status, func(w io.Writer) error {
+				return printDNSStatus(w, status)
+			})
+		})
+	}
+
+	return cmd
+}
+
+// dnsCheckCommand creates a new command which reports drift from the desired DNS settings.
+func dnsCheckCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "report drift from the desired DNS settings",
+		Args:  cobra.NoArgs,
+	}
+
+	dnsArgs := dnsSettings{}
+	addDNSFlags(cmd, &dnsArgs)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runDNS(cmd, dnsArgs, func(ctx context.Context, t *network.DNSTask) error {
+			return checkTask(ctx, cmd, t)
+		})
+	}
+
+	return cmd
+}
+
+// dnsApplyCommand creates a new command which applies the desired DNS settings.
+func dnsApplyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "apply the desired DNS settings",
+		Long: strings.TrimSpace(`
+apply changes the DNS servers and search domains of the services
+whose settings differ from the desired ones. Hosts given with
+--verify are resolved afterwards, and the command fails when any
+of them doesn't resolve, e.g. because the servers aren't
+reachable.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	dnsArgs := dnsSettings{}
+	addDNSFlags(cmd, &dnsArgs)
+	var dryrun bool
+	var verify []string
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().StringSliceVar(&verify, "verify", nil, "host to resolve after applying the settings, may be repeated")
+
+	// Changing network services' settings with networksetup requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runDNS(cmd, dnsArgs, func(ctx context.Context, t *network.DNSTask) error {
+			if err := applyTask(ctx, cmd, t, dryrun); err != nil {
+				return err
+			}
+			if dryrun || len(verify) == 0 {
+				return nil
+			}
+
+			return verifyDNS(ctx, cmd.OutOrStdout(), outputFormat(cmd), verify)
+		})
+	}
+
+	return cmd
+}
+
+// dnsVerifyCommand creates a new command which resolves hosts with the system's resolvers.
+func dnsVerifyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify <host>...",
+		Short: "verify that hosts resolve with the system's resolvers",
+		Long: strings.TrimSpace(`
+verify resolves each host with the system's resolvers and prints
+their addresses. The command fails when any host doesn't resolve.
+`),
+		Args: cobra.MinimumNArgs(1),
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", dnsDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			return verifyDNS(ctx, cmd.OutOrStdout(), outputFormat(cmd), args)
+		})
+	}
+
+	return cmd
+}
+
+// runDNS builds the DNS task from the configuration and flags and runs it with fn.
+func runDNS(cmd *cobra.Command, args dnsSettings, fn func(ctx context.Context, t *network.DNSTask) error) error {
+	return runUserCommand(cmd, args.timeout, func(ctx context.Context) error {
+		c, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		t, err := dnsTask(cmd, c.Network.DNS, args)
+		if err != nil {
+			return err
+		}
+
+		return fn(ctx, t)
+	})
+}
+
+// dnsTask merges the configured settings with the flags that were set to build the DNS task. Flags take precedence
+// over the configuration of the service they name.
+func dnsTask(cmd *cobra.Command, conf map[string]config.DNS, args dnsSettings) (*network.DNSTask, error) {
+	desired := dnsDesired(conf)
+
+	overridden := cmd.Flags().Changed("server") || cmd.Flags().Changed("search-domain")
+	if overridden && args.service == "" {
+		return nil, errors.New("--service is required with --server and --search-domain")
+	}
+	if args.service != "" {
+		dns := desired[args.service]
+		if cmd.Flags().Changed("server") {
+			dns.Servers = args.servers
+		}
+		if cmd.Flags().Changed("search-domain") {
+			dns.SearchDomains = args.searchDomains
+		}
+		desired[args.service] = dns
+	}
+	if len(desired) == 0 {
+		return nil, errors.New("no DNS settings configured, set them in the configuration file or with --service")
+	}
+
+	return &network.DNSTask{Desired: desired}, nil
+}
+
+// dnsDesired converts the configured settings of each service to the task's.
+func dnsDesired(conf map[string]config.DNS) map[string]network.DNS {
+	desired := map[string]network.DNS{}
+	for service, dns := range conf {
+		desired[service] = network.DNS{Servers: dns.Servers, SearchDomains: dns.SearchDomains}
+	}
+
+	return desired
+}
+
+// getDNSStatus gets the DNS settings of each service, ordered by device, and the system's resolvers.
+func getDNSStatus(ctx context.Context) (*dnsStatus, error) {
+	services, err := network.Services(ctx)
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]string, 0, len(services))
+	for device := range services {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+
+	status := &dnsStatus{Services: []serviceDNS{}}
+	for _, device := range devices {
+		dns, err := network.ServiceDNS(ctx, services[device])
+		if err != nil {
+			return nil, err
+		}
+		status.Services = append(status.Services, serviceDNS{Service: services[device], Device: device, DNS: *dns})
+	}
+
+	if status.Resolvers, err = network.Resolvers(ctx); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// printDNSStatus writes tables of the services' DNS settings and the system's resolvers to w.
+func printDNSStatus(w io.Writer, status *dnsStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tDEVICE\tSERVERS\tSEARCH DOMAINS")
+	for _, s := range status.Services {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Service, s.Device, joinOrDash(s.Servers), joinOrDash(s.SearchDomains))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(status.Resolvers) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESOLVER\tNAMESERVERS\tSEARCH DOMAINS\tINTERFACE")
+	for _, r := range status.Resolvers {
+		domain := r.Domain
+		if domain == "" {
+			domain = "(default)"
+		}
+		iface := r.Interface
+		if iface == "" {
+			iface = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", domain, joinOrDash(r.Nameservers), joinOrDash(r.SearchDomains), iface)
+	}
+
+	return tw.Flush()
+}
+
+// verifyDNS resolves each host, writes the outcomes to w, and fails when any host doesn't resolve.
+func verifyDNS(ctx context.Context, w io.Writer, format string, hosts []string) error {
+	results := make([]dnsResolution, 0, len(hosts))
+	failed := 0
+	for _, host := range hosts {
+		r := dnsResolution{Host: host, Addresses: []string{}}
+		addrs, err := network.Resolve(ctx, host)
+		if err != nil {
+			logrus.WithError(err).WithField("host", host).Warn("Host didn't resolve")
+			r.Error = err.Error()
+			failed++
+		} else {
+			r.Addresses = addrs
+		}
+		results = append(results, r)
+	}
+
+	err := printOutput(w, format, results, func(w io.Writer) error {
+		return printDNSResolutions(w, results)
+	})
+	if err != nil {
+		return err
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d of %d hosts didn't resolve", failed, len(hosts))
+	}
+
+	return nil
+}
+
+// printDNSResolutions writes a table of the hosts' addresses to w.
+func printDNSResolutions(w io.Writer, results []dnsResolution) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOST\tADDRESSES")
+	for _, r := range results {
+		addrs := joinOrDash(r.Addresses)
+		if r.Error != "" {
+			addrs = "unresolved"
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", r.Host, addrs)
+	}
+
+	return tw.Flush()
+}
+
+// joinOrDash joins the values with commas, or gets a dash when there aren't any.
+func joinOrDash(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+
+	return strings.Join(values, ",")
+}
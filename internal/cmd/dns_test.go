@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/network"
+)
+
+func TestDNSTask_FlagsOverrideConfig(t *testing.T) {
+	dnsArgs := dnsSettings{}
+	cmd := &cobra.Command{}
+	addDNSFlags(cmd, &dnsArgs)
+	assert.NoError(t, cmd.ParseFlags([]string{"--service", "Ethernet", "--server", "10.0.0.2,10.0.0.3"}))
+
+	task, err := dnsTask(cmd, map[string]config.DNS{
+		"Ethernet":           {Servers: []string{"172.31.0.2"}, SearchDomains: []string{"corp.example.com"}},
+		"Thunderbolt Bridge": {SearchDomains: []string{}},
+	}, dnsArgs)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]network.DNS{
+		"Ethernet":           {Servers: []string{"10.0.0.2", "10.0.0.3"}, SearchDomains: []string{"corp.example.com"}},
+		"Thunderbolt Bridge": {SearchDomains: []string{}},
+	}, task.Desired, "flags should only override the settings they set")
+}
+
+func TestDNSTask_RequiresService(t *testing.T) {
+	dnsArgs := dnsSettings{}
+	cmd := &cobra.Command{}
+	addDNSFlags(cmd, &dnsArgs)
+	assert.NoError(t, cmd.ParseFlags([]string{"--server", "10.0.0.2"}))
+
+	_, err := dnsTask(cmd, nil, dnsArgs)
+	assert.Error(t, err)
+
+	_, err = dnsTask(&cobra.Command{}, nil, dnsSettings{})
+	assert.Error(t, err, "there should be settings to apply")
+}
+
+func TestPrintDNSStatus(t *testing.T) {
+	var buf bytes.Buffer
+	err := printDNSStatus(&buf, &dnsStatus{
+		Services: []serviceDNS{
+			{Service: "Ethernet", Device: "en0", DNS: network.DNS{Servers: []string{"10.0.0.2"}, SearchDomains: []string{"corp.example.com"}}},
+			{Service: "Thunderbolt Bridge", Device: "bridge0"},
+		},
+		Resolvers: []network.Resolver{{Nameservers: []string{"10.0.0.2"}, Interface: "en0"}, {Domain: "local"}},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `SERVICE             DEVICE   SERVERS   SEARCH DOMAINS
+Ethernet            en0      10.0.0.2  corp.example.com
+Thunderbolt Bridge  bridge0  -         -
+
+RESOLVER   NAMESERVERS  SEARCH DOMAINS  INTERFACE
+(default)  10.0.0.2     -               en0
+local      -            -               -
+`, buf.String())
+}
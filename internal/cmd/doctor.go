@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/doctor"
+)
+
+// doctorDefaultTimeout is the default maximum run duration for running all of the doctor's checks.
+const doctorDefaultTimeout = 2 * time.Minute
+
+// doctorCommand creates a new command which diagnoses the host's configuration.
+func doctorCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "diagnose the host's configuration",
+		Long: strings.TrimSpace(`
+doctor runs a series of read-only checks of the host's
+configuration and reports anything that's likely to cause
+problems, or change how operations behave, along with the
+details it found. The command fails when any check fails or
+can't be completed; warnings are only reported.
+
+Some checks can only read the information they need with root
+permissions so doctor should be run with sudo.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", doctorDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if timeout != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		results := doctor.Run(ctx, doctorChecks())
+		if ctx.Err() == context.DeadlineExceeded {
+			return errors.New("timeout exceeded")
+		}
+
+		if err := printOutput(cmd.OutOrStdout(), outputFormat(cmd), results, func(w io.Writer) error {
+			return printDoctorResults(w, results)
+		}); err != nil {
+			return err
+		}
+
+		if !doctor.Healthy(results) {
+			return errors.New("one or more checks failed")
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// doctorChecks gets the checks run by the doctor command, in the order they're reported.
+func doctorChecks() []doctor.Check {
+	return []doctor.Check{
+		doctor.BinaryCheck{},
+		doctor.SecurityPolicyCheck{},
+		doctor.TRIMCheck{},
+		doctor.MDMCheck{},
+	}
+}
+
+// printDoctorResults writes a table of the check results to w.
+func printDoctorResults(w io.Writer, results []doctor.Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tMESSAGE")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Check, strings.ToUpper(string(r.Status)), r.Message)
+	}
+
+	return tw.Flush()
+}
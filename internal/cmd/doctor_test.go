@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/doctor"
+)
+
+func TestPrintDoctorResults(t *testing.T) {
+	var buf bytes.Buffer
+	results := []doctor.Result{
+		{Check: "security-policy", Status: doctor.StatusWarn, Message: "SIP disabled"},
+	}
+
+	assert.NoError(t, printDoctorResults(&buf, results))
+	assert.Equal(t, "CHECK            STATUS  MESSAGE\n"+
+		"security-policy  WARN    SIP disabled\n", buf.String())
+}
@@ -0,0 +1,342 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/firewall"
+	"github.com/aws/ec2-macos-utils/internal/imds"
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/internal/network"
+	"github.com/aws/ec2-macos-utils/internal/systemsetup"
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/internal/users"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+)
+
+const (
+	// driftDefaultTimeout is the default maximum run duration for checking the configuration for drift.
+	driftDefaultTimeout = 5 * time.Minute
+
+	// defaultSSHUser is the user whose authorized keys are managed when the configuration doesn't name one.
+	defaultSSHUser = "ec2-user"
+)
+
+// driftReport is the drift of each configured task.
+type driftReport struct {
+	// Drifted indicates that at least one task differs from the configuration or couldn't be checked.
+	Drifted bool `json:"drifted"`
+	// Tasks are the results of checking each task.
+	Tasks []driftResult `json:"tasks"`
+}
+
+// driftResult is the drift of a task.
+type driftResult struct {
+	// Task is the name of the task.
+	Task string `json:"task"`
+	// Changes are the differences between the system and the configuration.
+	Changes []task.Change `json:"changes"`
+	// Error is why the task couldn't be checked.
+	Error string `json:"error,omitempty"`
+}
+
+// driftCommand creates a new command which compares the system with the declared configuration.
+func driftCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "drift",
+		Short: "report drift from the declared configuration",
+		Long: strings.TrimSpace(`
+drift checks every section of the configuration file (system
+settings and time sync, users, preferences, the firewall, power
+settings, interface MTUs, DNS and proxy settings, SSH keys and
+sshd settings, volumes, and mounts) against the system and
+reports the differences as JSON, unless another output format is
+selected, without applying any changes. The power settings are
+checked against the server settings whenever the power section is
+present, even when it's empty (power: {}). The command fails when
+anything has drifted.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", driftDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Reading some settings (e.g. remote login) with systemsetup requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			c, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			tasks, err := configTasks(ctx, c)
+			if err != nil {
+				return err
+			}
+			report := checkDrift(ctx, tasks)
+
+			format := outputJSON
+			if f := cmd.Flags().Lookup("output"); f != nil && f.Changed {
+				format = outputFormat(cmd)
+			}
+			if err := printOutput(cmd.OutOrStdout(), format, report, func(w io.Writer) error {
+				return printDriftTable(w, report.Tasks)
+			}); err != nil {
+				return err
+			}
+			if report.Drifted {
+				return errors.New("system has drifted from the configuration")
+			}
+
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+// configTasks builds the tasks for each section of the configuration, in the order they're applied. Sections that
+// aren't configured are skipped. The power section applies the server settings under its own, so it's included
+// whenever it's present, even when it's empty.
+func configTasks(ctx context.Context, c *config.Config) ([]task.Task, error) {
+	var tasks []task.Task
+
+	setup := c.Setup
+	if setup.RemoteLogin != nil || setup.Timezone != "" || setup.RestartOnFreeze != nil || setup.NetworkTime != nil || setup.NetworkTimeServer != "" {
+		tz, err := resolveTimezone(ctx, setup.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, &systemsetup.Task{
+			RemoteLogin:       setup.RemoteLogin,
+			Timezone:          tz,
+			RestartFreeze:     setup.RestartOnFreeze,
+			NetworkTime:       setup.NetworkTime,
+			NetworkTimeServer: setup.NetworkTimeServer,
+		})
+	}
+
+	if len(c.Users) != 0 {
+		tasks = append(tasks, usersTask(c.Users))
+	}
+
+	if len(c.Defaults) != 0 {
+		t, err := defaultsTask(c.Defaults)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+
+	fw := c.Firewall
+	if fw.Enabled != nil || fw.StealthMode != nil || fw.BlockAll != nil || len(fw.AllowedApps) != 0 {
+		tasks = append(tasks, &firewall.Task{
+			Enabled:     fw.Enabled,
+			StealthMode: fw.StealthMode,
+			BlockAll:    fw.BlockAll,
+			AllowedApps: fw.AllowedApps,
+		})
+	}
+
+	if c.Power != nil {
+		tasks = append(tasks, powerTask(c.Power))
+	}
+
+	if len(c.Network.MTU) != 0 {
+		tasks = append(tasks, &network.MTUTask{Desired: c.Network.MTU})
+	}
+
+	if len(c.Network.DNS) != 0 {
+		tasks = append(tasks, &network.DNSTask{Desired: dnsDesired(c.Network.DNS)})
+	}
+
+	if proxy := c.Network.Proxy; proxy.HTTP != "" || proxy.HTTPS != "" || proxy.Bypass != nil {
+		t, err := proxyTask(ctx, proxy)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+
+	if len(c.SSH.AuthorizedKeys) != 0 || c.SSH.FromMetadata {
+		t, err := sshKeysTask(ctx, c.SSH, imds.NewCachedClient())
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+
+	if server := c.SSH.Server; server.PasswordAuthentication != nil || server.PermitRootLogin != "" || len(server.AllowUsers) != 0 || server.ClientAliveInterval != 0 || server.ClientAliveCountMax != 0 {
+		t, err := sshServerTask(server)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+
+	if len(c.Volumes) != 0 || len(c.Mounts) != 0 {
+		product := contextual.Product(ctx)
+		if product == nil {
+			return nil, errors.New("product required in context")
+		}
+		m := mounts.NewManager(product)
+
+		if len(c.Volumes) != 0 {
+			d, err := diskutil.ForProduct(product)
+			if err != nil {
+				return nil, err
+			}
+			tasks = append(tasks, volumesTask(d, m, c.Volumes))
+		}
+		if len(c.Mounts) != 0 {
+			tasks = append(tasks, mountsTask(m, c.Mounts))
+		}
+	}
+
+	return tasks, nil
+}
+
+// publicKeySource fetches the OpenSSH public keys that the instance was launched with.
+type publicKeySource interface {
+	PublicKeys(ctx context.Context) (map[string]string, error)
+}
+
+// sshKeysTask builds the authorized keys task from the configured keys and, when configured, the keys the instance
+// was launched with. The user is looked up when the task runs so that it can be one of the configured users.
+func sshKeysTask(ctx context.Context, conf config.SSH, metadata publicKeySource) (*users.AuthorizedKeysTask, error) {
+	name := conf.User
+	if name == "" {
+		name = defaultSSHUser
+	}
+
+	keys := append([]string(nil), conf.AuthorizedKeys...)
+	if conf.FromMetadata {
+		launched, err := metadata.PublicKeys(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get the instance's public keys: %w", err)
+		}
+		names := make([]string, 0, len(launched))
+		for name := range launched {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			keys = append(keys, launched[name])
+		}
+	}
+
+	return &users.AuthorizedKeysTask{Username: name, Keys: keys}, nil
+}
+
+// usersTask builds the task that creates each configured user that doesn't exist and authorizes its keys.
+func usersTask(conf []config.User) task.Task {
+	var tasks []task.Task
+	for _, u := range conf {
+		g := task.NewGroup(u.Name, &users.AccountTask{
+			Options: users.CreateOptions{Name: u.Name, FullName: u.FullName, Shell: u.Shell},
+			Admin:   u.Admin,
+		})
+		if len(u.AuthorizedKeys) != 0 {
+			g.Tasks = append(g.Tasks, &users.AuthorizedKeysTask{Username: u.Name, Keys: u.AuthorizedKeys})
+		}
+		tasks = append(tasks, g)
+	}
+
+	return task.NewGroup("users", tasks...)
+}
+
+// volumesTask builds the task that provisions each configured volume.
+func volumesTask(utility diskutil.DiskUtil, m *mounts.Manager, conf []config.Volume) task.Task {
+	var tasks []task.Task
+	for _, v := range conf {
+		args := provisionVolume{
+			id:               v.ID,
+			mountPoint:       v.MountPoint,
+			format:           v.Format,
+			label:            v.Label,
+			persist:          v.Persist == nil || *v.Persist,
+			disableSpotlight: v.DisableSpotlight,
+		}
+		if args.format == "" {
+			args.format = string(diskutil.FormatAPFS)
+		}
+		if args.label == "" {
+			args.label = "Data"
+		}
+		tasks = append(tasks, &volumeTask{utility: utility, manager: m, args: args})
+	}
+
+	return task.NewGroup("volumes", tasks...)
+}
+
+// mountsTask builds the mounts task from the configured mounts.
+func mountsTask(m *mounts.Manager, conf []config.Mount) *mounts.Task {
+	t := &mounts.Task{Manager: m}
+	for _, mount := range conf {
+		vfsType := mount.Type
+		if vfsType == "" {
+			vfsType = "apfs"
+		}
+		t.Entries = append(t.Entries, mounts.FstabEntry{
+			Spec:    mount.Spec,
+			File:    mount.MountPoint,
+			VfsType: vfsType,
+			MntOps:  mount.Options,
+		})
+	}
+
+	return t
+}
+
+// checkDrift checks each task. Tasks that can't be checked are reported, and count as drift, rather than stopping
+// the remaining checks.
+func checkDrift(ctx context.Context, tasks []task.Task) *driftReport {
+	report := &driftReport{Tasks: []driftResult{}}
+	for _, t := range tasks {
+		result := driftResult{Task: t.Name(), Changes: []task.Change{}}
+		changes, err := t.Check(ctx)
+		if err != nil {
+			result.Error = err.Error()
+		} else if changes != nil {
+			result.Changes = changes
+		}
+		if err != nil || len(changes) != 0 {
+			report.Drifted = true
+		}
+		report.Tasks = append(report.Tasks, result)
+	}
+
+	return report
+}
+
+// printDriftTable writes a table of the changed settings, and the tasks that failed, to w.
+func printDriftTable(w io.Writer, results []driftResult) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TASK\tSETTING\tCURRENT\tDESIRED")
+	for _, r := range results {
+		if r.Error != "" {
+			fmt.Fprintf(tw, "%s\t(error)\t%s\t\n", r.Task, r.Error)
+		}
+		for _, c := range r.Changes {
+			current := c.Current
+			if current == "" {
+				current = "(unset)"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Task, c.Setting, current, c.Desired)
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/internal/power"
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+func TestCheckDrift(t *testing.T) {
+	clean := &fakeTask{}
+	drifted := &fakeTask{changes: []task.Change{{Setting: "displaysleep", Current: "10", Desired: "0"}}}
+
+	report := checkDrift(context.Background(), []task.Task{clean})
+	assert.False(t, report.Drifted)
+	assert.Equal(t, []driftResult{{Task: "fake", Changes: []task.Change{}}}, report.Tasks, "changes should never be null in JSON")
+
+	report = checkDrift(context.Background(), []task.Task{clean, drifted})
+	assert.True(t, report.Drifted)
+	assert.Len(t, report.Tasks, 2)
+	assert.Equal(t, drifted.changes, report.Tasks[1].Changes)
+}
+
+func TestPrintDriftTable(t *testing.T) {
+	var buf bytes.Buffer
+	report := &driftReport{Drifted: true, Tasks: []driftResult{
+		{Task: "power", Changes: []task.Change{{Setting: "displaysleep", Current: "10", Desired: "0"}}},
+		{Task: "mtu", Changes: []task.Change{{Setting: "mtu.en0", Desired: "9001"}}},
+	}}
+
+	assert.NoError(t, printDriftTable(&buf, report.Tasks))
+	assert.Equal(t, "TASK   SETTING       CURRENT  DESIRED\n"+
+		"power  displaysleep  10       0\n"+
+		"mtu    mtu.en0       (unset)  9001\n", buf.String())
+}
+
+func TestPowerTask(t *testing.T) {
+	assert.Equal(t, power.ServerSettings, powerTask(nil).Desired)
+
+	desired := powerTask(map[string]string{"displaysleep": "15"}).Desired
+	assert.Equal(t, "15", desired["displaysleep"], "configured settings should take precedence")
+	assert.Equal(t, len(power.ServerSettings), len(desired))
+	assert.NotEqual(t, "15", power.ServerSettings["displaysleep"], "server settings shouldn't be modified")
+}
+
+func TestConfigTasks_Power(t *testing.T) {
+	tasks, err := configTasks(context.Background(), &config.Config{Users: []config.User{{Name: "runner"}}})
+	assert.NoError(t, err)
+	for _, tk := range tasks {
+		assert.NotEqual(t, "power", tk.Name(), "power settings shouldn't be managed without a power section")
+	}
+
+	tasks, err = configTasks(context.Background(), &config.Config{Power: map[string]string{}})
+	assert.NoError(t, err)
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, power.ServerSettings, tasks[0].(*power.Task).Desired, "an empty power section should apply the server settings")
+}
+
+func TestMountsTask(t *testing.T) {
+	tk := mountsTask(&mounts.Manager{}, []config.Mount{{Spec: "UUID=TEST", MountPoint: "/Volumes/Data"}})
+
+	assert.Equal(t, []mounts.FstabEntry{{Spec: "UUID=TEST", File: "/Volumes/Data", VfsType: "apfs"}}, tk.Entries)
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/escrow"
+	"github.com/aws/ec2-macos-utils/internal/imds"
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+)
+
+// unlockDefaultTimeout is the default maximum run duration for unlocking volumes, which includes waiting for the
+// instance metadata at boot.
+const unlockDefaultTimeout = 5 * time.Minute
+
+// newEscrow creates the escrow for the backend, which keeps passphrases with the instance's credentials under names
+// scoped to the instance, it's replaced in tests.
+var newEscrow = func(ctx context.Context, backend string) (escrow.Store, error) {
+	b, err := escrow.ParseBackend(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := imds.NewClient()
+	region, err := metadata.Region(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine region for escrow: %w", err)
+	}
+	instanceID, err := metadata.InstanceID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine instance ID for escrow: %w", err)
+	}
+
+	return escrow.New(b, aws.NewClient(region, metadata), instanceID)
+}
+
+// unlockVolumes is a struct for holding all information passed into the volume unlock command.
+type unlockVolumes struct {
+	dryrun  bool
+	escrow  string
+	persist bool
+	timeout time.Duration
+}
+
+// prepareEncryptedVolume prepares the encrypted data volume on the disk with diskutil.PrepareEncryptedVolume, with a
+// new random passphrase that's escrowed for blank disks.
+func prepareEncryptedVolume(ctx context.Context, utility diskutil.DiskUtil, id string, args provisionVolume) (*types.DiskInfo, error) {
+	store, err := newEscrow(ctx, args.escrow)
+	if err != nil {
+		return nil, err
+	}
+	passphrase, err := escrow.NewPassphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"device_id": id,
+		"escrow":    args.escrow,
+	}).Info("Preparing encrypted volume...")
+
+	return diskutil.PrepareEncryptedVolume(ctx, utility, id, args.label, passphrase, store)
+}
+
+// volumeUnlockCommand creates a new command which unlocks the encrypted data volumes with their escrowed passphrases.
+func volumeUnlockCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unlock",
+		Short: "unlock encrypted data volumes",
+		Long: strings.TrimSpace(`
+unlock unlocks the encrypted data volumes created by
+provision --encrypt, which are locked whenever the host boots,
+with their passphrases from escrow. Unlocked volumes are
+mounted at their mount point in /etc/fstab. Volumes without
+a passphrase in escrow (e.g. ones from another instance) are
+skipped. AWS is only called when there are locked volumes.
+With --persist, a launchd daemon is installed that unlocks
+the volumes at every boot.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	unlockArgs := unlockVolumes{}
+	cmd.PersistentFlags().StringVar(&unlockArgs.escrow, "escrow", string(escrow.BackendSecretsManager), "service the passphrases are escrowed in (secretsmanager or ssm)")
+	cmd.PersistentFlags().BoolVar(&unlockArgs.persist, "persist", false, "install a launchd daemon that unlocks the volumes at boot")
+	cmd.PersistentFlags().BoolVar(&unlockArgs.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&unlockArgs.timeout, "timeout", unlockDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Unlocking volumes with diskutil and installing launchd daemons requires root permissions and Full Disk Access.
+	cmd.PreRunE = assertDiskPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, unlockArgs.timeout, func(ctx context.Context) error {
+			backend, err := escrow.ParseBackend(unlockArgs.escrow)
+			if err != nil {
+				return err
+			}
+
+			product := contextual.Product(ctx)
+			if product == nil {
+				return errors.New("product required in context")
+			}
+
+			d, err := diskutil.ForProduct(product)
+			if err != nil {
+				return err
+			}
+			if unlockArgs.dryrun {
+				d = diskutil.Dryrun(d)
+			}
+
+			if unlockArgs.persist {
+				if err := persistVolumeUnlock(ctx, launchd.NewDaemonManager(), backend, unlockArgs.dryrun); err != nil {
+					return err
+				}
+			}
+
+			return runUnlock(ctx, d, unlockArgs)
+		})
+	}
+
+	// Keep other disk operations on the host from running alongside this one.
+	lockDiskOperation(cmd)
+
+	return cmd
+}
+
+// runUnlock unlocks the locked encrypted volumes with their passphrases from escrow. The escrow is only created once
+// locked volumes are found so that hosts without them don't call AWS. Every volume is attempted before failures are
+// returned.
+func runUnlock(ctx context.Context, utility diskutil.DiskUtil, args unlockVolumes) error {
+	locked, err := diskutil.LockedVolumes(ctx, utility)
+	if err != nil {
+		return err
+	}
+	if len(locked) == 0 {
+		logrus.Info("No locked volumes found, nothing to do")
+		return nil
+	}
+
+	store, err := newEscrow(ctx, args.escrow)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, volume := range locked {
+		err := diskutil.UnlockVolume(ctx, utility, volume.DeviceIdentifier, store)
+		if errors.Is(err, escrow.ErrNotFound) {
+			logrus.WithError(err).WithField("volume_id", volume.DeviceIdentifier).Warn("No escrowed passphrase for volume, skipping")
+		} else if err != nil {
+			logrus.WithError(err).WithField("volume_id", volume.DeviceIdentifier).Error("Failed to unlock volume")
+			failed = append(failed, volume.DeviceIdentifier)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to unlock volumes: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// persistVolumeUnlock installs the launchd job which unlocks the volumes at boot with this executable.
+func persistVolumeUnlock(ctx context.Context, m *launchd.Manager, backend escrow.Backend, dryrun bool) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot find executable: %w", err)
+	}
+	// Symlinks (e.g. from a package manager's bin directory) may move when upgraded, the job runs the target
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+
+	job := escrow.Job(executable, backend)
+	if dryrun {
+		logrus.WithFields(logrus.Fields{
+			"label":      job.Label,
+			"executable": executable,
+		}).Warn("Would have installed launchd job")
+		return nil
+	}
+
+	if _, err := m.Install(ctx, job); err != nil {
+		return fmt.Errorf("cannot install launchd job: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/escrow"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/diskutilfakes"
+)
+
+// memoryEscrow keeps passphrases in memory by the volume's UUID.
+type memoryEscrow map[string]string
+
+func (e memoryEscrow) Put(ctx context.Context, uuid string, passphrase string) error {
+	e[uuid] = passphrase
+
+	return nil
+}
+
+func (e memoryEscrow) Get(ctx context.Context, uuid string) (string, error) {
+	passphrase, ok := e[uuid]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", escrow.ErrNotFound, uuid)
+	}
+
+	return passphrase, nil
+}
+
+// useEscrow replaces the escrow for the test, counting how many times it's created.
+func useEscrow(t *testing.T, e escrow.Store) *int {
+	created := 0
+	original := newEscrow
+	newEscrow = func(ctx context.Context, backend string) (escrow.Store, error) {
+		created++
+		return e, nil
+	}
+	t.Cleanup(func() { newEscrow = original })
+
+	return &created
+}
+
+// fakeEncryptedDisk is an attached disk whose container, disk5, holds the locked volumes, each encrypted with
+// "secret" and given UUIDs in order.
+func fakeEncryptedDisk(volumes ...string) diskutilfakes.Disk {
+	container := &diskutilfakes.Container{ID: "disk5"}
+	for i, name := range volumes {
+		container.Volumes = append(container.Volumes, diskutilfakes.Volume{
+			Name:       name,
+			UUID:       fmt.Sprintf("00000000-0000-0000-0000-00000000000%d", i+1),
+			Passphrase: "secret",
+			Locked:     true,
+		})
+	}
+
+	return diskutilfakes.Disk{
+		ID:   "disk4",
+		Size: 500_000_000_000,
+		Partitions: []diskutilfakes.Partition{
+			{Content: "EFI", Size: 209_715_200, VolumeName: "EFI"},
+			{Content: "Apple_APFS", Size: 400_000_000_000, Container: container},
+		},
+	}
+}
+
+func TestRunUnlock(t *testing.T) {
+	e := memoryEscrow{"00000000-0000-0000-0000-000000000001": "secret"}
+	useEscrow(t, e)
+	fake := diskutilfakes.New(fakeBootDisk(), fakeEncryptedDisk("Data", "Other"))
+
+	err := runUnlock(context.Background(), fake, unlockVolumes{escrow: "secretsmanager"})
+
+	assert.NoError(t, err, "volumes without an escrowed passphrase should be skipped")
+	locked, err := diskutil.LockedVolumes(context.Background(), fake)
+	assert.NoError(t, err)
+	if assert.Len(t, locked, 1) {
+		assert.Equal(t, "Other", locked[0].VolumeName)
+	}
+}
+
+func TestRunUnlock_WrongPassphrase(t *testing.T) {
+	useEscrow(t, memoryEscrow{"00000000-0000-0000-0000-000000000001": "wrong"})
+	fake := diskutilfakes.New(fakeBootDisk(), fakeEncryptedDisk("Data"))
+
+	err := runUnlock(context.Background(), fake, unlockVolumes{escrow: "secretsmanager"})
+
+	assert.Error(t, err, "volumes that can't be unlocked should fail the command")
+}
+
+func TestRunUnlock_NothingLocked(t *testing.T) {
+	created := useEscrow(t, memoryEscrow{})
+	fake := diskutilfakes.New(fakeBootDisk())
+
+	assert.NoError(t, runUnlock(context.Background(), fake, unlockVolumes{escrow: "secretsmanager"}))
+	assert.Equal(t, 0, *created, "AWS shouldn't be called without locked volumes")
+}
+
+func TestRunProvision_EncryptWithoutAPFS(t *testing.T) {
+	fake := diskutilfakes.New(fakeBootDisk(), diskutilfakes.Disk{ID: "disk4", Size: 500_000_000_000})
+
+	err := runProvision(context.Background(), fake, nil, provisionVolume{
+		encrypt:    true,
+		format:     "ExFAT",
+		id:         "disk4",
+		label:      "DATA",
+		mountPoint: "/Volumes/Data",
+	})
+
+	assert.Error(t, err, "only APFS volumes can be encrypted")
+}
+
+func TestRunProvision_EncryptDryRun(t *testing.T) {
+	e := memoryEscrow{}
+	useEscrow(t, e)
+	fake := diskutilfakes.New(fakeBootDisk(), diskutilfakes.Disk{ID: "disk4", Size: 500_000_000_000})
+
+	err := runProvision(context.Background(), diskutil.Dryrun(fake), nil, provisionVolume{
+		dryrun:     true,
+		encrypt:    true,
+		escrow:     "secretsmanager",
+		format:     "APFS",
+		id:         "disk4",
+		label:      "Data",
+		mountPoint: "/Volumes/Data",
+		persist:    true,
+	})
+
+	assert.NoError(t, err, "should stop quietly before creating the volume")
+	assert.Empty(t, e, "nothing should be escrowed")
+	assert.Empty(t, fake.Disks()[1].Partitions)
+}
+
+func TestPrepareEncryptedVolume_EscrowUnavailable(t *testing.T) {
+	original := newEscrow
+	newEscrow = func(ctx context.Context, backend string) (escrow.Store, error) {
+		return nil, errors.New("no instance profile")
+	}
+	t.Cleanup(func() { newEscrow = original })
+	fake := diskutilfakes.New(fakeBootDisk(), diskutilfakes.Disk{ID: "disk4", Size: 500_000_000_000})
+
+	_, err := prepareEncryptedVolume(context.Background(), fake, "disk4", provisionVolume{escrow: "ssm", label: "Data"})
+
+	assert.Error(t, err)
+	assert.Empty(t, fake.Disks()[1].Partitions, "nothing should be created without an escrow")
+}
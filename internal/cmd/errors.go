@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/preflight"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// stderrExcerptLines is the number of lines at the end of a failed command's stderr that are kept in error
+// documents, which is where tools print the error that ended them.
+const stderrExcerptLines = 10
+
+// errorDocument is a command failure as it's reported with the JSON output format so that other tools can triage it
+// without matching messages.
+type errorDocument struct {
+	// Class is the category of the failure (e.g. "busy" or "permission-denied").
+	Class diskutil.ErrClass `json:"class"`
+	// Message is the error's message.
+	Message string `json:"message"`
+	// ExitCode is the code that the process exits with.
+	ExitCode int `json:"exit_code"`
+	// Command is the command run by the utility that failed, if any.
+	Command []string `json:"command,omitempty"`
+	// Stderr is the end of the failed command's standard error.
+	Stderr string `json:"stderr,omitempty"`
+	// Hint suggests how the failure can be fixed.
+	Hint string `json:"hint,omitempty"`
+}
+
+// classHints are the remediation hints for each class of failure.
+var classHints = map[diskutil.ErrClass]string{
+	diskutil.ClassBusy:              "The disk or volume is in use, retry once the processes using it have stopped.",
+	diskutil.ClassUnsupported:       "The operation isn't supported for the disk or this release of macOS.",
+	diskutil.ClassInsufficientSpace: "Free up space or increase the size of the EBS volume, then retry.",
+	diskutil.ClassPermissionDenied:  "Run the command as root (e.g. with sudo).",
+}
+
+// newErrorDocument creates the error document for the failure.
+func newErrorDocument(err error) errorDocument {
+	class := diskutil.Classify(err)
+	doc := errorDocument{
+		Class:    class,
+		Message:  err.Error(),
+		ExitCode: class.ExitCode(),
+		Hint:     classHints[class],
+	}
+
+	var permErr *preflight.PermissionError
+	if errors.As(err, &permErr) {
+		doc.Hint = fmt.Sprintf("Grant %s: %s.", permErr.Missing, permErr.Guidance)
+	}
+
+	var execErr *util.ExecError
+	var cmdErr *diskutil.CommandError
+	switch {
+	case errors.As(err, &execErr):
+		doc.Command = execErr.Command
+		doc.Stderr = stderrExcerpt(execErr.Stderr)
+	case errors.As(err, &cmdErr):
+		doc.Stderr = stderrExcerpt(cmdErr.Stderr)
+	}
+
+	return doc
+}
+
+// stderrExcerpt gets the last stderrExcerptLines lines of stderr.
+func stderrExcerpt(stderr string) string {
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	if len(lines) > stderrExcerptLines {
+		lines = lines[len(lines)-stderrExcerptLines:]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// ReportError writes the failure of the command to its stderr. It's written as an error document when the JSON
+// output format is selected, otherwise it's written as a line of text.
+func ReportError(cmd *cobra.Command, err error) {
+	w := cmd.ErrOrStderr()
+	if outputFormat(cmd) != outputJSON {
+		fmt.Fprintln(w, "Error:", err)
+		return
+	}
+
+	_ = printOutput(w, outputJSON, newErrorDocument(err), nil)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/preflight"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+func TestNewErrorDocument(t *testing.T) {
+	stderr := strings.Repeat("Unmounting...\n", 20) + "Error: -69888: Couldn't unmount disk\n"
+	err := fmt.Errorf("failed to erase disk: %w", &util.ExecError{
+		Command: []string{"diskutil", "eraseDisk", "APFS", "Data", "disk2"},
+		Stderr:  stderr,
+		Err:     errors.New("exit status 1"),
+	})
+	err = &diskutil.CommandError{Class: diskutil.ClassBusy, Stderr: stderr, Err: err}
+
+	doc := newErrorDocument(err)
+
+	assert.Equal(t, diskutil.ClassBusy, doc.Class)
+	assert.Equal(t, "failed to erase disk: exit status 1", doc.Message)
+	assert.Equal(t, 75, doc.ExitCode)
+	assert.Equal(t, []string{"diskutil", "eraseDisk", "APFS", "Data", "disk2"}, doc.Command)
+	assert.Equal(t, stderrExcerptLines, len(strings.Split(doc.Stderr, "\n")), "should only keep the end of stderr")
+	assert.True(t, strings.HasSuffix(doc.Stderr, "Error: -69888: Couldn't unmount disk"))
+	assert.NotEmpty(t, doc.Hint)
+}
+
+func TestNewErrorDocument_Unclassified(t *testing.T) {
+	doc := newErrorDocument(errors.New("no configuration"))
+
+	assert.Equal(t, errorDocument{Class: diskutil.ClassUnknown, Message: "no configuration", ExitCode: 1}, doc)
+}
+
+func TestNewErrorDocument_PermissionError(t *testing.T) {
+	doc := newErrorDocument(&preflight.PermissionError{Missing: "Full Disk Access", Guidance: "grant it in System Settings"})
+
+	assert.Equal(t, diskutil.ClassPermissionDenied, doc.Class)
+	assert.Equal(t, 77, doc.ExitCode)
+	assert.Equal(t, "Grant Full Disk Access: grant it in System Settings.", doc.Hint)
+}
+
+func TestReportError(t *testing.T) {
+	run := func(args ...string) string {
+		root := rootCommand()
+		root.AddCommand(&cobra.Command{
+			Use: "fail",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return &diskutil.CommandError{Class: diskutil.ClassPermissionDenied, Stderr: "Permission denied", Err: errors.New("exit status 1")}
+			},
+		})
+		var stderr bytes.Buffer
+		root.SetErr(&stderr)
+		root.SetArgs(args)
+
+		err := root.Execute()
+		assert.Error(t, err)
+		ReportError(root, err)
+
+		return stderr.String()
+	}
+
+	assert.Equal(t, "Error: exit status 1\n", run("fail"), "should report text by default")
+
+	var doc errorDocument
+	assert.NoError(t, json.Unmarshal([]byte(run("fail", "--output", "json")), &doc))
+	assert.Equal(t, diskutil.ClassPermissionDenied, doc.Class)
+	assert.Equal(t, "Permission denied", doc.Stderr)
+	assert.Equal(t, 77, doc.ExitCode)
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/firewall"
+)
+
+// firewallDefaultTimeout is the default maximum run duration for checking and applying firewall settings.
+const firewallDefaultTimeout = time.Minute
+
+// firewallSettings is a struct for holding all information passed into the firewall subcommands.
+type firewallSettings struct {
+	allowedApps []string
+	blockAll    bool
+	enabled     bool
+	stealthMode bool
+	timeout     time.Duration
+}
+
+// firewallCommand creates a new command which groups the Application Firewall subcommands.
+func firewallCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "firewall",
+		Short: "manage the Application Firewall",
+		Long: strings.TrimSpace(`
+firewall manages macOS's Application Firewall with
+socketfilterfw: whether it's enabled, stealth mode, blocking all
+incoming connections, and the applications allowed to accept
+incoming connections. The firewall is enabled unless configured
+otherwise while the other settings are only changed when
+configured. Rules for applications that aren't listed are left
+as-is.
+
+Settings are read from the firewall section of the configuration
+file and can be overridden with flags.
+`),
+	}
+
+	cmd.AddCommand(firewallCheckCommand(), firewallApplyCommand(), firewallStatusCommand())
+
+	return cmd
+}
+
+// addFirewallFlags adds the flags used to override the configured settings to the command.
+func addFirewallFlags(cmd *cobra.Command, args *firewallSettings) {
+	cmd.PersistentFlags().BoolVar(&args.enabled, "enabled", true, "enable the firewall")
+	cmd.PersistentFlags().BoolVar(&args.stealthMode, "stealth-mode", false, "enable stealth mode, which ignores probes on closed ports")
+	cmd.PersistentFlags().BoolVar(&args.blockAll, "block-all", false, "block all incoming connections")
+	cmd.PersistentFlags().StringSliceVar(&args.allowedApps, "allow-app", nil, "path to an application allowed to accept incoming connections, may be repeated")
+	cmd.PersistentFlags().DurationVar(&args.timeout, "timeout", firewallDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+}
+
+// firewallCheckCommand creates a new command which reports drift from the desired firewall settings.
+func firewallCheckCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "report drift from the desired firewall settings",
+		Args:  cobra.NoArgs,
+	}
+
+	firewallArgs := firewallSettings{}
+	addFirewallFlags(cmd, &firewallArgs)
+
+	// Reading the firewall's settings requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFirewall(cmd, firewallArgs, func(ctx context.Context, t *firewall.Task) error {
+			return checkTask(ctx, cmd, t)
+		})
+	}
+
+	return cmd
+}
+
+// firewallApplyCommand creates a new command which applies the desired firewall settings.
+func firewallApplyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "apply the desired firewall settings",
+		Args:  cobra.NoArgs,
+	}
+
+	firewallArgs := firewallSettings{}
+	addFirewallFlags(cmd, &firewallArgs)
+	var dryrun bool
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+
+	// Changing the firewall's settings requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFirewall(cmd, firewallArgs, func(ctx context.Context, t *firewall.Task) error {
+			return applyTask(ctx, cmd, t, dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// firewallStatusCommand creates a new command which reports the state of the firewall.
+func firewallStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "report the firewall's settings and application rules",
+		Args:  cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", firewallDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Reading the firewall's settings requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			status, err := firewall.GetStatus(ctx)
+			if err != nil {
+				return err
+			}
+			if status.Apps == nil {
+				status.Apps = []firewall.App{}
+			}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), status, func(w io.Writer) error {
+				return printFirewallStatus(w, status)
+			})
+		})
+	}
+
+	return cmd
+}
+
+// runFirewall builds the firewall task from the configuration and flags and runs it with fn.
+func runFirewall(cmd *cobra.Command, args firewallSettings, fn func(ctx context.Context, t *firewall.Task) error) error {
+	ctx := cmd.Context()
+	if args.timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, args.timeout)
+		defer cancel()
+	}
+
+	c, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(ctx, firewallTask(cmd, c.Firewall, args)); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return errors.New("timeout exceeded")
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// firewallTask merges the configured settings with the flags that were set to build the firewall task. Flags take
+// precedence over the configuration, which takes precedence over the flags' defaults.
+func firewallTask(cmd *cobra.Command, conf config.Firewall, args firewallSettings) *firewall.Task {
+	t := &firewall.Task{
+		Enabled:     &args.enabled,
+		StealthMode: conf.StealthMode,
+		BlockAll:    conf.BlockAll,
+		AllowedApps: conf.AllowedApps,
+	}
+	if !cmd.Flags().Changed("enabled") && conf.Enabled != nil {
+		t.Enabled = conf.Enabled
+	}
+	if cmd.Flags().Changed("stealth-mode") {
+		t.StealthMode = &args.stealthMode
+	}
+	if cmd.Flags().Changed("block-all") {
+		t.BlockAll = &args.blockAll
+	}
+	if cmd.Flags().Changed("allow-app") {
+		t.AllowedApps = args.allowedApps
+	}
+
+	return t
+}
+
+// printFirewallStatus writes the state of the firewall and a table of its application rules to w.
+func printFirewallStatus(w io.Writer, status *firewall.Status) error {
+	fmt.Fprintf(w, "Firewall: %s\n", firewall.OnOff(status.Enabled))
+	fmt.Fprintf(w, "Stealth mode: %s\n", firewall.OnOff(status.StealthMode))
+	fmt.Fprintf(w, "Block all: %s\n", firewall.OnOff(status.BlockAll))
+	if len(status.Apps) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "APP\tINCOMING")
+	for _, app := range status.Apps {
+		incoming := "blocked"
+		if app.Allowed {
+			incoming = "allowed"
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", app.Path, incoming)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/firewall"
+)
+
+func TestFirewallTask_Defaults(t *testing.T) {
+	task := firewallTask(firewallApplyCommand(), config.Firewall{}, firewallSettings{enabled: true})
+
+	assert.True(t, *task.Enabled)
+	assert.Nil(t, task.StealthMode, "stealth mode shouldn't be changed by default")
+	assert.Nil(t, task.BlockAll, "block all shouldn't be changed by default")
+	assert.Empty(t, task.AllowedApps)
+}
+
+func TestFirewallTask_FlagsOverrideConfig(t *testing.T) {
+	disabled := false
+	firewallArgs := firewallSettings{}
+	cmd := &cobra.Command{}
+	addFirewallFlags(cmd, &firewallArgs)
+	assert.NoError(t, cmd.ParseFlags([]string{"--stealth-mode", "--allow-app", "/usr/local/bin/node"}))
+
+	task := firewallTask(cmd, config.Firewall{
+		Enabled:     &disabled,
+		StealthMode: &disabled,
+		AllowedApps: []string{"/Applications/Xcode.app"},
+	}, firewallArgs)
+
+	assert.False(t, *task.Enabled, "config should override defaults")
+	assert.True(t, *task.StealthMode, "flags should override config")
+	assert.Equal(t, []string{"/usr/local/bin/node"}, task.AllowedApps)
+}
+
+func TestPrintFirewallStatus(t *testing.T) {
+	var buf bytes.Buffer
+	status := &firewall.Status{
+		Enabled: true,
+		Apps: []firewall.App{
+			{Path: "/Applications/Xcode.app", Allowed: true},
+		},
+	}
+
+	assert.NoError(t, printFirewallStatus(&buf, status))
+	assert.Equal(t, "Firewall: on\nStealth mode: off\nBlock all: off\n\n"+
+		"APP                      INCOMING\n"+
+		"/Applications/Xcode.app  allowed\n", buf.String())
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/fixture"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+)
+
+// fixturesDefaultTimeout is the default maximum run duration for recording fixtures.
+const fixturesDefaultTimeout = 5 * time.Minute
+
+// fixturesCommand creates a new hidden command which groups the test fixture subcommands. It's hidden since it's
+// only useful to people working on ec2-macos-utils itself.
+func fixturesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:    "fixtures",
+		Short:  "manage test fixtures",
+		Hidden: true,
+	}
+
+	cmd.AddCommand(fixturesRecordCommand())
+
+	return cmd
+}
+
+// fixturesRecordCommand creates a new command which records the output of the read-only diskutil commands.
+func fixturesRecordCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "record",
+		Short: "record diskutil output to fixture files",
+		Long: strings.TrimSpace(`
+record runs the read-only diskutil commands used by the tool
+(list, and info for the root volume and every disk) and writes
+their output to fixture files in a directory named for the
+running release (e.g. <dir>/ventura). The fixtures are replayed
+by the diskutil package's tests.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	var dir string
+	var timeout time.Duration
+	cmd.PersistentFlags().StringVar(&dir, "dir", "pkg/diskutil/testdata/fixtures", "directory to write the fixtures to")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", fixturesDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if timeout != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		product := contextual.Product(ctx)
+		if product == nil {
+			return errors.New("product required in context")
+		}
+
+		recorder := fixture.NewRecorder(dir, *product)
+		d, err := diskutil.ForProduct(product, diskutil.WithExecutor(recorder))
+		if err != nil {
+			return err
+		}
+
+		partitions, err := d.List(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("cannot record list: %w", err)
+		}
+		if _, err := d.Info(ctx, "/"); err != nil {
+			return fmt.Errorf("cannot record info for root: %w", err)
+		}
+		for _, id := range partitions.AllDisks {
+			if _, err := d.Info(ctx, id); err != nil {
+				return fmt.Errorf("cannot record info for %s: %w", id, err)
+			}
+		}
+
+		logrus.WithField("dir", recorder.Dir).Info("Fixtures recorded")
+
+		return nil
+	}
+
+	return cmd
+}
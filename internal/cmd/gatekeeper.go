@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/gatekeeper"
+)
+
+// gatekeeperDefaultTimeout is the default maximum run duration for Gatekeeper commands. Assessing large
+// applications (e.g. Xcode) verifies their whole signature so this is more generous than for other settings.
+const gatekeeperDefaultTimeout = 10 * time.Minute
+
+// gatekeeperCommand creates a new command which groups the Gatekeeper subcommands.
+func gatekeeperCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gatekeeper",
+		Short: "manage Gatekeeper assessments",
+		Long: strings.TrimSpace(`
+gatekeeper queries and adjusts Gatekeeper, which assesses
+software before it's run or installed, with spctl(8). Toolchains
+can be checked for being accepted, and notarized, before they're
+rolled out to a fleet. Disabling assessments must be confirmed in
+System Settings on macOS Sequoia and later, which isn't possible
+on a headless instance.
+`),
+	}
+
+	cmd.AddCommand(
+		gatekeeperStatusCommand(),
+		gatekeeperSetCommand("enable", true),
+		gatekeeperSetCommand("disable", false),
+		gatekeeperAssessCommand(),
+	)
+
+	return cmd
+}
+
+// gatekeeperStatusCommand creates a new command which reports whether Gatekeeper assessments are enabled.
+func gatekeeperStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "report whether Gatekeeper assessments are enabled",
+		Args:  cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", gatekeeperDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			enabled, err := gatekeeper.Enabled(ctx)
+			if err != nil {
+				return err
+			}
+			status := struct {
+				Enabled bool `json:"enabled"`
+			}{Enabled: enabled}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), status, func(w io.Writer) error {
+				state := "disabled"
+				if enabled {
+					state = "enabled"
+				}
+				_, err := fmt.Fprintf(w, "Gatekeeper: %s\n", state)
+				return err
+			})
+		})
+	}
+
+	return cmd
+}
+
+// gatekeeperSetCommand creates a new command, with the given name, which enables or disables Gatekeeper assessments.
+func gatekeeperSetCommand(name string, enabled bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   name,
+		Short: name + " Gatekeeper assessments",
+		Args:  cobra.NoArgs,
+	}
+
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", gatekeeperDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Changing the assessment policy requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			current, err := gatekeeper.Enabled(ctx)
+			if err != nil {
+				return err
+			}
+			if current == enabled {
+				logrus.WithField("enabled", enabled).Info("Gatekeeper already set, nothing to do")
+				return nil
+			}
+			if dryrun {
+				logrus.WithField("enabled", enabled).Warn("Would have changed Gatekeeper assessments")
+				return nil
+			}
+
+			if err := gatekeeper.SetEnabled(ctx, enabled); errors.Is(err, gatekeeper.ErrConfirmationRequired) {
+				return fmt.Errorf("cannot %s Gatekeeper on this version of macOS: %w", name, err)
+			} else if err != nil {
+				return err
+			}
+			logrus.WithField("enabled", enabled).Info("Successfully changed Gatekeeper assessments")
+
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+// gatekeeperAssessCommand creates a new command which assesses software with Gatekeeper.
+func gatekeeperAssessCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "assess <path>...",
+		Short: "check if Gatekeeper accepts software",
+		Long: strings.TrimSpace(`
+assess asks Gatekeeper whether each application, tool, or
+installer package would be allowed to run or be installed. The
+command fails when any of them is rejected or, with --notarized,
+when any of them isn't notarized by Apple.
+`),
+		Args: cobra.MinimumNArgs(1),
+	}
+
+	var notarized bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&notarized, "notarized", false, "require the software to be notarized")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", gatekeeperDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			var assessments []*gatekeeper.Assessment
+			for _, path := range args {
+				a, err := gatekeeper.Assess(ctx, path, gatekeeper.TypeForPath(path))
+				if err != nil {
+					return err
+				}
+				assessments = append(assessments, a)
+			}
+
+			if err := printOutput(cmd.OutOrStdout(), outputFormat(cmd), assessments, func(w io.Writer) error {
+				return printAssessments(w, assessments)
+			}); err != nil {
+				return err
+			}
+
+			return checkAssessments(assessments, notarized)
+		})
+	}
+
+	return cmd
+}
+
+// checkAssessments returns an error when any of the software was rejected or, when notarized is set, wasn't
+// notarized.
+func checkAssessments(assessments []*gatekeeper.Assessment, notarized bool) error {
+	var failed []string
+	for _, a := range assessments {
+		if !a.Accepted || (notarized && !a.Notarized) {
+			failed = append(failed, a.Path)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	if notarized {
+		return fmt.Errorf("software isn't notarized: %s", strings.Join(failed, ", "))
+	}
+
+	return fmt.Errorf("software was rejected: %s", strings.Join(failed, ", "))
+}
+
+// printAssessments writes a table of the assessments to w.
+func printAssessments(w io.Writer, assessments []*gatekeeper.Assessment) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tACCEPTED\tNOTARIZED\tSOURCE")
+	for _, a := range assessments {
+		fmt.Fprintf(tw, "%s\t%t\t%t\t%s\n", a.Path, a.Accepted, a.Notarized, a.Source)
+	}
+
+	return tw.Flush()
+}
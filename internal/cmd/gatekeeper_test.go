@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/gatekeeper"
+)
+
+func TestCheckAssessments(t *testing.T) {
+	apple := &gatekeeper.Assessment{Path: "/Applications/Xcode.app", Accepted: true, Source: "Apple Mac OS Application Signing"}
+	notarized := &gatekeeper.Assessment{Path: "/Applications/Tool.app", Accepted: true, Source: "Notarized Developer ID", Notarized: true}
+	rejected := &gatekeeper.Assessment{Path: "/usr/local/bin/tool", Source: "no usable signature"}
+
+	assert.NoError(t, checkAssessments([]*gatekeeper.Assessment{apple, notarized}, false))
+	assert.NoError(t, checkAssessments([]*gatekeeper.Assessment{notarized}, true))
+	assert.Error(t, checkAssessments([]*gatekeeper.Assessment{apple, rejected}, false), "rejected software should fail")
+	assert.Error(t, checkAssessments([]*gatekeeper.Assessment{apple}, true), "software that isn't notarized should fail")
+}
+
+func TestPrintAssessments(t *testing.T) {
+	var buf bytes.Buffer
+	assessments := []*gatekeeper.Assessment{
+		{Path: "/Applications/Tool.app", Accepted: true, Source: "Notarized Developer ID", Notarized: true},
+	}
+
+	assert.NoError(t, printAssessments(&buf, assessments))
+	assert.Equal(t, "PATH                    ACCEPTED  NOTARIZED  SOURCE\n"+
+		"/Applications/Tool.app  true      true       Notarized Developer ID\n", buf.String())
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
@@ -12,9 +13,10 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/aws/ec2-macos-utils/internal/contextual"
-	"github.com/aws/ec2-macos-utils/internal/diskutil"
-	"github.com/aws/ec2-macos-utils/internal/diskutil/identifier"
-	"github.com/aws/ec2-macos-utils/internal/diskutil/types"
+	"github.com/aws/ec2-macos-utils/internal/spotlight"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/identifier"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
 )
 
 // growDefaultTimeout is the default maximum run duration of 5 minutes. This time limit should be sufficiently long
@@ -22,11 +24,18 @@ import (
 // as unresponsive and the process will be terminated. This default time limit can be overridden with a flag.
 const growDefaultTimeout = 5 * time.Minute
 
+// interruptedReportTimeout is the maximum duration spent fetching the container's state after growing it was
+// interrupted or timed out.
+const interruptedReportTimeout = 30 * time.Second
+
 // growContainer is a struct for holding all information passed into the grow container command.
 type growContainer struct {
-	dryrun  bool
-	id      string
-	timeout time.Duration
+	disableSpotlight bool
+	dryrun           bool
+	id               string
+	plan             bool
+	timeout          time.Duration
+	unmountBusy      bool
 }
 
 // growContainerCommand creates a new command which grows APFS containers to their maximum size.
@@ -39,25 +48,44 @@ grow resizes the container to its maximum size using
 'diskutil'. The container to operate on can be specified
 with its identifier (e.g. disk1 or /dev/disk1). The string
 'root' may be provided to resize the OS's root volume.
+
+APFS volumes (e.g. disk1s5, a label, or a mount point) are
+resolved to the container that holds them, which is what's
+resized.
+
+Resizing fails while the disk is in use, in which case the
+error lists the processes that have the disk open and the
+volumes mounted from it. With --unmount-busy, the mounted
+volumes are unmounted before resizing.
+
+With --plan, the changes that growing would make (e.g.
+repairing the disk and resizing the container) are printed
+with the reason for each, and nothing is changed.
 		`),
+		Annotations: map[string]string{publishesEventsAnnotation: "true"},
 	}
 
 	// Set up the flags to be passed into the command
 	growArgs := growContainer{}
-	cmd.PersistentFlags().StringVar(&growArgs.id, "id", "", `container identifier to be resized or "root"`)
+	cmd.PersistentFlags().StringVar(&growArgs.id, "id", "", `container or volume to be resized or "root"`)
+	cmd.PersistentFlags().BoolVar(&growArgs.disableSpotlight, "disable-spotlight", false, "disable Spotlight indexing of the container's volumes after resizing")
 	cmd.PersistentFlags().BoolVar(&growArgs.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().BoolVar(&growArgs.plan, "plan", false, "print the changes that would be made to the disk without making them")
+	cmd.PersistentFlags().BoolVar(&growArgs.unmountBusy, "unmount-busy", false, "unmount the volumes using the disk (other than the system's) before resizing")
 	cmd.PersistentFlags().DurationVar(&growArgs.timeout, "timeout", growDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
 	cmd.MarkPersistentFlagRequired("id")
 
-	// Set up the command's pre-run to check for root permissions.
+	// Set up the command's pre-run to check for root permissions and Full Disk Access.
 	// This is necessary since diskutil repairDisk requires root permissions to run.
-	cmd.PreRunE = assertRootPrivileges
+	cmd.PreRunE = assertDiskPrivileges
 
 	// Set up the command's run function
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 		if growArgs.timeout != 0 {
-			ctx, _ = context.WithTimeout(ctx, growArgs.timeout)
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, growArgs.timeout)
+			defer cancel()
 		}
 
 		product := contextual.Product(ctx)
@@ -75,6 +103,10 @@ with its identifier (e.g. disk1 or /dev/disk1). The string
 			d = diskutil.Dryrun(d)
 		}
 
+		if growArgs.plan {
+			return runGrowPlan(ctx, cmd.OutOrStdout(), outputFormat(cmd), d, growArgs)
+		}
+
 		logrus.WithField("args", growArgs).Debug("Running grow command with args")
 		if err := run(ctx, d, growArgs); err != nil {
 			if ctx.Err() == context.DeadlineExceeded {
@@ -87,9 +119,50 @@ with its identifier (e.g. disk1 or /dev/disk1). The string
 		return nil
 	}
 
+	// Keep other disk operations on the host from running alongside this one.
+	lockDiskOperation(cmd)
+
 	return cmd
 }
 
+// growOptions gets the diskutil.GrowOptions for the arguments.
+func (args growContainer) growOptions() []diskutil.GrowOption {
+	var opts []diskutil.GrowOption
+	if args.unmountBusy {
+		opts = append(opts, diskutil.WithUnmountBusy())
+	}
+
+	return opts
+}
+
+// runGrowPlan prints the diskutil.Plan for growing the container without applying it.
+func runGrowPlan(ctx context.Context, w io.Writer, format string, utility diskutil.DiskUtil, args growContainer) error {
+	plan, err := planGrow(ctx, utility, args)
+	if err != nil {
+		return err
+	}
+
+	return printOutput(w, format, plan, func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, plan)
+		return err
+	})
+}
+
+// planGrow resolves the container and computes the diskutil.Plan for growing it.
+func planGrow(ctx context.Context, utility diskutil.DiskUtil, args growContainer) (*diskutil.Plan, error) {
+	di, err := getTargetDiskInfo(ctx, utility, args.id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot plan growing container: %w", err)
+	}
+
+	plan, err := diskutil.PlanGrow(ctx, utility, di, args.growOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot plan growing container: %w", err)
+	}
+
+	return plan, nil
+}
+
 // run attempts to grow the disk for the specified device identifier to its maximum size using diskutil.GrowContainer.
 func run(ctx context.Context, utility diskutil.DiskUtil, args growContainer) error {
 	di, err := getTargetDiskInfo(ctx, utility, args.id)
@@ -97,13 +170,22 @@ func run(ctx context.Context, utility diskutil.DiskUtil, args growContainer) err
 		return fmt.Errorf("cannot grow container: %w", err)
 	}
 
+	// Snapshot the partitions to report what growing changed, failing to isn't fatal since it's only for reporting
+	before, err := utility.List(ctx, nil)
+	if err != nil {
+		logrus.WithError(err).Warn("Unable to list partitions before growing, changes won't be reported")
+	}
+
 	logrus.WithField("device_id", di.DeviceIdentifier).Info("Attempting to grow container...")
-	if err := diskutil.GrowContainer(ctx, utility, di); err != nil {
+	if err := diskutil.GrowContainer(ctx, utility, di, args.growOptions()...); err != nil {
 		// Don't treat FreeSpaceErrors as fatal, instead exit quietly since there's nothing else to do.
 		if errors.As(err, &diskutil.FreeSpaceError{}) {
 			logrus.WithField("id", args.id).Info("Nothing to do without free space, stopping command")
 			return nil
 		}
+		if ctx.Err() != nil {
+			reportInterruptedGrow(utility, di, before)
+		}
 
 		return err
 	}
@@ -119,17 +201,143 @@ func run(ctx context.Context, utility diskutil.DiskUtil, args growContainer) err
 		"total_size": humanize.Bytes(updatedDi.TotalSize),
 	}).Info("Successfully grew device to maximum size")
 
+	if before != nil {
+		if after, err := utility.List(ctx, nil); err != nil {
+			logrus.WithError(err).Warn("Unable to list partitions after growing, changes won't be reported")
+		} else {
+			reportChanges(types.Diff(before, after))
+		}
+	}
+
+	if args.disableSpotlight {
+		if err := disableContainerSpotlight(ctx, utility, updatedDi.DeviceIdentifier, args.dryrun); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reportInterruptedGrow logs the container's current size and the changes made to the disks before growing it
+// was interrupted, so that callers know whether it has to be run again. The state is fetched with a new context
+// since the operation's context is already done.
+func reportInterruptedGrow(utility diskutil.DiskUtil, container *types.DiskInfo, before *types.SystemPartitions) {
+	ctx, cancel := context.WithTimeout(context.Background(), interruptedReportTimeout)
+	defer cancel()
+
+	current, err := utility.Info(ctx, container.DeviceIdentifier)
+	if err != nil {
+		logrus.WithError(err).Warn("Unable to fetch the container's state after growing was interrupted")
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"device_id":   container.DeviceIdentifier,
+		"size_before": humanize.Bytes(container.TotalSize),
+		"size_now":    humanize.Bytes(current.TotalSize),
+		"resized":     current.TotalSize != container.TotalSize,
+	}).Warn("Growing was interrupted, run grow again to make sure the container is at its maximum size")
+
+	if before == nil {
+		return
+	}
+	if after, err := utility.List(ctx, nil); err != nil {
+		logrus.WithError(err).Warn("Unable to list partitions after growing was interrupted, changes won't be reported")
+	} else {
+		reportChanges(types.Diff(before, after))
+	}
+}
+
+// reportChanges logs the changes made to the disks.
+func reportChanges(changes types.Changes) {
+	for _, c := range changes.Resized {
+		logrus.WithFields(logrus.Fields{
+			"device_id": c.DeviceIdentifier,
+			"before":    humanize.Bytes(c.Before),
+			"after":     humanize.Bytes(c.After),
+		}).Info("Device resized")
+	}
+	for _, c := range changes.Remounted {
+		logrus.WithFields(logrus.Fields{
+			"device_id": c.DeviceIdentifier,
+			"before":    c.Before,
+			"after":     c.After,
+		}).Info("Device remounted")
+	}
+	for _, id := range changes.Added {
+		logrus.WithField("device_id", id).Info("Device added")
+	}
+	for _, id := range changes.Removed {
+		logrus.WithField("device_id", id).Info("Device removed")
+	}
+}
+
+// disableContainerSpotlight disables Spotlight indexing for the mounted volumes in the APFS container. Reindexing
+// after a large resize competes with builds for disk I/O until it completes.
+func disableContainerSpotlight(ctx context.Context, utility diskutil.DiskUtil, containerID string, dryrun bool) error {
+	partitions, err := utility.List(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot list partitions: %w", err)
+	}
+
+	for _, mountPoint := range containerMountPoints(partitions, containerID) {
+		if err := disableSpotlight(ctx, mountPoint, dryrun); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// containerMountPoints finds the mount points of the mounted volumes in the APFS container, skipping the volumes used
+// internally by macOS (e.g. Preboot and VM).
+func containerMountPoints(partitions *types.SystemPartitions, containerID string) []string {
+	var mountPoints []string
+	for _, part := range partitions.AllDisksAndPartitions {
+		if !strings.EqualFold(part.DeviceIdentifier, containerID) {
+			continue
+		}
+		for _, volume := range part.APFSVolumes {
+			if volume.MountPoint != "" && !volume.OSInternal {
+				mountPoints = append(mountPoints, volume.MountPoint)
+			}
+		}
+	}
+
+	return mountPoints
+}
+
+// disableSpotlight disables Spotlight indexing for the volume mounted at mountPoint.
+func disableSpotlight(ctx context.Context, mountPoint string, dryrun bool) error {
+	if dryrun {
+		logrus.WithField("mount_point", mountPoint).Warn("Would have disabled Spotlight indexing")
+		return nil
+	}
+
+	changed, err := spotlight.DisableIndexing(ctx, mountPoint)
+	if err != nil {
+		return fmt.Errorf("cannot disable Spotlight indexing: %w", err)
+	}
+	logrus.WithFields(logrus.Fields{
+		"mount_point": mountPoint,
+		"changed":     changed,
+	}).Info("Spotlight indexing disabled")
+
 	return nil
 }
 
 // getTargetDiskInfo retrieves the disk info for the specified target identifier. If the identifier is "root", simply
-// return the disk information for "/". Otherwise, check if the identifier exists in the system partitions before
-// returning the disk information.
+// return the disk information for "/". Otherwise, resolve the target with diskutil.Resolve and check if it exists in
+// the system partitions before returning the disk information.
 func getTargetDiskInfo(ctx context.Context, du diskutil.DiskUtil, target string) (*types.DiskInfo, error) {
 	if strings.EqualFold("root", target) {
 		return du.Info(ctx, "/")
 	}
 
+	target, err := diskutil.Resolve(ctx, du, target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target: %w", err)
+	}
+
 	partitions, err := du.List(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("cannot list partitions: %w", err)
@@ -6,8 +6,9 @@ import (
 	"io/ioutil"
 	"testing"
 
-	mock_diskutil "github.com/aws/ec2-macos-utils/internal/diskutil/mocks"
-	"github.com/aws/ec2-macos-utils/internal/diskutil/types"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/diskutilfakes"
+	mock_diskutil "github.com/aws/ec2-macos-utils/pkg/diskutil/mocks"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
 
 	"github.com/golang/mock/gomock"
 	"github.com/sirupsen/logrus"
@@ -53,7 +54,10 @@ func TestRun_WithoutDiskInfo(t *testing.T) {
 	}
 
 	mock := mock_diskutil.NewMockDiskUtil(ctrl)
-	mock.EXPECT().Info(ctx, testDiskAlias).Return(&disk, nil)
+	gomock.InOrder(
+		mock.EXPECT().Info(ctx, testDiskAlias).Return(&disk, nil),
+		mock.EXPECT().List(ctx, nil).Return(nil, fmt.Errorf("error")),
+	)
 
 	err := run(ctx, mock, growContainer{
 		id: testDiskID,
@@ -103,6 +107,7 @@ func TestRun_WithoutFreeSpace(t *testing.T) {
 	gomock.InOrder(
 		mock.EXPECT().List(ctx, nil).Return(&parts, nil),
 		mock.EXPECT().Info(ctx, testDiskID).Return(&disk, nil),
+		mock.EXPECT().List(ctx, nil).Return(&parts, nil),
 		mock.EXPECT().RepairDisk(ctx, testDiskID).Return("", nil),
 		mock.EXPECT().List(ctx, nil).Return(&parts, nil),
 	)
@@ -155,6 +160,7 @@ func TestRun_WithUpdatedInfoErr(t *testing.T) {
 	gomock.InOrder(
 		mock.EXPECT().List(ctx, nil).Return(&parts, nil),
 		mock.EXPECT().Info(ctx, testDiskID).Return(&disk, nil),
+		mock.EXPECT().List(ctx, nil).Return(&parts, nil),
 		mock.EXPECT().RepairDisk(ctx, testDiskID).Return("", nil),
 		mock.EXPECT().List(ctx, nil).Return(&parts, nil),
 		mock.EXPECT().ResizeContainer(ctx, testDiskID, "0").Return("", nil),
@@ -210,11 +216,13 @@ func TestRun_Success(t *testing.T) {
 	gomock.InOrder(
 		mock.EXPECT().List(ctx, nil).Return(&parts, nil),
 		mock.EXPECT().Info(ctx, testDiskID).Return(&disk, nil),
+		mock.EXPECT().List(ctx, nil).Return(&parts, nil),
 		mock.EXPECT().RepairDisk(ctx, testDiskID).Return("", nil),
 		mock.EXPECT().List(ctx, nil).Return(&parts, nil),
 		mock.EXPECT().ResizeContainer(ctx, testDiskID, "0").Return("", nil),
 		mock.EXPECT().List(ctx, nil).Return(&parts, nil),
 		mock.EXPECT().Info(ctx, testDiskID).Return(&disk, nil),
+		mock.EXPECT().List(ctx, nil).Return(&parts, nil),
 	)
 
 	err := run(ctx, mock, growContainer{
@@ -224,6 +232,82 @@ func TestRun_Success(t *testing.T) {
 	assert.NoError(t, err, "should be able to grow container with valid data")
 }
 
+func TestRun_Interrupted(t *testing.T) {
+	const (
+		testDiskID        = "disk1"
+		diskSize   uint64 = 3_000_000
+		partSize   uint64 = 500_000
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	parts := types.SystemPartitions{
+		AllDisks: []string{testDiskID},
+		AllDisksAndPartitions: []types.DiskPart{
+			{
+				DeviceIdentifier: testDiskID,
+				Size:             diskSize,
+				Partitions: []types.Partition{
+					{Size: partSize},
+					{Size: partSize},
+				},
+			},
+		},
+	}
+
+	disk := types.DiskInfo{
+		APFSPhysicalStores: []types.APFSPhysicalStore{
+			{DeviceIdentifier: testDiskID},
+		},
+		ContainerInfo: types.ContainerInfo{
+			FilesystemType: "apfs",
+		},
+		DeviceIdentifier:  testDiskID,
+		ParentWholeDisk:   testDiskID,
+		TotalSize:         diskSize,
+		VirtualOrPhysical: "Physical",
+	}
+
+	mock := mock_diskutil.NewMockDiskUtil(ctrl)
+	gomock.InOrder(
+		mock.EXPECT().List(ctx, nil).Return(&parts, nil),
+		mock.EXPECT().Info(ctx, testDiskID).Return(&disk, nil),
+		mock.EXPECT().List(ctx, nil).Return(&parts, nil),
+		mock.EXPECT().RepairDisk(ctx, testDiskID).Return("", nil),
+		mock.EXPECT().List(ctx, nil).Return(&parts, nil),
+		mock.EXPECT().ResizeContainer(ctx, testDiskID, "0").DoAndReturn(func(context.Context, string, string) (string, error) {
+			cancel()
+			return "", fmt.Errorf("signal: terminated")
+		}),
+		// The container's state is fetched with a new context since the interrupted one is done
+		mock.EXPECT().Info(gomock.Not(ctx), testDiskID).Return(&disk, nil),
+		mock.EXPECT().List(gomock.Not(ctx), nil).Return(&parts, nil),
+	)
+
+	err := run(ctx, mock, growContainer{
+		id: testDiskID,
+	})
+
+	assert.Error(t, err, "should fail when interrupted")
+}
+
+func TestRun_Volume(t *testing.T) {
+	ctx := context.Background()
+	fake := diskutilfakes.New(fakeBootDisk())
+
+	err := run(ctx, fake, growContainer{id: "Macintosh HD"})
+
+	assert.NoError(t, err)
+	assert.Contains(t, fake.Calls(), diskutilfakes.Call{Method: "ResizeContainer", Args: []string{"disk3", "0"}},
+		"the volume's container should be resized")
+	container, err := fake.Info(ctx, "disk3")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(150_000_000_000-209_715_200), container.APFSContainerSize)
+}
+
 func TestGetTargetDiskInfo_WithRootInfoErr(t *testing.T) {
 	const testDiskID = "root"
 	var ctx = context.Background()
@@ -395,3 +479,28 @@ func TestValidateDeviceID(t *testing.T) {
 		})
 	}
 }
+
+func TestContainerMountPoints(t *testing.T) {
+	partitions := types.SystemPartitions{
+		AllDisksAndPartitions: []types.DiskPart{
+			{
+				DeviceIdentifier: "disk1",
+				APFSVolumes: []types.APFSVolume{
+					{DeviceIdentifier: "disk1s1", MountPoint: "/System/Volumes/Data"},
+					{DeviceIdentifier: "disk1s2", MountPoint: "/System/Volumes/Preboot", OSInternal: true},
+					{DeviceIdentifier: "disk1s3"},
+				},
+			},
+			{
+				DeviceIdentifier: "disk3",
+				APFSVolumes: []types.APFSVolume{
+					{DeviceIdentifier: "disk3s1", MountPoint: "/Volumes/Data"},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, []string{"/System/Volumes/Data"}, containerMountPoints(&partitions, "disk1"))
+	assert.Equal(t, []string{"/Volumes/Data"}, containerMountPoints(&partitions, "DISK3"))
+	assert.Empty(t, containerMountPoints(&partitions, "disk4"))
+}
@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/hdiutil"
+)
+
+// imageDefaultTimeout is the default maximum run duration for managing disk images.
+const imageDefaultTimeout = 5 * time.Minute
+
+// createImage is a struct for holding all information passed into the image create command.
+type createImage struct {
+	dryrun     bool
+	filesystem string
+	imageType  string
+	size       string
+	volumeName string
+}
+
+// imageCommand creates a new command which groups the disk image subcommands.
+func imageCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "image",
+		Short: "manage disk images",
+		Long: strings.TrimSpace(`
+image creates, attaches, detaches, and resizes disk images
+with hdiutil(1). Sparse bundles only use as much space as the
+data written to them, which makes them well suited as
+disposable per-job workspaces on build hosts.
+`),
+	}
+
+	cmd.AddCommand(imageCreateCommand(), imageAttachCommand(), imageDetachCommand(), imageResizeCommand(), imageListCommand())
+
+	return cmd
+}
+
+// imageCreateCommand creates a new command which creates a disk image.
+func imageCreateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create <path>",
+		Short: "create a disk image",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	createArgs := createImage{}
+	var timeout time.Duration
+	cmd.PersistentFlags().StringVar(&createArgs.size, "size", "", "maximum size of the image (e.g. 100g)")
+	cmd.PersistentFlags().StringVar(&createArgs.imageType, "type", string(hdiutil.TypeSparseBundle), "format of the image (SPARSEBUNDLE, SPARSE, or UDIF)")
+	cmd.PersistentFlags().StringVar(&createArgs.filesystem, "fs", "APFS", "filesystem of the image's volume")
+	cmd.PersistentFlags().StringVar(&createArgs.volumeName, "volume-name", "", "name of the image's volume")
+	cmd.PersistentFlags().BoolVar(&createArgs.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", imageDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+	cmd.MarkPersistentFlagRequired("size")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			return runImageCreate(ctx, &hdiutil.HDIUtilCmd{}, args[0], createArgs)
+		})
+	}
+
+	return cmd
+}
+
+// runImageCreate creates the disk image at path.
+func runImageCreate(ctx context.Context, h hdiutil.HDIUtil, path string, args createImage) error {
+	imageType, err := hdiutil.ParseImageType(args.imageType)
+	if err != nil {
+		return err
+	}
+	opts := hdiutil.CreateOptions{
+		Size:       args.size,
+		Type:       imageType,
+		Filesystem: args.filesystem,
+		VolumeName: args.volumeName,
+	}
+
+	if args.dryrun {
+		logrus.WithFields(logrus.Fields{
+			"path": path,
+			"size": opts.Size,
+			"type": opts.Type,
+		}).Warn("Would have created image")
+		return nil
+	}
+
+	created, err := h.Create(ctx, path, opts)
+	if err != nil {
+		return err
+	}
+	logrus.WithField("path", created).Info("Image created")
+
+	return nil
+}
+
+// imageAttachCommand creates a new command which attaches a disk image.
+func imageAttachCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "attach <path>",
+		Short: "attach a disk image",
+		Long: strings.TrimSpace(`
+attach attaches a disk image and mounts its volume, either in
+/Volumes or at the given mount point. The image's device and
+mount point are printed so they can be used to detach it.
+`),
+		Args: cobra.ExactArgs(1),
+	}
+
+	opts := hdiutil.AttachOptions{}
+	var timeout time.Duration
+	cmd.PersistentFlags().StringVar(&opts.MountPoint, "mount-point", "", "path to mount the image's volume at")
+	cmd.PersistentFlags().BoolVar(&opts.ReadOnly, "readonly", false, "attach the image without allowing writes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", imageDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			attachment, err := (&hdiutil.HDIUtilCmd{}).Attach(ctx, args[0], opts)
+			if err != nil {
+				return err
+			}
+
+			return printAttachment(cmd.OutOrStdout(), outputFormat(cmd), attachment)
+		})
+	}
+
+	return cmd
+}
+
+// imageDetachCommand creates a new command which detaches a disk image.
+func imageDetachCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "detach <device or mount point>",
+		Short: "detach a disk image",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	var dryrun, force bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&force, "force", false, "detach the image even if its volume is in use")
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", imageDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if dryrun {
+			logrus.WithField("device", args[0]).Warn("Would have detached image")
+			return nil
+		}
+
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			if err := (&hdiutil.HDIUtilCmd{}).Detach(ctx, args[0], force); err != nil {
+				return err
+			}
+			logrus.WithField("device", args[0]).Info("Image detached")
+
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+// imageResizeCommand creates a new command which resizes a disk image.
+func imageResizeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "resize <path>",
+		Short: "resize a disk image",
+		Long: strings.TrimSpace(`
+resize changes the maximum size of a disk image and grows (or
+shrinks) its volume to match. The image must be detached.
+`),
+		Args: cobra.ExactArgs(1),
+	}
+
+	var dryrun bool
+	var size string
+	var timeout time.Duration
+	cmd.PersistentFlags().StringVar(&size, "size", "", "new maximum size of the image (e.g. 200g)")
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", imageDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+	cmd.MarkPersistentFlagRequired("size")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			return runImageResize(ctx, &hdiutil.HDIUtilCmd{}, args[0], size, dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// runImageResize resizes the disk image at path, refusing to resize images that are attached.
+func runImageResize(ctx context.Context, h hdiutil.HDIUtil, path string, size string, dryrun bool) error {
+	images, err := h.Info(ctx)
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		if strings.TrimSuffix(image.Path, "/") == strings.TrimSuffix(path, "/") {
+			return fmt.Errorf("image [%s] is attached, detach it before resizing", path)
+		}
+	}
+
+	if dryrun {
+		logrus.WithFields(logrus.Fields{
+			"path": path,
+			"size": size,
+		}).Warn("Would have resized image")
+		return nil
+	}
+
+	if err := h.Resize(ctx, path, size); err != nil {
+		return err
+	}
+	logrus.WithFields(logrus.Fields{
+		"path": path,
+		"size": size,
+	}).Info("Image resized")
+
+	return nil
+}
+
+// imageListCommand creates a new command which lists the attached disk images.
+func imageListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list attached disk images",
+		Args:  cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", imageDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			images, err := (&hdiutil.HDIUtilCmd{}).Info(ctx)
+			if err != nil {
+				return err
+			}
+
+			return printImages(cmd.OutOrStdout(), outputFormat(cmd), images)
+		})
+	}
+
+	return cmd
+}
+
+// printAttachment writes the device and mount point of the attached image to w.
+func printAttachment(w io.Writer, format string, attachment *hdiutil.Attachment) error {
+	result := struct {
+		Device     string           `json:"device"`
+		MountPoint string           `json:"mountPoint"`
+		Entities   []hdiutil.Entity `json:"entities"`
+	}{Device: attachment.Device(), MountPoint: attachment.MountPoint(), Entities: attachment.Entities}
+	if result.Entities == nil {
+		result.Entities = []hdiutil.Entity{}
+	}
+
+	return printOutput(w, format, result, func(w io.Writer) error {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "DEVICE\tMOUNT POINT")
+		fmt.Fprintf(tw, "%s\t%s\n", result.Device, result.MountPoint)
+
+		return tw.Flush()
+	})
+}
+
+// printImages writes a table of the attached images and their mount points to w.
+func printImages(w io.Writer, format string, images []hdiutil.Image) error {
+	if images == nil {
+		images = []hdiutil.Image{}
+	}
+
+	return printOutput(w, format, images, func(w io.Writer) error {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "IMAGE\tDEVICE\tMOUNT POINT")
+		for _, image := range images {
+			attachment := hdiutil.Attachment{Entities: image.Entities}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", image.Path, attachment.Device(), attachment.MountPoint())
+		}
+
+		return tw.Flush()
+	})
+}
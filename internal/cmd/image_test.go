@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/hdiutil"
+	mock_hdiutil "github.com/aws/ec2-macos-utils/internal/hdiutil/mocks"
+)
+
+func TestRunImageCreate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_hdiutil.NewMockHDIUtil(ctrl)
+	mock.EXPECT().Create(gomock.Any(), "/tmp/job-42", hdiutil.CreateOptions{
+		Size:       "100g",
+		Type:       hdiutil.TypeSparseBundle,
+		Filesystem: "APFS",
+		VolumeName: "Workspace",
+	}).Return("/tmp/job-42.sparsebundle", nil)
+
+	err := runImageCreate(context.Background(), mock, "/tmp/job-42", createImage{
+		filesystem: "APFS",
+		imageType:  "sparsebundle",
+		size:       "100g",
+		volumeName: "Workspace",
+	})
+
+	assert.NoError(t, err)
+}
+
+func TestRunImageCreate_WithInvalidType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_hdiutil.NewMockHDIUtil(ctrl)
+
+	err := runImageCreate(context.Background(), mock, "/tmp/job-42", createImage{imageType: "iso", size: "100g"})
+
+	assert.Error(t, err, "should fail with unsupported image type")
+}
+
+func TestRunImageResize_WithAttachedImage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_hdiutil.NewMockHDIUtil(ctrl)
+	mock.EXPECT().Info(gomock.Any()).Return([]hdiutil.Image{{Path: "/tmp/job-42.sparsebundle"}}, nil)
+
+	err := runImageResize(context.Background(), mock, "/tmp/job-42.sparsebundle/", "200g", false)
+
+	assert.Error(t, err, "should refuse to resize attached images")
+}
+
+func TestRunImageResize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_hdiutil.NewMockHDIUtil(ctrl)
+	mock.EXPECT().Info(gomock.Any()).Return(nil, nil)
+	mock.EXPECT().Resize(gomock.Any(), "/tmp/job-42.sparsebundle", "200g").Return(nil)
+
+	err := runImageResize(context.Background(), mock, "/tmp/job-42.sparsebundle", "200g", false)
+
+	assert.NoError(t, err)
+}
+
+func TestPrintImages(t *testing.T) {
+	images := []hdiutil.Image{
+		{
+			Path: "/tmp/job-42.sparsebundle",
+			Entities: []hdiutil.Entity{
+				{DevEntry: "/dev/disk4"},
+				{DevEntry: "/dev/disk5s1", MountPoint: "/Volumes/Workspace"},
+			},
+		},
+	}
+	expected := "IMAGE                     DEVICE      MOUNT POINT\n" +
+		"/tmp/job-42.sparsebundle  /dev/disk4  /Volumes/Workspace\n"
+
+	var buf bytes.Buffer
+	err := printImages(&buf, outputText, images)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, buf.String())
+}
@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/journal"
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+)
+
+// journalDir is the directory of the operation journal, it's replaced in tests.
+var journalDir = journal.DefaultDir
+
+// operationProvision is the journal's name for the volume provision operation.
+const operationProvision = "provision"
+
+const (
+	// stepMountPoint is the provisioning step that creates the mount point.
+	stepMountPoint = "create mount point"
+	// stepEncrypt is the provisioning step that creates or unlocks the encrypted volume and escrows its passphrase.
+	stepEncrypt = "prepare encrypted volume"
+	// stepProvision is the provisioning step that erases and mounts the disk.
+	stepProvision = "provision volume"
+	// stepSpotlight is the provisioning step that disables Spotlight indexing of the volume.
+	stepSpotlight = "disable spotlight"
+	// stepPersist is the provisioning step that persists the mount in /etc/fstab.
+	stepPersist = "persist mount"
+)
+
+// journalCommand creates a new command which groups the operation journal subcommands.
+func journalCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "journal",
+		Short: "report and resume interrupted operations",
+		Long: strings.TrimSpace(`
+journal reports the multi-step operations (e.g. volume
+provision) that didn't complete because the utility crashed
+or the host rebooted, along with the steps they finished.
+Interrupted operations can be resumed, which runs them again
+with the same arguments since each of their steps can be
+safely repeated, or discarded once they've been dealt with.
+`),
+	}
+
+	cmd.AddCommand(journalListCommand(), journalResumeCommand(), journalDiscardCommand())
+
+	return cmd
+}
+
+// journalListCommand creates a new command which lists the interrupted operations.
+func journalListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list interrupted operations",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		entries, err := journal.New(journalDir).Pending()
+		if err != nil {
+			return err
+		}
+
+		return printJournal(cmd.OutOrStdout(), outputFormat(cmd), entries)
+	}
+
+	return cmd
+}
+
+// journalResumeCommand creates a new command which resumes an interrupted operation.
+func journalResumeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "resume <id>",
+		Short:       "resume an interrupted operation",
+		Args:        cobra.ExactArgs(1),
+		Annotations: map[string]string{publishesEventsAnnotation: "true"},
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", provisionDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Resuming operations modifies disks, which requires root permissions and Full Disk Access.
+	cmd.PreRunE = assertDiskPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if timeout != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		product := contextual.Product(ctx)
+		if product == nil {
+			return errors.New("product required in context")
+		}
+		d, err := diskutil.ForProduct(product)
+		if err != nil {
+			return err
+		}
+
+		return resumeOperation(ctx, d, mounts.NewManager(product), journal.New(journalDir), args[0])
+	}
+
+	// Keep other disk operations on the host from running alongside this one.
+	lockDiskOperation(cmd)
+
+	return cmd
+}
+
+// journalDiscardCommand creates a new command which discards an interrupted operation.
+func journalDiscardCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "discard <id>",
+		Short: "discard an interrupted operation",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	// The journal is only writable by root.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		e, err := journal.New(journalDir).Get(args[0])
+		if err != nil {
+			return err
+		}
+		if err := e.Complete(); err != nil {
+			return err
+		}
+		logrus.WithField("id", e.ID).Info("Discarded interrupted operation")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// resumeOperation runs the interrupted operation with the given ID again, removing its entry once it completes.
+func resumeOperation(ctx context.Context, utility diskutil.DiskUtil, m *mounts.Manager, j *journal.Journal, id string) error {
+	e, err := j.Get(id)
+	if err != nil {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"id":        e.ID,
+		"operation": e.Operation,
+		"completed": e.Completed(),
+	}).Info("Resuming interrupted operation...")
+	switch e.Operation {
+	case operationProvision:
+		args, err := provisionArgsFromJournal(e.Args)
+		if err != nil {
+			return err
+		}
+
+		// Each of the steps can be repeated so provisioning is run again from the start, which completes the entry.
+		return resumeProvision(ctx, utility, m, args, e)
+	default:
+		return fmt.Errorf("operation %q can't be resumed", e.Operation)
+	}
+}
+
+// provisionJournalArgs gets the journal's arguments for provisioning a volume.
+func provisionJournalArgs(args provisionVolume) map[string]string {
+	return map[string]string{
+		"id":                args.id,
+		"format":            args.format,
+		"label":             args.label,
+		"mount_point":       args.mountPoint,
+		"persist":           strconv.FormatBool(args.persist),
+		"disable_spotlight": strconv.FormatBool(args.disableSpotlight),
+		"encrypt":           strconv.FormatBool(args.encrypt),
+		"escrow":            args.escrow,
+	}
+}
+
+// provisionArgsFromJournal gets the arguments for provisioning a volume from the journal's.
+func provisionArgsFromJournal(args map[string]string) (provisionVolume, error) {
+	persist, err := strconv.ParseBool(args["persist"])
+	if err != nil {
+		return provisionVolume{}, fmt.Errorf("invalid persist argument in journal: %w", err)
+	}
+	disableSpotlight, err := strconv.ParseBool(args["disable_spotlight"])
+	if err != nil {
+		return provisionVolume{}, fmt.Errorf("invalid disable_spotlight argument in journal: %w", err)
+	}
+	// Entries journaled before volumes could be encrypted don't have the encryption arguments
+	var encrypt bool
+	if v, ok := args["encrypt"]; ok {
+		if encrypt, err = strconv.ParseBool(v); err != nil {
+			return provisionVolume{}, fmt.Errorf("invalid encrypt argument in journal: %w", err)
+		}
+	}
+
+	return provisionVolume{
+		id:               args["id"],
+		format:           args["format"],
+		label:            args["label"],
+		mountPoint:       args["mount_point"],
+		persist:          persist,
+		disableSpotlight: disableSpotlight,
+		encrypt:          encrypt,
+		escrow:           args["escrow"],
+	}, nil
+}
+
+// beginJournal saves a journal entry for the operation. The journal only helps with recovering from crashes so
+// operations run without one, with a warning, when it can't be written.
+func beginJournal(operation string, args map[string]string, steps []string) *journal.Entry {
+	e, err := journal.New(journalDir).Begin(operation, args, steps)
+	if err != nil {
+		logrus.WithError(err).Warn("Unable to journal operation, it won't be resumable if it's interrupted")
+		return nil
+	}
+
+	return e
+}
+
+// journalStep records the outcome of the step in the journal entry, if there is one.
+func journalStep(e *journal.Entry, name string, err error) {
+	if e == nil {
+		return
+	}
+	if jerr := e.Step(name, err); jerr != nil {
+		logrus.WithError(jerr).Warn("Unable to journal step")
+	}
+}
+
+// completeJournal removes the journal entry, if there is one, once its operation has completed.
+func completeJournal(e *journal.Entry) {
+	if e == nil {
+		return
+	}
+	if err := e.Complete(); err != nil {
+		logrus.WithError(err).Warn("Unable to remove completed operation from journal")
+	}
+}
+
+// reportPendingOperations logs the operations in the journal that didn't complete.
+func reportPendingOperations() {
+	entries, err := journal.New(journalDir).Pending()
+	if err != nil {
+		logrus.WithError(err).Debug("Unable to read the journal")
+		return
+	}
+
+	for _, e := range entries {
+		var next string
+		if s := e.Next(); s != nil {
+			next = s.Name
+		}
+		logrus.WithFields(logrus.Fields{
+			"id":        e.ID,
+			"operation": e.Operation,
+			"started":   e.Started.Format(time.RFC3339),
+			"next_step": next,
+		}).Warnf("Found an operation that didn't complete, run 'journal resume %s' to resume it", e.ID)
+	}
+}
+
+// printJournal writes a table of the journal's entries to w.
+func printJournal(w io.Writer, format string, entries []*journal.Entry) error {
+	if entries == nil {
+		entries = []*journal.Entry{}
+	}
+
+	return printOutput(w, format, entries, func(w io.Writer) error {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tOPERATION\tSTARTED\tCOMPLETED\tNEXT STEP")
+		for _, e := range entries {
+			next := "-"
+			if s := e.Next(); s != nil {
+				next = s.Name
+				if s.Status == journal.StepFailed {
+					next += " (failed: " + s.Error + ")"
+				}
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d/%d\t%s\n", e.ID, e.Operation, e.Started.Format(time.RFC3339), len(e.Completed()), len(e.Steps), next)
+		}
+
+		return tw.Flush()
+	})
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/journal"
+)
+
+func init() {
+	// Don't write to the host's journal in tests
+	journalDir = filepath.Join(os.TempDir(), "ec2-macos-utils-test-journal-"+strconv.Itoa(os.Getpid()))
+}
+
+func TestProvisionArgsFromJournal(t *testing.T) {
+	args := provisionVolume{
+		id:               "vol-0123456789abcdef0",
+		format:           "APFS",
+		label:            "Data",
+		mountPoint:       "/Volumes/Data",
+		persist:          true,
+		disableSpotlight: true,
+		encrypt:          true,
+		escrow:           "ssm",
+	}
+
+	actual, err := provisionArgsFromJournal(provisionJournalArgs(args))
+
+	assert.NoError(t, err, "should be able to read journaled args")
+	assert.Equal(t, args, actual, "args should round trip through the journal")
+}
+
+func TestProvisionArgsFromJournal_Invalid(t *testing.T) {
+	_, err := provisionArgsFromJournal(map[string]string{"id": "disk2"})
+
+	assert.Error(t, err, "should fail without persist")
+}
+
+func TestProvisionSteps(t *testing.T) {
+	assert.Equal(t, []string{stepMountPoint, stepProvision}, provisionSteps(provisionVolume{}))
+	assert.Equal(t, []string{stepMountPoint, stepProvision, stepSpotlight, stepPersist}, provisionSteps(provisionVolume{disableSpotlight: true, persist: true}))
+	assert.Equal(t, []string{stepMountPoint, stepEncrypt, stepProvision}, provisionSteps(provisionVolume{encrypt: true}))
+}
+
+func TestResumeOperation_Unknown(t *testing.T) {
+	j := journal.New(t.TempDir())
+	e, err := j.Begin("reticulate", nil, []string{"spline"})
+	assert.NoError(t, err, "should be able to begin entry")
+
+	err = resumeOperation(context.Background(), nil, nil, j, e.ID)
+
+	assert.Error(t, err, "should fail for operation that can't be resumed")
+}
+
+func TestPrintJournal(t *testing.T) {
+	j := journal.New(t.TempDir())
+	e, err := j.Begin(operationProvision, nil, []string{stepMountPoint, stepProvision})
+	assert.NoError(t, err, "should be able to begin entry")
+	assert.NoError(t, e.Step(stepMountPoint, nil))
+
+	var buf bytes.Buffer
+	err = printJournal(&buf, outputText, []*journal.Entry{e})
+
+	assert.NoError(t, err, "should be able to print journal")
+	assert.Contains(t, buf.String(), e.ID)
+	assert.Contains(t, buf.String(), "1/2")
+	assert.Contains(t, buf.String(), stepProvision, "next step should be printed")
+}
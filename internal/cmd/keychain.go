@@ -0,0 +1,371 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/keychain"
+)
+
+// keychainDefaultTimeout is the default maximum run duration for managing keychains.
+const keychainDefaultTimeout = 5 * time.Minute
+
+// importKeychainItem is a struct for holding all information passed into the keychain import command.
+type importKeychainItem struct {
+	dryrun     bool
+	file       string
+	format     string
+	passphrase passwordSource
+	password   passwordSource
+	secretID   string
+	secretKey  string
+	timeout    time.Duration
+	user       string
+}
+
+// keychainCommand creates a new command which groups the keychain management subcommands.
+func keychainCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keychain",
+		Short: "manage keychains and code signing certificates",
+		Long: strings.TrimSpace(`
+keychain creates keychains for local users and installs code
+signing certificates in them with security(1), without any of
+the prompts that normally block unattended builds. Keychains are
+created in the user's ~/Library/Keychains unless an absolute path
+is given, never lock automatically, and are added to the user's
+search list so that codesign and xcodebuild can find them.
+
+Keychain passwords and certificates can be read from AWS Secrets
+Manager, and passwords from Parameter Store, using the instance's
+credentials.
+`),
+	}
+
+	cmd.AddCommand(keychainCreateCommand(), keychainImportCommand(), keychainIdentitiesCommand())
+
+	return cmd
+}
+
+// keychainCreateCommand creates a new command which creates a keychain for a user.
+func keychainCreateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "create a keychain for a user",
+		Long: strings.TrimSpace(`
+create creates the keychain, unless it already exists, and adds
+it to the user's search list. Existing keychains are unlocked
+with the password so that a mismatched password is caught early.
+`),
+		Args: cobra.ExactArgs(1),
+	}
+
+	var name string
+	var setDefault, dryrun bool
+	var timeout time.Duration
+	password := passwordSource{}
+	cmd.PersistentFlags().StringVar(&name, "user", "", "user that owns the keychain")
+	cmd.PersistentFlags().BoolVar(&setDefault, "default", false, "make the keychain the user's default keychain")
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", keychainDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+	addPasswordFlags(cmd, &password, "password")
+	cmd.MarkPersistentFlagRequired("user")
+
+	// Running security as another user requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			if !password.isSet() {
+				return errors.New("one of --password-secret, --password-parameter, or --password-stdin is required")
+			}
+
+			kc, err := lookupKeychain(ctx, name, args[0])
+			if err != nil {
+				return err
+			}
+			pw, err := password.read(ctx, cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			exists, err := kc.Exists()
+			if err != nil {
+				return err
+			}
+
+			if dryrun {
+				logrus.WithFields(logrus.Fields{
+					"keychain": kc.Path,
+					"exists":   exists,
+					"default":  setDefault,
+				}).Warn("Would have created keychain")
+				return nil
+			}
+
+			if exists {
+				logrus.WithField("keychain", kc.Path).Info("Keychain already exists, unlocking...")
+				if err := kc.Unlock(ctx, pw); err != nil {
+					return err
+				}
+			} else {
+				logrus.WithField("keychain", kc.Path).Info("Creating keychain...")
+				if err := kc.Create(ctx, pw); err != nil {
+					return err
+				}
+			}
+
+			changed, err := kc.AddToSearchList(ctx)
+			if err != nil {
+				return err
+			}
+			logrus.WithField("changed", changed).Info("Keychain in search list")
+
+			if setDefault {
+				if err := kc.SetDefault(ctx); err != nil {
+					return err
+				}
+				logrus.WithField("keychain", kc.Path).Info("Set default keychain")
+			}
+			logrus.WithField("keychain", kc.Path).Info("Successfully created keychain")
+
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+// keychainImportCommand creates a new command which imports a certificate into a keychain.
+func keychainImportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import <name>",
+		Short: "import a code signing certificate into a keychain",
+		Long: strings.TrimSpace(`
+import imports a certificate, or a PKCS#12 bundle holding a
+certificate and its private key, into the user's keychain. The
+item can be read from a file or from a Secrets Manager secret,
+either as a binary secret or as a base64 encoded string. Private
+keys are made available to codesign and Apple's other tools
+without prompting by setting the keychain's key partition list.
+Items that are already in the keychain are skipped. The keychain
+password is given to security on stdin, but security only takes
+the passphrase of PKCS#12 bundles in its arguments, where other
+users on the host can see it while the bundle is imported.
+`),
+		Args: cobra.ExactArgs(1),
+	}
+
+	importArgs := importKeychainItem{}
+	cmd.PersistentFlags().StringVar(&importArgs.user, "user", "", "user that owns the keychain")
+	cmd.PersistentFlags().StringVar(&importArgs.file, "file", "", "path to the item to import")
+	cmd.PersistentFlags().StringVar(&importArgs.secretID, "secret", "", "name or ARN of the Secrets Manager secret holding the item to import")
+	cmd.PersistentFlags().StringVar(&importArgs.secretKey, "secret-key", "", "field of the JSON secret holding the base64 encoded item, the whole secret is used when empty")
+	cmd.PersistentFlags().StringVar(&importArgs.format, "format", string(keychain.FormatPKCS12), "format of the item (pkcs12 or x509)")
+	cmd.PersistentFlags().BoolVar(&importArgs.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&importArgs.timeout, "timeout", keychainDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+	addPasswordFlags(cmd, &importArgs.password, "password")
+	addPasswordFlags(cmd, &importArgs.passphrase, "passphrase")
+	cmd.MarkPersistentFlagRequired("user")
+
+	// Running security as another user requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, importArgs.timeout, func(ctx context.Context) error {
+			return runKeychainImport(ctx, cmd, args[0], importArgs)
+		})
+	}
+
+	return cmd
+}
+
+// runKeychainImport unlocks the keychain, imports the item into it, and grants Apple's tools access to its keys.
+func runKeychainImport(ctx context.Context, cmd *cobra.Command, name string, args importKeychainItem) error {
+	format := keychain.Format(args.format)
+	if format != keychain.FormatPKCS12 && format != keychain.FormatX509 {
+		return fmt.Errorf("unsupported item format %q", args.format)
+	}
+	if !args.password.isSet() {
+		return errors.New("one of --password-secret, --password-parameter, or --password-stdin is required")
+	}
+	if args.password.stdin && args.passphrase.stdin {
+		return errors.New("only one of --password-stdin and --passphrase-stdin can be used")
+	}
+
+	kc, err := lookupKeychain(ctx, args.user, name)
+	if err != nil {
+		return err
+	}
+	if exists, err := kc.Exists(); err != nil {
+		return err
+	} else if !exists {
+		return fmt.Errorf("keychain %s doesn't exist", kc.Path)
+	}
+
+	data, err := readKeychainItem(ctx, args)
+	if err != nil {
+		return err
+	}
+	password, err := args.password.read(ctx, cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+	var passphrase string
+	if args.passphrase.isSet() {
+		if passphrase, err = args.passphrase.read(ctx, cmd.InOrStdin()); err != nil {
+			return err
+		}
+	}
+
+	if args.dryrun {
+		logrus.WithFields(logrus.Fields{
+			"keychain": kc.Path,
+			"format":   format,
+			"size":     len(data),
+		}).Warn("Would have imported item")
+		return nil
+	}
+
+	if err := kc.Unlock(ctx, password); err != nil {
+		return err
+	}
+
+	logrus.WithField("keychain", kc.Path).Info("Importing item...")
+	err = kc.Import(ctx, data, format, passphrase)
+	if errors.Is(err, keychain.ErrDuplicate) {
+		logrus.WithField("keychain", kc.Path).Info("Item already in keychain, skipping import")
+	} else if err != nil {
+		return err
+	}
+
+	if format == keychain.FormatPKCS12 {
+		logrus.WithField("partitions", keychain.DefaultPartitions).Info("Setting key partition list...")
+		if err := kc.SetKeyPartitionList(ctx, password, keychain.DefaultPartitions); err != nil {
+			return err
+		}
+	}
+	logrus.WithField("keychain", kc.Path).Info("Successfully imported item")
+
+	return nil
+}
+
+// readKeychainItem reads the item to import from its file or secret. Secrets may hold the item as a binary value
+// or as a base64 encoded string.
+func readKeychainItem(ctx context.Context, args importKeychainItem) ([]byte, error) {
+	switch {
+	case args.file != "" && args.secretID != "":
+		return nil, errors.New("only one of --file and --secret can be used")
+	case args.file != "":
+		return os.ReadFile(args.file)
+	case args.secretID == "":
+		return nil, errors.New("one of --file or --secret is required")
+	}
+
+	client, err := aws.NewClientFromMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := client.GetSecret(ctx, args.secretID)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeKeychainItem(secret, args.secretKey)
+}
+
+// decodeKeychainItem gets the item from the secret's binary value or, when it has none, the base64 encoded string
+// in its value or field.
+func decodeKeychainItem(secret *aws.Secret, field string) ([]byte, error) {
+	if secret.String == nil {
+		if field != "" {
+			return nil, errors.New("binary secrets have no fields")
+		}
+		if len(secret.Binary) == 0 {
+			return nil, errors.New("secret is empty")
+		}
+		return secret.Binary, nil
+	}
+
+	value, err := aws.SecretField(*secret.String, field)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("secret is not base64 encoded: %w", err)
+	}
+
+	return data, nil
+}
+
+// keychainIdentitiesCommand creates a new command which lists the code signing identities in a keychain.
+func keychainIdentitiesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "identities <name>",
+		Short: "list the code signing identities in a keychain",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	var name string
+	var timeout time.Duration
+	cmd.PersistentFlags().StringVar(&name, "user", "", "user that owns the keychain")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", keychainDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+	cmd.MarkPersistentFlagRequired("user")
+
+	// Running security as another user requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			kc, err := lookupKeychain(ctx, name, args[0])
+			if err != nil {
+				return err
+			}
+			ids, err := kc.Identities(ctx)
+			if err != nil {
+				return err
+			}
+			if ids == nil {
+				ids = []keychain.Identity{}
+			}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), ids, func(w io.Writer) error {
+				return printIdentities(w, ids)
+			})
+		})
+	}
+
+	return cmd
+}
+
+// lookupKeychain looks up the user and gets their keychain with the given name.
+func lookupKeychain(ctx context.Context, user string, name string) (*keychain.Keychain, error) {
+	owner, err := lookupManagedUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return keychain.New(owner, name), nil
+}
+
+// printIdentities writes a table of the identities to w.
+func printIdentities(w io.Writer, ids []keychain.Identity) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "HASH\tNAME")
+	for _, id := range ids {
+		fmt.Fprintf(tw, "%s\t%s\n", id.Hash, id.Name)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/keychain"
+)
+
+func TestReadKeychainItem(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signing.p12")
+	assert.NoError(t, os.WriteFile(path, []byte("p12"), 0600))
+
+	data, err := readKeychainItem(context.Background(), importKeychainItem{file: path})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("p12"), data)
+
+	_, err = readKeychainItem(context.Background(), importKeychainItem{file: path, secretID: "ci/signing"})
+	assert.Error(t, err, "should only allow one item source")
+
+	_, err = readKeychainItem(context.Background(), importKeychainItem{})
+	assert.Error(t, err, "should require an item source")
+}
+
+func TestDecodeKeychainItem(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name    string
+		secret  aws.Secret
+		field   string
+		want    []byte
+		wantErr bool
+	}{
+		{name: "binary", secret: aws.Secret{Binary: []byte{1, 2, 3}}, want: []byte{1, 2, 3}},
+		{name: "binary field", secret: aws.Secret{Binary: []byte{1, 2, 3}}, field: "p12", wantErr: true},
+		{name: "empty", secret: aws.Secret{}, wantErr: true},
+		{name: "base64 string", secret: aws.Secret{String: str("AQID\n")}, want: []byte{1, 2, 3}},
+		{name: "base64 field", secret: aws.Secret{String: str(`{"p12":"AQID"}`)}, field: "p12", want: []byte{1, 2, 3}},
+		{name: "not base64", secret: aws.Secret{String: str("not base64!")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeKeychainItem(&tt.secret, tt.field)
+
+			assert.Equal(t, tt.want, got)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPrintIdentities(t *testing.T) {
+	var buf bytes.Buffer
+	ids := []keychain.Identity{
+		{Hash: "0123456789ABCDEF0123456789ABCDEF01234567", Name: "Apple Development: Jane Doe (ABCDE12345)"},
+	}
+
+	assert.NoError(t, printIdentities(&buf, ids))
+	assert.Equal(t, "HASH                                      NAME\n"+
+		"0123456789ABCDEF0123456789ABCDEF01234567  Apple Development: Jane Doe (ABCDE12345)\n", buf.String())
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/imds"
+	"github.com/aws/ec2-macos-utils/internal/killswitch"
+	"github.com/aws/ec2-macos-utils/internal/schedule"
+)
+
+// killSwitchTimeout is the maximum time spent checking the kill switch before a scheduled run.
+const killSwitchTimeout = 30 * time.Second
+
+// killSwitch checks whether automation is paused (e.g. *killswitch.Checker).
+type killSwitch interface {
+	Check(ctx context.Context) (killswitch.State, error)
+}
+
+// newKillSwitch builds the kill switch from the configuration. The parameter is only read, with the instance
+// profile's credentials, when one is configured.
+func newKillSwitch(ctx context.Context, c config.Automation) (*killswitch.Checker, error) {
+	metadata := imds.NewClient()
+	k := &killswitch.Checker{Tags: metadata, Tag: c.KillSwitchTag, Parameter: c.KillSwitchParameter}
+	if k.Tag == "" {
+		k.Tag = killswitch.DefaultTag
+	}
+
+	if k.Parameter != "" {
+		region, err := metadata.Region(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot determine region for kill switch parameter: %w", err)
+		}
+		k.Parameters = aws.NewClient(region, metadata)
+	}
+
+	return k, nil
+}
+
+// skipScheduledRunIfPaused keeps scheduled runs of the command from doing anything while the kill switch pauses
+// automation, runs started by hand aren't affected. The run continues when the kill switch can't be checked so that
+// an outage of the instance metadata or Parameter Store doesn't stop automation on its own.
+func skipScheduledRunIfPaused(cmd *cobra.Command) {
+	name := os.Getenv(schedule.NameEnv)
+	if name == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), killSwitchTimeout)
+	defer cancel()
+
+	var k killSwitch
+	c, err := loadConfig(cmd)
+	if err == nil {
+		k, err = newKillSwitch(ctx, c.Automation)
+	}
+	if err != nil {
+		logrus.WithError(err).Warn("Unable to check the kill switch, continuing with scheduled run")
+		return
+	}
+
+	skipIfPaused(ctx, cmd, k, name)
+}
+
+// skipIfPaused replaces the command's run with one that only logs that it was skipped when the kill switch pauses
+// automation.
+func skipIfPaused(ctx context.Context, cmd *cobra.Command, k killSwitch, name string) {
+	state, err := k.Check(ctx)
+	if err != nil {
+		logrus.WithError(err).Warn("Unable to check the kill switch, continuing with scheduled run")
+		return
+	}
+	if !state.Paused {
+		return
+	}
+
+	fields := logrus.Fields{
+		"schedule": name,
+		"source":   state.Source,
+		"value":    state.Value,
+	}
+	cmd.PreRunE = nil
+	cmd.Run = nil
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		logrus.WithFields(fields).Warn("Automation is paused by the kill switch, skipping scheduled run")
+		return nil
+	}
+}
+
+// scheduleKillSwitchCommand creates a new command which reports whether the kill switch pauses scheduled runs.
+func scheduleKillSwitchCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "kill-switch",
+		Short: "report whether scheduled runs are paused",
+		Long: strings.TrimSpace(`
+kill-switch checks the instance tag (ec2-macos-utils:automation
+unless configured otherwise) and, when one is configured, the
+Parameter Store parameter that pause the scheduled runs of the
+utility. Either of them pauses scheduled runs while its value
+is "disabled", "paused", or "off". Runs started by hand are
+never paused.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", scheduleDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			c, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			k, err := newKillSwitch(ctx, c.Automation)
+			if err != nil {
+				return err
+			}
+			state, err := k.Check(ctx)
+			if err != nil {
+				return err
+			}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), state, func(w io.Writer) error {
+				_, err := fmt.Fprintln(w, state)
+				return err
+			})
+		})
+	}
+
+	return cmd
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/killswitch"
+)
+
+// fakeKillSwitch is a killSwitch with a fixed state.
+type fakeKillSwitch struct {
+	state killswitch.State
+	err   error
+}
+
+func (f fakeKillSwitch) Check(ctx context.Context) (killswitch.State, error) {
+	return f.state, f.err
+}
+
+// killSwitchTestCommand creates a command that records whether it ran.
+func killSwitchTestCommand(ran *bool) *cobra.Command {
+	cmd := &cobra.Command{Use: "test", SilenceUsage: true, SilenceErrors: true}
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		*ran = true
+		return nil
+	}
+
+	return cmd
+}
+
+func TestSkipIfPaused(t *testing.T) {
+	tests := []struct {
+		name     string
+		k        fakeKillSwitch
+		expected bool
+	}{
+		{name: "enabled", k: fakeKillSwitch{}, expected: true},
+		{name: "paused", k: fakeKillSwitch{state: killswitch.State{Paused: true, Source: "tag " + killswitch.DefaultTag, Value: "disabled"}}, expected: false},
+		{name: "unavailable", k: fakeKillSwitch{err: errors.New("connection refused")}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ran bool
+			cmd := killSwitchTestCommand(&ran)
+
+			skipIfPaused(context.Background(), cmd, tt.k, "grow")
+
+			assert.NoError(t, cmd.Execute())
+			assert.Equal(t, tt.expected, ran)
+		})
+	}
+}
+
+func TestSkipScheduledRunIfPaused_ByHand(t *testing.T) {
+	t.Setenv("EC2_MACOS_UTILS_SCHEDULE", "")
+	var ran bool
+	cmd := killSwitchTestCommand(&ran)
+
+	skipScheduledRunIfPaused(cmd)
+
+	assert.NoError(t, cmd.Execute())
+	assert.True(t, ran, "runs started by hand should never be paused")
+}
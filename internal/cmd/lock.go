@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/oplock"
+)
+
+// diskLockPath is the path of the lock held by mutating disk operations, it's replaced in tests.
+var diskLockPath = oplock.DefaultPath
+
+// lockDiskOperation makes the command hold the disk operation lock while it runs so that it can't interleave with
+// another mutating disk operation on the host. The command fails with an oplock.LockedError when another operation
+// holds the lock, unless --lock-wait is given to wait for it. Dry runs and plans don't take the lock since they don't
+// change any disks. Operations in the journal that didn't complete are reported once the lock is taken, when they
+// can't still be running. This must be called after the command's RunE is set.
+func lockDiskOperation(cmd *cobra.Command) {
+	var wait time.Duration
+	cmd.Flags().DurationVar(&wait, "lock-wait", 0, "wait up to this long for other disk operations on the host to finish, 0s fails immediately")
+
+	run := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if readonlyRun(cmd) {
+			return run(cmd, args)
+		}
+
+		ctx := cmd.Context()
+		if wait > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, wait)
+			defer cancel()
+		}
+
+		logrus.WithField("path", diskLockPath).Debug("Taking the disk operation lock...")
+		lock, err := oplock.Acquire(ctx, diskLockPath, wait > 0)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+		reportPendingOperations()
+
+		return run(cmd, args)
+	}
+}
+
+// readonlyRun reports whether the command is run with --dry-run or --plan, which don't change anything.
+func readonlyRun(cmd *cobra.Command) bool {
+	for _, name := range []string{"dry-run", "plan"} {
+		if readonly, err := cmd.Flags().GetBool(name); err == nil && readonly {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,81 @@
+//go:build unix
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/control"
+	"github.com/aws/ec2-macos-utils/internal/oplock"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/diskutilfakes"
+)
+
+func init() {
+	// Don't take the host's disk operation lock in tests
+	diskLockPath = filepath.Join(os.TempDir(), "ec2-macos-utils-test-"+strconv.Itoa(os.Getpid())+".lock")
+}
+
+// lockedTestCommand creates a command that holds the disk operation lock, recording whether it ran.
+func lockedTestCommand(ran *bool) *cobra.Command {
+	cmd := &cobra.Command{Use: "test", SilenceUsage: true, SilenceErrors: true}
+	cmd.Flags().Bool("dry-run", false, "")
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		*ran = true
+		return nil
+	}
+	lockDiskOperation(cmd)
+
+	return cmd
+}
+
+func TestLockDiskOperation(t *testing.T) {
+	var ran bool
+	cmd := lockedTestCommand(&ran)
+	cmd.SetArgs([]string{})
+	assert.NoError(t, cmd.ExecuteContext(context.Background()))
+	assert.True(t, ran, "should run while the lock is free")
+
+	lock, err := oplock.Acquire(context.Background(), diskLockPath, false)
+	assert.NoError(t, err)
+	defer lock.Release()
+
+	ran = false
+	cmd = lockedTestCommand(&ran)
+	cmd.SetArgs([]string{})
+	err = cmd.ExecuteContext(context.Background())
+	var lockedErr *oplock.LockedError
+	assert.True(t, errors.As(err, &lockedErr), "should fail while another operation holds the lock")
+	assert.False(t, ran)
+
+	cmd = lockedTestCommand(&ran)
+	cmd.SetArgs([]string{"--lock-wait", "300ms"})
+	err = cmd.ExecuteContext(context.Background())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "should stop waiting for the lock after --lock-wait")
+	assert.False(t, ran)
+
+	cmd = lockedTestCommand(&ran)
+	cmd.SetArgs([]string{"--dry-run"})
+	assert.NoError(t, cmd.ExecuteContext(context.Background()))
+	assert.True(t, ran, "dry runs shouldn't take the lock")
+}
+
+func TestControlService_GrowLocked(t *testing.T) {
+	svc := newControlService(context.Background(), diskutilfakes.New(fakeBootDisk()), nil, 0)
+
+	lock, err := oplock.Acquire(context.Background(), diskLockPath, false)
+	assert.NoError(t, err)
+	defer lock.Release()
+
+	err = svc.Grow(&control.GrowArgs{ID: "root"}, &control.GrowReply{})
+	var lockedErr *oplock.LockedError
+	assert.True(t, errors.As(err, &lockedErr), "shouldn't grow while another operation holds the lock")
+	assert.NoError(t, svc.Grow(&control.GrowArgs{ID: "root", DryRun: true}, &control.GrowReply{}), "dry runs shouldn't take the lock")
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/health"
+	"github.com/aws/ec2-macos-utils/internal/metrics"
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/internal/telemetry"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/events"
+)
+
+// metricsDefaultListen is the default address that metrics are served on.
+const metricsDefaultListen = "127.0.0.1:9662"
+
+// metricsCommand creates a new command which groups the metrics subcommands.
+func metricsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "metrics",
+		Short: "expose host metrics",
+	}
+
+	cmd.AddCommand(metricsServeCommand())
+
+	return cmd
+}
+
+// metricsServeCommand creates a new command which serves metrics in the Prometheus text format.
+func metricsServeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "serve metrics in the Prometheus text format",
+		Long: strings.TrimSpace(`
+serve runs until it's stopped, serving the capacity of the
+mounted local filesystems, counters of every command's runs and
+failures by class, and counters and duration histograms of the
+disk operations run by the daemon, in the Prometheus text format
+at /metrics. The daemon's status is
+served at /healthz. Metrics are only served on the loopback
+interface so they can't be scraped from off the host without
+a local agent or tunnel.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	var listen string
+	cmd.PersistentFlags().StringVar(&listen, "listen", metricsDefaultListen, "loopback address to serve metrics on")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		collector := metrics.NewCollector()
+		events.FromContext(cmd.Context()).Subscribe(collector)
+
+		monitor := health.NewMonitor("metrics")
+		handlers := map[string]http.Handler{
+			metrics.Path: metrics.Handler(collector, telemetry.New(telemetryPath).Read, mounts.Mounted, monitor.Record),
+			health.Path:  monitor,
+		}
+
+		logrus.WithField("address", listen).Info("Serving metrics")
+
+		return health.Serve(cmd.Context(), listen, handlers)
+	}
+
+	return cmd
+}
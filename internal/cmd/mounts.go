@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+)
+
+// mountsAdd is a struct for holding all information passed into the mounts add command.
+type mountsAdd struct {
+	dryrun     bool
+	mountPoint string
+	options    string
+	spec       string
+	vfsType    string
+}
+
+// mountsCommand creates a new command which groups the persistent mount management subcommands.
+func mountsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mounts",
+		Short: "manage persistent mounts",
+		Long: strings.TrimSpace(`
+mounts manages the entries in /etc/fstab and /etc/synthetic.conf
+that mount data volumes at fixed paths on every boot. Changes
+are made with the same locking and atomic replacement used by
+vifs(8) so that the files are never left partially written.
+`),
+	}
+
+	cmd.AddCommand(mountsListCommand(), mountsAddCommand(), mountsRemoveCommand())
+
+	return cmd
+}
+
+// mountsListCommand creates a new command which lists the persisted mounts.
+func mountsListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list persistent mounts",
+		Long: strings.TrimSpace(`
+list prints the entries in /etc/fstab and /etc/synthetic.conf.
+With --mounted, the filesystems that are currently mounted are
+listed along with their capacity, read directly from the
+kernel rather than from df(1).
+`),
+		Args: cobra.NoArgs,
+	}
+
+	var mounted bool
+	cmd.PersistentFlags().BoolVar(&mounted, "mounted", false, "list mounted filesystems and their capacity")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if mounted {
+			filesystems, err := mounts.Mounted()
+			if err != nil {
+				return err
+			}
+
+			return printFilesystems(cmd.OutOrStdout(), outputFormat(cmd), filesystems)
+		}
+
+		tab, err := mounts.ReadFstab(mounts.FstabPath)
+		if err != nil {
+			return fmt.Errorf("cannot read fstab: %w", err)
+		}
+		conf, err := mounts.ReadSynthetic(mounts.SyntheticPath)
+		if err != nil {
+			return fmt.Errorf("cannot read synthetic.conf: %w", err)
+		}
+
+		return printMounts(cmd.OutOrStdout(), tab, conf)
+	}
+
+	return cmd
+}
+
+// mountsAddCommand creates a new command which persists a mount.
+func mountsAddCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "persist a mount",
+		Long: strings.TrimSpace(`
+add persists a mount in /etc/fstab, replacing any existing
+entry for the same volume or mount point. Volumes should be
+identified by their volume UUID (e.g. UUID=<uuid>) since
+device identifiers can change between boots.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	addArgs := mountsAdd{}
+	cmd.PersistentFlags().StringVar(&addArgs.spec, "spec", "", `volume to mount (e.g. "UUID=<uuid>")`)
+	cmd.PersistentFlags().StringVar(&addArgs.mountPoint, "mount-point", "", "path to mount the volume at")
+	cmd.PersistentFlags().StringVar(&addArgs.vfsType, "type", "apfs", "filesystem type of the volume")
+	cmd.PersistentFlags().StringVar(&addArgs.options, "options", "rw,auto", "comma separated mount options")
+	cmd.PersistentFlags().BoolVar(&addArgs.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.MarkPersistentFlagRequired("spec")
+	cmd.MarkPersistentFlagRequired("mount-point")
+
+	// Editing /etc/fstab and /etc/synthetic.conf requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		product := contextual.Product(cmd.Context())
+		if product == nil {
+			return errors.New("product required in context")
+		}
+
+		entry := mounts.FstabEntry{
+			Spec:    addArgs.spec,
+			File:    addArgs.mountPoint,
+			VfsType: addArgs.vfsType,
+			MntOps:  addArgs.options,
+		}
+		if addArgs.dryrun {
+			logrus.WithField("entry", entry.String()).Warn("Would have persisted mount")
+			return nil
+		}
+
+		changed, err := mounts.NewManager(product).Persist(cmd.Context(), entry)
+		if err != nil {
+			return err
+		}
+		logrus.WithField("changed", changed).Info("Mount persisted")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// mountsRemoveCommand creates a new command which removes a persisted mount.
+func mountsRemoveCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove <spec or mount point>",
+		Short: "remove a persistent mount",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	var dryrun bool
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+
+	// Editing /etc/fstab and /etc/synthetic.conf requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		product := contextual.Product(cmd.Context())
+		if product == nil {
+			return errors.New("product required in context")
+		}
+
+		if dryrun {
+			logrus.WithField("mount", args[0]).Warn("Would have removed mount")
+			return nil
+		}
+
+		changed, err := mounts.NewManager(product).Remove(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+		if !changed {
+			logrus.WithField("mount", args[0]).Info("No persisted mount found, nothing to do")
+			return nil
+		}
+		logrus.WithField("mount", args[0]).Info("Mount removed")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// printMounts writes a table of the fstab entries and synthetic objects to w.
+func printMounts(w io.Writer, tab *mounts.Fstab, conf *mounts.Synthetic) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SPEC\tMOUNT POINT\tTYPE\tOPTIONS\tMANAGED")
+	for _, e := range tab.Entries() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\n", e.Spec, e.File, e.VfsType, e.MntOps, e.Managed)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	entries := conf.Entries()
+	if len(entries) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SYNTHETIC\tTARGET")
+	for _, e := range entries {
+		target := e.Target
+		if target == "" {
+			target = "(empty directory)"
+		}
+		fmt.Fprintf(tw, "/%s\t%s\n", e.Name, target)
+	}
+
+	return tw.Flush()
+}
+
+// printFilesystems writes a table of the mounted filesystems and their capacity to w.
+func printFilesystems(w io.Writer, format string, filesystems []mounts.Filesystem) error {
+	if filesystems == nil {
+		filesystems = []mounts.Filesystem{}
+	}
+
+	return printOutput(w, format, filesystems, func(w io.Writer) error {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "DEVICE\tMOUNT POINT\tTYPE\tSIZE\tUSED\tAVAIL\tCAPACITY")
+		for _, fs := range filesystems {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%.0f%%\n", fs.Device, fs.MountPoint, fs.Type,
+				humanize.Bytes(fs.TotalBytes), humanize.Bytes(fs.UsedBytes()), humanize.Bytes(fs.AvailableBytes), fs.UsedPercent())
+		}
+
+		return tw.Flush()
+	})
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+)
+
+func TestPrintMounts(t *testing.T) {
+	tab, err := mounts.ParseFstab(strings.NewReader("# managed by ec2-macos-utils\nUUID=TEST /data apfs rw,auto\n"))
+	assert.NoError(t, err)
+	conf, err := mounts.ParseSynthetic(strings.NewReader("data\n"))
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = printMounts(&buf, tab, conf)
+
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"SPEC       MOUNT POINT  TYPE  OPTIONS  MANAGED",
+		"UUID=TEST  /data        apfs  rw,auto  true",
+		"",
+		"SYNTHETIC  TARGET",
+		"/data      (empty directory)",
+		"",
+	}, "\n"), buf.String())
+}
+
+func TestPrintMounts_WithoutSynthetic(t *testing.T) {
+	var buf bytes.Buffer
+	err := printMounts(&buf, &mounts.Fstab{}, &mounts.Synthetic{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "SPEC  MOUNT POINT  TYPE  OPTIONS  MANAGED\n", buf.String())
+}
+
+func TestPrintFilesystems(t *testing.T) {
+	filesystems := []mounts.Filesystem{
+		{
+			Device:         "/dev/disk3s1",
+			MountPoint:     "/data",
+			Type:           "apfs",
+			Local:          true,
+			TotalBytes:     100000000000,
+			FreeBytes:      75000000000,
+			AvailableBytes: 75000000000,
+		},
+	}
+
+	var buf bytes.Buffer
+	err := printFilesystems(&buf, outputText, filesystems)
+
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"DEVICE        MOUNT POINT  TYPE  SIZE    USED   AVAIL  CAPACITY",
+		"/dev/disk3s1  /data        apfs  100 GB  25 GB  75 GB  25%",
+		"",
+	}, "\n"), buf.String())
+}
@@ -0,0 +1,450 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/imds"
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/internal/network"
+)
+
+const (
+	// networkDefaultTimeout is the default maximum run duration for configuring network aliases.
+	networkDefaultTimeout = 5 * time.Minute
+	// networkMetadataRetryDelay is the time waited between attempts to fetch the network interfaces' metadata while
+	// waiting for the metadata service (e.g. at boot, before the network is up).
+	networkMetadataRetryDelay = 5 * time.Second
+)
+
+// networkMetadata fetches the metadata of the instance's network interfaces.
+type networkMetadata interface {
+	NetworkInterfaces(ctx context.Context) ([]imds.NetworkInterface, error)
+}
+
+// networkCommand creates a new command which groups the network subcommands.
+func networkCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "network",
+		Short: "configure secondary private IP and IPv6 addresses",
+		Long: strings.TrimSpace(`
+network configures the secondary private IPv4 addresses and the
+IPv6 addresses of the instance's ENIs as interface aliases.
+macOS only configures the primary IPv4 address that DHCP assigns
+to each interface, so the other addresses aren't reachable until
+they're configured. The addresses are read from the instance
+metadata service and matched to interfaces by their MAC
+addresses.
+
+Interfaces with IPv6 addresses learn their default route from
+the VPC router. Interfaces in IPv6-only subnets also use the
+Amazon DNS server's IPv6 address when they don't have DNS
+servers configured.
+
+Aliases don't persist across reboots. They can be configured at
+boot, and periodically afterwards to pick up newly assigned
+addresses, by a launchd job installed with --persist.
+`),
+	}
+
+	cmd.AddCommand(networkStatusCommand(), networkConfigureCommand(), networkRemoveCommand())
+
+	return cmd
+}
+
+// networkStatusCommand creates a new command which reports the ENIs' addresses and whether they're configured.
+func networkStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "report ENI addresses and their aliases",
+		Args:  cobra.NoArgs,
+	}
+
+	var ipv6Metadata bool
+	var timeout time.Duration
+	addIPv6MetadataFlag(cmd, &ipv6Metadata)
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", networkDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			state, err := loadNetworkState(ctx, networkMetadataClient(ipv6Metadata), false)
+			if err != nil {
+				return err
+			}
+			aliases := state.aliases
+			if aliases == nil {
+				aliases = []network.Alias{}
+			}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), aliases, func(w io.Writer) error {
+				return printNetworkAliases(w, aliases)
+			})
+		})
+	}
+
+	return cmd
+}
+
+// networkConfigureCommand creates a new command which configures the ENIs' addresses as aliases.
+func networkConfigureCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "configure",
+		Short: "configure ENI addresses as aliases",
+		Args:  cobra.NoArgs,
+	}
+
+	var dryrun, ipv6Metadata, persist, verify, wait bool
+	var timeout time.Duration
+	addIPv6MetadataFlag(cmd, &ipv6Metadata)
+	cmd.PersistentFlags().BoolVar(&persist, "persist", false, "install a launchd job which configures the aliases at boot")
+	cmd.PersistentFlags().BoolVar(&verify, "verify", false, "verify IPv6 connectivity after configuring interfaces with IPv6 addresses")
+	cmd.PersistentFlags().BoolVar(&wait, "wait", false, "wait for the instance metadata service until the timeout")
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", networkDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Configuring interfaces and installing launchd jobs requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			state, err := loadNetworkState(ctx, networkMetadataClient(ipv6Metadata), wait)
+			if err != nil {
+				return err
+			}
+
+			if err := configureAliases(ctx, state.aliases, dryrun); err != nil {
+				return err
+			}
+			if err := configureIPv6(ctx, state, dryrun); err != nil {
+				return err
+			}
+			if verify && !dryrun && state.hasIPv6() {
+				if err := verifyIPv6(ctx); err != nil {
+					return err
+				}
+			}
+			if persist {
+				return persistNetworkAliases(ctx, launchd.NewDaemonManager(), dryrun)
+			}
+
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+// networkRemoveCommand creates a new command which removes the aliases and the job that persists them.
+func networkRemoveCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove",
+		Short: "remove the aliases of ENI addresses",
+		Args:  cobra.NoArgs,
+	}
+
+	var dryrun, ipv6Metadata bool
+	var timeout time.Duration
+	addIPv6MetadataFlag(cmd, &ipv6Metadata)
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", networkDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Configuring interfaces and removing launchd jobs requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			// The job is removed first so that it can't add the aliases back
+			if dryrun {
+				logrus.WithField("label", network.JobLabel).Warn("Would have uninstalled launchd job")
+			} else if _, err := launchd.NewDaemonManager().Uninstall(ctx, network.JobLabel); err != nil {
+				return fmt.Errorf("cannot uninstall launchd job: %w", err)
+			}
+
+			state, err := loadNetworkState(ctx, networkMetadataClient(ipv6Metadata), false)
+			if err != nil {
+				return err
+			}
+
+			return removeAliases(ctx, state.aliases, dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// addIPv6MetadataFlag adds the flag used to read the metadata from the IPv6 endpoint to the command.
+func addIPv6MetadataFlag(cmd *cobra.Command, ipv6Metadata *bool) {
+	cmd.PersistentFlags().BoolVar(ipv6Metadata, "imds-ipv6", false, "use the instance metadata service's IPv6 endpoint (e.g. in IPv6-only subnets)")
+}
+
+// networkMetadataClient creates the client for the metadata service's IPv4 endpoint, or its IPv6 endpoint.
+func networkMetadataClient(ipv6 bool) *imds.Client {
+	if ipv6 {
+		return imds.NewClientForEndpoint(imds.IPv6Endpoint)
+	}
+
+	return imds.NewClient()
+}
+
+// networkState is the instance's ENIs, the system's interfaces, and the aliases for the ENIs' addresses.
+type networkState struct {
+	enis       []imds.NetworkInterface
+	interfaces []network.Interface
+	aliases    []network.Alias
+}
+
+// hasIPv6 checks if any of the ENIs have IPv6 addresses.
+func (s *networkState) hasIPv6() bool {
+	for _, eni := range s.enis {
+		if len(eni.IPv6s) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// loadNetworkState finds the aliases for the addresses of the instance's ENIs. With wait, fetching the metadata is
+// retried until ctx is done.
+func loadNetworkState(ctx context.Context, metadata networkMetadata, wait bool) (*networkState, error) {
+	enis, err := fetchNetworkInterfaces(ctx, metadata, wait)
+	if err != nil {
+		return nil, err
+	}
+
+	interfaces, err := network.Interfaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, unmatched := network.Aliases(enis, interfaces)
+	for _, mac := range unmatched {
+		logrus.WithField("mac", mac).Warn("No interface found for ENI, its addresses won't be configured")
+	}
+
+	return &networkState{enis: enis, interfaces: interfaces, aliases: aliases}, nil
+}
+
+// fetchNetworkInterfaces fetches the metadata of the instance's ENIs. With wait, failures are retried every
+// networkMetadataRetryDelay until ctx is done.
+func fetchNetworkInterfaces(ctx context.Context, metadata networkMetadata, wait bool) ([]imds.NetworkInterface, error) {
+	for {
+		enis, err := metadata.NetworkInterfaces(ctx)
+		if err == nil || !wait {
+			return enis, err
+		}
+
+		logrus.WithError(err).Info("Waiting for instance metadata...")
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(networkMetadataRetryDelay):
+		}
+	}
+}
+
+// configureAliases adds the aliases that aren't configured yet.
+func configureAliases(ctx context.Context, aliases []network.Alias, dryrun bool) error {
+	for _, a := range aliases {
+		fields := logrus.Fields{
+			"interface": a.Interface,
+			"address":   a.Address,
+		}
+		if a.Configured {
+			logrus.WithFields(fields).Debug("Alias already configured")
+			continue
+		}
+		if dryrun {
+			logrus.WithFields(fields).Warn("Would have added alias")
+			continue
+		}
+
+		if err := network.AddAlias(ctx, a); err != nil {
+			return err
+		}
+		logrus.WithFields(fields).Info("Added alias")
+	}
+
+	return nil
+}
+
+// configureIPv6 configures the services of the interfaces with IPv6 addresses to learn their default route from
+// router advertisements. Services of interfaces in IPv6-only subnets without DNS servers use the Amazon DNS server.
+func configureIPv6(ctx context.Context, state *networkState, dryrun bool) error {
+	if !state.hasIPv6() {
+		return nil
+	}
+
+	services, err := network.Services(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, eni := range state.enis {
+		if len(eni.IPv6s) == 0 {
+			continue
+		}
+		service, ok := ipv6Service(eni, state.interfaces, services)
+		if !ok {
+			logrus.WithField("mac", eni.MAC).Warn("No network service found for ENI, its IPv6 router won't be configured")
+			continue
+		}
+
+		if dryrun {
+			logrus.WithField("service", service).Warn("Would have configured IPv6 automatically")
+		} else {
+			if err := network.EnableIPv6Router(ctx, service); err != nil {
+				return err
+			}
+			logrus.WithField("service", service).Info("Configured IPv6 automatically")
+		}
+
+		if eni.IPv6Only() {
+			if err := configureIPv6DNS(ctx, service, dryrun); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ipv6Service finds the name of the network service for the ENI's interface.
+func ipv6Service(eni imds.NetworkInterface, interfaces []network.Interface, services map[string]string) (string, bool) {
+	for _, iface := range interfaces {
+		if strings.EqualFold(iface.MAC, eni.MAC) {
+			service, ok := services[iface.Name]
+			return service, ok
+		}
+	}
+
+	return "", false
+}
+
+// configureIPv6DNS configures the service to use the Amazon DNS server when it doesn't have any DNS servers.
+func configureIPv6DNS(ctx context.Context, service string, dryrun bool) error {
+	servers, err := network.DNSServers(ctx, service)
+	if err != nil {
+		return err
+	}
+	if len(servers) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"service": service,
+			"servers": servers,
+		}).Debug("DNS servers already configured")
+		return nil
+	}
+
+	fields := logrus.Fields{
+		"service": service,
+		"server":  network.AmazonDNSIPv6,
+	}
+	if dryrun {
+		logrus.WithFields(fields).Warn("Would have configured DNS server")
+		return nil
+	}
+	if err := network.SetDNSServers(ctx, service, []string{network.AmazonDNSIPv6}); err != nil {
+		return err
+	}
+	logrus.WithFields(fields).Info("Configured DNS server")
+
+	return nil
+}
+
+// verifyIPv6 checks that there's a default IPv6 route and that the metadata service is reachable over IPv6.
+func verifyIPv6(ctx context.Context) error {
+	gateway, err := network.DefaultIPv6Gateway(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot verify IPv6 connectivity: %w", err)
+	}
+	if _, err := imds.NewClientForEndpoint(imds.IPv6Endpoint).InstanceID(ctx); err != nil {
+		return fmt.Errorf("cannot verify IPv6 connectivity: %w", err)
+	}
+	logrus.WithField("gateway", gateway).Info("Verified IPv6 connectivity")
+
+	return nil
+}
+
+// removeAliases removes the aliases that are configured.
+func removeAliases(ctx context.Context, aliases []network.Alias, dryrun bool) error {
+	for _, a := range aliases {
+		fields := logrus.Fields{
+			"interface": a.Interface,
+			"address":   a.Address,
+		}
+		if !a.Configured {
+			continue
+		}
+		if dryrun {
+			logrus.WithFields(fields).Warn("Would have removed alias")
+			continue
+		}
+
+		if err := network.RemoveAlias(ctx, a); err != nil {
+			return err
+		}
+		logrus.WithFields(fields).Info("Removed alias")
+	}
+
+	return nil
+}
+
+// persistNetworkAliases installs the launchd job which configures the aliases at boot with this executable.
+func persistNetworkAliases(ctx context.Context, m *launchd.Manager, dryrun bool) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot find executable: %w", err)
+	}
+	// Symlinks (e.g. from a package manager's bin directory) may move when upgraded, the job runs the target
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+
+	job := network.Job(executable)
+	if dryrun {
+		logrus.WithFields(logrus.Fields{
+			"label":      job.Label,
+			"executable": executable,
+		}).Warn("Would have installed launchd job")
+		return nil
+	}
+
+	if _, err := m.Install(ctx, job); err != nil {
+		return fmt.Errorf("cannot install launchd job: %w", err)
+	}
+
+	return nil
+}
+
+// printNetworkAliases writes a table of the aliases to w.
+func printNetworkAliases(w io.Writer, aliases []network.Alias) error {
+	if len(aliases) == 0 {
+		_, err := fmt.Fprintln(w, "No addresses to configure")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "INTERFACE\tMAC\tADDRESS\tCONFIGURED")
+	for _, a := range aliases {
+		configured := "no"
+		if a.Configured {
+			configured = "yes"
+		}
+		address := a.Address
+		if a.IPv6() {
+			address = fmt.Sprintf("%s/%d", a.Address, a.PrefixLength)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", a.Interface, a.MAC, address, configured)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/imds"
+	"github.com/aws/ec2-macos-utils/internal/network"
+)
+
+// fakeNetworkMetadata serves the ENIs' metadata once it's failed the given number of times.
+type fakeNetworkMetadata struct {
+	failures int
+	calls    int
+	enis     []imds.NetworkInterface
+	onCall   func()
+}
+
+func (f *fakeNetworkMetadata) NetworkInterfaces(ctx context.Context) ([]imds.NetworkInterface, error) {
+	f.calls++
+	if f.onCall != nil {
+		f.onCall()
+	}
+	if f.calls <= f.failures {
+		return nil, errors.New("connection refused")
+	}
+
+	return f.enis, nil
+}
+
+func TestFetchNetworkInterfaces_WithoutWait(t *testing.T) {
+	metadata := &fakeNetworkMetadata{failures: 1}
+
+	_, err := fetchNetworkInterfaces(context.Background(), metadata, false)
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, metadata.calls, "shouldn't retry without wait")
+}
+
+func TestFetchNetworkInterfaces_WaitUntilDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	metadata := &fakeNetworkMetadata{failures: 1, onCall: cancel}
+
+	_, err := fetchNetworkInterfaces(ctx, metadata, true)
+
+	assert.EqualError(t, err, "connection refused", "should return the last failure once the context is done")
+	assert.Equal(t, 1, metadata.calls)
+}
+
+func TestConfigureAliases_DryRun(t *testing.T) {
+	aliases := []network.Alias{
+		{Interface: "en0", Address: "172.31.22.15", Configured: true},
+		{Interface: "en0", Address: "172.31.22.16"},
+	}
+
+	assert.NoError(t, configureAliases(context.Background(), aliases, true))
+	assert.NoError(t, removeAliases(context.Background(), aliases, true))
+}
+
+func TestConfigureIPv6_WithoutIPv6(t *testing.T) {
+	state := &networkState{enis: []imds.NetworkInterface{{MAC: "0e:5d:2c:8a:1f:37", PrimaryIPv4: "172.31.22.14"}}}
+
+	// Services aren't listed when there's nothing to configure, which would fail off of macOS
+	assert.NoError(t, configureIPv6(context.Background(), state, false))
+}
+
+func TestIPv6Service(t *testing.T) {
+	interfaces := []network.Interface{{Name: "en0", MAC: "0e:5d:2c:8a:1f:37"}}
+	services := map[string]string{"en0": "Ethernet"}
+
+	service, ok := ipv6Service(imds.NetworkInterface{MAC: "0E:5D:2C:8A:1F:37"}, interfaces, services)
+	assert.True(t, ok)
+	assert.Equal(t, "Ethernet", service)
+
+	_, ok = ipv6Service(imds.NetworkInterface{MAC: "0e:00:00:00:00:03"}, interfaces, services)
+	assert.False(t, ok, "should fail for ENIs without interfaces")
+}
+
+func TestPrintNetworkAliases(t *testing.T) {
+	aliases := []network.Alias{
+		{Interface: "en0", MAC: "0e:5d:2c:8a:1f:37", Address: "172.31.22.15", Configured: true},
+		{Interface: "en1", MAC: "0e:7a:11:42:9c:01", Address: "172.31.40.9"},
+		{Interface: "en1", MAC: "0e:7a:11:42:9c:01", Address: "2600:1f14:abc:de00::11", PrefixLength: 64},
+	}
+	expected := "INTERFACE  MAC                ADDRESS                    CONFIGURED\n" +
+		"en0        0e:5d:2c:8a:1f:37  172.31.22.15               yes\n" +
+		"en1        0e:7a:11:42:9c:01  172.31.40.9                no\n" +
+		"en1        0e:7a:11:42:9c:01  2600:1f14:abc:de00::11/64  no\n"
+
+	var buf bytes.Buffer
+	assert.NoError(t, printNetworkAliases(&buf, aliases))
+	assert.Equal(t, expected, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, printNetworkAliases(&buf, nil))
+	assert.Equal(t, "No addresses to configure\n", buf.String())
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/imds"
+	"github.com/aws/ec2-macos-utils/internal/notify"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/events"
+)
+
+const (
+	// notifyFlushTimeout is the time given to notifications that are still being sent when the command exits.
+	notifyFlushTimeout = 15 * time.Second
+	// notifyMetadataTimeout is the time given to looking up the instance ID that notifications are sent with, so that
+	// hosts without the instance metadata service don't hold up the command.
+	notifyMetadataTimeout = 2 * time.Second
+)
+
+// publishesEventsAnnotation marks commands that run the disk operations whose outcomes are notified about. Other
+// commands don't load the configuration or look up the instance ID for notifications.
+const publishesEventsAnnotation = "ec2-macos-utils/publishes-events"
+
+// setupNotifications subscribes a notifier to the command's events when notifications are configured and the command
+// is annotated with publishesEventsAnnotation. Problems with the configuration are logged rather than failing the
+// command since notifications are only informational.
+func setupNotifications(cmd *cobra.Command) {
+	if _, ok := cmd.Annotations[publishesEventsAnnotation]; !ok {
+		return
+	}
+	bus := events.FromContext(cmd.Context())
+	if bus == nil {
+		return
+	}
+
+	c, err := loadConfig(cmd)
+	if err != nil {
+		logrus.WithError(err).Debug("Not sending notifications")
+		return
+	}
+	n, err := newNotifier(cmd.Context(), c.Notifications)
+	if err != nil {
+		logrus.WithError(err).Warn("Not sending notifications")
+		return
+	} else if n == nil {
+		return
+	}
+	bus.Subscribe(n)
+
+	// Finalizers run after the command whether or not it failed, which is when notifications matter most.
+	cobra.OnFinalize(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyFlushTimeout)
+		defer cancel()
+		if err := n.Wait(ctx); err != nil {
+			logrus.WithError(err).Warn("Exiting before all notifications were sent")
+		}
+	})
+}
+
+// newNotifier creates a notifier for the configured destinations. No notifier is created when there aren't any.
+func newNotifier(ctx context.Context, c config.Notifications) (*notify.Notifier, error) {
+	if c.WebhookURL == "" && c.SNSTopicARN == "" {
+		return nil, nil
+	}
+
+	var operations []events.Operation
+	for _, name := range c.Operations {
+		switch op := events.Operation(name); op {
+		case events.OperationGrow, events.OperationRepair, events.OperationResize, events.OperationProvision:
+			operations = append(operations, op)
+		default:
+			return nil, fmt.Errorf("unknown notification operation %q", name)
+		}
+	}
+
+	var senders []notify.Sender
+	if c.WebhookURL != "" {
+		webhook, err := notify.NewWebhookSender(c.WebhookURL)
+		if err != nil {
+			return nil, err
+		}
+		senders = append(senders, webhook)
+	}
+
+	// Notifiers live as long as the daemons that use them so their metadata lookups are cached.
+	metadata := imds.NewCachedClient()
+	if c.SNSTopicARN != "" {
+		// Topics may be in another region than the instance so the client is created for the topic's region.
+		region, err := aws.RegionFromARN(c.SNSTopicARN)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SNS topic: %w", err)
+		}
+		senders = append(senders, &notify.SNSSender{Client: aws.NewClient(region, metadata), TopicARN: c.SNSTopicARN})
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, notifyMetadataTimeout)
+	defer cancel()
+	instanceID, err := metadata.InstanceID(lookupCtx)
+	if err != nil {
+		logrus.WithError(err).Debug("Sending notifications without the instance ID")
+	}
+
+	return notify.NewNotifier(instanceID, operations, senders...), nil
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+)
+
+func TestNewNotifier_Unconfigured(t *testing.T) {
+	n, err := newNotifier(context.Background(), config.Notifications{Operations: []string{"grow"}})
+
+	assert.NoError(t, err)
+	assert.Nil(t, n, "nothing should be sent without a destination")
+}
+
+func TestNewNotifier_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input config.Notifications
+	}{
+		{name: "http webhook", input: config.Notifications{WebhookURL: "http://example.com/hook"}},
+		{name: "topic", input: config.Notifications{SNSTopicARN: "disks"}},
+		{name: "operation", input: config.Notifications{WebhookURL: "https://example.com/hook", Operations: []string{"format"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newNotifier(context.Background(), tt.input)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPublishesEventsAnnotation(t *testing.T) {
+	var annotated []string
+	var walk func(c *cobra.Command)
+	walk = func(c *cobra.Command) {
+		if _, ok := c.Annotations[publishesEventsAnnotation]; ok {
+			annotated = append(annotated, c.CommandPath())
+		}
+		for _, sub := range c.Commands() {
+			walk(sub)
+		}
+	}
+	walk(MainCommand())
+
+	assert.ElementsMatch(t, []string{
+		"ec2-macos-utils grow",
+		"ec2-macos-utils volume provision",
+		"ec2-macos-utils scratch provision",
+		"ec2-macos-utils scratch uninstall",
+		"ec2-macos-utils apply",
+		"ec2-macos-utils control serve",
+		"ec2-macos-utils journal resume",
+	}, annotated, "only commands that run grow, repair, resize, or provision operations should set up notifications")
+}
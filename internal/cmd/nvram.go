@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/nvram"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// nvramDefaultTimeout is the default maximum run duration for managing firmware variables.
+const nvramDefaultTimeout = time.Minute
+
+// nvramCommand creates a new command which groups the firmware variable subcommands.
+func nvramCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "nvram",
+		Short: "manage firmware variables",
+		Long: strings.TrimSpace(`
+nvram reads and changes firmware variables, such as the kernel's
+boot-args, with nvram(8). Changes take effect on the next boot.
+Variables can only be changed on Intel (mac1) instances since
+Apple silicon only honors boot-args with reduced boot security.
+`),
+	}
+
+	cmd.AddCommand(nvramGetCommand(), nvramSetCommand(), nvramDeleteCommand(), nvramBootArgsCommand())
+
+	return cmd
+}
+
+// nvramGetCommand creates a new command which prints a firmware variable.
+func nvramGetCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get <name>",
+		Short: "print a firmware variable",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", nvramDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			value, err := nvram.Get(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			variable := struct {
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			}{Name: args[0], Value: value}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), variable, func(w io.Writer) error {
+				_, err := fmt.Fprintln(w, value)
+				return err
+			})
+		})
+	}
+
+	return cmd
+}
+
+// nvramSetCommand creates a new command which sets a firmware variable.
+func nvramSetCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set <name> <value>",
+		Short: "set a firmware variable",
+		Args:  cobra.ExactArgs(2),
+	}
+
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", nvramDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Changing firmware variables requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		name, value := args[0], args[1]
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			current, err := nvram.Get(ctx, name)
+			if err != nil && !errors.Is(err, nvram.ErrNotFound) {
+				return err
+			}
+			if err == nil && current == value {
+				logrus.WithField("name", name).Info("Variable already set, nothing to do")
+				return nil
+			}
+
+			return changeVariable(ctx, name, dryrun, func() error {
+				return nvram.Set(ctx, name, value)
+			})
+		})
+	}
+
+	return cmd
+}
+
+// nvramDeleteCommand creates a new command which deletes a firmware variable.
+func nvramDeleteCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "delete a firmware variable",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", nvramDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Changing firmware variables requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			if _, err := nvram.Get(ctx, name); errors.Is(err, nvram.ErrNotFound) {
+				logrus.WithField("name", name).Info("Variable isn't set, nothing to do")
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			return changeVariable(ctx, name, dryrun, func() error {
+				return nvram.Delete(ctx, name)
+			})
+		})
+	}
+
+	return cmd
+}
+
+// nvramBootArgsCommand creates a new command which edits the kernel's boot arguments.
+func nvramBootArgsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "boot-args",
+		Short: "print or edit the kernel's boot arguments",
+		Long: strings.TrimSpace(`
+boot-args prints the kernel's boot arguments or, when any flags
+are given, edits them. Added arguments replace existing arguments
+with the same key (e.g. --add serial=3 replaces serial=1) so
+that repeated runs leave the same arguments in place. boot-args
+is deleted when no arguments remain.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	var add, remove []string
+	var clearArgs, dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().StringSliceVar(&add, "add", nil, `boot argument to add (e.g. "-v" or "serial=3"), may be repeated`)
+	cmd.PersistentFlags().StringSliceVar(&remove, "remove", nil, `key of a boot argument to remove (e.g. "serial"), may be repeated`)
+	cmd.PersistentFlags().BoolVar(&clearArgs, "clear", false, "remove all boot arguments before adding")
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", nvramDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			current, err := nvram.GetBootArgs(ctx)
+			if err != nil {
+				return err
+			}
+			if len(add) == 0 && len(remove) == 0 && !clearArgs {
+				return printBootArgs(cmd, current)
+			}
+
+			// Changing firmware variables requires root permissions.
+			if err := assertRootPrivileges(cmd, args); err != nil {
+				return err
+			}
+
+			base := current
+			if clearArgs {
+				base = nil
+			}
+			desired := nvram.MergeBootArgs(base, add, remove)
+			if err := nvram.ValidateBootArgs(desired); err != nil {
+				return err
+			}
+			if strings.Join(desired, " ") == strings.Join(current, " ") {
+				logrus.WithField("boot_args", current).Info("Boot arguments already set, nothing to do")
+				return printBootArgs(cmd, current)
+			}
+
+			if err := changeVariable(ctx, nvram.BootArgs, dryrun, func() error {
+				if len(desired) == 0 {
+					return nvram.Delete(ctx, nvram.BootArgs)
+				}
+				return nvram.Set(ctx, nvram.BootArgs, strings.Join(desired, " "))
+			}); err != nil {
+				return err
+			}
+
+			return printBootArgs(cmd, desired)
+		})
+	}
+
+	return cmd
+}
+
+// changeVariable runs change unless dryrun is set, refusing to change variables on Apple silicon.
+func changeVariable(ctx context.Context, name string, dryrun bool, change func() error) error {
+	appleSilicon, err := system.AppleSilicon(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot detect processor: %w", err)
+	}
+	if appleSilicon {
+		return errors.New("firmware variables can only be changed on Intel instances")
+	}
+
+	if dryrun {
+		logrus.WithField("name", name).Warn("Would have changed variable")
+		return nil
+	}
+
+	if err := change(); err != nil {
+		return err
+	}
+	logrus.WithField("name", name).Info("Successfully changed variable, reboot for it to take effect")
+
+	return nil
+}
+
+// printBootArgs writes the boot arguments to the command's output.
+func printBootArgs(cmd *cobra.Command, bootArgs []string) error {
+	if bootArgs == nil {
+		bootArgs = []string{}
+	}
+
+	return printOutput(cmd.OutOrStdout(), outputFormat(cmd), bootArgs, func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, strings.Join(bootArgs, " "))
+		return err
+	})
+}
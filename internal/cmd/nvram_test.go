@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintBootArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		bootArgs []string
+		want     string
+	}{
+		{name: "text", format: outputText, bootArgs: []string{"-v", "serial=3"}, want: "-v serial=3\n"},
+		{name: "json", format: outputJSON, bootArgs: []string{"-v"}, want: "[\n  \"-v\"\n]\n"},
+		{name: "json unset", format: outputJSON, want: "[]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			cmd := &cobra.Command{}
+			cmd.Flags().String("output", tt.format, "")
+			cmd.SetOut(&buf)
+
+			assert.NoError(t, printBootArgs(cmd, tt.bootArgs))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
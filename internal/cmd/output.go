@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// outputText formats command results as human-readable text.
+	outputText = "text"
+	// outputJSON formats command results as indented JSON for consumption by other tools.
+	outputJSON = "json"
+)
+
+// outputFormat gets the format selected with the root command's --output flag. Commands run without the root
+// command (e.g. in tests) default to text.
+func outputFormat(cmd *cobra.Command) string {
+	f := cmd.Flags().Lookup("output")
+	if f == nil {
+		return outputText
+	}
+
+	return f.Value.String()
+}
+
+// validateOutputFormat checks that the output format is supported.
+func validateOutputFormat(format string) error {
+	switch format {
+	case outputText, outputJSON:
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format %q, expected %q or %q", format, outputText, outputJSON)
+	}
+}
+
+// printOutput writes v to w in the given format. The text format is written by printText.
+func printOutput(w io.Writer, format string, v interface{}, printText func(w io.Writer) error) error {
+	if format != outputJSON {
+		return printText(w)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/power"
+)
+
+// powerDefaultTimeout is the default maximum run duration for checking and applying power management settings.
+const powerDefaultTimeout = time.Minute
+
+// powerCommand creates a new command which groups the power management subcommands.
+func powerCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "power",
+		Short: "manage power management settings",
+		Long: strings.TrimSpace(`
+power manages the pmset(1) settings of the instance. macOS
+defaults to settings meant for desktops and laptops which put
+idle hosts to sleep and leave them unreachable. The server
+settings disable system, disk, and display sleep as well as
+Power Nap and standby, and keep wake for network access on.
+Settings in the configuration file's power section are applied
+on top of the server settings.
+`),
+	}
+
+	cmd.AddCommand(powerCheckCommand(), powerApplyCommand())
+
+	return cmd
+}
+
+// powerCheckCommand creates a new command which reports drift from the server power management settings.
+func powerCheckCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "report drift from the server power settings",
+		Long: strings.TrimSpace(`
+check compares the active power management settings with the
+server settings and reports each setting that differs. The
+command fails when any setting differs.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", powerDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if timeout != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		c, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		t := powerTask(c.Power)
+
+		if err := checkTask(ctx, cmd, t); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return errors.New("timeout exceeded")
+			}
+
+			return err
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// powerApplyCommand creates a new command which applies the server power management settings.
+func powerApplyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "apply the server power settings",
+		Args:  cobra.NoArgs,
+	}
+
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", powerDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Changing settings with pmset requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if timeout != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		c, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		t := powerTask(c.Power)
+
+		if err := applyTask(ctx, cmd, t, dryrun); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return errors.New("timeout exceeded")
+			}
+
+			return err
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// powerTask builds the power task from the server settings and the configured settings, which take precedence.
+func powerTask(conf map[string]string) *power.Task {
+	t := power.NewTask()
+	if len(conf) == 0 {
+		return t
+	}
+
+	desired := power.Settings{}
+	for k, v := range t.Desired {
+		desired[k] = v
+	}
+	for k, v := range conf {
+		desired[k] = v
+	}
+	t.Desired = desired
+
+	return t
+}
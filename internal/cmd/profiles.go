@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/profiles"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// profilesDefaultTimeout is the default maximum run duration for managing configuration profiles.
+const profilesDefaultTimeout = 5 * time.Minute
+
+// profileManager lists, installs, and removes configuration profiles so that tests can stand in for the profiles
+// CLI.
+type profileManager interface {
+	List(ctx context.Context) ([]profiles.Profile, error)
+	Install(ctx context.Context, p *system.Product, path string) error
+	Remove(ctx context.Context, identifier string) error
+}
+
+// systemProfiles is the profileManager for the running system.
+type systemProfiles struct{}
+
+func (systemProfiles) List(ctx context.Context) ([]profiles.Profile, error) {
+	return profiles.List(ctx)
+}
+
+func (systemProfiles) Install(ctx context.Context, p *system.Product, path string) error {
+	return profiles.Install(ctx, p, path)
+}
+
+func (systemProfiles) Remove(ctx context.Context, identifier string) error {
+	return profiles.Remove(ctx, identifier)
+}
+
+// profilesCommand creates a new command which groups the configuration profile subcommands.
+func profilesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profiles",
+		Short: "manage configuration profiles",
+		Long: strings.TrimSpace(`
+profiles installs, lists, and removes configuration profiles
+(.mobileconfig files) with profiles(1) so that fleet policies,
+like certificates and restrictions, can be applied to instances.
+macOS Big Sur and later only install profiles through MDM or
+after they're approved in System Settings, and profiles
+installed by MDM can only be removed by it, so these changes
+fail with an explanation instead.
+`),
+	}
+
+	cmd.AddCommand(profilesStatusCommand(), profilesListCommand(), profilesInstallCommand(), profilesRemoveCommand())
+
+	return cmd
+}
+
+// profilesStatusCommand creates a new command which reports the host's MDM enrollment.
+func profilesStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "report whether the host is enrolled in MDM",
+		Args:  cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", profilesDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			e, err := profiles.EnrollmentStatus(ctx)
+			if err != nil {
+				return err
+			}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), e, func(w io.Writer) error {
+				_, err := fmt.Fprintf(w, "MDM: %s\n", e)
+				return err
+			})
+		})
+	}
+
+	return cmd
+}
+
+// profilesListCommand creates a new command which lists the installed configuration profiles.
+func profilesListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list the installed configuration profiles",
+		Args:  cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", profilesDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Listing the profiles of every user requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			installed, err := profiles.List(ctx)
+			if err != nil {
+				return err
+			}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), installed, func(w io.Writer) error {
+				return printProfiles(w, installed)
+			})
+		})
+	}
+
+	return cmd
+}
+
+// profilesInstallCommand creates a new command which installs a configuration profile.
+func profilesInstallCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install <path>",
+		Short: "install a configuration profile",
+		Long: strings.TrimSpace(`
+install installs the configuration profile for the computer
+unless the same version of it (by PayloadUUID) is already
+installed. A profile with the same identifier but a different
+version is replaced.
+`),
+		Args: cobra.ExactArgs(1),
+	}
+
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", profilesDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Installing profiles for the computer requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			product := contextual.Product(ctx)
+			if product == nil {
+				return errors.New("product required in context")
+			}
+
+			return installProfile(ctx, systemProfiles{}, product, args[0], dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// installProfile installs the configuration profile at path unless the same version of it is already installed.
+func installProfile(ctx context.Context, m profileManager, p *system.Product, path string, dryrun bool) error {
+	file, err := profiles.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	fields := logrus.Fields{"identifier": file.Identifier, "uuid": file.UUID}
+
+	installed, err := m.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, profile := range installed {
+		if profile.User == "" && profile.Identifier == file.Identifier && profile.UUID == file.UUID {
+			logrus.WithFields(fields).Info("Profile already installed, nothing to do")
+			return nil
+		}
+	}
+
+	if dryrun {
+		logrus.WithFields(fields).Warn("Would have installed profile")
+		return nil
+	}
+
+	logrus.WithFields(fields).Info("Installing profile...")
+	if err := m.Install(ctx, p, path); errors.Is(err, profiles.ErrMDMRequired) {
+		return fmt.Errorf("cannot install %s on %s, deploy it with MDM or approve it in System Settings instead: %w", file.Identifier, p, err)
+	} else if err != nil {
+		return err
+	}
+	logrus.WithFields(fields).Info("Successfully installed profile")
+
+	return nil
+}
+
+// profilesRemoveCommand creates a new command which removes a configuration profile.
+func profilesRemoveCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove <identifier>",
+		Short: "remove a configuration profile",
+		Long: strings.TrimSpace(`
+remove removes the installed configuration profile with the
+identifier (e.g. com.example.fleet.rootca). Profiles installed by
+MDM can only be removed by the MDM server.
+`),
+		Args: cobra.ExactArgs(1),
+	}
+
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", profilesDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Removing profiles requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			return removeProfile(ctx, systemProfiles{}, args[0], dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// removeProfile removes the installed configuration profile with the identifier, if there is one.
+func removeProfile(ctx context.Context, m profileManager, identifier string, dryrun bool) error {
+	installed, err := m.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	var found *profiles.Profile
+	for i := range installed {
+		if installed[i].Identifier == identifier {
+			found = &installed[i]
+			break
+		}
+	}
+	if found == nil {
+		logrus.WithField("identifier", identifier).Info("Profile not installed, nothing to do")
+		return nil
+	}
+	if !found.Removable {
+		return fmt.Errorf("cannot remove %s, it can only be removed by the MDM server that installed it: %w", identifier, profiles.ErrMDMRequired)
+	}
+
+	if dryrun {
+		logrus.WithField("identifier", identifier).Warn("Would have removed profile")
+		return nil
+	}
+
+	if err := m.Remove(ctx, identifier); err != nil {
+		return err
+	}
+	logrus.WithField("identifier", identifier).Info("Successfully removed profile")
+
+	return nil
+}
+
+// printProfiles writes a table of the profiles to w.
+func printProfiles(w io.Writer, installed []profiles.Profile) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "IDENTIFIER\tNAME\tUSER\tREMOVABLE\tPAYLOADS")
+	for _, p := range installed {
+		user := p.User
+		if user == "" {
+			user = "(computer)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\n", p.Identifier, p.DisplayName, user, p.Removable, strings.Join(p.PayloadTypes, ","))
+	}
+
+	return tw.Flush()
+}
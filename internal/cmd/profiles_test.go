@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/profiles"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// fakeProfiles is a profileManager that records the profiles it installs and removes.
+type fakeProfiles struct {
+	installed  []profiles.Profile
+	installErr error
+	installs   []string
+	removes    []string
+}
+
+func (f *fakeProfiles) List(ctx context.Context) ([]profiles.Profile, error) {
+	return f.installed, nil
+}
+
+func (f *fakeProfiles) Install(ctx context.Context, p *system.Product, path string) error {
+	if f.installErr != nil {
+		return f.installErr
+	}
+	f.installs = append(f.installs, path)
+
+	return nil
+}
+
+func (f *fakeProfiles) Remove(ctx context.Context, identifier string) error {
+	f.removes = append(f.removes, identifier)
+
+	return nil
+}
+
+// writeProfile writes a configuration profile with the identifier and UUID to a temporary directory.
+func writeProfile(t *testing.T, identifier, uuid string) string {
+	path := filepath.Join(t.TempDir(), "profile.mobileconfig")
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>PayloadIdentifier</key>
+	<string>` + identifier + `</string>
+	<key>PayloadType</key>
+	<string>Configuration</string>
+	<key>PayloadUUID</key>
+	<string>` + uuid + `</string>
+</dict>
+</plist>
+`
+	assert.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+	return path
+}
+
+func TestInstallProfile(t *testing.T) {
+	installed := []profiles.Profile{{Identifier: "com.example.fleet.rootca", UUID: "A"}}
+	tests := []struct {
+		name         string
+		uuid         string
+		dryrun       bool
+		wantInstalls int
+	}{
+		{name: "same version", uuid: "A"},
+		{name: "new version", uuid: "B", wantInstalls: 1},
+		{name: "dry run", uuid: "B", dryrun: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &fakeProfiles{installed: installed}
+			path := writeProfile(t, "com.example.fleet.rootca", tt.uuid)
+
+			err := installProfile(context.Background(), m, &system.Product{Release: system.Catalina}, path, tt.dryrun)
+
+			assert.NoError(t, err)
+			assert.Len(t, m.installs, tt.wantInstalls)
+		})
+	}
+}
+
+func TestInstallProfile_MDMRequired(t *testing.T) {
+	m := &fakeProfiles{installErr: profiles.ErrMDMRequired}
+	path := writeProfile(t, "com.example.fleet.rootca", "A")
+
+	err := installProfile(context.Background(), m, &system.Product{Release: system.Ventura}, path, false)
+
+	assert.True(t, errors.Is(err, profiles.ErrMDMRequired))
+}
+
+func TestRemoveProfile(t *testing.T) {
+	m := &fakeProfiles{installed: []profiles.Profile{
+		{Identifier: "com.example.fleet.rootca", Removable: true},
+		{Identifier: "com.example.mdm"},
+	}}
+
+	assert.NoError(t, removeProfile(context.Background(), m, "com.example.fleet.rootca", false))
+	assert.NoError(t, removeProfile(context.Background(), m, "com.example.missing", false))
+	err := removeProfile(context.Background(), m, "com.example.mdm", false)
+
+	assert.True(t, errors.Is(err, profiles.ErrMDMRequired), "profiles installed by MDM shouldn't be removable")
+	assert.Equal(t, []string{"com.example.fleet.rootca"}, m.removes)
+}
+
+func TestPrintProfiles(t *testing.T) {
+	var buf bytes.Buffer
+	installed := []profiles.Profile{
+		{Identifier: "com.example.fleet.rootca", DisplayName: "Root CA", Removable: true, PayloadTypes: []string{"com.apple.security.root"}},
+	}
+
+	assert.NoError(t, printProfiles(&buf, installed))
+	assert.Equal(t, "IDENTIFIER                NAME     USER        REMOVABLE  PAYLOADS\n"+
+		"com.example.fleet.rootca  Root CA  (computer)  true       com.apple.security.root\n", buf.String())
+}
@@ -0,0 +1,354 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/internal/network"
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+// proxyDefaultTimeout is the default maximum run duration for checking and applying proxy settings.
+const proxyDefaultTimeout = time.Minute
+
+// proxySettings is a struct for holding all information passed into the proxy subcommands.
+type proxySettings struct {
+	http       string
+	https      string
+	bypass     []string
+	services   []string
+	launchdEnv bool
+	timeout    time.Duration
+}
+
+// serviceProxy is the proxy configuration of a network service reported by proxy status.
+type serviceProxy struct {
+	Service string         `json:"service"`
+	HTTP    *network.Proxy `json:"http"`
+	HTTPS   *network.Proxy `json:"https"`
+	Bypass  []string       `json:"bypass"`
+}
+
+// proxyCommand creates a new command which groups the proxy subcommands.
+func proxyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "proxy",
+		Short: "manage the system-wide HTTP and HTTPS proxies",
+		Long: strings.TrimSpace(`
+proxy manages the HTTP and HTTPS proxies, and the hosts and
+domains that bypass them, of the system's network services with
+networksetup, for instances in VPCs whose egress goes through a
+proxy. All services are configured unless services are named.
+Proxies are given as host:port, or off to disable them.
+
+Daemons run by launchd don't read the system's proxy settings,
+so the matching HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment
+variables can also be set for launchd's services at boot by a
+launchd job installed with --launchd-env. NO_PROXY always
+includes the loopback addresses, the instance metadata service,
+and the Amazon Time Sync Service.
+
+Settings are read from the network section's proxy entry of the
+configuration file and can be overridden with flags.
+`),
+	}
+
+	cmd.AddCommand(proxyStatusCommand(), proxyCheckCommand(), proxyApplyCommand(), proxyEnvCommand())
+
+	return cmd
+}
+
+// addProxyFlags adds the flags used to override the configured settings to the command.
+func addProxyFlags(cmd *cobra.Command, args *proxySettings) {
+	cmd.PersistentFlags().StringVar(&args.http, "http", "", "proxy used for HTTP as host:port, or off to disable it")
+	cmd.PersistentFlags().StringVar(&args.https, "https", "", "proxy used for HTTPS as host:port, or off to disable it")
+	cmd.PersistentFlags().StringSliceVar(&args.bypass, "bypass", nil, "host or domain (e.g. *.internal) that bypasses the proxies, may be repeated")
+	cmd.PersistentFlags().StringSliceVar(&args.services, "service", nil, "network service to configure, may be repeated, all services when unset")
+	cmd.PersistentFlags().BoolVar(&args.launchdEnv, "launchd-env", false, "set the proxy environment variables for launchd's services at boot")
+	cmd.PersistentFlags().DurationVar(&args.timeout, "timeout", proxyDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+}
+
+// proxyStatusCommand creates a new command which reports the proxies of each service.
+func proxyStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "report the proxies of each network service",
+		Args:  cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", proxyDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			services, err := proxyServices(ctx, nil)
+			if err != nil {
+				return err
+			}
+
+			statuses := make([]serviceProxy, 0, len(services))
+			for _, service := range services {
+				s := serviceProxy{Service: service}
+				if s.HTTP, err = network.GetProxy(ctx, service, network.WebProxy); err != nil {
+					return err
+				}
+				if s.HTTPS, err = network.GetProxy(ctx, service, network.SecureWebProxy); err != nil {
+					return err
+				}
+				if s.Bypass, err = network.ProxyBypassDomains(ctx, service); err != nil {
+					return err
+				}
+				if s.Bypass == nil {
+					s.Bypass = []string{}
+				}
+				statuses = append(statuses, s)
+			}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), statuses, func(w io.Writer) error {
+				return printProxyStatus(w, statuses)
+			})
+		})
+	}
+
+	return cmd
+}
+
+// proxyCheckCommand creates a new command which reports drift from the desired proxy settings.
+func proxyCheckCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "report drift from the desired proxy settings",
+		Args:  cobra.NoArgs,
+	}
+
+	proxyArgs := proxySettings{}
+	addProxyFlags(cmd, &proxyArgs)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runProxy(cmd, proxyArgs, func(ctx context.Context, conf config.Proxy) error {
+			t, err := proxyTask(ctx, conf)
+			if err != nil {
+				return err
+			}
+
+			return checkTask(ctx, cmd, t)
+		})
+	}
+
+	return cmd
+}
+
+// proxyApplyCommand creates a new command which applies the desired proxy settings.
+func proxyApplyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "apply the desired proxy settings",
+		Args:  cobra.NoArgs,
+	}
+
+	proxyArgs := proxySettings{}
+	addProxyFlags(cmd, &proxyArgs)
+	var dryrun bool
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+
+	// Changing network services' settings with networksetup and installing launchd jobs requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runProxy(cmd, proxyArgs, func(ctx context.Context, conf config.Proxy) error {
+			t, err := proxyTask(ctx, conf)
+			if err != nil {
+				return err
+			}
+
+			return applyTask(ctx, cmd, t, dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// proxyEnvCommand creates a new command which prints the proxy environment variables for shells.
+func proxyEnvCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "env",
+		Short: "print the proxy environment variables",
+		Long: strings.TrimSpace(`
+env prints the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment
+variables matching the desired proxy settings as export
+statements, e.g. for eval in a shell or a CI job's environment.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	proxyArgs := proxySettings{}
+	addProxyFlags(cmd, &proxyArgs)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runProxy(cmd, proxyArgs, func(ctx context.Context, conf config.Proxy) error {
+			env, err := proxyEnvironment(conf)
+			if err != nil {
+				return err
+			}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), env, func(w io.Writer) error {
+				return printProxyEnv(w, env)
+			})
+		})
+	}
+
+	return cmd
+}
+
+// runProxy merges the configuration with the flags and runs fn with the desired settings.
+func runProxy(cmd *cobra.Command, args proxySettings, fn func(ctx context.Context, conf config.Proxy) error) error {
+	return runUserCommand(cmd, args.timeout, func(ctx context.Context) error {
+		c, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		conf := proxyConfig(cmd, c.Network.Proxy, args)
+		if conf.HTTP == "" && conf.HTTPS == "" && conf.Bypass == nil {
+			return errors.New("no proxy settings configured, set them in the configuration file or with --http and --https")
+		}
+
+		return fn(ctx, conf)
+	})
+}
+
+// proxyConfig merges the configured settings with the flags that were set. Flags take precedence over the
+// configuration.
+func proxyConfig(cmd *cobra.Command, conf config.Proxy, args proxySettings) config.Proxy {
+	if cmd.Flags().Changed("http") {
+		conf.HTTP = args.http
+	}
+	if cmd.Flags().Changed("https") {
+		conf.HTTPS = args.https
+	}
+	if cmd.Flags().Changed("bypass") {
+		conf.Bypass = args.bypass
+	}
+	if cmd.Flags().Changed("service") {
+		conf.Services = args.services
+	}
+	if cmd.Flags().Changed("launchd-env") {
+		conf.LaunchdEnvironment = args.launchdEnv
+	}
+
+	return conf
+}
+
+// proxyTask builds the task that applies the proxy settings to the services, and installs the job that sets the
+// environment variables for launchd's services when it's enabled.
+func proxyTask(ctx context.Context, conf config.Proxy) (task.Task, error) {
+	http, err := parseProxySetting(conf.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	https, err := parseProxySetting(conf.HTTPS)
+	if err != nil {
+		return nil, err
+	}
+	services, err := proxyServices(ctx, conf.Services)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &network.ProxyTask{Services: services, HTTP: http, HTTPS: https, Bypass: conf.Bypass}
+	if !conf.LaunchdEnvironment {
+		return t, nil
+	}
+
+	return task.NewGroup("proxy", t, &network.ProxyEnvTask{
+		Manager: launchd.NewDaemonManager(),
+		Env:     network.ProxyEnvironment(http, https, conf.Bypass),
+	}), nil
+}
+
+// proxyEnvironment gets the environment variables matching the proxy settings.
+func proxyEnvironment(conf config.Proxy) (map[string]string, error) {
+	http, err := parseProxySetting(conf.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	https, err := parseProxySetting(conf.HTTPS)
+	if err != nil {
+		return nil, err
+	}
+
+	return network.ProxyEnvironment(http, https, conf.Bypass), nil
+}
+
+// parseProxySetting parses a configured proxy, which is nil when it isn't set so that it's left as it is.
+func parseProxySetting(addr string) (*network.Proxy, error) {
+	if addr == "" {
+		return nil, nil
+	}
+
+	return network.ParseProxy(addr)
+}
+
+// proxyServices gets the named services, or the names of all of the system's services ordered by device when none
+// are named.
+func proxyServices(ctx context.Context, named []string) ([]string, error) {
+	if len(named) != 0 {
+		return named, nil
+	}
+
+	services, err := network.Services(ctx)
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]string, 0, len(services))
+	for device := range services {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+
+	names := make([]string, 0, len(devices))
+	for _, device := range devices {
+		names = append(names, services[device])
+	}
+
+	return names, nil
+}
+
+// printProxyStatus writes a table of the services' proxies to w.
+func printProxyStatus(w io.Writer, statuses []serviceProxy) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tHTTP\tHTTPS\tBYPASS")
+	for _, s := range statuses {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Service, s.HTTP, s.HTTPS, joinOrDash(s.Bypass))
+	}
+
+	return tw.Flush()
+}
+
+// printProxyEnv writes the environment variables to w as export statements, sorted by name.
+func printProxyEnv(w io.Writer, env map[string]string) error {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		// Values are single quoted so that the shell doesn't expand them.
+		if _, err := fmt.Fprintf(w, "export %s='%s'\n", k, strings.ReplaceAll(env[k], "'", `'\''`)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
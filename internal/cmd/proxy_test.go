@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+)
+
+func TestProxyConfig_FlagsOverrideConfig(t *testing.T) {
+	proxyArgs := proxySettings{}
+	cmd := &cobra.Command{}
+	addProxyFlags(cmd, &proxyArgs)
+	assert.NoError(t, cmd.ParseFlags([]string{"--https", "off", "--service", "Ethernet"}))
+
+	conf := proxyConfig(cmd, config.Proxy{
+		HTTP:               "proxy.example.com:3128",
+		HTTPS:              "proxy.example.com:3128",
+		Bypass:             []string{"*.internal"},
+		LaunchdEnvironment: true,
+	}, proxyArgs)
+
+	assert.Equal(t, config.Proxy{
+		HTTP:               "proxy.example.com:3128",
+		HTTPS:              "off",
+		Bypass:             []string{"*.internal"},
+		Services:           []string{"Ethernet"},
+		LaunchdEnvironment: true,
+	}, conf, "flags should only override the settings they set")
+}
+
+func TestParseProxySetting(t *testing.T) {
+	p, err := parseProxySetting("")
+	assert.NoError(t, err)
+	assert.Nil(t, p, "unset proxies should be left as they are")
+
+	p, err = parseProxySetting("off")
+	assert.NoError(t, err)
+	assert.False(t, p.Enabled)
+}
+
+func TestPrintProxyEnv(t *testing.T) {
+	var buf bytes.Buffer
+	err := printProxyEnv(&buf, map[string]string{"http_proxy": "http://proxy:3128", "HTTP_PROXY": "http://proxy:3128"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "export HTTP_PROXY='http://proxy:3128'\nexport http_proxy='http://proxy:3128'\n", buf.String())
+}
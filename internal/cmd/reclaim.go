@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/internal/purgeable"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+)
+
+// reclaimDefaultTimeout is the default maximum run duration for accounting for and reclaiming purgeable space.
+const reclaimDefaultTimeout = 10 * time.Minute
+
+const (
+	// systemVolumesDir is the directory that the system's helper volumes (e.g. Preboot and VM) are mounted in.
+	systemVolumesDir = "/System/Volumes/"
+	// dataVolumePath is where the data volume is mounted on macOS releases with a read-only system volume.
+	dataVolumePath = "/System/Volumes/Data"
+)
+
+// reclaimSpace is a struct for holding all information passed into the reclaim command.
+type reclaimSpace struct {
+	dryrun    bool
+	volumes   []string
+	snapshots bool
+	caches    bool
+	timeout   time.Duration
+}
+
+// reclaimResult is the outcome of the reclaim command.
+type reclaimResult struct {
+	Volumes          []purgeable.Volume `json:"volumes"`
+	ThinnedSnapshots []string           `json:"thinned_snapshots,omitempty"`
+	PurgedCaches     uint64             `json:"purged_caches,omitempty"`
+}
+
+// reclaimCommand creates a new command which reports and reclaims the purgeable space on APFS volumes.
+func reclaimCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reclaim",
+		Short: "report and reclaim purgeable space",
+		Long: strings.TrimSpace(`
+reclaim reports the purgeable space of each APFS volume: the
+space that macOS counts as free because it can be freed on
+demand, which is why the free space shown by df regularly
+disagrees with the free space reported by diskutil and the
+Finder. The snapshots of each volume and the space used by the
+system caches are reported along with it. Local Time Machine
+snapshots are thinned with --snapshots and the system caches
+are purged with --caches before the space is reported.
+Snapshots created by other tools are never deleted.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	reclaimArgs := reclaimSpace{}
+	cmd.PersistentFlags().StringSliceVar(&reclaimArgs.volumes, "volume", nil, "mount point of a volume to reclaim space on, defaults to the writable APFS volumes")
+	cmd.PersistentFlags().BoolVar(&reclaimArgs.snapshots, "snapshots", false, "thin the local Time Machine snapshots")
+	cmd.PersistentFlags().BoolVar(&reclaimArgs.caches, "caches", false, "purge the contents of the system caches")
+	cmd.PersistentFlags().BoolVar(&reclaimArgs.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&reclaimArgs.timeout, "timeout", reclaimDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Thinning snapshots and purging the system caches requires root permissions, reporting doesn't.
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if reclaimArgs.snapshots || reclaimArgs.caches {
+			return assertRootPrivileges(cmd, args)
+		}
+
+		return nil
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, reclaimArgs.timeout, func(ctx context.Context) error {
+			product := contextual.Product(ctx)
+			if product == nil {
+				return errors.New("product required in context")
+			}
+
+			d, err := diskutil.ForProduct(product)
+			if err != nil {
+				return err
+			}
+
+			result, err := runReclaim(ctx, d, reclaimArgs)
+			if err != nil {
+				return err
+			}
+
+			return printReclaimResult(cmd.OutOrStdout(), outputFormat(cmd), result)
+		})
+	}
+
+	return cmd
+}
+
+// runReclaim reclaims the purgeable space that was asked for and then accounts for the purgeable space remaining on
+// each volume. Nothing is reclaimed in dry-run mode.
+func runReclaim(ctx context.Context, utility diskutil.DiskUtil, args reclaimSpace) (*reclaimResult, error) {
+	volumes := args.volumes
+	if len(volumes) == 0 {
+		filesystems, err := mounts.Mounted()
+		if err != nil {
+			return nil, fmt.Errorf("cannot list mounted volumes: %w", err)
+		}
+		volumes = reclaimVolumes(filesystems)
+	}
+
+	result := &reclaimResult{}
+	if args.snapshots {
+		for _, v := range volumes {
+			if args.dryrun {
+				logrus.WithField("mount_point", v).Warn("Would have thinned local snapshots")
+				continue
+			}
+
+			logrus.WithField("mount_point", v).Info("Thinning local snapshots...")
+			thinned, err := purgeable.ThinSnapshots(ctx, v)
+			if err != nil {
+				return nil, err
+			}
+			result.ThinnedSnapshots = append(result.ThinnedSnapshots, thinned...)
+		}
+	}
+
+	if args.caches {
+		if args.dryrun {
+			logrus.WithField("dirs", purgeable.CacheDirs).Warn("Would have purged system caches")
+		} else {
+			logrus.WithField("dirs", purgeable.CacheDirs).Info("Purging system caches...")
+			freed, err := purgeable.PurgeCaches(ctx, purgeable.CacheDirs)
+			result.PurgedCaches = freed
+			if err != nil {
+				logrus.WithError(err).Warn("Some system caches couldn't be purged")
+			}
+		}
+	}
+
+	for _, v := range volumes {
+		volume, err := purgeable.Account(ctx, utility, v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot account for purgeable space of %s: %w", v, err)
+		}
+		result.Volumes = append(result.Volumes, *volume)
+	}
+
+	return result, nil
+}
+
+// reclaimVolumes gets the mount points of the writable, local APFS volumes that can hold purgeable space. The
+// system's helper volumes are left out, other than the data volume which holds the users' data and the caches.
+func reclaimVolumes(filesystems []mounts.Filesystem) []string {
+	var volumes []string
+	for _, fs := range filesystems {
+		if fs.Type != "apfs" || !fs.Local || fs.ReadOnly {
+			continue
+		}
+		if strings.HasPrefix(fs.MountPoint, systemVolumesDir) && fs.MountPoint != dataVolumePath {
+			continue
+		}
+		volumes = append(volumes, fs.MountPoint)
+	}
+
+	return volumes
+}
+
+// printReclaimResult writes a table of the volumes' purgeable space, and what was reclaimed, to w.
+func printReclaimResult(w io.Writer, format string, result *reclaimResult) error {
+	return printOutput(w, format, result, func(w io.Writer) error {
+		if len(result.ThinnedSnapshots) > 0 {
+			fmt.Fprintf(w, "Thinned %d local snapshot(s)\n", len(result.ThinnedSnapshots))
+		}
+		if result.PurgedCaches > 0 {
+			fmt.Fprintf(w, "Purged %s of system caches\n", humanize.IBytes(result.PurgedCaches))
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VOLUME\tFREE\tAVAILABLE\tPURGEABLE\tSNAPSHOTS\tCACHES")
+		for _, v := range result.Volumes {
+			caches := "-"
+			if len(v.Caches) > 0 {
+				caches = humanize.IBytes(v.CacheSize())
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n", v.MountPoint, humanize.IBytes(v.FreeSpace), humanize.IBytes(v.AvailableSpace),
+				humanize.IBytes(v.Purgeable()), len(v.Snapshots), caches)
+		}
+
+		return tw.Flush()
+	})
+}
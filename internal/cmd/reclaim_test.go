@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/internal/purgeable"
+)
+
+func TestReclaimVolumes(t *testing.T) {
+	volumes := reclaimVolumes([]mounts.Filesystem{
+		{MountPoint: "/", Type: "apfs", Local: true, ReadOnly: true},
+		{MountPoint: "/dev", Type: "devfs", Local: true},
+		{MountPoint: "/System/Volumes/VM", Type: "apfs", Local: true},
+		{MountPoint: "/System/Volumes/Data", Type: "apfs", Local: true},
+		{MountPoint: "/Volumes/Data", Type: "apfs", Local: true},
+		{MountPoint: "/Volumes/Shared", Type: "exfat", Local: true},
+	})
+
+	assert.Equal(t, []string{"/System/Volumes/Data", "/Volumes/Data"}, volumes)
+}
+
+func TestPrintReclaimResult(t *testing.T) {
+	result := &reclaimResult{
+		Volumes: []purgeable.Volume{
+			{
+				MountPoint:     "/System/Volumes/Data",
+				FreeSpace:      60 << 30,
+				AvailableSpace: 40 << 30,
+				Snapshots:      []purgeable.Snapshot{{Name: "com.apple.TimeMachine.2023-08-01-120000.local"}},
+				Caches:         []purgeable.Cache{{Path: "/Library/Caches", Size: 512 << 20}},
+			},
+			{MountPoint: "/Volumes/Data", FreeSpace: 10 << 30, AvailableSpace: 10 << 30},
+		},
+		ThinnedSnapshots: []string{"2023-08-01-110000"},
+	}
+
+	var text bytes.Buffer
+	assert.NoError(t, printReclaimResult(&text, outputText, result))
+	assert.Equal(t, "Thinned 1 local snapshot(s)\n"+
+		"VOLUME                FREE    AVAILABLE  PURGEABLE  SNAPSHOTS  CACHES\n"+
+		"/System/Volumes/Data  60 GiB  40 GiB     20 GiB     1          512 MiB\n"+
+		"/Volumes/Data         10 GiB  10 GiB     0 B        0          -\n", text.String())
+
+	var out bytes.Buffer
+	assert.NoError(t, printReclaimResult(&out, outputJSON, result))
+	assert.Contains(t, out.String(), `"thinned_snapshots": [`)
+	assert.NotContains(t, out.String(), "purged_caches", "nothing was purged")
+}
@@ -2,9 +2,8 @@
 package cmd
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
@@ -12,6 +11,11 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/aws/ec2-macos-utils/internal/build"
+	"github.com/aws/ec2-macos-utils/internal/codesign"
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/preflight"
+	"github.com/aws/ec2-macos-utils/internal/selfupdate"
+	"github.com/aws/ec2-macos-utils/pkg/unifiedlog"
 )
 
 const shortLicenseText = "Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved."
@@ -22,6 +26,49 @@ func MainCommand() *cobra.Command {
 
 	cmds := []*cobra.Command{
 		growContainerCommand(),
+		volumeCommand(),
+		scratchCommand(),
+		benchmarkCommand(),
+		reclaimCommand(),
+		imageCommand(),
+		mountsCommand(),
+		updatesCommand(),
+		updateCommand(),
+		devtoolsCommand(),
+		brewCommand(),
+		rosettaCommand(),
+		powerCommand(),
+		networkCommand(),
+		dnsCommand(),
+		proxyCommand(),
+		setupCommand(),
+		defaultsCommand(),
+		userCommand(),
+		sshCommand(),
+		sessionCommand(),
+		screenSharingCommand(),
+		keychainCommand(),
+		secretCommand(),
+		trustCommand(),
+		profilesCommand(),
+		firewallCommand(),
+		gatekeeperCommand(),
+		nvramCommand(),
+		startupdiskCommand(),
+		diskHealthCommand(),
+		diagnosticsCommand(),
+		doctorCommand(),
+		configCommand(),
+		driftCommand(),
+		applyCommand(),
+		metricsCommand(),
+		statusCommand(),
+		controlCommand(),
+		journalCommand(),
+		stateCommand(),
+		scheduleCommand(),
+		uninstallCommand(),
+		fixturesCommand(),
 	}
 	for i := range cmds {
 		cmd.AddCommand(cmds[i])
@@ -43,6 +90,7 @@ help text and usages that accompany them.
 `),
 		Version:           build.Version,
 		SilenceUsage:      true,
+		SilenceErrors:     true,
 		DisableAutoGenTag: true,
 	}
 
@@ -50,7 +98,13 @@ help text and usages that accompany them.
 	cmd.SetVersionTemplate(fmt.Sprintf(versionTemplate, build.CommitDate, shortLicenseText))
 
 	var verbose bool
+	var output string
+	var configPath string
+	var verifyBinary bool
 	cmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging output")
+	cmd.PersistentFlags().StringVarP(&output, "output", "o", outputText, "Set the output format of command results (text or json)")
+	cmd.PersistentFlags().StringVar(&configPath, "config", config.DefaultPath, "Set the path or S3 URI (s3://bucket/key) of the configuration file")
+	cmd.PersistentFlags().BoolVar(&verifyBinary, "verify-binary", false, "Refuse to run unless the executable's Developer ID code signature is valid")
 
 	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
 		level := logrus.InfoLevel
@@ -58,6 +112,24 @@ help text and usages that accompany them.
 			level = logrus.DebugLevel
 		}
 		setupLogging(level)
+		setupUnifiedLogging(cmd)
+		setupNotifications(cmd)
+
+		if verifyBinary {
+			if err := verifyExecutable(cmd.Context()); err != nil {
+				return err
+			}
+		}
+
+		if err := validateOutputFormat(output); err != nil {
+			return err
+		}
+
+		// State kept by earlier releases is migrated before the command uses it.
+		migrateState(cmd)
+
+		// Scheduled runs don't do anything while the kill switch pauses automation across the fleet.
+		skipScheduledRunIfPaused(cmd)
 
 		return nil
 	}
@@ -79,19 +151,59 @@ func setupLogging(level logrus.Level) {
 	logrus.SetFormatter(Formatter)
 }
 
-func hasRootPrivileges() bool {
-	return os.Geteuid() == 0
+// setupUnifiedLogging also writes logs to macOS's unified log, categorized by the command being run (e.g. "volume
+// provision"), so they can be followed with log stream alongside the system's events. Builds that can't write to the
+// unified log continue with the standard logs.
+func setupUnifiedLogging(cmd *cobra.Command) {
+	hook, err := unifiedlog.NewHook(unifiedlog.Subsystem, commandName(cmd))
+	if err != nil {
+		logrus.WithError(err).Debug("Not writing logs to the unified log")
+		return
+	}
+	logrus.AddHook(hook)
+}
+
+// verifyExecutable checks that the running executable is signed with a Developer ID and hasn't been modified since,
+// so that a tampered binary isn't run as root. Notarization isn't checked since it may require contacting Apple.
+func verifyExecutable(ctx context.Context) error {
+	exe, err := selfupdate.Executable()
+	if err != nil {
+		return err
+	}
+	s, err := codesign.Verify(ctx, exe, false)
+	if err != nil {
+		return err
+	}
+	if !s.Signed || s.AdHoc || s.Tampered() {
+		return fmt.Errorf("refusing to run %s, its code signature can't be trusted: %s", exe, s)
+	}
+	logrus.WithFields(logrus.Fields{"path": exe, "team_id": s.TeamID}).Debug("Verified executable's code signature")
+
+	return nil
 }
 
 // assertRootPrivileges checks if the command is running with root permissions.
-// If the command doesn't have root permissions, a help message is logged with
-// an example and an error is returned.
+// If the command doesn't have root permissions, a warning is logged and a
+// preflight.PermissionError is returned with how to fix it.
 func assertRootPrivileges(cmd *cobra.Command, args []string) error {
 	logrus.Debug("Checking user permissions...")
-	ok := hasRootPrivileges()
-	if !ok {
+	if err := preflight.Root(); err != nil {
 		logrus.Warn("Root privileges required")
-		return errors.New("root privileges required, re-run command with sudo")
+		return err
+	}
+
+	return nil
+}
+
+// assertDiskPrivileges checks if the command is running with the permissions
+// that operating on disks needs, which are root permissions and, on Apple
+// silicon, Full Disk Access. This is checked before any disk is changed
+// rather than having diskutil fail part of the way through.
+func assertDiskPrivileges(cmd *cobra.Command, args []string) error {
+	logrus.Debug("Checking user permissions for disk operations...")
+	if err := preflight.Disk(cmd.Context()); err != nil {
+		logrus.WithError(err).Warn("Missing permissions for disk operations")
+		return err
 	}
 
 	return nil
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/rosetta"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// rosettaDefaultTimeout is the default maximum run duration for Rosetta commands, which download Rosetta when it's
+// installed.
+const rosettaDefaultTimeout = 10 * time.Minute
+
+// rosettaSystem detects and installs Rosetta.
+type rosettaSystem interface {
+	// AppleSilicon checks if the system has an Apple silicon processor.
+	AppleSilicon(ctx context.Context) (bool, error)
+	// Installed checks if Rosetta is installed.
+	Installed(ctx context.Context) (bool, error)
+	// Install installs Rosetta.
+	Install(ctx context.Context) error
+}
+
+// hostRosetta detects and installs Rosetta on the system.
+type hostRosetta struct{}
+
+func (hostRosetta) AppleSilicon(ctx context.Context) (bool, error) {
+	return system.AppleSilicon(ctx)
+}
+
+func (hostRosetta) Installed(ctx context.Context) (bool, error) {
+	return rosetta.Installed(ctx)
+}
+
+func (hostRosetta) Install(ctx context.Context) error {
+	return rosetta.Install(ctx)
+}
+
+// rosettaStatus is the state of Rosetta on the system.
+type rosettaStatus struct {
+	// AppleSilicon indicates that the system has an Apple silicon processor, which needs Rosetta for Intel binaries.
+	AppleSilicon bool `json:"apple_silicon"`
+	// Installed indicates that Rosetta is installed, which is always false on Intel systems.
+	Installed bool `json:"installed"`
+}
+
+// rosettaCommand creates a new command which groups the Rosetta subcommands.
+func rosettaCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rosetta",
+		Short: "manage Rosetta 2 on Apple silicon",
+		Long: strings.TrimSpace(`
+rosetta checks for and installs Rosetta 2, which runs Intel
+(x86_64) binaries on Apple silicon. Fresh mac2 instances don't
+have Rosetta installed so Intel toolchains fail to run with "Bad
+CPU type in executable" until it is. Intel instances run these
+binaries natively and never need Rosetta.
+`),
+	}
+
+	cmd.AddCommand(rosettaStatusCommand(), rosettaInstallCommand())
+
+	return cmd
+}
+
+// rosettaStatusCommand creates a new command which reports whether Rosetta is installed.
+func rosettaStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "report whether Rosetta is installed",
+		Args:  cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", rosettaDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			status, err := getRosettaStatus(ctx, hostRosetta{})
+			if err != nil {
+				return err
+			}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), status, func(w io.Writer) error {
+				state := "not installed"
+				switch {
+				case !status.AppleSilicon:
+					state = "not needed on Intel"
+				case status.Installed:
+					state = "installed"
+				}
+				_, err := fmt.Fprintf(w, "Rosetta: %s\n", state)
+				return err
+			})
+		})
+	}
+
+	return cmd
+}
+
+// rosettaInstallCommand creates a new command which installs Rosetta.
+func rosettaInstallCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "install Rosetta without prompting",
+		Long: strings.TrimSpace(`
+install installs Rosetta with softwareupdate(8), agreeing to its
+license on behalf of the user. Nothing is done on Intel instances
+or when Rosetta is already installed.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", rosettaDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Installing software with softwareupdate requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			return installRosetta(ctx, hostRosetta{}, dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// getRosettaStatus detects the processor and whether Rosetta is installed.
+func getRosettaStatus(ctx context.Context, r rosettaSystem) (*rosettaStatus, error) {
+	appleSilicon, err := r.AppleSilicon(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot detect processor: %w", err)
+	}
+	if !appleSilicon {
+		return &rosettaStatus{}, nil
+	}
+
+	installed, err := r.Installed(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rosettaStatus{AppleSilicon: true, Installed: installed}, nil
+}
+
+// installRosetta installs Rosetta on Apple silicon when it isn't already installed.
+func installRosetta(ctx context.Context, r rosettaSystem, dryrun bool) error {
+	status, err := getRosettaStatus(ctx, r)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !status.AppleSilicon:
+		logrus.Info("Rosetta isn't needed on Intel, nothing to do")
+		return nil
+	case status.Installed:
+		logrus.Info("Rosetta already installed, nothing to do")
+		return nil
+	case dryrun:
+		logrus.Warn("Would have installed Rosetta")
+		return nil
+	}
+
+	logrus.Info("Installing Rosetta...")
+	if err := r.Install(ctx); err != nil {
+		return err
+	}
+	logrus.Info("Successfully installed Rosetta")
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRosetta is a system whose processor and Rosetta installation are fixed.
+type fakeRosetta struct {
+	appleSilicon bool
+	installed    bool
+	installs     int
+}
+
+func (f *fakeRosetta) AppleSilicon(ctx context.Context) (bool, error) {
+	return f.appleSilicon, nil
+}
+
+func (f *fakeRosetta) Installed(ctx context.Context) (bool, error) {
+	return f.installed, nil
+}
+
+func (f *fakeRosetta) Install(ctx context.Context) error {
+	f.installs++
+	f.installed = true
+
+	return nil
+}
+
+func TestInstallRosetta(t *testing.T) {
+	tests := []struct {
+		name         string
+		appleSilicon bool
+		installed    bool
+		dryrun       bool
+		wantInstalls int
+	}{
+		{name: "Apple silicon without Rosetta", appleSilicon: true, wantInstalls: 1},
+		{name: "Apple silicon with Rosetta", appleSilicon: true, installed: true},
+		{name: "Intel", installed: true},
+		{name: "dry run", appleSilicon: true, dryrun: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fakeRosetta{appleSilicon: tt.appleSilicon, installed: tt.installed}
+
+			err := installRosetta(context.Background(), r, tt.dryrun)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantInstalls, r.installs)
+		})
+	}
+}
+
+func TestGetRosettaStatus_Intel(t *testing.T) {
+	status, err := getRosettaStatus(context.Background(), &fakeRosetta{installed: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &rosettaStatus{}, status, "Intel systems don't report Rosetta as installed")
+}
@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/internal/schedule"
+	"github.com/aws/ec2-macos-utils/internal/selfupdate"
+)
+
+// scheduleDefaultTimeout is the default maximum run duration for managing schedules.
+const scheduleDefaultTimeout = time.Minute
+
+// scheduleStatus is an installed schedule and the state of its job.
+type scheduleStatus struct {
+	*schedule.Schedule
+	// State is the state of the schedule's job (e.g. "running", "not running", or "not loaded").
+	State string `json:"state"`
+	// LastExitCode is the exit status of the last run.
+	LastExitCode string `json:"last_exit_code,omitempty"`
+}
+
+// scheduleCommand creates a new command which groups the schedule subcommands.
+func scheduleCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "run subcommands on a schedule",
+		Long: strings.TrimSpace(`
+schedule installs launchd daemons that run any of the utility's
+subcommands on an interval (e.g. growing the root container
+hourly) or at calendar times (e.g. nightly snapshot thinning). Each run's output is
+written to /var/log/ec2-macos-utils/schedule.<name>.log.
+Scheduled runs are skipped while an instance tag or Parameter
+Store parameter pauses automation, see kill-switch.
+`),
+	}
+
+	cmd.AddCommand(scheduleAddCommand(), scheduleListCommand(), scheduleRemoveCommand(), scheduleKillSwitchCommand())
+
+	return cmd
+}
+
+// scheduleAddCommand creates a new command which schedules a subcommand.
+func scheduleAddCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add <name> <subcommand> [args...]",
+		Short: "schedule a subcommand",
+		Long: strings.TrimSpace(`
+add schedules the subcommand, with its arguments, to run as root
+either every --every interval or whenever the time matches --at.
+Calendar times are a time of day (e.g. "03:30") optionally
+preceded by a weekday (e.g. "sun 03:30") or a day of the month
+(e.g. "1 03:30"), or one of "hourly", "daily", and "weekly".
+Adding a schedule with an existing name replaces it.
+
+Flags after the subcommand are passed to it, for example:
+
+  ec2-macos-utils schedule add --at daily thin reclaim --snapshots
+`),
+		Args: cobra.MinimumNArgs(2),
+	}
+	// Flags after the name belong to the scheduled subcommand.
+	cmd.Flags().SetInterspersed(false)
+
+	var every time.Duration
+	var at string
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&every, "every", 0, "run the subcommand on this interval (e.g. 15m, 1h)")
+	cmd.PersistentFlags().StringVar(&at, "at", "", "run the subcommand at this calendar time (e.g. 03:30, sun 03:30, daily)")
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", scheduleDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Installing launchd daemons requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			s, err := newSchedule(cmd.Root(), args[0], args[1:], every, at)
+			if err != nil {
+				return err
+			}
+			executable, err := selfupdate.Executable()
+			if err != nil {
+				return err
+			}
+
+			return addSchedule(ctx, launchd.NewDaemonManager(), s, executable, dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// newSchedule builds the schedule for the subcommand of root, checking that the subcommand exists and that exactly
+// one of every and at is set.
+func newSchedule(root *cobra.Command, name string, args []string, every time.Duration, at string) (*schedule.Schedule, error) {
+	if (every == 0) == (at == "") {
+		return nil, errors.New("exactly one of --every and --at is required")
+	}
+
+	sub, _, err := root.Find(args)
+	if err != nil {
+		return nil, fmt.Errorf("cannot schedule %q: %w", strings.Join(args, " "), err)
+	}
+	if sub == root || !sub.Runnable() {
+		return nil, fmt.Errorf("cannot schedule %q: not a runnable subcommand", strings.Join(args, " "))
+	}
+	if strings.HasPrefix(sub.CommandPath(), root.Name()+" schedule") {
+		return nil, errors.New("cannot schedule the schedule commands")
+	}
+
+	s := &schedule.Schedule{Name: name, Args: args, Interval: every}
+	if at != "" {
+		if s.Calendar, err = schedule.ParseCalendar(at); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, s.Validate()
+}
+
+// addSchedule installs the schedule's job, which runs the utility at executable.
+func addSchedule(ctx context.Context, m *launchd.Manager, s *schedule.Schedule, executable string, dryrun bool) error {
+	job, err := s.Job(executable)
+	if err != nil {
+		return err
+	}
+	fields := logrus.Fields{
+		"name":    s.Name,
+		"command": strings.Join(s.Args, " "),
+		"label":   job.Label,
+	}
+	if dryrun {
+		logrus.WithFields(fields).Warn("Would have scheduled subcommand")
+		return nil
+	}
+
+	if err := os.MkdirAll(schedule.LogDir, 0755); err != nil {
+		return fmt.Errorf("cannot create log directory: %w", err)
+	}
+	changed, err := m.Install(ctx, job)
+	if err != nil {
+		return fmt.Errorf("cannot install launchd job: %w", err)
+	}
+	if !changed {
+		logrus.WithFields(fields).Info("Subcommand already scheduled, nothing to do")
+		return nil
+	}
+	logrus.WithFields(fields).Info("Successfully scheduled subcommand")
+
+	return nil
+}
+
+// scheduleListCommand creates a new command which lists the installed schedules.
+func scheduleListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list the scheduled subcommands",
+		Args:  cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", scheduleDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			m := launchd.NewDaemonManager()
+			schedules, err := schedule.List(m)
+			if err != nil {
+				return err
+			}
+
+			statuses := make([]scheduleStatus, 0, len(schedules))
+			for _, s := range schedules {
+				status := scheduleStatus{Schedule: s, State: "not loaded"}
+				if st, err := m.Status(ctx, schedule.Label(s.Name)); err == nil {
+					status.State, status.LastExitCode = st.State, st.LastExitCode
+				} else if !errors.Is(err, launchd.ErrNotLoaded) {
+					return err
+				}
+				statuses = append(statuses, status)
+			}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), statuses, func(w io.Writer) error {
+				return printSchedules(w, statuses)
+			})
+		})
+	}
+
+	return cmd
+}
+
+// scheduleRemoveCommand creates a new command which removes a schedule.
+func scheduleRemoveCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove <name>",
+		Short: "remove a scheduled subcommand",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", scheduleDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Removing launchd daemons requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			m := launchd.NewDaemonManager()
+			if _, err := schedule.Read(m, args[0]); errors.Is(err, schedule.ErrNotScheduled) {
+				logrus.WithField("name", args[0]).Info("Subcommand not scheduled, nothing to do")
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if dryrun {
+				logrus.WithField("name", args[0]).Warn("Would have removed schedule")
+				return nil
+			}
+
+			if _, err := m.Uninstall(ctx, schedule.Label(args[0])); err != nil {
+				return fmt.Errorf("cannot uninstall launchd job: %w", err)
+			}
+			logrus.WithField("name", args[0]).Info("Successfully removed schedule")
+
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+// printSchedules writes a table of the schedules to w.
+func printSchedules(w io.Writer, statuses []scheduleStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSCHEDULE\tSTATE\tCOMMAND")
+	for _, s := range statuses {
+		when := "every " + s.Interval.String()
+		if s.Calendar != nil {
+			when = "at " + schedule.FormatCalendar(s.Calendar)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Name, when, s.State, strings.Join(s.Args, " "))
+	}
+
+	return tw.Flush()
+}
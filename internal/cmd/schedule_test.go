@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/schedule"
+)
+
+func TestNewSchedule(t *testing.T) {
+	root := MainCommand()
+
+	s, err := newSchedule(root, "trim", []string{"doctor", "--timeout", "5m"}, 0, "sun 03:30")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"doctor", "--timeout", "5m"}, s.Args)
+	assert.NotNil(t, s.Calendar)
+
+	_, err = newSchedule(root, "bogus", []string{"bogus"}, time.Hour, "")
+	assert.Error(t, err, "unknown subcommands should be rejected")
+
+	_, err = newSchedule(root, "group", []string{"volume"}, time.Hour, "")
+	assert.Error(t, err, "command groups can't be run")
+
+	_, err = newSchedule(root, "loop", []string{"schedule", "list"}, time.Hour, "")
+	assert.Error(t, err, "schedules shouldn't schedule themselves")
+
+	_, err = newSchedule(root, "both", []string{"doctor"}, time.Hour, "daily")
+	assert.Error(t, err, "only one of --every and --at should be allowed")
+}
+
+func TestPrintSchedules(t *testing.T) {
+	var buf bytes.Buffer
+	calendar, err := schedule.ParseCalendar("03:30")
+	assert.NoError(t, err)
+	statuses := []scheduleStatus{
+		{Schedule: &schedule.Schedule{Name: "metrics", Args: []string{"metrics"}, Interval: time.Hour}, State: "not running"},
+		{Schedule: &schedule.Schedule{Name: "doctor", Args: []string{"doctor"}, Calendar: calendar}, State: "not loaded"},
+	}
+
+	assert.NoError(t, printSchedules(&buf, statuses))
+	assert.Equal(t, "NAME     SCHEDULE      STATE        COMMAND\n"+
+		"metrics  every 1h0m0s  not running  metrics\n"+
+		"doctor   at 03:30      not loaded   doctor\n", buf.String())
+}
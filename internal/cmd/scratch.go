@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+)
+
+// scratchDefaultMountPoint is the default path that the scratch volume is mounted at.
+const scratchDefaultMountPoint = "/Volumes/Scratch"
+
+// provisionScratch is a struct for holding all information passed into the scratch provision command.
+type provisionScratch struct {
+	dryrun     bool
+	force      bool
+	label      string
+	mountPoint string
+	persist    bool
+	timeout    time.Duration
+}
+
+// uninstallScratch is a struct for holding all information passed into the scratch uninstall command.
+type uninstallScratch struct {
+	dryrun  bool
+	force   bool
+	timeout time.Duration
+}
+
+// scratchCommand creates a new command which groups the internal SSD scratch volume subcommands.
+func scratchCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scratch",
+		Short: "manage a scratch volume on the internal SSD",
+		Long: strings.TrimSpace(`
+scratch manages a scratch volume on the host's internal SSD
+(e.g. on mac1.metal instances), which is faster than EBS for
+ephemeral data like build caches. The internal SSD isn't
+backed up and its data doesn't survive the host being
+replaced. The internal SSD of Apple silicon hosts holds their
+boot policy so it's never used.
+`),
+	}
+
+	cmd.AddCommand(scratchProvisionCommand())
+	cmd.AddCommand(scratchUninstallCommand())
+
+	return cmd
+}
+
+// scratchProvisionCommand creates a new command which erases the internal SSD and mounts it as a scratch volume.
+func scratchProvisionCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "provision",
+		Short: "erase the internal SSD and mount it as a scratch volume",
+		Long: strings.TrimSpace(`
+provision erases the host's internal SSD and formats it with a
+single APFS volume mounted at the mount point. Everything on
+the internal SSD is lost so --force is required. Provisioning
+can be repeated: an internal SSD that already holds a volume
+with the label isn't erased again. Unless disabled, the mount
+is added to /etc/fstab so it's mounted on every boot.
+`),
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{publishesEventsAnnotation: "true"},
+	}
+
+	args := provisionScratch{}
+	cmd.PersistentFlags().StringVar(&args.label, "label", "Scratch", "name of the scratch volume")
+	cmd.PersistentFlags().StringVar(&args.mountPoint, "mount-point", scratchDefaultMountPoint, "path to mount the scratch volume at")
+	cmd.PersistentFlags().BoolVar(&args.persist, "persist", true, "persist the mount across reboots in /etc/fstab")
+	cmd.PersistentFlags().BoolVar(&args.force, "force", false, "confirm that the internal SSD can be erased")
+	cmd.PersistentFlags().BoolVar(&args.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&args.timeout, "timeout", provisionDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Erasing and mounting disks with diskutil requires root permissions and Full Disk Access.
+	cmd.PreRunE = assertDiskPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		if args.timeout != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, args.timeout)
+			defer cancel()
+		}
+
+		product := contextual.Product(ctx)
+		if product == nil {
+			return errors.New("product required in context")
+		}
+
+		d, err := diskutil.ForProduct(product)
+		if err != nil {
+			return err
+		}
+		if args.dryrun {
+			d = diskutil.Dryrun(d)
+		}
+
+		logrus.WithField("args", args).Debug("Running scratch provision command with args")
+		if err := runScratchProvision(ctx, d, mounts.NewManager(product), args); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return errors.New("timeout exceeded")
+			}
+
+			return err
+		}
+
+		return nil
+	}
+
+	// Keep other disk operations on the host from running alongside this one.
+	lockDiskOperation(cmd)
+
+	return cmd
+}
+
+// runScratchProvision provisions the scratch volume on the internal SSD using diskutil.ProvisionScratch.
+func runScratchProvision(ctx context.Context, utility diskutil.DiskUtil, m *mounts.Manager, args provisionScratch) error {
+	if !args.force {
+		return errors.New("provisioning erases the internal SSD, run with --force to confirm")
+	}
+	if !filepath.IsAbs(args.mountPoint) {
+		return fmt.Errorf("mount point must be an absolute path: %s", args.mountPoint)
+	}
+
+	if !args.dryrun {
+		if _, err := m.EnsureMountPoint(ctx, args.mountPoint); err != nil {
+			return fmt.Errorf("cannot create mount point: %w", err)
+		}
+	}
+
+	volume, err := diskutil.ProvisionScratch(ctx, utility, args.label, args.mountPoint)
+	if err != nil {
+		return fmt.Errorf("cannot provision scratch volume: %w", err)
+	}
+	if volume == nil {
+		logrus.Info("Dry-run complete, nothing else to do")
+		return nil
+	}
+
+	if args.persist {
+		if args.dryrun {
+			logrus.WithField("volume_uuid", volume.VolumeUUID).Warn("Would have persisted mount")
+		} else if err := persistVolumeMount(ctx, m, volume.VolumeUUID, args.mountPoint, volume.FilesystemType); err != nil {
+			return fmt.Errorf("cannot persist mount: %w", err)
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"volume_id":   volume.DeviceIdentifier,
+		"mount_point": volume.MountPoint,
+	}).Info("Successfully provisioned scratch volume")
+
+	return nil
+}
+
+// scratchUninstallCommand creates a new command which removes the scratch volume from the internal SSD.
+func scratchUninstallCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "uninstall",
+		Short: "remove the scratch volume from the internal SSD",
+		Long: strings.TrimSpace(`
+uninstall unmounts the scratch volume, removes its mount from
+/etc/fstab, and erases the internal SSD to free space so that
+nothing is left on it. Everything on the scratch volume is
+lost so --force is required.
+`),
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{publishesEventsAnnotation: "true"},
+	}
+
+	args := uninstallScratch{}
+	cmd.PersistentFlags().BoolVar(&args.force, "force", false, "confirm that the internal SSD can be erased")
+	cmd.PersistentFlags().BoolVar(&args.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&args.timeout, "timeout", provisionDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Unmounting and erasing disks with diskutil requires root permissions and Full Disk Access.
+	cmd.PreRunE = assertDiskPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		if args.timeout != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, args.timeout)
+			defer cancel()
+		}
+
+		product := contextual.Product(ctx)
+		if product == nil {
+			return errors.New("product required in context")
+		}
+
+		d, err := diskutil.ForProduct(product)
+		if err != nil {
+			return err
+		}
+		if args.dryrun {
+			d = diskutil.Dryrun(d)
+		}
+
+		logrus.WithField("args", args).Debug("Running scratch uninstall command with args")
+		if err := runScratchUninstall(ctx, d, mounts.NewManager(product), args); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return errors.New("timeout exceeded")
+			}
+
+			return err
+		}
+
+		return nil
+	}
+
+	// Keep other disk operations on the host from running alongside this one.
+	lockDiskOperation(cmd)
+
+	return cmd
+}
+
+// runScratchUninstall removes the scratch volume and its persisted mount using diskutil.RemoveScratch.
+func runScratchUninstall(ctx context.Context, utility diskutil.DiskUtil, m *mounts.Manager, args uninstallScratch) error {
+	if !args.force {
+		return errors.New("uninstalling erases the internal SSD, run with --force to confirm")
+	}
+
+	volume, err := diskutil.RemoveScratch(ctx, utility)
+	if err != nil {
+		return fmt.Errorf("cannot uninstall scratch volume: %w", err)
+	}
+
+	if volume != nil {
+		spec := volume.MountPoint
+		if volume.VolumeUUID != "" {
+			spec = "UUID=" + volume.VolumeUUID
+		}
+		if spec == "" {
+			logrus.Info("Scratch volume has no mount to remove")
+		} else if args.dryrun {
+			logrus.WithField("mount", spec).Warn("Would have removed persisted mount")
+		} else if _, err := m.Remove(ctx, spec); err != nil {
+			return fmt.Errorf("cannot remove persisted mount: %w", err)
+		}
+	}
+
+	logrus.Info("Successfully uninstalled scratch volume")
+
+	return nil
+}
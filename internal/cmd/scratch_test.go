@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/diskutilfakes"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// fakeInternalSSD is the blank internal SSD of a mac1.metal host.
+func fakeInternalSSD() diskutilfakes.Disk {
+	return diskutilfakes.Disk{
+		ID:          "disk1",
+		Size:        250_000_000_000,
+		Internal:    true,
+		MediaName:   "APPLE SSD AP0256M",
+		BusProtocol: "PCI-Express",
+	}
+}
+
+// testMountsManager creates a mounts.Manager which edits files in a temporary directory.
+func testMountsManager(t *testing.T) *mounts.Manager {
+	dir := t.TempDir()
+
+	return &mounts.Manager{
+		FstabPath:     filepath.Join(dir, "fstab"),
+		SyntheticPath: filepath.Join(dir, "synthetic.conf"),
+		Product:       &system.Product{Release: system.BigSur},
+	}
+}
+
+func TestRunScratchProvision_WithoutForce(t *testing.T) {
+	fake := diskutilfakes.New(fakeBootDisk(), fakeInternalSSD())
+
+	err := runScratchProvision(context.Background(), fake, nil, provisionScratch{label: "Scratch", mountPoint: "/Volumes/Scratch"})
+
+	assert.Error(t, err, "the internal SSD shouldn't be erased without --force")
+	assert.Empty(t, fake.Disks()[1].Partitions)
+}
+
+func TestRunScratchProvision_Uninstall(t *testing.T) {
+	ctx := context.Background()
+	fake := diskutilfakes.New(fakeBootDisk(), fakeInternalSSD())
+	m := testMountsManager(t)
+	mountPoint := filepath.Join(t.TempDir(), "Scratch")
+
+	err := runScratchProvision(ctx, fake, m, provisionScratch{force: true, label: "Scratch", mountPoint: mountPoint})
+	assert.NoError(t, err)
+	_, err = m.Persist(ctx, mounts.FstabEntry{Spec: "/dev/disk1s2", File: mountPoint, VfsType: "apfs"})
+	assert.NoError(t, err)
+
+	ssd := fake.Disks()[1]
+	if assert.Len(t, ssd.Partitions, 2) && assert.NotNil(t, ssd.Partitions[1].Container) {
+		assert.Equal(t, "Scratch", ssd.Partitions[1].Container.Volumes[0].Name)
+		assert.Equal(t, mountPoint, ssd.Partitions[1].Container.Volumes[0].MountPoint)
+	}
+
+	err = runScratchProvision(ctx, fake, m, provisionScratch{force: true, label: "Scratch", mountPoint: mountPoint})
+	assert.NoError(t, err, "provisioning should be repeatable")
+	erases := 0
+	for _, c := range fake.Calls() {
+		if c.Method == "EraseDisk" {
+			erases++
+		}
+	}
+	assert.Equal(t, 1, erases, "the scratch volume shouldn't be erased again")
+
+	err = runScratchUninstall(ctx, fake, m, uninstallScratch{force: true})
+	assert.NoError(t, err)
+	assert.Len(t, fake.Disks()[1].Partitions, 1, "only the EFI partition should be left")
+	assert.Len(t, fake.Disks()[0].Partitions, 2, "the boot disk shouldn't be touched")
+	tab, err := mounts.ReadFstab(m.FstabPath)
+	assert.NoError(t, err)
+	assert.Empty(t, tab.Entries(), "the persisted mount should be removed")
+}
+
+func TestRunScratchProvision_AppleSilicon(t *testing.T) {
+	ssd := fakeInternalSSD()
+	ssd.MediaName = "APPLE SSD AP0256Q"
+	ssd.BusProtocol = "Apple Fabric"
+	fake := diskutilfakes.New(fakeBootDisk(), ssd)
+
+	err := runScratchProvision(context.Background(), fake, testMountsManager(t), provisionScratch{force: true, label: "Scratch", mountPoint: t.TempDir()})
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Apple silicon")
+	}
+	assert.Empty(t, fake.Disks()[1].Partitions)
+}
+
+func TestRunScratchProvision_WithoutInternalSSD(t *testing.T) {
+	fake := diskutilfakes.New(fakeBootDisk())
+
+	err := runScratchProvision(context.Background(), fake, testMountsManager(t), provisionScratch{force: true, label: "Scratch", mountPoint: t.TempDir()})
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no internal SSD found")
+	}
+}
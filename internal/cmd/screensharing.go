@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/internal/screensharing"
+	"github.com/aws/ec2-macos-utils/internal/users"
+)
+
+// screenSharingDefaultTimeout is the default maximum run duration for configuring Screen Sharing.
+const screenSharingDefaultTimeout = time.Minute
+
+// screenSharingCommand creates a new command which groups the Screen Sharing subcommands.
+func screenSharingCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "screensharing",
+		Short: "manage Screen Sharing (VNC) access",
+		Long: strings.TrimSpace(`
+screensharing enables and disables macOS's Screen Sharing service
+for GUI access to the instance. Access is limited to the given
+users, who sign in with their account passwords. A password for
+legacy VNC clients can be set from stdin, a Secrets Manager
+secret, or a Parameter Store parameter.
+
+Screen Sharing listens on port 5900, which should only be reached
+through an SSH tunnel or a restricted security group.
+`),
+	}
+
+	cmd.AddCommand(screenSharingEnableCommand(), screenSharingDisableCommand(), screenSharingStatusCommand())
+
+	return cmd
+}
+
+// screenSharingEnableCommand creates a new command which enables Screen Sharing.
+func screenSharingEnableCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "enable",
+		Short: "enable Screen Sharing for users",
+		Args:  cobra.NoArgs,
+	}
+
+	var names []string
+	var dryrun bool
+	var timeout time.Duration
+	vncPassword := passwordSource{}
+	cmd.PersistentFlags().StringSliceVar(&names, "user", nil, "user allowed to connect, may be repeated")
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", screenSharingDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+	addPasswordFlags(cmd, &vncPassword, "vnc-password")
+	cmd.MarkPersistentFlagRequired("user")
+
+	// Configuring remote management and loading services requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			for _, name := range names {
+				if _, err := users.Lookup(ctx, name); err != nil {
+					return fmt.Errorf("cannot grant access: %w", err)
+				}
+			}
+
+			var password string
+			if vncPassword.isSet() {
+				var err error
+				if password, err = vncPassword.read(ctx, cmd.InOrStdin()); err != nil {
+					return err
+				}
+				if err := screensharing.ValidateVNCPassword(password); err != nil {
+					return err
+				}
+			}
+
+			if dryrun {
+				logrus.WithFields(logrus.Fields{
+					"users":        names,
+					"vnc_password": password != "",
+				}).Warn("Would have enabled Screen Sharing")
+				return nil
+			}
+
+			logrus.WithField("users", names).Info("Enabling Screen Sharing...")
+			if err := screensharing.Enable(ctx, launchd.NewDaemonManager(), names); err != nil {
+				return err
+			}
+			if password != "" {
+				if err := screensharing.SetVNCPassword(ctx, password); err != nil {
+					return err
+				}
+				logrus.Info("Set VNC password")
+			}
+			logrus.WithField("users", names).Info("Successfully enabled Screen Sharing")
+
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+// screenSharingDisableCommand creates a new command which disables Screen Sharing.
+func screenSharingDisableCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "disable",
+		Short: "disable Screen Sharing",
+		Args:  cobra.NoArgs,
+	}
+
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", screenSharingDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Configuring remote management and unloading services requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			if dryrun {
+				logrus.Warn("Would have disabled Screen Sharing")
+				return nil
+			}
+
+			if err := screensharing.Disable(ctx, launchd.NewDaemonManager()); err != nil {
+				return err
+			}
+			logrus.Info("Successfully disabled Screen Sharing")
+
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+// screenSharingStatusCommand creates a new command which reports the state of Screen Sharing.
+func screenSharingStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "report whether Screen Sharing is enabled",
+		Args:  cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", screenSharingDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			status, err := screensharing.GetStatus(ctx, launchd.NewDaemonManager())
+			if err != nil {
+				return err
+			}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), status, func(w io.Writer) error {
+				return printScreenSharingStatus(w, status)
+			})
+		})
+	}
+
+	return cmd
+}
+
+// printScreenSharingStatus writes the state of Screen Sharing to w.
+func printScreenSharingStatus(w io.Writer, status *screensharing.Status) error {
+	state := "disabled"
+	switch {
+	case status.Running:
+		state = "enabled (client connected)"
+	case status.Loaded:
+		state = "enabled"
+	}
+	_, err := fmt.Fprintf(w, "Screen Sharing: %s\n", state)
+
+	return err
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/screensharing"
+)
+
+func TestPrintScreenSharingStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status screensharing.Status
+		want   string
+	}{
+		{name: "disabled", status: screensharing.Status{}, want: "Screen Sharing: disabled\n"},
+		{name: "enabled", status: screensharing.Status{Loaded: true}, want: "Screen Sharing: enabled\n"},
+		{name: "connected", status: screensharing.Status{Loaded: true, Running: true}, want: "Screen Sharing: enabled (client connected)\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			assert.NoError(t, printScreenSharingStatus(&buf, &tt.status))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
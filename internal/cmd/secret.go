@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/secret"
+	"github.com/aws/ec2-macos-utils/internal/users"
+)
+
+// secretDefaultTimeout is the default maximum run duration for fetching secrets.
+const secretDefaultTimeout = time.Minute
+
+// getSecret is a struct for holding all information passed into the secret get command.
+type getSecret struct {
+	service string
+	key     string
+	file    string
+	owner   string
+	mode    string
+	timeout time.Duration
+}
+
+// secretCommand creates a new command which groups the secret subcommands.
+func secretCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secret",
+		Short: "fetch secrets with the instance's credentials",
+		Long: strings.TrimSpace(`
+secret fetches secrets kept in AWS Secrets Manager or as Parameter
+Store SecureString parameters using the instance's credentials,
+e.g. for provisioning scripts that need tokens or certificates
+without keeping the credentials to fetch them.
+`),
+	}
+
+	cmd.AddCommand(secretGetCommand())
+
+	return cmd
+}
+
+// secretGetCommand creates a new command which fetches a secret and writes it to a file or stdout.
+func secretGetCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get <name>",
+		Short: "fetch a secret and write it to a file or stdout",
+		Long: strings.TrimSpace(`
+get fetches the secret or parameter with the name or ARN and
+writes its value, or the field of its JSON object value given with
+--key, to stdout or, with --file, to a file. Binary secrets are
+written as-is.
+
+Files are replaced atomically, are never readable by others
+before their owner and mode are set, and belong to the user given
+with --owner, and their primary group, or to the user running the
+command. Writing files for other users requires root access.
+`),
+		Args: cobra.ExactArgs(1),
+	}
+
+	getArgs := getSecret{}
+	cmd.PersistentFlags().StringVar(&getArgs.service, "service", string(secret.ServiceSecretsManager), "service the secret is kept in (secretsmanager or ssm)")
+	cmd.PersistentFlags().StringVar(&getArgs.key, "key", "", "field of the JSON secret to get, the whole secret is used when empty")
+	cmd.PersistentFlags().StringVar(&getArgs.file, "file", "", "path of the file to write the secret to, stdout is used when empty")
+	cmd.PersistentFlags().StringVar(&getArgs.owner, "owner", "", "user that owns the file")
+	cmd.PersistentFlags().StringVar(&getArgs.mode, "mode", "0600", "permissions of the file, in octal")
+	cmd.PersistentFlags().DurationVar(&getArgs.timeout, "timeout", secretDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, getArgs.timeout, func(ctx context.Context) error {
+			return runGetSecret(ctx, cmd, args[0], getArgs)
+		})
+	}
+
+	return cmd
+}
+
+// runGetSecret fetches the secret and writes it to the file, or to stdout when no file is given.
+func runGetSecret(ctx context.Context, cmd *cobra.Command, name string, args getSecret) error {
+	service, err := secret.ParseService(args.service)
+	if err != nil {
+		return err
+	}
+	f, err := secretFile(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	client, err := aws.NewClientFromMetadata(ctx)
+	if err != nil {
+		return err
+	}
+	value, err := secret.Get(ctx, client, secret.Ref{Service: service, ID: name, Field: args.key})
+	if err != nil {
+		return err
+	}
+
+	if f == nil {
+		_, err := cmd.OutOrStdout().Write(value)
+		return err
+	}
+	if err := secret.Write(*f, value); err != nil {
+		return err
+	}
+	logrus.WithFields(logrus.Fields{"secret": name, "path": f.Path}).Info("Successfully wrote secret")
+
+	return nil
+}
+
+// secretFile builds the file the secret is written to from the flags, or nil when it's written to stdout.
+func secretFile(ctx context.Context, args getSecret) (*secret.File, error) {
+	if args.file == "" {
+		if args.owner != "" {
+			return nil, errors.New("--owner can only be used with --file")
+		}
+		return nil, nil
+	}
+
+	mode, err := parseFileMode(args.mode)
+	if err != nil {
+		return nil, err
+	}
+	f := &secret.File{Path: args.file, UID: -1, GID: -1, Mode: mode}
+	if args.owner != "" {
+		u, err := users.Lookup(ctx, args.owner)
+		if err != nil {
+			return nil, err
+		}
+		f.UID, f.GID = u.UID, u.GID
+	}
+
+	return f, nil
+}
+
+// parseFileMode parses octal file permissions (e.g. "0640").
+func parseFileMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid mode %q, expected octal permissions (e.g. 0600)", s)
+	}
+
+	return os.FileMode(mode), nil
+}
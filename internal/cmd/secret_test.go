@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/secret"
+)
+
+func TestParseFileMode(t *testing.T) {
+	mode, err := parseFileMode("0640")
+
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), mode)
+
+	for _, s := range []string{"rw-r-----", "0999", "01777"} {
+		_, err := parseFileMode(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestSecretFile(t *testing.T) {
+	f, err := secretFile(context.Background(), getSecret{file: "/etc/token", mode: "0400"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &secret.File{Path: "/etc/token", UID: -1, GID: -1, Mode: 0400}, f)
+}
+
+func TestSecretFile_Stdout(t *testing.T) {
+	f, err := secretFile(context.Background(), getSecret{mode: "0600"})
+	assert.NoError(t, err)
+	assert.Nil(t, f)
+
+	_, err = secretFile(context.Background(), getSecret{owner: "runner", mode: "0600"})
+	assert.Error(t, err, "owners can't be set on stdout")
+}
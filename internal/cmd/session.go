@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/sessions"
+	"github.com/aws/ec2-macos-utils/internal/users"
+)
+
+// sessionDefaultTimeout is the default maximum run duration for session commands.
+const sessionDefaultTimeout = time.Minute
+
+// sessionDefaultIdle is the default idle time after which sessions are cleaned up.
+const sessionDefaultIdle = 12 * time.Hour
+
+// cleanupSessions is a struct for holding all information passed into the session cleanup command.
+type cleanupSessions struct {
+	dryrun             bool
+	gui                bool
+	idle               time.Duration
+	restartLoginWindow bool
+	timeout            time.Duration
+}
+
+// sessionCommand creates a new command which groups the login session subcommands.
+func sessionCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "session",
+		Short: "manage login sessions",
+		Long: strings.TrimSpace(`
+session lists and cleans up the GUI and SSH login sessions on
+the instance. Shared CI hosts accumulate sessions left behind by
+disconnected clients and finished jobs, which keep their
+processes and memory until they're ended.
+`),
+	}
+
+	cmd.AddCommand(sessionListCommand(), sessionCleanupCommand())
+
+	return cmd
+}
+
+// sessionListCommand creates a new command which lists the login sessions.
+func sessionListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list login sessions",
+		Args:  cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", sessionDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			list, err := sessions.List(ctx)
+			if err != nil {
+				return err
+			}
+
+			return printSessions(cmd, list)
+		})
+	}
+
+	return cmd
+}
+
+// sessionCleanupCommand creates a new command which ends idle login sessions.
+func sessionCleanupCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "end idle login sessions",
+		Long: strings.TrimSpace(`
+cleanup ends the SSH and terminal sessions that have been idle
+for at least --idle, which hangs up their shells and the programs
+started from them. GUI sessions are only logged out with --gui,
+where their idle time is the time since the last keyboard or
+mouse input. The login window can be restarted afterwards with
+--restart-loginwindow to release what's left of GUI sessions.
+The session running the command is never ended.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	cleanupArgs := cleanupSessions{}
+	cmd.PersistentFlags().DurationVar(&cleanupArgs.idle, "idle", sessionDefaultIdle, "minimum idle time of the sessions to end")
+	cmd.PersistentFlags().BoolVar(&cleanupArgs.gui, "gui", false, "also log out idle GUI sessions")
+	cmd.PersistentFlags().BoolVar(&cleanupArgs.restartLoginWindow, "restart-loginwindow", false, "restart the login window after cleaning up")
+	cmd.PersistentFlags().BoolVar(&cleanupArgs.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&cleanupArgs.timeout, "timeout", sessionDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Ending other users' sessions requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, cleanupArgs.timeout, func(ctx context.Context) error {
+			logrus.WithField("args", cleanupArgs).Debug("Running session cleanup command with args")
+
+			return runSessionCleanup(ctx, cmd, cleanupArgs)
+		})
+	}
+
+	return cmd
+}
+
+// runSessionCleanup ends the sessions that have been idle for longer than the threshold, other than the one
+// running the command, and then restarts the login window when requested.
+func runSessionCleanup(ctx context.Context, cmd *cobra.Command, args cleanupSessions) error {
+	list, err := sessions.List(ctx)
+	if err != nil {
+		return err
+	}
+	current, err := sessions.CurrentLine(ctx)
+	if err != nil {
+		return err
+	}
+
+	idle := sessions.Idle(list, args.idle, args.gui, current)
+	if len(idle) == 0 {
+		logrus.WithField("idle", args.idle).Info("No idle sessions, nothing to do")
+	}
+	for _, s := range idle {
+		entry := logrus.WithFields(logrus.Fields{
+			"user": s.User,
+			"line": s.Line,
+			"idle": s.Idle,
+		})
+		if args.dryrun {
+			entry.Warn("Would have ended session")
+			continue
+		}
+
+		if err := endSession(ctx, s); err != nil {
+			return err
+		}
+		entry.Info("Ended idle session")
+	}
+
+	if args.restartLoginWindow {
+		if args.dryrun {
+			logrus.Warn("Would have restarted the login window")
+		} else {
+			if err := sessions.RestartLoginWindow(ctx); err != nil {
+				return err
+			}
+			logrus.Info("Restarted the login window")
+		}
+	}
+
+	if idle == nil {
+		idle = []sessions.Session{}
+	}
+
+	return printSessions(cmd, idle)
+}
+
+// endSession ends the session, looking up the user's UID for GUI sessions.
+func endSession(ctx context.Context, s sessions.Session) error {
+	var uid int
+	if s.GUI() {
+		u, err := users.Lookup(ctx, s.User)
+		if err != nil {
+			return err
+		}
+		uid = u.UID
+	}
+
+	return sessions.Terminate(ctx, s, uid)
+}
+
+// printSessions writes the sessions in the output format selected for the command.
+func printSessions(cmd *cobra.Command, list []sessions.Session) error {
+	return printOutput(cmd.OutOrStdout(), outputFormat(cmd), list, func(w io.Writer) error {
+		return printSessionTable(w, list)
+	})
+}
+
+// printSessionTable writes a table of the sessions to w.
+func printSessionTable(w io.Writer, list []sessions.Session) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "USER\tLINE\tHOST\tLOGIN\tIDLE")
+	for _, s := range list {
+		host := s.Host
+		if host == "" {
+			host = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", s.User, s.Line, host, s.Login.Format("Jan 2 15:04"), s.Idle.Round(time.Minute))
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/sessions"
+)
+
+func TestPrintSessionTable(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := printSessionTable(&buf, []sessions.Session{
+		{User: "ec2-user", Line: "console", Login: time.Date(2023, 10, 14, 8, 1, 0, 0, time.UTC), Idle: 90 * time.Minute},
+		{User: "ci", Line: "ttys001", Host: "10.0.0.6", Login: time.Date(2023, 10, 14, 7, 0, 0, 0, time.UTC), Idle: 2*time.Hour + 15*time.Minute},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `USER      LINE     HOST      LOGIN         IDLE
+ec2-user  console  -         Oct 14 08:01  1h30m0s
+ci        ttys001  10.0.0.6  Oct 14 07:00  2h15m0s
+`, buf.String())
+}
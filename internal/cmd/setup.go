@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/imds"
+	"github.com/aws/ec2-macos-utils/internal/systemsetup"
+)
+
+// setupDefaultTimeout is the default maximum run duration for checking and applying systemsetup settings.
+const setupDefaultTimeout = time.Minute
+
+// setupSettings is a struct for holding all information passed into the setup subcommands.
+type setupSettings struct {
+	remoteLogin     bool
+	restartOnFreeze bool
+	timezone        string
+	timeout         time.Duration
+}
+
+// setupCommand creates a new command which groups the systemsetup subcommands.
+func setupCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "setup",
+		Short: "manage system settings",
+		Long: strings.TrimSpace(`
+setup manages the systemsetup(8) settings of the instance: remote
+login (SSH), the time zone, and restarting after a freeze. Remote
+login and restarting after a freeze are enabled unless configured
+otherwise while the time zone is only changed when configured. The
+time zone "auto" selects the time zone of the instance's AWS
+Region.
+
+Settings are read from the setup section of the configuration
+file and can be overridden with flags.
+`),
+	}
+
+	cmd.AddCommand(setupCheckCommand(), setupApplyCommand())
+
+	return cmd
+}
+
+// addSetupFlags adds the flags used to override the configured settings to the command.
+func addSetupFlags(cmd *cobra.Command, args *setupSettings) {
+	cmd.PersistentFlags().BoolVar(&args.remoteLogin, "remote-login", true, "enable remote login (SSH)")
+	cmd.PersistentFlags().BoolVar(&args.restartOnFreeze, "restart-on-freeze", true, "restart automatically after the system freezes")
+	cmd.PersistentFlags().StringVar(&args.timezone, "timezone", "", `time zone to set (e.g. "America/Los_Angeles" or "auto")`)
+	cmd.PersistentFlags().DurationVar(&args.timeout, "timeout", setupDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+}
+
+// setupCheckCommand creates a new command which reports drift from the desired system settings.
+func setupCheckCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "report drift from the desired system settings",
+		Args:  cobra.NoArgs,
+	}
+
+	setupArgs := setupSettings{}
+	addSetupFlags(cmd, &setupArgs)
+
+	// Reading some settings (e.g. remote login) with systemsetup requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runSetup(cmd, setupArgs, func(ctx context.Context, t *systemsetup.Task) error {
+			return checkTask(ctx, cmd, t)
+		})
+	}
+
+	return cmd
+}
+
+// setupApplyCommand creates a new command which applies the desired system settings.
+func setupApplyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "apply the desired system settings",
+		Args:  cobra.NoArgs,
+	}
+
+	setupArgs := setupSettings{}
+	addSetupFlags(cmd, &setupArgs)
+	var dryrun bool
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+
+	// Changing settings with systemsetup requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runSetup(cmd, setupArgs, func(ctx context.Context, t *systemsetup.Task) error {
+			return applyTask(ctx, cmd, t, dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// runSetup builds the systemsetup task from the configuration and flags and runs it with fn.
+func runSetup(cmd *cobra.Command, args setupSettings, fn func(ctx context.Context, t *systemsetup.Task) error) error {
+	ctx := cmd.Context()
+	if args.timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, args.timeout)
+		defer cancel()
+	}
+
+	c, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	t, err := setupTask(ctx, cmd, c.Setup, args)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(ctx, t); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return errors.New("timeout exceeded")
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// setupTask merges the configured settings with the flags that were set to build the systemsetup task. Flags take
+// precedence over the configuration, which takes precedence over the flags' defaults.
+func setupTask(ctx context.Context, cmd *cobra.Command, conf config.Setup, args setupSettings) (*systemsetup.Task, error) {
+	t := &systemsetup.Task{
+		RemoteLogin:       &args.remoteLogin,
+		RestartFreeze:     &args.restartOnFreeze,
+		Timezone:          args.timezone,
+		NetworkTime:       conf.NetworkTime,
+		NetworkTimeServer: conf.NetworkTimeServer,
+	}
+	if !cmd.Flags().Changed("remote-login") && conf.RemoteLogin != nil {
+		t.RemoteLogin = conf.RemoteLogin
+	}
+	if !cmd.Flags().Changed("restart-on-freeze") && conf.RestartOnFreeze != nil {
+		t.RestartFreeze = conf.RestartOnFreeze
+	}
+	if !cmd.Flags().Changed("timezone") {
+		t.Timezone = conf.Timezone
+	}
+
+	tz, err := resolveTimezone(ctx, t.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	t.Timezone = tz
+
+	return t, nil
+}
+
+// resolveTimezone selects the time zone for the instance's region when tz is systemsetup.TimezoneAuto. Other time
+// zones are returned as-is.
+func resolveTimezone(ctx context.Context, tz string) (string, error) {
+	if tz != systemsetup.TimezoneAuto {
+		return tz, nil
+	}
+
+	logrus.Info("Looking up the instance's region for its time zone...")
+	region, err := imds.NewClient().Region(ctx)
+	if err != nil {
+		return "", fmt.Errorf("cannot select time zone: %w", err)
+	}
+	tz, err = systemsetup.TimezoneForRegion(region)
+	if err != nil {
+		return "", fmt.Errorf("cannot select time zone: %w", err)
+	}
+	logrus.WithFields(logrus.Fields{
+		"region":   region,
+		"timezone": tz,
+	}).Debug("Selected time zone for region")
+
+	return tz, nil
+}
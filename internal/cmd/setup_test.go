@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+)
+
+func TestSetupTask_Defaults(t *testing.T) {
+	cmd := setupApplyCommand()
+
+	task, err := setupTask(context.Background(), cmd, config.Setup{}, setupSettings{remoteLogin: true, restartOnFreeze: true})
+
+	assert.NoError(t, err)
+	assert.True(t, *task.RemoteLogin)
+	assert.True(t, *task.RestartFreeze)
+	assert.Empty(t, task.Timezone, "time zone shouldn't be changed by default")
+}
+
+func TestSetupTask_Config(t *testing.T) {
+	disabled := false
+	cmd := setupApplyCommand()
+
+	task, err := setupTask(context.Background(), cmd, config.Setup{RemoteLogin: &disabled, Timezone: "Europe/Dublin"}, setupSettings{remoteLogin: true, restartOnFreeze: true})
+
+	assert.NoError(t, err)
+	assert.False(t, *task.RemoteLogin, "config should override defaults")
+	assert.True(t, *task.RestartFreeze)
+	assert.Equal(t, "Europe/Dublin", task.Timezone)
+}
+
+func TestSetupTask_FlagsOverrideConfig(t *testing.T) {
+	disabled := false
+	setupArgs := setupSettings{}
+	cmd := &cobra.Command{}
+	addSetupFlags(cmd, &setupArgs)
+	assert.NoError(t, cmd.ParseFlags([]string{"--remote-login=true", "--timezone", "Asia/Tokyo"}))
+
+	task, err := setupTask(context.Background(), cmd, config.Setup{RemoteLogin: &disabled, Timezone: "Europe/Dublin"}, setupArgs)
+
+	assert.NoError(t, err)
+	assert.True(t, *task.RemoteLogin, "flags should override config")
+	assert.Equal(t, "Asia/Tokyo", task.Timezone)
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/sshd"
+)
+
+const (
+	// sshDefaultTimeout is the default maximum run duration for checking and configuring the SSH server.
+	sshDefaultTimeout = time.Minute
+
+	// defaultPermitRootLogin denies logging in as root unless configured otherwise.
+	defaultPermitRootLogin = "no"
+	// defaultClientAliveInterval is the default number of seconds after which idle clients are sent a keepalive.
+	defaultClientAliveInterval = 60
+	// defaultClientAliveCountMax is the default number of unanswered keepalives before a client is disconnected.
+	defaultClientAliveCountMax = 3
+)
+
+// sshServerSettings is a struct for holding all information passed into the ssh server subcommands.
+type sshServerSettings struct {
+	passwordAuth        bool
+	permitRootLogin     string
+	allowUsers          []string
+	clientAliveInterval int
+	clientAliveCountMax int
+	timeout             time.Duration
+}
+
+// sshCommand creates a new command which groups the SSH subcommands.
+func sshCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ssh",
+		Short: "manage the SSH server and SSH access",
+		Long: strings.TrimSpace(`
+ssh manages the SSH server's settings with a fragment in
+/etc/ssh/sshd_config.d that's read before macOS's own, so its
+settings take precedence. Password and keyboard-interactive
+authentication and root logins are denied, and idle clients are
+sent keepalives every 60 seconds and disconnected after 3 go
+unanswered, unless configured otherwise. Logins can also be
+restricted to a list of users.
+
+Settings are read from the ssh section's server entry of the
+configuration file and can be overridden with flags.
+
+Short-lived keys pushed through Parameter Store are managed with
+the ephemeral subcommands, and accounts and their keys are synced
+from IAM or a manifest with sync.
+`),
+	}
+
+	cmd.AddCommand(sshCheckCommand(), sshConfigureCommand(), sshEphemeralCommand(), sshSyncCommand())
+
+	return cmd
+}
+
+// addSSHServerFlags adds the flags used to override the configured settings to the command.
+func addSSHServerFlags(cmd *cobra.Command, args *sshServerSettings) {
+	cmd.PersistentFlags().BoolVar(&args.passwordAuth, "password-auth", false, "allow logging in with passwords")
+	cmd.PersistentFlags().StringVar(&args.permitRootLogin, "permit-root-login", defaultPermitRootLogin, "allow logging in as root (yes, no, prohibit-password, or forced-commands-only)")
+	cmd.PersistentFlags().StringSliceVar(&args.allowUsers, "allow-user", nil, "user allowed to log in, may be repeated, all users when unset")
+	cmd.PersistentFlags().IntVar(&args.clientAliveInterval, "client-alive-interval", defaultClientAliveInterval, "seconds after which idle clients are sent a keepalive")
+	cmd.PersistentFlags().IntVar(&args.clientAliveCountMax, "client-alive-count-max", defaultClientAliveCountMax, "unanswered keepalives before a client is disconnected")
+	cmd.PersistentFlags().DurationVar(&args.timeout, "timeout", sshDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+}
+
+// sshCheckCommand creates a new command which reports drift from the desired SSH server settings.
+func sshCheckCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "report drift from the desired SSH server settings",
+		Args:  cobra.NoArgs,
+	}
+
+	sshArgs := sshServerSettings{}
+	addSSHServerFlags(cmd, &sshArgs)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runSSHServer(cmd, sshArgs, func(ctx context.Context, t *sshd.Task) error {
+			return checkTask(ctx, cmd, t)
+		})
+	}
+
+	return cmd
+}
+
+// sshConfigureCommand creates a new command which applies the desired SSH server settings.
+func sshConfigureCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "configure",
+		Short: "apply the desired SSH server settings",
+		Long: strings.TrimSpace(`
+configure writes the managed sshd_config fragment, adding an
+Include of /etc/ssh/sshd_config.d to the top of sshd_config when
+it's missing, and validates the configuration with sshd -t. The
+previous files are restored when the configuration is invalid.
+
+sshd is started by launchd for each connection, so new
+connections use the settings immediately without reloading the
+service and open sessions aren't interrupted.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	sshArgs := sshServerSettings{}
+	addSSHServerFlags(cmd, &sshArgs)
+	var dryrun bool
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+
+	// Writing to /etc/ssh requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runSSHServer(cmd, sshArgs, func(ctx context.Context, t *sshd.Task) error {
+			return applyTask(ctx, cmd, t, dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// runSSHServer builds the SSH server task from the configuration and flags and runs it with fn.
+func runSSHServer(cmd *cobra.Command, args sshServerSettings, fn func(ctx context.Context, t *sshd.Task) error) error {
+	return runUserCommand(cmd, args.timeout, func(ctx context.Context) error {
+		c, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		settings, err := sshServerConfig(c.SSH.Server, args, cmd.Flags().Changed)
+		if err != nil {
+			return err
+		}
+
+		return fn(ctx, sshd.NewTask(settings))
+	})
+}
+
+// sshServerConfig merges the configured settings with the flags that were changed. Flags take precedence over the
+// configuration, which takes precedence over the flags' defaults.
+func sshServerConfig(conf config.SSHServer, args sshServerSettings, changed func(name string) bool) (sshd.Settings, error) {
+	s := sshd.Settings{
+		PasswordAuthentication: &args.passwordAuth,
+		PermitRootLogin:        args.permitRootLogin,
+		AllowUsers:             conf.AllowUsers,
+		ClientAliveInterval:    args.clientAliveInterval,
+		ClientAliveCountMax:    args.clientAliveCountMax,
+	}
+	if !changed("password-auth") && conf.PasswordAuthentication != nil {
+		s.PasswordAuthentication = conf.PasswordAuthentication
+	}
+	if !changed("permit-root-login") && conf.PermitRootLogin != "" {
+		s.PermitRootLogin = conf.PermitRootLogin
+	}
+	if changed("allow-user") {
+		s.AllowUsers = args.allowUsers
+	}
+	if !changed("client-alive-interval") && conf.ClientAliveInterval != 0 {
+		s.ClientAliveInterval = conf.ClientAliveInterval
+	}
+	if !changed("client-alive-count-max") && conf.ClientAliveCountMax != 0 {
+		s.ClientAliveCountMax = conf.ClientAliveCountMax
+	}
+
+	switch s.PermitRootLogin {
+	case "yes", "no", "prohibit-password", "forced-commands-only":
+	default:
+		return sshd.Settings{}, fmt.Errorf("invalid permit root login %q, must be yes, no, prohibit-password, or forced-commands-only", s.PermitRootLogin)
+	}
+	if s.ClientAliveInterval < 0 || s.ClientAliveCountMax < 0 {
+		return sshd.Settings{}, errors.New("client alive interval and count max must not be negative")
+	}
+
+	return s, nil
+}
+
+// sshServerTask builds the SSH server task from the configured settings and their defaults.
+func sshServerTask(conf config.SSHServer) (*sshd.Task, error) {
+	defaults := sshServerSettings{
+		permitRootLogin:     defaultPermitRootLogin,
+		clientAliveInterval: defaultClientAliveInterval,
+		clientAliveCountMax: defaultClientAliveCountMax,
+	}
+	settings, err := sshServerConfig(conf, defaults, func(string) bool { return false })
+	if err != nil {
+		return nil, err
+	}
+
+	return sshd.NewTask(settings), nil
+}
@@ -0,0 +1,326 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/imds"
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/internal/users"
+)
+
+const (
+	// ephemeralKeysParameterRoot is the Parameter Store path that holds each instance's ephemeral keys under its ID.
+	ephemeralKeysParameterRoot = "/ec2-macos-utils/ephemeral-keys"
+	// ephemeralKeysDefaultTTL is the default time that pushed keys are authorized for.
+	ephemeralKeysDefaultTTL = 15 * time.Minute
+	// ephemeralKeysDefaultInterval is the default time between syncs of the ephemeral keys daemon.
+	ephemeralKeysDefaultInterval = 30 * time.Second
+)
+
+// ephemeralKeysSettings is a struct for holding all information passed into the ssh ephemeral subcommands.
+type ephemeralKeysSettings struct {
+	path    string
+	users   []string
+	ttl     time.Duration
+	timeout time.Duration
+}
+
+// userEphemeralKey is an ephemeral key reported by ssh ephemeral list.
+type userEphemeralKey struct {
+	User string `json:"user"`
+	users.EphemeralKey
+}
+
+// parameterSource fetches the Parameter Store parameters under a path.
+type parameterSource interface {
+	GetParametersByPath(ctx context.Context, path string) ([]aws.Parameter, error)
+}
+
+// sshEphemeralCommand creates a new command which groups the ephemeral SSH key subcommands.
+func sshEphemeralCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ephemeral",
+		Short: "authorize short-lived SSH keys pushed through Parameter Store",
+		Long: strings.TrimSpace(`
+ephemeral authorizes SSH keys that are pushed to Parameter Store
+for a limited time, for fleets that disallow long-lived keys. A
+key is pushed by putting a parameter named after the local user
+under /ec2-macos-utils/ephemeral-keys/<instance-id>, holding one
+OpenSSH public key per line, so IAM policies on ssm:PutParameter
+control who can push keys to which instances and users. Only the
+users given with --user accept keys.
+
+Pushed keys are authorized for --ttl after the parameter was last
+modified. They're written to the user's authorized_keys with
+OpenSSH's expiry-time option, so sshd refuses them once they
+expire, and they're removed when they expire or their parameter
+is deleted. The user's own keys are left as they are.
+`),
+	}
+
+	cmd.AddCommand(sshEphemeralSyncCommand(), sshEphemeralServeCommand(), sshEphemeralListCommand())
+
+	return cmd
+}
+
+// addEphemeralKeysFlags adds the flags used to select the pushed keys to the command.
+func addEphemeralKeysFlags(cmd *cobra.Command, args *ephemeralKeysSettings) {
+	cmd.PersistentFlags().StringVar(&args.path, "path", "", "Parameter Store path holding the keys, "+ephemeralKeysParameterRoot+"/<instance-id> when empty")
+	cmd.PersistentFlags().StringSliceVar(&args.users, "user", []string{defaultSSHUser}, "user that accepts pushed keys, may be repeated")
+	cmd.PersistentFlags().DurationVar(&args.ttl, "ttl", ephemeralKeysDefaultTTL, "time that pushed keys are authorized for (e.g. 5m, 1h)")
+}
+
+// sshEphemeralSyncCommand creates a new command which syncs the ephemeral keys once.
+func sshEphemeralSyncCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "authorize the pushed keys and remove expired ones once",
+		Args:  cobra.NoArgs,
+	}
+
+	ephemeralArgs := ephemeralKeysSettings{}
+	addEphemeralKeysFlags(cmd, &ephemeralArgs)
+	var dryrun bool
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&ephemeralArgs.timeout, "timeout", sshDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Writing other users' authorized keys requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, ephemeralArgs.timeout, func(ctx context.Context) error {
+			source, err := aws.NewClientFromMetadata(ctx)
+			if err != nil {
+				return err
+			}
+			t, err := ephemeralKeysTask(ctx, source, ephemeralArgs)
+			if err != nil {
+				return err
+			}
+
+			return applyTask(ctx, cmd, t, dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// sshEphemeralServeCommand creates a new command which syncs the ephemeral keys on an interval.
+func sshEphemeralServeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "sync the pushed keys on an interval",
+		Long: strings.TrimSpace(`
+serve runs until it's stopped, syncing the pushed keys every
+--interval so that they can be used shortly after they're pushed
+and are removed shortly after they expire or are revoked. Keys
+stay authorized until they expire while Parameter Store can't be
+reached. serve is meant to be run by a launchd daemon.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	ephemeralArgs := ephemeralKeysSettings{}
+	addEphemeralKeysFlags(cmd, &ephemeralArgs)
+	var interval time.Duration
+	cmd.PersistentFlags().DurationVar(&interval, "interval", ephemeralKeysDefaultInterval, "time between syncs (e.g. 10s, 1m)")
+	cmd.PersistentFlags().DurationVar(&ephemeralArgs.timeout, "timeout", sshDefaultTimeout, "Set the timeout for each sync (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Writing other users' authorized keys requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if interval <= 0 {
+			return errors.New("interval must be positive")
+		}
+		source, err := aws.NewClientFromMetadata(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		logrus.WithField("interval", interval).Info("Syncing ephemeral SSH keys")
+		for {
+			if err := syncEphemeralKeys(cmd.Context(), source, ephemeralArgs); err != nil {
+				logrus.WithError(err).Error("Failed to sync ephemeral SSH keys")
+			}
+
+			select {
+			case <-cmd.Context().Done():
+				return nil
+			case <-time.After(interval):
+			}
+		}
+	}
+
+	return cmd
+}
+
+// sshEphemeralListCommand creates a new command which lists the ephemeral keys that are authorized.
+func sshEphemeralListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list the authorized ephemeral keys",
+		Args:  cobra.NoArgs,
+	}
+
+	var names []string
+	var timeout time.Duration
+	cmd.PersistentFlags().StringSliceVar(&names, "user", []string{defaultSSHUser}, "user whose ephemeral keys are listed, may be repeated")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", sshDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Reading other users' authorized keys requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			keys := []userEphemeralKey{}
+			for _, name := range names {
+				u, err := users.Lookup(ctx, name)
+				if err != nil {
+					return err
+				}
+				userKeys, err := users.EphemeralKeys(u)
+				if err != nil {
+					return err
+				}
+				for _, key := range userKeys {
+					keys = append(keys, userEphemeralKey{User: u.Name, EphemeralKey: key})
+				}
+			}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), keys, func(w io.Writer) error {
+				return printEphemeralKeys(w, keys, time.Now())
+			})
+		})
+	}
+
+	return cmd
+}
+
+// syncEphemeralKeys applies the pushed keys once, logging the changes that were made.
+func syncEphemeralKeys(ctx context.Context, source parameterSource, args ephemeralKeysSettings) error {
+	if args.timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, args.timeout)
+		defer cancel()
+	}
+
+	t, err := ephemeralKeysTask(ctx, source, args)
+	if err != nil {
+		return err
+	}
+	changes, err := t.Apply(ctx)
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		if c.Desired == "" {
+			logrus.WithField("key", c.Setting).Info("Removed ephemeral SSH key")
+		} else {
+			logrus.WithFields(logrus.Fields{"key": c.Setting, "until": strings.TrimPrefix(c.Desired, "authorized until ")}).Info("Authorized ephemeral SSH key")
+		}
+	}
+
+	return nil
+}
+
+// ephemeralKeysTask builds the task that authorizes the keys pushed for each of the users. Keys pushed for other
+// users are ignored.
+func ephemeralKeysTask(ctx context.Context, source parameterSource, args ephemeralKeysSettings) (task.Task, error) {
+	if args.ttl <= 0 {
+		return nil, errors.New("ttl must be positive")
+	}
+	parameterPath := args.path
+	if parameterPath == "" {
+		id, err := imds.NewCachedClient().InstanceID(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get instance ID: %w", err)
+		}
+		parameterPath = path.Join(ephemeralKeysParameterRoot, id)
+	}
+
+	params, err := source.GetParametersByPath(ctx, parameterPath)
+	if err != nil {
+		return nil, err
+	}
+	pushed := pushedEphemeralKeys(params, args.users, args.ttl)
+
+	tasks := make([]task.Task, 0, len(args.users))
+	for _, name := range args.users {
+		u, err := users.Lookup(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, &users.EphemeralKeysTask{User: u, Keys: pushed[name]})
+	}
+
+	return task.NewGroup("ephemeral-ssh-keys", tasks...), nil
+}
+
+// pushedEphemeralKeys gets the keys pushed for each of the users from the parameters named after them. Each key
+// expires ttl after its parameter was last modified.
+func pushedEphemeralKeys(params []aws.Parameter, names []string, ttl time.Duration) map[string][]users.EphemeralKey {
+	allowed := map[string]bool{}
+	for _, name := range names {
+		allowed[name] = true
+	}
+
+	pushed := map[string][]users.EphemeralKey{}
+	for _, p := range params {
+		name := path.Base(p.Name)
+		if !allowed[name] {
+			logrus.WithField("parameter", p.Name).Warn("Ignoring keys pushed for a user that doesn't accept them")
+			continue
+		}
+		for _, line := range strings.Split(p.Value, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			pushed[name] = append(pushed[name], users.EphemeralKey{Key: line, Expires: p.LastModified.Add(ttl)})
+		}
+	}
+
+	return pushed
+}
+
+// printEphemeralKeys writes a table of the ephemeral keys and the time left until they expire to w.
+func printEphemeralKeys(w io.Writer, keys []userEphemeralKey, now time.Time) error {
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].User < keys[j].User
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "USER\tKEY\tEXPIRES\tREMAINING")
+	for _, k := range keys {
+		remaining := "expired"
+		if k.Expires.After(now) {
+			remaining = k.Expires.Sub(now).Round(time.Second).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", k.User, ephemeralKeyName(k.Key), k.Expires.UTC().Format(time.RFC3339), remaining)
+	}
+
+	return tw.Flush()
+}
+
+// ephemeralKeyName names the key in tables by its comment, falling back to its type.
+func ephemeralKeyName(key string) string {
+	fields := strings.Fields(key)
+	if len(fields) > 2 {
+		return strings.Join(fields[2:], " ")
+	}
+
+	return fields[0]
+}
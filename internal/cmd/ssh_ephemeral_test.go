@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/users"
+)
+
+func TestPushedEphemeralKeys(t *testing.T) {
+	modified := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	params := []aws.Parameter{
+		{Name: "/ec2-macos-utils/ephemeral-keys/i-0123456789abcdef0/ec2-user", Value: "ssh-ed25519 AAAA alice\n\n# comment\nssh-rsa BBBB bob\n", LastModified: modified},
+		{Name: "/ec2-macos-utils/ephemeral-keys/i-0123456789abcdef0/root", Value: "ssh-ed25519 CCCC mallory", LastModified: modified},
+	}
+
+	pushed := pushedEphemeralKeys(params, []string{"ec2-user"}, 15*time.Minute)
+
+	assert.Equal(t, map[string][]users.EphemeralKey{
+		"ec2-user": {
+			{Key: "ssh-ed25519 AAAA alice", Expires: modified.Add(15 * time.Minute)},
+			{Key: "ssh-rsa BBBB bob", Expires: modified.Add(15 * time.Minute)},
+		},
+	}, pushed, "keys for users that don't accept them should be ignored")
+}
+
+func TestPrintEphemeralKeys(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	keys := []userEphemeralKey{
+		{User: "ec2-user", EphemeralKey: users.EphemeralKey{Key: "ssh-ed25519 AAAA alice", Expires: now.Add(10 * time.Minute)}},
+		{User: "ci", EphemeralKey: users.EphemeralKey{Key: "ssh-rsa BBBB", Expires: now.Add(-time.Minute)}},
+	}
+
+	assert.NoError(t, printEphemeralKeys(&buf, keys, now))
+	assert.Equal(t, "USER      KEY      EXPIRES               REMAINING\n"+
+		"ci        ssh-rsa  2026-10-15T11:59:00Z  expired\n"+
+		"ec2-user  alice    2026-10-15T12:10:00Z  10m0s\n", buf.String())
+}
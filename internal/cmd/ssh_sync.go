@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/fetch"
+	"github.com/aws/ec2-macos-utils/internal/keysync"
+	"github.com/aws/ec2-macos-utils/internal/users"
+)
+
+// sshSyncDefaultTimeout is the default maximum run duration for syncing accounts and their keys.
+const sshSyncDefaultTimeout = 5 * time.Minute
+
+// sshSyncSettings is a struct for holding all information passed into the ssh sync command.
+type sshSyncSettings struct {
+	manifest string
+	iamGroup string
+	dryrun   bool
+	timeout  time.Duration
+}
+
+// sshSyncCommand creates a new command which syncs local accounts and their keys from the central sources.
+func sshSyncCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "sync local accounts and their SSH keys from IAM or a manifest",
+		Long: strings.TrimSpace(`
+sync maps centrally managed users to local accounts and the SSH
+keys authorized to log in as them, so that team access to shared
+hosts is managed in one place. Users are read from a YAML or JSON
+manifest, from a file or S3, and from the members of an IAM
+group, whose active SSH public keys are authorized. IAM user names
+are mapped to short names by dropping any "@" suffix, lower-casing
+them, and replacing other characters with "_".
+
+Accounts that don't exist are created with a random password.
+Synced keys are marked in authorized_keys and are the only ones
+replaced, so users' own keys are left as they are. Users that are
+no longer listed have their synced keys removed, but their
+accounts are kept. Accounts that belong to macOS are never
+changed.
+
+Sources are read from the ssh section's sync entry of the
+configuration file and can be overridden with flags. sync can be
+run periodically with schedule.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	syncArgs := sshSyncSettings{}
+	cmd.PersistentFlags().StringVar(&syncArgs.manifest, "manifest", "", "path or S3 URI of the manifest listing the users")
+	cmd.PersistentFlags().StringVar(&syncArgs.iamGroup, "iam-group", "", "IAM group whose members are synced")
+	cmd.PersistentFlags().BoolVar(&syncArgs.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&syncArgs.timeout, "timeout", sshSyncDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Creating users and writing their authorized keys requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, syncArgs.timeout, func(ctx context.Context) error {
+			c, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			conf := sshSyncConfig(cmd, c.SSH.Sync, syncArgs)
+			if conf.Manifest == "" && conf.IAMGroup == "" {
+				return errors.New("no sync sources configured, set them in the configuration file or with --manifest and --iam-group")
+			}
+
+			client, err := aws.NewClientFromMetadata(ctx)
+			if err != nil {
+				return err
+			}
+			accounts, err := syncAccounts(ctx, client, &fetch.Fetcher{}, conf)
+			if err != nil {
+				return err
+			}
+			previous, err := users.SyncedUsers()
+			if err != nil {
+				return err
+			}
+
+			return applyTask(ctx, cmd, keysync.NewTask(accounts, previous), syncArgs.dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// sshSyncConfig merges the configured sources with the flags that were set. Flags take precedence over the
+// configuration.
+func sshSyncConfig(cmd *cobra.Command, conf config.SSHSync, args sshSyncSettings) config.SSHSync {
+	if cmd.Flags().Changed("manifest") {
+		conf.Manifest = args.manifest
+	}
+	if cmd.Flags().Changed("iam-group") {
+		conf.IAMGroup = args.iamGroup
+	}
+
+	return conf
+}
+
+// syncAccounts reads the accounts from the manifest and the IAM group and resolves the keys of their IAM users.
+// Accounts listed in the manifest take precedence over the IAM group's members with the same name, which only add
+// their keys.
+func syncAccounts(ctx context.Context, src keysync.KeySource, fetcher *fetch.Fetcher, conf config.SSHSync) ([]keysync.Account, error) {
+	var manifest []keysync.Account
+	if conf.Manifest != "" {
+		var data []byte
+		var err error
+		if aws.IsS3URI(conf.Manifest) {
+			data, err = fetchS3Object(ctx, fetcher, conf.Manifest, "")
+		} else {
+			data, err = os.ReadFile(conf.Manifest)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read manifest %s: %w", conf.Manifest, err)
+		}
+		m, err := keysync.ParseManifest(data)
+		if err != nil {
+			return nil, err
+		}
+		manifest = m.Users
+	}
+
+	var members []keysync.Account
+	if conf.IAMGroup != "" {
+		var err error
+		if members, err = keysync.FromIAMGroup(ctx, src, conf.IAMGroup); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := keysync.ResolveKeys(ctx, src, manifest); err != nil {
+		return nil, err
+	}
+	if err := keysync.ResolveKeys(ctx, src, members); err != nil {
+		return nil, err
+	}
+
+	return keysync.Merge(manifest, members), nil
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/fetch"
+	"github.com/aws/ec2-macos-utils/internal/keysync"
+)
+
+type fakeKeySource struct {
+	groups map[string][]string
+	keys   map[string][]string
+}
+
+func (f *fakeKeySource) GroupUsers(ctx context.Context, group string) ([]string, error) {
+	return f.groups[group], nil
+}
+
+func (f *fakeKeySource) SSHPublicKeys(ctx context.Context, user string) ([]string, error) {
+	return f.keys[user], nil
+}
+
+func TestSyncAccounts(t *testing.T) {
+	manifest := filepath.Join(t.TempDir(), "users.yaml")
+	assert.NoError(t, os.WriteFile(manifest, []byte(`
+users:
+  - name: alice
+    admin: true
+    authorized_keys: [ssh-ed25519 AAAA laptop]
+`), 0600))
+	src := &fakeKeySource{
+		groups: map[string][]string{"mac-users": {"alice@example.com", "bob"}},
+		keys:   map[string][]string{"alice@example.com": {"ssh-ed25519 BBBB iam"}, "bob": {"ssh-rsa CCCC bob"}},
+	}
+
+	accounts, err := syncAccounts(context.Background(), src, &fetch.Fetcher{}, config.SSHSync{Manifest: manifest, IAMGroup: "mac-users"})
+
+	admin := true
+	assert.NoError(t, err)
+	assert.Equal(t, []keysync.Account{
+		{Name: "alice", Admin: &admin, AuthorizedKeys: []string{"ssh-ed25519 AAAA laptop", "ssh-ed25519 BBBB iam"}},
+		{Name: "bob", IAMUser: "bob", AuthorizedKeys: []string{"ssh-rsa CCCC bob"}},
+	}, accounts, "manifest entries should take precedence over IAM group members")
+}
+
+func TestSyncAccounts_MissingManifest(t *testing.T) {
+	_, err := syncAccounts(context.Background(), &fakeKeySource{}, &fetch.Fetcher{}, config.SSHSync{Manifest: filepath.Join(t.TempDir(), "missing.yaml")})
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+)
+
+func TestSSHServerTask_Defaults(t *testing.T) {
+	task, err := sshServerTask(config.SSHServer{AllowUsers: []string{"ec2-user"}})
+
+	assert.NoError(t, err)
+	assert.False(t, *task.Settings.PasswordAuthentication, "password authentication should be denied by default")
+	assert.Equal(t, "no", task.Settings.PermitRootLogin)
+	assert.Equal(t, []string{"ec2-user"}, task.Settings.AllowUsers)
+	assert.Equal(t, 60, task.Settings.ClientAliveInterval)
+	assert.Equal(t, 3, task.Settings.ClientAliveCountMax)
+}
+
+func TestSSHServerConfig_FlagsOverrideConfig(t *testing.T) {
+	enabled := true
+	sshArgs := sshServerSettings{}
+	cmd := &cobra.Command{}
+	addSSHServerFlags(cmd, &sshArgs)
+	assert.NoError(t, cmd.ParseFlags([]string{"--password-auth=false", "--allow-user", "ci"}))
+
+	settings, err := sshServerConfig(config.SSHServer{
+		PasswordAuthentication: &enabled,
+		PermitRootLogin:        "prohibit-password",
+		AllowUsers:             []string{"ec2-user"},
+	}, sshArgs, cmd.Flags().Changed)
+
+	assert.NoError(t, err)
+	assert.False(t, *settings.PasswordAuthentication, "flags should override config")
+	assert.Equal(t, "prohibit-password", settings.PermitRootLogin, "config should override defaults")
+	assert.Equal(t, []string{"ci"}, settings.AllowUsers)
+}
+
+func TestSSHServerConfig_InvalidPermitRootLogin(t *testing.T) {
+	_, err := sshServerTask(config.SSHServer{PermitRootLogin: "maybe"})
+
+	assert.Error(t, err)
+}
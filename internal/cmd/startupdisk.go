@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/startupdisk"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// startupDiskDefaultTimeout is the default maximum run duration for managing the startup disk.
+const startupDiskDefaultTimeout = 2 * time.Minute
+
+// setStartupDisk is a struct for holding all information passed into the startupdisk set command.
+type setStartupDisk struct {
+	dryrun   bool
+	force    bool
+	password passwordSource
+	timeout  time.Duration
+	user     string
+}
+
+// startupDiskChange is the result of the startupdisk set command, with the command that boots the previous
+// startup disk again.
+type startupDiskChange struct {
+	Previous string `json:"previous"`
+	Current  string `json:"current"`
+	Changed  bool   `json:"changed"`
+	Rollback string `json:"rollback,omitempty"`
+}
+
+// startupdiskCommand creates a new command which groups the startup disk subcommands.
+func startupdiskCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "startupdisk",
+		Short: "list bootable volumes and set the startup disk",
+		Long: strings.TrimSpace(`
+startupdisk lists the volumes the host can boot from with
+systemsetup(8) and sets the startup disk with bless(8), e.g. to
+boot a system volume that an AMI build pipeline staged on a
+secondary disk. Changes take effect on the next boot. Apple
+silicon requires an administrator's name and password to change
+the startup disk.
+`),
+	}
+
+	cmd.AddCommand(startupdiskListCommand(), startupdiskSetCommand())
+
+	return cmd
+}
+
+// startupdiskListCommand creates a new command which prints the volumes that the host can boot from.
+func startupdiskListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "print the volumes the host can boot from",
+		Args:  cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", startupDiskDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Listing startup disks with systemsetup requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			volumes, err := startupdisk.List(ctx)
+			if err != nil {
+				return err
+			}
+
+			return printStartupVolumes(cmd.OutOrStdout(), outputFormat(cmd), volumes)
+		})
+	}
+
+	return cmd
+}
+
+// startupdiskSetCommand creates a new command which sets the volume that the host boots from.
+func startupdiskSetCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set <volume>",
+		Short: "set the volume the host boots from",
+		Long: strings.TrimSpace(`
+set makes the volume the startup disk for the next boot. The
+volume can be a mount point (e.g. "/Volumes/Macintosh HD 2") or
+any reference to a mounted volume that diskutil accepts (e.g.
+its identifier or label) and must be listed by startupdisk list.
+A host that boots from the wrong volume may not come back so
+--force is required. The previous startup disk and the command
+that sets it again are printed for rolling the change back.
+`),
+		Args: cobra.ExactArgs(1),
+	}
+
+	setArgs := setStartupDisk{}
+	cmd.PersistentFlags().StringVar(&setArgs.user, "user", "", "administrator authorizing the change, required on Apple silicon")
+	addPasswordFlags(cmd, &setArgs.password, "password")
+	cmd.PersistentFlags().BoolVar(&setArgs.force, "force", false, "confirm that the host should boot from the volume")
+	cmd.PersistentFlags().BoolVar(&setArgs.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&setArgs.timeout, "timeout", startupDiskDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Setting the startup disk with bless requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, setArgs.timeout, func(ctx context.Context) error {
+			product := contextual.Product(ctx)
+			if product == nil {
+				return errors.New("product required in context")
+			}
+
+			d, err := diskutil.ForProduct(product)
+			if err != nil {
+				return err
+			}
+
+			change, err := runSetStartupDisk(ctx, cmd, d, args[0], setArgs)
+			if err != nil {
+				return err
+			}
+
+			return printStartupDiskChange(cmd.OutOrStdout(), outputFormat(cmd), change)
+		})
+	}
+
+	// Keep other disk operations on the host from running alongside this one.
+	lockDiskOperation(cmd)
+
+	return cmd
+}
+
+// runSetStartupDisk makes the volume the startup disk unless it already is, returning the previous startup disk and
+// how to set it again.
+func runSetStartupDisk(ctx context.Context, cmd *cobra.Command, utility diskutil.DiskUtil, ref string, args setStartupDisk) (*startupDiskChange, error) {
+	volumes, err := startupdisk.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	target, err := resolveStartupVolume(ctx, utility, ref, volumes)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := currentStartupVolume(volumes)
+	if err != nil {
+		return nil, err
+	}
+	change := &startupDiskChange{Previous: previous.MountPoint, Current: target.MountPoint}
+	if target.Current {
+		logrus.WithField("mount_point", target.MountPoint).Info("Volume is already the startup disk, nothing to do")
+		return change, nil
+	}
+	change.Changed = true
+	change.Rollback = rollbackStartupDiskCommand(previous.MountPoint)
+
+	if !args.force && !args.dryrun {
+		return nil, fmt.Errorf("the host will boot from %s, run with --force to confirm", target.MountPoint)
+	}
+
+	creds, err := startupDiskCredentials(ctx, cmd, args)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := logrus.Fields{
+		"previous":    previous.MountPoint,
+		"mount_point": target.MountPoint,
+		"rollback":    change.Rollback,
+	}
+	if args.dryrun {
+		logrus.WithFields(fields).Warn("Would have set startup disk")
+		return change, nil
+	}
+
+	if err := startupdisk.Set(ctx, target.MountPoint, creds); err != nil {
+		return nil, err
+	}
+	logrus.WithFields(fields).Info("Successfully set startup disk, reboot for it to take effect")
+
+	return change, nil
+}
+
+// resolveStartupVolume finds the bootable volume for the reference, which is either a mount point or CoreServices
+// folder of one of the volumes or a reference to a mounted volume that diskutil.Resolve accepts.
+func resolveStartupVolume(ctx context.Context, utility diskutil.DiskUtil, ref string, volumes []startupdisk.Volume) (startupdisk.Volume, error) {
+	mountPoint := ref
+	if filepath.IsAbs(ref) {
+		mountPoint = startupdisk.MountPoint(ref)
+	} else {
+		id, err := diskutil.Resolve(ctx, utility, ref)
+		if err != nil {
+			return startupdisk.Volume{}, fmt.Errorf("invalid volume: %w", err)
+		}
+		disk, err := utility.Info(ctx, id)
+		if err != nil {
+			return startupdisk.Volume{}, fmt.Errorf("unable to get volume information: %w", err)
+		}
+		if disk.MountPoint == "" {
+			return startupdisk.Volume{}, fmt.Errorf("volume [%s] is not mounted", disk.DeviceIdentifier)
+		}
+		mountPoint = disk.MountPoint
+	}
+
+	for _, v := range volumes {
+		if v.MountPoint == filepath.Clean(mountPoint) {
+			return v, nil
+		}
+	}
+
+	return startupdisk.Volume{}, fmt.Errorf("%s is not a bootable volume, see startupdisk list", mountPoint)
+}
+
+// currentStartupVolume finds the startup disk in the bootable volumes.
+func currentStartupVolume(volumes []startupdisk.Volume) (startupdisk.Volume, error) {
+	for _, v := range volumes {
+		if v.Current {
+			return v, nil
+		}
+	}
+
+	return startupdisk.Volume{}, errors.New("the startup disk isn't one of the bootable volumes")
+}
+
+// startupDiskCredentials reads the administrator's credentials that Apple silicon requires for changing the startup
+// disk, Intel instances don't need them.
+func startupDiskCredentials(ctx context.Context, cmd *cobra.Command, args setStartupDisk) (*startupdisk.Credentials, error) {
+	appleSilicon, err := system.AppleSilicon(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot detect processor: %w", err)
+	}
+	if !appleSilicon {
+		return nil, nil
+	}
+	if args.user == "" || !args.password.isSet() {
+		return nil, errors.New("--user and one of --password-secret, --password-parameter, or --password-stdin are required to set the startup disk on Apple silicon")
+	}
+
+	password, err := args.password.read(ctx, cmd.InOrStdin())
+	if err != nil {
+		return nil, err
+	}
+
+	return &startupdisk.Credentials{User: args.user, Password: password}, nil
+}
+
+// rollbackStartupDiskCommand gets the command that makes the volume mounted at the mount point the startup disk again.
+func rollbackStartupDiskCommand(mountPoint string) string {
+	return fmt.Sprintf("sudo ec2-macos-utils startupdisk set %q --force", mountPoint)
+}
+
+// printStartupVolumes writes a table of the bootable volumes to w.
+func printStartupVolumes(w io.Writer, format string, volumes []startupdisk.Volume) error {
+	if volumes == nil {
+		volumes = []startupdisk.Volume{}
+	}
+
+	return printOutput(w, format, volumes, func(w io.Writer) error {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "MOUNT POINT\tPATH\tCURRENT")
+		for _, v := range volumes {
+			current := ""
+			if v.Current {
+				current = "*"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", v.MountPoint, v.Path, current)
+		}
+
+		return tw.Flush()
+	})
+}
+
+// printStartupDiskChange writes the previous and new startup disks, and the command to roll back, to w.
+func printStartupDiskChange(w io.Writer, format string, change *startupDiskChange) error {
+	return printOutput(w, format, change, func(w io.Writer) error {
+		if !change.Changed {
+			_, err := fmt.Fprintf(w, "startup disk: %s (unchanged)\n", change.Current)
+			return err
+		}
+		fmt.Fprintf(w, "previous startup disk: %s\n", change.Previous)
+		fmt.Fprintf(w, "startup disk: %s\n", change.Current)
+		_, err := fmt.Fprintf(w, "roll back with: %s\n", change.Rollback)
+		return err
+	})
+}
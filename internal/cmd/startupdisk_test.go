@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/startupdisk"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/diskutilfakes"
+)
+
+func testStartupVolumes() []startupdisk.Volume {
+	return []startupdisk.Volume{
+		{Path: "/System/Library/CoreServices", MountPoint: "/", Current: true},
+		{Path: "/Volumes/Staged HD/System/Library/CoreServices", MountPoint: "/Volumes/Staged HD"},
+	}
+}
+
+func TestResolveStartupVolume(t *testing.T) {
+	ctx := context.Background()
+	fake := diskutilfakes.New(fakeBootDisk())
+	volumes := testStartupVolumes()
+
+	v, err := resolveStartupVolume(ctx, fake, "/Volumes/Staged HD", volumes)
+	assert.NoError(t, err)
+	assert.Equal(t, volumes[1], v, "mount points should match their volume")
+
+	v, err = resolveStartupVolume(ctx, fake, "/Volumes/Staged HD/System/Library/CoreServices", volumes)
+	assert.NoError(t, err)
+	assert.Equal(t, volumes[1], v, "CoreServices folders should match their volume")
+
+	v, err = resolveStartupVolume(ctx, fake, "Macintosh HD", volumes)
+	assert.NoError(t, err)
+	assert.Equal(t, volumes[0], v, "volume labels should resolve to their mount point")
+
+	_, err = resolveStartupVolume(ctx, fake, "/Volumes/Data", volumes)
+	assert.Error(t, err, "volumes that can't be booted from should be rejected")
+
+	_, err = resolveStartupVolume(ctx, fake, "disk0s1", volumes)
+	assert.Error(t, err, "unmounted volumes can't be booted from")
+}
+
+func TestCurrentStartupVolume(t *testing.T) {
+	v, err := currentStartupVolume(testStartupVolumes())
+	assert.NoError(t, err)
+	assert.Equal(t, "/", v.MountPoint)
+
+	_, err = currentStartupVolume(testStartupVolumes()[1:])
+	assert.Error(t, err)
+}
+
+func TestPrintStartupVolumes(t *testing.T) {
+	var text bytes.Buffer
+	assert.NoError(t, printStartupVolumes(&text, outputText, testStartupVolumes()))
+	assert.Equal(t, "MOUNT POINT         PATH                                            CURRENT\n"+
+		"/                   /System/Library/CoreServices                    *\n"+
+		"/Volumes/Staged HD  /Volumes/Staged HD/System/Library/CoreServices  \n", text.String())
+
+	var out bytes.Buffer
+	assert.NoError(t, printStartupVolumes(&out, outputJSON, nil))
+	assert.Equal(t, "[]\n", out.String())
+}
+
+func TestPrintStartupDiskChange(t *testing.T) {
+	change := &startupDiskChange{
+		Previous: "/",
+		Current:  "/Volumes/Staged HD",
+		Changed:  true,
+		Rollback: rollbackStartupDiskCommand("/"),
+	}
+
+	var text bytes.Buffer
+	assert.NoError(t, printStartupDiskChange(&text, outputText, change))
+	assert.Equal(t, "previous startup disk: /\n"+
+		"startup disk: /Volumes/Staged HD\n"+
+		"roll back with: sudo ec2-macos-utils startupdisk set \"/\" --force\n", text.String())
+
+	var out bytes.Buffer
+	assert.NoError(t, printStartupDiskChange(&out, outputJSON, &startupDiskChange{Previous: "/", Current: "/"}))
+	assert.NotContains(t, out.String(), "rollback", "unchanged startup disks have nothing to roll back")
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/preflight"
+	"github.com/aws/ec2-macos-utils/internal/state"
+)
+
+var (
+	// stateDir is the versioned state directory, it's replaced in tests.
+	stateDir = state.DefaultDir
+	// legacyStateDir is where earlier releases kept their state, it's replaced in tests.
+	legacyStateDir = state.LegacyDir
+)
+
+// noStateAnnotation marks commands that don't use the state directory, which isn't migrated for them.
+const noStateAnnotation = "ec2-macos-utils/no-state"
+
+// stateReport is the schema version of the state directory.
+type stateReport struct {
+	// Dir is the state directory.
+	Dir string `json:"dir"`
+	// Version is the directory's schema version.
+	Version int `json:"version"`
+	// Latest is the schema version that this release migrates the directory to.
+	Latest int `json:"latest"`
+	// Pending are the migrations that haven't been applied.
+	Pending []state.Migration `json:"pending"`
+	// Applied are the migrations that were applied by this run.
+	Applied []state.Migration `json:"applied,omitempty"`
+}
+
+// stateCommand creates a new command which groups the state directory subcommands.
+func stateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "state",
+		Short: "report and migrate the utility's state directory",
+		Long: strings.TrimSpace(`
+state reports and migrates the directory that the utility keeps
+its state in (e.g. the operation journal and the telemetry
+counters). The directory has a schema version so that releases
+can change how their state is kept, and each release migrates it
+to the version it supports. Migrations run automatically before
+any command that's run as root, except for these and for runs
+with --dry-run or --plan.
+`),
+	}
+
+	cmd.AddCommand(stateStatusCommand(), stateMigrateCommand())
+
+	return cmd
+}
+
+// stateStatusCommand creates a new command which reports the state directory's schema version.
+func stateStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "report the state directory's schema version",
+		Long: strings.TrimSpace(`
+status reports the schema version of the state directory, the
+version that this release supports, and the migrations that
+haven't been applied. Nothing is changed.
+`),
+		Args: cobra.NoArgs,
+		// The directory is reported as it is rather than after it's migrated.
+		Annotations: map[string]string{noStateAnnotation: "true"},
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		d := newStateDir()
+		version, err := d.Version()
+		if err != nil {
+			return err
+		}
+		pending, err := d.Pending()
+		if err != nil {
+			return err
+		}
+		report := &stateReport{Dir: d.Path, Version: version, Latest: d.Latest(), Pending: pending}
+		if report.Pending == nil {
+			report.Pending = []state.Migration{}
+		}
+
+		return printOutput(cmd.OutOrStdout(), outputFormat(cmd), report, func(w io.Writer) error {
+			return printState(w, report)
+		})
+	}
+
+	return cmd
+}
+
+// stateMigrateCommand creates a new command which migrates the state directory.
+func stateMigrateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "migrate the state directory to this release's schema",
+		Long: strings.TrimSpace(`
+migrate applies the migrations that haven't been applied to the
+state directory, creating it when it doesn't exist, and reports
+the ones that were applied. A migration that fails is retried by
+the next run without repeating the ones that completed. State
+from a newer release than this one isn't migrated.
+`),
+		Args: cobra.NoArgs,
+		// The directory is only migrated by the command itself so that the migrations it applies are reported.
+		Annotations: map[string]string{noStateAnnotation: "true"},
+	}
+
+	// Migrations move state that's only writable by root.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		d := newStateDir()
+		applied, err := d.Migrate()
+		if err != nil {
+			return err
+		}
+		version, err := d.Version()
+		if err != nil {
+			return err
+		}
+		report := &stateReport{Dir: d.Path, Version: version, Latest: d.Latest(), Pending: []state.Migration{}, Applied: applied}
+
+		return printOutput(cmd.OutOrStdout(), outputFormat(cmd), report, func(w io.Writer) error {
+			return printState(w, report)
+		})
+	}
+
+	return cmd
+}
+
+// migrateState migrates the state directory before the command runs, unless it's annotated with noStateAnnotation or
+// run with --dry-run or --plan, which shouldn't change anything. Runs without root permissions can't migrate the
+// directory so they use it as it is. Failures are logged rather than stopping the command since most commands don't
+// use the state directory.
+func migrateState(cmd *cobra.Command) {
+	if _, ok := cmd.Annotations[noStateAnnotation]; ok {
+		return
+	}
+	if readonlyRun(cmd) {
+		logrus.Debug("Not migrating the state directory for a dry run")
+		return
+	}
+	if err := preflight.Root(); err != nil {
+		logrus.Debug("Not migrating the state directory without root privileges")
+		return
+	}
+
+	applied, err := newStateDir().Migrate()
+	for _, m := range applied {
+		logrus.WithFields(logrus.Fields{"version": m.Version, "migration": m.Description}).Info("Migrated state directory")
+	}
+	if err != nil {
+		logrus.WithError(err).Warn("Unable to migrate the state directory, run 'state migrate' to retry")
+	}
+}
+
+// newStateDir creates the Dir for the state directory with the utility's migrations.
+func newStateDir() *state.Dir {
+	return &state.Dir{Path: stateDir, Migrations: state.Migrations(legacyStateDir)}
+}
+
+// printState writes the report to w with a line for each migration.
+func printState(w io.Writer, report *stateReport) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Directory:\t%s\n", report.Dir)
+	fmt.Fprintf(tw, "Schema version:\t%d (latest %d)\n", report.Version, report.Latest)
+	for _, m := range report.Applied {
+		fmt.Fprintf(tw, "Applied:\t%d\t%s\n", m.Version, m.Description)
+	}
+	for _, m := range report.Pending {
+		fmt.Fprintf(tw, "Pending:\t%d\t%s\n", m.Version, m.Description)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/state"
+)
+
+func init() {
+	// Don't migrate the host's state directory in tests
+	stateDir = filepath.Join(os.TempDir(), "ec2-macos-utils-test-state-"+strconv.Itoa(os.Getpid()))
+	legacyStateDir = filepath.Join(os.TempDir(), "ec2-macos-utils-test-legacy-"+strconv.Itoa(os.Getpid()))
+}
+
+func TestMigrateState_NoState(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	defer func(d string) { stateDir = d }(stateDir)
+	stateDir = dir
+
+	migrateState(&cobra.Command{Use: "uninstall", Annotations: map[string]string{noStateAnnotation: "true"}})
+
+	_, err := os.Stat(dir)
+	assert.True(t, os.IsNotExist(err), "annotated commands shouldn't create the state directory")
+}
+
+func TestMigrateState_DryRun(t *testing.T) {
+	for _, name := range []string{"dry-run", "plan"} {
+		t.Run(name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "state")
+			defer func(d string) { stateDir = d }(stateDir)
+			stateDir = dir
+			cmd := &cobra.Command{Use: "provision"}
+			cmd.Flags().Bool(name, false, "")
+			assert.NoError(t, cmd.Flags().Set(name, "true"))
+
+			migrateState(cmd)
+
+			_, err := os.Stat(dir)
+			assert.True(t, os.IsNotExist(err), "read-only runs shouldn't create the state directory")
+		})
+	}
+}
+
+func TestPrintState(t *testing.T) {
+	var b strings.Builder
+	report := &stateReport{
+		Dir:     "/var/lib/ec2-macos-utils",
+		Version: 0,
+		Latest:  1,
+		Pending: []state.Migration{{Version: 1, Description: "move the journal"}},
+	}
+
+	assert.NoError(t, printState(&b, report))
+
+	expected := "" +
+		"Directory:       /var/lib/ec2-macos-utils\n" +
+		"Schema version:  0 (latest 1)\n" +
+		"Pending:         1  move the journal\n"
+	assert.Equal(t, expected, b.String())
+}
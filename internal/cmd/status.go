@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/build"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/journal"
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/internal/telemetry"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+)
+
+// statusDefaultTimeout is the default maximum run duration for summarizing the utility's state, which includes
+// checking the configuration for drift.
+const statusDefaultTimeout = 5 * time.Minute
+
+// statusReport summarizes the state of the utility on the host. Each part is gathered on its own so that one that
+// can't be read is reported without hiding the others.
+type statusReport struct {
+	// Version is the utility's version.
+	Version string `json:"version"`
+	// Jobs are the utility's installed launchd jobs.
+	Jobs []jobStatus `json:"jobs"`
+	// JobsError is why the jobs couldn't be listed.
+	JobsError string `json:"jobs_error,omitempty"`
+	// Grow is the state of the root container.
+	Grow growStatus `json:"grow"`
+	// Drift summarizes the drift from the configuration.
+	Drift driftSummary `json:"drift"`
+	// Pending are the operations that didn't complete, see journal.
+	Pending []pendingOperation `json:"pending_operations"`
+	// PendingError is why the pending operations couldn't be read.
+	PendingError string `json:"pending_operations_error,omitempty"`
+	// Commands are the counters of each command's runs.
+	Commands telemetry.Counters `json:"commands"`
+	// CommandsError is why the counters couldn't be read.
+	CommandsError string `json:"commands_error,omitempty"`
+}
+
+// jobStatus is the state of one of the utility's launchd jobs.
+type jobStatus struct {
+	// Label is the job's label.
+	Label string `json:"label"`
+	// State is the state of the job (e.g. "running", "not running", or "not loaded").
+	State string `json:"state"`
+	// Runs is the number of times the job has been started since it was loaded.
+	Runs int `json:"runs"`
+	// LastExitCode is the exit status of the job's last run.
+	LastExitCode string `json:"last_exit_code,omitempty"`
+}
+
+// growStatus is whether the root container can be grown.
+type growStatus struct {
+	// Grown indicates that the container already uses all of its disk's space.
+	Grown bool `json:"grown"`
+	// Actions are the changes that growing the container would make.
+	Actions []diskutil.Action `json:"actions"`
+	// Error is why the container couldn't be checked.
+	Error string `json:"error,omitempty"`
+}
+
+// driftSummary is the number of configured tasks that have drifted.
+type driftSummary struct {
+	// Drifted indicates that at least one task differs from the configuration or couldn't be checked.
+	Drifted bool `json:"drifted"`
+	// Tasks is the number of tasks that were checked.
+	Tasks int `json:"tasks"`
+	// DriftedTasks are the names of the tasks that differ from the configuration or couldn't be checked.
+	DriftedTasks []string `json:"drifted_tasks"`
+	// Error is why the configuration couldn't be checked.
+	Error string `json:"error,omitempty"`
+}
+
+// pendingOperation is an operation that didn't complete.
+type pendingOperation struct {
+	// ID is the operation's journal entry.
+	ID string `json:"id"`
+	// Operation is the operation's name (e.g. "provision").
+	Operation string `json:"operation"`
+	// Started is when the operation started.
+	Started time.Time `json:"started"`
+}
+
+// jobLister lists the utility's launchd jobs and their state so that tests can stand in for launchctl.
+type jobLister interface {
+	Installed() ([]string, error)
+	Status(ctx context.Context, label string) (*launchd.Status, error)
+}
+
+// statusCommand creates a new command which summarizes the state of the utility on the host.
+func statusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "summarize the utility's state on the host",
+		Long: strings.TrimSpace(`
+status summarizes the utility's state on the host in one view
+for spot checks of a fleet's health: the version, the installed
+launchd jobs and the result of their last run, whether the root
+container can be grown, the tasks that have drifted from the
+configuration, the operations that didn't complete, and the
+counters of each command's runs and failures. Nothing is changed.
+Parts that can't be read are reported with why rather than
+failing the command.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", statusDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Checking the configuration for drift (e.g. reading remote login with systemsetup) requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			report := &statusReport{
+				Version: build.Version,
+				Grow:    growStatus{Actions: []diskutil.Action{}},
+				Drift:   driftSummary{DriftedTasks: []string{}},
+			}
+			var err error
+			if report.Jobs, err = jobStatuses(ctx, launchd.NewDaemonManager()); err != nil {
+				report.JobsError = err.Error()
+			}
+			if report.Pending, err = pendingOperations(journal.New(journalDir)); err != nil {
+				report.PendingError = err.Error()
+			}
+			if report.Commands, err = telemetry.New(telemetryPath).Read(); err != nil {
+				report.CommandsError = err.Error()
+			}
+
+			product := contextual.Product(ctx)
+			if product == nil {
+				return errors.New("product required in context")
+			}
+			if d, err := diskutil.ForProduct(product); err != nil {
+				report.Grow.Error = err.Error()
+			} else {
+				report.Grow = rootGrowStatus(ctx, d)
+			}
+
+			if c, err := loadConfig(cmd); err != nil {
+				report.Drift.Error = err.Error()
+			} else if tasks, err := configTasks(ctx, c); err != nil {
+				report.Drift.Error = err.Error()
+			} else {
+				report.Drift = summarizeDrift(checkDrift(ctx, tasks))
+			}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), report, func(w io.Writer) error {
+				return printStatus(w, report)
+			})
+		})
+	}
+
+	return cmd
+}
+
+// jobStatuses gets the state of each of the utility's installed jobs. Jobs that are installed but not loaded are
+// reported as "not loaded".
+func jobStatuses(ctx context.Context, jobs jobLister) ([]jobStatus, error) {
+	labels, err := jobs.Installed()
+	if err != nil {
+		return []jobStatus{}, err
+	}
+
+	statuses := make([]jobStatus, 0, len(labels))
+	for _, label := range labels {
+		status := jobStatus{Label: label, State: "not loaded"}
+		if st, err := jobs.Status(ctx, label); err == nil {
+			status.State, status.Runs, status.LastExitCode = st.State, st.Runs, st.LastExitCode
+		} else if !errors.Is(err, launchd.ErrNotLoaded) {
+			return statuses, err
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// rootGrowStatus checks whether growing the root container would change anything.
+func rootGrowStatus(ctx context.Context, utility diskutil.DiskUtil) growStatus {
+	plan, err := planGrow(ctx, utility, growContainer{id: "root"})
+	if err != nil {
+		return growStatus{Actions: []diskutil.Action{}, Error: err.Error()}
+	}
+
+	actions := plan.Actions
+	if actions == nil {
+		actions = []diskutil.Action{}
+	}
+
+	return growStatus{Grown: plan.Empty(), Actions: actions}
+}
+
+// summarizeDrift gets the names of the tasks that drifted from the report.
+func summarizeDrift(report *driftReport) driftSummary {
+	summary := driftSummary{Drifted: report.Drifted, Tasks: len(report.Tasks), DriftedTasks: []string{}}
+	for _, r := range report.Tasks {
+		if r.Error != "" || len(r.Changes) != 0 {
+			summary.DriftedTasks = append(summary.DriftedTasks, r.Task)
+		}
+	}
+
+	return summary
+}
+
+// pendingOperations reads the operations that didn't complete from the journal.
+func pendingOperations(j *journal.Journal) ([]pendingOperation, error) {
+	entries, err := j.Pending()
+	if err != nil {
+		return []pendingOperation{}, err
+	}
+
+	pending := make([]pendingOperation, 0, len(entries))
+	for _, e := range entries {
+		pending = append(pending, pendingOperation{ID: e.ID, Operation: e.Operation, Started: e.Started})
+	}
+
+	return pending, nil
+}
+
+// printStatus writes the report to w as a section for each part.
+func printStatus(w io.Writer, report *statusReport) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Version:\t%s\n", report.Version)
+
+	grow := "grown"
+	switch {
+	case report.Grow.Error != "":
+		grow = "unknown (" + report.Grow.Error + ")"
+	case !report.Grow.Grown:
+		grow = fmt.Sprintf("can be grown (%d changes)", len(report.Grow.Actions))
+	}
+	fmt.Fprintf(tw, "Root container:\t%s\n", grow)
+
+	drift := "in sync"
+	switch {
+	case report.Drift.Error != "":
+		drift = "unknown (" + report.Drift.Error + ")"
+	case report.Drift.Drifted:
+		drift = fmt.Sprintf("drifted (%s)", strings.Join(report.Drift.DriftedTasks, ", "))
+	}
+	fmt.Fprintf(tw, "Configuration:\t%s\n", drift)
+
+	pending := strconv.Itoa(len(report.Pending))
+	if report.PendingError != "" {
+		pending = "unknown (" + report.PendingError + ")"
+	}
+	fmt.Fprintf(tw, "Pending operations:\t%s\n", pending)
+
+	fmt.Fprintln(tw)
+	if report.JobsError != "" {
+		fmt.Fprintf(tw, "Jobs:\tunknown (%s)\n", report.JobsError)
+	} else {
+		fmt.Fprintln(tw, "JOB\tSTATE\tRUNS\tLAST EXIT")
+		for _, j := range report.Jobs {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", j.Label, j.State, j.Runs, j.LastExitCode)
+		}
+	}
+
+	fmt.Fprintln(tw)
+	if report.CommandsError != "" {
+		fmt.Fprintf(tw, "Commands:\tunknown (%s)\n", report.CommandsError)
+	} else {
+		names := make([]string, 0, len(report.Commands))
+		for name := range report.Commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Fprintln(tw, "COMMAND\tRUNS\tSUCCESSES\tFAILURES\tLAST RUN\tLAST OUTCOME")
+		for _, name := range names {
+			c := report.Commands[name]
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\t%s\n", name, c.Runs, c.Successes, c.Runs-c.Successes, c.LastRun.Format(time.RFC3339), c.LastOutcome)
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/journal"
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/internal/telemetry"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+)
+
+func TestJobStatuses(t *testing.T) {
+	daemons := &fakeDaemons{states: map[string]string{
+		"com.amazon.ec2.macos-utils.metrics": "running",
+		"com.amazon.ec2.macos-utils.network": "not running",
+	}}
+
+	statuses, err := jobStatuses(context.Background(), daemons)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []jobStatus{
+		{Label: "com.amazon.ec2.macos-utils.metrics", State: "running"},
+		{Label: "com.amazon.ec2.macos-utils.network", State: "not running"},
+		{Label: "com.amazon.ec2.macos-utils.serve", State: "not loaded"},
+	}, statuses)
+}
+
+func TestSummarizeDrift(t *testing.T) {
+	report := &driftReport{Drifted: true, Tasks: []driftResult{
+		{Task: "power", Changes: []task.Change{}},
+		{Task: "firewall", Changes: []task.Change{{Setting: "enabled", Current: "off", Desired: "on"}}},
+		{Task: "dns", Changes: []task.Change{}, Error: "cannot read resolvers"},
+	}}
+
+	summary := summarizeDrift(report)
+
+	assert.Equal(t, driftSummary{Drifted: true, Tasks: 3, DriftedTasks: []string{"firewall", "dns"}}, summary)
+}
+
+func TestPendingOperations(t *testing.T) {
+	j := journal.New(t.TempDir())
+	e, err := j.Begin(operationProvision, map[string]string{"id": "disk4"}, []string{stepProvision})
+	assert.NoError(t, err)
+
+	pending, err := pendingOperations(j)
+
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, e.ID, pending[0].ID)
+	assert.Equal(t, operationProvision, pending[0].Operation)
+}
+
+func TestPrintStatus(t *testing.T) {
+	report := &statusReport{
+		Version: "1.2.3",
+		Jobs:    []jobStatus{{Label: "com.amazon.ec2.macos-utils.grow", State: "not running", Runs: 2, LastExitCode: "0"}},
+		Grow:    growStatus{Actions: []diskutil.Action{{Kind: diskutil.ActionResize, Device: "disk1"}}},
+		Drift:   driftSummary{Drifted: true, Tasks: 2, DriftedTasks: []string{"firewall"}},
+		Pending: []pendingOperation{},
+		Commands: telemetry.Counters{
+			"grow": {Runs: 3, Successes: 2, LastRun: time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC), LastOutcome: "busy"},
+		},
+	}
+
+	var b strings.Builder
+	assert.NoError(t, printStatus(&b, report))
+
+	out := b.String()
+	assert.Contains(t, out, "Version:")
+	assert.Contains(t, out, "can be grown (1 changes)")
+	assert.Contains(t, out, "drifted (firewall)")
+	assert.Contains(t, out, "com.amazon.ec2.macos-utils.grow")
+	assert.Contains(t, out, "2023-06-01T12:00:00Z")
+	assert.Contains(t, out, "busy")
+}
+
+func TestPrintStatus_Errors(t *testing.T) {
+	report := &statusReport{
+		Version:       "1.2.3",
+		JobsError:     "permission denied",
+		Grow:          growStatus{Error: "no root container"},
+		Drift:         driftSummary{Error: "cannot load config"},
+		CommandsError: "invalid counters",
+	}
+
+	var b strings.Builder
+	assert.NoError(t, printStatus(&b, report))
+
+	out := b.String()
+	for _, msg := range []string{"permission denied", "no root container", "cannot load config", "invalid counters"} {
+		assert.Contains(t, out, "unknown ("+msg+")")
+	}
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+// checkTask reports the changes needed to bring the system in line with the task. An error is returned when the
+// system has drifted from the desired configuration so that scripts can rely on the exit status.
+func checkTask(ctx context.Context, cmd *cobra.Command, t task.Task) error {
+	changes, err := t.Check(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot check %s: %w", t.Name(), err)
+	}
+
+	if err := printChanges(cmd, changes); err != nil {
+		return err
+	}
+	if len(changes) != 0 {
+		return fmt.Errorf("%s: %d setting(s) differ from the desired configuration", t.Name(), len(changes))
+	}
+	logrus.WithField("task", t.Name()).Info("No drift detected")
+
+	return nil
+}
+
+// applyTask applies the task and reports the changes that were made. The changes that would have been made are
+// reported instead when dryrun is set.
+func applyTask(ctx context.Context, cmd *cobra.Command, t task.Task, dryrun bool) error {
+	var changes []task.Change
+	var err error
+	if dryrun {
+		changes, err = t.Check(ctx)
+	} else {
+		changes, err = t.Apply(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot apply %s: %w", t.Name(), err)
+	}
+
+	switch {
+	case len(changes) == 0:
+		logrus.WithField("task", t.Name()).Info("Already configured, nothing to do")
+	case dryrun:
+		logrus.WithField("task", t.Name()).Warn("Would have applied changes")
+	default:
+		logrus.WithField("task", t.Name()).Info("Successfully applied changes")
+	}
+
+	return printChanges(cmd, changes)
+}
+
+// printChanges writes the changes in the output format selected for the command.
+func printChanges(cmd *cobra.Command, changes []task.Change) error {
+	if changes == nil {
+		changes = []task.Change{}
+	}
+
+	return printOutput(cmd.OutOrStdout(), outputFormat(cmd), changes, func(w io.Writer) error {
+		if len(changes) == 0 {
+			return nil
+		}
+		return printChangeTable(w, changes)
+	})
+}
+
+// printChangeTable writes a table of the changes to w.
+func printChangeTable(w io.Writer, changes []task.Change) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tCURRENT\tDESIRED")
+	for _, c := range changes {
+		current := c.Current
+		if current == "" {
+			current = "(unset)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Setting, current, c.Desired)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+// fakeTask is a task.Task which reports fixed changes, or fails with err.
+type fakeTask struct {
+	changes []task.Change
+	err     error
+	applied bool
+}
+
+func (t *fakeTask) Name() string {
+	return "fake"
+}
+
+func (t *fakeTask) Check(ctx context.Context) ([]task.Change, error) {
+	return t.changes, t.err
+}
+
+func (t *fakeTask) Apply(ctx context.Context) ([]task.Change, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	t.applied = true
+	return t.changes, nil
+}
+
+// testTaskCommand creates a command which writes its output to buf.
+func testTaskCommand(buf *bytes.Buffer) *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.SetOut(buf)
+
+	return cmd
+}
+
+func TestCheckTask_WithDrift(t *testing.T) {
+	var buf bytes.Buffer
+	ft := &fakeTask{changes: []task.Change{{Setting: "sleep", Current: "1", Desired: "0"}, {Setting: "standby", Desired: "0"}}}
+
+	err := checkTask(context.Background(), testTaskCommand(&buf), ft)
+
+	assert.Error(t, err, "drift should fail the check")
+	assert.False(t, ft.applied)
+	assert.Equal(t, strings.Join([]string{
+		"SETTING  CURRENT  DESIRED",
+		"sleep    1        0",
+		"standby  (unset)  0",
+		"",
+	}, "\n"), buf.String())
+}
+
+func TestCheckTask_WithoutDrift(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := checkTask(context.Background(), testTaskCommand(&buf), &fakeTask{})
+
+	assert.NoError(t, err)
+	assert.Empty(t, buf.String())
+}
+
+func TestApplyTask_Dryrun(t *testing.T) {
+	var buf bytes.Buffer
+	ft := &fakeTask{changes: []task.Change{{Setting: "sleep", Current: "1", Desired: "0"}}}
+
+	err := applyTask(context.Background(), testTaskCommand(&buf), ft, true)
+
+	assert.NoError(t, err)
+	assert.False(t, ft.applied, "dry-run shouldn't apply changes")
+	assert.Contains(t, buf.String(), "sleep")
+}
+
+func TestApplyTask(t *testing.T) {
+	var buf bytes.Buffer
+	ft := &fakeTask{changes: []task.Change{{Setting: "sleep", Current: "1", Desired: "0"}}}
+
+	err := applyTask(context.Background(), testTaskCommand(&buf), ft, false)
+
+	assert.NoError(t, err)
+	assert.True(t, ft.applied)
+}
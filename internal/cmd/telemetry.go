@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/telemetry"
+)
+
+// telemetryPath is the file that the telemetry counters are kept in, it's replaced in tests.
+var telemetryPath = telemetry.DefaultPath
+
+// noTelemetryAnnotation marks commands whose runs aren't counted in the telemetry counters.
+const noTelemetryAnnotation = "ec2-macos-utils/no-telemetry"
+
+// RecordRun counts the run of the command that was executed, and whether it failed, in the telemetry counters.
+// Failing to record the run is only logged since it doesn't change the command's outcome (e.g. when it's run
+// without the root permissions needed to save the counters).
+func RecordRun(cmd *cobra.Command, err error) {
+	recordRun(telemetry.New(telemetryPath), cmd, err)
+}
+
+// recordRun counts the run of the command in the store. Commands that only print help (e.g. groups of subcommands or
+// those run with --help) aren't counted since nothing ran, nor are commands annotated with noTelemetryAnnotation.
+func recordRun(store *telemetry.Store, cmd *cobra.Command, err error) {
+	if cmd == nil || !cmd.Runnable() {
+		return
+	}
+	if _, ok := cmd.Annotations[noTelemetryAnnotation]; ok {
+		return
+	}
+	if help, _ := cmd.Flags().GetBool("help"); help {
+		return
+	}
+
+	name := commandName(cmd)
+	if recordErr := store.Record(name, err); recordErr != nil {
+		logrus.WithError(recordErr).WithField("command", name).Debug("Not recording telemetry")
+	}
+}
+
+// commandName gets the name of the command without the root command's (e.g. "volume provision"). The root command's
+// name is used for the root command.
+func commandName(cmd *cobra.Command) string {
+	name := strings.TrimSpace(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()))
+	if name == "" {
+		name = cmd.Root().Name()
+	}
+
+	return name
+}
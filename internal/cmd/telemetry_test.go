@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/telemetry"
+)
+
+func TestRecordRun(t *testing.T) {
+	store := telemetry.New(filepath.Join(t.TempDir(), "telemetry.json"))
+	root := &cobra.Command{Use: "ec2-macos-utils"}
+	group := &cobra.Command{Use: "ssh"}
+	sync := &cobra.Command{Use: "sync", RunE: func(cmd *cobra.Command, args []string) error { return nil }}
+	root.AddCommand(group)
+	group.AddCommand(sync)
+
+	recordRun(store, sync, nil)
+	recordRun(store, sync, errors.New("failed"))
+	recordRun(store, group, nil)
+
+	counters, err := store.Read()
+	assert.NoError(t, err)
+	assert.Len(t, counters, 1, "commands that only print help shouldn't be counted")
+	assert.Equal(t, uint64(2), counters["ssh sync"].Runs)
+	assert.Equal(t, uint64(1), counters["ssh sync"].Successes)
+}
+
+func TestRecordRun_Help(t *testing.T) {
+	store := telemetry.New(filepath.Join(t.TempDir(), "telemetry.json"))
+	root := &cobra.Command{Use: "ec2-macos-utils"}
+	grow := &cobra.Command{Use: "grow", RunE: func(cmd *cobra.Command, args []string) error { return nil }}
+	root.AddCommand(grow)
+	root.SetArgs([]string{"grow", "--help"})
+	root.SetOut(&strings.Builder{})
+	executed, err := root.ExecuteC()
+	assert.NoError(t, err)
+
+	recordRun(store, executed, err)
+
+	counters, err := store.Read()
+	assert.NoError(t, err)
+	assert.Empty(t, counters)
+}
+
+func TestRecordRun_NoTelemetry(t *testing.T) {
+	store := telemetry.New(filepath.Join(t.TempDir(), "telemetry.json"))
+	uninstall := uninstallCommand()
+
+	recordRun(store, uninstall, nil)
+
+	_, err := os.Stat(store.Path)
+	assert.True(t, os.IsNotExist(err), "annotated commands shouldn't create the counters")
+}
+
+func TestRecordRun_NoState(t *testing.T) {
+	store := telemetry.New(filepath.Join(t.TempDir(), "telemetry.json"))
+	status := stateStatusCommand()
+	root := &cobra.Command{Use: "ec2-macos-utils"}
+	group := &cobra.Command{Use: "state"}
+	root.AddCommand(group)
+	group.AddCommand(status)
+
+	recordRun(store, status, nil)
+
+	counters, err := store.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), counters["state status"].Runs, "commands that don't use the state directory should still be counted")
+}
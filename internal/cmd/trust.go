@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/fetch"
+	"github.com/aws/ec2-macos-utils/internal/trust"
+)
+
+// trustDefaultTimeout is the default maximum run duration for managing trusted certificates.
+const trustDefaultTimeout = 2 * time.Minute
+
+// trustSource is a struct for holding the flags that select the certificates for the trust subcommands.
+type trustSource struct {
+	file      string
+	s3URI     string
+	checksum  string
+	secretID  string
+	secretKey string
+}
+
+// trustCommand creates a new command which groups the trusted certificate subcommands.
+func trustCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "trust",
+		Short: "manage trusted root certificate authorities",
+		Long: strings.TrimSpace(`
+trust installs private certificate authorities into the System
+keychain as trusted roots for every user with security(1), e.g.
+for networks whose proxies intercept TLS. Certificates are read
+as PEM, which may hold several, or DER from a file, an S3 object
+downloaded with the instance's credentials, or a Secrets Manager
+secret holding PEM or base64 encoded DER. Only certificate
+authorities are accepted.
+
+Certificates that are already trusted are skipped when adding
+them, and certificates that aren't installed are skipped when
+removing them.
+`),
+	}
+
+	cmd.AddCommand(trustListCommand(), trustAddCommand(), trustRemoveCommand())
+
+	return cmd
+}
+
+// addTrustSourceFlags adds the flags used to select the certificates to the command.
+func addTrustSourceFlags(cmd *cobra.Command, src *trustSource) {
+	cmd.PersistentFlags().StringVar(&src.file, "file", "", "path to the certificates")
+	cmd.PersistentFlags().StringVar(&src.s3URI, "s3", "", "S3 URI of the certificates (e.g. s3://bucket/ca.pem)")
+	cmd.PersistentFlags().StringVar(&src.checksum, "sha256", "", "expected SHA-256 checksum of the S3 object")
+	cmd.PersistentFlags().StringVar(&src.secretID, "secret", "", "name or ARN of the Secrets Manager secret holding the certificates")
+	cmd.PersistentFlags().StringVar(&src.secretKey, "secret-key", "", "field of the JSON secret holding the certificates, the whole secret is used when empty")
+}
+
+// trustListCommand creates a new command which lists the certificate authorities in the System keychain.
+func trustListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list the certificate authorities in the System keychain",
+		Args:  cobra.NoArgs,
+	}
+
+	var timeout time.Duration
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", trustDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			certs, err := trust.NewSystemStore().List(ctx)
+			if err != nil {
+				return err
+			}
+
+			return printOutput(cmd.OutOrStdout(), outputFormat(cmd), certs, func(w io.Writer) error {
+				return printTrustedCertificates(w, certs)
+			})
+		})
+	}
+
+	return cmd
+}
+
+// trustAddCommand creates a new command which installs certificate authorities as trusted roots.
+func trustAddCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "install certificate authorities as trusted roots",
+		Args:  cobra.NoArgs,
+	}
+
+	src := trustSource{}
+	addTrustSourceFlags(cmd, &src)
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", trustDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Changing the System keychain and the admin trust settings requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			certs, err := readTrustCertificates(ctx, src)
+			if err != nil {
+				return err
+			}
+
+			return applyTask(ctx, cmd, &trust.AddTask{Store: trust.NewSystemStore(), Certificates: certs}, dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// trustRemoveCommand creates a new command which removes certificate authorities and their trust settings.
+func trustRemoveCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove",
+		Short: "remove certificate authorities from the System keychain",
+		Long: strings.TrimSpace(`
+remove removes the certificate authorities' trust settings and
+deletes them from the System keychain. Certificates are selected
+from the same sources as add, or by the SHA-1 fingerprints that
+list prints with --sha1.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	src := trustSource{}
+	addTrustSourceFlags(cmd, &src)
+	var fingerprints []string
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().StringSliceVar(&fingerprints, "sha1", nil, "SHA-1 fingerprint of an installed certificate to remove, may be repeated")
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", trustDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Changing the System keychain and the admin trust settings requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			store := trust.NewSystemStore()
+			var certs []*x509.Certificate
+			if len(fingerprints) == 0 {
+				var err error
+				if certs, err = readTrustCertificates(ctx, src); err != nil {
+					return err
+				}
+			}
+			for _, fingerprint := range fingerprints {
+				cert, err := store.Lookup(ctx, fingerprint)
+				if err != nil {
+					return err
+				}
+				if cert == nil {
+					return fmt.Errorf("no certificate with SHA-1 fingerprint %s in %s", fingerprint, store.Keychain)
+				}
+				certs = append(certs, cert)
+			}
+
+			return applyTask(ctx, cmd, &trust.RemoveTask{Store: store, Certificates: certs}, dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// readTrustCertificates reads the certificates from the single source that was selected.
+func readTrustCertificates(ctx context.Context, src trustSource) ([]*x509.Certificate, error) {
+	sources := 0
+	for _, s := range []string{src.file, src.s3URI, src.secretID} {
+		if s != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, errors.New("exactly one of --file, --s3, or --secret is required")
+	}
+
+	var data []byte
+	var err error
+	switch {
+	case src.file != "":
+		data, err = os.ReadFile(src.file)
+	case src.s3URI != "":
+		data, err = fetchS3Object(ctx, &fetch.Fetcher{}, src.s3URI, src.checksum)
+	default:
+		data, err = readSecretCertificates(ctx, src.secretID, src.secretKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return trust.ParseCertificates(data)
+}
+
+// readSecretCertificates reads the certificates from the secret's binary value or, when it has none, from its value
+// or field, which holds either PEM or base64 encoded DER.
+func readSecretCertificates(ctx context.Context, secretID, field string) ([]byte, error) {
+	client, err := aws.NewClientFromMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := client.GetSecret(ctx, secretID)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeSecretCertificates(secret, field)
+}
+
+// decodeSecretCertificates gets the certificates from the secret's binary value or from its value or field.
+func decodeSecretCertificates(secret *aws.Secret, field string) ([]byte, error) {
+	if secret.String == nil {
+		return decodeKeychainItem(secret, field)
+	}
+
+	value, err := aws.SecretField(*secret.String, field)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(value, "-----BEGIN") {
+		return []byte(value), nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("secret is neither PEM nor base64 encoded: %w", err)
+	}
+
+	return data, nil
+}
+
+// printTrustedCertificates writes a table of the certificate authorities to w.
+func printTrustedCertificates(w io.Writer, certs []trust.Certificate) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSHA-1\tEXPIRES\tTRUSTED")
+	for _, c := range certs {
+		trusted := "no"
+		if c.Trusted {
+			trusted = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Name, c.SHA1, c.NotAfter.Format("2006-01-02"), trusted)
+	}
+
+	return tw.Flush()
+}
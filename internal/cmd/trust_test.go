@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/trust"
+)
+
+func TestReadTrustCertificates_RequiresOneSource(t *testing.T) {
+	_, err := readTrustCertificates(context.Background(), trustSource{})
+	assert.Error(t, err)
+
+	_, err = readTrustCertificates(context.Background(), trustSource{file: "ca.pem", secretID: "ca"})
+	assert.Error(t, err)
+}
+
+func TestDecodeSecretCertificates(t *testing.T) {
+	pemValue := "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+	data, err := decodeSecretCertificates(&aws.Secret{String: &pemValue}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, pemValue, string(data), "PEM should be used as-is")
+
+	encoded := `{"ca": "` + base64.StdEncoding.EncodeToString([]byte{0x30, 0x82}) + `"}`
+	data, err = decodeSecretCertificates(&aws.Secret{String: &encoded}, "ca")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x30, 0x82}, data, "DER should be base64 decoded")
+
+	data, err = decodeSecretCertificates(&aws.Secret{Binary: []byte{0x30, 0x82}}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x30, 0x82}, data)
+}
+
+func TestPrintTrustedCertificates(t *testing.T) {
+	var buf bytes.Buffer
+	err := printTrustedCertificates(&buf, []trust.Certificate{
+		{Name: "Example Root CA", SHA1: "0123456789ABCDEF0123456789ABCDEF01234567", NotAfter: time.Date(2035, 1, 2, 0, 0, 0, 0, time.UTC), Trusted: true},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, `NAME             SHA-1                                     EXPIRES     TRUSTED
+Example Root CA  0123456789ABCDEF0123456789ABCDEF01234567  2035-01-02  yes
+`, buf.String())
+}
@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/config"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/control"
+	"github.com/aws/ec2-macos-utils/internal/devtools"
+	"github.com/aws/ec2-macos-utils/internal/homebrew"
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/internal/oplock"
+	"github.com/aws/ec2-macos-utils/internal/schedule"
+	"github.com/aws/ec2-macos-utils/internal/shellprofile"
+	"github.com/aws/ec2-macos-utils/internal/sshd"
+	"github.com/aws/ec2-macos-utils/internal/state"
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/internal/users"
+)
+
+// uninstallDefaultTimeout is the default maximum run duration for removing what the utility created.
+const uninstallDefaultTimeout = 5 * time.Minute
+
+// jobUninstaller lists and removes the utility's launchd jobs so that tests can stand in for launchctl.
+type jobUninstaller interface {
+	Installed() ([]string, error)
+	Uninstall(ctx context.Context, label string) (bool, error)
+}
+
+// uninstaller removes everything the utility created on the host.
+type uninstaller struct {
+	// jobs are the utility's launchd jobs.
+	jobs jobUninstaller
+	// mounts manages the fstab entries, and their synthetic mount points, that the utility persisted.
+	mounts *mounts.Manager
+	// sshdFragment is the sshd configuration fragment that the utility manages.
+	sshdFragment string
+	// pathsFile is the file in the paths directory that the utility manages.
+	pathsFile string
+	// homeRoot is the directory of the users' home directories.
+	homeRoot string
+	// lookup looks up the users whose keys are removed.
+	lookup func(ctx context.Context, name string) (*users.User, error)
+	// state are the files and directories that the utility keeps its configuration and state in.
+	state []string
+}
+
+// uninstallCommand creates a new command which removes everything the utility created on the host.
+func uninstallCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "uninstall",
+		Short: "remove everything the utility created on the host",
+		Long: strings.TrimSpace(`
+uninstall removes everything the utility created on the host, for
+hosts moving to other tooling: its launchd jobs (schedules,
+daemons, and the proxy environment job), the fstab entries it
+persisted and the synthetic.conf mount points created for them,
+its sshd configuration fragment, its PATH entries and shell
+profile blocks, the SSH keys it synced or authorized until they
+expire, and its configuration, journal, counters, diagnostics,
+and logs. Entries and keys that it didn't create are left as they
+are.
+
+Settings that were applied (e.g. preferences, the firewall, or
+power settings), users that were created, and volumes that were
+provisioned are kept since removing them could lose data or lock
+users out. The utility's own binary is left to the package
+manager that installed it.
+
+The removals are reported as JSON, unless another output format
+is selected. With --dry-run, what would be removed is reported
+instead.
+`),
+		Args: cobra.NoArgs,
+		// The state directory isn't migrated, nor are runs counted, so that it isn't created again once it's removed.
+		Annotations: map[string]string{noStateAnnotation: "true", noTelemetryAnnotation: "true"},
+	}
+
+	var dryrun bool
+	var keepConfig bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().BoolVar(&keepConfig, "keep-config", false, "keep the configuration file")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", uninstallDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Removing launchd daemons and editing fstab, sshd's configuration, and users' files require root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			product := contextual.Product(ctx)
+			if product == nil {
+				return errors.New("product required in context")
+			}
+
+			u := &uninstaller{
+				jobs:         launchd.NewDaemonManager(),
+				mounts:       mounts.NewManager(product),
+				sshdFragment: filepath.Join(sshd.FragmentDir, sshd.FragmentName),
+				pathsFile:    filepath.Join(shellprofile.PathsDir, devtools.PathsFile),
+				homeRoot:     users.HomeRoot,
+				lookup:       users.Lookup,
+				state: []string{
+					stateDir,
+					filepath.Join(legacyStateDir, state.JournalDir),
+					filepath.Join(legacyStateDir, state.TelemetryFile),
+					filepath.Join(legacyStateDir, state.TelemetryFile+".lock"),
+					diagnosticsDefaultDir,
+					schedule.LogDir,
+					oplock.DefaultPath,
+					control.DefaultSocketPath,
+				},
+			}
+			if !keepConfig {
+				u.state = append(u.state, config.DefaultPath)
+			}
+			report := applyTasks(ctx, u.tasks(), dryrun)
+
+			format := outputJSON
+			if f := cmd.Flags().Lookup("output"); f != nil && f.Changed {
+				format = outputFormat(cmd)
+			}
+			if err := printOutput(cmd.OutOrStdout(), format, report, func(w io.Writer) error {
+				return printDriftTable(w, report.Tasks)
+			}); err != nil {
+				return err
+			}
+			if report.Failed {
+				return errors.New("not everything the utility created could be removed")
+			}
+
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+// tasks builds the tasks that remove what the utility created. The launchd jobs are removed first so that they
+// don't recreate anything while the rest is removed.
+func (u *uninstaller) tasks() []task.Task {
+	return []task.Task{
+		&removeTask{name: "launchd-jobs", find: func(ctx context.Context) ([]string, error) {
+			return u.jobs.Installed()
+		}, remove: func(ctx context.Context, label string) error {
+			_, err := u.jobs.Uninstall(ctx, label)
+			return err
+		}},
+		&removeTask{name: "mounts", find: u.managedMounts, remove: func(ctx context.Context, mountPoint string) error {
+			_, err := u.mounts.Remove(ctx, mountPoint)
+			return err
+		}},
+		&removeTask{name: "sshd", find: existing(u.sshdFragment), remove: removePath},
+		&removeTask{name: "paths", find: existing(u.pathsFile), remove: removePath},
+		&removeTask{name: "shell-profiles", find: u.profiles, remove: func(ctx context.Context, path string) error {
+			_, err := shellprofile.RemoveBlock(path, homebrew.ProfileBlock)
+			return err
+		}},
+		&removeTask{name: "ssh-keys", find: func(ctx context.Context) ([]string, error) {
+			return users.ManagedKeyUsers(u.homeRoot)
+		}, remove: func(ctx context.Context, name string) error {
+			user, err := u.lookup(ctx, name)
+			if err != nil {
+				return err
+			}
+			_, err = users.RemoveManagedKeys(user)
+			return err
+		}},
+		&removeTask{name: "state", find: existing(u.state...), remove: removePath},
+	}
+}
+
+// managedMounts finds the mount points of the fstab entries that the utility persisted.
+func (u *uninstaller) managedMounts(ctx context.Context) ([]string, error) {
+	tab, err := mounts.ReadFstab(u.mounts.FstabPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var mountPoints []string
+	for _, e := range tab.Entries() {
+		if e.Managed {
+			mountPoints = append(mountPoints, e.File)
+		}
+	}
+
+	return mountPoints, nil
+}
+
+// profiles finds the users' shell profiles that have the utility's Homebrew block.
+func (u *uninstaller) profiles(ctx context.Context) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(u.homeRoot, "*", homebrew.ProfileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var found []string
+	for _, path := range paths {
+		has, err := shellprofile.HasBlock(path, homebrew.ProfileBlock)
+		if err != nil {
+			return nil, err
+		}
+		if has {
+			found = append(found, path)
+		}
+	}
+
+	return found, nil
+}
+
+// existing finds the paths that exist.
+func existing(paths ...string) func(ctx context.Context) ([]string, error) {
+	return func(ctx context.Context) ([]string, error) {
+		var found []string
+		for _, path := range paths {
+			if _, err := os.Lstat(path); err == nil {
+				found = append(found, path)
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+		}
+
+		return found, nil
+	}
+}
+
+// removePath removes the file or directory at the path.
+func removePath(ctx context.Context, path string) error {
+	return os.RemoveAll(path)
+}
+
+// removeTask removes what the utility created of one kind (e.g. its launchd jobs). Each target that's found is a
+// change from "present" to "removed".
+type removeTask struct {
+	name   string
+	find   func(ctx context.Context) ([]string, error)
+	remove func(ctx context.Context, target string) error
+}
+
+// Name identifies the task.
+func (t *removeTask) Name() string {
+	return t.name
+}
+
+// Check finds the targets that would be removed.
+func (t *removeTask) Check(ctx context.Context) ([]task.Change, error) {
+	targets, err := t.find(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]task.Change, 0, len(targets))
+	for _, target := range targets {
+		changes = append(changes, task.Change{Setting: target, Current: "present", Desired: "removed"})
+	}
+
+	return changes, nil
+}
+
+// Apply removes each target that's found, stopping at the first that can't be removed.
+func (t *removeTask) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, c := range changes {
+		if err := t.remove(ctx, c.Setting); err != nil {
+			return changes[:i], err
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/homebrew"
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/internal/shellprofile"
+	"github.com/aws/ec2-macos-utils/internal/users"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// fakeJobUninstaller is a jobUninstaller whose jobs are only kept in memory.
+type fakeJobUninstaller struct {
+	labels []string
+}
+
+func (f *fakeJobUninstaller) Installed() ([]string, error) {
+	return f.labels, nil
+}
+
+func (f *fakeJobUninstaller) Uninstall(ctx context.Context, label string) (bool, error) {
+	for i, l := range f.labels {
+		if l == label {
+			f.labels = append(f.labels[:i], f.labels[i+1:]...)
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// testUninstaller creates an uninstaller whose files are all in dir, with a job, a managed and an unmanaged mount,
+// the sshd fragment, the paths file, a profile with and one without the Homebrew block, and the state directory.
+func testUninstaller(t *testing.T, dir string) *uninstaller {
+	u := &uninstaller{
+		jobs: &fakeJobUninstaller{labels: []string{"com.amazon.ec2.macos-utils.metrics"}},
+		mounts: &mounts.Manager{
+			FstabPath:     filepath.Join(dir, "fstab"),
+			SyntheticPath: filepath.Join(dir, "synthetic.conf"),
+			Product:       &system.Product{Release: system.Mojave},
+		},
+		sshdFragment: filepath.Join(dir, "sshd.conf"),
+		pathsFile:    filepath.Join(dir, "paths"),
+		homeRoot:     filepath.Join(dir, "Users"),
+		lookup: func(ctx context.Context, name string) (*users.User, error) {
+			return nil, users.ErrNotFound
+		},
+		state: []string{filepath.Join(dir, "state"), filepath.Join(dir, "config.yaml")},
+	}
+
+	_, err := u.mounts.Persist(context.Background(), mounts.FstabEntry{Spec: "UUID=DATA", File: filepath.Join(dir, "Data"), VfsType: "apfs"})
+	assert.NoError(t, err)
+	f, err := os.OpenFile(u.mounts.FstabPath, os.O_APPEND|os.O_WRONLY, 0o644)
+	assert.NoError(t, err)
+	_, err = f.WriteString("UUID=OTHER /Volumes/Other apfs rw\n")
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	for _, path := range []string{u.sshdFragment, u.pathsFile, filepath.Join(dir, "config.yaml")} {
+		assert.NoError(t, os.WriteFile(path, []byte("managed\n"), 0o644))
+	}
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "state", "journal"), 0o755))
+	for _, name := range []string{"alice", "bob"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(u.homeRoot, name), 0o755))
+	}
+	profile := &shellprofile.BlockTask{
+		Path:  filepath.Join(u.homeRoot, "alice", homebrew.ProfileName),
+		Block: homebrew.ProfileBlock,
+		Lines: []string{homebrew.ShellEnv("/opt/homebrew")},
+	}
+	_, err = profile.Apply(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(u.homeRoot, "bob", homebrew.ProfileName), []byte("export EDITOR=vim\n"), 0o644))
+
+	return u
+}
+
+func TestUninstaller(t *testing.T) {
+	dir := t.TempDir()
+	u := testUninstaller(t, dir)
+
+	report := applyTasks(context.Background(), u.tasks(), false)
+
+	assert.False(t, report.Failed)
+	assert.True(t, report.Changed)
+	removed := map[string][]string{}
+	for _, r := range report.Tasks {
+		assert.Empty(t, r.Error, r.Task)
+		for _, c := range r.Changes {
+			removed[r.Task] = append(removed[r.Task], c.Setting)
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"launchd-jobs":   {"com.amazon.ec2.macos-utils.metrics"},
+		"mounts":         {filepath.Join(dir, "Data")},
+		"sshd":           {u.sshdFragment},
+		"paths":          {u.pathsFile},
+		"shell-profiles": {filepath.Join(u.homeRoot, "alice", homebrew.ProfileName)},
+		"state":          {filepath.Join(dir, "state"), filepath.Join(dir, "config.yaml")},
+	}, removed)
+
+	tab, err := mounts.ReadFstab(u.mounts.FstabPath)
+	assert.NoError(t, err)
+	assert.Len(t, tab.Entries(), 1, "entries that the utility didn't persist should be kept")
+	for _, path := range []string{u.sshdFragment, u.pathsFile, filepath.Join(dir, "state")} {
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err), path)
+	}
+	has, err := shellprofile.HasBlock(filepath.Join(u.homeRoot, "alice", homebrew.ProfileName), homebrew.ProfileBlock)
+	assert.NoError(t, err)
+	assert.False(t, has)
+	data, err := os.ReadFile(filepath.Join(u.homeRoot, "bob", homebrew.ProfileName))
+	assert.NoError(t, err)
+	assert.Equal(t, "export EDITOR=vim\n", string(data), "profiles without the block should be left as they are")
+
+	report = applyTasks(context.Background(), u.tasks(), false)
+	assert.False(t, report.Failed)
+	assert.False(t, report.Changed, "should be idempotent")
+}
+
+func TestUninstaller_DryRun(t *testing.T) {
+	dir := t.TempDir()
+	u := testUninstaller(t, dir)
+
+	report := applyTasks(context.Background(), u.tasks(), true)
+
+	assert.True(t, report.DryRun)
+	assert.True(t, report.Changed)
+	assert.FileExists(t, u.sshdFragment)
+	assert.DirExists(t, filepath.Join(dir, "state"))
+	assert.Equal(t, []string{"com.amazon.ec2.macos-utils.metrics"}, u.jobs.(*fakeJobUninstaller).labels)
+}
+
+func TestRemoveTask_Error(t *testing.T) {
+	removeErr := errors.New("busy")
+	tsk := &removeTask{
+		name: "test",
+		find: func(ctx context.Context) ([]string, error) {
+			return []string{"a", "b", "c"}, nil
+		},
+		remove: func(ctx context.Context, target string) error {
+			if target == "b" {
+				return removeErr
+			}
+			return nil
+		},
+	}
+
+	changes, err := tsk.Apply(context.Background())
+
+	assert.True(t, errors.Is(err, removeErr))
+	assert.Len(t, changes, 1, "only the targets that were removed should be reported")
+}
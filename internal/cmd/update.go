@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/build"
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/internal/selfupdate"
+)
+
+// updateDefaultTimeout is the default maximum run duration for updating the utility.
+const updateDefaultTimeout = 10 * time.Minute
+
+// updateSelf is a struct for holding all information passed into the update self command.
+type updateSelf struct {
+	check      bool
+	dryrun     bool
+	force      bool
+	manifest   string
+	noRestart  bool
+	publicKeys []string
+	timeout    time.Duration
+}
+
+// daemonRestarter finds and restarts the utility's launchd daemons so that tests can stand in for launchctl.
+type daemonRestarter interface {
+	Installed() ([]string, error)
+	Status(ctx context.Context, label string) (*launchd.Status, error)
+	Kickstart(ctx context.Context, label string, restart bool) error
+}
+
+// updateCommand creates a new command which groups the subcommands that update the utility.
+func updateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "update the utility",
+	}
+
+	cmd.AddCommand(updateSelfCommand())
+
+	return cmd
+}
+
+// updateSelfCommand creates a new command which replaces the utility's binary with the latest release.
+func updateSelfCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "self",
+		Short: "update the utility to the latest release",
+		Long: strings.TrimSpace(`
+self checks the release manifest, from GitHub by default or from
+an HTTPS URL or S3 URI, for a newer version of the utility. The
+binary for the instance's architecture is downloaded, its
+checksum and Ed25519 signature, which covers the release's
+version and architecture, are verified against the trusted
+public keys, and it atomically replaces the running executable.
+Releases older than the running version are never installed.
+The utility's launchd daemons that are running are restarted so
+that they pick up the new version.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	u := updateSelf{}
+	cmd.PersistentFlags().StringVar(&u.manifest, "manifest", selfupdate.DefaultManifestURL, "HTTPS URL or S3 URI of the release manifest")
+	cmd.PersistentFlags().StringSliceVar(&u.publicKeys, "public-key", nil, "base64-encoded Ed25519 key trusted to sign releases (default the key the utility was built with)")
+	cmd.PersistentFlags().BoolVar(&u.check, "check", false, "only report whether an update is available")
+	cmd.PersistentFlags().BoolVar(&u.force, "force", false, "install the release even if it isn't newer, releases older than the current version are never installed")
+	cmd.PersistentFlags().BoolVar(&u.noRestart, "no-restart", false, "don't restart the utility's running daemons")
+	cmd.PersistentFlags().BoolVar(&u.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&u.timeout, "timeout", updateDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Replacing the installed binary and restarting daemons requires root permissions.
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if u.check {
+			return nil
+		}
+
+		return assertRootPrivileges(cmd, args)
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, u.timeout, func(ctx context.Context) error {
+			keys, err := releasePublicKeys(u.publicKeys)
+			if err != nil {
+				return err
+			}
+			exe, err := selfupdate.Executable()
+			if err != nil {
+				return err
+			}
+			updater := &selfupdate.Updater{ManifestURL: u.manifest, PublicKeys: keys, Current: build.Version}
+
+			return runUpdateSelf(ctx, updater, launchd.NewDaemonManager(), exe, build.Version, u)
+		})
+	}
+
+	return cmd
+}
+
+// releasePublicKeys parses the trusted keys, falling back to the key the utility was built with.
+func releasePublicKeys(flags []string) ([]ed25519.PublicKey, error) {
+	if len(flags) == 0 && build.ReleasePublicKey != "" {
+		flags = []string{build.ReleasePublicKey}
+	}
+	if len(flags) == 0 {
+		return nil, errors.New("no public key to verify releases with, set one with --public-key")
+	}
+
+	var keys []ed25519.PublicKey
+	for _, flag := range flags {
+		key, err := selfupdate.ParsePublicKey(flag)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// runUpdateSelf replaces the executable at exe with the latest release when it's newer than the current version and
+// restarts the running daemons.
+func runUpdateSelf(ctx context.Context, updater *selfupdate.Updater, daemons daemonRestarter, exe, current string, u updateSelf) error {
+	manifest, artifact, err := updater.Latest(ctx)
+	if err != nil {
+		return err
+	}
+	fields := logrus.Fields{"current": current, "latest": manifest.Version}
+
+	newer, err := manifest.Newer(current)
+	if err != nil {
+		return err
+	}
+	if !newer && !u.force {
+		logrus.WithFields(fields).Info("Utility already up to date, nothing to do")
+		return nil
+	}
+	if u.check {
+		logrus.WithFields(fields).Info("Update available")
+		return nil
+	}
+
+	// Downloads are verified before they're written next to the executable so that the final rename is atomic.
+	logrus.WithFields(fields).WithField("url", artifact.URL).Info("Downloading release...")
+	path, err := updater.Download(ctx, manifest, artifact, filepath.Dir(exe))
+	if err != nil {
+		return err
+	}
+	if u.dryrun {
+		os.Remove(path)
+		logrus.WithFields(fields).WithField("path", exe).Warn("Would have replaced the utility")
+		return nil
+	}
+
+	if err := selfupdate.Replace(path, exe); err != nil {
+		os.Remove(path)
+		return err
+	}
+	logrus.WithFields(fields).WithField("path", exe).Info("Successfully updated the utility")
+
+	if u.noRestart {
+		return nil
+	}
+
+	return restartDaemons(ctx, daemons)
+}
+
+// restartDaemons restarts the utility's daemons that are running. Daemons that only run at boot, and aren't running,
+// pick up the new version the next time they're started.
+func restartDaemons(ctx context.Context, daemons daemonRestarter) error {
+	labels, err := daemons.Installed()
+	if err != nil {
+		return err
+	}
+
+	for _, label := range labels {
+		status, err := daemons.Status(ctx, label)
+		if errors.Is(err, launchd.ErrNotLoaded) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if !status.Running() {
+			continue
+		}
+
+		if err := daemons.Kickstart(ctx, label, true); err != nil {
+			return err
+		}
+		logrus.WithField("label", label).Info("Restarted daemon")
+	}
+
+	return nil
+}
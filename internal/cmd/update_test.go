@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+)
+
+// fakeDaemons is a daemonRestarter whose daemons have fixed states.
+type fakeDaemons struct {
+	states   map[string]string
+	restarts []string
+}
+
+func (f *fakeDaemons) Installed() ([]string, error) {
+	return []string{"com.amazon.ec2.macos-utils.metrics", "com.amazon.ec2.macos-utils.network", "com.amazon.ec2.macos-utils.serve"}, nil
+}
+
+func (f *fakeDaemons) Status(ctx context.Context, label string) (*launchd.Status, error) {
+	state, ok := f.states[label]
+	if !ok {
+		return nil, launchd.ErrNotLoaded
+	}
+
+	return &launchd.Status{Label: label, State: state}, nil
+}
+
+func (f *fakeDaemons) Kickstart(ctx context.Context, label string, restart bool) error {
+	f.restarts = append(f.restarts, label)
+
+	return nil
+}
+
+func TestRestartDaemons(t *testing.T) {
+	daemons := &fakeDaemons{states: map[string]string{
+		"com.amazon.ec2.macos-utils.metrics": "running",
+		"com.amazon.ec2.macos-utils.network": "not running",
+	}}
+
+	err := restartDaemons(context.Background(), daemons)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"com.amazon.ec2.macos-utils.metrics"}, daemons.restarts, "only running daemons should be restarted")
+}
+
+func TestReleasePublicKeys(t *testing.T) {
+	_, err := releasePublicKeys(nil)
+	assert.Error(t, err, "updates shouldn't be possible without a public key")
+
+	keys, err := releasePublicKeys([]string{"11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="})
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+}
@@ -0,0 +1,357 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/softwareupdate"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// updatesDefaultTimeout is the default maximum run duration for scanning and installing updates. Downloading and
+// preparing OS updates routinely takes tens of minutes.
+const updatesDefaultTimeout = time.Hour
+
+// installUpdates is a struct for holding all information passed into the updates install command.
+type installUpdates struct {
+	dryrun       bool
+	includeMajor bool
+	labels       []string
+	restart      bool
+	securityOnly bool
+	timeout      time.Duration
+}
+
+// updatesCommand creates a new command which groups the software update management subcommands.
+func updatesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "updates",
+		Short: "manage macOS software updates",
+		Long: strings.TrimSpace(`
+updates wraps softwareupdate(8) to list and install available
+updates. Updates are classified as major upgrades, OS updates,
+security updates, or other updates so that instances can stay
+patched without being upgraded to a new major release of macOS
+unexpectedly.
+`),
+	}
+
+	cmd.AddCommand(updatesListCommand(), updatesInstallCommand(), updatesDeferMajorCommand())
+
+	return cmd
+}
+
+// updatesListCommand creates a new command which lists the available updates.
+func updatesListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list available updates",
+		Long: strings.TrimSpace(`
+list scans for available updates. The updates cached by macOS's
+last background scan can be listed instead with --cached, which
+is much faster but may be out of date.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	var cached bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&cached, "cached", false, "list the updates found by the last background scan")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", updatesDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if timeout != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		product := contextual.Product(ctx)
+		if product == nil {
+			return errors.New("product required in context")
+		}
+
+		var updates []softwareupdate.Update
+		if cached {
+			prefs, err := softwareupdate.ReadPreferences(softwareupdate.PreferencesPath)
+			if err != nil {
+				return fmt.Errorf("cannot read cached updates: %w", err)
+			}
+			updates = prefs.CachedUpdates(product)
+		} else {
+			logrus.Info("Scanning for available updates...")
+			var err error
+			updates, err = softwareupdate.List(ctx, product)
+			if err != nil {
+				if ctx.Err() == context.DeadlineExceeded {
+					return errors.New("timeout exceeded")
+				}
+				return err
+			}
+		}
+
+		return printOutput(cmd.OutOrStdout(), outputFormat(cmd), updates, func(w io.Writer) error {
+			return printUpdates(w, updates)
+		})
+	}
+
+	return cmd
+}
+
+// updatesInstallCommand creates a new command which installs available updates.
+func updatesInstallCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "install available updates",
+		Long: strings.TrimSpace(`
+install installs the updates with the given labels or, when no
+labels are given, all recommended updates. Major upgrades are
+never installed unless --include-major is set. The installation
+can be limited to security updates and OS updates for the current
+release with --security-only.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	installArgs := installUpdates{}
+	cmd.PersistentFlags().StringSliceVar(&installArgs.labels, "label", nil, "label of an update to be installed, may be repeated")
+	cmd.PersistentFlags().BoolVar(&installArgs.securityOnly, "security-only", false, "only install security updates and OS updates for the current release")
+	cmd.PersistentFlags().BoolVar(&installArgs.includeMajor, "include-major", false, "allow major upgrades to be installed")
+	cmd.PersistentFlags().BoolVar(&installArgs.restart, "restart", false, "restart the system if an update requires it")
+	cmd.PersistentFlags().BoolVar(&installArgs.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&installArgs.timeout, "timeout", updatesDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Installing updates with softwareupdate requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if installArgs.timeout != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, installArgs.timeout)
+			defer cancel()
+		}
+
+		product := contextual.Product(ctx)
+		if product == nil {
+			return errors.New("product required in context")
+		}
+
+		logrus.WithField("args", installArgs).Debug("Running updates install command with args")
+		if err := runInstallUpdates(ctx, product, installArgs); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return errors.New("timeout exceeded")
+			}
+
+			return err
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// updatesDeferMajorCommand creates a new command which prevents major upgrades from being installed automatically.
+func updatesDeferMajorCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "defer-major",
+		Short: "prevent automatic major upgrades",
+		Long: strings.TrimSpace(`
+defer-major disables the automatic download and installation of
+macOS updates so that major upgrades are only installed when
+requested. Security responses and system data files are still
+installed automatically. Available major upgrades are also
+ignored on releases prior to macOS 10.15.5, after which ignoring
+updates requires MDM.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", updatesDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Writing softwareupdate's preferences requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if timeout != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		product := contextual.Product(ctx)
+		if product == nil {
+			return errors.New("product required in context")
+		}
+
+		var updates []softwareupdate.Update
+		if softwareupdate.CanIgnore(product) {
+			logrus.Info("Scanning for major upgrades...")
+			var err error
+			updates, err = softwareupdate.List(ctx, product)
+			if err != nil {
+				return err
+			}
+		}
+
+		if dryrun {
+			logrus.WithField("ignore", titles(filterUpdates(updates, softwareupdate.CategoryMajorUpgrade))).
+				Warn("Would have deferred major upgrades")
+			return nil
+		}
+
+		if err := softwareupdate.DeferMajorUpgrades(ctx, product, updates); err != nil {
+			return err
+		}
+		logrus.Info("Major upgrades deferred")
+
+		return nil
+	}
+
+	return cmd
+}
+
+// runInstallUpdates scans for available updates and installs the ones selected by the args.
+func runInstallUpdates(ctx context.Context, product *system.Product, args installUpdates) error {
+	logrus.Info("Scanning for available updates...")
+	available, err := softwareupdate.List(ctx, product)
+	if err != nil {
+		return err
+	}
+
+	selected, err := selectUpdates(available, args)
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		logrus.Info("No updates to install, nothing to do")
+		return nil
+	}
+
+	labels := make([]string, 0, len(selected))
+	for _, u := range selected {
+		labels = append(labels, u.Label)
+	}
+	if args.dryrun {
+		logrus.WithField("labels", labels).Warn("Would have installed updates")
+		return nil
+	}
+
+	logrus.WithField("labels", labels).Info("Installing updates...")
+	out, err := softwareupdate.Install(ctx, labels, args.restart)
+	logrus.WithField("out", out).Debug("Install output")
+	if err != nil {
+		return err
+	}
+	logrus.WithField("labels", labels).Info("Successfully installed updates")
+
+	return nil
+}
+
+// selectUpdates chooses the updates to be installed from the available updates. Updates requested by label must
+// be available and permitted by the args while, without labels, all recommended updates permitted by the args are
+// selected.
+func selectUpdates(available []softwareupdate.Update, args installUpdates) ([]softwareupdate.Update, error) {
+	permitted := func(u softwareupdate.Update) error {
+		if u.Category == softwareupdate.CategoryMajorUpgrade && !args.includeMajor {
+			return fmt.Errorf("update %q is a major upgrade, set --include-major to install it", u.Label)
+		}
+		if args.securityOnly && !u.IsSecurity() {
+			return fmt.Errorf("update %q is not a security update", u.Label)
+		}
+		return nil
+	}
+
+	var selected []softwareupdate.Update
+	if len(args.labels) == 0 {
+		for _, u := range available {
+			if !u.Recommended {
+				continue
+			}
+			if err := permitted(u); err != nil {
+				logrus.WithError(err).WithField("label", u.Label).Debug("Skipping update")
+				continue
+			}
+			selected = append(selected, u)
+		}
+
+		return selected, nil
+	}
+
+	for _, label := range args.labels {
+		u, ok := findUpdate(available, label)
+		if !ok {
+			return nil, fmt.Errorf("update %q is not available", label)
+		}
+		if err := permitted(u); err != nil {
+			return nil, err
+		}
+		selected = append(selected, u)
+	}
+
+	return selected, nil
+}
+
+// findUpdate finds the update with the given label.
+func findUpdate(updates []softwareupdate.Update, label string) (softwareupdate.Update, bool) {
+	for _, u := range updates {
+		if u.Label == label {
+			return u, true
+		}
+	}
+
+	return softwareupdate.Update{}, false
+}
+
+// filterUpdates gets the updates in the category.
+func filterUpdates(updates []softwareupdate.Update, category softwareupdate.Category) []softwareupdate.Update {
+	var filtered []softwareupdate.Update
+	for _, u := range updates {
+		if u.Category == category {
+			filtered = append(filtered, u)
+		}
+	}
+
+	return filtered
+}
+
+// titles gets the titles of the updates.
+func titles(updates []softwareupdate.Update) []string {
+	var t []string
+	for _, u := range updates {
+		t = append(t, u.Title)
+	}
+
+	return t
+}
+
+// printUpdates writes a table of the updates to w.
+func printUpdates(w io.Writer, updates []softwareupdate.Update) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LABEL\tTITLE\tVERSION\tCATEGORY\tSIZE\tRESTART")
+	for _, u := range updates {
+		size := "-"
+		if u.SizeKB != 0 {
+			size = humanize.Bytes(u.SizeKB * 1000)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%t\n", u.Label, u.Title, u.Version, u.Category, size, u.Restart)
+	}
+
+	return tw.Flush()
+}
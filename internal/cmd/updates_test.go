@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/softwareupdate"
+)
+
+// testUpdates are the available updates used for selecting updates to be installed.
+var testUpdates = []softwareupdate.Update{
+	{Label: "macOS Ventura 13.6.1-22G313", Title: "macOS Ventura 13.6.1", Recommended: true, Category: softwareupdate.CategoryOSUpdate},
+	{Label: "macOS Sonoma 14.1-23B74", Title: "macOS Sonoma 14.1", Recommended: true, Category: softwareupdate.CategoryMajorUpgrade},
+	{Label: "Command Line Tools for Xcode-15.0", Title: "Command Line Tools for Xcode", Recommended: true, Category: softwareupdate.CategoryOther},
+	{Label: "Safari-17.1", Title: "Safari", Category: softwareupdate.CategoryOther},
+}
+
+func TestSelectUpdates(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    installUpdates
+		want    []string
+		wantErr bool
+	}{
+		{name: "recommended", args: installUpdates{}, want: []string{"macOS Ventura 13.6.1-22G313", "Command Line Tools for Xcode-15.0"}},
+		{name: "recommended with major", args: installUpdates{includeMajor: true}, want: []string{"macOS Ventura 13.6.1-22G313", "macOS Sonoma 14.1-23B74", "Command Line Tools for Xcode-15.0"}},
+		{name: "security only", args: installUpdates{securityOnly: true}, want: []string{"macOS Ventura 13.6.1-22G313"}},
+		{name: "label", args: installUpdates{labels: []string{"Safari-17.1"}}, want: []string{"Safari-17.1"}},
+		{name: "major label", args: installUpdates{labels: []string{"macOS Sonoma 14.1-23B74"}}, wantErr: true},
+		{name: "non-security label", args: installUpdates{labels: []string{"Safari-17.1"}, securityOnly: true}, wantErr: true},
+		{name: "unavailable label", args: installUpdates{labels: []string{"missing"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := selectUpdates(testUpdates, tt.args)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			var labels []string
+			for _, u := range selected {
+				labels = append(labels, u.Label)
+			}
+			assert.Equal(t, tt.want, labels)
+		})
+	}
+}
+
+func TestPrintUpdates(t *testing.T) {
+	var buf bytes.Buffer
+	err := printUpdates(&buf, []softwareupdate.Update{
+		{Label: "Safari-17.1", Title: "Safari", Version: "17.1", SizeKB: 1500, Category: softwareupdate.CategoryOther},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"LABEL        TITLE   VERSION  CATEGORY  SIZE    RESTART",
+		"Safari-17.1  Safari  17.1     other     1.5 MB  false",
+		"",
+	}, "\n"), buf.String())
+}
+
+func TestPrintOutput_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	err := printOutput(&buf, outputJSON, []softwareupdate.Update{{Label: "Safari-17.1", Title: "Safari", Category: softwareupdate.CategoryOther}}, nil)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"label":"Safari-17.1","title":"Safari","recommended":false,"restart":false,"category":"other"}]`, buf.String())
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	assert.NoError(t, validateOutputFormat(outputText))
+	assert.NoError(t, validateOutputFormat(outputJSON))
+	assert.Error(t, validateOutputFormat("yaml"))
+}
@@ -0,0 +1,517 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/secret"
+	"github.com/aws/ec2-macos-utils/internal/users"
+)
+
+const (
+	// userDefaultTimeout is the default maximum run duration for managing users.
+	userDefaultTimeout = 5 * time.Minute
+
+	// generatedPasswordLength is the length of generated passwords.
+	generatedPasswordLength = 32
+)
+
+// passwordSource is a struct for holding the flags that select where a user's password comes from.
+type passwordSource struct {
+	secretID    string
+	secretKey   string
+	parameterID string
+	stdin       bool
+}
+
+// createUser is a struct for holding all information passed into the user create command.
+type createUser struct {
+	admin    bool
+	dryrun   bool
+	fullName string
+	password passwordSource
+	shell    string
+	timeout  time.Duration
+	uid      int
+}
+
+// userCommand creates a new command which groups the local user management subcommands.
+func userCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "manage local users",
+		Long: strings.TrimSpace(`
+user manages local user accounts (e.g. additional accounts for CI
+runners) with sysadminctl(8), dscl(1), and dseditgroup(8).
+Passwords can be read from stdin, from AWS Secrets Manager, or
+from Parameter Store SecureString parameters using the instance's
+credentials. Accounts that belong to macOS (UIDs below 500) are
+never modified.
+`),
+	}
+
+	cmd.AddCommand(
+		userCreateCommand(),
+		userDeleteCommand(),
+		userShellCommand(),
+		userAdminCommand(),
+		userPasswordCommand(),
+		userAutoLoginCommand(),
+		userCISessionCommand(),
+	)
+
+	return cmd
+}
+
+// addPasswordFlags adds the flags selecting the source of the named password (e.g. "password") to the command.
+func addPasswordFlags(cmd *cobra.Command, src *passwordSource, name string) {
+	desc := strings.ReplaceAll(name, "-", " ")
+	cmd.PersistentFlags().StringVar(&src.secretID, name+"-secret", "", "name or ARN of the Secrets Manager secret holding the "+desc)
+	cmd.PersistentFlags().StringVar(&src.secretKey, name+"-secret-key", "", "field of the JSON secret or parameter holding the "+desc+", the whole value is used when empty")
+	cmd.PersistentFlags().StringVar(&src.parameterID, name+"-parameter", "", "name or ARN of the Parameter Store SecureString parameter holding the "+desc)
+	cmd.PersistentFlags().BoolVar(&src.stdin, name+"-stdin", false, "read the "+desc+" from stdin")
+}
+
+// isSet checks if a password source was selected.
+func (src passwordSource) isSet() bool {
+	return src.secretID != "" || src.parameterID != "" || src.stdin
+}
+
+// read reads the password from the selected source.
+func (src passwordSource) read(ctx context.Context, stdin io.Reader) (string, error) {
+	if src.sources() > 1 {
+		return "", errors.New("only one of a secret, a parameter, and stdin can be used as the password source")
+	}
+
+	var password string
+	if src.stdin {
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("cannot read password from stdin: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	} else {
+		client, err := aws.NewClientFromMetadata(ctx)
+		if err != nil {
+			return "", err
+		}
+		if password, err = secret.GetString(ctx, client, src.ref()); err != nil {
+			return "", err
+		}
+	}
+	if password == "" {
+		return "", errors.New("password is empty")
+	}
+
+	return password, nil
+}
+
+// sources counts the password sources that were selected.
+func (src passwordSource) sources() int {
+	n := 0
+	for _, set := range []bool{src.secretID != "", src.parameterID != "", src.stdin} {
+		if set {
+			n++
+		}
+	}
+
+	return n
+}
+
+// ref identifies the secret or parameter holding the password.
+func (src passwordSource) ref() secret.Ref {
+	if src.parameterID != "" {
+		return secret.Ref{Service: secret.ServiceParameterStore, ID: src.parameterID, Field: src.secretKey}
+	}
+
+	return secret.Ref{Service: secret.ServiceSecretsManager, ID: src.secretID, Field: src.secretKey}
+}
+
+// store stores the password in the selected secret.
+func (src passwordSource) store(ctx context.Context, password string) error {
+	client, err := aws.NewClientFromMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	current := ""
+	if src.secretKey != "" {
+		if current, err = client.GetSecretValue(ctx, src.secretID); err != nil {
+			return err
+		}
+	}
+	value, err := aws.SetSecretField(current, src.secretKey, password)
+	if err != nil {
+		return fmt.Errorf("cannot update secret %s: %w", src.secretID, err)
+	}
+
+	return client.PutSecretValue(ctx, src.secretID, value)
+}
+
+// userCreateCommand creates a new command which creates a local user.
+func userCreateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "create a local user",
+		Long: strings.TrimSpace(`
+create creates a local user with a home directory in /Users. A
+random password is generated when no password source is given
+since accounts for services usually authenticate with SSH keys.
+Existing users are left as-is.
+`),
+		Args: cobra.ExactArgs(1),
+	}
+
+	createArgs := createUser{}
+	cmd.PersistentFlags().StringVar(&createArgs.fullName, "full-name", "", "full name of the user, the short name is used when empty")
+	cmd.PersistentFlags().StringVar(&createArgs.shell, "shell", "", "login shell of the user (e.g. /bin/zsh)")
+	cmd.PersistentFlags().IntVar(&createArgs.uid, "uid", 0, "user ID of the user, the next available ID is used when 0")
+	cmd.PersistentFlags().BoolVar(&createArgs.admin, "admin", false, "make the user an administrator")
+	cmd.PersistentFlags().BoolVar(&createArgs.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&createArgs.timeout, "timeout", userDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+	addPasswordFlags(cmd, &createArgs.password, "password")
+
+	// Creating users requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, createArgs.timeout, func(ctx context.Context) error {
+			return runCreateUser(ctx, cmd, args[0], createArgs)
+		})
+	}
+
+	return cmd
+}
+
+// runCreateUser creates the user unless it already exists.
+func runCreateUser(ctx context.Context, cmd *cobra.Command, name string, args createUser) error {
+	if args.uid != 0 && args.uid < users.MinUID {
+		return fmt.Errorf("uid must be at least %d", users.MinUID)
+	}
+
+	existing, err := users.Lookup(ctx, name)
+	if err == nil {
+		logrus.WithFields(logrus.Fields{
+			"user": existing.Name,
+			"uid":  existing.UID,
+		}).Info("User already exists, nothing to do")
+		return nil
+	} else if !errors.Is(err, users.ErrNotFound) {
+		return err
+	}
+
+	var password string
+	if args.password.isSet() {
+		if password, err = args.password.read(ctx, cmd.InOrStdin()); err != nil {
+			return err
+		}
+	} else {
+		if password, err = users.GeneratePassword(generatedPasswordLength); err != nil {
+			return err
+		}
+		logrus.WithField("user", name).Warn("No password source given, using a random password")
+	}
+
+	if args.dryrun {
+		logrus.WithFields(logrus.Fields{
+			"user":  name,
+			"admin": args.admin,
+		}).Warn("Would have created user")
+		return nil
+	}
+
+	logrus.WithField("user", name).Info("Creating user...")
+	if err := users.Create(ctx, users.CreateOptions{
+		Name:     name,
+		FullName: args.fullName,
+		Password: password,
+		Shell:    args.shell,
+		UID:      args.uid,
+		Admin:    args.admin,
+	}); err != nil {
+		return err
+	}
+	logrus.WithField("user", name).Info("Successfully created user")
+
+	return nil
+}
+
+// userDeleteCommand creates a new command which deletes a local user.
+func userDeleteCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "delete a local user",
+		Long: strings.TrimSpace(`
+delete deletes a local user along with their home directory,
+unless --keep-home is set. Deleting a user that doesn't exist
+isn't an error.
+`),
+		Args: cobra.ExactArgs(1),
+	}
+
+	var keepHome, dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&keepHome, "keep-home", false, "keep the user's home directory")
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", userDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Deleting users requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			u, err := users.Lookup(ctx, args[0])
+			if errors.Is(err, users.ErrNotFound) {
+				logrus.WithField("user", args[0]).Info("User doesn't exist, nothing to do")
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if u.IsSystem() {
+				return fmt.Errorf("refusing to delete system user %s", u.Name)
+			}
+
+			if dryrun {
+				logrus.WithFields(logrus.Fields{
+					"user":      u.Name,
+					"home":      u.Home,
+					"keep_home": keepHome,
+				}).Warn("Would have deleted user")
+				return nil
+			}
+
+			logrus.WithField("user", u.Name).Info("Deleting user...")
+			if err := users.Delete(ctx, u, keepHome); err != nil {
+				return err
+			}
+			logrus.WithField("user", u.Name).Info("Successfully deleted user")
+
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+// userShellCommand creates a new command which changes the login shell of a local user.
+func userShellCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "shell <name> <shell>",
+		Short: "change the login shell of a local user",
+		Args:  cobra.ExactArgs(2),
+	}
+
+	var dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", userDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Changing user records requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		name, shell := args[0], args[1]
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			u, err := lookupManagedUser(ctx, name)
+			if err != nil {
+				return err
+			}
+			if u.Shell == shell {
+				logrus.WithFields(logrus.Fields{"user": u.Name, "shell": shell}).Info("User already has shell, nothing to do")
+				return nil
+			}
+			if dryrun {
+				logrus.WithFields(logrus.Fields{"user": u.Name, "shell": shell}).Warn("Would have changed shell")
+				return nil
+			}
+
+			if err := users.SetShell(ctx, u.Name, shell); err != nil {
+				return err
+			}
+			logrus.WithFields(logrus.Fields{"user": u.Name, "shell": shell}).Info("Successfully changed shell")
+
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+// userAdminCommand creates a new command which manages the admin group membership of a local user.
+func userAdminCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin <name>",
+		Short: "grant or revoke administrator access for a local user",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	var remove, dryrun bool
+	var timeout time.Duration
+	cmd.PersistentFlags().BoolVar(&remove, "remove", false, "remove the user from the admin group instead of adding them")
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", userDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Editing group membership requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			u, err := lookupManagedUser(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			admin, err := users.IsAdmin(ctx, u.Name)
+			if err != nil {
+				return err
+			}
+			if admin != remove {
+				logrus.WithFields(logrus.Fields{"user": u.Name, "admin": admin}).Info("Admin membership already set, nothing to do")
+				return nil
+			}
+			if dryrun {
+				logrus.WithFields(logrus.Fields{"user": u.Name, "admin": !remove}).Warn("Would have changed admin membership")
+				return nil
+			}
+
+			if err := users.SetAdmin(ctx, u.Name, !remove); err != nil {
+				return err
+			}
+			logrus.WithFields(logrus.Fields{"user": u.Name, "admin": !remove}).Info("Successfully changed admin membership")
+
+			return nil
+		})
+	}
+
+	return cmd
+}
+
+// userPasswordCommand creates a new command which sets or rotates the password of a local user.
+func userPasswordCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "password <name>",
+		Short: "set or rotate the password of a local user",
+		Long: strings.TrimSpace(`
+password sets the password of a local user from stdin, a Secrets
+Manager secret, or a Parameter Store parameter. With --rotate, a new random password is
+generated instead and, when a secret is given, stored in the
+secret before it's set so that it's never lost.
+
+Note that resetting the password of a user with a secure token
+(e.g. the first administrator) requires the user's old password
+and isn't supported.
+`),
+		Args: cobra.ExactArgs(1),
+	}
+
+	src := passwordSource{}
+	var rotate, dryrun bool
+	var timeout time.Duration
+	addPasswordFlags(cmd, &src, "password")
+	cmd.PersistentFlags().BoolVar(&rotate, "rotate", false, "generate a new random password and store it in the secret")
+	cmd.PersistentFlags().BoolVar(&dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", userDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+
+	// Resetting passwords requires root permissions.
+	cmd.PreRunE = assertRootPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, timeout, func(ctx context.Context) error {
+			return runSetPassword(ctx, cmd, args[0], src, rotate, dryrun)
+		})
+	}
+
+	return cmd
+}
+
+// runSetPassword sets the user's password from the source, or rotates it when rotate is set.
+func runSetPassword(ctx context.Context, cmd *cobra.Command, name string, src passwordSource, rotate bool, dryrun bool) error {
+	switch {
+	case rotate && src.stdin:
+		return errors.New("--rotate can't be used with --password-stdin")
+	case rotate && src.parameterID != "":
+		return errors.New("--rotate can't be used with --password-parameter, rotated passwords are stored in secrets")
+	case !rotate && !src.isSet():
+		return errors.New("one of --password-secret, --password-parameter, --password-stdin, or --rotate is required")
+	}
+
+	u, err := lookupManagedUser(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	var password string
+	if rotate {
+		if password, err = users.GeneratePassword(generatedPasswordLength); err != nil {
+			return err
+		}
+	} else if password, err = src.read(ctx, cmd.InOrStdin()); err != nil {
+		return err
+	}
+
+	if dryrun {
+		logrus.WithFields(logrus.Fields{
+			"user":   u.Name,
+			"rotate": rotate,
+			"secret": src.secretID,
+		}).Warn("Would have set password")
+		return nil
+	}
+
+	if rotate && src.secretID != "" {
+		logrus.WithField("secret", src.secretID).Info("Storing rotated password...")
+		if err := src.store(ctx, password); err != nil {
+			return fmt.Errorf("cannot store rotated password: %w", err)
+		}
+	} else if rotate {
+		logrus.WithField("user", u.Name).Warn("No secret given, the rotated password won't be recoverable")
+	}
+
+	if err := users.SetPassword(ctx, u.Name, password); err != nil {
+		return err
+	}
+	logrus.WithField("user", u.Name).Info("Successfully set password")
+
+	return nil
+}
+
+// lookupManagedUser fetches the user and checks that it isn't a system user.
+func lookupManagedUser(ctx context.Context, name string) (*users.User, error) {
+	u, err := users.Lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if u.IsSystem() {
+		return nil, fmt.Errorf("refusing to modify system user %s", u.Name)
+	}
+
+	return u, nil
+}
+
+// runUserCommand runs fn with the command's context and timeout.
+func runUserCommand(cmd *cobra.Command, timeout time.Duration, fn func(ctx context.Context) error) error {
+	ctx := cmd.Context()
+	if timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if err := fn(ctx); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return errors.New("timeout exceeded")
+		}
+
+		return err
+	}
+
+	return nil
+}
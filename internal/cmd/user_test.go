@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/secret"
+)
+
+func TestPasswordSource_ReadStdin(t *testing.T) {
+	src := passwordSource{stdin: true}
+
+	password, err := src.read(context.Background(), strings.NewReader("hunter2\n"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", password)
+}
+
+func TestPasswordSource_ReadStdinEmpty(t *testing.T) {
+	src := passwordSource{stdin: true}
+
+	_, err := src.read(context.Background(), strings.NewReader("\n"))
+
+	assert.Error(t, err, "empty passwords should be rejected")
+}
+
+func TestPasswordSource_ReadConflicting(t *testing.T) {
+	src := passwordSource{stdin: true, secretID: "ci/password"}
+
+	_, err := src.read(context.Background(), strings.NewReader("hunter2\n"))
+
+	assert.Error(t, err, "only one source should be allowed")
+}
+
+func TestRunSetPassword_WithoutSource(t *testing.T) {
+	err := runSetPassword(context.Background(), userPasswordCommand(), "runner", passwordSource{}, false, false)
+
+	assert.Error(t, err)
+}
+
+func TestRunSetPassword_RotateStdin(t *testing.T) {
+	err := runSetPassword(context.Background(), userPasswordCommand(), "runner", passwordSource{stdin: true}, true, false)
+
+	assert.Error(t, err, "rotated passwords can't be read from stdin")
+}
+
+func TestPasswordSource_Ref(t *testing.T) {
+	src := passwordSource{parameterID: "/ci/vnc", secretKey: "password"}
+
+	assert.Equal(t, secret.Ref{Service: secret.ServiceParameterStore, ID: "/ci/vnc", Field: "password"}, src.ref())
+	assert.Equal(t, secret.Ref{Service: secret.ServiceSecretsManager, ID: "ci/runner"}, passwordSource{secretID: "ci/runner"}.ref())
+}
+
+func TestRunSetPassword_RotateParameter(t *testing.T) {
+	err := runSetPassword(context.Background(), userPasswordCommand(), "runner", passwordSource{parameterID: "/ci/password"}, true, false)
+
+	assert.Error(t, err, "rotated passwords can only be stored in secrets")
+}
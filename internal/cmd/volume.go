@@ -0,0 +1,880 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/aws/ec2-macos-utils/internal/asr"
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/contextual"
+	"github.com/aws/ec2-macos-utils/internal/ebs"
+	"github.com/aws/ec2-macos-utils/internal/escrow"
+	"github.com/aws/ec2-macos-utils/internal/fetch"
+	"github.com/aws/ec2-macos-utils/internal/fsck"
+	"github.com/aws/ec2-macos-utils/internal/journal"
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/identifier"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+	"github.com/aws/ec2-macos-utils/pkg/progress"
+)
+
+// provisionDefaultTimeout is the default maximum run duration for provisioning a volume. Formatting is quick for
+// blank volumes so this mirrors the grow command's default.
+const provisionDefaultTimeout = 5 * time.Minute
+
+// restoreDefaultTimeout is the default maximum run duration for restoring a volume. Restores copy the whole source so
+// they take much longer than growing or provisioning.
+const restoreDefaultTimeout = time.Hour
+
+// provisionVolume is a struct for holding all information passed into the volume provision command.
+type provisionVolume struct {
+	disableSpotlight bool
+	dryrun           bool
+	encrypt          bool
+	escrow           string
+	format           string
+	id               string
+	label            string
+	mountPoint       string
+	persist          bool
+	plan             bool
+	timeout          time.Duration
+}
+
+// formatVolume is a struct for holding all information passed into the volume format command.
+type formatVolume struct {
+	dryrun  bool
+	force   bool
+	format  string
+	id      string
+	label   string
+	timeout time.Duration
+}
+
+// checkVolume is a struct for holding all information passed into the volume check command.
+type checkVolume struct {
+	dryrun           bool
+	id               string
+	repair           bool
+	resetPermissions bool
+	resetUID         int
+	timeout          time.Duration
+}
+
+// restoreVolume is a struct for holding all information passed into the volume restore command.
+type restoreVolume struct {
+	dryrun  bool
+	erase   bool
+	scan    bool
+	source  string
+	sha256  string
+	target  string
+	timeout time.Duration
+}
+
+// volumeCommand creates a new command which groups the data volume management subcommands.
+func volumeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "volume",
+		Short: "manage data volumes",
+		Long: strings.TrimSpace(`
+volume groups subcommands for managing data volumes (e.g.
+attached EBS volumes) used alongside the OS's root volume.
+`),
+	}
+
+	cmd.AddCommand(volumeProvisionCommand(), volumeFormatCommand(), volumeCheckCommand(), volumeRestoreCommand(), volumeUnlockCommand())
+
+	return cmd
+}
+
+// volumeProvisionCommand creates a new command which formats, mounts, and persists a data volume.
+func volumeProvisionCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "provision",
+		Short: "format and mount a data volume",
+		Long: strings.TrimSpace(`
+provision prepares a data volume for use. The disk to operate
+on can be specified with its identifier (e.g. disk2 or
+/dev/disk2) or with the ID of the EBS volume attached to the
+instance (e.g. vol-0123456789abcdef0). Blank disks are
+formatted with a single labeled volume while disks that
+already hold a data volume are reused as-is. The volume is
+then mounted at the mount point and, unless disabled, added
+to /etc/fstab so it's mounted there on every boot. Mount
+points at the root of the filesystem (e.g. /data) are added
+to /etc/synthetic.conf when the system volume is read-only.
+Spotlight indexing of the volume can be turned off with
+--disable-spotlight. The boot disk and the host's internal SSD
+are never erased. ExFAT and FAT32 volumes can be shared with
+other systems, their labels are limited to 11 characters and
+FAT32 disks use an MBR partition map so can't exceed 2 TiB.
+With --plan, the changes that would be made to the disk are
+printed with the reason for each, and nothing is changed.
+With --encrypt, blank disks get an encrypted APFS volume
+whose random passphrase is escrowed in Secrets Manager or
+Parameter Store (--escrow) under a name scoped to the
+instance and the volume's UUID, and locked volumes are
+unlocked from escrow. Encrypted volumes are locked at every
+boot, see volume unlock --persist to unlock them at boot.
+`),
+		Annotations: map[string]string{publishesEventsAnnotation: "true"},
+	}
+
+	provisionArgs := provisionVolume{}
+	cmd.PersistentFlags().StringVar(&provisionArgs.id, "id", "", "disk identifier or EBS volume ID to be provisioned")
+	cmd.PersistentFlags().StringVar(&provisionArgs.format, "format", string(diskutil.FormatAPFS), "filesystem to format blank disks with (APFS, JHFS+, ExFAT, or FAT32)")
+	cmd.PersistentFlags().StringVar(&provisionArgs.label, "label", "Data", "name of the volume created on blank disks")
+	cmd.PersistentFlags().StringVar(&provisionArgs.mountPoint, "mount-point", "", "path to mount the volume at")
+	cmd.PersistentFlags().BoolVar(&provisionArgs.persist, "persist", true, "persist the mount across reboots in /etc/fstab")
+	cmd.PersistentFlags().BoolVar(&provisionArgs.disableSpotlight, "disable-spotlight", false, "disable Spotlight indexing of the volume")
+	cmd.PersistentFlags().BoolVar(&provisionArgs.encrypt, "encrypt", false, "create an encrypted APFS volume and escrow its passphrase")
+	cmd.PersistentFlags().StringVar(&provisionArgs.escrow, "escrow", string(escrow.BackendSecretsManager), "service to escrow the passphrase of encrypted volumes in (secretsmanager or ssm)")
+	cmd.PersistentFlags().BoolVar(&provisionArgs.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().BoolVar(&provisionArgs.plan, "plan", false, "print the changes that would be made to the disk without making them")
+	cmd.PersistentFlags().DurationVar(&provisionArgs.timeout, "timeout", provisionDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+	cmd.MarkPersistentFlagRequired("id")
+	cmd.MarkPersistentFlagRequired("mount-point")
+
+	// Erasing and mounting disks with diskutil requires root permissions and Full Disk Access.
+	cmd.PreRunE = assertDiskPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if provisionArgs.timeout != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, provisionArgs.timeout)
+			defer cancel()
+		}
+
+		product := contextual.Product(ctx)
+		if product == nil {
+			return errors.New("product required in context")
+		}
+
+		logrus.WithField("product", product).Info("Configuring diskutil for product")
+		d, err := diskutil.ForProduct(product)
+		if err != nil {
+			return err
+		}
+
+		if provisionArgs.dryrun {
+			d = diskutil.Dryrun(d)
+		}
+
+		if provisionArgs.plan {
+			if provisionArgs.encrypt {
+				return errors.New("--plan can't be used with --encrypt")
+			}
+			return runProvisionPlan(ctx, cmd.OutOrStdout(), outputFormat(cmd), d, provisionArgs)
+		}
+
+		logrus.WithField("args", provisionArgs).Debug("Running volume provision command with args")
+		if err := runProvision(ctx, d, mounts.NewManager(product), provisionArgs); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return errors.New("timeout exceeded")
+			}
+
+			return err
+		}
+
+		return nil
+	}
+
+	// Keep other disk operations on the host from running alongside this one.
+	lockDiskOperation(cmd)
+
+	return cmd
+}
+
+// runProvision resolves the target disk and provisions it as a mounted data volume using diskutil.ProvisionVolume.
+// Provisioning steps are recorded in the journal, unless it's a dry run, so that provisioning that's interrupted can
+// be reported and resumed.
+func runProvision(ctx context.Context, utility diskutil.DiskUtil, m *mounts.Manager, args provisionVolume) error {
+	return resumeProvision(ctx, utility, m, args, nil)
+}
+
+// resumeProvision provisions the data volume as described by runProvision, recording its steps in the journal entry.
+// A new entry is begun when e is nil.
+func resumeProvision(ctx context.Context, utility diskutil.DiskUtil, m *mounts.Manager, args provisionVolume, e *journal.Entry) error {
+	format, err := diskutil.ParseVolumeFormat(args.format)
+	if err != nil {
+		return err
+	}
+
+	if !filepath.IsAbs(args.mountPoint) {
+		return fmt.Errorf("mount point must be an absolute path: %s", args.mountPoint)
+	}
+	if args.encrypt && format != diskutil.FormatAPFS {
+		return fmt.Errorf("encrypted volumes must be %s, got %s", diskutil.FormatAPFS, format)
+	}
+
+	id, err := resolveProvisionTarget(ctx, utility, args.id)
+	if err != nil {
+		return fmt.Errorf("cannot provision volume: %w", err)
+	}
+
+	if e == nil && !args.dryrun {
+		e = beginJournal(operationProvision, provisionJournalArgs(args), provisionSteps(args))
+	}
+
+	if !args.dryrun {
+		_, err := m.EnsureMountPoint(ctx, args.mountPoint)
+		journalStep(e, stepMountPoint, err)
+		if err != nil {
+			return fmt.Errorf("cannot create mount point: %w", err)
+		}
+	}
+
+	if args.encrypt {
+		volume, err := prepareEncryptedVolume(ctx, utility, id, args)
+		journalStep(e, stepEncrypt, err)
+		if err != nil {
+			return err
+		}
+		if volume == nil {
+			logrus.WithField("device_id", id).Info("Dry-run complete, nothing else to do")
+			return nil
+		}
+	}
+
+	logrus.WithField("device_id", id).Info("Attempting to provision volume...")
+	volume, err := diskutil.ProvisionVolume(ctx, utility, id, format, args.label, args.mountPoint)
+	journalStep(e, stepProvision, err)
+	if err != nil {
+		return err
+	}
+	if volume == nil {
+		logrus.WithField("device_id", id).Info("Dry-run complete, nothing else to do")
+		return nil
+	}
+
+	if args.disableSpotlight {
+		err := disableSpotlight(ctx, args.mountPoint, args.dryrun)
+		journalStep(e, stepSpotlight, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	if args.persist {
+		if args.dryrun {
+			logrus.WithField("volume_uuid", volume.VolumeUUID).Warn("Would have persisted mount")
+		} else {
+			err := persistVolumeMount(ctx, m, volume.VolumeUUID, args.mountPoint, volume.FilesystemType)
+			journalStep(e, stepPersist, err)
+			if err != nil {
+				return fmt.Errorf("cannot persist mount: %w", err)
+			}
+		}
+	}
+	completeJournal(e)
+
+	logrus.WithFields(logrus.Fields{
+		"volume_id":   volume.DeviceIdentifier,
+		"mount_point": volume.MountPoint,
+	}).Info("Successfully provisioned volume")
+
+	return nil
+}
+
+// provisionSteps gets the journal's steps for provisioning a volume with the arguments.
+func provisionSteps(args provisionVolume) []string {
+	steps := []string{stepMountPoint}
+	if args.encrypt {
+		steps = append(steps, stepEncrypt)
+	}
+	steps = append(steps, stepProvision)
+	if args.disableSpotlight {
+		steps = append(steps, stepSpotlight)
+	}
+	if args.persist {
+		steps = append(steps, stepPersist)
+	}
+
+	return steps
+}
+
+// runProvisionPlan prints the diskutil.Plan for provisioning the data volume without applying it.
+func runProvisionPlan(ctx context.Context, w io.Writer, outFormat string, utility diskutil.DiskUtil, args provisionVolume) error {
+	plan, err := planProvision(ctx, utility, args)
+	if err != nil {
+		return err
+	}
+
+	return printOutput(w, outFormat, plan, func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, plan)
+		return err
+	})
+}
+
+// planProvision resolves the target disk and computes the diskutil.Plan for provisioning it as a data volume. Only
+// the changes to the disk are planned, the mount point and /etc/fstab are left for provisioning.
+func planProvision(ctx context.Context, utility diskutil.DiskUtil, args provisionVolume) (*diskutil.Plan, error) {
+	format, err := diskutil.ParseVolumeFormat(args.format)
+	if err != nil {
+		return nil, err
+	}
+
+	if !filepath.IsAbs(args.mountPoint) {
+		return nil, fmt.Errorf("mount point must be an absolute path: %s", args.mountPoint)
+	}
+
+	id, err := resolveProvisionTarget(ctx, utility, args.id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot plan provisioning volume: %w", err)
+	}
+
+	plan, err := diskutil.PlanProvision(ctx, utility, id, format, args.label, args.mountPoint)
+	if err != nil {
+		return nil, fmt.Errorf("cannot plan provisioning volume: %w", err)
+	}
+
+	return plan, nil
+}
+
+// resolveProvisionTarget resolves EBS volume IDs to the device identifier of their NVMe device. Other references are
+// resolved with diskutil.Resolve to the whole disk that holds them.
+func resolveProvisionTarget(ctx context.Context, utility diskutil.DiskUtil, id string) (string, error) {
+	if ebs.IsVolumeID(id) {
+		logrus.WithField("volume_id", id).Info("Resolving EBS volume to device...")
+		return ebs.DeviceForVolume(ctx, id)
+	}
+
+	deviceID, err := diskutil.Resolve(ctx, utility, id)
+	if err != nil {
+		return "", fmt.Errorf("id does not match a device identifier, EBS volume ID, or volume: %w", err)
+	}
+
+	return identifier.ParseDiskID(deviceID), nil
+}
+
+// persistVolumeMount persists the mount of the volume with the given UUID at mountPoint.
+func persistVolumeMount(ctx context.Context, m *mounts.Manager, uuid string, mountPoint string, fsType string) error {
+	if uuid == "" {
+		return errors.New("volume has no UUID")
+	}
+
+	_, err := m.Persist(ctx, mounts.FstabEntry{
+		Spec:    "UUID=" + uuid,
+		File:    mountPoint,
+		VfsType: fsType,
+	})
+
+	return err
+}
+
+// volumeTask provisions a data volume as the volume provision command does. The volume is only provisioned when its
+// disk would be changed or, when it's persisted, its mount point has no entry in the filesystem table.
+type volumeTask struct {
+	utility diskutil.DiskUtil
+	manager *mounts.Manager
+	args    provisionVolume
+}
+
+// Name identifies the task.
+func (t *volumeTask) Name() string {
+	return "volume"
+}
+
+// Check plans provisioning the volume and reports each change to its disk, and whether its mount is persisted.
+func (t *volumeTask) Check(ctx context.Context) ([]task.Change, error) {
+	plan, err := planProvision(ctx, t.utility, t.args)
+	if err != nil {
+		return nil, err
+	}
+
+	setting := "volume." + t.args.mountPoint
+	var changes []task.Change
+	for _, a := range plan.Actions {
+		changes = append(changes, task.Change{Setting: setting + "." + string(a.Kind), Current: a.Device, Desired: a.Reason})
+	}
+	if t.args.persist {
+		tab, err := mounts.ReadFstab(t.manager.FstabPath)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := tab.Lookup(t.args.mountPoint); !ok {
+			changes = append(changes, task.Change{Setting: "mount." + t.args.mountPoint, Desired: "persisted"})
+		}
+	}
+
+	return changes, nil
+}
+
+// Apply provisions the volume when it differs from the desired state.
+func (t *volumeTask) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+
+	return changes, runProvision(ctx, t.utility, t.manager, t.args)
+}
+
+// volumeFormatCommand creates a new command which formats a whole disk with newfs.
+func volumeFormatCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "format",
+		Short: "format a whole disk with newfs",
+		Long: strings.TrimSpace(`
+format creates a filesystem directly on a whole disk with the
+newfs tool for the format (e.g. newfs_apfs) rather than with
+diskutil eraseDisk. No partition map is written, which makes
+formatting large data volumes faster. The disk can be given
+with its identifier or the ID of its EBS volume. The boot disk
+and the host's internal SSD are never formatted and disks that
+aren't blank are only formatted with --force, which unmounts
+their volumes first.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	formatArgs := formatVolume{}
+	cmd.PersistentFlags().StringVar(&formatArgs.id, "id", "", "disk identifier or EBS volume ID to be formatted")
+	cmd.PersistentFlags().StringVar(&formatArgs.format, "format", string(diskutil.FormatAPFS), "filesystem to format the disk with (APFS, JHFS+, ExFAT, or FAT32)")
+	cmd.PersistentFlags().StringVar(&formatArgs.label, "label", "Data", "name of the created volume")
+	cmd.PersistentFlags().BoolVar(&formatArgs.force, "force", false, "format the disk even if it isn't blank")
+	cmd.PersistentFlags().BoolVar(&formatArgs.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&formatArgs.timeout, "timeout", provisionDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+	cmd.MarkPersistentFlagRequired("id")
+
+	// Formatting disks requires root permissions and Full Disk Access.
+	cmd.PreRunE = assertDiskPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runUserCommand(cmd, formatArgs.timeout, func(ctx context.Context) error {
+			product := contextual.Product(ctx)
+			if product == nil {
+				return errors.New("product required in context")
+			}
+
+			d, err := diskutil.ForProduct(product)
+			if err != nil {
+				return err
+			}
+			if formatArgs.dryrun {
+				d = diskutil.Dryrun(d)
+			}
+
+			return runFormat(ctx, d, formatArgs)
+		})
+	}
+
+	// Keep other disk operations on the host from running alongside this one.
+	lockDiskOperation(cmd)
+
+	return cmd
+}
+
+// runFormat resolves the target disk and formats it using diskutil.FormatDevice.
+func runFormat(ctx context.Context, utility diskutil.DiskUtil, args formatVolume) error {
+	format, err := diskutil.ParseVolumeFormat(args.format)
+	if err != nil {
+		return err
+	}
+
+	id, err := resolveProvisionTarget(ctx, utility, args.id)
+	if err != nil {
+		return fmt.Errorf("cannot format volume: %w", err)
+	}
+
+	if err := diskutil.FormatDevice(ctx, utility, id, format, args.label, args.force); err != nil {
+		return err
+	}
+	if !args.dryrun {
+		logrus.WithField("device_id", id).Info("Successfully formatted device")
+	}
+
+	return nil
+}
+
+// volumeCheckCommand creates a new command which checks and repairs an APFS volume with fsck_apfs.
+func volumeCheckCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "check an APFS volume for problems",
+		Long: strings.TrimSpace(`
+check verifies an APFS volume or container with fsck_apfs(8)
+and reports whether it's clean, was repaired, or has problems
+that weren't (or couldn't be) repaired. Mounted volumes are
+checked live from a snapshot. With --repair, mounted volumes
+are unmounted for the repair and mounted again afterwards.
+The command fails unless the volume is clean or was repaired.
+With --reset-user-permissions, the files on the volume are
+then given to the user with the UID with diskutil
+resetUserPermissions, e.g. after restoring a workspace image
+whose home directories belonged to another user.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	checkArgs := checkVolume{}
+	cmd.PersistentFlags().StringVar(&checkArgs.id, "id", "", "identifier, UUID, label, or mount point of the volume or container to check")
+	cmd.PersistentFlags().BoolVar(&checkArgs.repair, "repair", false, "repair the problems found")
+	cmd.PersistentFlags().IntVar(&checkArgs.resetUID, "reset-user-permissions", 0, "UID of the user to give the files on the volume to after it's checked")
+	cmd.PersistentFlags().BoolVar(&checkArgs.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&checkArgs.timeout, "timeout", restoreDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+	cmd.MarkPersistentFlagRequired("id")
+
+	// Reading raw devices with fsck_apfs requires root permissions and Full Disk Access.
+	cmd.PreRunE = assertDiskPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		checkArgs.resetPermissions = cmd.Flags().Changed("reset-user-permissions")
+		if checkArgs.resetPermissions && checkArgs.resetUID < 0 {
+			return fmt.Errorf("invalid --reset-user-permissions UID %d", checkArgs.resetUID)
+		}
+
+		return runUserCommand(cmd, checkArgs.timeout, func(ctx context.Context) error {
+			product := contextual.Product(ctx)
+			if product == nil {
+				return errors.New("product required in context")
+			}
+
+			d, err := diskutil.ForProduct(product)
+			if err != nil {
+				return err
+			}
+			if checkArgs.dryrun {
+				d = diskutil.Dryrun(d)
+			}
+
+			result, err := runCheck(ctx, d, checkArgs)
+			if err != nil {
+				return err
+			}
+			if err := printCheckResult(cmd.OutOrStdout(), outputFormat(cmd), result); err != nil {
+				return err
+			}
+			if result.Verdict != fsck.VerdictClean && result.Verdict != fsck.VerdictRepaired {
+				return fmt.Errorf("volume [%s] is %s", checkArgs.id, result.Verdict)
+			}
+			if checkArgs.resetPermissions {
+				return runResetUserPermissions(ctx, d, checkArgs)
+			}
+
+			return nil
+		})
+	}
+
+	// Keep other disk operations on the host from running alongside this one.
+	lockDiskOperation(cmd)
+
+	return cmd
+}
+
+// runCheck checks the volume with fsck_apfs in the mode appropriate for whether it's mounted. Mounted volumes are
+// unmounted for repairs and mounted at the same mount point afterwards. Repairs are skipped in dry-run mode and the
+// volume is only checked.
+func runCheck(ctx context.Context, utility diskutil.DiskUtil, args checkVolume) (*fsck.Result, error) {
+	id, err := diskutil.Resolve(ctx, utility, args.id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid volume: %w", err)
+	}
+	volume, err := utility.Info(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get volume information: %w", err)
+	}
+
+	repair := args.repair
+	if repair && args.dryrun {
+		logrus.WithField("device_id", volume.DeviceIdentifier).Warn("Would have repaired volume, checking it instead")
+		repair = false
+	}
+
+	mountPoint := volume.MountPoint
+	if repair && mountPoint != "" {
+		logrus.WithField("mount_point", mountPoint).Info("Unmounting volume for repair...")
+		if _, err := utility.Unmount(ctx, volume.DeviceIdentifier); err != nil {
+			return nil, err
+		}
+		defer func() {
+			logrus.WithField("mount_point", mountPoint).Info("Mounting volume after repair...")
+			if _, err := utility.Mount(ctx, volume.DeviceIdentifier, mountPoint); err != nil {
+				logrus.WithError(err).Error("Failed to mount volume after repair")
+			}
+		}()
+	}
+
+	mode, err := fsck.ModeFor(mountPoint != "" && !repair, repair)
+	if err != nil {
+		return nil, err
+	}
+
+	device := "/dev/r" + volume.DeviceIdentifier
+	logrus.WithFields(logrus.Fields{
+		"device": device,
+		"mode":   mode,
+	}).Info("Checking volume...")
+
+	return fsck.Check(ctx, device, mode, func(phase string) {
+		logrus.WithField("phase", phase).Debug("Check progress")
+	})
+}
+
+// runResetUserPermissions gives the files on the checked volume to the user with the UID, once it's mounted again
+// after any repairs. Permissions are only reset after the volume is known to be clean so that problems in the
+// filesystem aren't spread by walking it.
+func runResetUserPermissions(ctx context.Context, utility diskutil.DiskUtil, args checkVolume) error {
+	id, err := diskutil.Resolve(ctx, utility, args.id)
+	if err != nil {
+		return fmt.Errorf("invalid volume: %w", err)
+	}
+	volume, err := utility.Info(ctx, id)
+	if err != nil {
+		return fmt.Errorf("unable to get volume information: %w", err)
+	}
+	if volume.MountPoint == "" {
+		return fmt.Errorf("volume [%s] must be mounted to reset user permissions", volume.DeviceIdentifier)
+	}
+
+	fields := logrus.Fields{
+		"mount_point": volume.MountPoint,
+		"uid":         args.resetUID,
+	}
+	if args.dryrun {
+		logrus.WithFields(fields).Warn("Would have reset user permissions")
+		return nil
+	}
+
+	logrus.WithFields(fields).Info("Resetting user permissions...")
+	if _, err := utility.ResetUserPermissions(ctx, volume.DeviceIdentifier, args.resetUID); err != nil {
+		return err
+	}
+	logrus.WithFields(fields).Info("Successfully reset user permissions")
+
+	return nil
+}
+
+// printCheckResult writes the verdict and problems of the check to w.
+func printCheckResult(w io.Writer, format string, result *fsck.Result) error {
+	return printOutput(w, format, result, func(w io.Writer) error {
+		fmt.Fprintf(w, "%s: %s\n", result.Device, result.Verdict)
+		for _, problem := range result.Problems {
+			fmt.Fprintf(w, "  %s\n", problem)
+		}
+
+		return nil
+	})
+}
+
+// volumeRestoreCommand creates a new command which restores a disk image or volume onto a data volume.
+func volumeRestoreCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "restore a data volume from an image",
+		Long: strings.TrimSpace(`
+restore re-images a data volume from a golden disk image or
+another volume with asr(8). The source can be the path to a
+disk image, the S3 URI of a disk image (s3://bucket/key), or
+a volume identifier (e.g. disk4s1) and the target is the
+identifier of the volume to restore onto. Images in S3 are
+downloaded with the instance's credentials and validated
+against --sha256 and the checksum they were uploaded with,
+if any, before they're restored. The
+target is erased and copied block for block unless --erase
+is disabled, in which case files are copied onto it instead.
+Volumes on the boot disk or the host's internal SSD are never
+restored onto. Disk images that weren't created by asr must be
+scanned (--scan) once before they can be restored.
+`),
+		Args: cobra.NoArgs,
+	}
+
+	restoreArgs := restoreVolume{}
+	cmd.PersistentFlags().StringVar(&restoreArgs.source, "source", "", "disk image path, S3 URI, or volume identifier to restore from")
+	cmd.PersistentFlags().StringVar(&restoreArgs.sha256, "sha256", "", "expected SHA-256 checksum of a disk image downloaded from S3")
+	cmd.PersistentFlags().StringVar(&restoreArgs.target, "target", "", "identifier, UUID, label, or mount point of the volume to restore onto")
+	cmd.PersistentFlags().BoolVar(&restoreArgs.erase, "erase", true, "erase the target and copy the source block for block")
+	cmd.PersistentFlags().BoolVar(&restoreArgs.scan, "scan", false, "scan the source disk image before restoring it")
+	cmd.PersistentFlags().BoolVar(&restoreArgs.dryrun, "dry-run", false, "run command without mutating changes")
+	cmd.PersistentFlags().DurationVar(&restoreArgs.timeout, "timeout", restoreDefaultTimeout, "Set the timeout for the command (e.g. 30s, 1m, 1.5h), 0s will disable the timeout")
+	cmd.MarkPersistentFlagRequired("source")
+	cmd.MarkPersistentFlagRequired("target")
+
+	// Restoring volumes with asr requires root permissions and Full Disk Access.
+	cmd.PreRunE = assertDiskPrivileges
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if restoreArgs.timeout != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, restoreArgs.timeout)
+			defer cancel()
+		}
+
+		product := contextual.Product(ctx)
+		if product == nil {
+			return errors.New("product required in context")
+		}
+
+		logrus.WithField("product", product).Info("Configuring diskutil for product")
+		d, err := diskutil.ForProduct(product)
+		if err != nil {
+			return err
+		}
+
+		logrus.WithField("args", restoreArgs).Debug("Running volume restore command with args")
+		if err := runRestore(ctx, d, restoreArgs); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return errors.New("timeout exceeded")
+			}
+
+			return err
+		}
+
+		return nil
+	}
+
+	// Keep other disk operations on the host from running alongside this one.
+	lockDiskOperation(cmd)
+
+	return cmd
+}
+
+// runRestore checks that the target volume is safe to restore onto and restores the source onto it with asr.
+func runRestore(ctx context.Context, utility diskutil.DiskUtil, args restoreVolume) error {
+	targetID, err := diskutil.Resolve(ctx, utility, args.target)
+	if err != nil {
+		return fmt.Errorf("invalid target: %w", err)
+	}
+	target, err := utility.Info(ctx, targetID)
+	if err != nil {
+		return fmt.Errorf("unable to get target information: %w", err)
+	}
+	if target.WholeDisk {
+		return fmt.Errorf("target [%s] is a whole disk, expected a volume", target.DeviceIdentifier)
+	}
+
+	whole, err := utility.Info(ctx, target.ParentWholeDisk)
+	if err != nil {
+		return fmt.Errorf("unable to get target disk information: %w", err)
+	}
+	logrus.WithField("device_id", whole.DeviceIdentifier).Info("Checking that target isn't on the boot disk...")
+	if err := diskutil.AssertNotBootDisk(ctx, utility, whole); err != nil {
+		return err
+	}
+	kind, err := diskutil.ResolveKind(ctx, utility, target)
+	if err != nil {
+		return fmt.Errorf("unable to classify target disk: %w", err)
+	}
+	if kind == types.KindInternal {
+		return fmt.Errorf("target [%s] is on the host's internal SSD", target.DeviceIdentifier)
+	}
+
+	if aws.IsS3URI(args.source) {
+		if args.dryrun {
+			logrus.WithFields(logrus.Fields{
+				"source": args.source,
+				"target": target.DeviceNode,
+				"erase":  args.erase,
+			}).Warn("Would have downloaded image and restored volume")
+			return nil
+		}
+
+		path, cleanup, err := fetchRestoreImage(ctx, &fetch.Fetcher{}, args.source, args.sha256)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		args.source = path
+	} else if args.sha256 != "" {
+		return errors.New("--sha256 only applies to disk images downloaded from S3")
+	}
+
+	source, isImage, err := resolveRestoreSource(ctx, utility, args.source)
+	if err != nil {
+		return err
+	}
+	if source == target.DeviceNode {
+		return fmt.Errorf("source and target are the same volume [%s]", source)
+	}
+
+	if args.dryrun {
+		logrus.WithFields(logrus.Fields{
+			"source": source,
+			"target": target.DeviceNode,
+			"erase":  args.erase,
+		}).Warn("Would have restored volume")
+		return nil
+	}
+
+	if args.scan && isImage {
+		logrus.WithField("image", source).Info("Scanning image...")
+		if err := asr.ImageScan(ctx, source); err != nil {
+			return err
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"source": source,
+		"target": target.DeviceNode,
+	}).Info("Restoring volume...")
+	report := progress.FromContext(ctx)("Restoring " + target.DeviceIdentifier)
+	err = asr.Restore(ctx, asr.RestoreOptions{
+		Source:   source,
+		Target:   target.DeviceNode,
+		Erase:    args.erase,
+		Progress: report.Percent,
+	})
+	report.Done()
+	if err != nil {
+		return err
+	}
+	logrus.WithField("target", target.DeviceNode).Info("Successfully restored volume")
+
+	return nil
+}
+
+// fetchRestoreImage downloads the disk image at the S3 URI to a temporary directory, validating it against the
+// checksum. The returned cleanup function removes the downloaded image.
+func fetchRestoreImage(ctx context.Context, fetcher *fetch.Fetcher, uri, checksum string) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "ec2-macos-utils-restore")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() {
+		if err := os.RemoveAll(dir); err != nil {
+			logrus.WithError(err).WithField("dir", dir).Warn("Unable to remove downloaded image")
+		}
+	}
+
+	image := filepath.Join(dir, filepath.Base(uri))
+	if err := fetcher.Fetch(ctx, uri, image, checksum); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("unable to download image: %w", err)
+	}
+
+	return image, cleanup, nil
+}
+
+// resolveRestoreSource resolves the restore source to the path of a disk image or the device node of a volume.
+// resolveRestoreSource reports whether the source is a disk image.
+func resolveRestoreSource(ctx context.Context, utility diskutil.DiskUtil, source string) (string, bool, error) {
+	if info, err := os.Stat(source); err == nil && info.Mode().IsRegular() {
+		return source, true, nil
+	}
+
+	id, err := diskutil.Resolve(ctx, utility, source)
+	if err != nil {
+		return "", false, fmt.Errorf("source [%s] is neither a disk image nor a volume: %w", source, err)
+	}
+	volume, err := utility.Info(ctx, id)
+	if err != nil {
+		return "", false, fmt.Errorf("unable to get source information: %w", err)
+	}
+
+	return volume.DeviceNode, false, nil
+}
@@ -0,0 +1,342 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/fetch"
+	"github.com/aws/ec2-macos-utils/internal/fsck"
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/diskutilfakes"
+	mock_diskutil "github.com/aws/ec2-macos-utils/pkg/diskutil/mocks"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunProvision_WithInvalidFormat(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_diskutil.NewMockDiskUtil(ctrl)
+
+	err := runProvision(context.Background(), mock, nil, provisionVolume{
+		format:     "ntfs",
+		id:         "disk2",
+		mountPoint: "/Volumes/Data",
+	})
+
+	assert.Error(t, err, "should fail with unsupported format")
+}
+
+func TestRunProvision_WithRelativeMountPoint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_diskutil.NewMockDiskUtil(ctrl)
+
+	err := runProvision(context.Background(), mock, nil, provisionVolume{
+		format:     "APFS",
+		id:         "disk2",
+		mountPoint: "data",
+	})
+
+	assert.Error(t, err, "should fail with relative mount point")
+}
+
+func TestRunProvision_WithInvalidID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_diskutil.NewMockDiskUtil(ctrl)
+	mock.EXPECT().List(gomock.Any(), nil).Return(&types.SystemPartitions{}, nil)
+
+	err := runProvision(context.Background(), mock, nil, provisionVolume{
+		format:     "APFS",
+		id:         "bad",
+		mountPoint: "/Volumes/Data",
+	})
+
+	assert.Error(t, err, "should fail with invalid device identifier")
+}
+
+func TestRunProvision_DryRun(t *testing.T) {
+	const testDiskID = "disk2"
+	var ctx = context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	root := types.DiskInfo{DeviceIdentifier: "disk1s5", ParentWholeDisk: "disk1"}
+	parts := types.SystemPartitions{
+		AllDisksAndPartitions: []types.DiskPart{
+			{DeviceIdentifier: testDiskID},
+		},
+	}
+
+	mock := mock_diskutil.NewMockDiskUtil(ctrl)
+	gomock.InOrder(
+		mock.EXPECT().Info(ctx, testDiskID).Return(&types.DiskInfo{DeviceIdentifier: testDiskID, WholeDisk: true}, nil),
+		mock.EXPECT().Info(ctx, "/").Return(&root, nil),
+		mock.EXPECT().List(ctx, nil).Return(&parts, nil),
+	)
+
+	err := runProvision(ctx, diskutil.Dryrun(mock), nil, provisionVolume{
+		dryrun:     true,
+		format:     "apfs",
+		id:         "/dev/" + testDiskID,
+		label:      "Data",
+		mountPoint: "/Volumes/Data",
+		persist:    true,
+	})
+
+	assert.NoError(t, err, "should stop quietly before formatting the disk")
+}
+
+func TestPersistVolumeMount(t *testing.T) {
+	const testUUID = "AAAAAAAA-BBBB-CCCC-DDDD-FFFFFFFFFFFF"
+
+	dir := t.TempDir()
+	m := &mounts.Manager{
+		FstabPath:     filepath.Join(dir, "fstab"),
+		SyntheticPath: filepath.Join(dir, "synthetic.conf"),
+		Product:       &system.Product{Release: system.Ventura},
+	}
+	mountPoint := filepath.Join(dir, "Build Cache")
+
+	err := persistVolumeMount(context.Background(), m, testUUID, mountPoint, "apfs")
+	assert.NoError(t, err, "should be able to persist mount")
+
+	tab, err := mounts.ReadFstab(m.FstabPath)
+	assert.NoError(t, err)
+	entry, ok := tab.Lookup("UUID=" + testUUID)
+	assert.True(t, ok, "should have fstab entry for volume")
+	assert.Equal(t, mountPoint, entry.File)
+}
+
+func TestPersistVolumeMount_WithoutUUID(t *testing.T) {
+	m := mounts.NewManager(&system.Product{Release: system.Ventura})
+
+	err := persistVolumeMount(context.Background(), m, "", "/Volumes/Data", "apfs")
+
+	assert.Error(t, err, "shouldn't persist a volume without UUID")
+}
+
+func TestRunRestore_WithWholeDiskTarget(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_diskutil.NewMockDiskUtil(ctrl)
+	mock.EXPECT().Info(gomock.Any(), "disk2").Return(&types.DiskInfo{DeviceIdentifier: "disk2", WholeDisk: true}, nil)
+
+	err := runRestore(context.Background(), mock, restoreVolume{source: "/tmp/golden.dmg", target: "disk2"})
+
+	assert.Error(t, err, "should refuse to restore onto a whole disk")
+}
+
+func TestRunRestore_WithBootDiskTarget(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_diskutil.NewMockDiskUtil(ctrl)
+	mock.EXPECT().Info(gomock.Any(), "disk1s5").Return(&types.DiskInfo{DeviceIdentifier: "disk1s5", DeviceNode: "/dev/disk1s5", ParentWholeDisk: "disk1"}, nil)
+	mock.EXPECT().Info(gomock.Any(), "disk1").Return(&types.DiskInfo{DeviceIdentifier: "disk1", WholeDisk: true}, nil)
+	mock.EXPECT().Info(gomock.Any(), "/").Return(&types.DiskInfo{DeviceIdentifier: "disk1s5", ParentWholeDisk: "disk1"}, nil)
+
+	err := runRestore(context.Background(), mock, restoreVolume{source: "/tmp/golden.dmg", target: "disk1s5"})
+
+	assert.Error(t, err, "should refuse to restore onto the boot disk")
+}
+
+// restoreTarget is an APFS volume in the container disk3, whose physical store is on disk2.
+var restoreTarget = types.DiskInfo{
+	APFSPhysicalStores: []types.APFSPhysicalStore{{DeviceIdentifier: "disk2s2"}},
+	DeviceIdentifier:   "disk3s1",
+	DeviceNode:         "/dev/disk3s1",
+	ParentWholeDisk:    "disk3",
+	VirtualOrPhysical:  "Virtual",
+}
+
+func TestRunRestore_DryRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	image := filepath.Join(t.TempDir(), "golden.dmg")
+	assert.NoError(t, os.WriteFile(image, nil, 0600))
+
+	mock := mock_diskutil.NewMockDiskUtil(ctrl)
+	mock.EXPECT().Info(gomock.Any(), "disk3s1").Return(&restoreTarget, nil)
+	mock.EXPECT().Info(gomock.Any(), "disk3").Return(&types.DiskInfo{DeviceIdentifier: "disk3", WholeDisk: true}, nil)
+	mock.EXPECT().Info(gomock.Any(), "/").Return(&types.DiskInfo{DeviceIdentifier: "disk1s5", ParentWholeDisk: "disk1"}, nil)
+	mock.EXPECT().Info(gomock.Any(), "disk2").Return(&types.DiskInfo{DeviceIdentifier: "disk2", MediaName: "Amazon Elastic Block Store", VirtualOrPhysical: "Physical", WholeDisk: true}, nil)
+
+	err := runRestore(context.Background(), mock, restoreVolume{source: image, target: "disk3s1", erase: true, dryrun: true})
+
+	assert.NoError(t, err, "should succeed without restoring in dry-run mode")
+}
+
+func TestRunRestore_DryRunFromS3(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_diskutil.NewMockDiskUtil(ctrl)
+	mock.EXPECT().Info(gomock.Any(), "disk3s1").Return(&restoreTarget, nil)
+	mock.EXPECT().Info(gomock.Any(), "disk3").Return(&types.DiskInfo{DeviceIdentifier: "disk3", WholeDisk: true}, nil)
+	mock.EXPECT().Info(gomock.Any(), "/").Return(&types.DiskInfo{DeviceIdentifier: "disk1s5", ParentWholeDisk: "disk1"}, nil)
+	mock.EXPECT().Info(gomock.Any(), "disk2").Return(&types.DiskInfo{DeviceIdentifier: "disk2", MediaName: "Amazon Elastic Block Store", VirtualOrPhysical: "Physical", WholeDisk: true}, nil)
+
+	err := runRestore(context.Background(), mock, restoreVolume{source: "s3://images/golden.dmg", target: "disk3s1", erase: true, dryrun: true})
+
+	assert.NoError(t, err, "should succeed without downloading in dry-run mode")
+}
+
+func TestRunRestore_WithInternalSSDTarget(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_diskutil.NewMockDiskUtil(ctrl)
+	mock.EXPECT().Info(gomock.Any(), "disk3s1").Return(&restoreTarget, nil)
+	mock.EXPECT().Info(gomock.Any(), "disk3").Return(&types.DiskInfo{DeviceIdentifier: "disk3", WholeDisk: true}, nil)
+	mock.EXPECT().Info(gomock.Any(), "/").Return(&types.DiskInfo{DeviceIdentifier: "disk1s5", ParentWholeDisk: "disk1"}, nil)
+	mock.EXPECT().Info(gomock.Any(), "disk2").Return(&types.DiskInfo{DeviceIdentifier: "disk2", MediaName: "APPLE SSD AP0256M", VirtualOrPhysical: "Physical", WholeDisk: true}, nil)
+
+	err := runRestore(context.Background(), mock, restoreVolume{source: "/tmp/golden.dmg", target: "disk3s1"})
+
+	assert.EqualError(t, err, "target [disk3s1] is on the host's internal SSD")
+}
+
+func TestFetchRestoreImage(t *testing.T) {
+	fetcher := &fetch.Fetcher{S3: fakeObjectGetter{"s3://images/macos/golden.dmg": "image"}}
+
+	image, cleanup, err := fetchRestoreImage(context.Background(), fetcher, "s3://images/macos/golden.dmg", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "golden.dmg", filepath.Base(image))
+	data, err := os.ReadFile(image)
+	assert.NoError(t, err)
+	assert.Equal(t, "image", string(data))
+
+	cleanup()
+	_, err = os.Stat(filepath.Dir(image))
+	assert.True(t, os.IsNotExist(err), "should remove the downloaded image")
+}
+
+// fakeObjectGetter serves objects by their S3 URIs.
+type fakeObjectGetter map[string]string
+
+func (f fakeObjectGetter) GetObject(ctx context.Context, bucket, key string) (*aws.Object, error) {
+	body, ok := f["s3://"+bucket+"/"+key]
+	if !ok {
+		return nil, &aws.APIError{StatusCode: http.StatusNotFound, Code: "NoSuchKey"}
+	}
+
+	return &aws.Object{Body: io.NopCloser(strings.NewReader(body)), Size: int64(len(body))}, nil
+}
+
+func TestResolveRestoreSource_WithInvalidSource(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_diskutil.NewMockDiskUtil(ctrl)
+	mock.EXPECT().List(gomock.Any(), nil).Return(&types.SystemPartitions{}, nil)
+
+	_, _, err := resolveRestoreSource(context.Background(), mock, "/does/not/exist.dmg")
+
+	assert.Error(t, err, "should fail for a missing image")
+}
+
+func TestRunFormat_WithInvalidFormat(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_diskutil.NewMockDiskUtil(ctrl)
+
+	err := runFormat(context.Background(), mock, formatVolume{format: "ntfs", id: "disk2"})
+
+	assert.Error(t, err, "should fail with unsupported format")
+}
+
+func TestRunFormat_DryRun(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mock := mock_diskutil.NewMockDiskUtil(ctrl)
+	mock.EXPECT().Info(gomock.Any(), "disk2").Return(&types.DiskInfo{DeviceIdentifier: "disk2", WholeDisk: true}, nil)
+	mock.EXPECT().Info(gomock.Any(), "/").Return(&types.DiskInfo{DeviceIdentifier: "disk1s5", ParentWholeDisk: "disk1"}, nil)
+	mock.EXPECT().List(gomock.Any(), nil).Return(&types.SystemPartitions{
+		AllDisksAndPartitions: []types.DiskPart{{DeviceIdentifier: "disk2"}},
+	}, nil)
+
+	err := runFormat(context.Background(), diskutil.Dryrun(mock), formatVolume{format: "APFS", id: "/dev/disk2", label: "Data", dryrun: true})
+
+	assert.NoError(t, err, "should succeed without formatting in dry-run mode")
+}
+
+func TestRunFormat_ForceMounted(t *testing.T) {
+	ctx := context.Background()
+	fake := diskutilfakes.New(fakeBootDisk(), diskutilfakes.Disk{
+		ID:        "disk2",
+		Size:      50_000_000_000,
+		MediaName: "Amazon Elastic Block Store",
+		Partitions: []diskutilfakes.Partition{
+			{Content: "EFI", Size: 209_715_200, VolumeName: "EFI"},
+			{Content: "Apple_HFS", Size: 49_000_000_000, VolumeName: "Data", MountPoint: "/Volumes/Data"},
+		},
+	})
+
+	assert.Error(t, runFormat(ctx, fake, formatVolume{format: "APFS", id: "disk2", label: "Scratch"}), "disks that aren't blank should be rejected")
+
+	assert.NoError(t, runFormat(ctx, fake, formatVolume{format: "APFS", id: "disk2", label: "Scratch", force: true}))
+	var methods []string
+	for _, call := range fake.Calls() {
+		if call.Method == "UnmountDisk" || call.Method == "NewFS" {
+			methods = append(methods, call.Method)
+		}
+	}
+	assert.Equal(t, []string{"UnmountDisk", "NewFS"}, methods, "the disk's volumes should be unmounted before it's formatted")
+	assert.Equal(t, "Scratch", fake.Disks()[1].VolumeName)
+}
+
+func TestPrintCheckResult(t *testing.T) {
+	result := &fsck.Result{
+		Device:   "/dev/rdisk3s1",
+		Mode:     fsck.ModeRepair,
+		Verdict:  fsck.VerdictRepaired,
+		Problems: []string{"error: dstream (oid 0x405f2c): invalid refcnt (0, should be 1)"},
+	}
+	expected := "/dev/rdisk3s1: repaired\n" +
+		"  error: dstream (oid 0x405f2c): invalid refcnt (0, should be 1)\n"
+
+	var buf bytes.Buffer
+	err := printCheckResult(&buf, outputText, result)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestRunResetUserPermissions(t *testing.T) {
+	ctx := context.Background()
+	fake := diskutilfakes.New(fakeBootDisk())
+
+	assert.NoError(t, runResetUserPermissions(ctx, fake, checkVolume{id: "/", resetUID: 501}))
+	assert.Contains(t, fake.Calls(), diskutilfakes.Call{Method: "ResetUserPermissions", Args: []string{"disk3s1", "501"}})
+
+	dryrun := diskutilfakes.New(fakeBootDisk())
+	assert.NoError(t, runResetUserPermissions(ctx, diskutil.Dryrun(dryrun), checkVolume{id: "/", resetUID: 501, dryrun: true}))
+	for _, call := range dryrun.Calls() {
+		assert.NotEqual(t, "ResetUserPermissions", call.Method, "permissions shouldn't be reset in dry-run mode")
+	}
+
+	_, err := fake.Unmount(ctx, "disk3s1")
+	assert.NoError(t, err)
+	assert.Error(t, runResetUserPermissions(ctx, fake, checkVolume{id: "disk3s1", resetUID: 501}), "unmounted volumes should be rejected")
+}
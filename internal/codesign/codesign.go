@@ -0,0 +1,147 @@
+// Package codesign provides the functionality necessary for verifying the code signatures, and notarization, of
+// executables with macOS's codesign CLI.
+package codesign
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// teamNotSet is the team identifier codesign reports for ad-hoc and unsigned code.
+const teamNotSet = "not set"
+
+// Signature is the verified code signature of an executable.
+type Signature struct {
+	// Path is the path to the executable.
+	Path string `json:"path"`
+	// Signed indicates that the executable has a code signature.
+	Signed bool `json:"signed"`
+	// AdHoc indicates that the signature isn't made by a certificate, which is the case for local builds.
+	AdHoc bool `json:"ad_hoc"`
+	// Valid indicates that the executable matches its signature. Signed executables that aren't valid have been
+	// modified since they were signed.
+	Valid bool `json:"valid"`
+	// Notarized indicates that Apple notarized the executable.
+	Notarized bool `json:"notarized"`
+	// Identifier is the signing identifier (e.g. "com.amazon.ec2.macos-utils").
+	Identifier string `json:"identifier,omitempty"`
+	// TeamID is the Apple Developer team that signed the executable.
+	TeamID string `json:"team_id,omitempty"`
+	// Authorities is the certificate chain of the signature, starting with the signing certificate.
+	Authorities []string `json:"authorities,omitempty"`
+	// Problem is why codesign rejected the signature.
+	Problem string `json:"problem,omitempty"`
+}
+
+// Tampered checks if the executable was modified after it was signed.
+func (s *Signature) Tampered() bool {
+	return s.Signed && !s.Valid
+}
+
+// String describes the signature for operators.
+func (s *Signature) String() string {
+	switch {
+	case !s.Signed:
+		return "not signed"
+	case s.Tampered():
+		return "signature invalid: " + s.Problem
+	case s.AdHoc:
+		return "ad-hoc signed"
+	case s.Notarized:
+		return fmt.Sprintf("signed by %s and notarized", s.signer())
+	default:
+		return fmt.Sprintf("signed by %s, not notarized", s.signer())
+	}
+}
+
+// signer names the signing certificate, or the team when the certificate isn't known.
+func (s *Signature) signer() string {
+	if len(s.Authorities) > 0 {
+		return s.Authorities[0]
+	}
+
+	return s.TeamID
+}
+
+// Verify verifies the code signature of the executable at path. Notarization is checked when notarized is set, which
+// may contact Apple's servers when the notarization ticket isn't stapled. Executables that aren't signed or don't
+// match their signature aren't an error, only failing to check them is.
+func Verify(ctx context.Context, path string, notarized bool) (*Signature, error) {
+	// cmdDisplay represents the command used for executing macOS's codesign to describe a signature.
+	//   * --display - print information about the signature
+	//   * --verbose=2 - include the certificate chain and team identifier
+	cmdDisplay := []string{"codesign", "--display", "--verbose=2", path}
+
+	// codesign prints the signature's information on stderr and fails for code that isn't signed.
+	out, err := util.ExecuteCommand(ctx, cmdDisplay, "", nil, nil)
+	if strings.Contains(out.Stderr, "not signed at all") {
+		return &Signature{Path: path}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("codesign: failed to display signature of %s, stderr: [%s]: %w", path, strings.TrimSpace(out.Stderr), err)
+	}
+	s := parseDisplay(out.Stderr)
+	s.Path = path
+
+	// cmdVerify represents the command used for executing macOS's codesign to verify a signature.
+	//   * --verify - check that the code matches its signature
+	//   * --strict - also reject signatures that are valid but malformed
+	cmdVerify := []string{"codesign", "--verify", "--strict", path}
+	out, err = util.ExecuteCommand(ctx, cmdVerify, "", nil, nil)
+	if err != nil {
+		s.Problem = verifyProblem(out.Stderr, path)
+		return s, nil
+	}
+	s.Valid = true
+
+	if notarized && !s.AdHoc {
+		// cmdNotarized represents the command used for executing macOS's codesign to check notarization.
+		//   * --verify - check that the code matches its signature
+		//   * --test-requirement=notarized - check that the signature satisfies the "notarized" requirement
+		cmdNotarized := []string{"codesign", "--verify", "--test-requirement==notarized", path}
+		_, err = util.ExecuteCommand(ctx, cmdNotarized, "", nil, nil)
+		s.Notarized = err == nil
+	}
+
+	return s, nil
+}
+
+// parseDisplay parses the "key=value" lines printed by "codesign --display --verbose=2".
+func parseDisplay(out string) *Signature {
+	s := &Signature{Signed: true}
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "Identifier":
+			s.Identifier = value
+		case "TeamIdentifier":
+			if value != teamNotSet {
+				s.TeamID = value
+			}
+		case "Authority":
+			s.Authorities = append(s.Authorities, value)
+		case "Signature":
+			s.AdHoc = value == "adhoc"
+		}
+	}
+
+	return s
+}
+
+// verifyProblem extracts why codesign rejected the signature from its output (e.g. "/path: invalid signature (code
+// or signature have been modified)").
+func verifyProblem(out, path string) string {
+	for _, line := range strings.Split(out, "\n") {
+		if problem := strings.TrimPrefix(strings.TrimSpace(line), path+": "); problem != strings.TrimSpace(line) {
+			return problem
+		}
+	}
+
+	return strings.TrimSpace(out)
+}
@@ -0,0 +1,67 @@
+package codesign
+
+import (
+	_ "embed"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	// displayOutput contains the signature printed by "codesign --display --verbose=2" for a Developer ID signed
+	// executable.
+	//
+	//go:embed testdata/display.txt
+	displayOutput string
+
+	// adhocOutput contains the signature printed by "codesign --display --verbose=2" for a local build, which the
+	// linker signs ad-hoc.
+	//
+	//go:embed testdata/adhoc.txt
+	adhocOutput string
+)
+
+func TestParseDisplay(t *testing.T) {
+	s := parseDisplay(displayOutput)
+
+	assert.Equal(t, &Signature{
+		Signed:     true,
+		Identifier: "com.amazon.ec2.macos-utils",
+		TeamID:     "94KV3E626L",
+		Authorities: []string{
+			"Developer ID Application: Amazon Web Services, Inc. (94KV3E626L)",
+			"Developer ID Certification Authority",
+			"Apple Root CA",
+		},
+	}, s)
+}
+
+func TestParseDisplay_AdHoc(t *testing.T) {
+	s := parseDisplay(adhocOutput)
+
+	assert.True(t, s.AdHoc)
+	assert.Empty(t, s.TeamID)
+	assert.Equal(t, "ec2-macos-utils", s.Identifier)
+}
+
+func TestVerifyProblem(t *testing.T) {
+	out := "/usr/local/bin/ec2-macos-utils: invalid signature (code or signature have been modified)\n" +
+		"In architecture: arm64\n"
+
+	assert.Equal(t, "invalid signature (code or signature have been modified)", verifyProblem(out, "/usr/local/bin/ec2-macos-utils"))
+}
+
+func TestSignatureString(t *testing.T) {
+	s := parseDisplay(displayOutput)
+	s.Valid = true
+	assert.Equal(t, "signed by Developer ID Application: Amazon Web Services, Inc. (94KV3E626L), not notarized", s.String())
+
+	s.Notarized = true
+	assert.Equal(t, "signed by Developer ID Application: Amazon Web Services, Inc. (94KV3E626L) and notarized", s.String())
+
+	s = &Signature{Signed: true, Problem: "invalid signature (code or signature have been modified)"}
+	assert.True(t, s.Tampered())
+	assert.Equal(t, "signature invalid: invalid signature (code or signature have been modified)", s.String())
+
+	assert.Equal(t, "not signed", (&Signature{}).String())
+}
@@ -0,0 +1,258 @@
+// Package config provides the functionality necessary for loading the EC2 macOS Utils configuration file.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is the path to the configuration file read when no other path is given.
+const DefaultPath = "/usr/local/aws/ec2-macos-utils/config.yaml"
+
+// Config is the configuration of the utility. Every section is optional and commands fall back to their defaults
+// for anything that isn't configured.
+type Config struct {
+	// Setup configures the settings managed with systemsetup.
+	Setup Setup `yaml:"setup"`
+	// Defaults configures preferences managed with defaults.
+	Defaults []Default `yaml:"defaults"`
+	// Firewall configures the Application Firewall.
+	Firewall Firewall `yaml:"firewall"`
+	// Notifications configures where the outcomes of disk operations are sent.
+	Notifications Notifications `yaml:"notifications"`
+	// Power configures the power management settings managed with pmset.
+	Power map[string]string `yaml:"power"`
+	// Network configures the network interfaces.
+	Network Network `yaml:"network"`
+	// Users configures local user accounts, which are created when they don't exist.
+	Users []User `yaml:"users"`
+	// SSH configures the keys authorized to log in with SSH and the SSH server.
+	SSH SSH `yaml:"ssh"`
+	// Volumes configures the data volumes that are provisioned.
+	Volumes []Volume `yaml:"volumes"`
+	// Mounts configures the filesystems persisted in fstab.
+	Mounts []Mount `yaml:"mounts"`
+	// Automation configures the kill switch that pauses the utility's automatic behaviors.
+	Automation Automation `yaml:"automation"`
+}
+
+// Setup configures the settings managed with systemsetup. Unset values are left as they are on the system.
+type Setup struct {
+	// RemoteLogin enables or disables SSH access to the instance.
+	RemoteLogin *bool `yaml:"remote_login"`
+	// Timezone is the name of the system's time zone (e.g. "America/Los_Angeles"). The special value "auto"
+	// derives the time zone from the instance's AWS Region.
+	Timezone string `yaml:"timezone"`
+	// RestartOnFreeze enables or disables restarting the system automatically after it freezes.
+	RestartOnFreeze *bool `yaml:"restart_on_freeze"`
+	// NetworkTime enables or disables synchronizing the clock with a network time server.
+	NetworkTime *bool `yaml:"network_time"`
+	// NetworkTimeServer is the network time server to synchronize the clock with (e.g. "169.254.169.123" for the
+	// Amazon Time Sync Service).
+	NetworkTimeServer string `yaml:"network_time_server"`
+}
+
+// Firewall configures the Application Firewall. Unset values are left as they are on the system.
+type Firewall struct {
+	// Enabled enables or disables the firewall.
+	Enabled *bool `yaml:"enabled"`
+	// StealthMode enables or disables ignoring probes (e.g. ICMP pings) on closed ports.
+	StealthMode *bool `yaml:"stealth_mode"`
+	// BlockAll enables or disables blocking all incoming connections.
+	BlockAll *bool `yaml:"block_all"`
+	// AllowedApps are the paths to applications allowed to accept incoming connections.
+	AllowedApps []string `yaml:"allowed_apps"`
+}
+
+// Notifications configures where the outcomes of disk operations (e.g. growing a container) are sent. Nothing is
+// sent when neither a webhook nor a topic is set.
+type Notifications struct {
+	// WebhookURL is the HTTPS URL that outcomes are posted to as JSON.
+	WebhookURL string `yaml:"webhook_url"`
+	// SNSTopicARN is the ARN of the SNS topic that outcomes are published to with the instance profile's
+	// credentials.
+	SNSTopicARN string `yaml:"sns_topic_arn"`
+	// Operations are the operations (grow, repair, resize, or provision) whose outcomes are sent. The outcomes of
+	// all operations are sent when unset.
+	Operations []string `yaml:"operations"`
+}
+
+// Network configures the network interfaces. Unset values are left as they are on the system.
+type Network struct {
+	// MTU is the desired MTU of each interface keyed by its device name (e.g. "en0": 9001).
+	MTU map[string]int `yaml:"mtu"`
+	// DNS is the DNS configuration of each network service keyed by its name (e.g. "Ethernet").
+	DNS map[string]DNS `yaml:"dns"`
+	// Proxy configures the system-wide HTTP and HTTPS proxies.
+	Proxy Proxy `yaml:"proxy"`
+}
+
+// DNS configures a network service's DNS servers and search domains. Unset lists are left as they are on the system
+// while empty lists clear the service's setting so that DHCP's is used.
+type DNS struct {
+	// Servers are the addresses of the DNS servers (e.g. corporate resolvers reachable over the VPC), in order.
+	Servers []string `yaml:"servers"`
+	// SearchDomains are the domains appended to unqualified names, in order.
+	SearchDomains []string `yaml:"search_domains"`
+}
+
+// Proxy configures the HTTP and HTTPS proxies of the network services, e.g. for instances in VPCs whose egress goes
+// through a proxy. Unset values are left as they are on the system.
+type Proxy struct {
+	// HTTP is the address of the proxy used for HTTP (e.g. "proxy.example.com:3128"), or "off" to disable it.
+	HTTP string `yaml:"http"`
+	// HTTPS is the address of the proxy used for HTTPS, or "off" to disable it.
+	HTTPS string `yaml:"https"`
+	// Bypass are the hosts and domains that bypass the proxies (e.g. "*.internal"). An empty list clears them.
+	Bypass []string `yaml:"bypass"`
+	// Services are the names of the network services that are configured. All services are configured when unset.
+	Services []string `yaml:"services"`
+	// LaunchdEnvironment also sets the matching HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables for
+	// launchd's services at boot.
+	LaunchdEnvironment bool `yaml:"launchd_environment"`
+}
+
+// SSH configures the keys authorized to log in with SSH and the SSH server.
+type SSH struct {
+	// User is the user whose authorized_keys are managed. The ec2-user is used when unset.
+	User string `yaml:"user"`
+	// AuthorizedKeys are the OpenSSH public keys that should be authorized.
+	AuthorizedKeys []string `yaml:"authorized_keys"`
+	// FromMetadata also authorizes the keys that the instance was launched with.
+	FromMetadata bool `yaml:"from_metadata"`
+	// Server configures the SSH server's settings.
+	Server SSHServer `yaml:"server"`
+	// Sync configures the central sources that local accounts and their keys are synced from.
+	Sync SSHSync `yaml:"sync"`
+}
+
+// SSHSync configures the central sources that local accounts and their keys are synced from. Nothing is synced when
+// neither is set.
+type SSHSync struct {
+	// Manifest is the path or S3 URI of a YAML or JSON manifest listing the accounts and their keys.
+	Manifest string `yaml:"manifest"`
+	// IAMGroup is the name of the IAM group whose members are synced with their active SSH public keys.
+	IAMGroup string `yaml:"iam_group"`
+}
+
+// SSHServer configures the SSH server's settings, which are written to a fragment in sshd_config.d. Password
+// authentication and root logins are denied, and idle clients are sent keepalives, unless configured otherwise.
+type SSHServer struct {
+	// PasswordAuthentication allows or denies logging in with passwords, including keyboard-interactive logins.
+	PasswordAuthentication *bool `yaml:"password_authentication"`
+	// PermitRootLogin allows logging in as root: "yes", "no", "prohibit-password", or "forced-commands-only".
+	PermitRootLogin string `yaml:"permit_root_login"`
+	// AllowUsers are the only users (or user@host patterns) allowed to log in. All users are allowed when unset.
+	AllowUsers []string `yaml:"allow_users"`
+	// ClientAliveInterval is the number of seconds after which idle clients are sent a keepalive message.
+	ClientAliveInterval int `yaml:"client_alive_interval"`
+	// ClientAliveCountMax is the number of keepalive messages that can go unanswered before the client is
+	// disconnected.
+	ClientAliveCountMax int `yaml:"client_alive_count_max"`
+}
+
+// Automation configures the kill switch that pauses scheduled runs of the utility across a fleet of instances.
+type Automation struct {
+	// KillSwitchTag is the key of the instance tag that pauses automation. The ec2-macos-utils:automation tag is
+	// checked when unset.
+	KillSwitchTag string `yaml:"kill_switch_tag"`
+	// KillSwitchParameter is the name of the Parameter Store parameter that pauses automation. No parameter is
+	// checked when unset.
+	KillSwitchParameter string `yaml:"kill_switch_parameter"`
+}
+
+// User is a local user account. Accounts that don't exist are created with a random password, existing accounts are
+// only changed to match Admin and AuthorizedKeys.
+type User struct {
+	// Name is the account's short name.
+	Name string `yaml:"name"`
+	// FullName is the account's full name. The short name is used when unset.
+	FullName string `yaml:"full_name"`
+	// Shell is the login shell the account is created with. macOS's default shell is used when unset.
+	Shell string `yaml:"shell"`
+	// Admin is whether the account is an administrator. Existing accounts' memberships are left as they are when
+	// unset.
+	Admin *bool `yaml:"admin"`
+	// AuthorizedKeys are the OpenSSH public keys authorized to log in as the account. Keys that are already
+	// authorized are left as they are.
+	AuthorizedKeys []string `yaml:"authorized_keys"`
+}
+
+// Volume is a data volume provisioned as volume provision does: blank disks are formatted while disks that already
+// hold a data volume are reused as-is, and the volume is mounted at its mount point.
+type Volume struct {
+	// ID is the disk identifier (e.g. disk2) or the ID of the attached EBS volume (e.g. vol-0123456789abcdef0).
+	ID string `yaml:"id"`
+	// MountPoint is the absolute path that the volume is mounted at.
+	MountPoint string `yaml:"mount_point"`
+	// Format is the filesystem blank disks are formatted with (APFS, JHFS+, ExFAT, or FAT32). APFS is used when
+	// unset.
+	Format string `yaml:"format"`
+	// Label is the name of the volume created on blank disks. "Data" is used when unset.
+	Label string `yaml:"label"`
+	// Persist enables or disables persisting the mount in fstab. Mounts are persisted when unset.
+	Persist *bool `yaml:"persist"`
+	// DisableSpotlight disables Spotlight indexing of the volume.
+	DisableSpotlight bool `yaml:"disable_spotlight"`
+}
+
+// Mount is a filesystem persisted in fstab.
+type Mount struct {
+	// Spec is the filesystem to mount, preferably as UUID=<VolumeUUID>.
+	Spec string `yaml:"spec"`
+	// MountPoint is the absolute path that the filesystem is mounted at.
+	MountPoint string `yaml:"mount_point"`
+	// Type is the type of the filesystem (e.g. apfs). apfs is used when unset.
+	Type string `yaml:"type"`
+	// Options are the comma separated mount options. "rw,auto" is used when unset.
+	Options string `yaml:"options"`
+}
+
+// Default is the desired value of a preference managed with defaults.
+type Default struct {
+	// Domain is the preference domain (e.g. "com.apple.finder" or "/Library/Preferences/com.apple.loginwindow").
+	Domain string `yaml:"domain"`
+	// Key is the name of the preference.
+	Key string `yaml:"key"`
+	// Type is the type of the value (bool, int, string, or dict). The type is inferred from the value when unset.
+	Type string `yaml:"type"`
+	// Value is the desired value of the preference.
+	Value interface{} `yaml:"value"`
+	// User is the user whose preferences are changed. The preferences of the user running the utility are changed
+	// when unset.
+	User string `yaml:"user"`
+	// CurrentHost restricts the preference to the current host.
+	CurrentHost bool `yaml:"current_host"`
+}
+
+// Load reads the configuration file at path, rendering its templates with the variables. A missing file is treated as
+// an empty configuration.
+func Load(path string, vars VarsFunc) (*Config, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return DecodeTemplate(f, vars)
+}
+
+// Decode reads the configuration from the reader. Unknown keys are rejected so that typos don't silently go
+// unapplied.
+func Decode(r io.Reader) (*Config, error) {
+	c := &Config{}
+
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error decoding config: %w", err)
+	}
+
+	return c, nil
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecode(t *testing.T) {
+	c, err := Decode(strings.NewReader(`
+setup:
+  remote_login: true
+  timezone: auto
+`))
+
+	assert.NoError(t, err)
+	assert.NotNil(t, c.Setup.RemoteLogin)
+	assert.True(t, *c.Setup.RemoteLogin)
+	assert.Equal(t, "auto", c.Setup.Timezone)
+	assert.Nil(t, c.Setup.RestartOnFreeze, "unset values should be nil")
+}
+
+func TestDecode_Defaults(t *testing.T) {
+	c, err := Decode(strings.NewReader(`
+defaults:
+  - domain: com.apple.screensaver
+    key: idleTime
+    value: 0
+    current_host: true
+  - domain: com.apple.finder
+    key: FXPreferredViewStyle
+    type: string
+    value: Nlsv
+    user: ec2-user
+`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Default{
+		{Domain: "com.apple.screensaver", Key: "idleTime", Value: 0, CurrentHost: true},
+		{Domain: "com.apple.finder", Key: "FXPreferredViewStyle", Type: "string", Value: "Nlsv", User: "ec2-user"},
+	}, c.Defaults)
+}
+
+func TestDecode_Firewall(t *testing.T) {
+	c, err := Decode(strings.NewReader(`
+firewall:
+  enabled: true
+  stealth_mode: false
+  allowed_apps:
+    - /Applications/Xcode.app
+`))
+
+	assert.NoError(t, err)
+	assert.True(t, *c.Firewall.Enabled)
+	assert.False(t, *c.Firewall.StealthMode)
+	assert.Nil(t, c.Firewall.BlockAll, "unset values should be nil")
+	assert.Equal(t, []string{"/Applications/Xcode.app"}, c.Firewall.AllowedApps)
+}
+
+func TestDecode_Notifications(t *testing.T) {
+	c, err := Decode(strings.NewReader(`
+notifications:
+  webhook_url: https://example.com/hooks/disks
+  sns_topic_arn: arn:aws:sns:us-east-1:123456789012:disks
+  operations:
+    - grow
+    - repair
+`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, Notifications{
+		WebhookURL:  "https://example.com/hooks/disks",
+		SNSTopicARN: "arn:aws:sns:us-east-1:123456789012:disks",
+		Operations:  []string{"grow", "repair"},
+	}, c.Notifications)
+}
+
+func TestDecode_DNS(t *testing.T) {
+	c, err := Decode(strings.NewReader(`
+network:
+  dns:
+    Ethernet:
+      servers: [10.0.0.2, 10.0.0.3]
+      search_domains: []
+`))
+
+	assert.NoError(t, err)
+	dns := c.Network.DNS["Ethernet"]
+	assert.Equal(t, []string{"10.0.0.2", "10.0.0.3"}, dns.Servers)
+	assert.NotNil(t, dns.SearchDomains, "empty lists should be kept to clear the setting")
+	assert.Empty(t, dns.SearchDomains)
+}
+
+func TestDecode_Proxy(t *testing.T) {
+	c, err := Decode(strings.NewReader(`
+network:
+  proxy:
+    http: proxy.example.com:3128
+    https: "off"
+    bypass: ["*.internal"]
+    launchd_environment: true
+`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, Proxy{
+		HTTP:               "proxy.example.com:3128",
+		HTTPS:              "off",
+		Bypass:             []string{"*.internal"},
+		LaunchdEnvironment: true,
+	}, c.Network.Proxy)
+}
+
+func TestDecode_SSHServer(t *testing.T) {
+	c, err := Decode(strings.NewReader(`
+ssh:
+  server:
+    password_authentication: false
+    permit_root_login: "no"
+    allow_users: [ec2-user, ci]
+    client_alive_interval: 60
+`))
+
+	passwordAuthentication := false
+	assert.NoError(t, err)
+	assert.Equal(t, SSHServer{
+		PasswordAuthentication: &passwordAuthentication,
+		PermitRootLogin:        "no",
+		AllowUsers:             []string{"ec2-user", "ci"},
+		ClientAliveInterval:    60,
+	}, c.SSH.Server)
+}
+
+func TestDecode_SSHSync(t *testing.T) {
+	c, err := Decode(strings.NewReader(`
+ssh:
+  sync:
+    manifest: s3://bucket/users.yaml
+    iam_group: mac-users
+`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, SSHSync{Manifest: "s3://bucket/users.yaml", IAMGroup: "mac-users"}, c.SSH.Sync)
+}
+
+func TestDecode_UsersAndVolumes(t *testing.T) {
+	c, err := Decode(strings.NewReader(`
+users:
+  - name: runner
+    admin: false
+    authorized_keys: [ssh-ed25519 AAAA ci]
+volumes:
+  - id: vol-0123456789abcdef0
+    mount_point: /Volumes/Data
+    persist: false
+`))
+
+	admin, persist := false, false
+	assert.NoError(t, err)
+	assert.Equal(t, []User{{Name: "runner", Admin: &admin, AuthorizedKeys: []string{"ssh-ed25519 AAAA ci"}}}, c.Users)
+	assert.Equal(t, []Volume{{ID: "vol-0123456789abcdef0", MountPoint: "/Volumes/Data", Persist: &persist}}, c.Volumes)
+}
+
+func TestDecode_Empty(t *testing.T) {
+	c, err := Decode(strings.NewReader(""))
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{}, c)
+}
+
+func TestDecode_UnknownKey(t *testing.T) {
+	_, err := Decode(strings.NewReader("setup:\n  remote_logn: true\n"))
+
+	assert.Error(t, err, "unknown keys should be rejected")
+}
+
+func TestLoad_Missing(t *testing.T) {
+	c, err := Load(filepath.Join(t.TempDir(), "config.yaml"), nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{}, c)
+}
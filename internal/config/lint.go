@@ -0,0 +1,383 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/aws/ec2-macos-utils/internal/defaults"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/events"
+)
+
+// maxMTU is the largest MTU supported by EC2 instances' network interfaces (jumbo frames).
+const maxMTU = 9001
+
+var (
+	// userNamePattern matches the short names of local accounts.
+	userNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_.-]{0,31}$`)
+	// decodeErrorPattern matches the line number in yaml's decoding errors (e.g. "line 3: field x not found").
+	decodeErrorPattern = regexp.MustCompile(`line (\d+): (.*)$`)
+)
+
+// Problem is an issue found in a configuration file by Lint.
+type Problem struct {
+	// Line is the line of the file the problem was found on, 0 when it isn't known.
+	Line int `json:"line"`
+	// Path is the key of the value with the problem (e.g. "users[0].name"), empty when the file can't be decoded.
+	Path string `json:"path"`
+	// Message describes the problem.
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	var location []string
+	if p.Line != 0 {
+		location = append(location, "line "+strconv.Itoa(p.Line))
+	}
+	if p.Path != "" {
+		location = append(location, p.Path)
+	}
+	if len(location) == 0 {
+		return p.Message
+	}
+
+	return strings.Join(location, ", ") + ": " + p.Message
+}
+
+// Lint decodes and validates the configuration file's contents, returning every problem found with the line it's on.
+// Problems that stop the file from being decoded (e.g. syntax errors, invalid templates, unknown keys, or values of
+// the wrong type) are returned on their own since the values can't be validated until they're fixed. Templates are
+// rendered with sample variables, and tags that aren't set render as empty values.
+func Lint(data []byte) []Problem {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return decodeProblems(err)
+	}
+
+	// Templates are rendered in a copy of the document so that the values' lines are still those of the file.
+	var rendered yaml.Node
+	_ = yaml.Unmarshal(data, &rendered)
+	var problems []Problem
+	templated := map[int]bool{}
+	for _, n := range templateNodes(&rendered) {
+		templated[n.Line] = true
+		if err := render([]*yaml.Node{n}, sampleVars, "missingkey=zero"); err != nil {
+			problems = append(problems, decodeProblems(err)...)
+		}
+	}
+
+	// Unknown keys are only rejected when decoding files, so they're checked in the file as it's written. Templates
+	// are checked once they're rendered since they may not decode as the type of their value until then.
+	if _, err := Decode(bytes.NewReader(data)); err != nil {
+		for _, p := range decodeProblems(err) {
+			if !templated[p.Line] {
+				problems = append(problems, p)
+			}
+		}
+	}
+	if len(problems) != 0 {
+		return problems
+	}
+
+	c := &Config{}
+	if err := rendered.Decode(c); err != nil {
+		return decodeProblems(err)
+	}
+
+	problems = c.Validate()
+	for i := range problems {
+		problems[i].Line = lineOf(&root, problems[i].Path)
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Line != problems[j].Line {
+			return problems[i].Line < problems[j].Line
+		}
+		return problems[i].Path < problems[j].Path
+	})
+
+	return problems
+}
+
+// decodeProblems splits a decoding error into a problem for each line that it reports.
+func decodeProblems(err error) []Problem {
+	var messages []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+
+	problems := make([]Problem, 0, len(messages))
+	for _, msg := range messages {
+		p := Problem{Message: strings.TrimPrefix(msg, "yaml: ")}
+		if m := decodeErrorPattern.FindStringSubmatch(msg); m != nil {
+			p.Line, _ = strconv.Atoi(m[1])
+			p.Message = m[2]
+		}
+		problems = append(problems, p)
+	}
+
+	return problems
+}
+
+// Validate checks the configuration's values, returning a problem for each value that commands would reject or
+// that can't work on an instance. Values that aren't configured aren't checked. Problems are in the order of the
+// configuration's sections, with the problems in maps (e.g. power) in no particular order.
+func (c *Config) Validate() []Problem {
+	v := &validator{}
+
+	if s := c.Setup.NetworkTimeServer; s != "" && strings.ContainsAny(s, " \t/") {
+		v.add("setup.network_time_server", "invalid host %q", s)
+	}
+
+	names := map[string]bool{}
+	for i, u := range c.Users {
+		path := fmt.Sprintf("users[%d]", i)
+		switch {
+		case !userNamePattern.MatchString(u.Name):
+			v.add(path+".name", "invalid user name %q, names are lower case letters, digits, _, ., and - of up to 32 characters", u.Name)
+		case names[u.Name]:
+			v.add(path+".name", "duplicate user %s", u.Name)
+		}
+		names[u.Name] = true
+		v.authorizedKeys(path+".authorized_keys", u.AuthorizedKeys)
+	}
+
+	for i, d := range c.Defaults {
+		path := fmt.Sprintf("defaults[%d]", i)
+		switch {
+		case d.Domain == "":
+			v.add(path, "domain is required")
+		case d.Key == "":
+			v.add(path, "key is required")
+		case d.Value == nil:
+			v.add(path+".value", "value is required")
+		default:
+			domain := defaults.Domain{Name: d.Domain, User: d.User, CurrentHost: d.CurrentHost}
+			if _, err := defaults.NewSetting(domain, d.Key, defaults.Type(d.Type), d.Value); err != nil {
+				v.add(path, "%s", err)
+			}
+		}
+	}
+
+	for i, app := range c.Firewall.AllowedApps {
+		if !filepath.IsAbs(app) {
+			v.add(fmt.Sprintf("firewall.allowed_apps[%d]", i), "path %q must be absolute", app)
+		}
+	}
+
+	n := c.Notifications
+	if n.WebhookURL != "" {
+		if u, err := url.Parse(n.WebhookURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			v.add("notifications.webhook_url", "webhook URL %q must be an https URL", n.WebhookURL)
+		}
+	}
+	if n.SNSTopicARN != "" && !strings.HasPrefix(n.SNSTopicARN, "arn:") {
+		v.add("notifications.sns_topic_arn", "invalid ARN %q", n.SNSTopicARN)
+	}
+	for i, op := range n.Operations {
+		switch events.Operation(op) {
+		case events.OperationGrow, events.OperationRepair, events.OperationResize, events.OperationProvision:
+		default:
+			v.add(fmt.Sprintf("notifications.operations[%d]", i), "unknown operation %q, expected grow, repair, resize, or provision", op)
+		}
+	}
+
+	for setting, value := range c.Power {
+		if strings.TrimSpace(value) == "" {
+			v.add("power."+setting, "value is required")
+		}
+	}
+
+	for iface, mtu := range c.Network.MTU {
+		if mtu <= 0 || mtu > maxMTU {
+			v.add("network.mtu."+iface, "MTU %d is out of range, expected 1-%d", mtu, maxMTU)
+		}
+	}
+	for service, dns := range c.Network.DNS {
+		for i, server := range dns.Servers {
+			if net.ParseIP(server) == nil {
+				v.add(fmt.Sprintf("network.dns.%s.servers[%d]", service, i), "invalid IP address %q", server)
+			}
+		}
+	}
+	for _, proxy := range []struct{ key, value string }{{"http", c.Network.Proxy.HTTP}, {"https", c.Network.Proxy.HTTPS}} {
+		if proxy.value == "" {
+			continue
+		}
+		if _, port, err := net.SplitHostPort(proxy.value); err != nil || port == "" {
+			v.add("network.proxy."+proxy.key, "proxy %q must be host:port", proxy.value)
+		}
+	}
+
+	if u := c.SSH.User; u != "" && !userNamePattern.MatchString(u) {
+		v.add("ssh.user", "invalid user name %q", u)
+	}
+	v.authorizedKeys("ssh.authorized_keys", c.SSH.AuthorizedKeys)
+	switch c.SSH.Server.PermitRootLogin {
+	case "", "yes", "no", "prohibit-password", "forced-commands-only":
+	default:
+		v.add("ssh.server.permit_root_login", "invalid value %q, expected yes, no, prohibit-password, or forced-commands-only", c.SSH.Server.PermitRootLogin)
+	}
+	if c.SSH.Server.ClientAliveInterval < 0 {
+		v.add("ssh.server.client_alive_interval", "interval can't be negative")
+	}
+	if c.SSH.Server.ClientAliveCountMax < 0 {
+		v.add("ssh.server.client_alive_count_max", "count can't be negative")
+	}
+	if m := c.SSH.Sync.Manifest; m != "" && !filepath.IsAbs(m) && !strings.HasPrefix(m, "s3://") {
+		v.add("ssh.sync.manifest", "manifest %q must be an absolute path or an S3 URI", m)
+	}
+
+	mountPoints := map[string]bool{}
+	for i, vol := range c.Volumes {
+		path := fmt.Sprintf("volumes[%d]", i)
+		if vol.ID == "" {
+			v.add(path, "id is required")
+		}
+		v.mountPoint(path+".mount_point", vol.MountPoint, mountPoints)
+		format := diskutil.FormatAPFS
+		if vol.Format != "" {
+			f, err := diskutil.ParseVolumeFormat(vol.Format)
+			if err != nil {
+				v.add(path+".format", "%s, expected APFS, JHFS+, ExFAT, or FAT32", err)
+				continue
+			}
+			format = f
+		}
+		if err := format.Validate(vol.Label, 0); err != nil {
+			v.add(path+".label", "%s", err)
+		}
+	}
+	for i, m := range c.Mounts {
+		path := fmt.Sprintf("mounts[%d]", i)
+		if m.Spec == "" {
+			v.add(path, "spec is required")
+		}
+		v.mountPoint(path+".mount_point", m.MountPoint, mountPoints)
+	}
+
+	return v.problems
+}
+
+// validator collects the problems found while validating a configuration.
+type validator struct {
+	problems []Problem
+}
+
+// add records a problem with the value at the path.
+func (v *validator) add(path string, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// authorizedKeys checks that each key is an OpenSSH public key (e.g. "ssh-ed25519 AAAA... name"), which may have
+// options.
+func (v *validator) authorizedKeys(path string, keys []string) {
+	for i, key := range keys {
+		if !isPublicKey(key) {
+			v.add(fmt.Sprintf("%s[%d]", path, i), "invalid OpenSSH public key %q", key)
+		}
+	}
+}
+
+// mountPoint checks that the mount point is an absolute path that isn't used by another volume or mount.
+func (v *validator) mountPoint(path string, mountPoint string, seen map[string]bool) {
+	switch {
+	case mountPoint == "":
+		v.add(path, "mount point is required")
+	case !filepath.IsAbs(mountPoint):
+		v.add(path, "mount point %q must be an absolute path", mountPoint)
+	case seen[filepath.Clean(mountPoint)]:
+		v.add(path, "mount point %s is used more than once", mountPoint)
+	}
+	seen[filepath.Clean(mountPoint)] = true
+}
+
+// isPublicKey checks if the line holds an OpenSSH key type (e.g. "ssh-ed25519" or "ecdsa-sha2-nistp256") followed by
+// the key.
+func isPublicKey(line string) bool {
+	fields := strings.Fields(line)
+	for i := 0; i < len(fields)-1; i++ {
+		for _, prefix := range []string{"ssh-", "ecdsa-", "sk-"} {
+			if strings.HasPrefix(fields[i], prefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// lineOf finds the line of the value at the path (e.g. "users[0].name") in the document. The line of the closest
+// enclosing value is used when the value isn't in the document (e.g. a missing required key).
+func lineOf(root *yaml.Node, path string) int {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return 0
+	}
+
+	node := root.Content[0]
+	line := node.Line
+	for _, seg := range splitPath(path) {
+		next := child(node, seg)
+		if next == nil {
+			break
+		}
+		node, line = next, next.Line
+	}
+
+	return line
+}
+
+// child finds the value of the key in a mapping, or of the index in a sequence.
+func child(node *yaml.Node, seg string) *yaml.Node {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == seg {
+				return node.Content[i+1]
+			}
+		}
+	case yaml.SequenceNode:
+		if i, err := strconv.Atoi(seg); err == nil && i >= 0 && i < len(node.Content) {
+			return node.Content[i]
+		}
+	}
+
+	return nil
+}
+
+// splitPath splits the path into its keys and indices (e.g. "users[0].name" into "users", "0", and "name").
+func splitPath(path string) []string {
+	var segs []string
+	for _, part := range strings.Split(path, ".") {
+		for part != "" {
+			i := strings.Index(part, "[")
+			if i < 0 {
+				segs = append(segs, part)
+				break
+			}
+			if i > 0 {
+				segs = append(segs, part[:i])
+			}
+			end := strings.Index(part, "]")
+			if end < i {
+				break
+			}
+			segs = append(segs, part[i+1:end])
+			part = part[end+1:]
+		}
+	}
+
+	return segs
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint_Valid(t *testing.T) {
+	problems := Lint([]byte(`
+setup:
+  timezone: auto
+  network_time_server: 169.254.169.123
+users:
+  - name: runner
+    authorized_keys: ['from="10.0.0.0/8" ssh-ed25519 AAAA ci']
+defaults:
+  - domain: com.apple.screensaver
+    key: idleTime
+    value: 0
+network:
+  mtu:
+    en0: 9001
+  proxy:
+    http: proxy.example.com:3128
+volumes:
+  - id: vol-0123456789abcdef0
+    mount_point: /Volumes/Data
+mounts:
+  - spec: UUID=0A81F3B1-51D9-3335-B3E3-169C3640360D
+    mount_point: /Volumes/Cache
+`))
+
+	assert.Empty(t, problems)
+}
+
+func TestLint_Values(t *testing.T) {
+	problems := Lint([]byte(`users:
+  - name: Runner
+  - name: ci
+    authorized_keys: [not a key]
+defaults:
+  - domain: com.apple.screensaver
+    key: idleTime
+    type: int
+    value: soon
+network:
+  proxy:
+    http: proxy.example.com
+ssh:
+  server:
+    permit_root_login: maybe
+volumes:
+  - id: disk4
+    mount_point: /Volumes/Data
+    format: ExFAT
+    label: WayTooLongForFAT
+mounts:
+  - spec: UUID=TEST
+    mount_point: /Volumes/Data
+`))
+
+	assert.Equal(t, []Problem{
+		{Line: 2, Path: "users[0].name", Message: `invalid user name "Runner", names are lower case letters, digits, _, ., and - of up to 32 characters`},
+		{Line: 4, Path: "users[1].authorized_keys[0]", Message: `invalid OpenSSH public key "not a key"`},
+		{Line: 6, Path: "defaults[0]", Message: problems[2].Message},
+		{Line: 12, Path: "network.proxy.http", Message: `proxy "proxy.example.com" must be host:port`},
+		{Line: 15, Path: "ssh.server.permit_root_login", Message: `invalid value "maybe", expected yes, no, prohibit-password, or forced-commands-only`},
+		{Line: 20, Path: "volumes[0].label", Message: problems[5].Message},
+		{Line: 23, Path: "mounts[0].mount_point", Message: "mount point /Volumes/Data is used more than once"},
+	}, problems)
+	assert.Contains(t, problems[2].Message, "soon")
+	assert.Contains(t, problems[5].Message, "maximum is 11")
+}
+
+func TestLint_DecodeErrors(t *testing.T) {
+	problems := Lint([]byte("setup:\n  remote_logn: true\nfirewall:\n  enabld: true\n"))
+
+	assert.Len(t, problems, 2, "every unknown key should be reported")
+	assert.Equal(t, 2, problems[0].Line)
+	assert.Contains(t, problems[0].Message, "remote_logn")
+	assert.Equal(t, 4, problems[1].Line)
+}
+
+func TestLint_SyntaxError(t *testing.T) {
+	problems := Lint([]byte("setup:\n  timezone: [auto\n"))
+
+	assert.Len(t, problems, 1)
+	assert.NotZero(t, problems[0].Line)
+}
+
+func TestProblem_String(t *testing.T) {
+	assert.Equal(t, "line 3, users[0].name: invalid user name", Problem{Line: 3, Path: "users[0].name", Message: "invalid user name"}.String())
+	assert.Equal(t, "cannot read file", Problem{Message: "cannot read file"}.String())
+}
+
+func TestLint_Templates(t *testing.T) {
+	problems := Lint([]byte(`network:
+
+  mtu:
+    en0: "{{.Tags.mtu}}"
+    en1: "{{.InstanceID}}"
+`))
+
+	assert.Len(t, problems, 1)
+	assert.Equal(t, 5, problems[0].Line, "lines should be those of the file")
+	assert.Contains(t, problems[0].Message, "into int")
+}
+
+func TestLint_InvalidTemplates(t *testing.T) {
+	problems := Lint([]byte(`volumes:
+  - id: vol-0123456789abcdef0
+    mount_point: /Volumes/data-{{.InstanceId}}
+    label: "{{.Tags.team"
+    persit: true
+`))
+
+	assert.Len(t, problems, 3)
+	assert.Equal(t, 3, problems[0].Line)
+	assert.Contains(t, problems[0].Message, "InstanceId")
+	assert.Equal(t, 4, problems[1].Line)
+	assert.Equal(t, 5, problems[2].Line)
+	assert.Contains(t, problems[2].Message, "persit")
+}
+
+func TestLint_TemplateValues(t *testing.T) {
+	problems := Lint([]byte("volumes:\n  - id: vol-0123456789abcdef0\n    mount_point: \"{{.Tags.path}}\"\n"))
+
+	assert.Equal(t, []Problem{
+		{Line: 3, Path: "volumes[0].mount_point", Message: "mount point is required"},
+	}, problems, "templates should be validated once they're rendered")
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// templateDelim starts an action in a Go template, values without it aren't rendered.
+const templateDelim = "{{"
+
+// Vars are the variables that templates in configuration values are rendered with, describing the instance that the
+// configuration is applied on (e.g. "/Volumes/data-{{.InstanceID}}" or "{{index .Tags \"team\"}}").
+type Vars struct {
+	InstanceID       string
+	InstanceType     string
+	ImageID          string
+	AccountID        string
+	Region           string
+	AvailabilityZone string
+	Architecture     string
+	PrivateIP        string
+	// Hostname is the system's host name.
+	Hostname string
+	// Tags are the instance's tags, which are empty when tags in metadata aren't enabled for the instance.
+	Tags map[string]string
+}
+
+// VarsFunc gets the variables that templates are rendered with. It's only called when the configuration has
+// templates so that instances' metadata is only read when it's needed.
+type VarsFunc func() (*Vars, error)
+
+// sampleVars are the variables that templates are rendered with when linting, so that the rendered values are
+// validated without reading the metadata of the instance.
+var sampleVars = &Vars{
+	InstanceID:       "i-0123456789abcdef0",
+	InstanceType:     "mac2.metal",
+	ImageID:          "ami-0123456789abcdef0",
+	AccountID:        "123456789012",
+	Region:           "us-east-1",
+	AvailabilityZone: "us-east-1a",
+	Architecture:     "arm64_mac",
+	PrivateIP:        "10.0.0.1",
+	Hostname:         "ip-10-0-0-1.ec2.internal",
+	Tags:             map[string]string{},
+}
+
+// DecodeTemplate reads the configuration from the reader like Decode, rendering the Go templates in its values with
+// the variables first. Templates that refer to missing variables or tags are an error rather than rendering empty
+// values.
+func DecodeTemplate(r io.Reader, vars VarsFunc) (*Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var root yaml.Node
+	if err = yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("error decoding config: %w", err)
+	}
+	nodes := templateNodes(&root)
+	if len(nodes) == 0 {
+		return Decode(bytes.NewReader(data))
+	}
+
+	if vars == nil {
+		return nil, errors.New("error rendering config: templates aren't supported")
+	}
+	v, err := vars()
+	if err != nil {
+		return nil, fmt.Errorf("error rendering config: cannot get template variables: %w", err)
+	}
+	if err = render(nodes, v, "missingkey=error"); err != nil {
+		return nil, fmt.Errorf("error rendering config: %w", err)
+	}
+
+	// The rendered document is encoded again so that it's decoded with the same checks as files without templates.
+	rendered, err := yaml.Marshal(&root)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering config: %w", err)
+	}
+
+	return Decode(bytes.NewReader(rendered))
+}
+
+// templateNodes finds the scalars in the document that have templates.
+func templateNodes(node *yaml.Node) []*yaml.Node {
+	if node.Kind == yaml.ScalarNode {
+		if strings.Contains(node.Value, templateDelim) {
+			return []*yaml.Node{node}
+		}
+		return nil
+	}
+
+	var nodes []*yaml.Node
+	for _, n := range node.Content {
+		nodes = append(nodes, templateNodes(n)...)
+	}
+
+	return nodes
+}
+
+// render replaces each scalar's template with its rendered value. The scalars' tags and styles are cleared so that
+// rendered values are resolved like values written without templates (e.g. "{{.Tags.mtu}}" decoding as a number).
+func render(nodes []*yaml.Node, vars *Vars, option string) error {
+	for _, n := range nodes {
+		tmpl, err := template.New("").Option(option).Parse(n.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid template: %w", n.Line, err)
+		}
+
+		var b strings.Builder
+		if err = tmpl.Execute(&b, vars); err != nil {
+			return fmt.Errorf("line %d: cannot render template: %w", n.Line, err)
+		}
+		n.Value, n.Tag, n.Style = b.String(), "", 0
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testVars() (*Vars, error) {
+	return &Vars{
+		InstanceID: "i-0123456789abcdef0",
+		Region:     "us-west-2",
+		Hostname:   "mac-1",
+		Tags:       map[string]string{"team": "ci", "mtu": "9001"},
+	}, nil
+}
+
+func TestDecodeTemplate(t *testing.T) {
+	c, err := DecodeTemplate(strings.NewReader(`
+setup:
+  network_time_server: "{{.Region}}.pool.example.com"
+network:
+  mtu:
+    en0: "{{.Tags.mtu}}"
+volumes:
+  - id: vol-0123456789abcdef0
+    mount_point: /Volumes/data-{{.InstanceID}}
+    label: "{{index .Tags \"team\" | printf \"%.4s\"}}"
+`), testVars)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "us-west-2.pool.example.com", c.Setup.NetworkTimeServer)
+	assert.Equal(t, map[string]int{"en0": 9001}, c.Network.MTU, "rendered values should decode as their type")
+	assert.Equal(t, "/Volumes/data-i-0123456789abcdef0", c.Volumes[0].MountPoint)
+	assert.Equal(t, "ci", c.Volumes[0].Label)
+}
+
+func TestDecodeTemplate_NoTemplates(t *testing.T) {
+	c, err := DecodeTemplate(strings.NewReader("setup:\n  timezone: auto\n"), func() (*Vars, error) {
+		t.Fatal("variables shouldn't be read without templates")
+		return nil, nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "auto", c.Setup.Timezone)
+}
+
+func TestDecodeTemplate_MissingTag(t *testing.T) {
+	_, err := DecodeTemplate(strings.NewReader("setup:\n  timezone: \"{{.Tags.timezone}}\"\n"), testVars)
+
+	assert.Error(t, err, "missing tags shouldn't render as empty values")
+	assert.Contains(t, err.Error(), "line 2")
+}
+
+func TestDecodeTemplate_VarsError(t *testing.T) {
+	_, err := DecodeTemplate(strings.NewReader("setup:\n  timezone: \"{{.Region}}\"\n"), func() (*Vars, error) {
+		return nil, errors.New("no metadata")
+	})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no metadata")
+}
+
+func TestDecodeTemplate_UnknownKey(t *testing.T) {
+	_, err := DecodeTemplate(strings.NewReader("setup:\n  timezne: \"{{.Region}}\"\n"), testVars)
+
+	assert.Error(t, err, "unknown keys should be rejected in rendered configs")
+}
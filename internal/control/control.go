@@ -0,0 +1,136 @@
+// Package control provides the functionality necessary for serving the utility's disk operations over a Unix domain
+// socket so that other agents on the host (e.g. CI runners or MDM agents) can drive them without running the CLI.
+//
+// The API is JSON-RPC 1.0, as implemented by net/rpc/jsonrpc: each request is a JSON object with the method (e.g.
+// "Disk.List"), its params as a single element array, and an ID which the response echoes along with the result or
+// error. The socket is only accessible by root since the operations modify disks.
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+)
+
+const (
+	// DefaultSocketPath is the default path of the control socket.
+	DefaultSocketPath = "/var/run/ec2-macos-utils.sock"
+	// ServiceName is the name that the methods are served under (e.g. "Disk.Grow").
+	ServiceName = "Disk"
+)
+
+// ListArgs are the arguments of Disk.List.
+type ListArgs struct {
+	// Args are additional arguments for diskutil's list verb (e.g. "internal").
+	Args []string `json:"args,omitempty"`
+}
+
+// InfoArgs are the arguments of Disk.Info.
+type InfoArgs struct {
+	// ID is the device identifier, device node, or mount point of the disk.
+	ID string `json:"id"`
+}
+
+// GrowArgs are the arguments of Disk.Grow.
+type GrowArgs struct {
+	// ID is the device identifier of the APFS container to grow, or "root" for the boot volume's container.
+	ID string `json:"id"`
+	// DryRun runs the operation without mutating changes.
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// GrowReply is the result of Disk.Grow.
+type GrowReply struct {
+	// Grown indicates that the container was resized, it's false when there was no free space to grow into.
+	Grown bool `json:"grown"`
+	// Disk is the updated information for the container, which isn't set in dry-run mode.
+	Disk *types.DiskInfo `json:"disk,omitempty"`
+}
+
+// ProvisionArgs are the arguments of Disk.Provision.
+type ProvisionArgs struct {
+	// ID is the device identifier of the disk, or the ID of the EBS volume attached to it.
+	ID string `json:"id"`
+	// Format is the filesystem format of the volume (e.g. "APFS"), APFS is used when it's empty.
+	Format string `json:"format,omitempty"`
+	// Label is the name of the volume, "Data" is used when it's empty.
+	Label string `json:"label,omitempty"`
+	// MountPoint is the absolute path to mount the volume at.
+	MountPoint string `json:"mount_point"`
+	// Persist persists the mount in /etc/fstab.
+	Persist bool `json:"persist,omitempty"`
+	// DryRun runs the operation without mutating changes.
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// StatusArgs are the arguments of Disk.Status, which has none.
+type StatusArgs struct{}
+
+// Serve serves the receiver's methods, as described by net/rpc, under ServiceName on the Unix domain socket at path
+// until ctx is done. A stale socket left at path is replaced, but other files are never removed.
+func Serve(ctx context.Context, path string, rcvr interface{}) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName(ServiceName, rcvr); err != nil {
+		return fmt.Errorf("control: cannot register service: %w", err)
+	}
+
+	if err := removeStaleSocket(path); err != nil {
+		return err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("control: cannot listen on %s: %w", path, err)
+	}
+	defer os.Remove(path)
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return fmt.Errorf("control: cannot restrict access to %s: %w", path, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("control: cannot accept connection: %w", err)
+		}
+
+		logrus.Debug("Accepted control connection")
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// removeStaleSocket removes the socket at path when it was left by a server which didn't shut down cleanly.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("control: %s exists and isn't a socket", path)
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("control: %s is in use by another server", path)
+	}
+
+	return os.Remove(path)
+}
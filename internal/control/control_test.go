@@ -0,0 +1,90 @@
+package control
+
+import (
+	"context"
+	"net"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// echoService is a service which replies with its arguments.
+type echoService struct{}
+
+func (echoService) Info(args *InfoArgs, reply *InfoArgs) error {
+	*reply = *args
+	return nil
+}
+
+// startServer serves the receiver on the socket at path until the test finishes.
+func startServer(t *testing.T, path string, rcvr interface{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Serve(ctx, path, rcvr) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+		_, err := os.Stat(path)
+		assert.True(t, os.IsNotExist(err), "socket should be removed when the server stops")
+	})
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return
+		}
+	}
+	t.Fatal("server didn't start")
+}
+
+func TestServe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+	startServer(t, path, echoService{})
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	conn, err := net.Dial("unix", path)
+	assert.NoError(t, err)
+	client := jsonrpc.NewClient(conn)
+	defer client.Close()
+
+	var reply InfoArgs
+	assert.NoError(t, client.Call(ServiceName+".Info", &InfoArgs{ID: "disk0"}, &reply))
+	assert.Equal(t, "disk0", reply.ID)
+}
+
+func TestServe_InUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+	startServer(t, path, echoService{})
+
+	err := Serve(context.Background(), path, echoService{})
+
+	assert.EqualError(t, err, "control: "+path+" is in use by another server")
+}
+
+func TestServe_NotASocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+	assert.NoError(t, os.WriteFile(path, nil, 0644))
+
+	err := Serve(context.Background(), path, echoService{})
+
+	assert.EqualError(t, err, "control: "+path+" exists and isn't a socket")
+	_, err = os.Stat(path)
+	assert.NoError(t, err, "files that aren't sockets should never be removed")
+}
+
+func TestServe_StaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+	l, err := net.Listen("unix", path)
+	assert.NoError(t, err)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	startServer(t, path, echoService{})
+}
@@ -0,0 +1,178 @@
+// Package defaults provides typed access to macOS preference domains with the defaults(1) CLI.
+package defaults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"howett.net/plist"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// ErrNotFound is returned when reading a key that isn't set in the domain.
+var ErrNotFound = errors.New("defaults: key not found")
+
+// Well-known preference domains. Domains given as absolute paths (without the .plist extension) apply to all users
+// while other domains apply to the user that defaults runs as.
+const (
+	// LoginWindowDomain configures the login window for all users.
+	LoginWindowDomain = "/Library/Preferences/com.apple.loginwindow"
+	// SoftwareUpdateDomain configures softwareupdate's automatic checks and installs.
+	SoftwareUpdateDomain = "/Library/Preferences/com.apple.SoftwareUpdate"
+	// ScreenSaverDomain configures the screen saver of the user.
+	ScreenSaverDomain = "com.apple.screensaver"
+	// FinderDomain configures the Finder of the user.
+	FinderDomain = "com.apple.finder"
+)
+
+// Domain is a preference domain read and written with defaults.
+type Domain struct {
+	// Name is the domain's identifier (e.g. "com.apple.finder") or the path to its plist without the extension.
+	Name string
+	// User is the user that defaults runs as, which selects the user's preferences for domains given by identifier.
+	// The preferences of the user running the utility are used when empty.
+	User string
+	// CurrentHost restricts the domain to the preferences of the current host (i.e. ByHost preferences).
+	CurrentHost bool
+}
+
+// NewDomain creates a new Domain with the given name for the user running the utility.
+func NewDomain(name string) Domain {
+	return Domain{Name: name}
+}
+
+// command builds the defaults command for the operation on the domain.
+func (d Domain) command(op string, args ...string) []string {
+	// c represents the command used for executing macOS's defaults.
+	//   * -currentHost - restrict the operation to the current host's preferences (optional)
+	//   * op - the operation to be performed (e.g. read, write, delete)
+	//   * domain - the domain to be operated on
+	//   * args - the key and, for writes, the typed value
+	c := []string{"defaults"}
+	if d.CurrentHost {
+		c = append(c, "-currentHost")
+	}
+	c = append(c, op, d.Name)
+
+	return append(c, args...)
+}
+
+// run executes the defaults operation on the domain and returns its output.
+func (d Domain) run(ctx context.Context, op string, args ...string) (string, error) {
+	out, err := util.ExecuteCommand(ctx, d.command(op, args...), d.User, nil, nil)
+	if err != nil {
+		// defaults reports missing keys with "does not exist" and missing domains with "not found".
+		if strings.Contains(out.Stderr, "does not exist") || strings.Contains(out.Stderr, "not found") {
+			return "", fmt.Errorf("%s %v: %w", d.Name, args, ErrNotFound)
+		}
+		return "", fmt.Errorf("defaults: failed to %s %s, stderr: [%s]: %w", op, d.Name, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return strings.TrimSpace(out.Stdout), nil
+}
+
+// ReadString reads the string value of the key.
+func (d Domain) ReadString(ctx context.Context, key string) (string, error) {
+	return d.run(ctx, "read", key)
+}
+
+// ReadBool reads the boolean value of the key.
+func (d Domain) ReadBool(ctx context.Context, key string) (bool, error) {
+	value, err := d.ReadString(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	return parseBool(value)
+}
+
+// ReadInt reads the integer value of the key.
+func (d Domain) ReadInt(ctx context.Context, key string) (int, error) {
+	value, err := d.ReadString(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("defaults: %s %s is not an integer: %w", d.Name, key, err)
+	}
+
+	return i, nil
+}
+
+// ReadDict reads the dictionary value of the key. defaults prints dictionaries in the untyped OpenStep format so
+// the dictionary's values are strings, arrays, or nested dictionaries.
+func (d Domain) ReadDict(ctx context.Context, key string) (map[string]interface{}, error) {
+	value, err := d.ReadString(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	dict := map[string]interface{}{}
+	if _, err := plist.Unmarshal([]byte(value), &dict); err != nil {
+		return nil, fmt.Errorf("defaults: %s %s is not a dictionary: %w", d.Name, key, err)
+	}
+
+	return dict, nil
+}
+
+// WriteString writes the string value to the key.
+func (d Domain) WriteString(ctx context.Context, key string, value string) error {
+	return d.write(ctx, key, "-string", value)
+}
+
+// WriteBool writes the boolean value to the key.
+func (d Domain) WriteBool(ctx context.Context, key string, value bool) error {
+	return d.write(ctx, key, "-bool", strconv.FormatBool(value))
+}
+
+// WriteInt writes the integer value to the key.
+func (d Domain) WriteInt(ctx context.Context, key string, value int) error {
+	return d.write(ctx, key, "-int", strconv.Itoa(value))
+}
+
+// WriteDict replaces the key's value with the dictionary. The dictionary is written as a property list so that its
+// values keep their types.
+func (d Domain) WriteDict(ctx context.Context, key string, value map[string]interface{}) error {
+	data, err := plist.Marshal(value, plist.XMLFormat)
+	if err != nil {
+		return fmt.Errorf("defaults: cannot encode dictionary for %s: %w", key, err)
+	}
+
+	return d.write(ctx, key, string(data))
+}
+
+// write writes the typed value to the key.
+func (d Domain) write(ctx context.Context, key string, value ...string) error {
+	_, err := d.run(ctx, "write", append([]string{key}, value...)...)
+
+	return err
+}
+
+// Delete removes the key from the domain. Deleting a key that isn't set isn't an error.
+func (d Domain) Delete(ctx context.Context, key string) error {
+	_, err := d.run(ctx, "delete", key)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// parseBool parses the values defaults prints for booleans. Booleans are printed as 1 or 0 but values written as
+// strings (e.g. "YES" or "true") are accepted as well.
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "1", "yes", "true":
+		return true, nil
+	case "0", "no", "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("defaults: %q is not a boolean", value)
+	}
+}
@@ -0,0 +1,41 @@
+package defaults
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDomain_Command(t *testing.T) {
+	d := NewDomain(FinderDomain)
+	assert.Equal(t, []string{"defaults", "read", "com.apple.finder", "ShowPathbar"}, d.command("read", "ShowPathbar"))
+
+	d.CurrentHost = true
+	assert.Equal(t, []string{"defaults", "-currentHost", "write", "com.apple.finder", "ShowPathbar", "-bool", "true"}, d.command("write", "ShowPathbar", "-bool", "true"))
+}
+
+func TestParseBool(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    bool
+		wantErr bool
+	}{
+		{input: "1", want: true},
+		{input: "0", want: false},
+		{input: "YES", want: true},
+		{input: "false", want: false},
+		{input: "maybe", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseBool(tt.input)
+
+			assert.Equal(t, tt.want, got)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package defaults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"howett.net/plist"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+// Type is the type of a preference's value.
+type Type string
+
+const (
+	// TypeBool is a boolean value.
+	TypeBool Type = "bool"
+	// TypeInt is an integer value.
+	TypeInt Type = "int"
+	// TypeString is a string value.
+	TypeString Type = "string"
+	// TypeDict is a dictionary value.
+	TypeDict Type = "dict"
+)
+
+// Setting is the desired value of a key in a preference domain.
+type Setting struct {
+	// Domain is the preference domain of the key.
+	Domain Domain
+	// Key is the name of the preference.
+	Key string
+	// Type is the type of the preference's value.
+	Type Type
+	// Value is the desired value, which is a bool, int, string, or map[string]interface{} matching Type.
+	Value interface{}
+}
+
+// NewSetting creates a new Setting after converting the value to the type. The type is inferred from the value when
+// empty. Values are converted from strings where possible (e.g. "YES" for a bool) since configuration files aren't
+// always precise about their types.
+func NewSetting(domain Domain, key string, t Type, value interface{}) (Setting, error) {
+	if domain.Name == "" || key == "" {
+		return Setting{}, errors.New("defaults: setting requires a domain and key")
+	}
+	if t == "" {
+		t = inferType(value)
+	}
+
+	converted, err := convert(t, value)
+	if err != nil {
+		return Setting{}, fmt.Errorf("defaults: invalid value for %s %s: %w", domain.Name, key, err)
+	}
+
+	return Setting{Domain: domain, Key: key, Type: t, Value: converted}, nil
+}
+
+// String identifies the setting by its domain and key.
+func (s Setting) String() string {
+	name := s.Domain.Name + " " + s.Key
+	if s.Domain.CurrentHost {
+		name = "-currentHost " + name
+	}
+	if s.Domain.User != "" {
+		name = s.Domain.User + ": " + name
+	}
+
+	return name
+}
+
+// Read reads the key's current value in the canonical form used to compare it with the desired value. An empty
+// string is returned when the key isn't set.
+func (s Setting) Read(ctx context.Context) (string, error) {
+	var value interface{}
+	var err error
+	switch s.Type {
+	case TypeBool:
+		value, err = s.Domain.ReadBool(ctx, s.Key)
+	case TypeInt:
+		value, err = s.Domain.ReadInt(ctx, s.Key)
+	case TypeString:
+		value, err = s.Domain.ReadString(ctx, s.Key)
+	case TypeDict:
+		value, err = s.Domain.ReadDict(ctx, s.Key)
+	default:
+		return "", fmt.Errorf("defaults: unsupported type %q", s.Type)
+	}
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return canonical(value)
+}
+
+// Write writes the desired value to the key.
+func (s Setting) Write(ctx context.Context) error {
+	switch v := s.Value.(type) {
+	case bool:
+		return s.Domain.WriteBool(ctx, s.Key, v)
+	case int:
+		return s.Domain.WriteInt(ctx, s.Key, v)
+	case string:
+		return s.Domain.WriteString(ctx, s.Key, v)
+	case map[string]interface{}:
+		return s.Domain.WriteDict(ctx, s.Key, v)
+	default:
+		return fmt.Errorf("defaults: unsupported value %T", s.Value)
+	}
+}
+
+// Task applies the desired preference settings.
+type Task struct {
+	// Settings are the preferences to be applied.
+	Settings []Setting
+}
+
+// Name identifies the task.
+func (t *Task) Name() string {
+	return "defaults"
+}
+
+// Check compares the current preferences with the desired settings.
+func (t *Task) Check(ctx context.Context) ([]task.Change, error) {
+	changes, _, err := t.check(ctx)
+
+	return changes, err
+}
+
+// Apply writes the settings that differ from the current preferences.
+func (t *Task) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, settings, err := t.check(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range settings {
+		if err := s.Write(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
+
+// check compares the current preferences with the desired settings and returns the changes along with the
+// settings that need to be written.
+func (t *Task) check(ctx context.Context) ([]task.Change, []Setting, error) {
+	var changes []task.Change
+	var settings []Setting
+	for _, s := range t.Settings {
+		current, err := s.Read(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		desired, err := canonical(s.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		if current == desired {
+			continue
+		}
+		changes = append(changes, task.Change{Setting: s.String(), Current: current, Desired: desired})
+		settings = append(settings, s)
+	}
+
+	return changes, settings, nil
+}
+
+// inferType determines the type of the value.
+func inferType(value interface{}) Type {
+	switch value.(type) {
+	case bool:
+		return TypeBool
+	case int:
+		return TypeInt
+	case map[string]interface{}:
+		return TypeDict
+	default:
+		return TypeString
+	}
+}
+
+// convert converts the value to the Go type matching t.
+func convert(t Type, value interface{}) (interface{}, error) {
+	switch t {
+	case TypeBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return parseBool(v)
+		}
+	case TypeInt:
+		switch v := value.(type) {
+		case int:
+			return v, nil
+		case string:
+			return strconv.Atoi(v)
+		}
+	case TypeString:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case bool, int:
+			return fmt.Sprint(v), nil
+		}
+	case TypeDict:
+		if v, ok := value.(map[string]interface{}); ok {
+			return v, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %q", t)
+	}
+
+	return nil, fmt.Errorf("cannot use %T as %s", value, t)
+}
+
+// canonical formats the value so that values read from defaults and desired values can be compared. Booleans are
+// formatted as 1 or 0 to match how defaults prints them and dictionaries are formatted in the untyped OpenStep
+// format with sorted keys, once their leaves are formatted the same way.
+func canonical(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case map[string]interface{}:
+		data, err := plist.Marshal(untyped(v), plist.OpenStepFormat)
+		if err != nil {
+			return "", fmt.Errorf("defaults: cannot encode dictionary: %w", err)
+		}
+		return string(data), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// untyped formats the leaves of the dictionary or array as canonical strings, since the dictionaries read back from
+// defaults' OpenStep output only hold strings.
+func untyped(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		dict := make(map[string]interface{}, len(v))
+		for key, e := range v {
+			dict[key] = untyped(e)
+		}
+		return dict
+	case []interface{}:
+		array := make([]interface{}, len(v))
+		for i, e := range v {
+			array[i] = untyped(e)
+		}
+		return array
+	default:
+		s, _ := canonical(v)
+		return s
+	}
+}
+
+// UnattendedSessionSettings are the preferences that keep the user's GUI session unlocked while it's idle, which UI
+// test automation needs since it can't interact with a screen saver or a locked screen. The screen saver is
+// disabled and waking from it (or from display sleep) no longer asks for the user's password.
+func UnattendedSessionSettings(user string) []Setting {
+	byHost := Domain{Name: ScreenSaverDomain, User: user, CurrentHost: true}
+	domain := Domain{Name: ScreenSaverDomain, User: user}
+
+	return []Setting{
+		// idleTime is the idle time, in seconds, before the screen saver starts. 0 disables the screen saver.
+		{Domain: byHost, Key: "idleTime", Type: TypeInt, Value: 0},
+		// askForPassword locks the session when the screen saver starts or the display sleeps.
+		{Domain: domain, Key: "askForPassword", Type: TypeInt, Value: 0},
+		// askForPasswordDelay is the grace period, in seconds, before the session is locked.
+		{Domain: domain, Key: "askForPasswordDelay", Type: TypeInt, Value: 0},
+	}
+}
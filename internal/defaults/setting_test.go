@@ -0,0 +1,108 @@
+package defaults
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"howett.net/plist"
+)
+
+func TestNewSetting(t *testing.T) {
+	d := NewDomain(ScreenSaverDomain)
+
+	tests := []struct {
+		name     string
+		t        Type
+		value    interface{}
+		wantType Type
+		want     interface{}
+		wantErr  bool
+	}{
+		{name: "inferred bool", value: true, wantType: TypeBool, want: true},
+		{name: "inferred int", value: 0, wantType: TypeInt, want: 0},
+		{name: "inferred dict", value: map[string]interface{}{"a": "b"}, wantType: TypeDict, want: map[string]interface{}{"a": "b"}},
+		{name: "bool from string", t: TypeBool, value: "YES", wantType: TypeBool, want: true},
+		{name: "int from string", t: TypeInt, value: "300", wantType: TypeInt, want: 300},
+		{name: "string from int", t: TypeString, value: 300, wantType: TypeString, want: "300"},
+		{name: "invalid int", t: TypeInt, value: "soon", wantErr: true},
+		{name: "invalid dict", t: TypeDict, value: "a=b", wantErr: true},
+		{name: "unsupported type", t: "date", value: "now", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSetting(d, "idleTime", tt.t, tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantType, s.Type)
+			assert.Equal(t, tt.want, s.Value)
+		})
+	}
+}
+
+func TestNewSetting_WithoutKey(t *testing.T) {
+	_, err := NewSetting(NewDomain(FinderDomain), "", TypeBool, true)
+
+	assert.Error(t, err)
+}
+
+func TestSetting_String(t *testing.T) {
+	s := Setting{Domain: Domain{Name: ScreenSaverDomain, User: "ec2-user", CurrentHost: true}, Key: "idleTime"}
+
+	assert.Equal(t, "ec2-user: -currentHost com.apple.screensaver idleTime", s.String())
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{name: "true", value: true, want: "1"},
+		{name: "false", value: false, want: "0"},
+		{name: "int", value: 300, want: "300"},
+		{name: "string", value: "Finder", want: "Finder"},
+		{name: "typed dict", value: map[string]interface{}{"b": 1, "a": true}, want: "{a=1;b=1;}"},
+		{name: "untyped dict", value: map[string]interface{}{"b": "1", "a": "1"}, want: "{a=1;b=1;}"},
+		{name: "nested dict", value: map[string]interface{}{"outer": map[string]interface{}{"enabled": true, "delay": 5, "ratio": 1.5}, "list": []interface{}{false, "x y"}}, want: `{list=(0,"x y",);outer={delay=5;enabled=1;ratio="1.5";};}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := canonical(tt.value)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCanonical_ReadDict(t *testing.T) {
+	// defaults prints dictionaries in the OpenStep format, whose values are all read back as strings.
+	read := map[string]interface{}{}
+	_, err := plist.Unmarshal([]byte("{\n    outer =     {\n        delay = 5;\n        enabled = 1;\n        ratio = \"1.5\";\n    };\n}"), &read)
+	assert.NoError(t, err)
+	desired := map[string]interface{}{"outer": map[string]interface{}{"enabled": true, "delay": 5, "ratio": 1.5}}
+
+	current, err := canonical(read)
+	assert.NoError(t, err)
+	want, err := canonical(desired)
+	assert.NoError(t, err)
+	assert.Equal(t, want, current, "nested booleans and numbers should match the values read back")
+}
+
+func TestUnattendedSessionSettings(t *testing.T) {
+	settings := UnattendedSessionSettings("ec2-user")
+
+	var names []string
+	for _, s := range settings {
+		names = append(names, s.String())
+	}
+	assert.Equal(t, []string{
+		"ec2-user: -currentHost com.apple.screensaver idleTime",
+		"ec2-user: com.apple.screensaver askForPassword",
+		"ec2-user: com.apple.screensaver askForPasswordDelay",
+	}, names)
+}
@@ -0,0 +1,277 @@
+// Package devtools provides the functionality necessary for detecting, installing, and selecting Apple's developer
+// tools (Xcode and the Command Line Tools) with macOS's xcode-select, pkgutil, and softwareupdate CLIs.
+package devtools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"howett.net/plist"
+
+	"github.com/aws/ec2-macos-utils/internal/shellprofile"
+	"github.com/aws/ec2-macos-utils/internal/softwareupdate"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// CommandLineToolsPath is where the Command Line Tools are installed.
+	CommandLineToolsPath = "/Library/Developer/CommandLineTools"
+	// ApplicationsDir is the directory searched for Xcode installations.
+	ApplicationsDir = "/Applications"
+
+	// cltPackage is the receipt of the package that installs the Command Line Tools' executables.
+	cltPackage = "com.apple.pkg.CLTools_Executables"
+	// cltOnDemandPath is the placeholder that makes softwareupdate list the Command Line Tools, which it otherwise
+	// only offers once they've been requested interactively (e.g. by running git).
+	cltOnDemandPath = "/tmp/.com.apple.dt.CommandLineTools.installondemand.in-progress"
+	// cltTitle is the title of the Command Line Tools updates listed by softwareupdate.
+	cltTitle = "Command Line Tools"
+	// PathsFile is the file in /etc/paths.d that adds the developer directory's tools to PATH.
+	PathsFile = "ec2-macos-utils-devtools"
+)
+
+// ErrNotInstalled is returned when the developer tools aren't installed.
+var ErrNotInstalled = errors.New("devtools: not installed")
+
+// Xcode is an installation of Xcode.
+type Xcode struct {
+	// Path is the path to the application.
+	Path string `json:"path"`
+	// Version is the version of Xcode (e.g. "15.0.1").
+	Version string `json:"version"`
+}
+
+// Status is the state of the developer tools on the system.
+type Status struct {
+	// DeveloperDir is the active developer directory selected with xcode-select, which is empty when none is
+	// selected.
+	DeveloperDir string `json:"developer_dir"`
+	// CommandLineTools indicates that the Command Line Tools are installed.
+	CommandLineTools bool `json:"command_line_tools"`
+	// CommandLineToolsVersion is the version of the installed Command Line Tools.
+	CommandLineToolsVersion string `json:"command_line_tools_version,omitempty"`
+	// Xcodes are the installations of Xcode in ApplicationsDir.
+	Xcodes []Xcode `json:"xcodes"`
+}
+
+// GetStatus detects the developer tools installed on the system.
+func GetStatus(ctx context.Context) (*Status, error) {
+	status := &Status{}
+
+	dir, err := DeveloperDir(ctx)
+	if err != nil && !errors.Is(err, ErrNotInstalled) {
+		return nil, err
+	}
+	status.DeveloperDir = dir
+
+	version, err := CommandLineToolsVersion(ctx)
+	if err != nil && !errors.Is(err, ErrNotInstalled) {
+		return nil, err
+	}
+	status.CommandLineTools = version != ""
+	status.CommandLineToolsVersion = version
+
+	if status.Xcodes, err = FindXcodes(ApplicationsDir); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// DeveloperDir gets the active developer directory. ErrNotInstalled is returned when none is selected, which is the
+// case until Xcode or the Command Line Tools are installed.
+func DeveloperDir(ctx context.Context) (string, error) {
+	// cmdPrintPath represents the command used for executing macOS's xcode-select to get the developer directory.
+	//   * -p - print the path of the active developer directory
+	cmdPrintPath := []string{"xcode-select", "-p"}
+
+	out, err := util.ExecuteCommand(ctx, cmdPrintPath, "", nil, nil)
+	if err != nil {
+		// xcode-select exits with status 2 when there's no developer directory
+		if strings.Contains(out.Stderr, "unable to get active developer directory") {
+			return "", ErrNotInstalled
+		}
+		return "", fmt.Errorf("devtools: failed to get developer directory, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	dir := strings.TrimSpace(out.Stdout)
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		// The selected directory was removed (e.g. Xcode was deleted)
+		return "", ErrNotInstalled
+	}
+
+	return dir, nil
+}
+
+// CommandLineToolsVersion gets the version of the installed Command Line Tools. ErrNotInstalled is returned when
+// they aren't installed.
+func CommandLineToolsVersion(ctx context.Context) (string, error) {
+	// cmdPkgInfo represents the command used for executing macOS's pkgutil to get the Command Line Tools' receipt.
+	//   * --pkg-info - print the receipt of the following package
+	//   * cltPackage - the package that installs the Command Line Tools' executables
+	cmdPkgInfo := []string{"pkgutil", "--pkg-info", cltPackage}
+
+	out, err := util.ExecuteCommand(ctx, cmdPkgInfo, "", nil, nil)
+	if err != nil {
+		if strings.Contains(out.Stderr, "No receipt") {
+			return "", ErrNotInstalled
+		}
+		return "", fmt.Errorf("devtools: failed to get Command Line Tools receipt, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+	if _, err := os.Stat(CommandLineToolsPath); errors.Is(err, os.ErrNotExist) {
+		// The receipt is left behind when the tools are removed by deleting their directory
+		return "", ErrNotInstalled
+	}
+
+	return parsePkgVersion(out.Stdout)
+}
+
+// parsePkgVersion parses the version from the output of pkgutil --pkg-info.
+func parsePkgVersion(out string) (string, error) {
+	for _, line := range strings.Split(out, "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) == "version" {
+			return strings.TrimSpace(value), nil
+		}
+	}
+
+	return "", fmt.Errorf("devtools: no version in package receipt")
+}
+
+// FindXcodes finds the installations of Xcode in the directory (e.g. "Xcode.app" or "Xcode_15.0.app"), ordered by
+// their paths.
+func FindXcodes(dir string) ([]Xcode, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "Xcode*.app"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	xcodes := []Xcode{}
+	for _, path := range paths {
+		version, err := bundleVersion(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		xcodes = append(xcodes, Xcode{Path: path, Version: version})
+	}
+
+	return xcodes, nil
+}
+
+// bundleVersion reads the version of the application bundle at path from its Info.plist.
+func bundleVersion(path string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(path, "Contents", "Info.plist"))
+	if err != nil {
+		return "", err
+	}
+
+	var info struct {
+		Version string `plist:"CFBundleShortVersionString"`
+	}
+	if _, err := plist.Unmarshal(data, &info); err != nil {
+		return "", fmt.Errorf("devtools: invalid Info.plist in %s: %w", path, err)
+	}
+
+	return info.Version, nil
+}
+
+// FindCommandLineTools finds the Command Line Tools offered by softwareupdate for the product and returns the
+// newest one. The placeholder that makes softwareupdate offer them is only present while searching.
+func FindCommandLineTools(ctx context.Context, p *system.Product) (*softwareupdate.Update, error) {
+	f, err := os.Create(cltOnDemandPath)
+	if err != nil {
+		return nil, fmt.Errorf("devtools: failed to request Command Line Tools: %w", err)
+	}
+	f.Close()
+	defer os.Remove(cltOnDemandPath)
+
+	updates, err := softwareupdate.List(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	update, ok := LatestCommandLineTools(updates)
+	if !ok {
+		return nil, fmt.Errorf("devtools: softwareupdate doesn't offer the Command Line Tools")
+	}
+
+	return update, nil
+}
+
+// InstallCommandLineTools installs the Command Line Tools update (see FindCommandLineTools) without prompting.
+func InstallCommandLineTools(ctx context.Context, update *softwareupdate.Update) error {
+	f, err := os.Create(cltOnDemandPath)
+	if err != nil {
+		return fmt.Errorf("devtools: failed to request Command Line Tools: %w", err)
+	}
+	f.Close()
+	defer os.Remove(cltOnDemandPath)
+
+	if _, err := softwareupdate.Install(ctx, []string{update.Label}, false); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// LatestCommandLineTools finds the newest Command Line Tools in the updates, which list one for each Xcode release
+// supported by the system.
+func LatestCommandLineTools(updates []softwareupdate.Update) (*softwareupdate.Update, bool) {
+	var latest *softwareupdate.Update
+	var latestVersion *semver.Version
+	for i := range updates {
+		u := &updates[i]
+		if !strings.HasPrefix(u.Title, cltTitle) && !strings.HasPrefix(u.Label, cltTitle) {
+			continue
+		}
+
+		v, err := semver.NewVersion(u.Version)
+		if err != nil {
+			// Labels end with the version (e.g. "Command Line Tools for Xcode-15.0") when it isn't listed
+			_, version, _ := strings.Cut(u.Label, "-")
+			if v, err = semver.NewVersion(version); err != nil {
+				v = nil
+			}
+		}
+		if latest == nil || (v != nil && (latestVersion == nil || v.GreaterThan(latestVersion))) {
+			latest, latestVersion = u, v
+		}
+	}
+
+	return latest, latest != nil
+}
+
+// Select sets the active developer directory to the Xcode application or developer directory at path (e.g.
+// "/Applications/Xcode_15.0.app" or CommandLineToolsPath).
+func Select(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("devtools: invalid developer directory: %w", err)
+	}
+
+	// cmdSwitch represents the command used for executing macOS's xcode-select to set the developer directory.
+	//   * --switch - set the active developer directory to the following path
+	//   * path - the Xcode application or developer directory
+	cmdSwitch := []string{"xcode-select", "--switch", path}
+
+	out, err := util.ExecuteCommand(ctx, cmdSwitch, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("devtools: failed to select developer directory, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// PathsTask creates the task that adds the tools in the developer directory's usr/bin (e.g. the ones that are
+// otherwise only reachable through xcrun) to the PATH of login shells with a file in /etc/paths.d. The file is
+// replaced, rather than added to, when another developer directory is selected.
+func PathsTask(developerDir string) *shellprofile.PathsTask {
+	return shellprofile.NewPathsTask(PathsFile, filepath.Join(developerDir, "usr", "bin"))
+}
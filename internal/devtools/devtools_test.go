@@ -0,0 +1,65 @@
+package devtools
+
+import (
+	_ "embed"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/softwareupdate"
+)
+
+// pkgInfo contains the receipt printed by pkgutil for the Command Line Tools.
+//
+//go:embed testdata/pkginfo.txt
+var pkgInfo string
+
+func TestParsePkgVersion(t *testing.T) {
+	version, err := parsePkgVersion(pkgInfo)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "15.0.0.0.1.1694021235", version)
+}
+
+func TestFindXcodes(t *testing.T) {
+	dir := t.TempDir()
+	for name, version := range map[string]string{"Xcode.app": "15.0.1", "Xcode_14.3.app": "14.3"} {
+		contents := filepath.Join(dir, name, "Contents")
+		assert.NoError(t, os.MkdirAll(contents, 0755))
+		info := `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0"><dict><key>CFBundleShortVersionString</key><string>` + version + `</string></dict></plist>`
+		assert.NoError(t, os.WriteFile(filepath.Join(contents, "Info.plist"), []byte(info), 0644))
+	}
+	// Incomplete installations (e.g. Xcode being extracted) are skipped
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "Xcode-beta.app"), 0755))
+
+	xcodes, err := FindXcodes(dir)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Xcode{
+		{Path: filepath.Join(dir, "Xcode.app"), Version: "15.0.1"},
+		{Path: filepath.Join(dir, "Xcode_14.3.app"), Version: "14.3"},
+	}, xcodes)
+}
+
+func TestLatestCommandLineTools(t *testing.T) {
+	updates := []softwareupdate.Update{
+		{Label: "macOS Ventura 13.6.1-22G313", Title: "macOS Ventura 13.6.1", Version: "13.6.1"},
+		{Label: "Command Line Tools for Xcode-14.3", Title: "Command Line Tools for Xcode", Version: "14.3"},
+		{Label: "Command Line Tools for Xcode-15.0", Title: "Command Line Tools for Xcode", Version: "15.0"},
+		{Label: "Command Line Tools for Xcode-14.2", Title: "Command Line Tools for Xcode"},
+	}
+
+	latest, ok := LatestCommandLineTools(updates)
+
+	assert.True(t, ok)
+	assert.Equal(t, "Command Line Tools for Xcode-15.0", latest.Label)
+}
+
+func TestLatestCommandLineTools_None(t *testing.T) {
+	_, ok := LatestCommandLineTools([]softwareupdate.Update{{Label: "Safari17.1VenturaAuto-17.1", Title: "Safari", Version: "17.1"}})
+
+	assert.False(t, ok)
+}
@@ -0,0 +1,233 @@
+// Package diagnostics provides the functionality necessary for collecting the kernel panic logs and crash reports
+// that macOS saves in DiagnosticReports, and for packaging them into an archive, so that hosts which rebooted
+// unexpectedly can be triaged without logging in to them.
+package diagnostics
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// SystemReportsDir is where macOS saves kernel panic logs and the crash reports of system processes.
+	SystemReportsDir = "/Library/Logs/DiagnosticReports"
+	// userReportsPattern matches the directories where macOS saves the crash reports of users' processes.
+	userReportsPattern = "/Users/*/Library/Logs/DiagnosticReports"
+	// retiredDir is the subdirectory that reports are moved to once they've been submitted to Apple.
+	retiredDir = "Retired"
+
+	// manifestName is the name of the manifest in archives.
+	manifestName = "manifest.json"
+	// timestampFormat is the format of the time in the names of archives.
+	timestampFormat = "20060102T150405Z"
+)
+
+// Kind is a kind of diagnostic report.
+type Kind string
+
+const (
+	// KindPanic is a kernel panic log, written when the kernel panicked and the host rebooted.
+	KindPanic Kind = "panic"
+	// KindCrash is the crash, hang, or resource report of a process.
+	KindCrash Kind = "crash"
+)
+
+// crashExts are the extensions of the reports of processes.
+var crashExts = map[string]bool{
+	".crash":        true,
+	".ips":          true,
+	".diag":         true,
+	".spin":         true,
+	".hang":         true,
+	".cpu_resource": true,
+}
+
+// Report is a diagnostic report saved by macOS.
+type Report struct {
+	// Path is the path to the report.
+	Path string `json:"path"`
+	// Kind is the kind of report.
+	Kind Kind `json:"kind"`
+	// Size is the size of the report in bytes.
+	Size int64 `json:"size"`
+	// Modified is when the report was written.
+	Modified time.Time `json:"modified"`
+}
+
+// Manifest describes the reports in an archive.
+type Manifest struct {
+	// InstanceID is the ID of the instance that the reports were collected from.
+	InstanceID string `json:"instance_id,omitempty"`
+	// Hostname is the name of the host that the reports were collected from.
+	Hostname string `json:"hostname,omitempty"`
+	// Collected is when the reports were collected.
+	Collected time.Time `json:"collected"`
+	// Since is the time that reports written before were left out.
+	Since time.Time `json:"since"`
+	// Reports are the reports in the archive.
+	Reports []Report `json:"reports"`
+}
+
+// Panics counts the kernel panic logs in the manifest.
+func (m *Manifest) Panics() int {
+	var n int
+	for _, r := range m.Reports {
+		if r.Kind == KindPanic {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Dirs gets the directories that reports are searched for in: the system's, including the reports that have been
+// submitted to Apple, and, when users is set, each user's.
+func Dirs(users bool) ([]string, error) {
+	dirs := []string{SystemReportsDir, filepath.Join(SystemReportsDir, retiredDir)}
+	if !users {
+		return dirs, nil
+	}
+
+	userDirs, err := filepath.Glob(userReportsPattern)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range userDirs {
+		dirs = append(dirs, dir, filepath.Join(dir, retiredDir))
+	}
+
+	return dirs, nil
+}
+
+// Find finds the reports of the kinds that were written in the directories since the given time, oldest first.
+// Directories that don't exist are skipped since macOS only creates them when it writes the first report.
+func Find(dirs []string, since time.Time, kinds ...Kind) ([]Report, error) {
+	wanted := map[Kind]bool{}
+	for _, k := range kinds {
+		wanted[k] = true
+	}
+
+	var reports []Report
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("cannot read reports in %s: %w", dir, err)
+		}
+
+		for _, e := range entries {
+			kind, ok := classify(e.Name())
+			if !ok || !e.Type().IsRegular() || (len(wanted) > 0 && !wanted[kind]) {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				return nil, err
+			}
+			if info.ModTime().Before(since) {
+				continue
+			}
+			reports = append(reports, Report{
+				Path:     filepath.Join(dir, e.Name()),
+				Kind:     kind,
+				Size:     info.Size(),
+				Modified: info.ModTime(),
+			})
+		}
+	}
+
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].Modified.Before(reports[j].Modified)
+	})
+
+	return reports, nil
+}
+
+// classify gets the kind of the report with the file name, which is false for files that aren't reports.
+func classify(name string) (Kind, bool) {
+	ext := filepath.Ext(name)
+	switch {
+	case ext == ".panic":
+		return KindPanic, true
+	// Kernel panics are also written as .ips reports (e.g. "panic-full-2023-05-01-101500.0002.ips").
+	case ext == ".ips" && (strings.HasPrefix(name, "panic-") || strings.HasPrefix(name, "Kernel")):
+		return KindPanic, true
+	case crashExts[ext]:
+		return KindCrash, true
+	default:
+		return "", false
+	}
+}
+
+// Archive writes the manifest and its reports to w as a gzipped tar. Reports are stored at their path without the
+// leading slash (e.g. "Library/Logs/DiagnosticReports/panic-full-2023-05-01-101500.0002.panic").
+func Archive(w io.Writer, m *Manifest) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: manifestName, Mode: 0o644, Size: int64(len(manifest)), ModTime: m.Collected}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return err
+	}
+
+	for _, r := range m.Reports {
+		if err := addFile(tw, r); err != nil {
+			return fmt.Errorf("cannot archive %s: %w", r.Path, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// addFile writes the report to the archive.
+func addFile(tw *tar.Writer, r Report) error {
+	f, err := os.Open(r.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = strings.TrimPrefix(filepath.ToSlash(r.Path), "/")
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	// Only the size in the header is copied since reports that are still being written could grow.
+	_, err = io.CopyN(tw, f, hdr.Size)
+
+	return err
+}
+
+// ArchiveName gets the file name of the archive of reports collected from the host at the given time (e.g.
+// "diagnostics-i-0123456789abcdef0-20230501T101500Z.tar.gz").
+func ArchiveName(host string, collected time.Time) string {
+	return fmt.Sprintf("diagnostics-%s-%s.tar.gz", host, collected.UTC().Format(timestampFormat))
+}
@@ -0,0 +1,112 @@
+package diagnostics
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeReport writes a report named name in dir, modified at the given time.
+func writeReport(t *testing.T, dir, name, content string, modified time.Time) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	assert.NoError(t, os.Chtimes(path, modified, modified))
+
+	return path
+}
+
+func TestClassify(t *testing.T) {
+	cases := map[string]Kind{
+		"Kernel-2023-05-01-101500.panic":              KindPanic,
+		"panic-full-2023-05-01-101500.0002.panic":     KindPanic,
+		"panic-base-2023-05-01-101500.ips":            KindPanic,
+		"Xcode-2023-05-01-101500.ips":                 KindCrash,
+		"launchd_2023-05-01-101500_ip-10-0-0-1.crash": KindCrash,
+		"WindowServer_2023-05-01-101500.spin":         KindCrash,
+	}
+	for name, expected := range cases {
+		kind, ok := classify(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, expected, kind, name)
+	}
+
+	for _, name := range []string{".DS_Store", "Analytics-2023-05-01.core_analytics", "log.txt"} {
+		_, ok := classify(name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	retired := filepath.Join(dir, retiredDir)
+	assert.NoError(t, os.Mkdir(retired, 0o755))
+	now := time.Now()
+
+	panicLog := writeReport(t, retired, "panic-full-2023-05-01-101500.0002.panic", "panic", now.Add(-2*time.Hour))
+	crash := writeReport(t, dir, "Xcode-2023-05-01-101500.ips", "crash", now.Add(-time.Hour))
+	writeReport(t, dir, "Xcode-2023-04-01-101500.ips", "old", now.Add(-30*24*time.Hour))
+	writeReport(t, dir, "notes.txt", "not a report", now)
+
+	reports, err := Find([]string{dir, retired, filepath.Join(dir, "missing")}, now.Add(-24*time.Hour))
+	assert.NoError(t, err)
+	if assert.Len(t, reports, 2) {
+		assert.Equal(t, panicLog, reports[0].Path, "reports should be sorted oldest first")
+		assert.Equal(t, KindPanic, reports[0].Kind)
+		assert.Equal(t, crash, reports[1].Path)
+		assert.Equal(t, int64(5), reports[1].Size)
+	}
+
+	reports, err = Find([]string{dir, retired}, now.Add(-24*time.Hour), KindPanic)
+	assert.NoError(t, err)
+	if assert.Len(t, reports, 1) {
+		assert.Equal(t, panicLog, reports[0].Path)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	path := writeReport(t, dir, "panic-full-2023-05-01-101500.0002.panic", "panicked", time.Now())
+	m := &Manifest{
+		InstanceID: "i-0123456789abcdef0",
+		Collected:  time.Date(2023, 5, 1, 10, 30, 0, 0, time.UTC),
+		Reports:    []Report{{Path: path, Kind: KindPanic, Size: 8}},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, Archive(&buf, m))
+
+	gz, err := gzip.NewReader(&buf)
+	assert.NoError(t, err)
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		assert.NoError(t, err)
+		files[hdr.Name] = data
+	}
+
+	assert.Equal(t, "panicked", string(files[filepath.ToSlash(path)[1:]]))
+	var manifest Manifest
+	assert.NoError(t, json.Unmarshal(files[manifestName], &manifest))
+	assert.Equal(t, "i-0123456789abcdef0", manifest.InstanceID)
+	assert.Equal(t, 1, manifest.Panics())
+}
+
+func TestArchiveName(t *testing.T) {
+	collected := time.Date(2023, 5, 1, 10, 15, 0, 0, time.UTC)
+	assert.Equal(t, "diagnostics-i-0123456789abcdef0-20230501T101500Z.tar.gz", ArchiveName("i-0123456789abcdef0", collected))
+}
@@ -0,0 +1,57 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// sysdiagnoseOutputPattern matches the line that sysdiagnose prints with the path of its archive.
+var sysdiagnoseOutputPattern = regexp.MustCompile(`Output available at '([^']+)'`)
+
+// Sysdiagnose runs sysdiagnose(1) without prompting or showing any UI and waits for it to write its archive, named
+// name.tar.gz, in dir. The path to the archive is returned. sysdiagnose collects the system's logs, the state of its
+// disks and network, and spindumps, which takes several minutes.
+func Sysdiagnose(ctx context.Context, dir, name string) (string, error) {
+	// cmdSysdiagnose represents the command used for executing macOS's sysdiagnose.
+	//   * -u - disable the UI feedback
+	//   * -b - don't show the archive in Finder once it's written
+	//   * -f - write the archive in the directory
+	//   * -A - name the archive
+	// sysdiagnose only waits for Enter to be pressed before starting when stdin is a terminal, which it isn't here.
+	cmdSysdiagnose := []string{"sysdiagnose", "-u", "-b", "-f", dir, "-A", name}
+
+	out, err := util.ExecuteCommand(ctx, cmdSysdiagnose, "", nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("sysdiagnose failed, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	path := archivePath(out.Stdout, dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("sysdiagnose didn't write its archive: %w", err)
+	}
+
+	return path, nil
+}
+
+// archivePath gets the path to the archive that sysdiagnose printed, falling back to where it's written by default
+// for releases that don't print it.
+func archivePath(stdout, dir, name string) string {
+	if m := sysdiagnoseOutputPattern.FindStringSubmatch(stdout); m != nil {
+		return m[1]
+	}
+
+	return filepath.Join(dir, name+".tar.gz")
+}
+
+// SysdiagnoseName gets the name, without its extension, of the sysdiagnose archive of the host started at the given
+// time (e.g. "sysdiagnose-i-0123456789abcdef0-20230501T101500Z").
+func SysdiagnoseName(host string, started time.Time) string {
+	return fmt.Sprintf("sysdiagnose-%s-%s", host, started.UTC().Format(timestampFormat))
+}
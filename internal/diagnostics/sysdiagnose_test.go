@@ -0,0 +1,21 @@
+package diagnostics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchivePath(t *testing.T) {
+	stdout := "Sysdiagnose will run for about 5 minutes.\n\nOutput available at '/var/tmp/sysdiagnose-i-0123456789abcdef0.tar.gz'.\n"
+	assert.Equal(t, "/var/tmp/sysdiagnose-i-0123456789abcdef0.tar.gz", archivePath(stdout, "/tmp", "other"))
+
+	assert.Equal(t, "/var/tmp/sysdiagnose-host.tar.gz", archivePath("", "/var/tmp", "sysdiagnose-host"),
+		"archives should be found where they're written by default when their path isn't printed")
+}
+
+func TestSysdiagnoseName(t *testing.T) {
+	started := time.Date(2023, 5, 1, 10, 15, 0, 0, time.UTC)
+	assert.Equal(t, "sysdiagnose-i-0123456789abcdef0-20230501T101500Z", SysdiagnoseName("i-0123456789abcdef0", started))
+}
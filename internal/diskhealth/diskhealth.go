@@ -0,0 +1,292 @@
+// Package diskhealth provides the functionality necessary for checking the health of the host's disks (their SMART
+// status, the results of verifying their filesystems, and the free space left in them) and for triggering alarm
+// hooks when the disks degrade, so that failing or full disks are noticed before the builds running on them fail.
+package diskhealth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/fsck"
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+)
+
+const (
+	// DefaultMinFreePercent is the percentage of a filesystem's space that must be free when no other threshold is
+	// configured.
+	DefaultMinFreePercent = 10
+
+	// smartVerified is the SMART status of disks without problems.
+	smartVerified = "Verified"
+	// smartNotSupported is the SMART status of disks that don't report it, which includes EBS volumes.
+	smartNotSupported = "Not Supported"
+
+	// systemVolumesDir is where macOS mounts the volumes of the system's APFS volume group.
+	systemVolumesDir = "/System/Volumes/"
+	// dataVolumeMountPoint is where the volume holding users' files is mounted on Catalina and later.
+	dataVolumeMountPoint = "/System/Volumes/Data"
+)
+
+// Check is a kind of health check.
+type Check string
+
+const (
+	// CheckSMART checks the SMART status of the whole disks.
+	CheckSMART Check = "smart"
+	// CheckVerify verifies the mounted APFS filesystems with fsck_apfs.
+	CheckVerify Check = "verify"
+	// CheckFreeSpace checks the free space left in the mounted filesystems.
+	CheckFreeSpace Check = "free-space"
+)
+
+// Checks are all the kinds of health checks, in the order they're run.
+var Checks = []Check{CheckSMART, CheckVerify, CheckFreeSpace}
+
+// Status is the outcome of a health check.
+type Status string
+
+const (
+	// StatusOK means that the check passed.
+	StatusOK Status = "ok"
+	// StatusDegraded means that the check found a problem.
+	StatusDegraded Status = "degraded"
+	// StatusUnknown means that the check couldn't be run, which doesn't trigger alarms by itself.
+	StatusUnknown Status = "unknown"
+)
+
+// Finding is the outcome of a health check of a disk or filesystem.
+type Finding struct {
+	// Check is the kind of health check.
+	Check Check `json:"check"`
+	// Target is the disk or mount point that was checked (e.g. "disk0" or "/").
+	Target string `json:"target"`
+	// Status is the outcome of the check.
+	Status Status `json:"status"`
+	// Message describes the outcome (e.g. "SMART status is Failing").
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s %s: %s", f.Check, f.Target, f.Message)
+}
+
+// Report is the outcome of checking the health of the host's disks.
+type Report struct {
+	// Time is when the disks were checked.
+	Time time.Time `json:"time"`
+	// Status is degraded when any of the findings is.
+	Status Status `json:"status"`
+	// Findings are the outcomes of the checks.
+	Findings []Finding `json:"findings"`
+}
+
+// Degraded gets the findings which found a problem.
+func (r *Report) Degraded() []Finding {
+	var degraded []Finding
+	for _, f := range r.Findings {
+		if f.Status == StatusDegraded {
+			degraded = append(degraded, f)
+		}
+	}
+
+	return degraded
+}
+
+// Summary describes the report in a single line (e.g. "2 problems: smart disk0: SMART status is Failing, ...").
+func (r *Report) Summary() string {
+	degraded := r.Degraded()
+	if len(degraded) == 0 {
+		return "disks are healthy"
+	}
+
+	problems := make([]string, 0, len(degraded))
+	for _, f := range degraded {
+		problems = append(problems, f.String())
+	}
+	noun := "problems"
+	if len(degraded) == 1 {
+		noun = "problem"
+	}
+
+	return fmt.Sprintf("%d %s: %s", len(degraded), noun, strings.Join(problems, ", "))
+}
+
+// Thresholds are the limits that the free space left in each filesystem is checked against.
+type Thresholds struct {
+	// MinFreePercent is the percentage of the filesystem's space that must be free, it isn't checked when zero.
+	MinFreePercent float64
+	// MinFreeBytes is the amount of space that must be free, it isn't checked when zero.
+	MinFreeBytes uint64
+}
+
+// Checker checks the health of the host's disks.
+type Checker struct {
+	// DiskUtil fetches the SMART status of the disks.
+	DiskUtil diskutil.DiskUtil
+	// Mounted lists the mounted filesystems (e.g. mounts.Mounted).
+	Mounted func() ([]mounts.Filesystem, error)
+	// Verify checks the filesystem on the raw device without changing it (e.g. with fsck.Check).
+	Verify func(ctx context.Context, device string) (*fsck.Result, error)
+	// Thresholds are the limits for the free space left in the filesystems.
+	Thresholds Thresholds
+	// now gets the time that reports are made at.
+	now func() time.Time
+}
+
+// NewChecker creates a Checker which uses d for the SMART status, verifies the filesystems with fsck_apfs, and
+// checks the free space against the thresholds.
+func NewChecker(d diskutil.DiskUtil, thresholds Thresholds) *Checker {
+	return &Checker{
+		DiskUtil: d,
+		Mounted:  mounts.Mounted,
+		Verify: func(ctx context.Context, device string) (*fsck.Result, error) {
+			return fsck.Check(ctx, device, fsck.ModeLive, nil)
+		},
+		Thresholds: thresholds,
+		now:        time.Now,
+	}
+}
+
+// Check runs the health checks. The filesystems are only verified when verify is set since verifying reads all of
+// their metadata. Checks that can't be run are reported as unknown findings so that the others still run, an error is
+// only returned when the context is done.
+func (c *Checker) Check(ctx context.Context, verify bool) (*Report, error) {
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	r := &Report{Time: now(), Status: StatusOK}
+
+	r.Findings = append(r.Findings, c.checkSMART(ctx)...)
+
+	filesystems, err := c.Mounted()
+	if err != nil {
+		r.Findings = append(r.Findings, Finding{Check: CheckFreeSpace, Target: "mounts", Status: StatusUnknown, Message: fmt.Sprintf("cannot list mounted filesystems: %v", err)})
+	}
+	filesystems = checkedFilesystems(filesystems)
+	if verify {
+		r.Findings = append(r.Findings, c.checkVerify(ctx, filesystems)...)
+	}
+	r.Findings = append(r.Findings, c.checkFreeSpace(filesystems)...)
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(r.Degraded()) > 0 {
+		r.Status = StatusDegraded
+	}
+
+	return r, nil
+}
+
+// checkSMART checks the SMART status of each whole disk. Disks that don't report it (e.g. EBS volumes and APFS
+// containers) are left out.
+func (c *Checker) checkSMART(ctx context.Context) []Finding {
+	partitions, err := c.DiskUtil.List(ctx, nil)
+	if err != nil {
+		return []Finding{{Check: CheckSMART, Target: "disks", Status: StatusUnknown, Message: fmt.Sprintf("cannot list disks: %v", err)}}
+	}
+
+	var findings []Finding
+	for _, id := range partitions.WholeDisks {
+		disk, err := c.DiskUtil.Info(ctx, id)
+		if err != nil {
+			findings = append(findings, Finding{Check: CheckSMART, Target: id, Status: StatusUnknown, Message: fmt.Sprintf("cannot get disk information: %v", err)})
+			continue
+		}
+
+		switch disk.SMARTStatus {
+		case "", smartNotSupported:
+			continue
+		case smartVerified:
+			f := Finding{Check: CheckSMART, Target: id, Status: StatusOK, Message: "SMART status is Verified"}
+			// NVMe disks also report how much of their spare capacity is left for replacing worn out blocks.
+			if smart := disk.SMARTDeviceSpecificKeysMayVaryNotGuaranteed; smart != nil && smart.AvailableSpareThreshold > 0 && smart.AvailableSpare < smart.AvailableSpareThreshold {
+				f.Status = StatusDegraded
+				f.Message = fmt.Sprintf("available spare %d%% is below the threshold of %d%%", smart.AvailableSpare, smart.AvailableSpareThreshold)
+			}
+			findings = append(findings, f)
+		default:
+			findings = append(findings, Finding{Check: CheckSMART, Target: id, Status: StatusDegraded, Message: "SMART status is " + disk.SMARTStatus})
+		}
+	}
+
+	return findings
+}
+
+// checkVerify verifies each writable APFS filesystem live, from a snapshot, without unmounting it.
+func (c *Checker) checkVerify(ctx context.Context, filesystems []mounts.Filesystem) []Finding {
+	var findings []Finding
+	for _, fs := range filesystems {
+		if fs.Type != "apfs" || fs.ReadOnly || !strings.HasPrefix(fs.Device, "/dev/disk") {
+			continue
+		}
+
+		device := "/dev/r" + strings.TrimPrefix(fs.Device, "/dev/")
+		result, err := c.Verify(ctx, device)
+		if err != nil {
+			findings = append(findings, Finding{Check: CheckVerify, Target: fs.MountPoint, Status: StatusUnknown, Message: fmt.Sprintf("cannot verify filesystem: %v", err)})
+			continue
+		}
+
+		f := Finding{Check: CheckVerify, Target: fs.MountPoint, Status: StatusOK, Message: "filesystem is " + string(result.Verdict)}
+		if result.Verdict != fsck.VerdictClean {
+			f.Status = StatusDegraded
+			if len(result.Problems) > 0 {
+				f.Message += ": " + result.Problems[0]
+			}
+		}
+		findings = append(findings, f)
+	}
+
+	return findings
+}
+
+// checkFreeSpace checks the space left in each filesystem against the thresholds.
+func (c *Checker) checkFreeSpace(filesystems []mounts.Filesystem) []Finding {
+	if c.Thresholds.MinFreePercent == 0 && c.Thresholds.MinFreeBytes == 0 {
+		return nil
+	}
+
+	var findings []Finding
+	for _, fs := range filesystems {
+		if fs.ReadOnly {
+			continue
+		}
+
+		freePercent := 100 - fs.UsedPercent()
+		f := Finding{Check: CheckFreeSpace, Target: fs.MountPoint, Status: StatusOK, Message: fmt.Sprintf("%.1f%% free", freePercent)}
+		switch {
+		case c.Thresholds.MinFreePercent > 0 && freePercent < c.Thresholds.MinFreePercent:
+			f.Status = StatusDegraded
+			f.Message = fmt.Sprintf("%.1f%% free is below the threshold of %g%%", freePercent, c.Thresholds.MinFreePercent)
+		case c.Thresholds.MinFreeBytes > 0 && fs.AvailableBytes < c.Thresholds.MinFreeBytes:
+			f.Status = StatusDegraded
+			f.Message = fmt.Sprintf("%d bytes free is below the threshold of %d bytes", fs.AvailableBytes, c.Thresholds.MinFreeBytes)
+		}
+		findings = append(findings, f)
+	}
+
+	return findings
+}
+
+// checkedFilesystems filters the filesystems down to the local ones with capacity (e.g. without devfs), leaving out
+// the volumes that macOS manages for itself under /System/Volumes (e.g. Preboot and VM), which are small and can
+// be full without affecting anything. The Data volume that holds users' files is kept.
+func checkedFilesystems(filesystems []mounts.Filesystem) []mounts.Filesystem {
+	var checked []mounts.Filesystem
+	for _, fs := range filesystems {
+		if !fs.Local || fs.TotalBytes == 0 {
+			continue
+		}
+		if strings.HasPrefix(fs.MountPoint, systemVolumesDir) && fs.MountPoint != dataVolumeMountPoint {
+			continue
+		}
+		checked = append(checked, fs)
+	}
+
+	return checked
+}
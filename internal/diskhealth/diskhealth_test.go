@@ -0,0 +1,124 @@
+package diskhealth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/fsck"
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/diskutilfakes"
+)
+
+var testTime = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func testDisks(smartStatus string) []diskutilfakes.Disk {
+	return []diskutilfakes.Disk{
+		{
+			ID:          "disk0",
+			Size:        500_000_000_000,
+			Internal:    true,
+			MediaName:   "APPLE SSD AP0512Q",
+			SMARTStatus: smartStatus,
+			Partitions: []diskutilfakes.Partition{
+				{Content: "Apple_APFS", Size: 500_000_000_000, Container: &diskutilfakes.Container{
+					ID:      "disk3",
+					Volumes: []diskutilfakes.Volume{{Name: "Macintosh HD", Size: 20_000_000_000, MountPoint: "/"}},
+				}},
+			},
+		},
+		{ID: "disk4", Size: 100_000_000_000, MediaName: "Amazon Elastic Block Store", SMARTStatus: "Not Supported"},
+	}
+}
+
+func testFilesystems() []mounts.Filesystem {
+	return []mounts.Filesystem{
+		{Device: "/dev/disk3s1s1", MountPoint: "/", Type: "apfs", ReadOnly: true, Local: true, TotalBytes: 500, FreeBytes: 100, AvailableBytes: 100},
+		{Device: "/dev/disk3s5", MountPoint: "/System/Volumes/Data", Type: "apfs", Local: true, TotalBytes: 500, FreeBytes: 5, AvailableBytes: 5},
+		{Device: "/dev/disk3s2", MountPoint: "/System/Volumes/Preboot", Type: "apfs", Local: true, TotalBytes: 500, FreeBytes: 1, AvailableBytes: 1},
+		{Device: "/dev/disk5s1", MountPoint: "/Volumes/Builds", Type: "apfs", Local: true, TotalBytes: 1000, FreeBytes: 500, AvailableBytes: 500},
+		{Device: "devfs", MountPoint: "/dev", Type: "devfs", Local: true},
+	}
+}
+
+func newTestChecker(smartStatus string, verdict fsck.Verdict) *Checker {
+	return &Checker{
+		DiskUtil: diskutilfakes.New(testDisks(smartStatus)...),
+		Mounted:  func() ([]mounts.Filesystem, error) { return testFilesystems(), nil },
+		Verify: func(ctx context.Context, device string) (*fsck.Result, error) {
+			return &fsck.Result{Device: device, Mode: fsck.ModeLive, Verdict: verdict, Problems: []string{"error: invalid refcnt"}}, nil
+		},
+		Thresholds: Thresholds{MinFreePercent: DefaultMinFreePercent},
+		now:        func() time.Time { return testTime },
+	}
+}
+
+func TestChecker_Check(t *testing.T) {
+	c := newTestChecker("Verified", fsck.VerdictClean)
+
+	r, err := c.Check(context.Background(), true)
+
+	assert.NoError(t, err)
+	assert.Equal(t, testTime, r.Time)
+	assert.Equal(t, StatusDegraded, r.Status)
+	assert.Equal(t, []Finding{
+		{Check: CheckSMART, Target: "disk0", Status: StatusOK, Message: "SMART status is Verified"},
+		{Check: CheckVerify, Target: "/System/Volumes/Data", Status: StatusOK, Message: "filesystem is clean"},
+		{Check: CheckVerify, Target: "/Volumes/Builds", Status: StatusOK, Message: "filesystem is clean"},
+		{Check: CheckFreeSpace, Target: "/System/Volumes/Data", Status: StatusDegraded, Message: "1.0% free is below the threshold of 10%"},
+		{Check: CheckFreeSpace, Target: "/Volumes/Builds", Status: StatusOK, Message: "50.0% free"},
+	}, r.Findings, "read-only and macOS managed volumes should be left out")
+}
+
+func TestChecker_Check_SMARTFailing(t *testing.T) {
+	c := newTestChecker("Failing", fsck.VerdictCorrupt)
+
+	r, err := c.Check(context.Background(), true)
+
+	assert.NoError(t, err)
+	assert.Contains(t, r.Degraded(), Finding{Check: CheckSMART, Target: "disk0", Status: StatusDegraded, Message: "SMART status is Failing"})
+	assert.Contains(t, r.Degraded(), Finding{Check: CheckVerify, Target: "/Volumes/Builds", Status: StatusDegraded, Message: "filesystem is corrupt: error: invalid refcnt"})
+}
+
+func TestChecker_Check_WithoutVerify(t *testing.T) {
+	c := newTestChecker("Verified", fsck.VerdictClean)
+	c.Verify = func(ctx context.Context, device string) (*fsck.Result, error) {
+		t.Fatal("filesystems shouldn't be verified")
+		return nil, nil
+	}
+	c.Thresholds = Thresholds{MinFreeBytes: 1}
+
+	r, err := c.Check(context.Background(), false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, StatusOK, r.Status)
+	assert.Empty(t, findingsFor(r.Findings, CheckVerify))
+}
+
+func TestChecker_Check_Unknown(t *testing.T) {
+	c := newTestChecker("Verified", fsck.VerdictClean)
+	c.DiskUtil.(*diskutilfakes.DiskUtil).FailNext("List", errors.New("diskutil timed out"))
+	c.Mounted = func() ([]mounts.Filesystem, error) { return nil, mounts.ErrUnsupported }
+
+	r, err := c.Check(context.Background(), true)
+
+	assert.NoError(t, err)
+	assert.Equal(t, StatusOK, r.Status, "checks that couldn't run shouldn't degrade the disks")
+	assert.Len(t, r.Findings, 2)
+	for _, f := range r.Findings {
+		assert.Equal(t, StatusUnknown, f.Status)
+	}
+}
+
+func TestReport_Summary(t *testing.T) {
+	r := &Report{Findings: []Finding{
+		{Check: CheckSMART, Target: "disk0", Status: StatusDegraded, Message: "SMART status is Failing"},
+		{Check: CheckFreeSpace, Target: "/", Status: StatusOK, Message: "50.0% free"},
+	}}
+	assert.Equal(t, "1 problem: smart disk0: SMART status is Failing", r.Summary())
+
+	assert.Equal(t, "disks are healthy", (&Report{}).Summary())
+}
@@ -0,0 +1,160 @@
+package diskhealth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// DefaultNamespace is the CloudWatch namespace that metrics are published in when no other is configured.
+	DefaultNamespace = "EC2MacOSUtils"
+	// MetricDegraded is the name of the CloudWatch metric counting the problems found, which alarms can be set on.
+	MetricDegraded = "DiskHealthDegraded"
+
+	// commandShell is the shell that command hooks are run with.
+	commandShell = "/bin/sh"
+)
+
+// Alert is the message sent by the SNS and command hooks.
+type Alert struct {
+	// InstanceID is the ID of the instance whose disks were checked.
+	InstanceID string `json:"instance_id,omitempty"`
+	// Summary describes the problems found in a single line.
+	Summary string `json:"summary"`
+	*Report
+}
+
+// newAlert creates the alert for the report.
+func newAlert(instanceID string, r *Report) Alert {
+	return Alert{InstanceID: instanceID, Summary: r.Summary(), Report: r}
+}
+
+// Subject summarizes the alert in a single line (e.g. "ec2-macos-utils: disks degraded on i-0123456789abcdef0").
+func (a Alert) Subject() string {
+	subject := "ec2-macos-utils: disks recovered"
+	if a.Status == StatusDegraded {
+		subject = "ec2-macos-utils: disks degraded"
+	}
+	if a.InstanceID != "" {
+		subject += " on " + a.InstanceID
+	}
+
+	return subject
+}
+
+// MetricsClient publishes CloudWatch metrics (e.g. *aws.Client).
+type MetricsClient interface {
+	PutMetricData(ctx context.Context, namespace string, data []aws.MetricDatum) error
+}
+
+// CloudWatchHook publishes the number of problems found, in total and for each kind of check, as the
+// DiskHealthDegraded metric.
+type CloudWatchHook struct {
+	// Client publishes the metrics.
+	Client MetricsClient
+	// Namespace is the namespace that the metrics are published in.
+	Namespace string
+	// InstanceID is the value of the metrics' InstanceId dimension.
+	InstanceID string
+}
+
+// Name identifies the hook.
+func (h *CloudWatchHook) Name() string {
+	return "cloudwatch"
+}
+
+// Trigger publishes the metrics for the report.
+func (h *CloudWatchHook) Trigger(ctx context.Context, r *Report) error {
+	degraded := r.Degraded()
+	data := []aws.MetricDatum{{
+		Name:       MetricDegraded,
+		Value:      float64(len(degraded)),
+		Unit:       "Count",
+		Dimensions: map[string]string{"InstanceId": h.InstanceID},
+	}}
+	for _, check := range Checks {
+		data = append(data, aws.MetricDatum{
+			Name:       MetricDegraded,
+			Value:      float64(len(findingsFor(degraded, check))),
+			Unit:       "Count",
+			Dimensions: map[string]string{"InstanceId": h.InstanceID, "Check": string(check)},
+		})
+	}
+
+	return h.Client.PutMetricData(ctx, h.Namespace, data)
+}
+
+// Publisher publishes messages to SNS topics (e.g. *aws.Client).
+type Publisher interface {
+	Publish(ctx context.Context, topicARN, subject, message string) (string, error)
+}
+
+// SNSHook publishes the alert as JSON to an SNS topic.
+type SNSHook struct {
+	// Client publishes the alert. Its region must be the topic's region.
+	Client Publisher
+	// TopicARN is the ARN of the topic.
+	TopicARN string
+	// InstanceID is the ID of the instance that the alert is attributed to.
+	InstanceID string
+}
+
+// Name identifies the hook.
+func (h *SNSHook) Name() string {
+	return "sns"
+}
+
+// Trigger publishes the alert for the report to the topic.
+func (h *SNSHook) Trigger(ctx context.Context, r *Report) error {
+	alert := newAlert(h.InstanceID, r)
+	message, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.Client.Publish(ctx, h.TopicARN, alert.Subject(), string(message))
+
+	return err
+}
+
+// CommandHook runs a local command with /bin/sh, giving it the alert as JSON on stdin. The status and summary are
+// also set in the EC2_MACOS_UTILS_DISK_HEALTH and EC2_MACOS_UTILS_DISK_HEALTH_SUMMARY environment variables for
+// scripts that don't parse JSON.
+type CommandHook struct {
+	// Command is the shell command to run (e.g. "/usr/local/bin/drain-runner").
+	Command string
+	// InstanceID is the ID of the instance that the alert is attributed to.
+	InstanceID string
+}
+
+// Name identifies the hook.
+func (h *CommandHook) Name() string {
+	return "command"
+}
+
+// Trigger runs the command with the alert for the report.
+func (h *CommandHook) Trigger(ctx context.Context, r *Report) error {
+	alert := newAlert(h.InstanceID, r)
+	message, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	env := []string{
+		"EC2_MACOS_UTILS_DISK_HEALTH=" + string(r.Status),
+		"EC2_MACOS_UTILS_DISK_HEALTH_SUMMARY=" + alert.Summary,
+	}
+
+	out, err := util.ExecuteCommand(ctx, []string{commandShell, "-c", h.Command}, "", env, io.NopCloser(bytes.NewReader(message)))
+	if err != nil {
+		return fmt.Errorf("command failed, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
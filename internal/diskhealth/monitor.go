@@ -0,0 +1,149 @@
+package diskhealth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Hook is an action that's triggered with the outcome of a run of the Monitor (e.g. publishing a metric).
+type Hook interface {
+	// Name identifies the hook in logs (e.g. "cloudwatch").
+	Name() string
+	// Trigger runs the action for the report.
+	Trigger(ctx context.Context, r *Report) error
+}
+
+// Monitor checks the health of the disks on an interval and triggers its hooks with the outcome.
+type Monitor struct {
+	// Checker checks the health of the disks.
+	Checker *Checker
+	// Interval is the time between runs.
+	Interval time.Duration
+	// VerifyInterval is the time between verifying the filesystems, which is longer than Interval since verifying
+	// reads all of their metadata. The findings of the last verification are carried into the reports in between.
+	// The filesystems aren't verified when it's zero.
+	VerifyInterval time.Duration
+	// Metrics are triggered after every run so that alarms on them always see the current state.
+	Metrics []Hook
+	// Alarms are triggered when the problems found change: when the disks degrade, when they recover, and when
+	// different problems are found. They're triggered again on the next run when any of them fails.
+	Alarms []Hook
+	// OnRun is called with the outcome of each run, when set (e.g. health.Monitor.Record).
+	OnRun func(err error)
+
+	lastVerify     time.Time
+	verifyFindings []Finding
+	problems       string
+}
+
+// Run runs the checks on the monitor's interval until the context is done.
+func (m *Monitor) Run(ctx context.Context) error {
+	for {
+		_, err := m.RunOnce(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Disk health run failed")
+		}
+		if m.OnRun != nil {
+			m.OnRun(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(m.Interval):
+		}
+	}
+}
+
+// RunOnce checks the health of the disks and triggers the hooks. The report is returned even when hooks fail.
+func (m *Monitor) RunOnce(ctx context.Context) (*Report, error) {
+	now := time.Now
+	if m.Checker.now != nil {
+		now = m.Checker.now
+	}
+	verify := m.VerifyInterval > 0 && (m.lastVerify.IsZero() || now().Sub(m.lastVerify) >= m.VerifyInterval)
+
+	r, err := m.Checker.Check(ctx, verify)
+	if err != nil {
+		return nil, err
+	}
+	if verify {
+		m.lastVerify = r.Time
+		m.verifyFindings = findingsFor(r.Findings, CheckVerify)
+	} else if len(m.verifyFindings) > 0 {
+		r.Findings = append(r.Findings, m.verifyFindings...)
+		if len(r.Degraded()) > 0 {
+			r.Status = StatusDegraded
+		}
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"status":  r.Status,
+		"summary": r.Summary(),
+	})
+	if r.Status == StatusDegraded {
+		logger.Warn("Disks are degraded")
+	} else {
+		logger.Info("Checked disk health")
+	}
+
+	var failed []string
+	failed = append(failed, trigger(ctx, m.Metrics, r)...)
+	if problems := problemsKey(r); problems != m.problems {
+		alarmsFailed := trigger(ctx, m.Alarms, r)
+		if len(alarmsFailed) == 0 {
+			m.problems = problems
+		}
+		failed = append(failed, alarmsFailed...)
+	}
+	if len(failed) > 0 {
+		return r, fmt.Errorf("failed to trigger hooks: %s", strings.Join(failed, ", "))
+	}
+
+	return r, nil
+}
+
+// trigger triggers each of the hooks with the report, returning the names of the hooks that failed.
+func trigger(ctx context.Context, hooks []Hook, r *Report) []string {
+	var failed []string
+	for _, h := range hooks {
+		if err := h.Trigger(ctx, r); err != nil {
+			logrus.WithError(err).WithField("hook", h.Name()).Warn("Failed to trigger hook")
+			failed = append(failed, h.Name())
+		}
+	}
+
+	return failed
+}
+
+// problemsKey identifies the problems in the report by their check and target, leaving out their messages which
+// change from run to run (e.g. the percentage of free space).
+func problemsKey(r *Report) string {
+	var keys []string
+	for _, f := range r.Degraded() {
+		keys = append(keys, string(f.Check)+" "+f.Target)
+	}
+	sort.Strings(keys)
+
+	return strings.Join(keys, "\n")
+}
+
+// findingsFor gets the findings of the kind of check.
+func findingsFor(findings []Finding, check Check) []Finding {
+	var matched []Finding
+	for _, f := range findings {
+		if f.Check == check {
+			matched = append(matched, f)
+		}
+	}
+
+	return matched
+}
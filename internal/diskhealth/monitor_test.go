@@ -0,0 +1,110 @@
+package diskhealth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/fsck"
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+)
+
+// recordingHook records the reports it's triggered with, failing while err is set.
+type recordingHook struct {
+	reports []*Report
+	err     error
+}
+
+func (h *recordingHook) Name() string {
+	return "recording"
+}
+
+func (h *recordingHook) Trigger(ctx context.Context, r *Report) error {
+	h.reports = append(h.reports, r)
+
+	return h.err
+}
+
+func TestMonitor_RunOnce_Alarms(t *testing.T) {
+	ctx := context.Background()
+	c := newTestChecker("Verified", fsck.VerdictClean)
+	metrics, alarms := &recordingHook{}, &recordingHook{}
+	m := &Monitor{Checker: c, Metrics: []Hook{metrics}, Alarms: []Hook{alarms}}
+
+	r, err := m.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusDegraded, r.Status)
+	assert.Len(t, alarms.reports, 1, "alarms should be triggered when the disks degrade")
+
+	_, err = m.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, alarms.reports, 1, "alarms shouldn't be triggered again for the same problems")
+	assert.Len(t, metrics.reports, 2, "metrics should be published on every run")
+
+	c.Thresholds = Thresholds{}
+	r, err = m.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusOK, r.Status)
+	assert.Len(t, alarms.reports, 2, "alarms should be triggered when the disks recover")
+}
+
+func TestMonitor_RunOnce_AlarmFails(t *testing.T) {
+	ctx := context.Background()
+	alarms := &recordingHook{err: errors.New("topic not found")}
+	m := &Monitor{Checker: newTestChecker("Failing", fsck.VerdictClean), Alarms: []Hook{alarms}}
+
+	r, err := m.RunOnce(ctx)
+	assert.Error(t, err)
+	assert.NotNil(t, r, "the report should be returned when hooks fail")
+
+	alarms.err = nil
+	_, err = m.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, alarms.reports, 2, "failed alarms should be triggered again")
+}
+
+func TestMonitor_RunOnce_VerifyInterval(t *testing.T) {
+	ctx := context.Background()
+	now := testTime
+	verified := 0
+	c := newTestChecker("Verified", fsck.VerdictClean)
+	c.now = func() time.Time { return now }
+	c.Verify = func(ctx context.Context, device string) (*fsck.Result, error) {
+		verified++
+		return &fsck.Result{Device: device, Verdict: fsck.VerdictCorrupt}, nil
+	}
+	c.Mounted = func() ([]mounts.Filesystem, error) { return testFilesystems()[3:4], nil }
+	m := &Monitor{Checker: c, VerifyInterval: time.Hour}
+
+	r, err := m.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, verified)
+	assert.Equal(t, StatusDegraded, r.Status)
+
+	now = now.Add(10 * time.Minute)
+	r, err = m.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, verified, "filesystems shouldn't be verified before the interval passes")
+	assert.Equal(t, StatusDegraded, r.Status, "the last verification should be carried into the report")
+
+	now = now.Add(time.Hour)
+	_, err = m.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, verified)
+}
+
+func TestMonitor_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var outcomes []error
+	m := &Monitor{Checker: newTestChecker("Verified", fsck.VerdictClean), Interval: time.Hour}
+	m.OnRun = func(err error) {
+		outcomes = append(outcomes, err)
+		cancel()
+	}
+
+	assert.NoError(t, m.Run(ctx))
+	assert.Equal(t, []error{nil}, outcomes)
+}
@@ -0,0 +1,44 @@
+package doctor
+
+import (
+	"context"
+
+	"github.com/aws/ec2-macos-utils/internal/codesign"
+	"github.com/aws/ec2-macos-utils/internal/selfupdate"
+)
+
+// BinaryCheck reports the code signature and notarization of the running executable. Since the utility runs as
+// root, an executable that doesn't match its signature has been tampered with and fails the check. Executables that
+// aren't signed with a Developer ID, like local builds, or aren't notarized are only warnings.
+type BinaryCheck struct{}
+
+// Name identifies the check.
+func (BinaryCheck) Name() string {
+	return "binary-integrity"
+}
+
+// Run verifies the running executable's signature.
+func (BinaryCheck) Run(ctx context.Context) (*Result, error) {
+	exe, err := selfupdate.Executable()
+	if err != nil {
+		return nil, err
+	}
+	s, err := codesign.Verify(ctx, exe, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return binaryResult(s), nil
+}
+
+// binaryResult summarizes the signature.
+func binaryResult(s *codesign.Signature) *Result {
+	switch {
+	case s.Tampered():
+		return &Result{Status: StatusFail, Message: s.String() + " (the executable may have been tampered with)", Details: s}
+	case !s.Signed || s.AdHoc || !s.Notarized:
+		return &Result{Status: StatusWarn, Message: s.String(), Details: s}
+	default:
+		return &Result{Status: StatusOK, Message: s.String(), Details: s}
+	}
+}
@@ -0,0 +1,69 @@
+// Package doctor provides the functionality necessary for diagnosing the host's configuration. Each check inspects
+// one aspect of the host and reports whether it's likely to cause problems for the utility or the workloads on it.
+package doctor
+
+import (
+	"context"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	// StatusOK means nothing needs attention.
+	StatusOK Status = "ok"
+	// StatusWarn means the host works but is configured in a way that changes how some operations behave.
+	StatusWarn Status = "warn"
+	// StatusFail means the host is misconfigured in a way that will cause operations to fail.
+	StatusFail Status = "fail"
+	// StatusError means the check couldn't be completed.
+	StatusError Status = "error"
+)
+
+// Result is the outcome of a check.
+type Result struct {
+	// Check is the name of the check.
+	Check string `json:"check"`
+	// Status is the outcome of the check.
+	Status Status `json:"status"`
+	// Message describes the outcome for operators.
+	Message string `json:"message"`
+	// Details holds the typed information gathered by the check, when it has any.
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Check is a diagnostic of the host.
+type Check interface {
+	// Name identifies the check.
+	Name() string
+	// Run inspects the host. An error is returned when the check can't be completed.
+	Run(ctx context.Context) (*Result, error)
+}
+
+// Run runs the checks in order and returns their results. Checks that return an error are reported with
+// StatusError rather than stopping the remaining checks.
+func Run(ctx context.Context, checks []Check) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, c := range checks {
+		r, err := c.Run(ctx)
+		if err != nil {
+			results = append(results, Result{Check: c.Name(), Status: StatusError, Message: err.Error()})
+			continue
+		}
+		r.Check = c.Name()
+		results = append(results, *r)
+	}
+
+	return results
+}
+
+// Healthy checks if none of the results failed or errored. Warnings don't make the host unhealthy.
+func Healthy(results []Result) bool {
+	for _, r := range results {
+		if r.Status == StatusFail || r.Status == StatusError {
+			return false
+		}
+	}
+
+	return true
+}
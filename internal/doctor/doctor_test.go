@@ -0,0 +1,104 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/codesign"
+	"github.com/aws/ec2-macos-utils/internal/profiles"
+	"github.com/aws/ec2-macos-utils/internal/secpolicy"
+	"github.com/aws/ec2-macos-utils/internal/trim"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+)
+
+// fakeCheck is a check that returns a fixed result.
+type fakeCheck struct {
+	name   string
+	result *Result
+	err    error
+}
+
+func (c fakeCheck) Name() string {
+	return c.name
+}
+
+func (c fakeCheck) Run(ctx context.Context) (*Result, error) {
+	return c.result, c.err
+}
+
+func TestRun(t *testing.T) {
+	results := Run(context.Background(), []Check{
+		fakeCheck{name: "first", result: &Result{Status: StatusOK, Message: "fine"}},
+		fakeCheck{name: "second", err: errors.New("broken")},
+		fakeCheck{name: "third", result: &Result{Status: StatusWarn, Message: "odd"}},
+	})
+
+	assert.Equal(t, []Result{
+		{Check: "first", Status: StatusOK, Message: "fine"},
+		{Check: "second", Status: StatusError, Message: "broken"},
+		{Check: "third", Status: StatusWarn, Message: "odd"},
+	}, results)
+	assert.False(t, Healthy(results))
+}
+
+func TestHealthy(t *testing.T) {
+	assert.True(t, Healthy(nil))
+	assert.True(t, Healthy([]Result{{Status: StatusOK}, {Status: StatusWarn}}), "warnings shouldn't be unhealthy")
+	assert.False(t, Healthy([]Result{{Status: StatusOK}, {Status: StatusFail}}))
+}
+
+func TestSecurityPolicyResult(t *testing.T) {
+	p := &secpolicy.Policy{SIP: secpolicy.StateEnabled, AuthenticatedRoot: secpolicy.StateEnabled, SecurityMode: secpolicy.SecurityFull}
+	r := securityPolicyResult(p)
+	assert.Equal(t, StatusOK, r.Status)
+	assert.Equal(t, "SIP enabled, authenticated root enabled, full security", r.Message)
+
+	p = &secpolicy.Policy{SIP: secpolicy.StateDisabled, AuthenticatedRoot: secpolicy.StateEnabled}
+	r = securityPolicyResult(p)
+	assert.Equal(t, StatusWarn, r.Status)
+	assert.Equal(t, "SIP disabled, authenticated root enabled (not the default policy)", r.Message)
+}
+
+func TestTRIMResult(t *testing.T) {
+	r := trimResult([]trim.Device{{ID: "disk4", Model: "APPLE SSD AP0256M"}})
+	assert.Equal(t, StatusOK, r.Status, "devices other than EBS volumes shouldn't be checked")
+	assert.Equal(t, "no EBS volumes found", r.Message)
+
+	enabled := types.TRIMStatus{Supported: true, Enabled: true}
+	r = trimResult([]trim.Device{{ID: "disk0", VolumeID: "vol-0aaaaaaaaaaaaaaaa", Status: enabled}})
+	assert.Equal(t, StatusOK, r.Status)
+	assert.Equal(t, "TRIM enabled on 1 EBS volume(s)", r.Message)
+
+	r = trimResult([]trim.Device{
+		{ID: "disk0", VolumeID: "vol-0aaaaaaaaaaaaaaaa", Status: enabled},
+		{ID: "disk2", VolumeID: "vol-0bbbbbbbbbbbbbbbb", Status: types.TRIMStatus{Supported: true}},
+		{ID: "disk5", VolumeID: "vol-0cccccccccccccccc"},
+	})
+	assert.Equal(t, StatusWarn, r.Status)
+	assert.Equal(t, "TRIM is vol-0bbbbbbbbbbbbbbbb (disk2) supported but disabled, vol-0cccccccccccccccc (disk5) unsupported", r.Message)
+}
+
+func TestMDMResult(t *testing.T) {
+	r := mdmResult(&profiles.Enrollment{})
+	assert.Equal(t, StatusOK, r.Status)
+	assert.Equal(t, "not enrolled in MDM", r.Message)
+
+	r = mdmResult(&profiles.Enrollment{MDM: true, UserApproved: true})
+	assert.Equal(t, StatusWarn, r.Status)
+	assert.Equal(t, "enrolled in MDM (user approved)", r.Message)
+}
+
+func TestBinaryResult(t *testing.T) {
+	r := binaryResult(&codesign.Signature{Signed: true, Valid: true, Notarized: true, TeamID: "94KV3E626L"})
+	assert.Equal(t, StatusOK, r.Status)
+	assert.Equal(t, "signed by 94KV3E626L and notarized", r.Message)
+
+	r = binaryResult(&codesign.Signature{Signed: true, Valid: true, AdHoc: true})
+	assert.Equal(t, StatusWarn, r.Status, "local builds should only warn")
+
+	r = binaryResult(&codesign.Signature{Signed: true, Problem: "invalid signature (code or signature have been modified)"})
+	assert.Equal(t, StatusFail, r.Status, "modified executables should fail")
+}
@@ -0,0 +1,35 @@
+package doctor
+
+import (
+	"context"
+
+	"github.com/aws/ec2-macos-utils/internal/profiles"
+)
+
+// MDMCheck reports the host's MDM enrollment. Enrollment isn't a failure but managed hosts behave differently for
+// several operations: profiles can only be installed through MDM, and MDM can restrict disk and security changes.
+type MDMCheck struct{}
+
+// Name identifies the check.
+func (MDMCheck) Name() string {
+	return "mdm-enrollment"
+}
+
+// Run inspects the MDM enrollment.
+func (MDMCheck) Run(ctx context.Context) (*Result, error) {
+	e, err := profiles.EnrollmentStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return mdmResult(e), nil
+}
+
+// mdmResult summarizes the enrollment.
+func mdmResult(e *profiles.Enrollment) *Result {
+	if e.Managed() {
+		return &Result{Status: StatusWarn, Message: e.String(), Details: e}
+	}
+
+	return &Result{Status: StatusOK, Message: e.String(), Details: e}
+}
@@ -0,0 +1,52 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/secpolicy"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// SecurityPolicyCheck reports the boot security policy. Policies other than the default aren't failures since
+// they're sometimes chosen deliberately (e.g. for kernel extensions) but they change how some disk operations
+// behave, such as modifying the system volume.
+type SecurityPolicyCheck struct{}
+
+// Name identifies the check.
+func (SecurityPolicyCheck) Name() string {
+	return "security-policy"
+}
+
+// Run inspects the boot security policy.
+func (SecurityPolicyCheck) Run(ctx context.Context) (*Result, error) {
+	appleSilicon, err := system.AppleSilicon(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot detect processor: %w", err)
+	}
+	p, err := secpolicy.Get(ctx, appleSilicon)
+	if err != nil {
+		return nil, err
+	}
+
+	return securityPolicyResult(p), nil
+}
+
+// securityPolicyResult summarizes the policy.
+func securityPolicyResult(p *secpolicy.Policy) *Result {
+	parts := []string{
+		"SIP " + string(p.SIP),
+		"authenticated root " + string(p.AuthenticatedRoot),
+	}
+	if p.SecurityMode != "" {
+		parts = append(parts, string(p.SecurityMode)+" security")
+	}
+	msg := strings.Join(parts, ", ")
+
+	if !p.IsDefault() {
+		return &Result{Status: StatusWarn, Message: msg + " (not the default policy)", Details: p}
+	}
+
+	return &Result{Status: StatusOK, Message: msg, Details: p}
+}
@@ -0,0 +1,52 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/trim"
+)
+
+// TRIMCheck reports EBS volumes that don't support TRIM or don't have it enabled. Without TRIM the blocks freed by
+// the filesystem stay allocated on the volume, which affects its performance and the size of its snapshots.
+type TRIMCheck struct{}
+
+// Name identifies the check.
+func (TRIMCheck) Name() string {
+	return "trim"
+}
+
+// Run inspects the TRIM status of the NVMe devices.
+func (TRIMCheck) Run(ctx context.Context) (*Result, error) {
+	devices, err := trim.Devices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return trimResult(devices), nil
+}
+
+// trimResult summarizes the TRIM status of the EBS volumes among the devices.
+func trimResult(devices []trim.Device) *Result {
+	var volumes []trim.Device
+	var problems []string
+	for _, d := range devices {
+		if d.VolumeID == "" {
+			continue
+		}
+		volumes = append(volumes, d)
+		if !d.Status.Enabled {
+			problems = append(problems, fmt.Sprintf("%s (%s) %s", d.VolumeID, d.ID, d.Status))
+		}
+	}
+
+	switch {
+	case len(volumes) == 0:
+		return &Result{Status: StatusOK, Message: "no EBS volumes found"}
+	case len(problems) > 0:
+		return &Result{Status: StatusWarn, Message: "TRIM is " + strings.Join(problems, ", "), Details: volumes}
+	default:
+		return &Result{Status: StatusOK, Message: fmt.Sprintf("TRIM enabled on %d EBS volume(s)", len(volumes)), Details: volumes}
+	}
+}
@@ -0,0 +1,114 @@
+// Package ebs provides the functionality necessary for identifying EBS volumes attached to EC2 macOS instances.
+package ebs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"howett.net/plist"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// volumeIDExp is the regexp expression for EBS volume IDs with or without the dash (e.g. vol-0123 or vol0123).
+var volumeIDExp = regexp.MustCompile("^vol-?[0-9a-f]+$")
+
+// IsVolumeID checks if s is formatted as an EBS volume ID.
+func IsVolumeID(s string) bool {
+	return volumeIDExp.MatchString(strings.ToLower(strings.TrimSpace(s)))
+}
+
+// NVMeDevice is an NVMe device reported by system_profiler. EBS volumes are exposed as NVMe devices whose serial
+// number is the volume ID without its dash (e.g. vol0123456789abcdef0).
+type NVMeDevice struct {
+	Name   string `plist:"_name"`
+	BSD    string `plist:"bsd_name"`
+	Model  string `plist:"device_model"`
+	Serial string `plist:"device_serial"`
+	// TRIM is whether the device reports support for TRIM (deallocate), either "Yes" or "No".
+	TRIM string `plist:"spnvme_trim_support"`
+}
+
+// nvmeItem mirrors the nested "_items" structure emitted by "system_profiler -xml SPNVMeDataType" where controllers
+// hold their devices.
+type nvmeItem struct {
+	NVMeDevice
+	Items []nvmeItem `plist:"_items"`
+}
+
+// VolumeID returns the EBS volume ID (e.g. vol-0123456789abcdef0) for the device or an empty string if the device
+// isn't an EBS volume.
+func (d NVMeDevice) VolumeID() string {
+	serial := strings.ToLower(strings.TrimSpace(d.Serial))
+	if !strings.HasPrefix(serial, "vol") || !IsVolumeID(serial) {
+		return ""
+	}
+
+	return "vol-" + strings.TrimPrefix(strings.TrimPrefix(serial, "vol"), "-")
+}
+
+// NVMeDevices fetches all NVMe devices attached to the system using system_profiler.
+func NVMeDevices(ctx context.Context) ([]NVMeDevice, error) {
+	// cmdNVMe represents the command used for executing macOS's system_profiler to list NVMe devices.
+	//   * -xml converts system_profiler's output from human-readable to the plist format
+	//   * SPNVMeDataType - limits the output to NVMe controllers and their devices
+	cmdNVMe := []string{"system_profiler", "-xml", "SPNVMeDataType"}
+
+	out, err := util.ExecuteCommand(ctx, cmdNVMe, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ebs: failed to run system_profiler to list NVMe devices, stderr: [%s]: %w", out.Stderr, err)
+	}
+
+	return decodeNVMeDevices(strings.NewReader(out.Stdout))
+}
+
+// decodeNVMeDevices decodes the raw plist data from system_profiler into a flat list of NVMe devices.
+func decodeNVMeDevices(reader io.ReadSeeker) ([]NVMeDevice, error) {
+	var data []nvmeItem
+	if err := plist.NewDecoder(reader).Decode(&data); err != nil {
+		return nil, fmt.Errorf("error decoding NVMe devices: %w", err)
+	}
+
+	var devices []NVMeDevice
+	var walk func(items []nvmeItem)
+	walk = func(items []nvmeItem) {
+		for _, item := range items {
+			if item.BSD != "" {
+				devices = append(devices, item.NVMeDevice)
+			}
+			walk(item.Items)
+		}
+	}
+	walk(data)
+
+	return devices, nil
+}
+
+// DeviceForVolume finds the device identifier (e.g. disk2) of the NVMe device backing the EBS volume with the given ID.
+func DeviceForVolume(ctx context.Context, volumeID string) (string, error) {
+	devices, err := NVMeDevices(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return findVolumeDevice(devices, volumeID)
+}
+
+// findVolumeDevice searches the devices for the EBS volume with the given ID.
+func findVolumeDevice(devices []NVMeDevice, volumeID string) (string, error) {
+	if !IsVolumeID(volumeID) {
+		return "", fmt.Errorf("invalid EBS volume ID %q", volumeID)
+	}
+	want := NVMeDevice{Serial: volumeID}.VolumeID()
+
+	for _, d := range devices {
+		if d.VolumeID() == want {
+			return d.BSD, nil
+		}
+	}
+
+	return "", fmt.Errorf("no NVMe device found for EBS volume %s", want)
+}
@@ -0,0 +1,57 @@
+package ebs
+
+import (
+	_ "embed"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// nvmeDevices contains system_profiler output with two EBS volumes attached.
+//
+//go:embed testdata/nvme.plist
+var nvmeDevices string
+
+func TestIsVolumeID(t *testing.T) {
+	assert.True(t, IsVolumeID("vol-0123456789abcdef0"))
+	assert.True(t, IsVolumeID("vol0123456789abcdef0"))
+	assert.False(t, IsVolumeID("disk2"))
+	assert.False(t, IsVolumeID(""))
+}
+
+func TestDecodeNVMeDevices(t *testing.T) {
+	devices, err := decodeNVMeDevices(strings.NewReader(nvmeDevices))
+
+	assert.NoError(t, err, "should be able to decode system_profiler output")
+	assert.Len(t, devices, 2)
+	assert.Equal(t, "disk2", devices[1].BSD)
+	assert.Equal(t, "vol-0bbbbbbbbbbbbbbbb", devices[1].VolumeID())
+	assert.Equal(t, "No", devices[1].TRIM)
+}
+
+func TestDecodeNVMeDevices_WithoutPlistInput(t *testing.T) {
+	devices, err := decodeNVMeDevices(strings.NewReader("this is not a plist"))
+
+	assert.Error(t, err, "shouldn't be able to decode non-plist input")
+	assert.Nil(t, devices)
+}
+
+func TestFindVolumeDevice(t *testing.T) {
+	devices, err := decodeNVMeDevices(strings.NewReader(nvmeDevices))
+	assert.NoError(t, err)
+
+	id, err := findVolumeDevice(devices, "vol-0bbbbbbbbbbbbbbbb")
+	assert.NoError(t, err, "should find attached volume")
+	assert.Equal(t, "disk2", id)
+
+	id, err = findVolumeDevice(devices, "vol0aaaaaaaaaaaaaaaa")
+	assert.NoError(t, err, "should find attached volume without dash")
+	assert.Equal(t, "disk0", id)
+
+	_, err = findVolumeDevice(devices, "vol-0cccccccccccccccc")
+	assert.Error(t, err, "shouldn't find detached volume")
+
+	_, err = findVolumeDevice(devices, "disk2")
+	assert.Error(t, err, "shouldn't accept non-volume IDs")
+}
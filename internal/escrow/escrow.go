@@ -0,0 +1,186 @@
+// Package escrow provides the functionality necessary for keeping the passphrases of encrypted data volumes in AWS
+// Secrets Manager or Parameter Store so that the volumes can be unlocked when the host boots. Passphrases are stored
+// under names scoped to the instance and the volume's UUID (e.g. ec2-macos-utils/i-0123456789abcdef0/volumes/<uuid>),
+// which can be granted to the instance's role with a single resource pattern.
+package escrow
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+)
+
+const (
+	// passphraseBytes is the number of random bytes in generated passphrases.
+	passphraseBytes = 32
+	// namePrefix is the prefix of the names that passphrases are stored under.
+	namePrefix = "ec2-macos-utils"
+	// jobInterval is the time, in seconds, between runs of the persisted job so that volumes which couldn't be
+	// unlocked before the network was up, or that are attached after boot, are also unlocked.
+	jobInterval = 60
+)
+
+// JobLabel is the label of the launchd job that unlocks the encrypted volumes at boot.
+var JobLabel = launchd.Label("volume-unlock")
+
+// ErrNotFound is returned by Get when no passphrase is stored for the volume.
+var ErrNotFound = errors.New("escrow: passphrase not found")
+
+// Backend is the AWS service that passphrases are stored in.
+type Backend string
+
+const (
+	// BackendSecretsManager stores passphrases as Secrets Manager secrets.
+	BackendSecretsManager Backend = "secretsmanager"
+	// BackendParameterStore stores passphrases as Parameter Store SecureString parameters.
+	BackendParameterStore Backend = "ssm"
+)
+
+// ParseBackend finds the Backend matching s, ignoring case.
+func ParseBackend(s string) (Backend, error) {
+	for _, b := range []Backend{BackendSecretsManager, BackendParameterStore} {
+		if strings.EqualFold(s, string(b)) {
+			return b, nil
+		}
+	}
+
+	return "", fmt.Errorf("escrow: unknown backend %q, expected %s or %s", s, BackendSecretsManager, BackendParameterStore)
+}
+
+// Store keeps the passphrases of the instance's encrypted volumes by the volume's UUID.
+type Store interface {
+	// Put stores the passphrase of the volume with the UUID, replacing any stored before.
+	Put(ctx context.Context, uuid string, passphrase string) error
+	// Get fetches the passphrase of the volume with the UUID, ErrNotFound is returned when there isn't one.
+	Get(ctx context.Context, uuid string) (string, error)
+}
+
+// Client calls the AWS APIs that passphrases are stored with (e.g. *aws.Client).
+type Client interface {
+	CreateSecret(ctx context.Context, name string, description string, value string) error
+	PutSecretValue(ctx context.Context, secretID string, value string) error
+	GetSecretValue(ctx context.Context, secretID string) (string, error)
+	PutParameter(ctx context.Context, name string, description string, value string) error
+	GetParameter(ctx context.Context, name string) (string, error)
+}
+
+// New creates the Store for the backend which keeps the passphrases of the instance's volumes.
+func New(backend Backend, client Client, instanceID string) (Store, error) {
+	if instanceID == "" {
+		return nil, errors.New("escrow: instance ID required")
+	}
+
+	switch backend {
+	case BackendSecretsManager:
+		return &SecretsManager{Client: client, InstanceID: instanceID}, nil
+	case BackendParameterStore:
+		return &ParameterStore{Client: client, InstanceID: instanceID}, nil
+	default:
+		return nil, fmt.Errorf("escrow: unknown backend %q", backend)
+	}
+}
+
+// Name gets the name that the passphrase of the instance's volume with the UUID is stored under.
+func Name(instanceID string, uuid string) string {
+	return fmt.Sprintf("%s/%s/volumes/%s", namePrefix, instanceID, strings.ToUpper(uuid))
+}
+
+// NewPassphrase generates a random passphrase for encrypting a volume.
+func NewPassphrase() (string, error) {
+	b := make([]byte, passphraseBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("escrow: cannot generate passphrase: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Job creates the launchd job which runs the utility at the path to unlock the encrypted volumes with their
+// passphrases from the backend at boot, and then periodically to unlock volumes that weren't unlocked at boot.
+func Job(executable string, backend Backend) *launchd.Job {
+	return &launchd.Job{
+		Label:            JobLabel,
+		ProgramArguments: []string{executable, "volume", "unlock", "--escrow", string(backend)},
+		RunAtLoad:        true,
+		StartInterval:    jobInterval,
+	}
+}
+
+// description gets the description of the secret or parameter holding the volume's passphrase.
+func description(instanceID string, uuid string) string {
+	return fmt.Sprintf("Passphrase of encrypted volume %s on %s", strings.ToUpper(uuid), instanceID)
+}
+
+// SecretsManager keeps passphrases as Secrets Manager secrets.
+type SecretsManager struct {
+	// Client calls Secrets Manager.
+	Client Client
+	// InstanceID is the ID of the instance whose volumes' passphrases are kept.
+	InstanceID string
+}
+
+// Put stores the passphrase in the volume's secret, which is created when it doesn't exist.
+func (s *SecretsManager) Put(ctx context.Context, uuid string, passphrase string) error {
+	name := Name(s.InstanceID, uuid)
+	err := s.Client.CreateSecret(ctx, name, description(s.InstanceID, uuid), passphrase)
+	if errors.Is(err, aws.ErrSecretExists) {
+		err = s.Client.PutSecretValue(ctx, name, passphrase)
+	}
+	if err != nil {
+		return fmt.Errorf("escrow: %w", err)
+	}
+
+	return nil
+}
+
+// Get fetches the passphrase from the volume's secret.
+func (s *SecretsManager) Get(ctx context.Context, uuid string) (string, error) {
+	name := Name(s.InstanceID, uuid)
+	passphrase, err := s.Client.GetSecretValue(ctx, name)
+	if err != nil {
+		var apiErr *aws.APIError
+		if errors.As(err, &apiErr) && apiErr.Code == "ResourceNotFoundException" {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+
+		return "", fmt.Errorf("escrow: %w", err)
+	}
+
+	return passphrase, nil
+}
+
+// ParameterStore keeps passphrases as Parameter Store SecureString parameters.
+type ParameterStore struct {
+	// Client calls Parameter Store.
+	Client Client
+	// InstanceID is the ID of the instance whose volumes' passphrases are kept.
+	InstanceID string
+}
+
+// Put stores the passphrase in the volume's parameter.
+func (s *ParameterStore) Put(ctx context.Context, uuid string, passphrase string) error {
+	if err := s.Client.PutParameter(ctx, "/"+Name(s.InstanceID, uuid), description(s.InstanceID, uuid), passphrase); err != nil {
+		return fmt.Errorf("escrow: %w", err)
+	}
+
+	return nil
+}
+
+// Get fetches the passphrase from the volume's parameter.
+func (s *ParameterStore) Get(ctx context.Context, uuid string) (string, error) {
+	name := "/" + Name(s.InstanceID, uuid)
+	passphrase, err := s.Client.GetParameter(ctx, name)
+	if errors.Is(err, aws.ErrParameterNotFound) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	} else if err != nil {
+		return "", fmt.Errorf("escrow: %w", err)
+	}
+
+	return passphrase, nil
+}
@@ -0,0 +1,139 @@
+package escrow
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+)
+
+const (
+	testInstanceID = "i-0123456789abcdef0"
+	testUUID       = "6e3b3b8c-5f0a-4b1e-9d7a-2c1f0e9b8a7d"
+)
+
+// fakeClient keeps secrets and parameters in memory.
+type fakeClient struct {
+	secrets    map[string]string
+	parameters map[string]string
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{secrets: make(map[string]string), parameters: make(map[string]string)}
+}
+
+func (f *fakeClient) CreateSecret(ctx context.Context, name string, description string, value string) error {
+	if _, ok := f.secrets[name]; ok {
+		return aws.ErrSecretExists
+	}
+	f.secrets[name] = value
+
+	return nil
+}
+
+func (f *fakeClient) PutSecretValue(ctx context.Context, secretID string, value string) error {
+	f.secrets[secretID] = value
+
+	return nil
+}
+
+func (f *fakeClient) GetSecretValue(ctx context.Context, secretID string) (string, error) {
+	value, ok := f.secrets[secretID]
+	if !ok {
+		return "", &aws.APIError{StatusCode: 400, Code: "ResourceNotFoundException"}
+	}
+
+	return value, nil
+}
+
+func (f *fakeClient) PutParameter(ctx context.Context, name string, description string, value string) error {
+	f.parameters[name] = value
+
+	return nil
+}
+
+func (f *fakeClient) GetParameter(ctx context.Context, name string) (string, error) {
+	value, ok := f.parameters[name]
+	if !ok {
+		return "", aws.ErrParameterNotFound
+	}
+
+	return value, nil
+}
+
+func TestParseBackend(t *testing.T) {
+	b, err := ParseBackend("SSM")
+	assert.NoError(t, err)
+	assert.Equal(t, BackendParameterStore, b)
+
+	b, err = ParseBackend("secretsmanager")
+	assert.NoError(t, err)
+	assert.Equal(t, BackendSecretsManager, b)
+
+	_, err = ParseBackend("keychain")
+	assert.Error(t, err)
+}
+
+func TestName(t *testing.T) {
+	assert.Equal(t, "ec2-macos-utils/i-0123456789abcdef0/volumes/6E3B3B8C-5F0A-4B1E-9D7A-2C1F0E9B8A7D", Name(testInstanceID, testUUID))
+}
+
+func TestNewPassphrase(t *testing.T) {
+	a, err := NewPassphrase()
+	assert.NoError(t, err)
+	b, err := NewPassphrase()
+	assert.NoError(t, err)
+
+	assert.Len(t, a, 43, "passphrases should hold 32 random bytes")
+	assert.NotEqual(t, a, b, "passphrases should be random")
+}
+
+func TestSecretsManager(t *testing.T) {
+	client := newFakeClient()
+	s, err := New(BackendSecretsManager, client, testInstanceID)
+	assert.NoError(t, err)
+
+	_, err = s.Get(context.Background(), testUUID)
+	assert.True(t, errors.Is(err, ErrNotFound), "missing passphrases should be reported")
+
+	assert.NoError(t, s.Put(context.Background(), testUUID, "first"))
+	assert.NoError(t, s.Put(context.Background(), testUUID, "second"), "existing secrets should be replaced")
+	assert.Equal(t, "second", client.secrets[Name(testInstanceID, testUUID)])
+
+	passphrase, err := s.Get(context.Background(), testUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", passphrase)
+}
+
+func TestParameterStore(t *testing.T) {
+	client := newFakeClient()
+	s, err := New(BackendParameterStore, client, testInstanceID)
+	assert.NoError(t, err)
+
+	_, err = s.Get(context.Background(), testUUID)
+	assert.True(t, errors.Is(err, ErrNotFound), "missing passphrases should be reported")
+
+	assert.NoError(t, s.Put(context.Background(), testUUID, "secret"))
+	assert.Equal(t, "secret", client.parameters["/"+Name(testInstanceID, testUUID)], "parameter names should be absolute")
+
+	passphrase, err := s.Get(context.Background(), testUUID)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", passphrase)
+}
+
+func TestNew_NoInstanceID(t *testing.T) {
+	_, err := New(BackendSecretsManager, newFakeClient(), "")
+
+	assert.Error(t, err, "passphrases must be scoped to an instance")
+}
+
+func TestJob(t *testing.T) {
+	job := Job("/usr/local/bin/ec2-macos-utils", BackendParameterStore)
+
+	assert.Equal(t, JobLabel, job.Label)
+	assert.Equal(t, []string{"/usr/local/bin/ec2-macos-utils", "volume", "unlock", "--escrow", "ssm"}, job.ProgramArguments)
+	assert.True(t, job.RunAtLoad, "volumes should be unlocked at boot")
+}
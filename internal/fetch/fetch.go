@@ -0,0 +1,140 @@
+// Package fetch provides the functionality necessary for downloading configuration files, disk images, and scripts
+// from S3 with the instance's credentials and validating their checksums.
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+)
+
+// ErrChecksumMismatch is returned when a downloaded file's checksum doesn't match the expected checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ObjectGetter downloads objects from S3.
+type ObjectGetter interface {
+	// GetObject starts downloading the object with the key from the bucket.
+	GetObject(ctx context.Context, bucket, key string) (*aws.Object, error)
+}
+
+// Fetcher downloads files from S3 URIs (e.g. "s3://bucket/images/golden.dmg").
+type Fetcher struct {
+	// S3 downloads the objects. A client for the instance's region is created when it isn't set.
+	S3 ObjectGetter
+}
+
+// Fetch downloads the file at the S3 URI to the path. The file's SHA-256 checksum is validated against checksum,
+// which is the hex-encoded hash optionally prefixed with "sha256:", when it's set, and against the checksum that the
+// object was uploaded with when S3 has one for the whole object. The path is only written once the whole file has been downloaded and
+// validated so that interrupted downloads don't leave partial files behind.
+func (f *Fetcher) Fetch(ctx context.Context, uri, path, checksum string) error {
+	expected, err := ParseChecksum(checksum)
+	if err != nil {
+		return err
+	}
+	bucket, key, err := aws.ParseS3URI(uri)
+	if err != nil {
+		return err
+	}
+	if f.S3 == nil {
+		client, err := aws.NewClientFromMetadata(ctx)
+		if err != nil {
+			return fmt.Errorf("cannot create S3 client: %w", err)
+		}
+		f.S3 = client
+	}
+
+	object, err := f.S3.GetObject(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	defer object.Body.Close()
+
+	logrus.WithFields(logrus.Fields{
+		"uri":  uri,
+		"path": path,
+		"size": object.Size,
+	}).Info("Downloading file...")
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("cannot create %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(tmp, h), object.Body); err != nil {
+		return fmt.Errorf("cannot download %s: %w", uri, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("cannot write %s: %w", path, err)
+	}
+	if err = verify(uri, h, expected, fullObjectChecksum(object)); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("cannot write %s: %w", path, err)
+	}
+	logrus.WithField("path", path).Info("Successfully downloaded file")
+
+	return nil
+}
+
+// verify checks the downloaded file's hash against the expected checksum and the object's own base64-encoded
+// checksum, either of which may be empty.
+func verify(uri string, h hash.Hash, expected []byte, objectChecksum string) error {
+	sum := h.Sum(nil)
+	if expected != nil && string(sum) != string(expected) {
+		return fmt.Errorf("%s: %w: expected sha256:%s, got sha256:%s", uri, ErrChecksumMismatch, hex.EncodeToString(expected), hex.EncodeToString(sum))
+	}
+	if objectChecksum != "" && base64.StdEncoding.EncodeToString(sum) != objectChecksum {
+		return fmt.Errorf("%s: %w: S3 has checksum %s, got %s", uri, ErrChecksumMismatch, objectChecksum, base64.StdEncoding.EncodeToString(sum))
+	}
+
+	return nil
+}
+
+// fullObjectChecksum gets the object's checksum when it's the SHA-256 hash of the whole object. Objects uploaded in
+// parts have composite checksums of their parts' checksums (e.g. "<base64>-3"), which can't be compared with the
+// downloaded file's hash, so they're skipped along with checksums whose type S3 reports as anything else.
+func fullObjectChecksum(object *aws.Object) string {
+	if object.ChecksumType != "" && object.ChecksumType != "FULL_OBJECT" {
+		return ""
+	}
+	if i := strings.LastIndexByte(object.ChecksumSHA256, '-'); i >= 0 {
+		if _, err := strconv.Atoi(object.ChecksumSHA256[i+1:]); err == nil {
+			return ""
+		}
+	}
+
+	return object.ChecksumSHA256
+}
+
+// ParseChecksum parses the hex-encoded SHA-256 checksum, which may be prefixed with "sha256:". Empty checksums are
+// parsed as nil.
+func ParseChecksum(checksum string) ([]byte, error) {
+	if checksum == "" {
+		return nil, nil
+	}
+
+	sum, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(checksum), "sha256:"))
+	if err != nil || len(sum) != sha256.Size {
+		return nil, fmt.Errorf("invalid checksum %q: expected a hex-encoded SHA-256 hash", checksum)
+	}
+
+	return sum, nil
+}
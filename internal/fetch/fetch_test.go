@@ -0,0 +1,104 @@
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+)
+
+// fakeS3 serves a single object.
+type fakeS3 struct {
+	bucket, key, body, checksum, checksumType string
+}
+
+func (f fakeS3) GetObject(ctx context.Context, bucket, key string) (*aws.Object, error) {
+	if bucket != f.bucket || key != f.key {
+		return nil, errors.New("NoSuchKey")
+	}
+
+	return &aws.Object{Body: io.NopCloser(strings.NewReader(f.body)), Size: int64(len(f.body)), ChecksumSHA256: f.checksum, ChecksumType: f.checksumType}, nil
+}
+
+// sha256Hex gets the hex-encoded SHA-256 hash of the string.
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	f := &Fetcher{S3: fakeS3{bucket: "fleet", key: "macos/config.yaml", body: "setup: {}\n"}}
+
+	err := f.Fetch(context.Background(), "s3://fleet/macos/config.yaml", path, "sha256:"+sha256Hex("setup: {}\n"))
+
+	assert.NoError(t, err)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "setup: {}\n", string(data))
+}
+
+func TestFetcher_Fetch_ChecksumMismatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "golden.dmg")
+	f := &Fetcher{S3: fakeS3{bucket: "images", key: "golden.dmg", body: "corrupted"}}
+
+	err := f.Fetch(context.Background(), "s3://images/golden.dmg", path, sha256Hex("image"))
+
+	assert.True(t, errors.Is(err, ErrChecksumMismatch))
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries, "shouldn't leave the download behind")
+}
+
+func TestFetcher_Fetch_ObjectChecksum(t *testing.T) {
+	sum := sha256.Sum256([]byte("image"))
+	path := filepath.Join(t.TempDir(), "golden.dmg")
+
+	f := &Fetcher{S3: fakeS3{bucket: "images", key: "golden.dmg", body: "image", checksum: base64.StdEncoding.EncodeToString(sum[:])}}
+	assert.NoError(t, f.Fetch(context.Background(), "s3://images/golden.dmg", path, ""))
+
+	f = &Fetcher{S3: fakeS3{bucket: "images", key: "golden.dmg", body: "corrupted", checksum: base64.StdEncoding.EncodeToString(sum[:])}}
+	err := f.Fetch(context.Background(), "s3://images/golden.dmg", path, "")
+	assert.True(t, errors.Is(err, ErrChecksumMismatch))
+}
+
+func TestFetcher_Fetch_CompositeChecksum(t *testing.T) {
+	// Multipart uploads have a checksum of their parts' checksums, which isn't the file's hash.
+	sum := sha256.Sum256([]byte("part checksums"))
+	composite := base64.StdEncoding.EncodeToString(sum[:]) + "-3"
+	path := filepath.Join(t.TempDir(), "golden.dmg")
+
+	f := &Fetcher{S3: fakeS3{bucket: "images", key: "golden.dmg", body: "image", checksum: composite}}
+	assert.NoError(t, f.Fetch(context.Background(), "s3://images/golden.dmg", path, ""), "composite checksums should be skipped")
+
+	f = &Fetcher{S3: fakeS3{bucket: "images", key: "golden.dmg", body: "image", checksum: base64.StdEncoding.EncodeToString(sum[:]), checksumType: "COMPOSITE"}}
+	assert.NoError(t, f.Fetch(context.Background(), "s3://images/golden.dmg", path, ""), "composite checksums should be skipped")
+
+	f = &Fetcher{S3: fakeS3{bucket: "images", key: "golden.dmg", body: "image", checksum: composite}}
+	err := f.Fetch(context.Background(), "s3://images/golden.dmg", path, sha256Hex("other"))
+	assert.True(t, errors.Is(err, ErrChecksumMismatch), "the expected checksum should still be validated")
+}
+
+func TestParseChecksum(t *testing.T) {
+	sum, err := ParseChecksum("SHA256:" + strings.ToUpper(sha256Hex("image")))
+	assert.NoError(t, err)
+	assert.Equal(t, sha256Hex("image"), hex.EncodeToString(sum))
+
+	sum, err = ParseChecksum("")
+	assert.NoError(t, err)
+	assert.Nil(t, sum)
+
+	_, err = ParseChecksum("md5:abc")
+	assert.Error(t, err)
+}
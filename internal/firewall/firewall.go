@@ -0,0 +1,202 @@
+// Package firewall provides the functionality necessary for managing macOS's Application Firewall with the
+// socketfilterfw CLI.
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// SocketFilterPath is the path to the Application Firewall's CLI.
+const SocketFilterPath = "/usr/libexec/ApplicationFirewall/socketfilterfw"
+
+// Setting is a firewall setting managed with socketfilterfw's --get<name> and --set<name> flags.
+type Setting string
+
+const (
+	// GlobalState enables the firewall.
+	GlobalState Setting = "globalstate"
+	// StealthMode stops the system from responding to probes (e.g. ICMP pings) on closed ports.
+	StealthMode Setting = "stealthmode"
+	// BlockAll blocks all incoming connections other than those needed by basic internet services (e.g. DHCP).
+	BlockAll Setting = "blockall"
+)
+
+const (
+	// On is the value of enabled settings.
+	On = "on"
+	// Off is the value of disabled settings.
+	Off = "off"
+)
+
+// appPattern matches the lines listing an app's path in socketfilterfw's --listapps output (e.g. "1 :  /usr/bin/ruby").
+var appPattern = regexp.MustCompile(`^\s*\d+\s*:\s+(.*?)\s*$`)
+
+// OnOff gets the value socketfilterfw uses for enabled or disabled settings.
+func OnOff(enabled bool) string {
+	if enabled {
+		return On
+	}
+
+	return Off
+}
+
+// App is an application with a firewall rule.
+type App struct {
+	// Path is the path to the application.
+	Path string `json:"path"`
+	// Allowed indicates that the application may accept incoming connections.
+	Allowed bool `json:"allowed"`
+}
+
+// Status describes the state of the firewall.
+type Status struct {
+	// Enabled indicates that the firewall is on.
+	Enabled bool `json:"enabled"`
+	// StealthMode indicates that stealth mode is on.
+	StealthMode bool `json:"stealth_mode"`
+	// BlockAll indicates that all incoming connections are blocked.
+	BlockAll bool `json:"block_all"`
+	// Apps are the applications with firewall rules.
+	Apps []App `json:"apps"`
+}
+
+// GetStatus fetches the state of the firewall and its application rules.
+func GetStatus(ctx context.Context) (*Status, error) {
+	status := &Status{}
+	for s, v := range map[Setting]*bool{
+		GlobalState: &status.Enabled,
+		StealthMode: &status.StealthMode,
+		BlockAll:    &status.BlockAll,
+	} {
+		enabled, err := Get(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		*v = enabled
+	}
+
+	apps, err := ListApps(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status.Apps = apps
+
+	return status, nil
+}
+
+// Get fetches whether the setting is enabled.
+func Get(ctx context.Context, s Setting) (bool, error) {
+	// cmdGet represents the command used for executing socketfilterfw to get a setting.
+	//   * --get<setting> - get the state of the setting
+	cmdGet := []string{SocketFilterPath, "--get" + string(s)}
+
+	out, err := run(ctx, cmdGet, "get "+string(s))
+	if err != nil {
+		return false, err
+	}
+
+	return parseState(out)
+}
+
+// Set enables or disables the setting.
+func Set(ctx context.Context, s Setting, enabled bool) error {
+	// cmdSet represents the command used for executing socketfilterfw to set a setting.
+	//   * --set<setting> - set the setting to the following state
+	//   * on|off - the state of the setting
+	cmdSet := []string{SocketFilterPath, "--set" + string(s), OnOff(enabled)}
+	_, err := run(ctx, cmdSet, "set "+string(s))
+
+	return err
+}
+
+// ListApps fetches the applications with firewall rules.
+func ListApps(ctx context.Context) ([]App, error) {
+	// cmdList represents the command used for executing socketfilterfw to list the application rules.
+	//   * --listapps - list the applications with rules and whether they're allowed
+	cmdList := []string{SocketFilterPath, "--listapps"}
+
+	out, err := run(ctx, cmdList, "list apps")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseApps(out), nil
+}
+
+// AllowApp adds a rule allowing the application to accept incoming connections.
+func AllowApp(ctx context.Context, path string) error {
+	// cmdAdd represents the command used for executing socketfilterfw to add an application rule.
+	//   * --add <path> - add a rule for the application
+	cmdAdd := []string{SocketFilterPath, "--add", path}
+	if _, err := run(ctx, cmdAdd, "add app"); err != nil {
+		return err
+	}
+
+	// cmdUnblock represents the command used for executing socketfilterfw to allow an application.
+	//   * --unblockapp <path> - allow incoming connections for the application
+	cmdUnblock := []string{SocketFilterPath, "--unblockapp", path}
+	_, err := run(ctx, cmdUnblock, "allow app")
+
+	return err
+}
+
+// RemoveApp removes the application's rule.
+func RemoveApp(ctx context.Context, path string) error {
+	// cmdRemove represents the command used for executing socketfilterfw to remove an application rule.
+	//   * --remove <path> - remove the rule for the application
+	cmdRemove := []string{SocketFilterPath, "--remove", path}
+	_, err := run(ctx, cmdRemove, "remove app")
+
+	return err
+}
+
+// run executes the socketfilterfw command and returns its output.
+func run(ctx context.Context, c []string, action string) (string, error) {
+	out, err := util.ExecuteCommand(ctx, c, "", nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("firewall: failed to %s, stderr: [%s]: %w", action, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return out.Stdout, nil
+}
+
+// parseState parses whether a setting is enabled from socketfilterfw's output. The wording differs between
+// settings and macOS versions, for example:
+//
+//	Firewall is enabled. (State = 1)
+//	Stealth mode disabled
+//	Firewall stealth mode is on
+//	Block all DISABLED!
+func parseState(out string) (bool, error) {
+	s := strings.ToLower(strings.TrimSpace(out))
+	switch {
+	case strings.Contains(s, "disabled"), strings.Contains(s, "is off"), strings.Contains(s, "state = 0"):
+		return false, nil
+	case strings.Contains(s, "enabled"), strings.Contains(s, "is on"):
+		return true, nil
+	default:
+		return false, fmt.Errorf("firewall: unexpected output %q", strings.TrimSpace(out))
+	}
+}
+
+// parseApps parses the applications from socketfilterfw's --listapps output. Each application's path is followed by
+// a line with its rule (e.g. "( Allow incoming connections )").
+func parseApps(out string) []App {
+	var apps []App
+	for _, line := range strings.Split(out, "\n") {
+		if m := appPattern.FindStringSubmatch(line); m != nil {
+			apps = append(apps, App{Path: m[1]})
+			continue
+		}
+		if len(apps) > 0 && strings.Contains(line, "Allow incoming connections") {
+			apps[len(apps)-1].Allowed = true
+		}
+	}
+
+	return apps
+}
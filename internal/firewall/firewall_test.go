@@ -0,0 +1,57 @@
+package firewall
+
+import (
+	_ "embed"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listAppsOutput contains the application rules printed by socketfilterfw's --listapps flag.
+//
+//go:embed testdata/listapps.txt
+var listAppsOutput string
+
+func TestParseState(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    bool
+		wantErr bool
+	}{
+		{name: "firewall enabled", input: "Firewall is enabled. (State = 1)\n", want: true},
+		{name: "firewall disabled", input: "Firewall is disabled. (State = 0)\n", want: false},
+		{name: "stealth mode enabled", input: "Stealth mode enabled\n", want: true},
+		{name: "stealth mode off", input: "Firewall stealth mode is off\n", want: false},
+		{name: "block all disabled", input: "Block all DISABLED! \n", want: false},
+		{name: "block all enabled", input: "Firewall has block all state set to enabled.\n", want: true},
+		{name: "unexpected", input: "socketfilterfw: unknown option\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseState(tt.input)
+
+			assert.Equal(t, tt.want, got)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParseApps(t *testing.T) {
+	expected := []App{
+		{Path: "/Applications/Xcode.app", Allowed: true},
+		{Path: "/usr/local/bin/ruby", Allowed: false},
+	}
+
+	assert.Equal(t, expected, parseApps(listAppsOutput))
+	assert.Empty(t, parseApps("ALF: total number of apps = 0 \n"))
+}
+
+func TestOnOff(t *testing.T) {
+	assert.Equal(t, On, OnOff(true))
+	assert.Equal(t, Off, OnOff(false))
+}
@@ -0,0 +1,103 @@
+package firewall
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+const (
+	// appSettingPrefix prefixes the settings of application rules to tell them apart from the firewall's settings.
+	appSettingPrefix = "app:"
+
+	// allowed is the value of application rules allowing incoming connections.
+	allowed = "allowed"
+	// blocked is the value of application rules blocking incoming connections.
+	blocked = "blocked"
+)
+
+// Task applies the desired firewall settings. Settings that aren't set are left as they are on the system, as are
+// the rules of applications that aren't listed.
+type Task struct {
+	// Enabled enables or disables the firewall.
+	Enabled *bool
+	// StealthMode enables or disables stealth mode.
+	StealthMode *bool
+	// BlockAll enables or disables blocking all incoming connections.
+	BlockAll *bool
+	// AllowedApps are the paths to applications allowed to accept incoming connections.
+	AllowedApps []string
+}
+
+// Name identifies the task.
+func (t *Task) Name() string {
+	return "firewall"
+}
+
+// settings gets the desired state of the firewall's settings.
+func (t *Task) settings() map[Setting]*bool {
+	return map[Setting]*bool{
+		GlobalState: t.Enabled,
+		StealthMode: t.StealthMode,
+		BlockAll:    t.BlockAll,
+	}
+}
+
+// Check compares the system's firewall settings and application rules with the desired settings.
+func (t *Task) Check(ctx context.Context) ([]task.Change, error) {
+	current := map[string]string{}
+	desired := map[string]string{}
+	for s, v := range t.settings() {
+		if v == nil {
+			continue
+		}
+		enabled, err := Get(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		current[string(s)] = OnOff(enabled)
+		desired[string(s)] = OnOff(*v)
+	}
+
+	if len(t.AllowedApps) > 0 {
+		apps, err := ListApps(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, app := range apps {
+			state := blocked
+			if app.Allowed {
+				state = allowed
+			}
+			current[appSettingPrefix+app.Path] = state
+		}
+		for _, path := range t.AllowedApps {
+			desired[appSettingPrefix+path] = allowed
+		}
+	}
+
+	return task.Diff(current, desired), nil
+}
+
+// Apply changes the settings and application rules that differ from the desired settings.
+func (t *Task) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range changes {
+		if strings.HasPrefix(c.Setting, appSettingPrefix) {
+			if err := AllowApp(ctx, strings.TrimPrefix(c.Setting, appSettingPrefix)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := Set(ctx, Setting(c.Setting), c.Desired == On); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,220 @@
+// Package fixture provides the functionality necessary for recording the output of the commands run by the tool
+// wrappers (e.g. diskutil) to fixture files and replaying them in tests. Fixtures are kept per macOS release so that
+// changes in the tools' output between releases are caught without real hardware.
+//
+// Each fixture is a pair of files in its release's directory: <name>.json holds the command and how it exited, and
+// <name>.out holds its raw standard output so that changes to it are reviewed as plain diffs.
+package fixture
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aws/ec2-macos-utils/pkg/system"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// metadataExt is the extension of the files holding a fixture's command and exit status.
+	metadataExt = ".json"
+	// stdoutExt is the extension of the files holding a fixture's standard output.
+	stdoutExt = ".out"
+)
+
+// ErrNoFixture is returned when a command without a fixture is replayed.
+var ErrNoFixture = errors.New("fixture: no fixture recorded for command")
+
+// unsafeChars matches the characters that aren't kept in fixture file names.
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Fixture is a recorded command and its output.
+type Fixture struct {
+	// Command is the command that was run.
+	Command []string `json:"command"`
+	// ProductVersion is the version of macOS that the command was recorded on.
+	ProductVersion string `json:"productVersion"`
+	// ExitCode is the command's exit status.
+	ExitCode int `json:"exitCode"`
+	// Stderr is the command's standard error.
+	Stderr string `json:"stderr,omitempty"`
+	// Stdout is the command's standard output, which is stored in its own file.
+	Stdout string `json:"-"`
+}
+
+// ReleaseDir gets the name of the directory holding the fixtures for the release (e.g. "bigsur").
+func ReleaseDir(r system.Release) string {
+	return strings.ToLower(strings.ReplaceAll(r.String(), " ", ""))
+}
+
+// Name gets the name of the fixture's files, without their extension, derived from its command (e.g.
+// "diskutil_info_-plist_disk0"). The root path is named "root".
+func Name(c []string) string {
+	parts := make([]string, 0, len(c))
+	for _, arg := range c {
+		if arg == "/" {
+			arg = "root"
+		}
+		arg = strings.Trim(unsafeChars.ReplaceAllString(arg, "_"), "_")
+		if arg != "" {
+			parts = append(parts, arg)
+		}
+	}
+
+	return strings.Join(parts, "_")
+}
+
+// ExitError is returned when a replayed command exited with a non-zero status.
+type ExitError struct {
+	// Code is the command's exit status.
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Recorder is a util.Executor which records the commands run by another Executor to fixture files.
+type Recorder struct {
+	// Executor runs the commands.
+	Executor util.Executor
+	// Dir is the directory that the release's fixtures are written to.
+	Dir string
+	// Product is the product that the commands are run on.
+	Product system.Product
+
+	mu sync.Mutex
+}
+
+// NewRecorder creates a Recorder which records the commands run on the system to the release's directory in dir.
+func NewRecorder(dir string, p system.Product) *Recorder {
+	return &Recorder{Executor: util.SystemExecutor{}, Dir: filepath.Join(dir, ReleaseDir(p.Release)), Product: p}
+}
+
+// Execute runs the command and records its output. Failed commands are recorded too since callers rely on how
+// they fail. Errors writing the fixture are returned when the command itself succeeded.
+func (r *Recorder) Execute(ctx context.Context, c []string, opts ...util.Option) (util.CommandOutput, error) {
+	out, err := r.Executor.Execute(ctx, c, opts...)
+
+	f := Fixture{Command: c, ProductVersion: r.Product.Version.String(), Stdout: out.Stdout, Stderr: out.Stderr}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		f.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		// Commands that couldn't be run at all aren't worth replaying.
+		return out, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if writeErr := Write(r.Dir, f); writeErr != nil && err == nil {
+		return out, writeErr
+	}
+
+	return out, err
+}
+
+// Write writes the fixture's files to dir.
+func Write(dir string, f Fixture) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("fixture: cannot create %s: %w", dir, err)
+	}
+
+	metadata, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Join(dir, Name(f.Command))
+	if err := os.WriteFile(name+metadataExt, append(metadata, '\n'), 0o644); err != nil {
+		return fmt.Errorf("fixture: cannot write %s: %w", name+metadataExt, err)
+	}
+	if err := os.WriteFile(name+stdoutExt, []byte(f.Stdout), 0o644); err != nil {
+		return fmt.Errorf("fixture: cannot write %s: %w", name+stdoutExt, err)
+	}
+
+	return nil
+}
+
+// Load reads the fixtures in the directory of fsys.
+func Load(fsys fs.FS, dir string) ([]Fixture, error) {
+	matches, err := fs.Glob(fsys, path.Join(dir, "*"+metadataExt))
+	if err != nil {
+		return nil, err
+	}
+
+	var fixtures []Fixture
+	for _, m := range matches {
+		data, err := fs.ReadFile(fsys, m)
+		if err != nil {
+			return nil, err
+		}
+		var f Fixture
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("fixture: cannot decode %s: %w", m, err)
+		}
+
+		stdout, err := fs.ReadFile(fsys, strings.TrimSuffix(m, metadataExt)+stdoutExt)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		f.Stdout = string(stdout)
+
+		fixtures = append(fixtures, f)
+	}
+	if len(fixtures) == 0 {
+		return nil, fmt.Errorf("fixture: no fixtures found in %s", dir)
+	}
+
+	return fixtures, nil
+}
+
+// Replayer is a util.Executor which replays the output of recorded commands instead of running them.
+type Replayer struct {
+	fixtures map[string]Fixture
+}
+
+// NewReplayer creates a Replayer for the fixtures.
+func NewReplayer(fixtures []Fixture) *Replayer {
+	r := &Replayer{fixtures: make(map[string]Fixture, len(fixtures))}
+	for _, f := range fixtures {
+		r.fixtures[strings.Join(f.Command, "\x00")] = f
+	}
+
+	return r
+}
+
+// LoadReplayer creates a Replayer for the release's fixtures in fsys.
+func LoadReplayer(fsys fs.FS, r system.Release) (*Replayer, error) {
+	fixtures, err := Load(fsys, ReleaseDir(r))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewReplayer(fixtures), nil
+}
+
+// Execute replays the command's recorded output. Commands that exited with a non-zero status return an ExitError
+// and commands without fixtures return ErrNoFixture.
+func (r *Replayer) Execute(ctx context.Context, c []string, opts ...util.Option) (util.CommandOutput, error) {
+	f, ok := r.fixtures[strings.Join(c, "\x00")]
+	if !ok {
+		return util.CommandOutput{}, fmt.Errorf("%w: %s", ErrNoFixture, strings.Join(c, " "))
+	}
+
+	out := util.CommandOutput{Stdout: f.Stdout, Stderr: f.Stderr}
+	if f.ExitCode != 0 {
+		return out, &ExitError{Code: f.ExitCode}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,133 @@
+package fixture
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/pkg/system"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// fakeExecutor is a util.Executor which returns canned output.
+type fakeExecutor struct {
+	out util.CommandOutput
+	err error
+}
+
+func (f fakeExecutor) Execute(ctx context.Context, c []string, opts ...util.Option) (util.CommandOutput, error) {
+	return f.out, f.err
+}
+
+func TestName(t *testing.T) {
+	assert.Equal(t, "diskutil_info_-plist_root", Name([]string{"diskutil", "info", "-plist", "/"}))
+	assert.Equal(t, "diskutil_info_-plist_dev_disk0", Name([]string{"diskutil", "info", "-plist", "/dev/disk0"}))
+	assert.Equal(t, "diskutil_list_-plist", Name([]string{"diskutil", "list", "-plist"}))
+}
+
+func TestReleaseDir(t *testing.T) {
+	assert.Equal(t, "mojave", ReleaseDir(system.Mojave))
+	assert.Equal(t, "bigsur", ReleaseDir(system.BigSur))
+}
+
+func TestRecorder_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	p := system.Product{Release: system.Ventura, Version: *semver.MustParse("13.6.1")}
+	c := []string{"diskutil", "info", "-plist", "/"}
+
+	r := NewRecorder(dir, p)
+	r.Executor = fakeExecutor{out: util.CommandOutput{Stdout: "<plist/>\n"}}
+	out, err := r.Execute(context.Background(), c)
+	assert.NoError(t, err)
+	assert.Equal(t, "<plist/>\n", out.Stdout)
+
+	stdout, err := os.ReadFile(filepath.Join(dir, "ventura", "diskutil_info_-plist_root.out"))
+	assert.NoError(t, err)
+	assert.Equal(t, "<plist/>\n", string(stdout))
+
+	replayer, err := LoadReplayer(os.DirFS(dir), system.Ventura)
+	assert.NoError(t, err)
+	out, err = replayer.Execute(context.Background(), c)
+	assert.NoError(t, err)
+	assert.Equal(t, "<plist/>\n", out.Stdout)
+}
+
+func TestRecorder_RecordsExitStatus(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+	var wantErr *exec.ExitError
+	if !errors.As(exitErr, &wantErr) {
+		t.Skip("sh is not available")
+	}
+
+	dir := t.TempDir()
+	c := []string{"diskutil", "info", "-plist", "disk9"}
+	r := &Recorder{Executor: fakeExecutor{out: util.CommandOutput{Stderr: "Could not find disk: disk9"}, err: exitErr}, Dir: dir}
+	_, err := r.Execute(context.Background(), c)
+	assert.Equal(t, exitErr, err)
+
+	fixtures, err := Load(os.DirFS(dir), ".")
+	assert.NoError(t, err)
+	assert.Len(t, fixtures, 1)
+	assert.Equal(t, 3, fixtures[0].ExitCode)
+
+	_, err = NewReplayer(fixtures).Execute(context.Background(), c)
+	var replayErr *ExitError
+	assert.True(t, errors.As(err, &replayErr))
+	assert.Equal(t, 3, replayErr.Code)
+}
+
+func TestRecorder_SkipsCommandsThatCannotRun(t *testing.T) {
+	dir := t.TempDir()
+	r := &Recorder{Executor: fakeExecutor{err: exec.ErrNotFound}, Dir: dir}
+
+	_, err := r.Execute(context.Background(), []string{"diskutil", "list"})
+	assert.True(t, errors.Is(err, exec.ErrNotFound))
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestReplayer_NoFixture(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sonoma/diskutil_list_-plist.json": {Data: []byte(`{"command":["diskutil","list","-plist"],"exitCode":0}`)},
+		"sonoma/diskutil_list_-plist.out":  {Data: []byte("<plist/>")},
+	}
+
+	r, err := LoadReplayer(fsys, system.Sonoma)
+	assert.NoError(t, err)
+
+	_, err = r.Execute(context.Background(), []string{"diskutil", "info", "-plist", "disk0"})
+	assert.True(t, errors.Is(err, ErrNoFixture))
+}
+
+func TestLoad_Empty(t *testing.T) {
+	_, err := Load(fstest.MapFS{}, "mojave")
+	assert.Error(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	assert.Empty(t, Diff("a\nb\n", "a\nb\n"))
+	assert.Equal(t, "-b\n+c\n", Diff("a\nb\n", "a\nc\n"))
+	assert.Equal(t, "+b\n", Diff("a\nc\n", "a\nb\nc\n"))
+}
+
+func TestAssertGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "golden", "value.json")
+	v := map[string]int{"size": 1}
+
+	AssertGolden(t, path, v, true)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"size\": 1\n}\n", string(data))
+
+	AssertGolden(t, path, v, false)
+}
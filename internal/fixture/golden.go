@@ -0,0 +1,87 @@
+package fixture
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// AssertGolden compares the JSON serialization of v with the golden file at path. When update is set, the golden
+// file is written instead so that changes to the decoded types are reviewed as changes to the golden files.
+func AssertGolden(t testing.TB, path string, v interface{}, update bool) {
+	t.Helper()
+
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatalf("cannot encode value for golden file %s: %v", path, err)
+	}
+	got = append(got, '\n')
+
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read golden file %s (run the tests with -update to create it): %v", path, err)
+	}
+	if d := Diff(string(want), string(got)); d != "" {
+		t.Errorf("decoded value differs from golden file %s (run the tests with -update to accept it):\n%s", path, d)
+	}
+}
+
+// Diff gets a line by line diff of want and got, with removed lines prefixed by "-" and added lines by "+". It
+// returns an empty string when they're the same.
+func Diff(want, got string) string {
+	if want == got {
+		return ""
+	}
+
+	a := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			fmt.Fprintf(&sb, "+%s\n", b[j])
+			j++
+		default:
+			fmt.Fprintf(&sb, "-%s\n", a[i])
+			i++
+		}
+	}
+
+	return sb.String()
+}
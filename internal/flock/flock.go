@@ -0,0 +1,11 @@
+// Package flock provides the advisory file locks (flock(2)) that keep the utility's processes from changing the same
+// state (e.g. the telemetry counters, the filesystem table, or disks) at the same time. Locks are held by the open
+// file, so files opened separately don't share them even within a process.
+package flock
+
+import (
+	"errors"
+)
+
+// ErrWouldBlock is returned by TryLock when the lock is held by another open file.
+var ErrWouldBlock = errors.New("flock: lock is held")
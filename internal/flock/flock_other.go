@@ -0,0 +1,22 @@
+//go:build !unix
+
+package flock
+
+import (
+	"os"
+)
+
+// Lock isn't supported outside of Unix systems, the lock is always taken without excluding other processes.
+func Lock(f *os.File) error {
+	return nil
+}
+
+// TryLock isn't supported outside of Unix systems, the lock is always taken without excluding other processes.
+func TryLock(f *os.File) error {
+	return nil
+}
+
+// Unlock isn't supported outside of Unix systems.
+func Unlock(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package flock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTryLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	held, err := os.Create(path)
+	assert.NoError(t, err)
+	defer held.Close()
+	other, err := os.Open(path)
+	assert.NoError(t, err)
+	defer other.Close()
+
+	assert.NoError(t, Lock(held))
+	assert.True(t, errors.Is(TryLock(other), ErrWouldBlock), "shouldn't take the held lock")
+
+	assert.NoError(t, Unlock(held))
+	assert.NoError(t, TryLock(other), "should take the released lock")
+	assert.NoError(t, Unlock(other))
+}
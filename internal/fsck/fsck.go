@@ -0,0 +1,154 @@
+// Package fsck provides the functionality necessary for checking and repairing APFS volumes with macOS's fsck_apfs
+// tool and reporting the outcome as a typed verdict.
+package fsck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// FsckAPFSPath is the path to macOS's fsck_apfs tool.
+const FsckAPFSPath = "/sbin/fsck_apfs"
+
+// Verdict is the outcome of checking a filesystem.
+type Verdict string
+
+const (
+	// VerdictClean means that no problems were found.
+	VerdictClean Verdict = "clean"
+	// VerdictRepaired means that problems were found and all of them were repaired.
+	VerdictRepaired Verdict = "repaired"
+	// VerdictCorrupt means that problems were found but weren't repaired since repairs weren't requested.
+	VerdictCorrupt Verdict = "corrupt"
+	// VerdictUnrepairable means that problems were found and couldn't be repaired.
+	VerdictUnrepairable Verdict = "unrepairable"
+)
+
+// Mode is the way fsck_apfs is run.
+type Mode string
+
+const (
+	// ModeCheck checks an unmounted filesystem without changing it.
+	ModeCheck Mode = "check"
+	// ModeLive checks a mounted filesystem without changing it, using a snapshot of the volume.
+	ModeLive Mode = "live"
+	// ModeRepair checks an unmounted filesystem and repairs the problems found.
+	ModeRepair Mode = "repair"
+)
+
+// ModeFor gets the appropriate Mode for checking a filesystem. Mounted filesystems can only be checked live since
+// fsck_apfs can't repair them.
+func ModeFor(mounted bool, repair bool) (Mode, error) {
+	switch {
+	case repair && mounted:
+		return "", errors.New("fsck: mounted filesystems can't be repaired")
+	case repair:
+		return ModeRepair, nil
+	case mounted:
+		return ModeLive, nil
+	default:
+		return ModeCheck, nil
+	}
+}
+
+// Result is the outcome of checking a filesystem.
+type Result struct {
+	// Device is the device node of the filesystem that was checked.
+	Device string `json:"device"`
+	// Mode is the way the filesystem was checked.
+	Mode Mode `json:"mode"`
+	// Verdict is the outcome of the check.
+	Verdict Verdict `json:"verdict"`
+	// Problems are the errors and warnings reported while checking.
+	Problems []string `json:"problems"`
+	// ExitCode is fsck_apfs's exit status.
+	ExitCode int `json:"exitCode"`
+}
+
+// Check runs fsck_apfs on the device in the given mode. Each phase of the check (e.g. "Checking the object map") is
+// passed to progress as it starts, when progress is set. An error is only returned when fsck_apfs can't be run, the
+// problems it finds are reported in the Result.
+func Check(ctx context.Context, device string, mode Mode, progress func(phase string)) (*Result, error) {
+	// cmdFsck represents the command used for executing macOS's fsck_apfs to check a filesystem.
+	//   * -n - never open the filesystem for writing
+	//   * -l - check a mounted filesystem live, using a snapshot
+	//   * -y - repair the problems found without prompting
+	//   * device - the device node of the filesystem to check
+	cmdFsck := []string{FsckAPFSPath}
+	switch mode {
+	case ModeCheck:
+		cmdFsck = append(cmdFsck, "-n")
+	case ModeLive:
+		cmdFsck = append(cmdFsck, "-n", "-l")
+	case ModeRepair:
+		cmdFsck = append(cmdFsck, "-y")
+	default:
+		return nil, fmt.Errorf("fsck: unsupported mode %q", mode)
+	}
+	cmdFsck = append(cmdFsck, device)
+
+	out, err := util.ExecuteCommandLines(ctx, cmdFsck, "", nil, func(line string) {
+		if phase, ok := parsePhase(line); ok && progress != nil {
+			progress(phase)
+		}
+	})
+	exitCode := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || ctx.Err() != nil {
+			return nil, fmt.Errorf("fsck: failed to check %s, stderr: [%s]: %w", device, strings.TrimSpace(out.Stderr), err)
+		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	result := parseResult(out.Stdout+"\n"+out.Stderr, mode, exitCode)
+	result.Device = device
+
+	return result, nil
+}
+
+// parsePhase parses the phase from fsck_apfs's progress lines (e.g. "** Checking the object map.").
+func parsePhase(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "** Checking") {
+		return "", false
+	}
+
+	return strings.TrimSuffix(strings.TrimSpace(strings.TrimPrefix(line, "**")), "."), true
+}
+
+// parseResult parses the problems and verdict from fsck_apfs's output. The exit status decides whether the check
+// passed while the summary lines tell repaired filesystems apart from clean ones.
+func parseResult(out string, mode Mode, exitCode int) *Result {
+	result := &Result{Mode: mode, ExitCode: exitCode, Problems: []string{}}
+
+	repaired := false
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		lower := strings.ToLower(line)
+		switch {
+		case strings.HasPrefix(lower, "error:"), strings.HasPrefix(lower, "warning:"):
+			result.Problems = append(result.Problems, line)
+		case strings.HasPrefix(line, "**") && strings.Contains(lower, "was repaired successfully"):
+			repaired = true
+		}
+	}
+
+	switch {
+	case exitCode == 0 && repaired:
+		result.Verdict = VerdictRepaired
+	case exitCode == 0:
+		result.Verdict = VerdictClean
+	case mode == ModeRepair:
+		result.Verdict = VerdictUnrepairable
+	default:
+		result.Verdict = VerdictCorrupt
+	}
+
+	return result
+}
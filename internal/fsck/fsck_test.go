@@ -0,0 +1,90 @@
+package fsck
+
+import (
+	_ "embed"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	// cleanOutput contains the output of fsck_apfs for a volume without problems.
+	//
+	//go:embed testdata/clean.txt
+	cleanOutput string
+
+	// repairedOutput contains the output of fsck_apfs for a volume whose problems were repaired.
+	//
+	//go:embed testdata/repaired.txt
+	repairedOutput string
+
+	// corruptOutput contains the output of fsck_apfs for a volume with problems that weren't repaired.
+	//
+	//go:embed testdata/corrupt.txt
+	corruptOutput string
+)
+
+func TestModeFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		mounted bool
+		repair  bool
+		want    Mode
+		wantErr bool
+	}{
+		{name: "unmounted", want: ModeCheck},
+		{name: "mounted", mounted: true, want: ModeLive},
+		{name: "repair", repair: true, want: ModeRepair},
+		{name: "repair mounted", mounted: true, repair: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ModeFor(tt.mounted, tt.repair)
+
+			assert.Equal(t, tt.want, got)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParsePhase(t *testing.T) {
+	got, ok := parsePhase("** Checking the object map.")
+	assert.True(t, ok)
+	assert.Equal(t, "Checking the object map", got)
+
+	_, ok = parsePhase("   Checking the checkpoint with transaction ID 148722.")
+	assert.False(t, ok, "should ignore detail lines")
+
+	_, ok = parsePhase("** The container /dev/disk3 appears to be OK.")
+	assert.False(t, ok, "should ignore summary lines")
+}
+
+func TestParseResult(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		mode         Mode
+		exitCode     int
+		wantVerdict  Verdict
+		wantProblems int
+	}{
+		{name: "clean", input: cleanOutput, mode: ModeCheck, wantVerdict: VerdictClean},
+		{name: "repaired", input: repairedOutput, mode: ModeRepair, wantVerdict: VerdictRepaired, wantProblems: 2},
+		{name: "corrupt", input: corruptOutput, mode: ModeLive, exitCode: 8, wantVerdict: VerdictCorrupt, wantProblems: 1},
+		{name: "unrepairable", input: corruptOutput, mode: ModeRepair, exitCode: 8, wantVerdict: VerdictUnrepairable, wantProblems: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseResult(tt.input, tt.mode, tt.exitCode)
+
+			assert.Equal(t, tt.wantVerdict, got.Verdict)
+			assert.Len(t, got.Problems, tt.wantProblems)
+			assert.Equal(t, tt.exitCode, got.ExitCode)
+			assert.Equal(t, tt.mode, got.Mode)
+		})
+	}
+}
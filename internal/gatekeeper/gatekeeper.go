@@ -0,0 +1,150 @@
+// Package gatekeeper provides the functionality necessary for querying and adjusting Gatekeeper's assessment of
+// software with macOS's spctl CLI.
+package gatekeeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// ErrConfirmationRequired is returned when macOS requires the change to be confirmed in System Settings, which is
+// the case for disabling assessments on macOS Sequoia and later.
+var ErrConfirmationRequired = errors.New("gatekeeper: change must be confirmed in System Settings")
+
+// notarizedSource is the assessment source of software notarized by Apple.
+const notarizedSource = "Notarized Developer ID"
+
+// AssessmentType is the kind of operation that software is assessed for.
+type AssessmentType string
+
+const (
+	// AssessExecute assesses applications and tools for being run.
+	AssessExecute AssessmentType = "execute"
+	// AssessInstall assesses installer packages for being installed.
+	AssessInstall AssessmentType = "install"
+)
+
+// TypeForPath selects the assessment type for the software at path based on its extension.
+func TypeForPath(path string) AssessmentType {
+	if strings.EqualFold(filepath.Ext(path), ".pkg") {
+		return AssessInstall
+	}
+
+	return AssessExecute
+}
+
+// Assessment is Gatekeeper's verdict on a piece of software.
+type Assessment struct {
+	// Path is the path to the software.
+	Path string `json:"path"`
+	// Accepted indicates that Gatekeeper allows the software.
+	Accepted bool `json:"accepted"`
+	// Source is the rule that matched the software (e.g. "Notarized Developer ID" or "no usable signature").
+	Source string `json:"source"`
+	// Origin is the signing authority of the software, when it's signed.
+	Origin string `json:"origin,omitempty"`
+	// Notarized indicates that the software was accepted as notarized by Apple.
+	Notarized bool `json:"notarized"`
+}
+
+// Enabled checks if Gatekeeper assessments are enabled.
+func Enabled(ctx context.Context) (bool, error) {
+	// cmdStatus represents the command used for executing macOS's spctl to get the assessment status.
+	//   * --status - print whether assessments are enabled
+	cmdStatus := []string{"spctl", "--status"}
+
+	// spctl exits with a non-zero status when assessments are disabled so only the output is checked.
+	out, err := util.ExecuteCommand(ctx, cmdStatus, "", nil, nil)
+	switch status := strings.TrimSpace(out.Stdout + out.Stderr); {
+	case strings.Contains(status, "assessments enabled"):
+		return true, nil
+	case strings.Contains(status, "assessments disabled"):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("gatekeeper: failed to get status, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	default:
+		return false, fmt.Errorf("gatekeeper: unexpected status %q", status)
+	}
+}
+
+// SetEnabled enables or disables Gatekeeper assessments. ErrConfirmationRequired is returned when macOS won't allow
+// the change without confirmation in System Settings.
+func SetEnabled(ctx context.Context, enabled bool) error {
+	// cmdMaster represents the command used for executing macOS's spctl to change the assessment status.
+	//   * --master-enable|--master-disable - enable or disable assessments for all software
+	cmdMaster := []string{"spctl", "--master-disable"}
+	if enabled {
+		cmdMaster = []string{"spctl", "--master-enable"}
+	}
+
+	out, err := util.ExecuteCommand(ctx, cmdMaster, "", nil, nil)
+	if strings.Contains(out.Stdout+out.Stderr, "confirmed in System Settings") {
+		return ErrConfirmationRequired
+	} else if err != nil {
+		return fmt.Errorf("gatekeeper: failed to change status, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// Assess asks Gatekeeper whether the software at path is allowed for the assessment type. Rejected software isn't
+// an error, only failing to get a verdict is.
+func Assess(ctx context.Context, path string, t AssessmentType) (*Assessment, error) {
+	// cmdAssess represents the command used for executing macOS's spctl to assess software.
+	//   * --assess - assess the software at the path
+	//   * --type <type> - the operation to assess the software for (execute or install)
+	//   * -vv - print the source and origin of the verdict
+	cmdAssess := []string{"spctl", "--assess", "--type", string(t), "-vv", path}
+
+	// spctl prints its verdict on stderr and exits with a non-zero status when the software is rejected.
+	out, err := util.ExecuteCommand(ctx, cmdAssess, "", nil, nil)
+	a, parseErr := parseAssessment(out.Stderr)
+	if parseErr != nil {
+		if err != nil {
+			return nil, fmt.Errorf("gatekeeper: failed to assess %s, stderr: [%s]: %w", path, strings.TrimSpace(out.Stderr), err)
+		}
+		return nil, parseErr
+	}
+
+	return a, nil
+}
+
+// parseAssessment parses the verdict printed by spctl's --assess command, for example:
+//
+//	/Applications/Xcode.app: accepted
+//	source=Apple Mac OS Application Signing
+//	origin=Apple Mac OS Application Signing
+func parseAssessment(out string) (*Assessment, error) {
+	a := &Assessment{}
+	found := false
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "source="):
+			a.Source = strings.TrimPrefix(line, "source=")
+		case strings.HasPrefix(line, "origin="):
+			a.Origin = strings.TrimPrefix(line, "origin=")
+		case strings.HasSuffix(line, ": accepted"):
+			a.Path, a.Accepted, found = strings.TrimSuffix(line, ": accepted"), true, true
+		case strings.HasSuffix(line, ": rejected"):
+			a.Path, a.Accepted, found = strings.TrimSuffix(line, ": rejected"), false, true
+		case strings.Contains(line, ": rejected ("):
+			// Some rejections explain themselves inline (e.g. "/path: rejected (the code is valid but does not
+			// seem to be an app)").
+			idx := strings.Index(line, ": rejected (")
+			a.Path, found = line[:idx], true
+			a.Source = strings.TrimSuffix(line[idx+len(": rejected ("):], ")")
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("gatekeeper: unexpected assessment %q", strings.TrimSpace(out))
+	}
+	a.Notarized = a.Accepted && a.Source == notarizedSource
+
+	return a, nil
+}
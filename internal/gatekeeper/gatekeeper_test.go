@@ -0,0 +1,88 @@
+package gatekeeper
+
+import (
+	_ "embed"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	// assessAccepted contains the verdict printed by spctl for software signed by Apple.
+	//
+	//go:embed testdata/assess_accepted.txt
+	assessAccepted string
+
+	// assessNotarized contains the verdict printed by spctl for notarized software.
+	//
+	//go:embed testdata/assess_notarized.txt
+	assessNotarized string
+
+	// assessRejected contains the verdict printed by spctl for unsigned software.
+	//
+	//go:embed testdata/assess_rejected.txt
+	assessRejected string
+)
+
+func TestParseAssessment(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    *Assessment
+		wantErr bool
+	}{
+		{
+			name:  "accepted",
+			input: assessAccepted,
+			want: &Assessment{
+				Path:     "/Applications/Xcode.app",
+				Accepted: true,
+				Source:   "Apple Mac OS Application Signing",
+				Origin:   "Apple Mac OS Application Signing",
+			},
+		},
+		{
+			name:  "notarized",
+			input: assessNotarized,
+			want: &Assessment{
+				Path:      "/Applications/Tool.app",
+				Accepted:  true,
+				Source:    "Notarized Developer ID",
+				Origin:    "Developer ID Application: Example Corp (ABCDE12345)",
+				Notarized: true,
+			},
+		},
+		{
+			name:  "rejected",
+			input: assessRejected,
+			want:  &Assessment{Path: "/usr/local/bin/tool", Source: "no usable signature"},
+		},
+		{
+			name:  "rejected inline",
+			input: "/usr/bin/true: rejected (the code is valid but does not seem to be an app)\n",
+			want:  &Assessment{Path: "/usr/bin/true", Source: "the code is valid but does not seem to be an app"},
+		},
+		{
+			name:    "unexpected",
+			input:   "/missing: No such file or directory\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAssessment(tt.input)
+
+			assert.Equal(t, tt.want, got)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTypeForPath(t *testing.T) {
+	assert.Equal(t, AssessInstall, TypeForPath("/tmp/Tool.PKG"))
+	assert.Equal(t, AssessExecute, TypeForPath("/Applications/Xcode.app"))
+}
@@ -0,0 +1,275 @@
+// Package hdiutil provides the functionality necessary for managing disk images (e.g. sparse bundles) with macOS's
+// hdiutil CLI.
+package hdiutil
+
+//go:generate mockgen -destination mocks/mock_hdiutil.go github.com/aws/ec2-macos-utils/internal/hdiutil HDIUtil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"howett.net/plist"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// ImageType is a format of disk image that hdiutil can create.
+type ImageType string
+
+const (
+	// TypeSparseBundle is a sparse image stored as a bundle of band files which grows as data is written.
+	TypeSparseBundle ImageType = "SPARSEBUNDLE"
+	// TypeSparse is a sparse image stored as a single file which grows as data is written.
+	TypeSparse ImageType = "SPARSE"
+	// TypeUDIF is a read-write image with a fixed size.
+	TypeUDIF ImageType = "UDIF"
+)
+
+// ParseImageType finds the ImageType matching s, ignoring case.
+func ParseImageType(s string) (ImageType, error) {
+	for _, t := range []ImageType{TypeSparseBundle, TypeSparse, TypeUDIF} {
+		if strings.EqualFold(string(t), strings.TrimSpace(s)) {
+			return t, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported image type %q", s)
+}
+
+// CreateOptions configures the disk image created by Create.
+type CreateOptions struct {
+	// Size is the maximum size of the image (e.g. 100g).
+	Size string
+	// Type is the format of the image, defaulting to TypeSparseBundle.
+	Type ImageType
+	// Filesystem is the filesystem of the image's volume (e.g. APFS), defaulting to APFS.
+	Filesystem string
+	// VolumeName is the name of the image's volume.
+	VolumeName string
+}
+
+// AttachOptions configures how Attach attaches a disk image.
+type AttachOptions struct {
+	// MountPoint is the path to mount the image's volume at. The volume is mounted in /Volumes when empty.
+	MountPoint string
+	// ReadOnly attaches the image without allowing writes.
+	ReadOnly bool
+}
+
+// Entity is a device created for an attached disk image (e.g. the whole disk and its volumes).
+type Entity struct {
+	// ContentHint is the partition type or filesystem of the device.
+	ContentHint string `plist:"content-hint" json:"contentHint,omitempty"`
+	// DevEntry is the device node (e.g. /dev/disk4s1).
+	DevEntry string `plist:"dev-entry" json:"devEntry"`
+	// MountPoint is where the device's volume is mounted, if it was.
+	MountPoint string `plist:"mount-point" json:"mountPoint,omitempty"`
+	// VolumeKind is the filesystem of the mounted volume (e.g. apfs).
+	VolumeKind string `plist:"volume-kind" json:"volumeKind,omitempty"`
+}
+
+// Attachment describes the devices created for an attached disk image.
+type Attachment struct {
+	// Entities are the devices created for the image.
+	Entities []Entity `plist:"system-entities" json:"entities"`
+}
+
+// Device gets the whole disk device node of the attachment, which is used to detach it. The whole disk is the
+// device with the shortest node since partitions are named after it (e.g. /dev/disk4 and /dev/disk4s1).
+func (a *Attachment) Device() string {
+	var dev string
+	for _, e := range a.Entities {
+		if dev == "" || len(e.DevEntry) < len(dev) {
+			dev = e.DevEntry
+		}
+	}
+
+	return dev
+}
+
+// MountPoint gets the mount point of the attachment's first mounted volume.
+func (a *Attachment) MountPoint() string {
+	for _, e := range a.Entities {
+		if e.MountPoint != "" {
+			return e.MountPoint
+		}
+	}
+
+	return ""
+}
+
+// Image is a disk image that's attached to the system.
+type Image struct {
+	// Path is the path to the image.
+	Path string `plist:"image-path" json:"path"`
+	// Entities are the devices created for the image.
+	Entities []Entity `plist:"system-entities" json:"entities"`
+}
+
+// HDIUtil outlines the functionality necessary for wrapping macOS's hdiutil tool.
+type HDIUtil interface {
+	// Create creates a disk image at path with a single volume and returns the path of the created image, which
+	// includes the extension added for the image type (e.g. .sparsebundle).
+	Create(ctx context.Context, path string, opts CreateOptions) (string, error)
+	// Attach attaches the disk image at path and mounts its volumes.
+	Attach(ctx context.Context, path string, opts AttachOptions) (*Attachment, error)
+	// Detach unmounts and detaches the disk image with the given device node or mount point.
+	Detach(ctx context.Context, device string, force bool) error
+	// Resize changes the maximum size of the disk image at path (e.g. to 200g).
+	Resize(ctx context.Context, path string, size string) error
+	// Info fetches the disk images that are attached to the system.
+	Info(ctx context.Context) ([]Image, error)
+}
+
+// HDIUtilCmd is an empty struct that provides the implementation for the HDIUtil interface.
+type HDIUtilCmd struct{}
+
+// Type assertion to ensure HDIUtilCmd implements the HDIUtil interface.
+var _ HDIUtil = (*HDIUtilCmd)(nil)
+
+// Create uses the macOS hdiutil create command to create a disk image with a single volume.
+func (h *HDIUtilCmd) Create(ctx context.Context, path string, opts CreateOptions) (string, error) {
+	if opts.Size == "" {
+		return "", errors.New("hdiutil: image size is required")
+	}
+	if opts.Type == "" {
+		opts.Type = TypeSparseBundle
+	}
+	if opts.Filesystem == "" {
+		opts.Filesystem = "APFS"
+	}
+
+	// cmdCreate represents the command used for executing macOS's hdiutil to create a disk image.
+	//   * create - create a new image
+	//   * -size <size> - the maximum size of the image
+	//   * -type <type> - the format of the image
+	//   * -fs <filesystem> - the filesystem of the image's volume
+	//   * -volname <name> - the name of the image's volume
+	//   * -plist - print the created image's path in the plist format
+	cmdCreate := []string{"hdiutil", "create", "-size", opts.Size, "-type", string(opts.Type), "-fs", opts.Filesystem}
+	if opts.VolumeName != "" {
+		cmdCreate = append(cmdCreate, "-volname", opts.VolumeName)
+	}
+	cmdCreate = append(cmdCreate, "-plist", path)
+
+	out, err := util.ExecuteCommand(ctx, cmdCreate, "", nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("hdiutil: failed to create image, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return decodeCreate(out.Stdout)
+}
+
+// Attach uses the macOS hdiutil attach command to attach a disk image. Volumes aren't shown in the Finder since
+// images are attached for use by tools rather than people.
+func (h *HDIUtilCmd) Attach(ctx context.Context, path string, opts AttachOptions) (*Attachment, error) {
+	// cmdAttach represents the command used for executing macOS's hdiutil to attach a disk image.
+	//   * attach - attach the image and mount its volumes
+	//   * -plist - print the created devices in the plist format
+	//   * -nobrowse - hide the volumes from the Finder
+	//   * -mountpoint <path> - mount the volume at the path instead of in /Volumes
+	//   * -readonly - attach the image without allowing writes
+	cmdAttach := []string{"hdiutil", "attach", "-plist", "-nobrowse"}
+	if opts.MountPoint != "" {
+		cmdAttach = append(cmdAttach, "-mountpoint", opts.MountPoint)
+	}
+	if opts.ReadOnly {
+		cmdAttach = append(cmdAttach, "-readonly")
+	}
+	cmdAttach = append(cmdAttach, path)
+
+	out, err := util.ExecuteCommand(ctx, cmdAttach, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("hdiutil: failed to attach image, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return decodeAttachment(out.Stdout)
+}
+
+// Detach uses the macOS hdiutil detach command to detach a disk image.
+func (h *HDIUtilCmd) Detach(ctx context.Context, device string, force bool) error {
+	// cmdDetach represents the command used for executing macOS's hdiutil to detach a disk image.
+	//   * detach - unmount the image's volumes and detach it
+	//   * device - the device node or mount point of the image
+	//   * -force - detach the image even if its volumes are in use
+	cmdDetach := []string{"hdiutil", "detach", device}
+	if force {
+		cmdDetach = append(cmdDetach, "-force")
+	}
+
+	out, err := util.ExecuteCommand(ctx, cmdDetach, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("hdiutil: failed to detach image, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// Resize uses the macOS hdiutil resize command to change the maximum size of a disk image. The image must be
+// detached.
+func (h *HDIUtilCmd) Resize(ctx context.Context, path string, size string) error {
+	// cmdResize represents the command used for executing macOS's hdiutil to resize a disk image.
+	//   * resize - resize the image and its volume
+	//   * -size <size> - the new maximum size of the image
+	cmdResize := []string{"hdiutil", "resize", "-size", size, path}
+
+	out, err := util.ExecuteCommand(ctx, cmdResize, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("hdiutil: failed to resize image, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// Info uses the macOS hdiutil info command to list the attached disk images.
+func (h *HDIUtilCmd) Info(ctx context.Context) ([]Image, error) {
+	// cmdInfo represents the command used for executing macOS's hdiutil to list attached disk images.
+	//   * info - display the attached images
+	//   * -plist - print the images in the plist format
+	cmdInfo := []string{"hdiutil", "info", "-plist"}
+
+	out, err := util.ExecuteCommand(ctx, cmdInfo, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("hdiutil: failed to list images, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return decodeInfo(out.Stdout)
+}
+
+// decodeCreate decodes the created image's path from the plist output of hdiutil's create command.
+func decodeCreate(out string) (string, error) {
+	var paths []string
+	if err := plist.NewDecoder(bytes.NewReader([]byte(out))).Decode(&paths); err != nil {
+		return "", fmt.Errorf("hdiutil: error decoding create output: %w", err)
+	}
+	if len(paths) == 0 {
+		return "", errors.New("hdiutil: no image was created")
+	}
+
+	return paths[0], nil
+}
+
+// decodeAttachment decodes the plist output of hdiutil's attach command.
+func decodeAttachment(out string) (*Attachment, error) {
+	attachment := &Attachment{}
+	if err := plist.NewDecoder(bytes.NewReader([]byte(out))).Decode(attachment); err != nil {
+		return nil, fmt.Errorf("hdiutil: error decoding attach output: %w", err)
+	}
+
+	return attachment, nil
+}
+
+// decodeInfo decodes the plist output of hdiutil's info command.
+func decodeInfo(out string) ([]Image, error) {
+	var info struct {
+		Images []Image `plist:"images"`
+	}
+	if err := plist.NewDecoder(bytes.NewReader([]byte(out))).Decode(&info); err != nil {
+		return nil, fmt.Errorf("hdiutil: error decoding info output: %w", err)
+	}
+
+	return info.Images, nil
+}
@@ -0,0 +1,85 @@
+package hdiutil
+
+import (
+	_ "embed"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	// createOutput contains the plist output of hdiutil's create command.
+	//
+	//go:embed testdata/create.plist
+	createOutput string
+
+	// attachOutput contains the plist output of hdiutil's attach command for a sparse bundle with an APFS volume.
+	//
+	//go:embed testdata/attach.plist
+	attachOutput string
+
+	// infoOutput contains the plist output of hdiutil's info command with a single attached sparse bundle.
+	//
+	//go:embed testdata/info.plist
+	infoOutput string
+)
+
+func TestParseImageType(t *testing.T) {
+	got, err := ParseImageType("sparsebundle")
+	assert.NoError(t, err)
+	assert.Equal(t, TypeSparseBundle, got)
+
+	_, err = ParseImageType("iso")
+	assert.Error(t, err, "should fail for unsupported types")
+}
+
+func TestDecodeCreate(t *testing.T) {
+	got, err := decodeCreate(createOutput)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/Users/ec2-user/workspaces/job-42.sparsebundle", got)
+}
+
+func TestDecodeCreate_WithoutPaths(t *testing.T) {
+	_, err := decodeCreate(`<plist version="1.0"><array/></plist>`)
+
+	assert.Error(t, err, "should fail when no image was created")
+}
+
+func TestDecodeAttachment(t *testing.T) {
+	got, err := decodeAttachment(attachOutput)
+
+	assert.NoError(t, err)
+	assert.Len(t, got.Entities, 3)
+	assert.Equal(t, "/dev/disk4", got.Device())
+	assert.Equal(t, "/Users/ec2-user/workspaces/job-42", got.MountPoint())
+	assert.Equal(t, "apfs", got.Entities[2].VolumeKind)
+}
+
+func TestDecodeInfo(t *testing.T) {
+	expected := []Image{
+		{
+			Path: "/Users/ec2-user/workspaces/job-42.sparsebundle",
+			Entities: []Entity{
+				{ContentHint: "GUID_partition_scheme", DevEntry: "/dev/disk4"},
+				{
+					ContentHint: "41504653-0000-11AA-AA11-00306543ECAC",
+					DevEntry:    "/dev/disk5s1",
+					MountPoint:  "/Users/ec2-user/workspaces/job-42",
+					VolumeKind:  "apfs",
+				},
+			},
+		},
+	}
+
+	got, err := decodeInfo(infoOutput)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, got)
+}
+
+func TestDecodeInfo_WithInvalidOutput(t *testing.T) {
+	_, err := decodeInfo("not a plist")
+
+	assert.Error(t, err, "should fail for invalid output")
+}
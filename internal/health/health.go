@@ -0,0 +1,161 @@
+// Package health provides the functionality necessary for reporting the status of long running (daemon) modes over
+// a localhost-only HTTP endpoint, so that fleet health checks can tell whether the daemon is running and whether its
+// last run succeeded.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/build"
+)
+
+const (
+	// Path is the path that the status is served at.
+	Path = "/healthz"
+
+	// shutdownTimeout is the time given to in-flight requests when the server is stopped.
+	shutdownTimeout = 5 * time.Second
+)
+
+// ErrNotLoopback is returned when the status would be served on an address other than the loopback interface.
+var ErrNotLoopback = errors.New("health: address must be on the loopback interface")
+
+// Status is the state of a daemon.
+type Status struct {
+	// Name identifies the daemon (e.g. "grow").
+	Name string `json:"name"`
+	// Version is the version of the utility running the daemon.
+	Version string `json:"version"`
+	// Started is when the daemon started.
+	Started time.Time `json:"started"`
+	// LastRun is when the daemon last finished a run, which is nil until its first run finishes.
+	LastRun *time.Time `json:"last_run,omitempty"`
+	// LastError is why the last run failed, which is empty when it succeeded.
+	LastError string `json:"last_error,omitempty"`
+	// Runs is the number of runs that finished.
+	Runs int `json:"runs"`
+	// Failures is the number of runs that failed.
+	Failures int `json:"failures"`
+	// Healthy indicates that the last run succeeded (or that there hasn't been a run yet).
+	Healthy bool `json:"healthy"`
+}
+
+// Monitor records the outcome of a daemon's runs. Monitors are served as an http.Handler which responds with the
+// status as JSON, using 503 Service Unavailable when the last run failed so that checks don't need to parse it.
+type Monitor struct {
+	mu     sync.RWMutex
+	status Status
+	now    func() time.Time
+}
+
+// NewMonitor creates a Monitor for the named daemon, started now.
+func NewMonitor(name string) *Monitor {
+	m := &Monitor{now: time.Now}
+	m.status = Status{Name: name, Version: build.Version, Started: m.now(), Healthy: true}
+
+	return m
+}
+
+// Record records that a run finished, or that it failed when err is set.
+func (m *Monitor) Record(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.status.LastRun = &now
+	m.status.Runs++
+	m.status.LastError = ""
+	m.status.Healthy = err == nil
+	if err != nil {
+		m.status.LastError = err.Error()
+		m.status.Failures++
+	}
+}
+
+// Status gets the daemon's current status.
+func (m *Monitor) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status := m.status
+	if status.LastRun != nil {
+		lastRun := *status.LastRun
+		status.LastRun = &lastRun
+	}
+
+	return status
+}
+
+// ServeHTTP responds with the daemon's status.
+func (m *Monitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := m.Status()
+	code := http.StatusOK
+	if !status.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
+
+// Serve serves the handlers, keyed by their path, on the address until the context is done. Only loopback
+// addresses (e.g. "127.0.0.1:8462" or "localhost:8462") are accepted so the status is never exposed off the host.
+func Serve(ctx context.Context, addr string, handlers map[string]http.Handler) error {
+	if err := assertLoopback(addr); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	for path, h := range handlers {
+		mux.Handle(path, h)
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("health: cannot listen on %s: %w", addr, err)
+	}
+
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// assertLoopback checks that the address's host is the loopback interface.
+func assertLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("health: invalid address %s: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+
+	return fmt.Errorf("%w, got [%s]", ErrNotLoopback, addr)
+}
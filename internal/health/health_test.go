@@ -0,0 +1,75 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitor(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMonitor("grow")
+	m.now = func() time.Time { return now }
+
+	m.Record(errors.New("not enough space"))
+
+	status := m.Status()
+	assert.False(t, status.Healthy)
+	assert.Equal(t, "not enough space", status.LastError)
+	assert.Equal(t, now, *status.LastRun)
+	assert.Equal(t, 1, status.Failures)
+
+	m.Record(nil)
+
+	status = m.Status()
+	assert.True(t, status.Healthy)
+	assert.Empty(t, status.LastError)
+	assert.Equal(t, 2, status.Runs)
+	assert.Equal(t, 1, status.Failures, "failures should be kept after a successful run")
+}
+
+func TestMonitor_ServeHTTP(t *testing.T) {
+	m := NewMonitor("grow")
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var status Status
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&status))
+	assert.Equal(t, "grow", status.Name)
+	assert.Nil(t, status.LastRun, "there shouldn't be a last run before the first run")
+
+	m.Record(errors.New("error"))
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, Path, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestServe_NotLoopback(t *testing.T) {
+	tests := []string{"0.0.0.0:8462", ":8462", "10.0.0.1:8462", "example.com:8462"}
+	for _, addr := range tests {
+		t.Run(addr, func(t *testing.T) {
+			err := Serve(context.Background(), addr, nil)
+
+			assert.True(t, errors.Is(err, ErrNotLoopback))
+		})
+	}
+}
+
+func TestAssertLoopback(t *testing.T) {
+	assert.NoError(t, assertLoopback("127.0.0.1:8462"))
+	assert.NoError(t, assertLoopback("[::1]:8462"))
+	assert.NoError(t, assertLoopback("localhost:8462"))
+	assert.Error(t, assertLoopback("localhost"), "addresses need a port")
+}
@@ -0,0 +1,225 @@
+// Package homebrew provides the functionality necessary for preparing a host for Homebrew: creating the directories
+// it installs into with the ownership its installer expects, and adding its executables to the PATH of login shells.
+// Fresh instances commonly fail to install with Homebrew when it runs as a user that can't write to them.
+package homebrew
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/shellprofile"
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/internal/users"
+)
+
+const (
+	// AppleSiliconPrefix is where Homebrew is installed on Apple silicon.
+	AppleSiliconPrefix = "/opt/homebrew"
+	// IntelPrefix is where Homebrew is installed on Intel.
+	IntelPrefix = "/usr/local"
+	// AdminGID is the ID of the admin group, which Homebrew's installer gives its directories to.
+	AdminGID = 80
+	// SystemPathsFile lists the directories in the PATH of every login shell.
+	SystemPathsFile = "/etc/paths"
+
+	// intelRepository is the directory of Homebrew's repository on Intel, where it isn't the prefix itself.
+	intelRepository = "Homebrew"
+	// cacheDir is Homebrew's download cache in the user's home directory.
+	cacheDir = "Library/Caches/Homebrew"
+	// ProfileName is the profile read by zsh, the default shell, for login shells.
+	ProfileName = ".zprofile"
+	// ProfileBlock is the name of the managed block in the profile.
+	ProfileBlock = "homebrew"
+	// dirMode is the mode of the directories.
+	dirMode = 0o755
+)
+
+// prefixDirs are the directories under the prefix that Homebrew writes to, parents first.
+var prefixDirs = []string{
+	"bin", "etc", "include", "lib", "sbin", "share", "opt", "var", "Frameworks", "Cellar", "Caskroom",
+	"share/zsh", "share/zsh/site-functions", "var/homebrew", "var/homebrew/linked", "var/log",
+}
+
+// Prefix gets the prefix that Homebrew is installed in for the architecture.
+func Prefix(appleSilicon bool) string {
+	if appleSilicon {
+		return AppleSiliconPrefix
+	}
+
+	return IntelPrefix
+}
+
+// ShellEnv gets the line that adds Homebrew in the prefix to a login shell's environment.
+func ShellEnv(prefix string) string {
+	return fmt.Sprintf(`eval "$(%s/bin/brew shellenv)"`, prefix)
+}
+
+// DirectoriesTask ensures that the directories Homebrew writes to exist and are owned by the user and group. The
+// prefix itself is only included when it's Homebrew's own (e.g. /opt/homebrew) rather than shared with the system
+// like /usr/local.
+type DirectoriesTask struct {
+	// Prefix is where Homebrew is installed.
+	Prefix string
+	// User is the user that runs Homebrew.
+	User *users.User
+	// GID is the group that owns the directories.
+	GID int
+}
+
+// NewDirectoriesTask creates a DirectoriesTask for the user which gives the directories to the admin group, like
+// Homebrew's installer does.
+func NewDirectoriesTask(prefix string, u *users.User) *DirectoriesTask {
+	return &DirectoriesTask{Prefix: prefix, User: u, GID: AdminGID}
+}
+
+// Name identifies the task.
+func (t *DirectoriesTask) Name() string {
+	return "brew-directories"
+}
+
+// Directories gets the directories that the task manages, parents first.
+func (t *DirectoriesTask) Directories() []string {
+	var dirs []string
+	if t.Prefix == IntelPrefix {
+		dirs = append(dirs, filepath.Join(t.Prefix, intelRepository))
+	} else {
+		dirs = append(dirs, t.Prefix)
+	}
+	for _, dir := range prefixDirs {
+		dirs = append(dirs, filepath.Join(t.Prefix, dir))
+	}
+
+	return append(dirs, filepath.Join(t.User.Home, cacheDir))
+}
+
+// Check compares the directories' owners with the user and group.
+func (t *DirectoriesTask) Check(ctx context.Context) ([]task.Change, error) {
+	desired := fmt.Sprintf("%d:%d", t.User.UID, t.GID)
+
+	var changes []task.Change
+	for _, dir := range t.Directories() {
+		info, err := os.Stat(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			changes = append(changes, task.Change{Setting: "directory." + dir, Desired: desired})
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("homebrew: cannot check %s: %w", dir, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("homebrew: %s isn't a directory", dir)
+		}
+
+		if uid, gid, ok := owner(info); ok && (uid != t.User.UID || gid != t.GID) {
+			changes = append(changes, task.Change{Setting: "directory." + dir, Current: fmt.Sprintf("%d:%d", uid, gid), Desired: desired})
+		}
+	}
+
+	return changes, nil
+}
+
+// Apply creates the missing directories and gives the directories to the user and group. The contents of existing
+// directories are left as they are.
+func (t *DirectoriesTask) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+
+	for _, c := range changes {
+		dir := strings.TrimPrefix(c.Setting, "directory.")
+		if err := os.MkdirAll(dir, dirMode); err != nil {
+			return nil, fmt.Errorf("homebrew: cannot create %s: %w", dir, err)
+		}
+		if err := os.Chown(dir, t.User.UID, t.GID); err != nil {
+			return nil, fmt.Errorf("homebrew: cannot change owner of %s: %w", dir, err)
+		}
+	}
+
+	return changes, nil
+}
+
+// ProfileTask ensures that Homebrew's executables are in the PATH of the user's login shells, either because the
+// prefix's bin directory is one of the system's paths (e.g. /usr/local/bin) or because a managed block in the user's
+// profile adds Homebrew's environment.
+type ProfileTask struct {
+	// Prefix is where Homebrew is installed.
+	Prefix string
+	// User is the user whose profile is managed.
+	User *users.User
+	// SystemPaths is the file listing the system's paths (e.g. SystemPathsFile).
+	SystemPaths string
+}
+
+// NewProfileTask creates a ProfileTask for the user which checks the system's paths in SystemPathsFile.
+func NewProfileTask(prefix string, u *users.User) *ProfileTask {
+	return &ProfileTask{Prefix: prefix, User: u, SystemPaths: SystemPathsFile}
+}
+
+// Name identifies the task.
+func (t *ProfileTask) Name() string {
+	return "brew-profile"
+}
+
+// Check checks whether Homebrew's executables are already in login shells' PATH.
+func (t *ProfileTask) Check(ctx context.Context) ([]task.Change, error) {
+	block, err := t.block()
+	if block == nil || err != nil {
+		return nil, err
+	}
+
+	return block.Check(ctx)
+}
+
+// Apply adds Homebrew's environment to the user's profile when its executables aren't in PATH, creating the profile
+// when it doesn't exist.
+func (t *ProfileTask) Apply(ctx context.Context) ([]task.Change, error) {
+	block, err := t.block()
+	if block == nil || err != nil {
+		return nil, err
+	}
+
+	return block.Apply(ctx)
+}
+
+// block gets the profile's managed block that adds Homebrew's environment, which is nil when the prefix's bin
+// directory is already one of the system's paths. The block replaces the bare lines that earlier releases appended.
+func (t *ProfileTask) block() (*shellprofile.BlockTask, error) {
+	bin := filepath.Join(t.Prefix, "bin")
+	found, err := containsLine(t.SystemPaths, func(line string) bool { return line == bin })
+	if err != nil || found {
+		return nil, err
+	}
+
+	return &shellprofile.BlockTask{
+		Path:     filepath.Join(t.User.Home, ProfileName),
+		Block:    ProfileBlock,
+		Lines:    []string{ShellEnv(t.Prefix)},
+		Replaces: []string{ShellEnv(t.Prefix)},
+		Owner:    t.User,
+	}, nil
+}
+
+// containsLine checks if any of the trimmed lines of the file match. A missing file has no lines.
+func containsLine(path string, match func(line string) bool) (bool, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("homebrew: cannot read %s: %w", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if match(strings.TrimSpace(scanner.Text())) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
@@ -0,0 +1,88 @@
+package homebrew
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/internal/users"
+)
+
+// testUser creates a user, owned by the test's own user and group, whose home directory is temporary.
+func testUser(t *testing.T) *users.User {
+	return &users.User{Name: "ec2-user", UID: os.Getuid(), GID: os.Getgid(), Home: t.TempDir()}
+}
+
+func TestPrefix(t *testing.T) {
+	assert.Equal(t, "/opt/homebrew", Prefix(true))
+	assert.Equal(t, "/usr/local", Prefix(false))
+}
+
+func TestDirectoriesTask(t *testing.T) {
+	u := testUser(t)
+	prefix := filepath.Join(t.TempDir(), "homebrew")
+	tk := &DirectoriesTask{Prefix: prefix, User: u, GID: os.Getgid()}
+
+	dirs := tk.Directories()
+	assert.Equal(t, prefix, dirs[0], "Homebrew's own prefix should be managed")
+	assert.Equal(t, filepath.Join(u.Home, "Library/Caches/Homebrew"), dirs[len(dirs)-1])
+
+	changes, err := tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, changes, len(dirs))
+
+	changes, err = tk.Apply(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, changes, len(dirs))
+	for _, dir := range dirs {
+		assert.DirExists(t, dir)
+	}
+
+	changes, err = tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestDirectoriesTask_IntelPrefix(t *testing.T) {
+	tk := NewDirectoriesTask(IntelPrefix, &users.User{Home: "/Users/ec2-user"})
+
+	dirs := tk.Directories()
+	assert.Equal(t, "/usr/local/Homebrew", dirs[0], "the shared prefix shouldn't be managed")
+	assert.NotContains(t, dirs, "/usr/local")
+	assert.Equal(t, AdminGID, tk.GID)
+}
+
+func TestProfileTask(t *testing.T) {
+	u := testUser(t)
+	paths := filepath.Join(t.TempDir(), "paths")
+	assert.NoError(t, os.WriteFile(paths, []byte("/usr/local/bin\n/usr/bin\n/bin\n"), 0o644))
+	profile := filepath.Join(u.Home, ".zprofile")
+	assert.NoError(t, os.WriteFile(profile, []byte("export LANG=en_US.UTF-8"), 0o644))
+	tk := &ProfileTask{Prefix: AppleSiliconPrefix, User: u, SystemPaths: paths}
+
+	changes, err := tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []task.Change{{Setting: profile + "[homebrew]", Desired: `eval "$(/opt/homebrew/bin/brew shellenv)"`}}, changes)
+
+	for i := 0; i < 2; i++ {
+		_, err = tk.Apply(context.Background())
+		assert.NoError(t, err)
+	}
+	data, err := os.ReadFile(profile)
+	assert.NoError(t, err)
+	assert.Equal(t, "export LANG=en_US.UTF-8\n\n# BEGIN ec2-macos-utils: homebrew\neval \"$(/opt/homebrew/bin/brew shellenv)\"\n# END ec2-macos-utils: homebrew\n", string(data),
+		"the environment should only be added once")
+
+	changes, err = tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	tk.Prefix = IntelPrefix
+	changes, err = tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, changes, "prefixes whose bin is in the system's paths don't need the profile")
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package homebrew
+
+import "os"
+
+// owner isn't supported outside of Unix systems, the owners of files are unknown.
+func owner(info os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,147 @@
+package imds
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultCacheTTL is the time that metadata is cached for by NewCachedClient.
+	DefaultCacheTTL = 5 * time.Minute
+
+	// refreshAfter is the fraction of the TTL after which an entry is refreshed in the background, so that lookups
+	// keep being served from the cache instead of waiting for the entry to be fetched again once it expires.
+	refreshAfter = 0.8
+	// refreshTimeout is the maximum duration of background refreshes since they aren't bound to a lookup's context.
+	refreshTimeout = 30 * time.Second
+)
+
+// uncachedPrefixes are the metadata paths that are never cached. Instance profile credentials expire on their own
+// schedule, which the ones using them track.
+var uncachedPrefixes = []string{
+	"/latest/meta-data/iam/security-credentials/",
+}
+
+// Cache caches metadata so that daemons which look up the same metadata repeatedly don't send a request for each
+// lookup. Entries are refreshed in the background before they expire. When fetching an expired entry fails (e.g.
+// while the service is throttling), its last value is served until it's MaxStale past its expiry.
+type Cache struct {
+	// TTL is the time that entries are served for before they're fetched again.
+	TTL time.Duration
+	// MaxStale is the time past their expiry that entries are served for when fetching them fails.
+	MaxStale time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	now     func() time.Time
+}
+
+// cacheEntry is a cached value and when it was fetched.
+type cacheEntry struct {
+	value      string
+	fetched    time.Time
+	refreshing bool
+}
+
+// NewCache creates a Cache whose entries are served for the TTL, and for another TTL when they can't be fetched.
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		TTL:      ttl,
+		MaxStale: ttl,
+		entries:  map[string]*cacheEntry{},
+		now:      time.Now,
+	}
+}
+
+// fetchFunc fetches the metadata at the path from the service.
+type fetchFunc func(ctx context.Context, path string) (string, error)
+
+// get looks up the metadata at the path, fetching it when it isn't cached or has expired.
+func (c *Cache) get(ctx context.Context, path string, fetch fetchFunc) (string, error) {
+	if !cacheable(path) {
+		return fetch(ctx, path)
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[path]
+	now := c.now()
+	if ok {
+		age := now.Sub(entry.fetched)
+		if age < c.TTL {
+			if age >= time.Duration(float64(c.TTL)*refreshAfter) && !entry.refreshing {
+				entry.refreshing = true
+				go c.refresh(path, fetch)
+			}
+			value := entry.value
+			c.mu.Unlock()
+			return value, nil
+		}
+	}
+	c.mu.Unlock()
+
+	value, err := fetch(ctx, path)
+	if err != nil {
+		return c.stale(path, err)
+	}
+	c.store(path, value)
+
+	return value, nil
+}
+
+// stale serves the last value of the expired entry when it's within MaxStale of its expiry, otherwise err is
+// returned. Metadata that no longer exists isn't served.
+func (c *Cache) stale(path string, err error) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[path]
+	if !ok || errors.Is(err, ErrNotFound) || c.now().Sub(entry.fetched) >= c.TTL+c.MaxStale {
+		return "", err
+	}
+	logrus.WithError(err).WithField("path", path).Warn("Serving stale instance metadata")
+
+	return entry.value, nil
+}
+
+// refresh fetches the entry in the background, leaving the cached value as-is when fetching fails.
+func (c *Cache) refresh(path string, fetch fetchFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+
+	value, err := fetch(ctx, path)
+	if err != nil {
+		logrus.WithError(err).WithField("path", path).Debug("Unable to refresh instance metadata")
+
+		c.mu.Lock()
+		if entry, ok := c.entries[path]; ok {
+			entry.refreshing = false
+		}
+		c.mu.Unlock()
+		return
+	}
+	c.store(path, value)
+}
+
+// store caches the value for the path.
+func (c *Cache) store(path string, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[path] = &cacheEntry{value: value, fetched: c.now()}
+}
+
+// cacheable checks if the metadata at the path may be cached.
+func cacheable(path string) bool {
+	for _, prefix := range uncachedPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,139 @@
+package imds
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a clock for Cache which only moves when advanced.
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func newTestCache(ttl time.Duration) (*Cache, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := NewCache(ttl)
+	c.now = clock.Now
+
+	return c, clock
+}
+
+func TestCache_Fresh(t *testing.T) {
+	c, clock := newTestCache(time.Minute)
+	var fetches int
+	fetch := func(ctx context.Context, path string) (string, error) {
+		fetches++
+		return "i-0123456789abcdef0", nil
+	}
+
+	for i := 0; i < 3; i++ {
+		value, err := c.get(context.Background(), "/latest/meta-data/instance-id", fetch)
+		assert.NoError(t, err)
+		assert.Equal(t, "i-0123456789abcdef0", value)
+		clock.now = clock.now.Add(10 * time.Second)
+	}
+
+	assert.Equal(t, 1, fetches)
+}
+
+func TestCache_Expired(t *testing.T) {
+	c, clock := newTestCache(time.Minute)
+	values := []string{"first", "second"}
+	fetch := func(ctx context.Context, path string) (string, error) {
+		value := values[0]
+		values = values[1:]
+		return value, nil
+	}
+
+	_, err := c.get(context.Background(), "/latest/meta-data/instance-id", fetch)
+	assert.NoError(t, err)
+	clock.now = clock.now.Add(time.Minute)
+	value, err := c.get(context.Background(), "/latest/meta-data/instance-id", fetch)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "second", value)
+}
+
+func TestCache_Refresh(t *testing.T) {
+	c, clock := newTestCache(time.Minute)
+	refreshed := make(chan struct{})
+	values := []string{"first", "second"}
+	fetch := func(ctx context.Context, path string) (string, error) {
+		value := values[0]
+		values = values[1:]
+		if len(values) == 0 {
+			defer close(refreshed)
+		}
+		return value, nil
+	}
+
+	_, err := c.get(context.Background(), "/latest/meta-data/instance-id", fetch)
+	assert.NoError(t, err)
+	clock.now = clock.now.Add(50 * time.Second)
+	value, err := c.get(context.Background(), "/latest/meta-data/instance-id", fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", value, "the cached value is served while it's refreshed")
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("the entry wasn't refreshed")
+	}
+	// The refreshed value is stored after fetching it returns
+	for i := 0; i < 100; i++ {
+		if value, _ = c.get(context.Background(), "/latest/meta-data/instance-id", fetch); value == "second" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, "second", value)
+}
+
+func TestCache_Stale(t *testing.T) {
+	throttled := errors.New("throttled")
+	c, clock := newTestCache(time.Minute)
+	var fail bool
+	fetch := func(ctx context.Context, path string) (string, error) {
+		if fail {
+			return "", throttled
+		}
+		return "us-west-2", nil
+	}
+
+	_, err := c.get(context.Background(), "/latest/meta-data/placement/region", fetch)
+	assert.NoError(t, err)
+	fail = true
+
+	clock.now = clock.now.Add(90 * time.Second)
+	value, err := c.get(context.Background(), "/latest/meta-data/placement/region", fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, "us-west-2", value)
+
+	clock.now = clock.now.Add(time.Minute)
+	_, err = c.get(context.Background(), "/latest/meta-data/placement/region", fetch)
+	assert.True(t, errors.Is(err, throttled))
+}
+
+func TestCache_Uncacheable(t *testing.T) {
+	c, _ := newTestCache(time.Minute)
+	var fetches int
+	fetch := func(ctx context.Context, path string) (string, error) {
+		fetches++
+		return "{}", nil
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := c.get(context.Background(), "/latest/meta-data/iam/security-credentials/role", fetch)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 2, fetches)
+}
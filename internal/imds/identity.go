@@ -0,0 +1,87 @@
+package imds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// identityDocumentPath is the path to the instance identity document.
+	identityDocumentPath = "/latest/dynamic/instance-identity/document"
+	// tagsPath is the path to the instance's tags, which are only available when tags in metadata are enabled.
+	tagsPath = "/latest/meta-data/tags/instance/"
+	// publicKeysPath is the path to the public keys that the instance was launched with.
+	publicKeysPath = "/latest/meta-data/public-keys/"
+)
+
+// IdentityDocument is the instance identity document, which describes the instance.
+type IdentityDocument struct {
+	AccountID        string `json:"accountId"`
+	Architecture     string `json:"architecture"`
+	AvailabilityZone string `json:"availabilityZone"`
+	ImageID          string `json:"imageId"`
+	InstanceID       string `json:"instanceId"`
+	InstanceType     string `json:"instanceType"`
+	PrivateIP        string `json:"privateIp"`
+	Region           string `json:"region"`
+}
+
+// IdentityDocument fetches the instance identity document.
+func (c *Client) IdentityDocument(ctx context.Context) (*IdentityDocument, error) {
+	body, err := c.Get(ctx, identityDocumentPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc IdentityDocument
+	if err = json.Unmarshal([]byte(body), &doc); err != nil {
+		return nil, fmt.Errorf("imds: invalid instance identity document: %w", err)
+	}
+
+	return &doc, nil
+}
+
+// Tags fetches the instance's tags. ErrNotFound is returned when tags in metadata aren't enabled for the instance.
+func (c *Client) Tags(ctx context.Context) (map[string]string, error) {
+	keys, err := c.Get(ctx, tagsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := map[string]string{}
+	for _, key := range strings.Fields(keys) {
+		value, err := c.Get(ctx, tagsPath+key)
+		if err != nil {
+			return nil, err
+		}
+		tags[key] = value
+	}
+
+	return tags, nil
+}
+
+// PublicKeys fetches the OpenSSH public keys that the instance was launched with, keyed by their key pair names.
+func (c *Client) PublicKeys(ctx context.Context) (map[string]string, error) {
+	entries, err := c.getOptional(ctx, publicKeysPath)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]string{}
+	for _, entry := range strings.Fields(entries) {
+		// Entries are the index of each key and its name (e.g. "0=my-key-pair")
+		index, name, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("imds: invalid public key entry %q", entry)
+		}
+		key, err := c.Get(ctx, publicKeysPath+index+"/openssh-key")
+		if err != nil {
+			return nil, err
+		}
+		keys[name] = key
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,218 @@
+// Package imds provides a minimal client for the EC2 Instance Metadata Service (IMDS) using IMDSv2 session tokens.
+package imds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultEndpoint is the IPv4 endpoint of the instance metadata service.
+	DefaultEndpoint = "http://169.254.169.254"
+	// IPv6Endpoint is the IPv6 endpoint of the instance metadata service, which is only reachable on Nitro
+	// instances with the endpoint enabled (e.g. in IPv6-only subnets).
+	IPv6Endpoint = "http://[fd00:ec2::254]"
+
+	// tokenPath is the path used to request IMDSv2 session tokens.
+	tokenPath = "/latest/api/token"
+	// tokenTTLHeader is the header used to set the lifetime, in seconds, of a requested session token.
+	tokenTTLHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+	// tokenHeader is the header used to authenticate metadata requests with a session token.
+	tokenHeader = "X-aws-ec2-metadata-token"
+	// tokenTTL is the lifetime of the session tokens requested by the client.
+	tokenTTL = 6 * time.Hour
+	// tokenRenewBefore is how long before they expire that session tokens are renewed, so that tokens don't expire
+	// while requests using them are in flight.
+	tokenRenewBefore = time.Minute
+
+	// throttleRetryAttempts is the number of times requests that the service throttles are attempted.
+	throttleRetryAttempts = 3
+	// throttleRetryDelay is the time waited after the first throttled attempt, which doubles after each attempt.
+	throttleRetryDelay = 500 * time.Millisecond
+
+	// defaultTimeout is the timeout for individual metadata requests. The service is link-local so requests that
+	// take longer than this are assumed to be running off of EC2.
+	defaultTimeout = 5 * time.Second
+)
+
+// ErrNotFound is returned when the requested metadata doesn't exist (e.g. the IPv6 addresses of an interface that
+// doesn't have any).
+var ErrNotFound = errors.New("not found")
+
+// errUnauthorized is returned when the service rejects the session token, which is renewed before retrying.
+var errUnauthorized = errors.New("unauthorized")
+
+// errThrottled is returned when the service throttles a request, which is retried after a delay.
+var errThrottled = errors.New("throttled")
+
+// Client fetches instance metadata from IMDS.
+type Client struct {
+	// Endpoint is the base URL of the metadata service.
+	Endpoint string
+	// HTTPClient is the client used to make requests.
+	HTTPClient *http.Client
+	// Cache caches the fetched metadata when set.
+	Cache *Cache
+
+	// mu guards the session token, which is reused until it's about to expire.
+	mu          sync.Mutex
+	tokenValue  string
+	tokenExpiry time.Time
+}
+
+// NewClient creates a new Client for the default endpoint.
+func NewClient() *Client {
+	return NewClientForEndpoint(DefaultEndpoint)
+}
+
+// NewClientForEndpoint creates a new Client for the endpoint (e.g. IPv6Endpoint).
+func NewClientForEndpoint(endpoint string) *Client {
+	return &Client{
+		Endpoint:   endpoint,
+		HTTPClient: &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// NewCachedClient creates a new Client for the default endpoint which caches metadata for DefaultCacheTTL. It's
+// meant for daemons, which look up the same metadata repeatedly.
+func NewCachedClient() *Client {
+	c := NewClient()
+	c.Cache = NewCache(DefaultCacheTTL)
+
+	return c
+}
+
+// Get fetches the metadata at the path (e.g. "/latest/meta-data/instance-id"). Requests that the service throttles
+// are retried, and the metadata is served from the Cache when one is set.
+func (c *Client) Get(ctx context.Context, path string) (string, error) {
+	if c.Cache != nil {
+		return c.Cache.get(ctx, path, c.fetch)
+	}
+
+	return c.fetch(ctx, path)
+}
+
+// fetch requests the metadata at the path from the service.
+func (c *Client) fetch(ctx context.Context, path string) (string, error) {
+	var body string
+	var err error
+	delay := throttleRetryDelay
+	for attempt := 0; attempt < throttleRetryAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return "", fmt.Errorf("imds: failed to get %s: %w", path, err)
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		body, err = c.request(ctx, path)
+		if err == nil || !errors.Is(err, errThrottled) {
+			break
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("imds: failed to get %s: %w", path, err)
+	}
+
+	return body, nil
+}
+
+// request sends a single request for the metadata at the path. The session token is renewed and the request sent
+// again when the service rejects the token (e.g. it was revoked).
+func (c *Client) request(ctx context.Context, path string) (string, error) {
+	for renewed := false; ; renewed = true {
+		token, err := c.sessionToken(ctx, renewed)
+		if err != nil {
+			return "", fmt.Errorf("failed to get session token: %w", err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.Endpoint+path, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set(tokenHeader, token)
+
+		body, err := c.do(req)
+		if errors.Is(err, errUnauthorized) && !renewed {
+			continue
+		}
+
+		return body, err
+	}
+}
+
+// sessionToken gets the session token, requesting a new one when there isn't one that's valid or renew is set.
+func (c *Client) sessionToken(ctx context.Context, renew bool) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !renew && c.tokenValue != "" && time.Until(c.tokenExpiry) > tokenRenewBefore {
+		return c.tokenValue, nil
+	}
+
+	token, err := c.token(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.tokenValue = token
+	c.tokenExpiry = time.Now().Add(tokenTTL)
+
+	return token, nil
+}
+
+// Region fetches the AWS Region the instance is running in.
+func (c *Client) Region(ctx context.Context) (string, error) {
+	return c.Get(ctx, "/latest/meta-data/placement/region")
+}
+
+// InstanceID fetches the ID of the instance.
+func (c *Client) InstanceID(ctx context.Context) (string, error) {
+	return c.Get(ctx, "/latest/meta-data/instance-id")
+}
+
+// token requests a new IMDSv2 session token.
+func (c *Client) token(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.Endpoint+tokenPath, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(tokenTTLHeader, strconv.Itoa(int(tokenTTL.Seconds())))
+
+	return c.do(req)
+}
+
+// do sends the request and reads the response body. Responses other than 200 OK are returned as errors.
+func (c *Client) do(req *http.Request) (string, error) {
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return "", ErrNotFound
+	case http.StatusUnauthorized:
+		return "", errUnauthorized
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return "", fmt.Errorf("%w: %s", errThrottled, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
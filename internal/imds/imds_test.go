@@ -0,0 +1,206 @@
+package imds
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testToken is the session token issued by the test server.
+const testToken = "test-token"
+
+// newTestServer creates a metadata service which serves the metadata and requires IMDSv2 session tokens.
+func newTestServer(t *testing.T, metadata map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == tokenPath {
+			assert.Equal(t, http.MethodPut, r.Method)
+			assert.NotEmpty(t, r.Header.Get(tokenTTLHeader))
+			w.Write([]byte(testToken))
+			return
+		}
+		if r.Header.Get(tokenHeader) != testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		value, ok := metadata[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(value))
+	}))
+}
+
+func TestClient_Region(t *testing.T) {
+	server := newTestServer(t, map[string]string{"/latest/meta-data/placement/region": "us-west-2\n"})
+	defer server.Close()
+
+	c := &Client{Endpoint: server.URL, HTTPClient: server.Client()}
+	region, err := c.Region(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "us-west-2", region)
+}
+
+func TestClient_Get_NotFound(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Close()
+
+	c := &Client{Endpoint: server.URL, HTTPClient: server.Client()}
+	_, err := c.InstanceID(context.Background())
+
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestClient_NetworkInterfaces(t *testing.T) {
+	server := newTestServer(t, map[string]string{
+		"/latest/meta-data/network/interfaces/macs/":                                          "0e:00:00:00:00:02/\n0e:00:00:00:00:01/\n0e:00:00:00:00:03/",
+		"/latest/meta-data/network/interfaces/macs/0e:00:00:00:00:01/device-number":           "0",
+		"/latest/meta-data/network/interfaces/macs/0e:00:00:00:00:01/local-ipv4s":             "10.0.0.10\n10.0.0.11\n10.0.0.12",
+		"/latest/meta-data/network/interfaces/macs/0e:00:00:00:00:01/subnet-ipv4-cidr-block":  "10.0.0.0/24",
+		"/latest/meta-data/network/interfaces/macs/0e:00:00:00:00:02/device-number":           "1",
+		"/latest/meta-data/network/interfaces/macs/0e:00:00:00:00:02/local-ipv4s":             "10.0.1.20",
+		"/latest/meta-data/network/interfaces/macs/0e:00:00:00:00:02/subnet-ipv4-cidr-block":  "10.0.1.0/24",
+		"/latest/meta-data/network/interfaces/macs/0e:00:00:00:00:03/device-number":           "2",
+		"/latest/meta-data/network/interfaces/macs/0e:00:00:00:00:03/ipv6s":                   "2600:1f14:abc:de00::10\n2600:1f14:abc:de00::11",
+		"/latest/meta-data/network/interfaces/macs/0e:00:00:00:00:03/subnet-ipv6-cidr-blocks": "2600:1f14:abc:de00::/64",
+	})
+	defer server.Close()
+
+	c := &Client{Endpoint: server.URL, HTTPClient: server.Client()}
+	interfaces, err := c.NetworkInterfaces(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []NetworkInterface{
+		{MAC: "0e:00:00:00:00:01", DeviceNumber: 0, PrimaryIPv4: "10.0.0.10", SecondaryIPv4s: []string{"10.0.0.11", "10.0.0.12"}, SubnetCIDR: "10.0.0.0/24", IPv6s: []string{}, SubnetIPv6CIDRs: []string{}},
+		{MAC: "0e:00:00:00:00:02", DeviceNumber: 1, PrimaryIPv4: "10.0.1.20", SecondaryIPv4s: []string{}, SubnetCIDR: "10.0.1.0/24", IPv6s: []string{}, SubnetIPv6CIDRs: []string{}},
+		{MAC: "0e:00:00:00:00:03", DeviceNumber: 2, SecondaryIPv4s: []string{}, IPv6s: []string{"2600:1f14:abc:de00::10", "2600:1f14:abc:de00::11"}, SubnetIPv6CIDRs: []string{"2600:1f14:abc:de00::/64"}},
+	}, interfaces)
+	assert.False(t, interfaces[0].IPv6Only())
+	assert.True(t, interfaces[2].IPv6Only())
+}
+
+func TestClient_Get_ReusesToken(t *testing.T) {
+	var tokens int
+	metadata := newTestServer(t, map[string]string{"/latest/meta-data/instance-id": "i-0123456789abcdef0"})
+	defer metadata.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == tokenPath {
+			tokens++
+		}
+		metadata.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	c := &Client{Endpoint: server.URL, HTTPClient: server.Client()}
+	for i := 0; i < 3; i++ {
+		_, err := c.InstanceID(context.Background())
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, tokens)
+}
+
+func TestClient_Get_RenewsRejectedToken(t *testing.T) {
+	server := newTestServer(t, map[string]string{"/latest/meta-data/instance-id": "i-0123456789abcdef0"})
+	defer server.Close()
+
+	c := &Client{Endpoint: server.URL, HTTPClient: server.Client()}
+	c.tokenValue = "revoked-token"
+	c.tokenExpiry = time.Now().Add(time.Hour)
+	id, err := c.InstanceID(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "i-0123456789abcdef0", id)
+	assert.Equal(t, testToken, c.tokenValue)
+}
+
+func TestClient_Get_RetriesThrottled(t *testing.T) {
+	var requests int
+	metadata := newTestServer(t, map[string]string{"/latest/meta-data/instance-id": "i-0123456789abcdef0"})
+	defer metadata.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != tokenPath {
+			requests++
+			if requests == 1 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		}
+		metadata.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	c := &Client{Endpoint: server.URL, HTTPClient: server.Client()}
+	id, err := c.InstanceID(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "i-0123456789abcdef0", id)
+	assert.Equal(t, 2, requests)
+}
+
+func TestClient_IdentityDocument(t *testing.T) {
+	server := newTestServer(t, map[string]string{
+		identityDocumentPath: `{"accountId": "123456789012", "architecture": "arm64", "availabilityZone": "us-west-2a", "imageId": "ami-0123456789abcdef0", "instanceId": "i-0123456789abcdef0", "instanceType": "mac2.metal", "privateIp": "10.0.0.10", "region": "us-west-2"}`,
+	})
+	defer server.Close()
+
+	c := &Client{Endpoint: server.URL, HTTPClient: server.Client()}
+	doc, err := c.IdentityDocument(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, &IdentityDocument{
+		AccountID:        "123456789012",
+		Architecture:     "arm64",
+		AvailabilityZone: "us-west-2a",
+		ImageID:          "ami-0123456789abcdef0",
+		InstanceID:       "i-0123456789abcdef0",
+		InstanceType:     "mac2.metal",
+		PrivateIP:        "10.0.0.10",
+		Region:           "us-west-2",
+	}, doc)
+}
+
+func TestClient_Tags(t *testing.T) {
+	server := newTestServer(t, map[string]string{
+		tagsPath:          "Name\nteam",
+		tagsPath + "Name": "build-host",
+		tagsPath + "team": "ci",
+	})
+	defer server.Close()
+
+	c := &Client{Endpoint: server.URL, HTTPClient: server.Client()}
+	tags, err := c.Tags(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"Name": "build-host", "team": "ci"}, tags)
+}
+
+func TestClient_Tags_Disabled(t *testing.T) {
+	server := newTestServer(t, nil)
+	defer server.Close()
+
+	c := &Client{Endpoint: server.URL, HTTPClient: server.Client()}
+	_, err := c.Tags(context.Background())
+
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestClient_PublicKeys(t *testing.T) {
+	server := newTestServer(t, map[string]string{
+		publicKeysPath:                   "0=build-key",
+		publicKeysPath + "0/openssh-key": "ssh-ed25519 AAAA build-key",
+	})
+	defer server.Close()
+
+	c := &Client{Endpoint: server.URL, HTTPClient: server.Client()}
+	keys, err := c.PublicKeys(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"build-key": "ssh-ed25519 AAAA build-key"}, keys)
+}
@@ -0,0 +1,114 @@
+package imds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// macsPath is the path to the metadata of the instance's network interfaces, keyed by their MAC addresses.
+const macsPath = "/latest/meta-data/network/interfaces/macs/"
+
+// NetworkInterface is the metadata of an elastic network interface (ENI) attached to the instance.
+type NetworkInterface struct {
+	// MAC is the interface's MAC address.
+	MAC string `json:"mac"`
+	// DeviceNumber is the interface's index on the instance, starting at 0 for the primary interface.
+	DeviceNumber int `json:"device_number"`
+	// PrimaryIPv4 is the interface's primary private IPv4 address, which DHCP assigns to it. It's empty for
+	// interfaces in IPv6-only subnets.
+	PrimaryIPv4 string `json:"primary_ipv4,omitempty"`
+	// SecondaryIPv4s are the interface's secondary private IPv4 addresses, which aren't assigned by DHCP.
+	SecondaryIPv4s []string `json:"secondary_ipv4s"`
+	// SubnetCIDR is the IPv4 CIDR block of the interface's subnet.
+	SubnetCIDR string `json:"subnet_cidr,omitempty"`
+	// IPv6s are the interface's IPv6 addresses.
+	IPv6s []string `json:"ipv6s"`
+	// SubnetIPv6CIDRs are the IPv6 CIDR blocks of the interface's subnet.
+	SubnetIPv6CIDRs []string `json:"subnet_ipv6_cidrs"`
+}
+
+// IPv6Only checks if the interface is in an IPv6-only subnet, where it doesn't have an IPv4 address.
+func (n *NetworkInterface) IPv6Only() bool {
+	return n.PrimaryIPv4 == "" && len(n.IPv6s) > 0
+}
+
+// NetworkInterfaces fetches the metadata of the instance's network interfaces, ordered by their device numbers.
+func (c *Client) NetworkInterfaces(ctx context.Context) ([]NetworkInterface, error) {
+	macs, err := c.Get(ctx, macsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var interfaces []NetworkInterface
+	for _, mac := range strings.Fields(macs) {
+		iface, err := c.networkInterface(ctx, strings.TrimSuffix(mac, "/"))
+		if err != nil {
+			return nil, err
+		}
+		interfaces = append(interfaces, *iface)
+	}
+	sort.Slice(interfaces, func(i, j int) bool {
+		return interfaces[i].DeviceNumber < interfaces[j].DeviceNumber
+	})
+
+	return interfaces, nil
+}
+
+// networkInterface fetches the metadata of the network interface with the MAC address.
+func (c *Client) networkInterface(ctx context.Context, mac string) (*NetworkInterface, error) {
+	path := macsPath + mac + "/"
+	number, err := c.Get(ctx, path+"device-number")
+	if err != nil {
+		return nil, err
+	}
+	deviceNumber, err := strconv.Atoi(number)
+	if err != nil {
+		return nil, fmt.Errorf("imds: invalid device number %q for %s: %w", number, mac, err)
+	}
+
+	iface := &NetworkInterface{MAC: mac, DeviceNumber: deviceNumber, SecondaryIPv4s: []string{}}
+
+	// The first address is the interface's primary address, interfaces in IPv6-only subnets don't have any
+	ipv4s, err := c.getOptional(ctx, path+"local-ipv4s")
+	if err != nil {
+		return nil, err
+	}
+	if addresses := strings.Fields(ipv4s); len(addresses) > 0 {
+		iface.PrimaryIPv4 = addresses[0]
+		iface.SecondaryIPv4s = addresses[1:]
+	}
+	if iface.SubnetCIDR, err = c.getOptional(ctx, path+"subnet-ipv4-cidr-block"); err != nil {
+		return nil, err
+	}
+
+	ipv6s, err := c.getOptional(ctx, path+"ipv6s")
+	if err != nil {
+		return nil, err
+	}
+	iface.IPv6s = strings.Fields(ipv6s)
+	cidrs, err := c.getOptional(ctx, path+"subnet-ipv6-cidr-blocks")
+	if err != nil {
+		return nil, err
+	}
+	iface.SubnetIPv6CIDRs = strings.Fields(cidrs)
+
+	if iface.PrimaryIPv4 == "" && len(iface.IPv6s) == 0 {
+		return nil, fmt.Errorf("imds: no IP addresses for %s", mac)
+	}
+
+	return iface, nil
+}
+
+// getOptional fetches the metadata at the path like Get, but metadata that doesn't exist is returned as empty.
+func (c *Client) getOptional(ctx context.Context, path string) (string, error) {
+	value, err := c.Get(ctx, path)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+
+	return value, err
+}
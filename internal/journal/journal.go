@@ -0,0 +1,237 @@
+// Package journal provides the functionality necessary for recording the progress of multi-step operations (e.g.
+// provisioning a volume) so that operations interrupted by a crash or reboot can be reported and resumed instead of
+// leaving the host half-configured.
+//
+// Each operation is an Entry, saved as a JSON file in the journal's directory from when it starts until it
+// completes. Entries that are left in the directory belong to operations that didn't complete.
+package journal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// DefaultDir is the default directory of the journal.
+const DefaultDir = "/var/lib/ec2-macos-utils/journal"
+
+// entryExt is the extension of the entries' files.
+const entryExt = ".json"
+
+// StepStatus is the status of an operation's step.
+type StepStatus string
+
+const (
+	// StepPending is the status of steps that haven't been run.
+	StepPending StepStatus = "pending"
+	// StepDone is the status of steps that completed.
+	StepDone StepStatus = "done"
+	// StepFailed is the status of steps that failed.
+	StepFailed StepStatus = "failed"
+)
+
+// Step is a step of an operation.
+type Step struct {
+	// Name describes the step (e.g. "persist mount").
+	Name string `json:"name"`
+	// Status is the status of the step.
+	Status StepStatus `json:"status"`
+	// Error is the step's failure, if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Entry is the journal's record of an operation.
+type Entry struct {
+	// ID identifies the entry.
+	ID string `json:"id"`
+	// Operation is the operation (e.g. "provision").
+	Operation string `json:"operation"`
+	// Args are the arguments that the operation was run with, which it can be resumed with.
+	Args map[string]string `json:"args"`
+	// Steps are the operation's steps, in order.
+	Steps []Step `json:"steps"`
+	// PID is the process that ran the operation.
+	PID int `json:"pid"`
+	// Started is when the operation started.
+	Started time.Time `json:"started"`
+	// Updated is when the entry was last saved.
+	Updated time.Time `json:"updated"`
+
+	// path is where the entry is saved.
+	path string
+}
+
+// Journal is the directory that entries are saved in.
+type Journal struct {
+	// Dir is the journal's directory, it's created when the first entry is saved.
+	Dir string
+}
+
+// New creates the Journal for the directory.
+func New(dir string) *Journal {
+	return &Journal{Dir: dir}
+}
+
+// Begin saves a new entry for the operation with all of its steps pending.
+func (j *Journal) Begin(operation string, args map[string]string, steps []string) (*Entry, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	e := &Entry{
+		ID:        id,
+		Operation: operation,
+		Args:      args,
+		PID:       os.Getpid(),
+		Started:   now,
+		path:      filepath.Join(j.Dir, id+entryExt),
+	}
+	for _, name := range steps {
+		e.Steps = append(e.Steps, Step{Name: name, Status: StepPending})
+	}
+
+	if err := os.MkdirAll(j.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("journal: cannot create directory: %w", err)
+	}
+	if err := e.save(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// Pending reads the entries of the operations that didn't complete, oldest first.
+func (j *Journal) Pending() ([]*Entry, error) {
+	files, err := os.ReadDir(j.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("journal: cannot read directory: %w", err)
+	}
+
+	var entries []*Entry
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), entryExt) {
+			continue
+		}
+		e, err := j.Get(strings.TrimSuffix(f.Name(), entryExt))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].Started.Before(entries[b].Started)
+	})
+
+	return entries, nil
+}
+
+// Get reads the entry with the given ID.
+func (j *Journal) Get(id string) (*Entry, error) {
+	if id == "" || filepath.Base(id) != id {
+		return nil, fmt.Errorf("journal: invalid entry ID %q", id)
+	}
+
+	path := filepath.Join(j.Dir, id+entryExt)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("journal: cannot read entry: %w", err)
+	}
+
+	e := &Entry{}
+	if err := json.Unmarshal(b, e); err != nil {
+		return nil, fmt.Errorf("journal: cannot decode entry %s: %w", id, err)
+	}
+	e.path = path
+
+	return e, nil
+}
+
+// Step marks the step with the given name as done, or as failed when err is set, and saves the entry.
+func (e *Entry) Step(name string, err error) error {
+	for i := range e.Steps {
+		if e.Steps[i].Name != name {
+			continue
+		}
+		e.Steps[i].Status = StepDone
+		e.Steps[i].Error = ""
+		if err != nil {
+			e.Steps[i].Status = StepFailed
+			e.Steps[i].Error = err.Error()
+		}
+
+		return e.save()
+	}
+
+	return fmt.Errorf("journal: operation %s has no step %q", e.Operation, name)
+}
+
+// Completed gets the names of the steps that are done.
+func (e *Entry) Completed() []string {
+	var names []string
+	for _, s := range e.Steps {
+		if s.Status == StepDone {
+			names = append(names, s.Name)
+		}
+	}
+
+	return names
+}
+
+// Next gets the first step that isn't done, it's nil when all of them are.
+func (e *Entry) Next() *Step {
+	for i := range e.Steps {
+		if e.Steps[i].Status != StepDone {
+			return &e.Steps[i]
+		}
+	}
+
+	return nil
+}
+
+// Complete removes the entry once its operation has completed.
+func (e *Entry) Complete() error {
+	if err := os.Remove(e.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("journal: cannot remove entry: %w", err)
+	}
+
+	return nil
+}
+
+// save writes the entry to its file, replacing it atomically so that it's never left partially written.
+func (e *Entry) save() error {
+	e.Updated = time.Now().UTC()
+	b, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("journal: cannot encode entry: %w", err)
+	}
+
+	if err := util.WriteFileAtomic(e.path, b, 0o600); err != nil {
+		return fmt.Errorf("journal: cannot write entry: %w", err)
+	}
+
+	return nil
+}
+
+// newID creates a random entry ID.
+func newID() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("journal: cannot create entry ID: %w", err)
+	}
+
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b), nil
+}
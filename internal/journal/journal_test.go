@@ -0,0 +1,89 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournal_Begin(t *testing.T) {
+	j := New(filepath.Join(t.TempDir(), "journal"))
+
+	e, err := j.Begin("provision", map[string]string{"id": "disk2"}, []string{"erase", "mount"})
+	assert.NoError(t, err, "should be able to begin entry")
+
+	assert.FileExists(t, filepath.Join(j.Dir, e.ID+entryExt), "entry should be saved")
+	assert.Equal(t, os.Getpid(), e.PID, "entry should record process")
+	assert.Equal(t, []Step{{Name: "erase", Status: StepPending}, {Name: "mount", Status: StepPending}}, e.Steps, "steps should be pending")
+}
+
+func TestEntry_Step(t *testing.T) {
+	j := New(t.TempDir())
+	e, err := j.Begin("provision", nil, []string{"erase", "mount", "persist"})
+	assert.NoError(t, err, "should be able to begin entry")
+
+	assert.NoError(t, e.Step("erase", nil), "should be able to record done step")
+	assert.NoError(t, e.Step("mount", errors.New("resource busy")), "should be able to record failed step")
+	assert.Error(t, e.Step("fstab", nil), "should fail for unknown step")
+
+	saved, err := j.Get(e.ID)
+	assert.NoError(t, err, "should be able to read entry")
+	assert.Equal(t, []string{"erase"}, saved.Completed(), "only done steps should be completed")
+	next := saved.Next()
+	if assert.NotNil(t, next, "should have next step") {
+		assert.Equal(t, "mount", next.Name, "failed step should be next")
+		assert.Equal(t, StepFailed, next.Status)
+		assert.Equal(t, "resource busy", next.Error)
+	}
+
+	assert.NoError(t, saved.Step("mount", nil), "should be able to record repeated step")
+	assert.Empty(t, saved.Steps[1].Error, "error should be cleared when step is done")
+}
+
+func TestJournal_Pending(t *testing.T) {
+	j := New(t.TempDir())
+
+	entries, err := j.Pending()
+	assert.NoError(t, err, "should be able to read empty journal")
+	assert.Empty(t, entries)
+
+	first, err := j.Begin("provision", nil, []string{"erase"})
+	assert.NoError(t, err, "should be able to begin entry")
+	second, err := j.Begin("provision", nil, []string{"erase"})
+	assert.NoError(t, err, "should be able to begin entry")
+	assert.NoError(t, os.WriteFile(filepath.Join(j.Dir, "notes.txt"), nil, 0o600))
+
+	entries, err = j.Pending()
+	assert.NoError(t, err, "should be able to read journal")
+	if assert.Len(t, entries, 2, "other files should be skipped") {
+		assert.Equal(t, first.ID, entries[0].ID, "oldest entry should be first")
+		assert.Equal(t, second.ID, entries[1].ID)
+	}
+
+	assert.NoError(t, first.Complete(), "should be able to complete entry")
+	assert.NoError(t, first.Complete(), "completing twice should be fine")
+	entries, err = j.Pending()
+	assert.NoError(t, err, "should be able to read journal")
+	assert.Len(t, entries, 1, "completed entry should be removed")
+}
+
+func TestJournal_Pending_MissingDir(t *testing.T) {
+	j := New(filepath.Join(t.TempDir(), "missing"))
+
+	entries, err := j.Pending()
+
+	assert.NoError(t, err, "missing journal should have no entries")
+	assert.Empty(t, entries)
+}
+
+func TestJournal_Get_InvalidID(t *testing.T) {
+	j := New(t.TempDir())
+
+	for _, id := range []string{"", "../passwd", "a/b"} {
+		_, err := j.Get(id)
+		assert.Error(t, err, "should fail for ID %q", id)
+	}
+}
@@ -0,0 +1,294 @@
+// Package keychain provides the functionality necessary for creating keychains and installing code signing
+// certificates in them non-interactively with macOS's security(1) tool.
+package keychain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/users"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// SecurityPath is the path to macOS's security tool.
+	SecurityPath = "/usr/bin/security"
+	// CodesignPath is the path to macOS's codesign tool.
+	CodesignPath = "/usr/bin/codesign"
+
+	// keychainsDir is the directory holding a user's keychains, relative to their home directory.
+	keychainsDir = "Library/Keychains"
+	// keychainExt is the extension of keychain files created by macOS 10.12 and later.
+	keychainExt = ".keychain-db"
+)
+
+var (
+	// ErrDuplicate is returned when an imported item already exists in the keychain.
+	ErrDuplicate = errors.New("keychain: item already exists")
+
+	// DefaultPartitions are the partitions granted access to imported keys so that Apple's tools (e.g. codesign
+	// and xcodebuild) can use them without prompting.
+	DefaultPartitions = []string{"apple-tool:", "apple:", "codesign:"}
+
+	// identityPattern matches the identities listed by security's find-identity command, for example:
+	//   1) 0123456789ABCDEF0123456789ABCDEF01234567 "Apple Development: Jane Doe (ABCDE12345)"
+	identityPattern = regexp.MustCompile(`^\s*\d+\)\s+([0-9A-F]{40})\s+"(.*)"`)
+)
+
+// Format is a format of the items that can be imported into a keychain.
+type Format string
+
+const (
+	// FormatPKCS12 is a PKCS#12 (e.g. .p12) bundle holding a certificate and its private key.
+	FormatPKCS12 Format = "pkcs12"
+	// FormatX509 is a single certificate (e.g. .cer).
+	FormatX509 Format = "x509"
+)
+
+// Identity is a certificate and private key pair which can be used for code signing.
+type Identity struct {
+	// Hash is the SHA-1 hash of the identity's certificate.
+	Hash string `json:"hash"`
+	// Name is the common name of the identity's certificate.
+	Name string `json:"name"`
+}
+
+// Keychain is a keychain file belonging to a local user. All operations are run as the user so that the keychain
+// and its search list belong to them.
+type Keychain struct {
+	// Path is the absolute path to the keychain file.
+	Path string
+	// Owner is the user that the keychain belongs to.
+	Owner *users.User
+}
+
+// New creates a Keychain for the named keychain in the user's keychain directory. Names that are absolute paths
+// are used as-is.
+func New(owner *users.User, name string) *Keychain {
+	path := name
+	if !filepath.IsAbs(path) {
+		if !strings.HasSuffix(path, keychainExt) {
+			path += keychainExt
+		}
+		path = filepath.Join(owner.Home, keychainsDir, path)
+	}
+
+	return &Keychain{Path: path, Owner: owner}
+}
+
+// Exists checks if the keychain file exists.
+func (k *Keychain) Exists() (bool, error) {
+	_, err := os.Stat(k.Path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Create creates the keychain with the given password. The keychain is configured to never lock automatically
+// (e.g. after a timeout or when the instance sleeps) so that unattended builds can keep using it. The password is
+// given to security's prompts on stdin so that it doesn't appear in the command's arguments.
+func (k *Keychain) Create(ctx context.Context, password string) error {
+	// cmdCreate represents the command used for executing security to create a keychain.
+	//   * create-keychain - create a keychain file, the password is prompted for and then retyped
+	cmdCreate := []string{SecurityPath, "create-keychain", k.Path}
+	if _, err := k.run(ctx, cmdCreate, "create keychain", util.Input(password+"\n"+password)); err != nil {
+		return err
+	}
+
+	// cmdSettings represents the command used for executing security to stop the keychain from locking.
+	//   * set-keychain-settings - replace the keychain's settings, leaving out -l and -t disables locking
+	cmdSettings := []string{SecurityPath, "set-keychain-settings", k.Path}
+	_, err := k.run(ctx, cmdSettings, "configure keychain")
+
+	return err
+}
+
+// Unlock unlocks the keychain with its password, which is given to security's prompt on stdin so that it doesn't
+// appear in the command's arguments.
+func (k *Keychain) Unlock(ctx context.Context, password string) error {
+	// cmdUnlock represents the command used for executing security to unlock a keychain.
+	//   * unlock-keychain - unlock the keychain, the password is prompted for
+	cmdUnlock := []string{SecurityPath, "unlock-keychain", k.Path}
+	_, err := k.run(ctx, cmdUnlock, "unlock keychain", util.Input(password))
+
+	return err
+}
+
+// SearchList fetches the keychains searched for the owner's items.
+func (k *Keychain) SearchList(ctx context.Context) ([]string, error) {
+	// cmdList represents the command used for executing security to get the keychain search list.
+	//   * list-keychains - display or modify the keychain search list
+	//   * -d user - use the user's search list
+	cmdList := []string{SecurityPath, "list-keychains", "-d", "user"}
+	out, err := k.run(ctx, cmdList, "get keychain search list")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSearchList(out), nil
+}
+
+// AddToSearchList adds the keychain to the end of the owner's search list so that tools like codesign can find
+// its identities. AddToSearchList reports whether the search list was changed.
+func (k *Keychain) AddToSearchList(ctx context.Context) (bool, error) {
+	list, err := k.SearchList(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, path := range list {
+		if path == k.Path {
+			return false, nil
+		}
+	}
+
+	// cmdSet represents the command used for executing security to replace the keychain search list.
+	//   * list-keychains - display or modify the keychain search list
+	//   * -d user - use the user's search list
+	//   * -s <keychains> - set the search list to the keychains
+	cmdSet := append([]string{SecurityPath, "list-keychains", "-d", "user", "-s"}, append(list, k.Path)...)
+	if _, err := k.run(ctx, cmdSet, "set keychain search list"); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SetDefault makes the keychain the owner's default keychain.
+func (k *Keychain) SetDefault(ctx context.Context) error {
+	// cmdDefault represents the command used for executing security to set the default keychain.
+	//   * default-keychain - display or set the default keychain
+	//   * -d user - use the user's default keychain
+	//   * -s <keychain> - set the default keychain
+	cmdDefault := []string{SecurityPath, "default-keychain", "-d", "user", "-s", k.Path}
+	_, err := k.run(ctx, cmdDefault, "set default keychain")
+
+	return err
+}
+
+// Import imports the item into the keychain. Private keys in the item are made accessible to codesign and security
+// without prompting. ErrDuplicate is returned when the item is already in the keychain.
+//
+// security can't read the passphrase of PKCS#12 bundles from stdin, it only prompts for it with a dialog, so the
+// passphrase is given in the command's arguments where other users can see it with ps(1) while the item is imported.
+// It only protects the staged file, which is removed once the item is imported.
+func (k *Keychain) Import(ctx context.Context, data []byte, format Format, passphrase string) error {
+	// security only imports items from files so the item is staged in a file that only the owner can read.
+	f, err := os.CreateTemp("", "ec2-macos-utils-import-*")
+	if err != nil {
+		return fmt.Errorf("keychain: cannot stage item: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chown(f.Name(), k.Owner.UID, k.Owner.GID)
+	}
+	if err != nil {
+		return fmt.Errorf("keychain: cannot stage item: %w", err)
+	}
+
+	// cmdImport represents the command used for executing security to import an item into a keychain.
+	//   * import <file> - import the items in the file
+	//   * -k <keychain> - the keychain to import into
+	//   * -f <format> - the format of the file, since the staged file has no extension to infer it from
+	//   * -P <passphrase> - the passphrase protecting the file (e.g. for PKCS#12 bundles), visible to other users
+	//   * -T <app> - allow the app to use imported private keys without prompting
+	cmdImport := []string{SecurityPath, "import", f.Name(), "-k", k.Path, "-f", string(format)}
+	if format == FormatPKCS12 {
+		cmdImport = append(cmdImport, "-P", passphrase)
+	}
+	cmdImport = append(cmdImport, "-T", CodesignPath, "-T", SecurityPath)
+
+	out, err := util.ExecuteCommand(ctx, cmdImport, k.Owner.Name, k.env(), nil)
+	if strings.Contains(out.Stderr, "already exists") {
+		return ErrDuplicate
+	} else if err != nil {
+		return fmt.Errorf("keychain: failed to import item, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// SetKeyPartitionList grants the partitions access to the private keys in the keychain. Keys imported on macOS
+// 10.12 and later can't be used by codesign without prompting until this is done. The keychain's password is given
+// to security's prompt on stdin so that it doesn't appear in the command's arguments.
+func (k *Keychain) SetKeyPartitionList(ctx context.Context, password string, partitions []string) error {
+	// cmdPartitions represents the command used for executing security to set the partitions of private keys.
+	//   * set-key-partition-list - set the partition list of keys, the keychain's password is prompted for
+	//   * -S <partitions> - the comma separated partitions
+	//   * -s - only match private keys
+	cmdPartitions := []string{SecurityPath, "set-key-partition-list", "-S", strings.Join(partitions, ","), "-s", k.Path}
+	_, err := k.run(ctx, cmdPartitions, "set key partition list", util.Input(password))
+
+	return err
+}
+
+// Identities fetches the valid code signing identities in the keychain.
+func (k *Keychain) Identities(ctx context.Context) ([]Identity, error) {
+	// cmdFind represents the command used for executing security to find code signing identities.
+	//   * find-identity - find identities
+	//   * -v - only show valid identities
+	//   * -p codesigning - only show identities that can be used for code signing
+	cmdFind := []string{SecurityPath, "find-identity", "-v", "-p", "codesigning", k.Path}
+	out, err := k.run(ctx, cmdFind, "find identities")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseIdentities(out), nil
+}
+
+// run runs the security command as the keychain's owner with the options and returns its output.
+func (k *Keychain) run(ctx context.Context, c []string, action string, opts ...util.Option) (string, error) {
+	out, err := util.ExecuteCommand(ctx, c, k.Owner.Name, k.env(), nil, opts...)
+	if err != nil {
+		return "", fmt.Errorf("keychain: failed to %s, stderr: [%s]: %w", action, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return out.Stdout, nil
+}
+
+// env returns the environment for the owner's security commands. security looks up the user's preferences (e.g.
+// the search list) through HOME, which isn't changed when running as another user.
+func (k *Keychain) env() []string {
+	return []string{"HOME=" + k.Owner.Home, "USER=" + k.Owner.Name, "LOGNAME=" + k.Owner.Name}
+}
+
+// parseSearchList parses the quoted keychain paths output by security's list-keychains command.
+func parseSearchList(out string) []string {
+	var list []string
+	for _, line := range strings.Split(out, "\n") {
+		path := strings.Trim(strings.TrimSpace(line), `"`)
+		if path != "" {
+			list = append(list, path)
+		}
+	}
+
+	return list
+}
+
+// parseIdentities parses the identities output by security's find-identity command.
+func parseIdentities(out string) []Identity {
+	var ids []Identity
+	for _, line := range strings.Split(out, "\n") {
+		m := identityPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		ids = append(ids, Identity{Hash: m[1], Name: m[2]})
+	}
+
+	return ids
+}
@@ -0,0 +1,63 @@
+package keychain
+
+import (
+	_ "embed"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/users"
+)
+
+var (
+	// findIdentityOutput contains the code signing identities printed by security's find-identity command.
+	//
+	//go:embed testdata/find-identity.txt
+	findIdentityOutput string
+
+	// listKeychainsOutput contains the keychain search list printed by security's list-keychains command.
+	//
+	//go:embed testdata/list-keychains.txt
+	listKeychainsOutput string
+)
+
+func TestNew(t *testing.T) {
+	owner := &users.User{Name: "ec2-user", Home: "/Users/ec2-user"}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "name", input: "build", want: "/Users/ec2-user/Library/Keychains/build.keychain-db"},
+		{name: "name with extension", input: "build.keychain-db", want: "/Users/ec2-user/Library/Keychains/build.keychain-db"},
+		{name: "absolute path", input: "/tmp/build.keychain", want: "/tmp/build.keychain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := New(owner, tt.input)
+
+			assert.Equal(t, tt.want, k.Path)
+			assert.Equal(t, owner, k.Owner)
+		})
+	}
+}
+
+func TestParseIdentities(t *testing.T) {
+	expected := []Identity{
+		{Hash: "0123456789ABCDEF0123456789ABCDEF01234567", Name: "Apple Development: Jane Doe (ABCDE12345)"},
+		{Hash: "89ABCDEF0123456789ABCDEF0123456789ABCDEF", Name: "Apple Distribution: Example Corp (ABCDE12345)"},
+	}
+
+	assert.Equal(t, expected, parseIdentities(findIdentityOutput))
+	assert.Empty(t, parseIdentities("     0 valid identities found\n"))
+}
+
+func TestParseSearchList(t *testing.T) {
+	expected := []string{
+		"/Users/ec2-user/Library/Keychains/login.keychain-db",
+		"/Library/Keychains/System.keychain",
+	}
+
+	assert.Equal(t, expected, parseSearchList(listKeychainsOutput))
+}
@@ -0,0 +1,182 @@
+// Package keysync provides the functionality necessary for mapping centrally managed users, the members of an IAM
+// group or the entries of a manifest, to local accounts and the SSH keys authorized to log in as them.
+package keysync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/internal/users"
+)
+
+// namePattern matches the short names that accounts are created with.
+var namePattern = regexp.MustCompile(`^[a-z_][a-z0-9_.-]{0,31}$`)
+
+// Account is a local account and the keys authorized to log in as it.
+type Account struct {
+	// Name is the account's short name.
+	Name string `yaml:"name" json:"name"`
+	// FullName is the account's full name. The short name is used when empty.
+	FullName string `yaml:"full_name" json:"full_name,omitempty"`
+	// Shell is the login shell the account is created with. macOS's default shell is used when empty.
+	Shell string `yaml:"shell" json:"shell,omitempty"`
+	// Admin is whether the account is an administrator. Existing accounts' memberships are left as they are when
+	// unset.
+	Admin *bool `yaml:"admin" json:"admin,omitempty"`
+	// AuthorizedKeys are the OpenSSH public keys authorized to log in as the account.
+	AuthorizedKeys []string `yaml:"authorized_keys" json:"authorized_keys"`
+	// IAMUser is the IAM user whose active SSH public keys are also authorized.
+	IAMUser string `yaml:"iam_user" json:"iam_user,omitempty"`
+}
+
+// Manifest lists the accounts that are synced.
+type Manifest struct {
+	// Users are the accounts.
+	Users []Account `yaml:"users"`
+}
+
+// KeySource fetches the members of IAM groups and the SSH public keys of IAM users.
+type KeySource interface {
+	GroupUsers(ctx context.Context, group string) ([]string, error)
+	SSHPublicKeys(ctx context.Context, user string) ([]string, error)
+}
+
+// ParseManifest reads the manifest as YAML, or JSON, and validates it. Unknown keys are rejected.
+func ParseManifest(data []byte) (*Manifest, error) {
+	m := &Manifest{}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(m); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("keysync: error decoding manifest: %w", err)
+	}
+
+	seen := map[string]bool{}
+	for _, a := range m.Users {
+		if !namePattern.MatchString(a.Name) {
+			return nil, fmt.Errorf("keysync: invalid user name %q", a.Name)
+		}
+		if seen[a.Name] {
+			return nil, fmt.Errorf("keysync: duplicate user %s", a.Name)
+		}
+		seen[a.Name] = true
+	}
+
+	return m, nil
+}
+
+// LocalName maps an IAM user name to a local short name: the part before any "@" (e.g. "alice" for
+// "alice@example.com"), lower-cased, with the characters that short names can't hold replaced by "_".
+func LocalName(iamUser string) (string, error) {
+	name := strings.ToLower(iamUser)
+	if i := strings.Index(name, "@"); i > 0 {
+		name = name[:i]
+	}
+	name = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+	if !namePattern.MatchString(name) {
+		return "", fmt.Errorf("keysync: IAM user %s doesn't map to a valid user name", iamUser)
+	}
+
+	return name, nil
+}
+
+// FromIAMGroup maps the members of the IAM group to accounts authorized with their IAM users' keys. Members whose
+// names don't map to a valid, unique local name are skipped.
+func FromIAMGroup(ctx context.Context, src KeySource, group string) ([]Account, error) {
+	members, err := src.GroupUsers(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	owners := map[string]string{}
+	accounts := make([]Account, 0, len(members))
+	for _, member := range members {
+		name, err := LocalName(member)
+		if err != nil {
+			logrus.WithError(err).Warn("Skipping IAM user")
+			continue
+		}
+		if owner, ok := owners[name]; ok {
+			logrus.WithFields(logrus.Fields{"iam_user": member, "user": name, "owner": owner}).Warn("Skipping IAM user that maps to another IAM user's account")
+			continue
+		}
+		owners[name] = member
+		accounts = append(accounts, Account{Name: name, IAMUser: member})
+	}
+
+	return accounts, nil
+}
+
+// ResolveKeys adds the active SSH public keys of each account's IAM user to its authorized keys.
+func ResolveKeys(ctx context.Context, src KeySource, accounts []Account) error {
+	for i := range accounts {
+		if accounts[i].IAMUser == "" {
+			continue
+		}
+		keys, err := src.SSHPublicKeys(ctx, accounts[i].IAMUser)
+		if err != nil {
+			return err
+		}
+		accounts[i].AuthorizedKeys = append(accounts[i].AuthorizedKeys, keys...)
+	}
+
+	return nil
+}
+
+// Merge combines the accounts by name. The attributes of the first account with a name are kept and the keys of all
+// of the accounts with the name are authorized.
+func Merge(accounts ...[]Account) []Account {
+	var merged []Account
+	index := map[string]int{}
+	for _, list := range accounts {
+		for _, a := range list {
+			if i, ok := index[a.Name]; ok {
+				merged[i].AuthorizedKeys = append(merged[i].AuthorizedKeys, a.AuthorizedKeys...)
+				continue
+			}
+			a.AuthorizedKeys = append([]string(nil), a.AuthorizedKeys...)
+			index[a.Name] = len(merged)
+			merged = append(merged, a)
+		}
+	}
+
+	return merged
+}
+
+// NewTask builds the task that creates each account that doesn't exist and authorizes exactly its keys. The synced
+// keys of the previously synced users that aren't listed are removed, but their accounts are left as they are.
+func NewTask(accounts []Account, previous []string) task.Task {
+	listed := map[string]bool{}
+	var tasks []task.Task
+	for _, a := range accounts {
+		listed[a.Name] = true
+		tasks = append(tasks, task.NewGroup(a.Name,
+			&users.AccountTask{Options: users.CreateOptions{Name: a.Name, FullName: a.FullName, Shell: a.Shell}, Admin: a.Admin},
+			&users.SyncedKeysTask{User: a.Name, Keys: a.AuthorizedKeys},
+		))
+	}
+
+	sort.Strings(previous)
+	for _, name := range previous {
+		if !listed[name] {
+			tasks = append(tasks, &users.SyncedKeysTask{User: name})
+		}
+	}
+
+	return task.NewGroup("ssh-sync", tasks...)
+}
@@ -0,0 +1,117 @@
+package keysync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/internal/users"
+)
+
+type fakeKeySource struct {
+	groups map[string][]string
+	keys   map[string][]string
+}
+
+func (f *fakeKeySource) GroupUsers(ctx context.Context, group string) ([]string, error) {
+	return f.groups[group], nil
+}
+
+func (f *fakeKeySource) SSHPublicKeys(ctx context.Context, user string) ([]string, error) {
+	return f.keys[user], nil
+}
+
+func TestParseManifest(t *testing.T) {
+	m, err := ParseManifest([]byte(`
+users:
+  - name: alice
+    admin: true
+    authorized_keys: [ssh-ed25519 AAAA alice]
+  - name: bob
+    iam_user: bob@example.com
+`))
+
+	admin := true
+	assert.NoError(t, err)
+	assert.Equal(t, []Account{
+		{Name: "alice", Admin: &admin, AuthorizedKeys: []string{"ssh-ed25519 AAAA alice"}},
+		{Name: "bob", IAMUser: "bob@example.com"},
+	}, m.Users)
+}
+
+func TestParseManifest_JSON(t *testing.T) {
+	m, err := ParseManifest([]byte(`{"users": [{"name": "ci", "authorized_keys": ["ssh-ed25519 AAAA ci"]}]}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ci", m.Users[0].Name)
+}
+
+func TestParseManifest_Invalid(t *testing.T) {
+	for name, manifest := range map[string]string{
+		"unknown key":  "users:\n  - name: alice\n    keys: []\n",
+		"invalid name": "users:\n  - name: Alice Smith\n",
+		"duplicate":    "users:\n  - name: alice\n  - name: alice\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseManifest([]byte(manifest))
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLocalName(t *testing.T) {
+	for iamUser, expected := range map[string]string{
+		"alice":             "alice",
+		"Bob.Smith@example": "bob.smith",
+		"carol+ci":          "carol_ci",
+	} {
+		name, err := LocalName(iamUser)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, name)
+	}
+
+	_, err := LocalName("1password")
+	assert.Error(t, err, "names starting with a digit aren't valid")
+}
+
+func TestFromIAMGroup(t *testing.T) {
+	src := &fakeKeySource{
+		groups: map[string][]string{"mac-users": {"alice@example.com", "Alice", "bob"}},
+		keys:   map[string][]string{"alice@example.com": {"ssh-ed25519 AAAA alice"}},
+	}
+
+	accounts, err := FromIAMGroup(context.Background(), src, "mac-users")
+	assert.NoError(t, err)
+	assert.NoError(t, ResolveKeys(context.Background(), src, accounts))
+
+	assert.Equal(t, []Account{
+		{Name: "alice", IAMUser: "alice@example.com", AuthorizedKeys: []string{"ssh-ed25519 AAAA alice"}},
+		{Name: "bob", IAMUser: "bob"},
+	}, accounts, "IAM users that map to another's account should be skipped")
+}
+
+func TestMerge(t *testing.T) {
+	admin := true
+	merged := Merge(
+		[]Account{{Name: "alice", Admin: &admin, AuthorizedKeys: []string{"ssh-ed25519 AAAA laptop"}}},
+		[]Account{{Name: "alice", IAMUser: "alice", AuthorizedKeys: []string{"ssh-ed25519 BBBB iam"}}, {Name: "bob"}},
+	)
+
+	assert.Equal(t, []Account{
+		{Name: "alice", Admin: &admin, AuthorizedKeys: []string{"ssh-ed25519 AAAA laptop", "ssh-ed25519 BBBB iam"}},
+		{Name: "bob"},
+	}, merged)
+}
+
+func TestNewTask(t *testing.T) {
+	tk := NewTask([]Account{{Name: "alice", AuthorizedKeys: []string{"ssh-ed25519 AAAA alice"}}}, []string{"carol", "alice"})
+
+	group := tk.(*task.Group)
+	assert.Len(t, group.Tasks, 2)
+	assert.Equal(t, "alice", group.Tasks[0].Name())
+	assert.Equal(t, &users.SyncedKeysTask{User: "carol"}, group.Tasks[1], "users that are no longer listed should have their keys removed")
+}
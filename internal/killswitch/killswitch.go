@@ -0,0 +1,103 @@
+// Package killswitch provides the functionality necessary for pausing the utility's automatic behaviors (e.g.
+// scheduled runs that grow the root container) across a fleet of instances from a single place, an instance tag or a
+// Parameter Store parameter, without touching every host.
+package killswitch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/imds"
+)
+
+// DefaultTag is the instance tag that's checked when no other tag is configured.
+const DefaultTag = "ec2-macos-utils:automation"
+
+// pausedValues are the values of the tag or parameter, matched without case, that pause automation.
+var pausedValues = []string{"disabled", "paused", "off"}
+
+// TagSource fetches the instance's tags (e.g. *imds.Client).
+type TagSource interface {
+	Tags(ctx context.Context) (map[string]string, error)
+}
+
+// ParameterSource fetches Parameter Store parameters (e.g. *aws.Client).
+type ParameterSource interface {
+	GetParameter(ctx context.Context, name string) (string, error)
+}
+
+// State is whether automation is paused and what paused it.
+type State struct {
+	// Paused is whether automatic behaviors shouldn't run.
+	Paused bool `json:"paused"`
+	// Source describes what paused automation (e.g. "tag ec2-macos-utils:automation").
+	Source string `json:"source,omitempty"`
+	// Value is the value of the tag or parameter that paused automation.
+	Value string `json:"value,omitempty"`
+}
+
+func (s State) String() string {
+	if !s.Paused {
+		return "automation is enabled"
+	}
+
+	return fmt.Sprintf("automation is paused by %s=%q", s.Source, s.Value)
+}
+
+// Checker checks the instance tag and the parameter that pause automation. Either of them pauses automation when its
+// value is "disabled", "paused", or "off".
+type Checker struct {
+	// Tags fetches the instance's tags, the tag isn't checked when it's nil.
+	Tags TagSource
+	// Tag is the key of the instance tag that's checked.
+	Tag string
+	// Parameters fetches parameters, the parameter isn't checked when it's nil.
+	Parameters ParameterSource
+	// Parameter is the name of the parameter that's checked, it isn't checked when it's empty.
+	Parameter string
+}
+
+// Check finds whether automation is paused. The tag is checked first since it's read from the instance metadata
+// without credentials. Instances without tags in metadata and missing parameters don't pause automation. Failures to
+// check are returned along with the state of what could be checked so that callers can choose whether to run.
+func (c *Checker) Check(ctx context.Context) (State, error) {
+	if c.Tags != nil && c.Tag != "" {
+		tags, err := c.Tags.Tags(ctx)
+		if err != nil && !errors.Is(err, imds.ErrNotFound) {
+			return State{}, fmt.Errorf("killswitch: cannot read instance tags: %w", err)
+		}
+		if value, ok := tags[c.Tag]; ok && paused(value) {
+			return State{Paused: true, Source: "tag " + c.Tag, Value: value}, nil
+		}
+	}
+
+	if c.Parameters != nil && c.Parameter != "" {
+		value, err := c.Parameters.GetParameter(ctx, c.Parameter)
+		if errors.Is(err, aws.ErrParameterNotFound) {
+			return State{}, nil
+		}
+		if err != nil {
+			return State{}, fmt.Errorf("killswitch: cannot read parameter: %w", err)
+		}
+		if paused(value) {
+			return State{Paused: true, Source: "parameter " + c.Parameter, Value: value}, nil
+		}
+	}
+
+	return State{}, nil
+}
+
+// paused checks whether the value pauses automation.
+func paused(value string) bool {
+	value = strings.TrimSpace(value)
+	for _, v := range pausedValues {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+
+	return false
+}
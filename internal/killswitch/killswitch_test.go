@@ -0,0 +1,110 @@
+package killswitch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/imds"
+)
+
+// fakeTags is a TagSource with fixed tags.
+type fakeTags struct {
+	tags map[string]string
+	err  error
+}
+
+func (f fakeTags) Tags(ctx context.Context) (map[string]string, error) {
+	return f.tags, f.err
+}
+
+// fakeParameters is a ParameterSource with fixed parameters.
+type fakeParameters map[string]string
+
+func (f fakeParameters) GetParameter(ctx context.Context, name string) (string, error) {
+	value, ok := f[name]
+	if !ok {
+		return "", aws.ErrParameterNotFound
+	}
+	if value == "error" {
+		return "", errors.New("access denied")
+	}
+
+	return value, nil
+}
+
+func TestChecker_Check(t *testing.T) {
+	const parameter = "/ec2-macos-utils/automation"
+
+	tests := []struct {
+		name     string
+		tags     fakeTags
+		params   fakeParameters
+		expected State
+		wantErr  bool
+	}{
+		{
+			name:     "nothing set",
+			tags:     fakeTags{tags: map[string]string{"Name": "ci-runner"}},
+			params:   fakeParameters{},
+			expected: State{},
+		},
+		{
+			name:     "tag paused",
+			tags:     fakeTags{tags: map[string]string{DefaultTag: "Disabled"}},
+			params:   fakeParameters{parameter: "error"},
+			expected: State{Paused: true, Source: "tag " + DefaultTag, Value: "Disabled"},
+		},
+		{
+			name:     "tag enabled",
+			tags:     fakeTags{tags: map[string]string{DefaultTag: "enabled"}},
+			params:   fakeParameters{},
+			expected: State{},
+		},
+		{
+			name:     "tags not in metadata",
+			tags:     fakeTags{err: imds.ErrNotFound},
+			params:   fakeParameters{parameter: "paused"},
+			expected: State{Paused: true, Source: "parameter " + parameter, Value: "paused"},
+		},
+		{
+			name:    "tags unavailable",
+			tags:    fakeTags{err: errors.New("connection refused")},
+			params:  fakeParameters{},
+			wantErr: true,
+		},
+		{
+			name:    "parameter unavailable",
+			tags:    fakeTags{},
+			params:  fakeParameters{parameter: "error"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Checker{Tags: tt.tags, Tag: DefaultTag, Parameters: tt.params, Parameter: parameter}
+
+			actual, err := c.Check(context.Background())
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestChecker_Check_NoParameter(t *testing.T) {
+	c := &Checker{Tags: fakeTags{}, Tag: DefaultTag, Parameters: fakeParameters{"": "error"}}
+
+	actual, err := c.Check(context.Background())
+
+	assert.NoError(t, err, "parameter shouldn't be checked when it isn't configured")
+	assert.False(t, actual.Paused)
+}
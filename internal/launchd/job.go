@@ -0,0 +1,104 @@
+// Package launchd provides the functionality necessary for managing launchd services on macOS.
+package launchd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"howett.net/plist"
+)
+
+// LabelPrefix is the prefix for the labels of all jobs owned by the utility.
+const LabelPrefix = "com.amazon.ec2.macos-utils"
+
+// Label creates the label for the utility's job with the given name (e.g. "grow" becomes
+// "com.amazon.ec2.macos-utils.grow").
+func Label(name string) string {
+	return LabelPrefix + "." + name
+}
+
+// IsOwnedLabel checks if the label belongs to a job owned by the utility.
+func IsOwnedLabel(label string) bool {
+	return strings.HasPrefix(label, LabelPrefix+".")
+}
+
+// Job mirrors the keys of a launchd.plist(5) job definition. Only the keys used by the utility are provided.
+type Job struct {
+	Label                 string             `plist:"Label"`
+	Program               string             `plist:"Program,omitempty"`
+	ProgramArguments      []string           `plist:"ProgramArguments,omitempty"`
+	EnvironmentVariables  map[string]string  `plist:"EnvironmentVariables,omitempty"`
+	UserName              string             `plist:"UserName,omitempty"`
+	GroupName             string             `plist:"GroupName,omitempty"`
+	WorkingDirectory      string             `plist:"WorkingDirectory,omitempty"`
+	RunAtLoad             bool               `plist:"RunAtLoad,omitempty"`
+	KeepAlive             bool               `plist:"KeepAlive,omitempty"`
+	StartInterval         int                `plist:"StartInterval,omitempty"`
+	StartCalendarInterval []CalendarInterval `plist:"StartCalendarInterval,omitempty"`
+	ThrottleInterval      int                `plist:"ThrottleInterval,omitempty"`
+	StandardOutPath       string             `plist:"StandardOutPath,omitempty"`
+	StandardErrorPath     string             `plist:"StandardErrorPath,omitempty"`
+	ProcessType           string             `plist:"ProcessType,omitempty"`
+}
+
+// CalendarInterval runs a job when the time matches all of the set fields, similar to a crontab(5) entry. Fields
+// are pointers since 0 is a valid value for all of them and unset fields match any value.
+type CalendarInterval struct {
+	Minute  *int `plist:"Minute,omitempty"`
+	Hour    *int `plist:"Hour,omitempty"`
+	Day     *int `plist:"Day,omitempty"`
+	Weekday *int `plist:"Weekday,omitempty"`
+	Month   *int `plist:"Month,omitempty"`
+}
+
+// Validate checks that the job has the keys required by launchd.
+func (j *Job) Validate() error {
+	if strings.TrimSpace(j.Label) == "" {
+		return fmt.Errorf("job requires a label")
+	}
+	if j.Program == "" && len(j.ProgramArguments) == 0 {
+		return fmt.Errorf("job %s requires a program or program arguments", j.Label)
+	}
+
+	return nil
+}
+
+// Render writes the job as an XML property list to w.
+func (j *Job) Render(w io.Writer) error {
+	if err := j.Validate(); err != nil {
+		return err
+	}
+
+	enc := plist.NewEncoderForFormat(w, plist.XMLFormat)
+	enc.Indent("\t")
+	if err := enc.Encode(j); err != nil {
+		return fmt.Errorf("error encoding job %s: %w", j.Label, err)
+	}
+
+	// The encoder doesn't terminate the document with a newline.
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+// Bytes renders the job into its XML property list.
+func (j *Job) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := j.Render(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// DecodeJob decodes a job from the raw property list data in the reader.
+func DecodeJob(reader io.ReadSeeker) (*Job, error) {
+	job := &Job{}
+	if err := plist.NewDecoder(reader).Decode(job); err != nil {
+		return nil, fmt.Errorf("error decoding job: %w", err)
+	}
+
+	return job, nil
+}
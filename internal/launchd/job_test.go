@@ -0,0 +1,77 @@
+package launchd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testJobPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+	<dict>
+		<key>Label</key>
+		<string>com.amazon.ec2.macos-utils.test</string>
+		<key>ProgramArguments</key>
+		<array>
+			<string>/usr/local/bin/ec2-macos-utils</string>
+			<string>grow</string>
+		</array>
+		<key>RunAtLoad</key>
+		<true/>
+		<key>StartCalendarInterval</key>
+		<array>
+			<dict>
+				<key>Hour</key>
+				<integer>2</integer>
+				<key>Minute</key>
+				<integer>0</integer>
+			</dict>
+		</array>
+	</dict>
+</plist>
+`
+
+func TestLabel(t *testing.T) {
+	label := Label("test")
+
+	assert.Equal(t, "com.amazon.ec2.macos-utils.test", label)
+	assert.True(t, IsOwnedLabel(label))
+	assert.False(t, IsOwnedLabel("com.apple.test"))
+	assert.False(t, IsOwnedLabel(LabelPrefix))
+}
+
+func TestJob_Validate(t *testing.T) {
+	assert.Error(t, (&Job{}).Validate(), "should require label")
+	assert.Error(t, (&Job{Label: Label("test")}).Validate(), "should require program")
+	assert.NoError(t, (&Job{Label: Label("test"), Program: "/bin/true"}).Validate())
+}
+
+func TestJob_Render(t *testing.T) {
+	minute, hour := 0, 2
+	job := &Job{
+		Label:            Label("test"),
+		ProgramArguments: []string{"/usr/local/bin/ec2-macos-utils", "grow"},
+		RunAtLoad:        true,
+		StartCalendarInterval: []CalendarInterval{
+			{Minute: &minute, Hour: &hour},
+		},
+	}
+
+	data, err := job.Bytes()
+
+	assert.NoError(t, err, "should be able to render valid job")
+	assert.Equal(t, testJobPlist, string(data))
+}
+
+func TestDecodeJob(t *testing.T) {
+	job, err := DecodeJob(bytes.NewReader([]byte(testJobPlist)))
+
+	assert.NoError(t, err, "should be able to decode rendered job")
+	assert.Equal(t, Label("test"), job.Label)
+	assert.Equal(t, []string{"/usr/local/bin/ec2-macos-utils", "grow"}, job.ProgramArguments)
+	assert.True(t, job.RunAtLoad)
+	assert.Len(t, job.StartCalendarInterval, 1)
+	assert.Equal(t, 2, *job.StartCalendarInterval[0].Hour)
+}
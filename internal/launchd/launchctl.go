@@ -0,0 +1,280 @@
+package launchd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// DaemonDir is the directory for system-wide daemons run as root on boot.
+	DaemonDir = "/Library/LaunchDaemons"
+
+	// SystemDomain is the launchd domain that system-wide daemons are bootstrapped into.
+	SystemDomain = "system"
+
+	// notFoundExitCode is the exit code launchctl reports when a service isn't found in its domain.
+	notFoundExitCode = 113
+)
+
+// ErrNotLoaded identifies errors due to a service not being loaded in its launchd domain.
+var ErrNotLoaded = errors.New("service not loaded")
+
+// Status is the state of a loaded service reported by "launchctl print".
+type Status struct {
+	// Label is the service's label.
+	Label string
+	// Path is the job definition the service was loaded from.
+	Path string
+	// State is the service's state (e.g. "running", "not running").
+	State string
+	// PID is the process ID of the running service or 0 when it isn't running.
+	PID int
+	// Runs is the number of times the service has been started since it was loaded.
+	Runs int
+	// LastExitCode is the last exit status reported by the service or "(never exited)".
+	LastExitCode string
+}
+
+// Running checks if the service has a running process.
+func (s *Status) Running() bool {
+	return s.State == "running"
+}
+
+// Manager installs and controls jobs in a launchd domain.
+type Manager struct {
+	// Dir is the directory where job definitions are installed.
+	Dir string
+	// Domain is the launchd domain that jobs are bootstrapped into.
+	Domain string
+}
+
+// NewDaemonManager creates a new Manager for system-wide daemons.
+func NewDaemonManager() *Manager {
+	return &Manager{
+		Dir:    DaemonDir,
+		Domain: SystemDomain,
+	}
+}
+
+// Path gets the path to the job definition for the label.
+func (m *Manager) Path(label string) string {
+	return filepath.Join(m.Dir, label+".plist")
+}
+
+// target gets the launchctl service target for the label (e.g. system/com.amazon.ec2.macos-utils.grow).
+func (m *Manager) target(label string) string {
+	return m.Domain + "/" + label
+}
+
+// Install writes the job's definition and (re)loads it into the domain. Jobs with an identical definition that are
+// already loaded are left as-is. Install reports whether any changes were made.
+func (m *Manager) Install(ctx context.Context, job *Job) (bool, error) {
+	data, err := job.Bytes()
+	if err != nil {
+		return false, err
+	}
+
+	path := m.Path(job.Label)
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	_, statusErr := m.Status(ctx, job.Label)
+	loaded := statusErr == nil
+	if bytes.Equal(existing, data) && loaded {
+		logrus.WithField("label", job.Label).Debug("Job already installed")
+		return false, nil
+	}
+
+	if err := util.WriteFileAtomic(path, data, 0644); err != nil {
+		return false, fmt.Errorf("cannot write job definition: %w", err)
+	}
+
+	// Definitions are only read when bootstrapped so loaded jobs must be removed first to pick up changes.
+	if loaded {
+		if err := m.Bootout(ctx, job.Label); err != nil && !errors.Is(err, ErrNotLoaded) {
+			return true, err
+		}
+	}
+	if err := m.Bootstrap(ctx, path); err != nil {
+		return true, err
+	}
+	logrus.WithField("label", job.Label).Info("Installed launchd job")
+
+	return true, nil
+}
+
+// Uninstall unloads the job with the label from the domain and removes its definition. Uninstall reports whether any
+// changes were made.
+func (m *Manager) Uninstall(ctx context.Context, label string) (bool, error) {
+	changed := false
+	if err := m.Bootout(ctx, label); err == nil {
+		changed = true
+	} else if !errors.Is(err, ErrNotLoaded) {
+		return false, err
+	}
+
+	if err := os.Remove(m.Path(label)); err == nil {
+		changed = true
+	} else if !os.IsNotExist(err) {
+		return changed, err
+	}
+	if changed {
+		logrus.WithField("label", label).Info("Uninstalled launchd job")
+	}
+
+	return changed, nil
+}
+
+// Installed lists the labels of the utility's jobs that have definitions in the manager's directory.
+func (m *Manager) Installed() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(m.Dir, LabelPrefix+".*.plist"))
+	if err != nil {
+		return nil, err
+	}
+
+	var labels []string
+	for _, p := range paths {
+		labels = append(labels, strings.TrimSuffix(filepath.Base(p), ".plist"))
+	}
+	sort.Strings(labels)
+
+	return labels, nil
+}
+
+// Bootstrap loads the job definition at path into the domain.
+func (m *Manager) Bootstrap(ctx context.Context, path string) error {
+	// Load the service with launchctl's bootstrap verb.
+	//   * bootstrap - load the service into the domain
+	//   * domain - the domain to load the service into (e.g. system)
+	//   * path - the path to the service's job definition
+	_, err := launchctl(ctx, "bootstrap", m.Domain, path)
+
+	return err
+}
+
+// Bootout unloads the job with the label from the domain, stopping it if it's running.
+func (m *Manager) Bootout(ctx context.Context, label string) error {
+	// Unload the service with launchctl's bootout verb.
+	//   * bootout - unload the service from the domain
+	//   * target - the service target (e.g. system/<label>)
+	_, err := launchctl(ctx, "bootout", m.target(label))
+
+	return err
+}
+
+// Enable allows the job with the label to be loaded, overriding a Disabled key in its job definition and any
+// earlier Disable. The override persists across reboots.
+func (m *Manager) Enable(ctx context.Context, label string) error {
+	// Enable the service with launchctl's enable verb.
+	//   * enable - allow the service to be loaded
+	//   * target - the service target (e.g. system/<label>)
+	_, err := launchctl(ctx, "enable", m.target(label))
+
+	return err
+}
+
+// Disable prevents the job with the label from being loaded. The override persists across reboots but doesn't
+// unload the job if it's already loaded.
+func (m *Manager) Disable(ctx context.Context, label string) error {
+	// Disable the service with launchctl's disable verb.
+	//   * disable - prevent the service from being loaded
+	//   * target - the service target (e.g. system/<label>)
+	_, err := launchctl(ctx, "disable", m.target(label))
+
+	return err
+}
+
+// Kickstart starts the job with the label immediately. Running jobs are restarted if restart is set.
+func (m *Manager) Kickstart(ctx context.Context, label string, restart bool) error {
+	// Start the service with launchctl's kickstart verb.
+	//   * kickstart - start the service
+	//   * -k - kill the running instance before restarting the service
+	//   * target - the service target (e.g. system/<label>)
+	args := []string{"kickstart"}
+	if restart {
+		args = append(args, "-k")
+	}
+	args = append(args, m.target(label))
+	_, err := launchctl(ctx, args...)
+
+	return err
+}
+
+// Status fetches the status of the loaded job with the label. ErrNotLoaded is returned when the job isn't loaded.
+func (m *Manager) Status(ctx context.Context, label string) (*Status, error) {
+	// Inspect the service with launchctl's print verb.
+	//   * print - print the service's state
+	//   * target - the service target (e.g. system/<label>)
+	out, err := launchctl(ctx, "print", m.target(label))
+	if err != nil {
+		return nil, err
+	}
+
+	status := parseStatus(out)
+	status.Label = label
+
+	return status, nil
+}
+
+// launchctl runs launchctl with the given arguments and returns its output. Failures due to missing services are
+// identified with ErrNotLoaded.
+func launchctl(ctx context.Context, args ...string) (string, error) {
+	cmd := append([]string{"launchctl"}, args...)
+	out, err := util.ExecuteCommand(ctx, cmd, "", nil, nil)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == notFoundExitCode {
+			return out.Stdout, fmt.Errorf("launchctl %s: %w", args[0], ErrNotLoaded)
+		}
+		return out.Stdout, fmt.Errorf("launchd: failed to run launchctl %s, stderr: [%s]: %w", args[0], strings.TrimSpace(out.Stderr), err)
+	}
+
+	return out.Stdout, nil
+}
+
+// statusExp matches the service's top-level "key = value" properties which are indented by a single tab.
+var statusExp = regexp.MustCompile(`^\t([^\t=][^=]*?) = (.*)$`)
+
+// parseStatus parses the human-readable output of "launchctl print" for a service.
+func parseStatus(raw string) *Status {
+	status := &Status{}
+
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	for scanner.Scan() {
+		m := statusExp.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		key, value := m[1], strings.TrimSpace(m[2])
+		switch key {
+		case "path":
+			status.Path = value
+		case "state":
+			status.State = value
+		case "pid":
+			status.PID, _ = strconv.Atoi(value)
+		case "runs":
+			status.Runs, _ = strconv.Atoi(value)
+		case "last exit code":
+			status.LastExitCode = value
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,63 @@
+package launchd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPrintOutput = `system/com.amazon.ec2.macos-utils.test = {
+	active count = 1
+	path = /Library/LaunchDaemons/com.amazon.ec2.macos-utils.test.plist
+	state = running
+
+	program = /usr/local/bin/ec2-macos-utils
+	arguments = {
+		/usr/local/bin/ec2-macos-utils
+		grow
+	}
+
+	runs = 3
+	pid = 421
+	last exit code = 0
+
+	properties = runatload
+}
+`
+
+func TestParseStatus(t *testing.T) {
+	status := parseStatus(testPrintOutput)
+
+	assert.Equal(t, &Status{
+		Path:         "/Library/LaunchDaemons/com.amazon.ec2.macos-utils.test.plist",
+		State:        "running",
+		PID:          421,
+		Runs:         3,
+		LastExitCode: "0",
+	}, status)
+	assert.True(t, status.Running())
+}
+
+func TestParseStatus_NeverExited(t *testing.T) {
+	status := parseStatus("system/test = {\n\tstate = not running\n\tlast exit code = (never exited)\n}\n")
+
+	assert.False(t, status.Running())
+	assert.Equal(t, "(never exited)", status.LastExitCode)
+	assert.Zero(t, status.PID)
+}
+
+func TestManager_Installed(t *testing.T) {
+	dir := t.TempDir()
+	m := &Manager{Dir: dir, Domain: SystemDomain}
+	for _, name := range []string{Label("status") + ".plist", Label("grow") + ".plist", "com.apple.other.plist"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	labels, err := m.Installed()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{Label("grow"), Label("status")}, labels, "should only list the utility's jobs")
+	assert.Equal(t, filepath.Join(dir, Label("grow")+".plist"), m.Path(Label("grow")))
+}
@@ -0,0 +1,266 @@
+// Package metrics provides the functionality necessary for exposing disk usage, operation, and command metrics in the
+// Prometheus text format, for customers who scrape their Mac fleet with their own monitoring.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/internal/telemetry"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/events"
+)
+
+const (
+	// Path is the path that metrics are served at.
+	Path = "/metrics"
+
+	// contentType is the content type of the Prometheus text format.
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+	// namespace prefixes the name of every metric.
+	namespace = "ec2_macos_utils"
+)
+
+// DurationBuckets are the upper bounds, in seconds, of the buckets that operation durations are counted in. Disk
+// operations range from a few seconds (e.g. repairs) to tens of minutes (e.g. restores).
+var DurationBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800}
+
+// operationKey identifies the counter for an operation's outcome.
+type operationKey struct {
+	operation events.Operation
+	outcome   events.Kind
+}
+
+// histogram counts observations in cumulative buckets.
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// observe counts the value in each bucket it fits in.
+func (h *histogram) observe(v float64) {
+	for i, bound := range DurationBuckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// Collector is an events.Subscriber which counts the outcomes of operations and their durations.
+type Collector struct {
+	mu        sync.Mutex
+	outcomes  map[operationKey]uint64
+	durations map[events.Operation]*histogram
+}
+
+// NewCollector creates an empty Collector.
+func NewCollector() *Collector {
+	return &Collector{
+		outcomes:  make(map[operationKey]uint64),
+		durations: make(map[events.Operation]*histogram),
+	}
+}
+
+// Handle counts operations that finished or failed.
+func (c *Collector) Handle(e events.Event) {
+	if e.Kind == events.KindStarted {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.outcomes[operationKey{operation: e.Operation, outcome: e.Kind}]++
+	h, ok := c.durations[e.Operation]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(DurationBuckets))}
+		c.durations[e.Operation] = h
+	}
+	h.observe(e.Duration.Seconds())
+}
+
+// WriteText writes the operation metrics in the Prometheus text format.
+func (c *Collector) WriteText(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tw := &textWriter{w: w}
+
+	keys := make([]operationKey, 0, len(c.outcomes))
+	for k := range c.outcomes {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].operation != keys[j].operation {
+			return keys[i].operation < keys[j].operation
+		}
+		return keys[i].outcome < keys[j].outcome
+	})
+	name := namespace + "_operations_total"
+	tw.header(name, "counter", "Number of operations that ended, by outcome.")
+	for _, k := range keys {
+		tw.sample(name, labels{"operation", string(k.operation), "outcome", string(k.outcome)}, float64(c.outcomes[k]))
+	}
+
+	operations := make([]string, 0, len(c.durations))
+	for op := range c.durations {
+		operations = append(operations, string(op))
+	}
+	sort.Strings(operations)
+	name = namespace + "_operation_duration_seconds"
+	tw.header(name, "histogram", "Duration of operations that ended.")
+	for _, op := range operations {
+		h := c.durations[events.Operation(op)]
+		for i, bound := range DurationBuckets {
+			tw.sample(name+"_bucket", labels{"operation", op, "le", formatFloat(bound)}, float64(h.counts[i]))
+		}
+		tw.sample(name+"_bucket", labels{"operation", op, "le", "+Inf"}, float64(h.count))
+		tw.sample(name+"_sum", labels{"operation", op}, h.sum)
+		tw.sample(name+"_count", labels{"operation", op}, float64(h.count))
+	}
+
+	return tw.err
+}
+
+// WriteFilesystems writes the capacity of the local filesystems in the Prometheus text format. Filesystems without
+// any capacity (e.g. devfs) are left out.
+func WriteFilesystems(w io.Writer, filesystems []mounts.Filesystem) error {
+	tw := &textWriter{w: w}
+
+	gauges := []struct {
+		name  string
+		help  string
+		value func(fs mounts.Filesystem) uint64
+	}{
+		{name: "filesystem_size_bytes", help: "Size of the filesystem.", value: func(fs mounts.Filesystem) uint64 { return fs.TotalBytes }},
+		{name: "filesystem_free_bytes", help: "Free space in the filesystem.", value: func(fs mounts.Filesystem) uint64 { return fs.FreeBytes }},
+		{name: "filesystem_avail_bytes", help: "Free space in the filesystem available to unprivileged users.", value: func(fs mounts.Filesystem) uint64 { return fs.AvailableBytes }},
+	}
+	for _, g := range gauges {
+		name := namespace + "_" + g.name
+		tw.header(name, "gauge", g.help)
+		for _, fs := range filesystems {
+			if !fs.Local || fs.TotalBytes == 0 {
+				continue
+			}
+			tw.sample(name, labels{"device", fs.Device, "mount_point", fs.MountPoint, "type", fs.Type}, float64(g.value(fs)))
+		}
+	}
+
+	return tw.err
+}
+
+// WriteCommands writes the counters of each command's runs in the Prometheus text format.
+func WriteCommands(w io.Writer, counters telemetry.Counters) error {
+	tw := &textWriter{w: w}
+
+	commands := make([]string, 0, len(counters))
+	for command := range counters {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+
+	name := namespace + "_command_runs_total"
+	tw.header(name, "counter", "Number of times the command ran.")
+	for _, command := range commands {
+		tw.sample(name, labels{"command", command}, float64(counters[command].Runs))
+	}
+
+	name = namespace + "_command_failures_total"
+	tw.header(name, "counter", "Number of runs of the command that failed, by the class of their failure.")
+	for _, command := range commands {
+		classes := make([]string, 0, len(counters[command].Failures))
+		for class := range counters[command].Failures {
+			classes = append(classes, string(class))
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			tw.sample(name, labels{"command", command, "class", class}, float64(counters[command].Failures[diskutil.ErrClass(class)]))
+		}
+	}
+
+	name = namespace + "_command_last_run_timestamp_seconds"
+	tw.header(name, "gauge", "Time the command last ran, in seconds since the Unix epoch.")
+	for _, command := range commands {
+		tw.sample(name, labels{"command", command}, float64(counters[command].LastRun.Unix()))
+	}
+
+	return tw.err
+}
+
+// Handler serves the collector's metrics along with the counters of the commands' runs and the capacity of the
+// filesystems that are mounted when the metrics are scraped. Failing to read the counters or the mounted
+// filesystems is reported to onError (when set) and only leaves out their metrics.
+func Handler(c *Collector, commands func() (telemetry.Counters, error), mounted func() ([]mounts.Filesystem, error), onError func(err error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counters, commandsErr := commands()
+		filesystems, mountErr := mounted()
+		if onError != nil {
+			err := commandsErr
+			if err == nil {
+				err = mountErr
+			}
+			onError(err)
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		if err := c.WriteText(w); err != nil {
+			return
+		}
+		if commandsErr == nil {
+			if err := WriteCommands(w, counters); err != nil {
+				return
+			}
+		}
+		if mountErr == nil {
+			WriteFilesystems(w, filesystems)
+		}
+	})
+}
+
+// labels are the names and values of a sample's labels, in pairs.
+type labels []string
+
+// textWriter writes the Prometheus text format, keeping the first error so that callers only check it once.
+type textWriter struct {
+	w   io.Writer
+	err error
+}
+
+// header writes the HELP and TYPE lines of a metric.
+func (t *textWriter) header(name, kind, help string) {
+	t.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes a sample of a metric.
+func (t *textWriter) sample(name string, l labels, value float64) {
+	var pairs []string
+	for i := 0; i+1 < len(l); i += 2 {
+		pairs = append(pairs, l[i]+`="`+labelEscaper.Replace(l[i+1])+`"`)
+	}
+	t.printf("%s{%s} %s\n", name, strings.Join(pairs, ","), formatFloat(value))
+}
+
+func (t *textWriter) printf(format string, args ...interface{}) {
+	if t.err != nil {
+		return
+	}
+	_, t.err = fmt.Fprintf(t.w, format, args...)
+}
+
+// labelEscaper escapes the characters that the text format doesn't allow in label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatFloat formats the value as the text format expects.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/internal/telemetry"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/events"
+)
+
+func TestCollector_WriteText(t *testing.T) {
+	c := NewCollector()
+	c.Handle(events.Event{Operation: events.OperationGrow, Kind: events.KindStarted})
+	c.Handle(events.Event{Operation: events.OperationGrow, Kind: events.KindFinished, Duration: 20 * time.Second})
+	c.Handle(events.Event{Operation: events.OperationGrow, Kind: events.KindFailed, Duration: 2 * time.Second})
+
+	var b strings.Builder
+	assert.NoError(t, c.WriteText(&b))
+
+	out := b.String()
+	assert.Contains(t, out, "# TYPE ec2_macos_utils_operations_total counter\n")
+	assert.Contains(t, out, `ec2_macos_utils_operations_total{operation="grow",outcome="failed"} 1`+"\n")
+	assert.Contains(t, out, `ec2_macos_utils_operations_total{operation="grow",outcome="finished"} 1`+"\n")
+	assert.Contains(t, out, `ec2_macos_utils_operation_duration_seconds_bucket{operation="grow",le="1"} 0`+"\n")
+	assert.Contains(t, out, `ec2_macos_utils_operation_duration_seconds_bucket{operation="grow",le="5"} 1`+"\n")
+	assert.Contains(t, out, `ec2_macos_utils_operation_duration_seconds_bucket{operation="grow",le="30"} 2`+"\n")
+	assert.Contains(t, out, `ec2_macos_utils_operation_duration_seconds_bucket{operation="grow",le="+Inf"} 2`+"\n")
+	assert.Contains(t, out, `ec2_macos_utils_operation_duration_seconds_sum{operation="grow"} 22`+"\n")
+	assert.Contains(t, out, `ec2_macos_utils_operation_duration_seconds_count{operation="grow"} 2`+"\n")
+}
+
+func TestWriteFilesystems(t *testing.T) {
+	filesystems := []mounts.Filesystem{
+		{Device: "/dev/disk3s1", MountPoint: `/Volumes/"data"`, Type: "apfs", Local: true, TotalBytes: 100, FreeBytes: 40, AvailableBytes: 30},
+		{Device: "devfs", MountPoint: "/dev", Type: "devfs", Local: true},
+		{Device: "server:/export", MountPoint: "/Volumes/nfs", Type: "nfs", TotalBytes: 100},
+	}
+
+	var b strings.Builder
+	assert.NoError(t, WriteFilesystems(&b, filesystems))
+
+	out := b.String()
+	assert.Contains(t, out, `ec2_macos_utils_filesystem_size_bytes{device="/dev/disk3s1",mount_point="/Volumes/\"data\"",type="apfs"} 100`+"\n")
+	assert.Contains(t, out, `ec2_macos_utils_filesystem_free_bytes{device="/dev/disk3s1",mount_point="/Volumes/\"data\"",type="apfs"} 40`+"\n")
+	assert.Contains(t, out, `ec2_macos_utils_filesystem_avail_bytes{device="/dev/disk3s1",mount_point="/Volumes/\"data\"",type="apfs"} 30`+"\n")
+	assert.NotContains(t, out, "devfs", "filesystems without capacity should be left out")
+	assert.NotContains(t, out, "nfs", "network filesystems should be left out")
+}
+
+func TestHandler_MountedErr(t *testing.T) {
+	var reported error
+	h := Handler(NewCollector(), noCommands, func() ([]mounts.Filesystem, error) {
+		return nil, mounts.ErrUnsupported
+	}, func(err error) {
+		reported = err
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, contentType, rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "ec2_macos_utils_operations_total")
+	assert.NotContains(t, rec.Body.String(), "filesystem_size_bytes")
+	assert.True(t, errors.Is(reported, mounts.ErrUnsupported))
+}
+
+func noCommands() (telemetry.Counters, error) {
+	return telemetry.Counters{}, nil
+}
+
+func TestWriteCommands(t *testing.T) {
+	counters := telemetry.Counters{
+		"ssh sync": {Runs: 2, Successes: 2, LastRun: time.Unix(1685620800, 0)},
+		"grow": {
+			Runs:      3,
+			Successes: 1,
+			Failures:  map[diskutil.ErrClass]uint64{diskutil.ClassUnknown: 1, diskutil.ClassBusy: 1},
+			LastRun:   time.Unix(1685624400, 0),
+		},
+	}
+
+	var b strings.Builder
+	assert.NoError(t, WriteCommands(&b, counters))
+
+	assert.Equal(t, `# HELP ec2_macos_utils_command_runs_total Number of times the command ran.
+# TYPE ec2_macos_utils_command_runs_total counter
+ec2_macos_utils_command_runs_total{command="grow"} 3
+ec2_macos_utils_command_runs_total{command="ssh sync"} 2
+# HELP ec2_macos_utils_command_failures_total Number of runs of the command that failed, by the class of their failure.
+# TYPE ec2_macos_utils_command_failures_total counter
+ec2_macos_utils_command_failures_total{command="grow",class="busy"} 1
+ec2_macos_utils_command_failures_total{command="grow",class="unknown"} 1
+# HELP ec2_macos_utils_command_last_run_timestamp_seconds Time the command last ran, in seconds since the Unix epoch.
+# TYPE ec2_macos_utils_command_last_run_timestamp_seconds gauge
+ec2_macos_utils_command_last_run_timestamp_seconds{command="grow"} 1.6856244e+09
+ec2_macos_utils_command_last_run_timestamp_seconds{command="ssh sync"} 1.6856208e+09
+`, b.String())
+}
+
+func TestHandler_CommandsErr(t *testing.T) {
+	var reported error
+	h := Handler(NewCollector(), func() (telemetry.Counters, error) {
+		return nil, errors.New("invalid counters")
+	}, func() ([]mounts.Filesystem, error) {
+		return []mounts.Filesystem{{Device: "/dev/disk3s1", MountPoint: "/", Type: "apfs", Local: true, TotalBytes: 100}}, nil
+	}, func(err error) {
+		reported = err
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "command_runs_total")
+	assert.Contains(t, rec.Body.String(), "filesystem_size_bytes")
+	assert.EqualError(t, reported, "invalid counters")
+}
@@ -0,0 +1,41 @@
+package mounts
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/ec2-macos-utils/internal/flock"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// writeFileAtomic writes the contents to path with util.WriteFileAtomic.
+func writeFileAtomic(path string, contents io.WriterTo, perm os.FileMode) error {
+	var buf bytes.Buffer
+	if _, err := contents.WriteTo(&buf); err != nil {
+		return fmt.Errorf("cannot write %s: %w", path, err)
+	}
+
+	return util.WriteFileAtomic(path, buf.Bytes(), perm)
+}
+
+// lockFile takes an exclusive advisory lock for editing the file at path. The file's directory is locked rather than
+// the file itself since edits replace the file (and its inode) when they're swapped into place.
+func lockFile(path string) (unlock func(), err error) {
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := flock.Lock(dir); err != nil {
+		dir.Close()
+		return nil, err
+	}
+
+	return func() {
+		flock.Unlock(dir)
+		dir.Close()
+	}, nil
+}
@@ -0,0 +1,286 @@
+// Package mounts provides the functionality necessary for persisting mounts on macOS via /etc/fstab and
+// /etc/synthetic.conf.
+package mounts
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FstabPath is the path to the filesystem table read by macOS's automounter on boot.
+	FstabPath = "/etc/fstab"
+
+	// managedMarker is the comment placed before fstab and synthetic.conf entries that were created by this utility
+	// so that they can be told apart from entries created by other tools or by hand.
+	managedMarker = "# managed by ec2-macos-utils"
+)
+
+// FstabEntry is a single filesystem entry described by fstab(5).
+type FstabEntry struct {
+	// Spec is the special device or remote filesystem to be mounted. Using UUID=<VolumeUUID> is preferred since
+	// device identifiers aren't stable across boots.
+	Spec string
+	// File is the mount point for the filesystem.
+	File string
+	// VfsType is the type of the filesystem (e.g. apfs, hfs, exfat).
+	VfsType string
+	// MntOps is the comma separated list of mount options.
+	MntOps string
+	// Freq is the dump(8) frequency and is almost always 0.
+	Freq int
+	// PassNo is the fsck(8) pass number and is almost always 0.
+	PassNo int
+	// Managed indicates that the entry was created by this utility.
+	Managed bool
+}
+
+// defaultMntOps are the mount options used for entries that don't specify any.
+const defaultMntOps = "rw,auto"
+
+// String formats the entry as an fstab(5) line. Whitespace in fields is escaped as "\040".
+func (e FstabEntry) String() string {
+	opts := e.MntOps
+	if opts == "" {
+		opts = defaultMntOps
+	}
+	line := fmt.Sprintf("%s %s %s %s", escapeField(e.Spec), escapeField(e.File), e.VfsType, opts)
+	if e.Freq != 0 || e.PassNo != 0 {
+		line += fmt.Sprintf(" %d %d", e.Freq, e.PassNo)
+	}
+
+	return line
+}
+
+// fstabLine is a line in the filesystem table. Lines that aren't entries (e.g. comments and blank lines) are kept
+// verbatim so that they survive rewrites.
+type fstabLine struct {
+	raw   string
+	entry *FstabEntry
+}
+
+// Fstab holds the contents of a filesystem table.
+type Fstab struct {
+	lines []fstabLine
+}
+
+// ParseFstab reads a filesystem table from the reader.
+func ParseFstab(r io.Reader) (*Fstab, error) {
+	tab := &Fstab{}
+	managed := false
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		raw := scanner.Text()
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == managedMarker {
+			managed = true
+			continue
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			tab.lines = append(tab.lines, fstabLine{raw: raw})
+			managed = false
+			continue
+		}
+
+		entry, err := parseFstabEntry(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("fstab line %d: %w", n, err)
+		}
+		entry.Managed = managed
+		managed = false
+		tab.lines = append(tab.lines, fstabLine{raw: raw, entry: entry})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return tab, nil
+}
+
+// parseFstabEntry parses the whitespace separated fields of a single fstab entry.
+func parseFstabEntry(line string) (*FstabEntry, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("expected at least 3 fields but got [%d]", len(fields))
+	}
+
+	entry := &FstabEntry{
+		Spec:    unescapeField(fields[0]),
+		File:    unescapeField(fields[1]),
+		VfsType: fields[2],
+	}
+	if len(fields) > 3 {
+		entry.MntOps = fields[3]
+	}
+	if len(fields) > 4 {
+		freq, err := strconv.Atoi(fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("invalid dump frequency: %w", err)
+		}
+		entry.Freq = freq
+	}
+	if len(fields) > 5 {
+		passNo, err := strconv.Atoi(fields[5])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pass number: %w", err)
+		}
+		entry.PassNo = passNo
+	}
+
+	return entry, nil
+}
+
+// Entries returns all filesystem entries in the table.
+func (t *Fstab) Entries() []FstabEntry {
+	var entries []FstabEntry
+	for _, l := range t.lines {
+		if l.entry != nil {
+			entries = append(entries, *l.entry)
+		}
+	}
+
+	return entries
+}
+
+// Lookup finds the entry for the given spec or mount point.
+func (t *Fstab) Lookup(specOrFile string) (FstabEntry, bool) {
+	for _, l := range t.lines {
+		if l.entry != nil && l.entry.matches(specOrFile) {
+			return *l.entry, true
+		}
+	}
+
+	return FstabEntry{}, false
+}
+
+// Set adds the entry to the table or replaces the existing entry with the same spec or mount point. Other entries
+// with the same spec or mount point are removed since only one of them can be mounted. Set reports whether the table
+// was changed.
+func (t *Fstab) Set(entry FstabEntry) bool {
+	if entry.MntOps == "" {
+		entry.MntOps = defaultMntOps
+	}
+
+	changed, found := false, false
+	lines := t.lines[:0]
+	for _, l := range t.lines {
+		if l.entry == nil || !(l.entry.matches(entry.Spec) || l.entry.matches(entry.File)) {
+			lines = append(lines, l)
+			continue
+		}
+		if found {
+			changed = true
+			continue
+		}
+		found = true
+		if *l.entry != entry {
+			e := entry
+			l = fstabLine{entry: &e}
+			changed = true
+		}
+		lines = append(lines, l)
+	}
+	t.lines = lines
+
+	if !found {
+		e := entry
+		t.lines = append(t.lines, fstabLine{entry: &e})
+		changed = true
+	}
+
+	return changed
+}
+
+// Remove deletes the entry for the given spec or mount point. Remove reports whether the table was changed.
+func (t *Fstab) Remove(specOrFile string) bool {
+	for i, l := range t.lines {
+		if l.entry != nil && l.entry.matches(specOrFile) {
+			t.lines = append(t.lines[:i], t.lines[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// WriteTo writes the table in the fstab(5) format. Unmodified lines are written as they were read.
+func (t *Fstab) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, l := range t.lines {
+		var text string
+		switch {
+		case l.entry == nil, l.raw != "" && !l.entry.Managed:
+			text = l.raw + "\n"
+		case l.entry.Managed:
+			text = managedMarker + "\n" + l.entry.String() + "\n"
+		default:
+			text = l.entry.String() + "\n"
+		}
+		n, err := io.WriteString(w, text)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// matches checks if the entry's spec or mount point is s.
+func (e *FstabEntry) matches(s string) bool {
+	return s != "" && (strings.EqualFold(e.Spec, s) || e.File == s)
+}
+
+// ReadFstab reads the filesystem table at path. A missing file is treated as an empty table.
+func ReadFstab(path string) (*Fstab, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &Fstab{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseFstab(f)
+}
+
+// EditFstab edits the filesystem table at path with vifs(8) semantics: the table is locked against concurrent edits,
+// edit is applied to its parsed contents, and the result is atomically swapped into place. The table is only
+// rewritten when edit reports a change.
+func EditFstab(path string, edit func(tab *Fstab) bool) (bool, error) {
+	unlock, err := lockFile(path)
+	if err != nil {
+		return false, fmt.Errorf("cannot lock %s: %w", path, err)
+	}
+	defer unlock()
+
+	tab, err := ReadFstab(path)
+	if err != nil {
+		return false, err
+	}
+
+	if !edit(tab) {
+		return false, nil
+	}
+
+	if err := writeFileAtomic(path, tab, 0644); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// escapeField encodes whitespace in an fstab field as octal escapes.
+func escapeField(s string) string {
+	return strings.NewReplacer(" ", "\\040", "\t", "\\011").Replace(s)
+}
+
+// unescapeField decodes octal escapes for whitespace in an fstab field.
+func unescapeField(s string) string {
+	return strings.NewReplacer("\\040", " ", "\\011", "\t").Replace(s)
+}
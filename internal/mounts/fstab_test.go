@@ -0,0 +1,112 @@
+package mounts
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testFstab = `# Warning - this file should only be modified with vifs(8)
+UUID=AAAAAAAA-BBBB-CCCC-DDDD-FFFFFFFFFFFF /Volumes/Build\040Cache apfs rw,auto
+
+# managed by ec2-macos-utils
+UUID=11111111-2222-3333-4444-555555555555 /data hfs rw,auto 0 2
+`
+
+func TestParseFstab(t *testing.T) {
+	tab, err := ParseFstab(strings.NewReader(testFstab))
+
+	assert.NoError(t, err, "should be able to parse fstab")
+	assert.Equal(t, []FstabEntry{
+		{
+			Spec:    "UUID=AAAAAAAA-BBBB-CCCC-DDDD-FFFFFFFFFFFF",
+			File:    "/Volumes/Build Cache",
+			VfsType: "apfs",
+			MntOps:  "rw,auto",
+		},
+		{
+			Spec:    "UUID=11111111-2222-3333-4444-555555555555",
+			File:    "/data",
+			VfsType: "hfs",
+			MntOps:  "rw,auto",
+			PassNo:  2,
+			Managed: true,
+		},
+	}, tab.Entries())
+}
+
+func TestParseFstab_WithInvalidEntry(t *testing.T) {
+	_, err := ParseFstab(strings.NewReader("UUID=AAAA /data\n"))
+
+	assert.Error(t, err, "shouldn't parse entry without type")
+}
+
+func TestFstab_RoundTrip(t *testing.T) {
+	tab, err := ParseFstab(strings.NewReader(testFstab))
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = tab.WriteTo(&buf)
+
+	assert.NoError(t, err)
+	assert.Equal(t, testFstab, buf.String(), "unchanged table should be written as it was read")
+}
+
+func TestFstab_SetAndRemove(t *testing.T) {
+	tab, err := ParseFstab(strings.NewReader(testFstab))
+	assert.NoError(t, err)
+
+	entry := FstabEntry{Spec: "UUID=NEW", File: "/Volumes/Scratch", VfsType: "apfs", Managed: true}
+	assert.True(t, tab.Set(entry), "should add new entry")
+	assert.False(t, tab.Set(entry), "shouldn't change identical entry")
+
+	entry.VfsType = "hfs"
+	assert.True(t, tab.Set(entry), "should replace entry with same spec")
+
+	got, ok := tab.Lookup("/Volumes/Scratch")
+	assert.True(t, ok)
+	entry.MntOps = defaultMntOps
+	assert.Equal(t, entry, got, "should default mount options")
+
+	assert.True(t, tab.Remove("UUID=NEW"), "should remove entry")
+	assert.False(t, tab.Remove("UUID=NEW"), "shouldn't remove missing entry")
+	assert.Len(t, tab.Entries(), 2)
+}
+
+func TestFstab_SetDuplicates(t *testing.T) {
+	tab, err := ParseFstab(strings.NewReader("UUID=OLD /data apfs rw,auto\nUUID=OTHER /Volumes/Other apfs rw,auto\nUUID=STALE /data hfs rw,auto\n"))
+	assert.NoError(t, err)
+
+	assert.True(t, tab.Set(FstabEntry{Spec: "UUID=NEW", File: "/data", VfsType: "apfs", Managed: true}))
+
+	var buf bytes.Buffer
+	_, err = tab.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "# managed by ec2-macos-utils\nUUID=NEW /data apfs rw,auto\nUUID=OTHER /Volumes/Other apfs rw,auto\n", buf.String(),
+		"other entries for the mount point should be removed")
+	assert.False(t, tab.Set(FstabEntry{Spec: "UUID=NEW", File: "/data", VfsType: "apfs", Managed: true}))
+}
+
+func TestEditFstab(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fstab")
+
+	changed, err := EditFstab(path, func(tab *Fstab) bool {
+		return tab.Set(FstabEntry{Spec: "UUID=NEW", File: "/Volumes/Scratch", VfsType: "apfs", Managed: true})
+	})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	changed, err = EditFstab(path, func(tab *Fstab) bool {
+		return tab.Set(FstabEntry{Spec: "UUID=NEW", File: "/Volumes/Scratch", VfsType: "apfs", Managed: true})
+	})
+	assert.NoError(t, err)
+	assert.False(t, changed, "shouldn't rewrite unchanged table")
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "# managed by ec2-macos-utils\nUUID=NEW /Volumes/Scratch apfs rw,auto\n", string(data))
+}
@@ -0,0 +1,160 @@
+package mounts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// Manager persists mounts by editing the filesystem table and synthetic configuration at the configured paths.
+type Manager struct {
+	// FstabPath is the path to the filesystem table.
+	FstabPath string
+	// SyntheticPath is the path to the synthetic configuration.
+	SyntheticPath string
+	// Product is the macOS product the mounts are managed for.
+	Product *system.Product
+}
+
+// NewManager creates a new Manager for the system's filesystem table and synthetic configuration.
+func NewManager(p *system.Product) *Manager {
+	return &Manager{
+		FstabPath:     FstabPath,
+		SyntheticPath: SyntheticPath,
+		Product:       p,
+	}
+}
+
+// Persist ensures that the entry's filesystem is mounted at its mount point on every boot. Mount points at the root
+// of the filesystem are added to the synthetic configuration on releases with a read-only system volume since they
+// can't otherwise be created. All other mount points are created as directories if they don't already exist.
+//
+// Persist reports whether any changes were made.
+func (m *Manager) Persist(ctx context.Context, entry FstabEntry) (bool, error) {
+	if entry.Spec == "" {
+		return false, errors.New("fstab entry requires a spec")
+	}
+	if !filepath.IsAbs(entry.File) {
+		return false, fmt.Errorf("mount point must be an absolute path: %s", entry.File)
+	}
+
+	linked, err := m.EnsureMountPoint(ctx, entry.File)
+	if err != nil {
+		return false, fmt.Errorf("cannot create mount point: %w", err)
+	}
+
+	entry.Managed = true
+	changed, err := EditFstab(m.FstabPath, func(tab *Fstab) bool {
+		return tab.Set(entry)
+	})
+	if err != nil {
+		return false, fmt.Errorf("cannot update fstab: %w", err)
+	}
+	if changed {
+		logrus.WithFields(logrus.Fields{
+			"spec":        entry.Spec,
+			"mount_point": entry.File,
+		}).Info("Persisted mount in fstab")
+	}
+
+	return changed || linked, nil
+}
+
+// Remove removes the fstab entry for the given spec or mount point along with the synthetic empty directory that
+// was created for its mount point by EnsureMountPoint. Synthetic entries that weren't created by this utility are
+// kept. Remove reports whether any changes were made.
+func (m *Manager) Remove(ctx context.Context, specOrFile string) (bool, error) {
+	tab, err := ReadFstab(m.FstabPath)
+	if err != nil {
+		return false, err
+	}
+	entry, ok := tab.Lookup(specOrFile)
+	if !ok {
+		return false, nil
+	}
+
+	changed, err := EditFstab(m.FstabPath, func(tab *Fstab) bool {
+		return tab.Remove(specOrFile)
+	})
+	if err != nil {
+		return false, fmt.Errorf("cannot update fstab: %w", err)
+	}
+
+	if name, ok := syntheticName(entry.File); ok {
+		removed, err := EditSynthetic(m.SyntheticPath, func(conf *Synthetic) bool {
+			if e, ok := conf.Lookup(name); !ok || !e.Managed || e.Target != "" {
+				return false
+			}
+			return conf.Remove(name)
+		})
+		if err != nil {
+			return changed, fmt.Errorf("cannot update synthetic.conf: %w", err)
+		}
+		changed = changed || removed
+	}
+
+	return changed, nil
+}
+
+// EnsureMountPoint creates the mount point's directory. Synthetic empty directories are used for mount points at the
+// root of the filesystem on releases that have a read-only system volume. EnsureMountPoint reports whether the
+// synthetic configuration was changed.
+func (m *Manager) EnsureMountPoint(ctx context.Context, path string) (bool, error) {
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	}
+
+	name, ok := syntheticName(path)
+	if !ok || !m.hasReadOnlySystemVolume() {
+		return false, os.MkdirAll(path, 0755)
+	}
+
+	changed, err := EditSynthetic(m.SyntheticPath, func(conf *Synthetic) bool {
+		// Existing entries are left to whoever created them, they're only waiting to be stitched
+		if _, ok := conf.Lookup(name); ok {
+			return false
+		}
+		return conf.Set(SyntheticEntry{Name: name, Managed: true})
+	})
+	if err != nil {
+		return false, fmt.Errorf("cannot update synthetic.conf: %w", err)
+	}
+	if changed {
+		logrus.WithField("name", name).Info("Added synthetic mount point")
+	}
+
+	if err := Stitch(ctx, m.Product); err != nil {
+		logrus.WithError(err).Warn("Synthetic mount point won't exist until reboot")
+	}
+
+	return changed, nil
+}
+
+// hasReadOnlySystemVolume checks if the product's system volume is mounted read-only (macOS Catalina and later).
+func (m *Manager) hasReadOnlySystemVolume() bool {
+	if m.Product == nil {
+		return false
+	}
+	caps, err := diskutil.Capabilities(m.Product)
+
+	return err == nil && caps.ReadOnlySystemVolume
+}
+
+// syntheticName gets the synthetic object name for paths at the root of the filesystem (e.g. /data), which are the
+// only paths that synthetic.conf can create.
+func syntheticName(path string) (string, bool) {
+	clean := filepath.Clean(path)
+	if filepath.Dir(clean) != "/" || clean == "/" {
+		return "", false
+	}
+
+	return strings.TrimPrefix(clean, "/"), true
+}
@@ -0,0 +1,114 @@
+package mounts
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+func init() {
+	logrus.SetOutput(ioutil.Discard)
+}
+
+func TestManager_PersistAndRemove(t *testing.T) {
+	dir := t.TempDir()
+	m := &Manager{
+		FstabPath:     filepath.Join(dir, "fstab"),
+		SyntheticPath: filepath.Join(dir, "synthetic.conf"),
+		Product:       &system.Product{Release: system.Mojave},
+	}
+	mountPoint := filepath.Join(dir, "Volumes", "Data")
+	entry := FstabEntry{Spec: "UUID=TEST", File: mountPoint, VfsType: "apfs"}
+
+	changed, err := m.Persist(context.Background(), entry)
+	assert.NoError(t, err)
+	assert.True(t, changed, "should persist new mount")
+	assert.DirExists(t, mountPoint, "should create mount point")
+
+	changed, err = m.Persist(context.Background(), entry)
+	assert.NoError(t, err)
+	assert.False(t, changed, "should be idempotent")
+
+	changed, err = m.Remove(context.Background(), mountPoint)
+	assert.NoError(t, err)
+	assert.True(t, changed, "should remove mount")
+
+	tab, err := ReadFstab(m.FstabPath)
+	assert.NoError(t, err)
+	assert.Empty(t, tab.Entries())
+	_, err = os.Stat(m.SyntheticPath)
+	assert.True(t, os.IsNotExist(err), "shouldn't touch synthetic.conf for nested mount points")
+}
+
+func TestManager_RemoveSynthetic(t *testing.T) {
+	dir := t.TempDir()
+	m := &Manager{FstabPath: filepath.Join(dir, "fstab"), SyntheticPath: filepath.Join(dir, "synthetic.conf")}
+	assert.NoError(t, os.WriteFile(m.FstabPath, []byte("# managed by ec2-macos-utils\nUUID=DATA /data apfs rw,auto\n# managed by ec2-macos-utils\nUUID=SCRATCH /scratch apfs rw,auto\n"), 0644))
+	assert.NoError(t, os.WriteFile(m.SyntheticPath, []byte("data\n# managed by ec2-macos-utils\nscratch\n"), 0644))
+
+	changed, err := m.Remove(context.Background(), "/data")
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	changed, err = m.Remove(context.Background(), "/scratch")
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	conf, err := ReadSynthetic(m.SyntheticPath)
+	assert.NoError(t, err)
+	assert.Equal(t, []SyntheticEntry{{Name: "data"}}, conf.Entries(), "only entries created by the utility should be removed")
+}
+
+func TestManager_PersistWithRelativePath(t *testing.T) {
+	m := NewManager(&system.Product{Release: system.Ventura})
+
+	_, err := m.Persist(context.Background(), FstabEntry{Spec: "UUID=TEST", File: "data", VfsType: "apfs"})
+
+	assert.Error(t, err, "shouldn't persist relative mount point")
+}
+
+func TestSyntheticName(t *testing.T) {
+	name, ok := syntheticName("/data/")
+	assert.True(t, ok)
+	assert.Equal(t, "data", name)
+
+	_, ok = syntheticName("/Volumes/Data")
+	assert.False(t, ok, "nested paths can't be synthetic")
+
+	_, ok = syntheticName("/")
+	assert.False(t, ok, "root can't be synthetic")
+}
+
+func TestTask(t *testing.T) {
+	dir := t.TempDir()
+	m := &Manager{
+		FstabPath:     filepath.Join(dir, "fstab"),
+		SyntheticPath: filepath.Join(dir, "synthetic.conf"),
+		Product:       &system.Product{Release: system.Mojave},
+	}
+	mountPoint := filepath.Join(dir, "Volumes", "Data")
+	tk := &Task{Manager: m, Entries: []FstabEntry{{Spec: "UUID=TEST", File: mountPoint, VfsType: "apfs"}}}
+
+	changes, err := tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1, "missing entries should drift")
+
+	_, err = tk.Apply(context.Background())
+	assert.NoError(t, err)
+
+	changes, err = tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	tk.Entries[0].MntOps = "ro,auto"
+	changes, err = tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1, "changed options should drift")
+	assert.Equal(t, "UUID=TEST "+mountPoint+" apfs rw,auto", changes[0].Current)
+}
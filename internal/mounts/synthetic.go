@@ -0,0 +1,235 @@
+package mounts
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// SyntheticPath is the path to the configuration for synthetic firmlinks and empty directories created at the
+	// root of the read-only system volume on boot (macOS Catalina and later).
+	SyntheticPath = "/etc/synthetic.conf"
+
+	// apfsUtilPath is the path to the APFS utility that can create synthetic objects without rebooting.
+	apfsUtilPath = "/System/Library/Filesystems/apfs.fs/Contents/Resources/apfs.util"
+
+	// stitchExitCode is the exit code apfs.util reports after stitching synthetic objects.
+	stitchExitCode = 253
+)
+
+// SyntheticEntry is a single synthetic object described by synthetic.conf(5). Entries without a Target are empty
+// directories which are suitable for use as mount points.
+type SyntheticEntry struct {
+	// Name is the name of the object at the root of the filesystem, without a leading slash.
+	Name string
+	// Target is the path that the object links to, relative to the root of the filesystem.
+	Target string
+	// Managed indicates that the entry was created by this utility.
+	Managed bool
+}
+
+// String formats the entry as a synthetic.conf(5) line.
+func (e SyntheticEntry) String() string {
+	if e.Target == "" {
+		return e.Name
+	}
+
+	return e.Name + "\t" + e.Target
+}
+
+// syntheticLine is a line in the synthetic configuration. Lines that aren't entries are kept verbatim.
+type syntheticLine struct {
+	raw   string
+	entry *SyntheticEntry
+}
+
+// Synthetic holds the contents of a synthetic configuration.
+type Synthetic struct {
+	lines []syntheticLine
+}
+
+// ParseSynthetic reads a synthetic configuration from the reader.
+func ParseSynthetic(r io.Reader) (*Synthetic, error) {
+	conf := &Synthetic{}
+	managed := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		raw := scanner.Text()
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == managedMarker {
+			managed = true
+			continue
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			conf.lines = append(conf.lines, syntheticLine{raw: raw})
+			managed = false
+			continue
+		}
+
+		// Fields are tab separated, per synthetic.conf(5), so names may contain spaces.
+		fields := strings.SplitN(strings.TrimRight(raw, "\r"), "\t", 2)
+		entry := &SyntheticEntry{Name: fields[0], Managed: managed}
+		if len(fields) == 2 {
+			entry.Target = strings.TrimSpace(fields[1])
+		}
+		managed = false
+		conf.lines = append(conf.lines, syntheticLine{raw: raw, entry: entry})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
+
+// Entries returns all synthetic objects in the configuration.
+func (c *Synthetic) Entries() []SyntheticEntry {
+	var entries []SyntheticEntry
+	for _, l := range c.lines {
+		if l.entry != nil {
+			entries = append(entries, *l.entry)
+		}
+	}
+
+	return entries
+}
+
+// Lookup finds the entry with the given name.
+func (c *Synthetic) Lookup(name string) (SyntheticEntry, bool) {
+	for _, l := range c.lines {
+		if l.entry != nil && l.entry.Name == name {
+			return *l.entry, true
+		}
+	}
+
+	return SyntheticEntry{}, false
+}
+
+// Set adds the entry to the configuration or replaces the existing entry with the same name. Set reports whether the
+// configuration was changed.
+func (c *Synthetic) Set(entry SyntheticEntry) bool {
+	for i, l := range c.lines {
+		if l.entry == nil || l.entry.Name != entry.Name {
+			continue
+		}
+		if *l.entry == entry {
+			return false
+		}
+		e := entry
+		c.lines[i] = syntheticLine{entry: &e}
+		return true
+	}
+
+	e := entry
+	c.lines = append(c.lines, syntheticLine{entry: &e})
+
+	return true
+}
+
+// Remove deletes the entry with the given name. Remove reports whether the configuration was changed.
+func (c *Synthetic) Remove(name string) bool {
+	for i, l := range c.lines {
+		if l.entry != nil && l.entry.Name == name {
+			c.lines = append(c.lines[:i], c.lines[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// WriteTo writes the configuration in the synthetic.conf(5) format. Unmodified lines are written as they were read.
+func (c *Synthetic) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, l := range c.lines {
+		var text string
+		switch {
+		case l.entry == nil, l.raw != "" && !l.entry.Managed:
+			text = l.raw
+		case l.entry.Managed:
+			text = managedMarker + "\n" + l.entry.String()
+		default:
+			text = l.entry.String()
+		}
+		n, err := io.WriteString(w, text+"\n")
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// ReadSynthetic reads the synthetic configuration at path. A missing file is treated as an empty configuration.
+func ReadSynthetic(path string) (*Synthetic, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &Synthetic{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseSynthetic(f)
+}
+
+// EditSynthetic edits the synthetic configuration at path while holding a lock against concurrent edits. The
+// configuration is only rewritten when edit reports a change.
+func EditSynthetic(path string, edit func(conf *Synthetic) bool) (bool, error) {
+	unlock, err := lockFile(path)
+	if err != nil {
+		return false, fmt.Errorf("cannot lock %s: %w", path, err)
+	}
+	defer unlock()
+
+	conf, err := ReadSynthetic(path)
+	if err != nil {
+		return false, err
+	}
+
+	if !edit(conf) {
+		return false, nil
+	}
+
+	if err := writeFileAtomic(path, conf, 0644); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Stitch asks APFS to create the synthetic objects in the configuration without waiting for a reboot. Catalina only
+// supports stitching synthetic objects from its boot-time helper so it requires a reboot instead.
+func Stitch(ctx context.Context, p *system.Product) error {
+	if caps, err := diskutil.Capabilities(p); err == nil && !caps.SyntheticStitch {
+		return fmt.Errorf("synthetic objects on %s are created on the next reboot", p.Release)
+	}
+
+	// cmdStitch represents the command used to create synthetic objects immediately.
+	//   * -t - stitch (create) the synthetic objects listed in synthetic.conf
+	cmdStitch := []string{apfsUtilPath, "-t"}
+
+	out, err := util.ExecuteCommand(ctx, cmdStitch, "", nil, nil)
+	// apfs.util exits with 253 after successfully stitching objects, so only other failures are errors.
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == stitchExitCode {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("mounts: failed to create synthetic objects, stderr: [%s]: %w", out.Stderr, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package mounts
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSynthetic = "data\nnix\nopt\tSystem/Volumes/Data/opt\n"
+
+func TestParseSynthetic(t *testing.T) {
+	conf, err := ParseSynthetic(strings.NewReader(testSynthetic))
+
+	assert.NoError(t, err, "should be able to parse synthetic.conf")
+	assert.Equal(t, []SyntheticEntry{
+		{Name: "data"},
+		{Name: "nix"},
+		{Name: "opt", Target: "System/Volumes/Data/opt"},
+	}, conf.Entries())
+}
+
+func TestSynthetic_Managed(t *testing.T) {
+	const managed = "data\n# managed by ec2-macos-utils\nscratch\n"
+	conf, err := ParseSynthetic(strings.NewReader(managed))
+	assert.NoError(t, err)
+	assert.Equal(t, []SyntheticEntry{{Name: "data"}, {Name: "scratch", Managed: true}}, conf.Entries())
+
+	assert.True(t, conf.Set(SyntheticEntry{Name: "cache", Managed: true}))
+	var buf bytes.Buffer
+	_, err = conf.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, managed+"# managed by ec2-macos-utils\ncache\n", buf.String())
+}
+
+func TestSynthetic_SetAndRemove(t *testing.T) {
+	conf, err := ParseSynthetic(strings.NewReader(testSynthetic))
+	assert.NoError(t, err)
+
+	assert.False(t, conf.Set(SyntheticEntry{Name: "data"}), "shouldn't change existing entry")
+	assert.True(t, conf.Set(SyntheticEntry{Name: "scratch"}), "should add new entry")
+	assert.True(t, conf.Remove("nix"), "should remove entry")
+
+	var buf bytes.Buffer
+	_, err = conf.WriteTo(&buf)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "data\nopt\tSystem/Volumes/Data/opt\nscratch\n", buf.String())
+}
@@ -0,0 +1,45 @@
+package mounts
+
+import "errors"
+
+// ErrUnsupported is returned when the mount table can't be read on the current platform.
+var ErrUnsupported = errors.New("mount table is not supported on this platform")
+
+// Filesystem is a mounted filesystem and its capacity as reported by the kernel.
+type Filesystem struct {
+	// Device is the device or remote filesystem that's mounted (e.g. /dev/disk3s1).
+	Device string `json:"device"`
+	// MountPoint is where the filesystem is mounted.
+	MountPoint string `json:"mountPoint"`
+	// Type is the type of the filesystem (e.g. apfs, hfs, devfs).
+	Type string `json:"type"`
+	// ReadOnly indicates that the filesystem is mounted read-only.
+	ReadOnly bool `json:"readOnly"`
+	// Local indicates that the filesystem is stored on a local device rather than the network.
+	Local bool `json:"local"`
+	// TotalBytes is the size of the filesystem.
+	TotalBytes uint64 `json:"totalBytes"`
+	// FreeBytes is the amount of free space in the filesystem.
+	FreeBytes uint64 `json:"freeBytes"`
+	// AvailableBytes is the amount of free space that unprivileged users can write to.
+	AvailableBytes uint64 `json:"availableBytes"`
+}
+
+// UsedBytes calculates the amount of space used in the filesystem.
+func (f Filesystem) UsedBytes() uint64 {
+	if f.FreeBytes > f.TotalBytes {
+		return 0
+	}
+
+	return f.TotalBytes - f.FreeBytes
+}
+
+// UsedPercent calculates the percentage of the filesystem's space that's used, like df(1)'s capacity column.
+func (f Filesystem) UsedPercent() float64 {
+	used := f.UsedBytes()
+	if used+f.AvailableBytes == 0 {
+		return 0
+	}
+
+	return float64(used) / float64(used+f.AvailableBytes) * 100
+}
@@ -0,0 +1,58 @@
+package mounts
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// Mounted reads the mounted filesystems from the kernel with getfsstat(2). MNT_NOWAIT is used so that unresponsive
+// network filesystems don't block the call, which means their capacity may be stale.
+func Mounted() ([]Filesystem, error) {
+	n, err := unix.Getfsstat(nil, unix.MNT_NOWAIT)
+	if err != nil {
+		return nil, fmt.Errorf("cannot count mounted filesystems: %w", err)
+	}
+
+	// Mounts can be added between the calls so room is left for a few more, getfsstat won't overrun the buffer.
+	buf := make([]unix.Statfs_t, n+8)
+	n, err = unix.Getfsstat(buf, unix.MNT_NOWAIT)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read mounted filesystems: %w", err)
+	}
+
+	filesystems := make([]Filesystem, 0, n)
+	for i := range buf[:n] {
+		filesystems = append(filesystems, fromStatfs(&buf[i]))
+	}
+
+	return filesystems, nil
+}
+
+// Usage reads the capacity of the filesystem holding path with statfs(2).
+func Usage(path string) (*Filesystem, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return nil, fmt.Errorf("cannot read filesystem of %s: %w", path, err)
+	}
+
+	fs := fromStatfs(&st)
+
+	return &fs, nil
+}
+
+// fromStatfs converts the kernel's filesystem statistics to a Filesystem.
+func fromStatfs(st *unix.Statfs_t) Filesystem {
+	bsize := uint64(st.Bsize)
+
+	return Filesystem{
+		Device:         unix.ByteSliceToString(st.Mntfromname[:]),
+		MountPoint:     unix.ByteSliceToString(st.Mntonname[:]),
+		Type:           unix.ByteSliceToString(st.Fstypename[:]),
+		ReadOnly:       st.Flags&unix.MNT_RDONLY != 0,
+		Local:          st.Flags&unix.MNT_LOCAL != 0,
+		TotalBytes:     st.Blocks * bsize,
+		FreeBytes:      st.Bfree * bsize,
+		AvailableBytes: st.Bavail * bsize,
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !darwin
+
+package mounts
+
+// Mounted isn't supported outside of macOS.
+func Mounted() ([]Filesystem, error) {
+	return nil, ErrUnsupported
+}
+
+// Usage isn't supported outside of macOS.
+func Usage(path string) (*Filesystem, error) {
+	return nil, ErrUnsupported
+}
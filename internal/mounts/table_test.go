@@ -0,0 +1,39 @@
+package mounts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilesystem_Usage(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       Filesystem
+		wantUsed    uint64
+		wantPercent float64
+	}{
+		{
+			name:        "half used",
+			input:       Filesystem{TotalBytes: 100, FreeBytes: 50, AvailableBytes: 50},
+			wantUsed:    50,
+			wantPercent: 50,
+		},
+		{
+			name:        "reserved space",
+			input:       Filesystem{TotalBytes: 100, FreeBytes: 40, AvailableBytes: 20},
+			wantUsed:    60,
+			wantPercent: 75,
+		},
+		{
+			name:  "empty",
+			input: Filesystem{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantUsed, tt.input.UsedBytes())
+			assert.Equal(t, tt.wantPercent, tt.input.UsedPercent())
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package mounts
+
+import (
+	"context"
+	"os"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+// Task persists the desired mounts in the filesystem table. Entries that aren't desired are left as they are.
+type Task struct {
+	// Manager persists the mounts.
+	Manager *Manager
+	// Entries are the mounts to be persisted.
+	Entries []FstabEntry
+}
+
+// Name identifies the task.
+func (t *Task) Name() string {
+	return "mounts"
+}
+
+// Check compares the filesystem table's entries, and the mount points, with the desired entries.
+func (t *Task) Check(ctx context.Context) ([]task.Change, error) {
+	tab, err := ReadFstab(t.Manager.FstabPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []task.Change
+	for _, entry := range t.Entries {
+		setting := "mount." + entry.File
+		existing, ok := tab.Lookup(entry.File)
+		if !ok {
+			changes = append(changes, task.Change{Setting: setting, Desired: entry.String()})
+			continue
+		}
+		if existing.String() != entry.String() {
+			changes = append(changes, task.Change{Setting: setting, Current: existing.String(), Desired: entry.String()})
+			continue
+		}
+		if _, err := os.Stat(entry.File); os.IsNotExist(err) {
+			changes = append(changes, task.Change{Setting: setting + " (mount point)", Desired: "exists"})
+		}
+	}
+
+	return changes, nil
+}
+
+// Apply persists the desired entries that differ from the filesystem table.
+func (t *Task) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+
+	for _, entry := range t.Entries {
+		if _, err := t.Manager.Persist(ctx, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
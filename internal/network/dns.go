@@ -0,0 +1,246 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// scutilPath is the path to macOS's scutil tool.
+	scutilPath = "/usr/sbin/scutil"
+
+	// emptySetting is the argument that clears a service's DNS servers or search domains with networksetup.
+	emptySetting = "Empty"
+)
+
+// resolverPattern matches the start of each resolver printed by "scutil --dns" (e.g. "resolver #1").
+var resolverPattern = regexp.MustCompile(`^resolver #\d+$`)
+
+// resolverFieldPattern matches the fields of the resolvers printed by "scutil --dns", capturing their name without
+// their index and their value (e.g. "nameserver[0] : 172.31.0.2").
+var resolverFieldPattern = regexp.MustCompile(`^([a-z_ ]+?)(?:\[\d+\])?\s*:\s*(.*)$`)
+
+// DNS is the DNS configuration of a network service. Nil fields are left as they are on the system while empty ones
+// clear the service's setting, which falls back to what DHCP provides.
+type DNS struct {
+	// Servers are the addresses of the DNS servers, in order.
+	Servers []string `json:"servers"`
+	// SearchDomains are the domains appended to unqualified names, in order.
+	SearchDomains []string `json:"search_domains"`
+}
+
+// Resolver is one of the system's effective DNS resolvers as reported by scutil.
+type Resolver struct {
+	// Domain is the domain that the resolver answers for, which is empty for the default resolver.
+	Domain string `json:"domain,omitempty"`
+	// SearchDomains are the domains appended to unqualified names.
+	SearchDomains []string `json:"search_domains"`
+	// Nameservers are the addresses of the resolver's servers.
+	Nameservers []string `json:"nameservers"`
+	// Interface is the interface that queries are sent through (e.g. en0).
+	Interface string `json:"interface,omitempty"`
+}
+
+// ServiceDNS gets the DNS servers and search domains configured for the service. Those provided by DHCP aren't
+// included.
+func ServiceDNS(ctx context.Context, service string) (*DNS, error) {
+	servers, err := DNSServers(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	domains, err := SearchDomains(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DNS{Servers: servers, SearchDomains: domains}, nil
+}
+
+// SearchDomains gets the search domains configured for the service. Domains provided by DHCP aren't included.
+func SearchDomains(ctx context.Context, service string) ([]string, error) {
+	// cmdGet represents the command used for executing macOS's networksetup to get the search domains.
+	//   * -getsearchdomains <service> - print the configured domains, one per line
+	cmdGet := []string{networksetupPath, "-getsearchdomains", service}
+
+	out, err := networksetup(ctx, cmdGet)
+	if err != nil {
+		return nil, err
+	}
+
+	// networksetup prints a sentence when no domains are set, like it does for servers.
+	return parseDNSServers(out), nil
+}
+
+// SetSearchDomains configures the service's search domains. No domains clears them.
+func SetSearchDomains(ctx context.Context, service string, domains []string) error {
+	// cmdSet represents the command used for executing macOS's networksetup to set the search domains.
+	//   * -setsearchdomains <service> <domains...> - replace the service's domains, "Empty" clears them
+	cmdSet := append([]string{networksetupPath, "-setsearchdomains", service}, orEmpty(domains)...)
+
+	_, err := networksetup(ctx, cmdSet)
+	return err
+}
+
+// Resolvers gets the system's default DNS resolvers, which combine the configuration of every active service, in
+// the order they're used.
+func Resolvers(ctx context.Context) ([]Resolver, error) {
+	// cmdDNS represents the command used for executing macOS's scutil to print the DNS configuration.
+	//   * --dns - print the resolvers used for queries
+	cmdDNS := []string{scutilPath, "--dns"}
+
+	out, err := util.ExecuteCommand(ctx, cmdDNS, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("network: failed to get DNS configuration, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseResolvers(out.Stdout), nil
+}
+
+// parseResolvers parses the resolvers printed by "scutil --dns" before its scoped queries section, which repeats the
+// resolvers for queries bound to each interface.
+func parseResolvers(out string) []Resolver {
+	var resolvers []Resolver
+	var r *Resolver
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "DNS configuration (for scoped queries)") {
+			break
+		}
+		if resolverPattern.MatchString(line) {
+			resolvers = append(resolvers, Resolver{})
+			r = &resolvers[len(resolvers)-1]
+			continue
+		}
+		m := resolverFieldPattern.FindStringSubmatch(line)
+		if r == nil || m == nil {
+			continue
+		}
+
+		switch m[1] {
+		case "domain":
+			r.Domain = m[2]
+		case "search domain":
+			r.SearchDomains = append(r.SearchDomains, m[2])
+		case "nameserver":
+			r.Nameservers = append(r.Nameservers, m[2])
+		case "if_index":
+			// e.g. "4 (en0)"
+			if i := strings.Index(m[2], "("); i >= 0 {
+				r.Interface = strings.TrimSuffix(m[2][i+1:], ")")
+			}
+		}
+	}
+
+	return resolvers
+}
+
+// Resolve looks up the addresses of the host with the system's resolvers, verifying that names resolve with the
+// configured DNS servers.
+func Resolve(ctx context.Context, host string) ([]string, error) {
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("network: cannot resolve %s: %w", host, err)
+	}
+
+	return addrs, nil
+}
+
+// DNSTask applies the desired DNS servers and search domains of each network service. The settings are stored with
+// the services, so they persist across reboots.
+type DNSTask struct {
+	// Desired is the DNS configuration of each service keyed by its name (e.g. "Ethernet").
+	Desired map[string]DNS
+}
+
+// Name identifies the task.
+func (t *DNSTask) Name() string {
+	return "dns"
+}
+
+// Check compares the DNS servers and search domains configured for each service with the desired ones.
+func (t *DNSTask) Check(ctx context.Context) ([]task.Change, error) {
+	current := map[string]string{}
+	desired := map[string]string{}
+	for _, service := range t.services() {
+		want := t.Desired[service]
+		have, err := ServiceDNS(ctx, service)
+		if err != nil {
+			return nil, err
+		}
+
+		if want.Servers != nil {
+			current[dnsSetting(service, dnsServersSetting)] = strings.Join(have.Servers, ",")
+			desired[dnsSetting(service, dnsServersSetting)] = strings.Join(want.Servers, ",")
+		}
+		if want.SearchDomains != nil {
+			current[dnsSetting(service, dnsSearchDomainsSetting)] = strings.Join(have.SearchDomains, ",")
+			desired[dnsSetting(service, dnsSearchDomainsSetting)] = strings.Join(want.SearchDomains, ",")
+		}
+	}
+
+	return task.Diff(current, desired), nil
+}
+
+// Apply changes the DNS servers and search domains of the services whose settings differ from the desired ones.
+func (t *DNSTask) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range changes {
+		setting := strings.TrimPrefix(c.Setting, dnsSettingPrefix)
+		if service := strings.TrimSuffix(setting, "."+dnsServersSetting); service != setting {
+			err = SetDNSServers(ctx, service, t.Desired[service].Servers)
+		} else {
+			service = strings.TrimSuffix(setting, "."+dnsSearchDomainsSetting)
+			err = SetSearchDomains(ctx, service, t.Desired[service].SearchDomains)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
+
+// services gets the names of the services with desired settings in a stable order.
+func (t *DNSTask) services() []string {
+	services := make([]string, 0, len(t.Desired))
+	for service := range t.Desired {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	return services
+}
+
+const (
+	// dnsSettingPrefix is the prefix of the services' DNS settings in changes.
+	dnsSettingPrefix = "dns."
+	// dnsServersSetting names the servers of a service in changes.
+	dnsServersSetting = "servers"
+	// dnsSearchDomainsSetting names the search domains of a service in changes.
+	dnsSearchDomainsSetting = "search_domains"
+)
+
+// dnsSetting names the DNS setting of the service in changes (e.g. "dns.Ethernet.servers").
+func dnsSetting(service, setting string) string {
+	return dnsSettingPrefix + service + "." + setting
+}
+
+// orEmpty gets the values as networksetup arguments, where "Empty" stands for no values.
+func orEmpty(values []string) []string {
+	if len(values) == 0 {
+		return []string{emptySetting}
+	}
+
+	return values
+}
@@ -0,0 +1,38 @@
+package network
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseResolvers(t *testing.T) {
+	out, err := os.ReadFile("testdata/scutil_dns.txt")
+	assert.NoError(t, err)
+
+	resolvers := parseResolvers(string(out))
+
+	assert.Equal(t, []Resolver{
+		{
+			SearchDomains: []string{"corp.example.com", "us-west-2.compute.internal"},
+			Nameservers:   []string{"10.0.0.2", "10.0.0.3"},
+			Interface:     "en0",
+		},
+		{Domain: "local"},
+	}, resolvers, "scoped resolvers should be ignored")
+}
+
+func TestParseSearchDomains(t *testing.T) {
+	assert.Empty(t, parseDNSServers("There aren't any Search Domains set on Ethernet.\n"))
+	assert.Equal(t, []string{"corp.example.com"}, parseDNSServers("corp.example.com\n"))
+}
+
+func TestDNSSetting(t *testing.T) {
+	assert.Equal(t, "dns.Thunderbolt Ethernet Slot 0.servers", dnsSetting("Thunderbolt Ethernet Slot 0", dnsServersSetting))
+}
+
+func TestOrEmpty(t *testing.T) {
+	assert.Equal(t, []string{"Empty"}, orEmpty(nil))
+	assert.Equal(t, []string{"10.0.0.2"}, orEmpty([]string{"10.0.0.2"}))
+}
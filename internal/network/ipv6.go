@@ -0,0 +1,147 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// networksetupPath is the path to macOS's networksetup tool.
+	networksetupPath = "/usr/sbin/networksetup"
+	// routePath is the path to macOS's route tool.
+	routePath = "/sbin/route"
+
+	// AmazonDNSIPv6 is the IPv6 address of the Amazon DNS server on Nitro instances. DHCP doesn't configure DNS in
+	// IPv6-only subnets, so it's configured for their interfaces' services.
+	AmazonDNSIPv6 = "fd00:ec2::253"
+)
+
+// serviceDevicePattern matches the hardware port lines of networksetup's service order, capturing the device (e.g.
+// "(Hardware Port: Ethernet, Device: en0)").
+var serviceDevicePattern = regexp.MustCompile(`^\(Hardware Port: .*, Device: (\S*)\)$`)
+
+// serviceNamePattern matches the name lines of networksetup's service order, capturing the name (e.g.
+// "(1) Ethernet" or "(*) Thunderbolt Bridge" for disabled services).
+var serviceNamePattern = regexp.MustCompile(`^\((?:\d+|\*)\) (.+)$`)
+
+// Services maps the BSD names of the system's interfaces (e.g. en0) to the names of their network services (e.g.
+// "Ethernet"), which networksetup configures interfaces by.
+func Services(ctx context.Context) (map[string]string, error) {
+	// cmdOrder represents the command used for executing macOS's networksetup to list the services.
+	//   * -listnetworkserviceorder - list the services with their hardware ports and devices
+	cmdOrder := []string{networksetupPath, "-listnetworkserviceorder"}
+
+	out, err := networksetup(ctx, cmdOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseServiceOrder(out), nil
+}
+
+// parseServiceOrder parses networksetup's service order, where each service's name line is followed by its hardware
+// port line.
+func parseServiceOrder(out string) map[string]string {
+	services := map[string]string{}
+	var name string
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := serviceDevicePattern.FindStringSubmatch(line); m != nil {
+			if name != "" && m[1] != "" {
+				services[m[1]] = name
+			}
+			name = ""
+		} else if m := serviceNamePattern.FindStringSubmatch(line); m != nil {
+			name = m[1]
+		}
+	}
+
+	return services
+}
+
+// EnableIPv6Router configures the service's IPv6 automatically so that the default route is learned from the VPC
+// router's advertisements.
+func EnableIPv6Router(ctx context.Context, service string) error {
+	// cmdAutomatic represents the command used for executing macOS's networksetup to configure IPv6 automatically.
+	//   * -setv6automatic <service> - accept router advertisements on the service's interface
+	cmdAutomatic := []string{networksetupPath, "-setv6automatic", service}
+
+	_, err := networksetup(ctx, cmdAutomatic)
+	return err
+}
+
+// DNSServers gets the DNS servers configured for the service. Servers provided by DHCP aren't included.
+func DNSServers(ctx context.Context, service string) ([]string, error) {
+	// cmdGet represents the command used for executing macOS's networksetup to get the DNS servers.
+	//   * -getdnsservers <service> - print the configured servers, one per line
+	cmdGet := []string{networksetupPath, "-getdnsservers", service}
+
+	out, err := networksetup(ctx, cmdGet)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseDNSServers(out), nil
+}
+
+// parseDNSServers parses the servers printed by networksetup, which prints a sentence instead when none are set
+// (e.g. "There aren't any DNS Servers set on Ethernet.").
+func parseDNSServers(out string) []string {
+	var servers []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.Contains(line, " ") {
+			continue
+		}
+		servers = append(servers, line)
+	}
+
+	return servers
+}
+
+// SetDNSServers configures the service's DNS servers. No servers clears them.
+func SetDNSServers(ctx context.Context, service string, servers []string) error {
+	// cmdSet represents the command used for executing macOS's networksetup to set the DNS servers.
+	//   * -setdnsservers <service> <servers...> - replace the service's servers, "Empty" clears them
+	cmdSet := append([]string{networksetupPath, "-setdnsservers", service}, orEmpty(servers)...)
+
+	_, err := networksetup(ctx, cmdSet)
+	return err
+}
+
+// DefaultIPv6Gateway gets the gateway of the system's default IPv6 route.
+func DefaultIPv6Gateway(ctx context.Context) (string, error) {
+	// cmdRoute represents the command used for executing macOS's route to look up the default route.
+	//   * -n - don't resolve addresses to names
+	//   * get -inet6 default - print the default IPv6 route
+	cmdRoute := []string{routePath, "-n", "get", "-inet6", "default"}
+
+	out, err := util.ExecuteCommand(ctx, cmdRoute, "", nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("network: no default IPv6 route, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	for _, line := range strings.Split(out.Stdout, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "gateway:" {
+			return fields[1], nil
+		}
+	}
+
+	return "", fmt.Errorf("network: no gateway for the default IPv6 route")
+}
+
+// networksetup executes the networksetup command and returns its output.
+func networksetup(ctx context.Context, c []string) (string, error) {
+	out, err := util.ExecuteCommand(ctx, c, "", nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("network: failed to run networksetup %s, stderr: [%s]: %w", c[1], strings.TrimSpace(out.Stderr), err)
+	}
+
+	return out.Stdout, nil
+}
@@ -0,0 +1,106 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// activeMTUPattern matches the active MTU printed by "networksetup -getMTU" (e.g. "Active MTU: 9001 (Current
+// Setting: 9001)").
+var activeMTUPattern = regexp.MustCompile(`Active MTU: (\d+)`)
+
+// MTU fetches the active MTU of the interface (e.g. en0).
+func MTU(ctx context.Context, iface string) (int, error) {
+	// cmdGetMTU represents the command used for executing macOS's networksetup to get an interface's MTU.
+	//   * -getMTU <device> - print the active MTU of the device
+	cmdGetMTU := []string{"networksetup", "-getMTU", iface}
+
+	out, err := util.ExecuteCommand(ctx, cmdGetMTU, "", nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("network: failed to get MTU of %s, stderr: [%s]: %w", iface, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseMTU(out.Stdout)
+}
+
+// parseMTU parses the active MTU from the output of "networksetup -getMTU".
+func parseMTU(out string) (int, error) {
+	m := activeMTUPattern.FindStringSubmatch(out)
+	if m == nil {
+		return 0, fmt.Errorf("network: unexpected MTU output %q", strings.TrimSpace(out))
+	}
+
+	return strconv.Atoi(m[1])
+}
+
+// SetMTU changes the MTU of the interface. The change persists across reboots.
+func SetMTU(ctx context.Context, iface string, mtu int) error {
+	// cmdSetMTU represents the command used for executing macOS's networksetup to set an interface's MTU.
+	//   * -setMTU <device> <mtu> - set the MTU of the device
+	cmdSetMTU := []string{"networksetup", "-setMTU", iface, strconv.Itoa(mtu)}
+
+	out, err := util.ExecuteCommand(ctx, cmdSetMTU, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("network: failed to set MTU of %s, stderr: [%s]: %w", iface, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// MTUTask applies the desired MTU of each interface.
+type MTUTask struct {
+	// Desired is the MTU of each interface keyed by its device name.
+	Desired map[string]int
+}
+
+// Name identifies the task.
+func (t *MTUTask) Name() string {
+	return "mtu"
+}
+
+// Check compares the active MTU of each interface with the desired MTU.
+func (t *MTUTask) Check(ctx context.Context) ([]task.Change, error) {
+	current := map[string]string{}
+	desired := map[string]string{}
+	for iface, mtu := range t.Desired {
+		active, err := MTU(ctx, iface)
+		if err != nil {
+			return nil, err
+		}
+		current[mtuSetting(iface)] = strconv.Itoa(active)
+		desired[mtuSetting(iface)] = strconv.Itoa(mtu)
+	}
+
+	return task.Diff(current, desired), nil
+}
+
+// Apply changes the MTU of the interfaces whose MTU differs from the desired MTU.
+func (t *MTUTask) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range changes {
+		iface := strings.TrimPrefix(c.Setting, mtuSettingPrefix)
+		if err := SetMTU(ctx, iface, t.Desired[iface]); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
+
+// mtuSettingPrefix is the prefix of the interfaces' MTU settings in changes.
+const mtuSettingPrefix = "mtu."
+
+// mtuSetting names the MTU setting of the interface in changes (e.g. "mtu.en0").
+func mtuSetting(iface string) string {
+	return mtuSettingPrefix + iface
+}
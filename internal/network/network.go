@@ -0,0 +1,253 @@
+// Package network provides the functionality necessary for configuring the secondary private IPv4 addresses and the
+// IPv6 addresses of the instance's network interfaces as aliases with macOS's ifconfig. DHCP only assigns each
+// interface its primary IPv4 address, so the other addresses assigned to an ENI aren't reachable until they're
+// configured.
+package network
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/imds"
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// ifconfigPath is the path to macOS's ifconfig tool.
+	ifconfigPath = "/sbin/ifconfig"
+	// aliasNetmask is the netmask of configured IPv4 aliases. Aliases in the same subnet as the primary address use
+	// a host netmask so that the subnet's route stays on the primary address.
+	aliasNetmask = "255.255.255.255"
+	// defaultIPv6PrefixLength is the prefix length of IPv6 aliases whose subnet isn't known. EC2 subnets' IPv6
+	// CIDR blocks are always /64.
+	defaultIPv6PrefixLength = 64
+	// jobInterval is the time, in seconds, between runs of the persisted job so that secondary addresses assigned
+	// after boot are also configured.
+	jobInterval = 300
+)
+
+// JobLabel is the label of the launchd job that configures the aliases at boot.
+var JobLabel = launchd.Label("network")
+
+// Interface is a network interface on the system.
+type Interface struct {
+	// Name is the BSD name of the interface (e.g. en0).
+	Name string
+	// MAC is the interface's MAC address.
+	MAC string
+	// IPv4s are the IPv4 addresses configured on the interface.
+	IPv4s []string
+	// IPv6s are the IPv6 addresses configured on the interface, excluding its link-local addresses.
+	IPv6s []string
+}
+
+// Alias is a secondary private IPv4 address, or an IPv6 address, of an ENI and the interface it's configured on.
+type Alias struct {
+	// Interface is the BSD name of the ENI's interface (e.g. en0).
+	Interface string `json:"interface"`
+	// MAC is the ENI's MAC address.
+	MAC string `json:"mac"`
+	// Address is the secondary private IPv4 address or the IPv6 address.
+	Address string `json:"address"`
+	// PrefixLength is the prefix length of IPv6 addresses, which is the length of their subnet's CIDR block.
+	PrefixLength int `json:"prefix_length,omitempty"`
+	// Configured indicates that the address is already configured on the interface.
+	Configured bool `json:"configured"`
+}
+
+// IPv6 checks if the alias is for an IPv6 address.
+func (a *Alias) IPv6() bool {
+	ip := net.ParseIP(a.Address)
+	return ip != nil && ip.To4() == nil
+}
+
+// Interfaces lists the system's network interfaces and their IPv4 addresses.
+func Interfaces(ctx context.Context) ([]Interface, error) {
+	// cmdList represents the command used for executing macOS's ifconfig to list the interfaces.
+	//   * -a - list all interfaces, including the ones that are down
+	cmdList := []string{ifconfigPath, "-a"}
+
+	out, err := util.ExecuteCommand(ctx, cmdList, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("network: failed to list interfaces, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseIfconfig(out.Stdout), nil
+}
+
+// parseIfconfig parses the interfaces from ifconfig's output. Each interface starts with an unindented line (e.g.
+// "en0: flags=8863<UP,...> mtu 9001") followed by indented lines for its properties (e.g. "\tether 0e:...").
+func parseIfconfig(out string) []Interface {
+	var interfaces []Interface
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			if i := strings.Index(line, ":"); i > 0 {
+				interfaces = append(interfaces, Interface{Name: line[:i]})
+			}
+			continue
+		}
+		if len(interfaces) == 0 {
+			continue
+		}
+
+		iface := &interfaces[len(interfaces)-1]
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "ether":
+			iface.MAC = strings.ToLower(fields[1])
+		case "inet":
+			iface.IPv4s = append(iface.IPv4s, fields[1])
+		case "inet6":
+			// Link-local addresses are scoped to the interface (e.g. "fe80::1%en0") and never assigned by EC2
+			if ip := net.ParseIP(fields[1]); ip != nil && !ip.IsLinkLocalUnicast() {
+				iface.IPv6s = append(iface.IPv6s, ip.String())
+			}
+		}
+	}
+
+	return interfaces
+}
+
+// Aliases finds the aliases for the secondary IPv4 addresses and the IPv6 addresses of the ENIs on the matching local
+// interfaces. The MAC addresses of the ENIs without a local interface (e.g. they were only just attached) are also
+// returned.
+func Aliases(enis []imds.NetworkInterface, interfaces []Interface) (aliases []Alias, unmatched []string) {
+	for _, eni := range enis {
+		iface := lookupMAC(interfaces, eni.MAC)
+		if iface == nil {
+			unmatched = append(unmatched, eni.MAC)
+			continue
+		}
+
+		for _, address := range eni.SecondaryIPv4s {
+			aliases = append(aliases, Alias{
+				Interface:  iface.Name,
+				MAC:        iface.MAC,
+				Address:    address,
+				Configured: contains(iface.IPv4s, address),
+			})
+		}
+		for _, address := range eni.IPv6s {
+			aliases = append(aliases, Alias{
+				Interface:    iface.Name,
+				MAC:          iface.MAC,
+				Address:      address,
+				PrefixLength: ipv6PrefixLength(address, eni.SubnetIPv6CIDRs),
+				Configured:   containsIP(iface.IPv6s, address),
+			})
+		}
+	}
+
+	return aliases, unmatched
+}
+
+// lookupMAC finds the interface with the MAC address.
+func lookupMAC(interfaces []Interface, mac string) *Interface {
+	for i := range interfaces {
+		if strings.EqualFold(interfaces[i].MAC, mac) {
+			return &interfaces[i]
+		}
+	}
+
+	return nil
+}
+
+// contains checks if the address is in the list.
+func contains(addresses []string, address string) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containsIP checks if the address is in the list, comparing the parsed addresses since IPv6 addresses can be
+// written in more than one way.
+func containsIP(addresses []string, address string) bool {
+	ip := net.ParseIP(address)
+	for _, a := range addresses {
+		if ip.Equal(net.ParseIP(a)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ipv6PrefixLength finds the prefix length of the subnet CIDR block containing the IPv6 address.
+func ipv6PrefixLength(address string, cidrs []string) int {
+	ip := net.ParseIP(address)
+	for _, cidr := range cidrs {
+		if _, subnet, err := net.ParseCIDR(cidr); err == nil && subnet.Contains(ip) {
+			ones, _ := subnet.Mask.Size()
+			return ones
+		}
+	}
+
+	return defaultIPv6PrefixLength
+}
+
+// AddAlias configures the alias's address on its interface.
+func AddAlias(ctx context.Context, a Alias) error {
+	// cmdAdd represents the command used for executing macOS's ifconfig to add an alias.
+	//   * <interface> - the interface to configure
+	//   * alias <address> - add the address without replacing the interface's other addresses
+	//   * netmask <netmask> - the alias's netmask
+	//   * inet6 <address> prefixlen <length> alias - add the IPv6 address with its subnet's prefix length
+	cmdAdd := []string{ifconfigPath, a.Interface, "alias", a.Address, "netmask", aliasNetmask}
+	if a.IPv6() {
+		cmdAdd = []string{ifconfigPath, a.Interface, "inet6", a.Address, "prefixlen", strconv.Itoa(a.PrefixLength), "alias"}
+	}
+
+	out, err := util.ExecuteCommand(ctx, cmdAdd, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("network: failed to add alias %s to %s, stderr: [%s]: %w", a.Address, a.Interface, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// RemoveAlias removes the alias's address from its interface.
+func RemoveAlias(ctx context.Context, a Alias) error {
+	// cmdRemove represents the command used for executing macOS's ifconfig to remove an alias.
+	//   * <interface> - the interface to configure
+	//   * -alias <address> - remove the address
+	//   * inet6 <address> -alias - remove the IPv6 address
+	cmdRemove := []string{ifconfigPath, a.Interface, "-alias", a.Address}
+	if a.IPv6() {
+		cmdRemove = []string{ifconfigPath, a.Interface, "inet6", a.Address, "-alias"}
+	}
+
+	out, err := util.ExecuteCommand(ctx, cmdRemove, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("network: failed to remove alias %s from %s, stderr: [%s]: %w", a.Address, a.Interface, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// Job creates the launchd job which runs the utility at the path to configure the aliases at boot, and then
+// periodically to configure addresses assigned after boot.
+func Job(executable string) *launchd.Job {
+	return &launchd.Job{
+		Label:            JobLabel,
+		ProgramArguments: []string{executable, "network", "configure", "--wait"},
+		RunAtLoad:        true,
+		StartInterval:    jobInterval,
+	}
+}
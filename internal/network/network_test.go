@@ -0,0 +1,94 @@
+package network
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/imds"
+)
+
+func TestParseIfconfig(t *testing.T) {
+	out, err := os.ReadFile("testdata/ifconfig.txt")
+	assert.NoError(t, err)
+
+	interfaces := parseIfconfig(string(out))
+
+	assert.Equal(t, []Interface{
+		{Name: "lo0", IPv4s: []string{"127.0.0.1"}, IPv6s: []string{"::1"}},
+		{Name: "en0", MAC: "0e:5d:2c:8a:1f:37", IPv4s: []string{"172.31.22.14", "172.31.22.15"}, IPv6s: []string{"2600:1f14:abc:de00::10"}},
+		{Name: "en1", MAC: "0e:7a:11:42:9c:01", IPv4s: []string{"172.31.40.8"}},
+		{Name: "bridge0", MAC: "36:a1:6b:2c:d0:40"},
+	}, interfaces)
+}
+
+func TestAliases(t *testing.T) {
+	interfaces := []Interface{
+		{Name: "en0", MAC: "0e:5d:2c:8a:1f:37", IPv4s: []string{"172.31.22.14", "172.31.22.15"}, IPv6s: []string{"2600:1f14:abc:de00::10"}},
+		{Name: "en1", MAC: "0e:7a:11:42:9c:01", IPv4s: []string{"172.31.40.8"}},
+	}
+	enis := []imds.NetworkInterface{
+		{
+			MAC: "0e:5d:2c:8a:1f:37", PrimaryIPv4: "172.31.22.14", SecondaryIPv4s: []string{"172.31.22.15", "172.31.22.16"},
+			IPv6s: []string{"2600:1f14:abc:de00:0:0:0:10", "2600:1f14:abc:de00::11"}, SubnetIPv6CIDRs: []string{"2600:1f14:abc:de00::/56"},
+		},
+		{MAC: "0E:7A:11:42:9C:01", DeviceNumber: 1, PrimaryIPv4: "172.31.40.8", SecondaryIPv4s: []string{"172.31.40.9"}},
+		{MAC: "0e:00:00:00:00:03", DeviceNumber: 2, PrimaryIPv4: "172.31.50.2", SecondaryIPv4s: []string{"172.31.50.3"}},
+	}
+
+	aliases, unmatched := Aliases(enis, interfaces)
+
+	assert.Equal(t, []Alias{
+		{Interface: "en0", MAC: "0e:5d:2c:8a:1f:37", Address: "172.31.22.15", Configured: true},
+		{Interface: "en0", MAC: "0e:5d:2c:8a:1f:37", Address: "172.31.22.16"},
+		{Interface: "en0", MAC: "0e:5d:2c:8a:1f:37", Address: "2600:1f14:abc:de00:0:0:0:10", PrefixLength: 56, Configured: true},
+		{Interface: "en0", MAC: "0e:5d:2c:8a:1f:37", Address: "2600:1f14:abc:de00::11", PrefixLength: 56},
+		{Interface: "en1", MAC: "0e:7a:11:42:9c:01", Address: "172.31.40.9"},
+	}, aliases)
+	assert.Equal(t, []string{"0e:00:00:00:00:03"}, unmatched)
+}
+
+func TestAlias_IPv6(t *testing.T) {
+	assert.False(t, (&Alias{Address: "172.31.22.15"}).IPv6())
+	assert.True(t, (&Alias{Address: "2600:1f14:abc:de00::11"}).IPv6())
+}
+
+func TestIPv6PrefixLength(t *testing.T) {
+	assert.Equal(t, 64, ipv6PrefixLength("2600:1f14:abc:de00::11", []string{"2600:1f14:abc:de00::/64"}))
+	assert.Equal(t, defaultIPv6PrefixLength, ipv6PrefixLength("2600:1f14:abc:de00::11", nil), "should default when the subnet isn't known")
+}
+
+func TestParseServiceOrder(t *testing.T) {
+	out, err := os.ReadFile("testdata/serviceorder.txt")
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"en0":     "Ethernet",
+		"en1":     "Ethernet 2",
+		"bridge0": "Thunderbolt Bridge",
+	}, parseServiceOrder(string(out)))
+}
+
+func TestParseDNSServers(t *testing.T) {
+	assert.Empty(t, parseDNSServers("There aren't any DNS Servers set on Ethernet.\n"))
+	assert.Equal(t, []string{"fd00:ec2::253", "10.0.0.2"}, parseDNSServers("fd00:ec2::253\n10.0.0.2\n"))
+}
+
+func TestJob(t *testing.T) {
+	job := Job("/usr/local/bin/ec2-macos-utils")
+
+	assert.NoError(t, job.Validate())
+	assert.Equal(t, "com.amazon.ec2.macos-utils.network", job.Label)
+	assert.Equal(t, []string{"/usr/local/bin/ec2-macos-utils", "network", "configure", "--wait"}, job.ProgramArguments)
+	assert.True(t, job.RunAtLoad)
+}
+
+func TestParseMTU(t *testing.T) {
+	mtu, err := parseMTU("Active MTU: 9001 (Current Setting: 9001)\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 9001, mtu)
+
+	_, err = parseMTU("en9 is not a hardware port or device\n")
+	assert.Error(t, err)
+}
@@ -0,0 +1,367 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+const (
+	// WebProxy is the kind of proxy used for HTTP.
+	WebProxy ProxyKind = "webproxy"
+	// SecureWebProxy is the kind of proxy used for HTTPS.
+	SecureWebProxy ProxyKind = "securewebproxy"
+
+	// launchctlPath is the path to macOS's launchctl tool.
+	launchctlPath = "/bin/launchctl"
+)
+
+// ProxyEnvJobLabel is the label of the launchd job that sets the proxy environment variables for launchd's services.
+var ProxyEnvJobLabel = launchd.Label("proxy-env")
+
+// DefaultNoProxy are the addresses that are never sent through the proxy in the environment: the loopback addresses,
+// the instance metadata service, and the Amazon Time Sync Service, which are only reachable from the instance.
+var DefaultNoProxy = []string{"localhost", "127.0.0.1", "::1", "169.254.169.254", "169.254.169.123", "fd00:ec2::254"}
+
+// ProxyKind is the kind of proxy that networksetup configures for a service.
+type ProxyKind string
+
+// Proxy is the configuration of one kind of proxy for a network service.
+type Proxy struct {
+	// Enabled indicates that the service uses the proxy.
+	Enabled bool `json:"enabled"`
+	// Server is the proxy's host name or address.
+	Server string `json:"server,omitempty"`
+	// Port is the proxy's port.
+	Port int `json:"port,omitempty"`
+}
+
+// ParseProxy parses a proxy's address (e.g. "proxy.example.com:3128"), optionally with an http:// scheme. An empty
+// address, or "off", is a disabled proxy.
+func ParseProxy(addr string) (*Proxy, error) {
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "http://"), "/")
+	if addr == "" || addr == "off" {
+		return &Proxy{}, nil
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("network: invalid proxy %q: %w", addr, err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p <= 0 || p > 65535 {
+		return nil, fmt.Errorf("network: invalid proxy port %q", port)
+	}
+
+	return &Proxy{Enabled: true, Server: host, Port: p}, nil
+}
+
+// String formats the proxy's address, or "off" when it's disabled.
+func (p *Proxy) String() string {
+	if !p.Enabled {
+		return "off"
+	}
+
+	return net.JoinHostPort(p.Server, strconv.Itoa(p.Port))
+}
+
+// URL formats the proxy's address as the URL used in the environment (e.g. "http://proxy.example.com:3128"), or an
+// empty string when it's disabled.
+func (p *Proxy) URL() string {
+	if !p.Enabled {
+		return ""
+	}
+
+	return "http://" + p.String()
+}
+
+// GetProxy gets the service's proxy of the kind.
+func GetProxy(ctx context.Context, service string, kind ProxyKind) (*Proxy, error) {
+	// cmdGet represents the command used for executing macOS's networksetup to get a proxy.
+	//   * -get<kind> <service> - print whether the proxy is enabled, its server, and its port
+	cmdGet := []string{networksetupPath, "-get" + string(kind), service}
+
+	out, err := networksetup(ctx, cmdGet)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseProxy(out), nil
+}
+
+// parseProxy parses the proxy printed by networksetup (e.g. "Enabled: Yes\nServer: proxy\nPort: 3128").
+func parseProxy(out string) *Proxy {
+	p := &Proxy{}
+	for _, line := range strings.Split(out, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.TrimSpace(key) {
+		case "Enabled":
+			p.Enabled = value == "Yes"
+		case "Server":
+			p.Server = value
+		case "Port":
+			p.Port, _ = strconv.Atoi(value)
+		}
+	}
+
+	return p
+}
+
+// SetProxy configures the service's proxy of the kind, or turns it off when the proxy is disabled.
+func SetProxy(ctx context.Context, service string, kind ProxyKind, p *Proxy) error {
+	// cmdSet represents the command used for executing macOS's networksetup to set a proxy.
+	//   * -set<kind> <service> <server> <port> - set and enable the proxy
+	cmdSet := []string{networksetupPath, "-set" + string(kind), service, p.Server, strconv.Itoa(p.Port)}
+	if !p.Enabled {
+		// cmdSet is replaced with the command to turn the proxy off, which keeps its server and port.
+		//   * -set<kind>state <service> off - disable the proxy
+		cmdSet = []string{networksetupPath, "-set" + string(kind) + "state", service, "off"}
+	}
+
+	_, err := networksetup(ctx, cmdSet)
+	return err
+}
+
+// ProxyBypassDomains gets the hosts and domains that bypass the service's proxies.
+func ProxyBypassDomains(ctx context.Context, service string) ([]string, error) {
+	// cmdGet represents the command used for executing macOS's networksetup to get the bypassed domains.
+	//   * -getproxybypassdomains <service> - print the domains, one per line
+	cmdGet := []string{networksetupPath, "-getproxybypassdomains", service}
+
+	out, err := networksetup(ctx, cmdGet)
+	if err != nil {
+		return nil, err
+	}
+
+	// networksetup prints a sentence when no domains are set, like it does for DNS servers.
+	return parseDNSServers(out), nil
+}
+
+// SetProxyBypassDomains configures the hosts and domains that bypass the service's proxies. No domains clears them.
+func SetProxyBypassDomains(ctx context.Context, service string, domains []string) error {
+	// cmdSet represents the command used for executing macOS's networksetup to set the bypassed domains.
+	//   * -setproxybypassdomains <service> <domains...> - replace the domains, "Empty" clears them
+	cmdSet := append([]string{networksetupPath, "-setproxybypassdomains", service}, orEmpty(domains)...)
+
+	_, err := networksetup(ctx, cmdSet)
+	return err
+}
+
+// ProxyTask applies the desired proxies and bypassed domains to each network service. Nil settings are left as they
+// are on the system.
+type ProxyTask struct {
+	// Services are the names of the services that are configured (e.g. "Ethernet").
+	Services []string
+	// HTTP is the proxy used for HTTP.
+	HTTP *Proxy
+	// HTTPS is the proxy used for HTTPS.
+	HTTPS *Proxy
+	// Bypass are the hosts and domains that bypass the proxies (e.g. "*.internal").
+	Bypass []string
+}
+
+// Name identifies the task.
+func (t *ProxyTask) Name() string {
+	return "proxy"
+}
+
+// Check compares each service's proxies and bypassed domains with the desired ones.
+func (t *ProxyTask) Check(ctx context.Context) ([]task.Change, error) {
+	current := map[string]string{}
+	desired := map[string]string{}
+	for _, service := range t.Services {
+		for kind, want := range t.proxies() {
+			have, err := GetProxy(ctx, service, kind)
+			if err != nil {
+				return nil, err
+			}
+			current[proxySetting(service, string(kind))] = have.String()
+			desired[proxySetting(service, string(kind))] = want.String()
+		}
+
+		if t.Bypass != nil {
+			have, err := ProxyBypassDomains(ctx, service)
+			if err != nil {
+				return nil, err
+			}
+			current[proxySetting(service, proxyBypassSetting)] = strings.Join(have, ",")
+			desired[proxySetting(service, proxyBypassSetting)] = strings.Join(t.Bypass, ",")
+		}
+	}
+
+	return task.Diff(current, desired), nil
+}
+
+// Apply changes the proxies and bypassed domains of the services whose settings differ from the desired ones.
+func (t *ProxyTask) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	proxies := t.proxies()
+	for _, c := range changes {
+		setting := strings.TrimPrefix(c.Setting, proxySettingPrefix)
+		i := strings.LastIndex(setting, ".")
+		service, kind := setting[:i], setting[i+1:]
+		if kind == proxyBypassSetting {
+			err = SetProxyBypassDomains(ctx, service, t.Bypass)
+		} else {
+			err = SetProxy(ctx, service, ProxyKind(kind), proxies[ProxyKind(kind)])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
+
+// proxies gets the desired proxy of each kind that's managed.
+func (t *ProxyTask) proxies() map[ProxyKind]*Proxy {
+	proxies := map[ProxyKind]*Proxy{}
+	if t.HTTP != nil {
+		proxies[WebProxy] = t.HTTP
+	}
+	if t.HTTPS != nil {
+		proxies[SecureWebProxy] = t.HTTPS
+	}
+
+	return proxies
+}
+
+const (
+	// proxySettingPrefix is the prefix of the services' proxy settings in changes.
+	proxySettingPrefix = "proxy."
+	// proxyBypassSetting names the bypassed domains of a service in changes.
+	proxyBypassSetting = "bypass"
+)
+
+// proxySetting names the proxy setting of the service in changes (e.g. "proxy.Ethernet.webproxy").
+func proxySetting(service, setting string) string {
+	return proxySettingPrefix + service + "." + setting
+}
+
+// ProxyEnvironment gets the environment variables that point HTTP clients at the proxies, in both the upper and lower
+// case spellings that clients look for. The bypassed domains are converted to NO_PROXY's syntax and added to
+// DefaultNoProxy. There aren't any variables when neither proxy is enabled.
+func ProxyEnvironment(http, https *Proxy, bypass []string) map[string]string {
+	env := map[string]string{}
+	if http != nil && http.Enabled {
+		env["HTTP_PROXY"] = http.URL()
+	}
+	if https != nil && https.Enabled {
+		env["HTTPS_PROXY"] = https.URL()
+	}
+	if len(env) == 0 {
+		return env
+	}
+
+	noProxy := append([]string{}, DefaultNoProxy...)
+	for _, domain := range bypass {
+		// Wildcards (e.g. "*.internal") are written as domain suffixes (e.g. ".internal").
+		noProxy = append(noProxy, strings.TrimPrefix(domain, "*"))
+	}
+	env["NO_PROXY"] = strings.Join(noProxy, ",")
+
+	for k, v := range env {
+		env[strings.ToLower(k)] = v
+	}
+
+	return env
+}
+
+// ProxyEnvJob gets the launchd job that sets the environment variables for all of launchd's services when it's
+// loaded at boot. Services that are already running only get the variables when they're restarted.
+func ProxyEnvJob(env map[string]string) *launchd.Job {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := []string{launchctlPath, "setenv"}
+	for _, k := range keys {
+		args = append(args, k, env[k])
+	}
+
+	return &launchd.Job{
+		Label:            ProxyEnvJobLabel,
+		ProgramArguments: args,
+		RunAtLoad:        true,
+	}
+}
+
+// ProxyEnvTask installs the launchd job that sets the proxy environment variables for launchd's services, or removes
+// it when there aren't any variables.
+type ProxyEnvTask struct {
+	// Manager installs the job.
+	Manager *launchd.Manager
+	// Env are the environment variables (e.g. from ProxyEnvironment).
+	Env map[string]string
+}
+
+// Name identifies the task.
+func (t *ProxyEnvTask) Name() string {
+	return "proxy-env"
+}
+
+// Check compares the installed job's definition with the desired one.
+func (t *ProxyEnvTask) Check(ctx context.Context) ([]task.Change, error) {
+	path := t.Manager.Path(ProxyEnvJobLabel)
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("network: cannot read %s: %w", path, err)
+	}
+
+	current := "installed"
+	if existing == nil {
+		current = "absent"
+	}
+	if len(t.Env) == 0 {
+		return task.Diff(map[string]string{path: current}, map[string]string{path: "absent"}), nil
+	}
+
+	data, err := ProxyEnvJob(t.Env).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && !bytes.Equal(existing, data) {
+		current = "outdated"
+	}
+
+	return task.Diff(map[string]string{path: current}, map[string]string{path: "installed"}), nil
+}
+
+// Apply installs or removes the job when its definition differs from the desired one.
+func (t *ProxyEnvTask) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+
+	if len(t.Env) == 0 {
+		_, err = t.Manager.Uninstall(ctx, ProxyEnvJobLabel)
+	} else {
+		_, err = t.Manager.Install(ctx, ProxyEnvJob(t.Env))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,82 @@
+package network
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+func TestParseProxy(t *testing.T) {
+	p, err := ParseProxy("http://proxy.example.com:3128/")
+	assert.NoError(t, err)
+	assert.Equal(t, &Proxy{Enabled: true, Server: "proxy.example.com", Port: 3128}, p)
+	assert.Equal(t, "proxy.example.com:3128", p.String())
+	assert.Equal(t, "http://proxy.example.com:3128", p.URL())
+
+	p, err = ParseProxy("off")
+	assert.NoError(t, err)
+	assert.False(t, p.Enabled)
+	assert.Equal(t, "off", p.String())
+
+	_, err = ParseProxy("proxy.example.com")
+	assert.Error(t, err, "the port is required")
+	_, err = ParseProxy("proxy.example.com:http")
+	assert.Error(t, err)
+}
+
+func TestParseProxyOutput(t *testing.T) {
+	assert.Equal(t, &Proxy{Enabled: true, Server: "proxy.example.com", Port: 3128},
+		parseProxy("Enabled: Yes\nServer: proxy.example.com\nPort: 3128\nAuthenticated Proxy Enabled: 0\n"))
+	assert.Equal(t, &Proxy{}, parseProxy("Enabled: No\nServer: \nPort: 0\nAuthenticated Proxy Enabled: 0\n"))
+}
+
+func TestProxyEnvironment(t *testing.T) {
+	http := &Proxy{Enabled: true, Server: "proxy.example.com", Port: 3128}
+
+	env := ProxyEnvironment(http, &Proxy{}, []string{"*.internal", "10.0.0.0/8"})
+
+	assert.Equal(t, "http://proxy.example.com:3128", env["HTTP_PROXY"])
+	assert.Equal(t, env["HTTP_PROXY"], env["http_proxy"])
+	assert.NotContains(t, env, "HTTPS_PROXY", "disabled proxies shouldn't be set")
+	assert.Equal(t, "localhost,127.0.0.1,::1,169.254.169.254,169.254.169.123,fd00:ec2::254,.internal,10.0.0.0/8", env["NO_PROXY"])
+	assert.Empty(t, ProxyEnvironment(nil, &Proxy{}, []string{"*.internal"}), "there should be no variables without proxies")
+}
+
+func TestProxyEnvJob(t *testing.T) {
+	job := ProxyEnvJob(map[string]string{"https_proxy": "http://proxy:3128", "HTTPS_PROXY": "http://proxy:3128"})
+
+	assert.Equal(t, []string{"/bin/launchctl", "setenv", "HTTPS_PROXY", "http://proxy:3128", "https_proxy", "http://proxy:3128"}, job.ProgramArguments)
+	assert.True(t, job.RunAtLoad)
+}
+
+func TestProxyEnvTask_Check(t *testing.T) {
+	m := &launchd.Manager{Dir: t.TempDir(), Domain: launchd.SystemDomain}
+	path := m.Path(ProxyEnvJobLabel)
+	tk := &ProxyEnvTask{Manager: m, Env: map[string]string{"HTTP_PROXY": "http://proxy:3128"}}
+
+	changes, err := tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []task.Change{{Setting: path, Current: "absent", Desired: "installed"}}, changes)
+
+	data, err := ProxyEnvJob(tk.Env).Bytes()
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, data, 0o644))
+	changes, err = tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	tk.Env["HTTP_PROXY"] = "http://other:3128"
+	changes, err = tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []task.Change{{Setting: path, Current: "outdated", Desired: "installed"}}, changes)
+
+	tk.Env = nil
+	changes, err = tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []task.Change{{Setting: path, Current: "installed", Desired: "absent"}}, changes)
+}
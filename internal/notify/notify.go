@@ -0,0 +1,129 @@
+// Package notify provides the functionality necessary for sending the outcomes of disk operations (e.g. growing a
+// container) to a webhook or SNS topic, so that fleet dashboards learn about them without scraping logs.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/events"
+)
+
+// sendTimeout is the time each sender has to deliver a notification.
+const sendTimeout = 10 * time.Second
+
+// Notification is the outcome of an operation.
+type Notification struct {
+	// InstanceID is the ID of the instance that ran the operation.
+	InstanceID string `json:"instance_id,omitempty"`
+	// Operation is the operation that ran.
+	Operation events.Operation `json:"operation"`
+	// Outcome is whether the operation finished or failed.
+	Outcome events.Kind `json:"outcome"`
+	// Device is the device identifier that the operation was run on.
+	Device string `json:"device,omitempty"`
+	// Time is when the operation ended.
+	Time time.Time `json:"time"`
+	// DurationSeconds is how long the operation ran for.
+	DurationSeconds float64 `json:"duration_seconds"`
+	// Error is why the operation failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Subject summarizes the notification in a single line (e.g. "ec2-macos-utils: grow failed on disk1").
+func (n Notification) Subject() string {
+	subject := fmt.Sprintf("ec2-macos-utils: %s %s", n.Operation, n.Outcome)
+	if n.Device != "" {
+		subject += " on " + n.Device
+	}
+	if n.InstanceID != "" {
+		subject += " (" + n.InstanceID + ")"
+	}
+
+	return subject
+}
+
+// Sender delivers notifications to a destination (e.g. a webhook).
+type Sender interface {
+	// Send delivers the notification.
+	Send(ctx context.Context, n Notification) error
+}
+
+// Notifier is an events.Subscriber which sends the outcomes of operations to its senders. Notifications are sent in
+// the background so that operations aren't held up by the network; Wait should be called before exiting so that
+// they aren't lost.
+type Notifier struct {
+	instanceID string
+	operations map[events.Operation]bool
+	senders    []Sender
+	wg         sync.WaitGroup
+}
+
+// NewNotifier creates a Notifier which sends the outcomes of the operations, or of all operations when none are
+// given, to the senders. Notifications are attributed to the instance with the given ID.
+func NewNotifier(instanceID string, operations []events.Operation, senders ...Sender) *Notifier {
+	n := &Notifier{instanceID: instanceID, senders: senders}
+	if len(operations) > 0 {
+		n.operations = make(map[events.Operation]bool, len(operations))
+		for _, op := range operations {
+			n.operations[op] = true
+		}
+	}
+
+	return n
+}
+
+// Handle sends a notification when an operation finishes or fails.
+func (n *Notifier) Handle(e events.Event) {
+	if e.Kind == events.KindStarted {
+		return
+	}
+	if n.operations != nil && !n.operations[e.Operation] {
+		return
+	}
+
+	notification := Notification{
+		InstanceID:      n.instanceID,
+		Operation:       e.Operation,
+		Outcome:         e.Kind,
+		Device:          e.Device,
+		Time:            e.Time,
+		DurationSeconds: e.Duration.Seconds(),
+	}
+	if e.Err != nil {
+		notification.Error = e.Err.Error()
+	}
+
+	for _, s := range n.senders {
+		n.wg.Add(1)
+		go func(s Sender) {
+			defer n.wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			defer cancel()
+			if err := s.Send(ctx, notification); err != nil {
+				logrus.WithError(err).WithField("operation", e.Operation).Warn("Failed to send notification")
+			}
+		}(s)
+	}
+}
+
+// Wait blocks until the notifications that are being sent have been delivered or the context is done.
+func (n *Notifier) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/events"
+)
+
+// recordingSender is a Sender which records the notifications it's sent.
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []Notification
+}
+
+func (s *recordingSender) Send(ctx context.Context, n Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, n)
+
+	return nil
+}
+
+func TestNotifier(t *testing.T) {
+	sender := &recordingSender{}
+	n := NewNotifier("i-0123456789abcdef0", []events.Operation{events.OperationGrow}, sender)
+	bus := events.NewBus(n)
+
+	bus.Start(events.OperationRepair, "disk0").End(nil)
+	bus.Start(events.OperationGrow, "disk1").End(errors.New("not enough space"))
+
+	assert.NoError(t, n.Wait(context.Background()))
+	assert.Len(t, sender.sent, 1, "only the outcome of grow should be sent")
+	assert.Equal(t, "i-0123456789abcdef0", sender.sent[0].InstanceID)
+	assert.Equal(t, events.KindFailed, sender.sent[0].Outcome)
+	assert.Equal(t, "disk1", sender.sent[0].Device)
+	assert.Equal(t, "not enough space", sender.sent[0].Error)
+}
+
+func TestNotification_Subject(t *testing.T) {
+	n := Notification{InstanceID: "i-0123456789abcdef0", Operation: events.OperationGrow, Outcome: events.KindFinished, Device: "disk1"}
+
+	assert.Equal(t, "ec2-macos-utils: grow finished on disk1 (i-0123456789abcdef0)", n.Subject())
+}
+
+func TestWebhookSender(t *testing.T) {
+	var got Notification
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	s, err := NewWebhookSender(server.URL)
+	assert.NoError(t, err)
+	s.HTTPClient = server.Client()
+
+	want := Notification{Operation: events.OperationGrow, Outcome: events.KindFinished, Time: time.Unix(0, 0).UTC(), DurationSeconds: 1.5}
+	assert.NoError(t, s.Send(context.Background(), want))
+	assert.Equal(t, want, got)
+}
+
+func TestWebhookSender_Status(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	s, err := NewWebhookSender(server.URL)
+	assert.NoError(t, err)
+	s.HTTPClient = server.Client()
+
+	assert.Error(t, s.Send(context.Background(), Notification{}))
+}
+
+func TestNewWebhookSender_HTTP(t *testing.T) {
+	_, err := NewWebhookSender("http://example.com/hook")
+
+	assert.Error(t, err)
+}
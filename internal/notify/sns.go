@@ -0,0 +1,28 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+)
+
+// SNSSender publishes notifications as JSON to an SNS topic.
+type SNSSender struct {
+	// Client is the client used to publish notifications. Its region must be the topic's region.
+	Client *aws.Client
+	// TopicARN is the ARN of the topic.
+	TopicARN string
+}
+
+// Send publishes the notification to the topic, using its subject as the message subject.
+func (s *SNSSender) Send(ctx context.Context, n Notification) error {
+	message, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Client.Publish(ctx, s.TopicARN, n.Subject(), string(message))
+
+	return err
+}
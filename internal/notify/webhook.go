@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// WebhookSender posts notifications as JSON to an HTTPS webhook.
+type WebhookSender struct {
+	// URL is the webhook's URL.
+	URL string
+	// HTTPClient is the client used to post notifications.
+	HTTPClient *http.Client
+}
+
+// NewWebhookSender creates a WebhookSender for the URL. Only HTTPS URLs are accepted since notifications describe
+// the instance's disks.
+func NewWebhookSender(rawURL string) (*WebhookSender, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("notify: invalid webhook URL: %w", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("notify: webhook URL must be https, got [%s]", rawURL)
+	}
+
+	return &WebhookSender{URL: rawURL, HTTPClient: &http.Client{}}, nil
+}
+
+// Send posts the notification to the webhook. Responses other than 2xx are treated as failures.
+func (s *WebhookSender) Send(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("notify: failed to post to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notify: webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+// Package nvram provides the functionality necessary for managing firmware variables, such as boot-args, with
+// macOS's nvram CLI.
+package nvram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// BootArgs is the variable holding the kernel's boot arguments.
+	BootArgs = "boot-args"
+
+	// maxBootArgsLength is the longest boot argument string the kernel accepts, including the null terminator.
+	maxBootArgsLength = 1024
+)
+
+// ErrNotFound is returned when the variable isn't set.
+var ErrNotFound = errors.New("nvram: variable not found")
+
+// namePattern matches variable names, which may be prefixed by the GUID of their vendor namespace (e.g.
+// "7C436110-AB2A-4BBB-A880-FE41995C9F82:boot-args").
+var namePattern = regexp.MustCompile(`^([0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}:)?[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+// ValidateName checks that the variable name is well formed.
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("nvram: invalid variable name %q", name)
+	}
+
+	return nil
+}
+
+// Get fetches the value of the variable. Non-printable bytes in the value are percent encoded by nvram.
+func Get(ctx context.Context, name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+
+	// cmdGet represents the command used for executing macOS's nvram to get a variable.
+	//   * name - the variable to print
+	cmdGet := []string{"nvram", name}
+
+	out, err := util.ExecuteCommand(ctx, cmdGet, "", nil, nil)
+	if strings.Contains(out.Stderr, "data was not found") {
+		return "", ErrNotFound
+	} else if err != nil {
+		return "", fmt.Errorf("nvram: failed to get %s, stderr: [%s]: %w", name, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseValue(out.Stdout, name)
+}
+
+// Set sets the value of the variable.
+func Set(ctx context.Context, name string, value string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if name == BootArgs {
+		if err := ValidateBootArgs(strings.Fields(value)); err != nil {
+			return err
+		}
+	}
+
+	// cmdSet represents the command used for executing macOS's nvram to set a variable.
+	//   * name=value - the variable and the value to set it to
+	cmdSet := []string{"nvram", name + "=" + value}
+
+	out, err := util.ExecuteCommand(ctx, cmdSet, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("nvram: failed to set %s, stderr: [%s]: %w", name, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// Delete deletes the variable. Deleting a variable that isn't set isn't an error.
+func Delete(ctx context.Context, name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	// cmdDelete represents the command used for executing macOS's nvram to delete a variable.
+	//   * -d name - delete the variable
+	cmdDelete := []string{"nvram", "-d", name}
+
+	out, err := util.ExecuteCommand(ctx, cmdDelete, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("nvram: failed to delete %s, stderr: [%s]: %w", name, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// GetBootArgs fetches the kernel's boot arguments. No arguments are returned when boot-args isn't set.
+func GetBootArgs(ctx context.Context) ([]string, error) {
+	value, err := Get(ctx, BootArgs)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(value), nil
+}
+
+// ValidateBootArgs checks that the boot arguments can be stored in boot-args and read back by the kernel.
+func ValidateBootArgs(args []string) error {
+	for _, arg := range args {
+		if arg == "" {
+			return errors.New("nvram: empty boot argument")
+		}
+		for _, r := range arg {
+			if r <= ' ' || r > '~' {
+				return fmt.Errorf("nvram: boot argument %q must be printable ASCII without whitespace", arg)
+			}
+		}
+	}
+	if n := len(strings.Join(args, " ")); n >= maxBootArgsLength {
+		return fmt.Errorf("nvram: boot arguments are %d bytes, which is more than the kernel's limit of %d", n, maxBootArgsLength-1)
+	}
+
+	return nil
+}
+
+// MergeBootArgs adds the arguments in add, replacing existing arguments with the same key (e.g. "serial=3" replaces
+// "serial=1"), and then removes the arguments whose key is in remove. The order of existing arguments is kept.
+func MergeBootArgs(current []string, add []string, remove []string) []string {
+	merged := append([]string{}, current...)
+	for _, arg := range add {
+		replaced := false
+		for i, existing := range merged {
+			if bootArgKey(existing) == bootArgKey(arg) {
+				merged[i], replaced = arg, true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, arg)
+		}
+	}
+
+	var kept []string
+	for _, arg := range merged {
+		removed := false
+		for _, key := range remove {
+			if bootArgKey(arg) == bootArgKey(key) {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			kept = append(kept, arg)
+		}
+	}
+
+	return kept
+}
+
+// bootArgKey gets the key of the boot argument (e.g. "serial" for "serial=3" and "-v" for "-v").
+func bootArgKey(arg string) string {
+	if idx := strings.Index(arg, "="); idx != -1 {
+		return arg[:idx]
+	}
+
+	return arg
+}
+
+// parseValue parses the value from nvram's output for a variable (e.g. "boot-args\t-v serial=3").
+func parseValue(out string, name string) (string, error) {
+	out = strings.TrimRight(out, "\r\n")
+	idx := strings.Index(out, "\t")
+	if idx == -1 || out[:idx] != name {
+		return "", fmt.Errorf("nvram: unexpected output %q", out)
+	}
+
+	return out[idx+1:], nil
+}
@@ -0,0 +1,55 @@
+package nvram
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateName(t *testing.T) {
+	assert.NoError(t, ValidateName("boot-args"))
+	assert.NoError(t, ValidateName("7C436110-AB2A-4BBB-A880-FE41995C9F82:boot-args"))
+	assert.Error(t, ValidateName(""))
+	assert.Error(t, ValidateName("boot-args=-v"), "names can't contain values")
+	assert.Error(t, ValidateName("-d"), "names can't look like flags")
+}
+
+func TestValidateBootArgs(t *testing.T) {
+	assert.NoError(t, ValidateBootArgs([]string{"-v", "serial=3", "debug=0x144"}))
+	assert.NoError(t, ValidateBootArgs(nil))
+	assert.Error(t, ValidateBootArgs([]string{"-v", ""}))
+	assert.Error(t, ValidateBootArgs([]string{"name=a b"}), "arguments can't contain whitespace")
+	assert.Error(t, ValidateBootArgs([]string{"é"}), "arguments must be ASCII")
+	assert.Error(t, ValidateBootArgs([]string{strings.Repeat("a", maxBootArgsLength)}), "arguments must fit the kernel's limit")
+}
+
+func TestMergeBootArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		current []string
+		add     []string
+		remove  []string
+		want    []string
+	}{
+		{name: "add to empty", add: []string{"-v"}, want: []string{"-v"}},
+		{name: "replace key", current: []string{"-v", "serial=1"}, add: []string{"serial=3"}, want: []string{"-v", "serial=3"}},
+		{name: "already set", current: []string{"-v", "serial=3"}, add: []string{"-v"}, want: []string{"-v", "serial=3"}},
+		{name: "remove key", current: []string{"-v", "serial=3"}, remove: []string{"serial"}, want: []string{"-v"}},
+		{name: "remove all", current: []string{"-v"}, remove: []string{"-v"}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, MergeBootArgs(tt.current, tt.add, tt.remove))
+		})
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	value, err := parseValue("boot-args\t-v serial=3\n", "boot-args")
+	assert.NoError(t, err)
+	assert.Equal(t, "-v serial=3", value)
+
+	_, err = parseValue("nvram: Error getting variable\n", "boot-args")
+	assert.Error(t, err)
+}
@@ -0,0 +1,122 @@
+// Package oplock provides the advisory lock that keeps mutating disk operations (e.g. growing a container or
+// provisioning a volume) from running concurrently on the host, whether they're run by the CLI or served by the
+// control socket.
+package oplock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/flock"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+)
+
+// DefaultPath is the default path of the lock file.
+const DefaultPath = "/var/run/ec2-macos-utils.lock"
+
+// pollInterval is the time waited between attempts to take a lock that's held by another process.
+const pollInterval = 250 * time.Millisecond
+
+// LockedError is returned when the lock is held by another operation. It matches diskutil.ErrBusy so that it's
+// classified like the failures of disks that are in use.
+type LockedError struct {
+	// Path is the lock file.
+	Path string
+	// PID is the process holding the lock, it's 0 when the process couldn't be determined.
+	PID int
+	// Err is why waiting for the lock stopped (e.g. context.DeadlineExceeded), it's nil when the lock wasn't waited
+	// for.
+	Err error
+}
+
+func (e *LockedError) Error() string {
+	holder := "another process"
+	if e.PID > 0 {
+		holder = fmt.Sprintf("process %d", e.PID)
+	}
+	msg := fmt.Sprintf("another disk operation is running (%s holds %s)", holder, e.Path)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+
+	return msg
+}
+
+func (e *LockedError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is diskutil.ErrBusy.
+func (e *LockedError) Is(target error) bool {
+	return target == diskutil.ErrBusy
+}
+
+// Lock is a held lock.
+type Lock struct {
+	f *os.File
+}
+
+// Acquire takes the lock at path. When it's held by another process, Acquire fails with a LockedError immediately
+// unless wait is set, in which case it waits for the lock until ctx is done. The process's ID is written to the lock
+// file while it's held so that it can be reported to the operations waiting for it.
+func Acquire(ctx context.Context, path string, wait bool) (*Lock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("oplock: failed to open lock file: %w", err)
+	}
+
+	for {
+		err := flock.TryLock(f)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, flock.ErrWouldBlock) {
+			f.Close()
+			return nil, fmt.Errorf("oplock: failed to lock %s: %w", path, err)
+		}
+		if !wait {
+			f.Close()
+			return nil, &LockedError{Path: path, PID: holder(path)}
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, &LockedError{Path: path, PID: holder(path), Err: ctx.Err()}
+		case <-time.After(pollInterval):
+		}
+	}
+
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	return &Lock{f: f}, nil
+}
+
+// Release releases the lock.
+func (l *Lock) Release() error {
+	l.f.Truncate(0)
+	flock.Unlock(l.f)
+
+	return l.f.Close()
+}
+
+// holder reads the ID of the process holding the lock from the lock file.
+func holder(path string) int {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0
+	}
+
+	return pid
+}
@@ -0,0 +1,58 @@
+//go:build unix
+
+package oplock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+)
+
+func TestAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	lock, err := Acquire(context.Background(), path, false)
+	assert.NoError(t, err, "should take the free lock")
+
+	_, err = Acquire(context.Background(), path, false)
+	var lockedErr *LockedError
+	assert.True(t, errors.As(err, &lockedErr), "shouldn't take the held lock")
+	assert.Equal(t, os.Getpid(), lockedErr.PID, "should report the process holding the lock")
+	assert.Nil(t, lockedErr.Err, "shouldn't have waited for the lock")
+	assert.Equal(t, diskutil.ClassBusy, diskutil.Classify(err), "should be classified as busy")
+
+	assert.NoError(t, lock.Release())
+
+	lock, err = Acquire(context.Background(), path, false)
+	assert.NoError(t, err, "should take the released lock")
+	assert.NoError(t, lock.Release())
+}
+
+func TestAcquire_Wait(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	held, err := Acquire(context.Background(), path, false)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*pollInterval)
+	defer cancel()
+	_, err = Acquire(ctx, path, true)
+	var lockedErr *LockedError
+	assert.True(t, errors.As(err, &lockedErr), "shouldn't take the held lock")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "should stop waiting when the context is done")
+
+	go func() {
+		time.Sleep(pollInterval)
+		held.Release()
+	}()
+	waited, err := Acquire(context.Background(), path, true)
+	assert.NoError(t, err, "should take the lock once it's released")
+	assert.NoError(t, waited.Release())
+}
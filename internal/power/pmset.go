@@ -0,0 +1,163 @@
+// Package power provides the functionality necessary for managing macOS's power management settings with pmset.
+package power
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// Settings maps pmset setting names to their values.
+type Settings map[string]string
+
+// ServerSettings are the power management settings that keep an EC2 Mac host reachable while idle. macOS defaults
+// to settings meant for desktops and laptops which put idle hosts to sleep.
+var ServerSettings = Settings{
+	// sleep is the idle time, in minutes, before the system sleeps. 0 disables sleep.
+	"sleep": "0",
+	// disksleep is the idle time, in minutes, before disks are spun down. 0 disables disk sleep.
+	"disksleep": "0",
+	// displaysleep is the idle time, in minutes, before the display sleeps. 0 disables display sleep.
+	"displaysleep": "0",
+	// powernap allows the system to wake from sleep for periodic background work.
+	"powernap": "0",
+	// standby allows the system to hibernate after sleeping for a while.
+	"standby": "0",
+	// womp wakes the system on network access (i.e. "Wake for network access").
+	"womp": "1",
+}
+
+// settingExp matches a setting in pmset's list of active settings (e.g. "sleep    0 (sleep prevented by powerd)").
+// Setting names may contain spaces.
+var settingExp = regexp.MustCompile(`^\s*(\S.*?)\s+(\S+)(\s+\(.*\))?$`)
+
+// Get fetches the active power management settings with pmset.
+func Get(ctx context.Context) (Settings, error) {
+	// cmdGet represents the command used for executing macOS's pmset to get the active settings.
+	//   * -g - get the settings that are currently in use
+	cmdGet := []string{"pmset", "-g"}
+
+	out, err := util.ExecuteCommand(ctx, cmdGet, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("pmset: failed to get settings, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseSettings(strings.NewReader(out.Stdout))
+}
+
+// parseSettings parses the active settings printed by "pmset -g".
+func parseSettings(r io.Reader) (Settings, error) {
+	settings := Settings{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Headings (e.g. "Currently in use:") aren't indented like the settings are.
+		if !strings.HasPrefix(line, " ") {
+			continue
+		}
+		if m := settingExp.FindStringSubmatch(line); m != nil {
+			settings[m[1]] = m[2]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// Set applies the power management settings for all power sources with pmset.
+func Set(ctx context.Context, settings Settings) error {
+	if len(settings) == 0 {
+		return nil
+	}
+
+	// cmdSet represents the command used for executing macOS's pmset to apply settings.
+	//   * -a - apply the settings for all power sources
+	//   * setting value - the pairs of settings and values to be applied
+	cmdSet := []string{"pmset", "-a"}
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		cmdSet = append(cmdSet, k, settings[k])
+	}
+
+	out, err := util.ExecuteCommand(ctx, cmdSet, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("pmset: failed to apply settings, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// Task applies the desired power management settings.
+type Task struct {
+	// Desired are the settings to be applied.
+	Desired Settings
+}
+
+// NewTask creates a new Task which applies the ServerSettings.
+func NewTask() *Task {
+	return &Task{Desired: ServerSettings}
+}
+
+// Name identifies the task.
+func (t *Task) Name() string {
+	return "power"
+}
+
+// Check compares the active settings with the desired settings. Settings that pmset doesn't report aren't supported
+// by the hardware and are skipped.
+func (t *Task) Check(ctx context.Context) ([]task.Change, error) {
+	current, err := Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff(current, t.Desired), nil
+}
+
+// Apply applies the desired settings that differ from the active settings.
+func (t *Task) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := Settings{}
+	for _, c := range changes {
+		settings[c.Setting] = c.Desired
+	}
+	if err := Set(ctx, settings); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// diff compares the current settings with the desired settings, skipping any desired settings that aren't supported.
+func diff(current, desired Settings) []task.Change {
+	supported := Settings{}
+	for k, v := range desired {
+		if _, ok := current[k]; !ok {
+			logrus.WithField("setting", k).Debug("Setting not supported by pmset, skipping")
+			continue
+		}
+		supported[k] = v
+	}
+
+	return task.Diff(current, supported)
+}
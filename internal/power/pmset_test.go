@@ -0,0 +1,61 @@
+package power
+
+import (
+	_ "embed"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+// pmsetOutput contains the active settings of a host using macOS's default power management settings.
+//
+//go:embed testdata/pmset.txt
+var pmsetOutput string
+
+func init() {
+	logrus.SetOutput(ioutil.Discard)
+}
+
+func TestParseSettings(t *testing.T) {
+	settings, err := parseSettings(strings.NewReader(pmsetOutput))
+
+	assert.NoError(t, err)
+	assert.Equal(t, Settings{
+		"standby":               "1",
+		"Sleep On Power Button": "1",
+		"hibernatefile":         "/var/vm/sleepimage",
+		"powernap":              "1",
+		"networkoversleep":      "0",
+		"disksleep":             "10",
+		"sleep":                 "1",
+		"hibernatemode":         "0",
+		"ttyskeepawake":         "1",
+		"displaysleep":          "10",
+		"tcpkeepalive":          "1",
+		"womp":                  "1",
+	}, settings)
+}
+
+func TestDiff(t *testing.T) {
+	current, err := parseSettings(strings.NewReader(pmsetOutput))
+	assert.NoError(t, err)
+
+	desired := Settings{}
+	for k, v := range ServerSettings {
+		desired[k] = v
+	}
+	desired["autorestart"] = "1"
+
+	assert.Equal(t, []task.Change{
+		{Setting: "disksleep", Current: "10", Desired: "0"},
+		{Setting: "displaysleep", Current: "10", Desired: "0"},
+		{Setting: "powernap", Current: "1", Desired: "0"},
+		{Setting: "sleep", Current: "1", Desired: "0"},
+		{Setting: "standby", Current: "1", Desired: "0"},
+	}, diff(current, desired), "unsupported settings should be skipped")
+}
@@ -0,0 +1,90 @@
+// Package preflight provides the functionality necessary for checking that the utility has the permissions that
+// mutating operations need before they're started, rather than having them fail part of the way through.
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// tccDatabasePath is the path to the system's TCC database, which only processes with Full Disk Access can read.
+const tccDatabasePath = "/Library/Application Support/com.apple.TCC/TCC.db"
+
+var (
+	// geteuid gets the effective UID of the process, it's replaced in tests.
+	geteuid = os.Geteuid
+	// tccPath is the path probed for Full Disk Access, it's replaced in tests.
+	tccPath = tccDatabasePath
+	// appleSilicon checks if the system has an Apple silicon processor, it's replaced in tests.
+	appleSilicon = system.AppleSilicon
+)
+
+// PermissionError is returned when the process doesn't have a permission that an operation needs. It matches
+// os.ErrPermission so that it's classified like the permission failures of macOS's tools.
+type PermissionError struct {
+	// Missing is the permission that the process doesn't have (e.g. "root privileges").
+	Missing string
+	// Guidance describes how the permission can be granted.
+	Guidance string
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("%s required, %s", e.Missing, e.Guidance)
+}
+
+// Is reports whether target is os.ErrPermission.
+func (e *PermissionError) Is(target error) bool {
+	return target == os.ErrPermission
+}
+
+// Root checks that the process runs with an effective UID of root.
+func Root() error {
+	if geteuid() != 0 {
+		return &PermissionError{Missing: "root privileges", Guidance: "re-run command with sudo"}
+	}
+
+	return nil
+}
+
+// FullDiskAccess checks that the process has been granted Full Disk Access by probing the TCC database. Failures
+// other than being denied (e.g. the database not existing) can't tell either way, so they aren't reported.
+func FullDiskAccess() error {
+	f, err := os.Open(tccPath)
+	if errors.Is(err, os.ErrPermission) {
+		return &PermissionError{
+			Missing:  "Full Disk Access",
+			Guidance: "grant it to the program running the command (e.g. sshd-keygen-wrapper, Terminal, or the launchd job's program) in System Settings > Privacy & Security > Full Disk Access",
+		}
+	}
+	if err != nil {
+		logrus.WithError(err).Debug("Unable to probe for Full Disk Access")
+		return nil
+	}
+
+	return f.Close()
+}
+
+// Disk checks the permissions that operations on disks need: root privileges and, on Apple silicon where raw
+// disk access is protected by TCC, Full Disk Access.
+func Disk(ctx context.Context) error {
+	if err := Root(); err != nil {
+		return err
+	}
+
+	arm64, err := appleSilicon(ctx)
+	if err != nil {
+		logrus.WithError(err).Debug("Unable to detect Apple silicon, not checking for Full Disk Access")
+		return nil
+	}
+	if !arm64 {
+		return nil
+	}
+
+	return FullDiskAccess()
+}
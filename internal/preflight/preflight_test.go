@@ -0,0 +1,67 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+)
+
+// stub replaces the system probes for the duration of the test.
+func stub(t *testing.T, euid int, path string, arm64 bool) {
+	prevEUID, prevPath, prevArm64 := geteuid, tccPath, appleSilicon
+	t.Cleanup(func() { geteuid, tccPath, appleSilicon = prevEUID, prevPath, prevArm64 })
+
+	geteuid = func() int { return euid }
+	tccPath = path
+	appleSilicon = func(context.Context) (bool, error) { return arm64, nil }
+}
+
+func TestRoot(t *testing.T) {
+	stub(t, 501, "", false)
+
+	err := Root()
+
+	var permErr *PermissionError
+	assert.True(t, errors.As(err, &permErr))
+	assert.EqualError(t, err, "root privileges required, re-run command with sudo")
+	assert.True(t, errors.Is(err, os.ErrPermission))
+	assert.Equal(t, diskutil.ClassPermissionDenied, diskutil.Classify(err), "should exit like other permission failures")
+}
+
+func TestDisk(t *testing.T) {
+	readable := filepath.Join(t.TempDir(), "TCC.db")
+	assert.NoError(t, os.WriteFile(readable, nil, 0o600))
+
+	stub(t, 0, readable, true)
+	assert.NoError(t, Disk(context.Background()))
+
+	stub(t, 0, filepath.Join(t.TempDir(), "missing.db"), true)
+	assert.NoError(t, Disk(context.Background()), "should only fail when access is denied")
+
+	stub(t, 501, readable, true)
+	assert.Error(t, Disk(context.Background()), "should require root")
+}
+
+func TestDisk_WithoutFullDiskAccess(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read files without permission")
+	}
+	denied := filepath.Join(t.TempDir(), "TCC.db")
+	assert.NoError(t, os.WriteFile(denied, nil, 0o000))
+
+	stub(t, 0, denied, true)
+	err := Disk(context.Background())
+
+	var permErr *PermissionError
+	assert.True(t, errors.As(err, &permErr))
+	assert.Equal(t, "Full Disk Access", permErr.Missing)
+
+	stub(t, 0, denied, false)
+	assert.NoError(t, Disk(context.Background()), "should only check for Full Disk Access on Apple silicon")
+}
@@ -0,0 +1,101 @@
+package profiles
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// Enrollment is the host's MDM enrollment state.
+type Enrollment struct {
+	// DEP indicates that the host was enrolled through Automated Device Enrollment (DEP).
+	DEP bool `json:"dep"`
+	// MDM indicates that the host is enrolled in MDM.
+	MDM bool `json:"mdm"`
+	// UserApproved indicates that the MDM enrollment was approved by a user (or through DEP), which is required for
+	// MDM to manage kernel extensions, privacy settings, and software updates.
+	UserApproved bool `json:"user_approved"`
+	// Server is the URL of the MDM server, when macOS reports it.
+	Server string `json:"server,omitempty"`
+}
+
+// Managed checks if the host is enrolled in MDM, by any means.
+func (e *Enrollment) Managed() bool {
+	return e.MDM || e.DEP
+}
+
+// String describes the enrollment state for operators.
+func (e *Enrollment) String() string {
+	if !e.Managed() {
+		return "not enrolled in MDM"
+	}
+
+	msg := "enrolled in MDM"
+	if e.DEP {
+		msg += " via DEP"
+	}
+	if e.UserApproved {
+		msg += " (user approved)"
+	}
+	if e.Server != "" {
+		msg += " with " + e.Server
+	}
+
+	return msg
+}
+
+// EnrollmentStatus gets the host's MDM enrollment state.
+func EnrollmentStatus(ctx context.Context) (*Enrollment, error) {
+	// cmdStatus represents the command used for executing macOS's profiles to get the enrollment state.
+	//   * status - print the state of profiles
+	//   * -type enrollment - print the DEP and MDM enrollment state
+	cmdStatus := []string{"profiles", "status", "-type", "enrollment"}
+
+	out, err := util.ExecuteCommand(ctx, cmdStatus, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("profiles: failed to get enrollment status, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseEnrollment(strings.NewReader(out.Stdout))
+}
+
+// parseEnrollment parses the "key: value" lines printed by "profiles status -type enrollment" (e.g.
+// "MDM enrollment: Yes (User Approved)").
+func parseEnrollment(r io.Reader) (*Enrollment, error) {
+	e := &Enrollment{}
+	found := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		yes := strings.HasPrefix(strings.ToLower(value), "yes")
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "enrolled via dep":
+			e.DEP = yes
+			found = true
+		case "mdm enrollment":
+			e.MDM = yes
+			e.UserApproved = yes && strings.Contains(strings.ToLower(value), "user approved")
+			found = true
+		case "mdm server":
+			e.Server = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("profiles: failed to read enrollment status: %w", err)
+	}
+	if !found {
+		return nil, fmt.Errorf("profiles: enrollment status not found in output")
+	}
+
+	return e, nil
+}
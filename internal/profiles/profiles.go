@@ -0,0 +1,215 @@
+// Package profiles provides the functionality necessary for installing, listing, and removing configuration
+// profiles (.mobileconfig files) with macOS's profiles CLI.
+package profiles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"howett.net/plist"
+
+	"github.com/aws/ec2-macos-utils/pkg/system"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// computerLevel is the key of the profiles installed for the whole computer, rather than for a user, in the output
+// of "profiles show".
+const computerLevel = "_computerlevel"
+
+// ErrMDMRequired is returned when macOS only allows the change through an MDM server (or approval in System
+// Settings), which is the case for installing profiles on Big Sur and later and for removing profiles installed by
+// MDM.
+var ErrMDMRequired = errors.New("profiles: change requires MDM")
+
+// Profile is an installed configuration profile.
+type Profile struct {
+	// Identifier is the profile's unique reverse-DNS identifier (e.g. "com.example.fleet.rootca").
+	Identifier string `json:"identifier"`
+	// DisplayName is the profile's human-readable name.
+	DisplayName string `json:"display_name"`
+	// Organization is the organization that issued the profile.
+	Organization string `json:"organization,omitempty"`
+	// UUID identifies the installed version of the profile.
+	UUID string `json:"uuid"`
+	// InstallDate is when the profile was installed.
+	InstallDate string `json:"install_date,omitempty"`
+	// User is the user the profile is installed for, which is empty for profiles installed for the whole computer.
+	User string `json:"user,omitempty"`
+	// PayloadTypes are the types of the profile's payloads (e.g. "com.apple.security.root").
+	PayloadTypes []string `json:"payload_types"`
+	// Removable indicates that the profile can be removed without MDM.
+	Removable bool `json:"removable"`
+}
+
+// installedProfile is a profile as printed by "profiles show -output stdout-xml".
+type installedProfile struct {
+	ProfileIdentifier        string
+	ProfileDisplayName       string
+	ProfileOrganization      string
+	ProfileUUID              string
+	ProfileInstallDate       string
+	ProfileRemovalDisallowed string
+	ProfileItems             []struct {
+		PayloadType string
+	}
+}
+
+// List lists the configuration profiles installed for the computer and its users, ordered by user and identifier.
+func List(ctx context.Context) ([]Profile, error) {
+	// cmdShow represents the command used for executing macOS's profiles to list the installed profiles.
+	//   * show - print the installed profiles
+	//   * -output stdout-xml - print them as a plist
+	cmdShow := []string{"profiles", "show", "-output", "stdout-xml"}
+
+	out, err := util.ExecuteCommand(ctx, cmdShow, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("profiles: failed to list profiles, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseShow([]byte(out.Stdout))
+}
+
+// parseShow parses the profiles printed by "profiles show -output stdout-xml", which are keyed by the user they're
+// installed for. Nothing is printed when no profiles are installed.
+func parseShow(data []byte) ([]Profile, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return []Profile{}, nil
+	}
+
+	var installed map[string][]installedProfile
+	if _, err := plist.Unmarshal(data, &installed); err != nil {
+		return nil, fmt.Errorf("profiles: failed to parse profiles: %w", err)
+	}
+
+	profiles := []Profile{}
+	for user, list := range installed {
+		if user == computerLevel {
+			user = ""
+		}
+		for _, p := range list {
+			profile := Profile{
+				Identifier:   p.ProfileIdentifier,
+				DisplayName:  p.ProfileDisplayName,
+				Organization: p.ProfileOrganization,
+				UUID:         p.ProfileUUID,
+				InstallDate:  p.ProfileInstallDate,
+				User:         user,
+				PayloadTypes: []string{},
+				Removable:    !strings.EqualFold(p.ProfileRemovalDisallowed, "true"),
+			}
+			for _, item := range p.ProfileItems {
+				profile.PayloadTypes = append(profile.PayloadTypes, item.PayloadType)
+			}
+			profiles = append(profiles, profile)
+		}
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].User != profiles[j].User {
+			return profiles[i].User < profiles[j].User
+		}
+		return profiles[i].Identifier < profiles[j].Identifier
+	})
+
+	return profiles, nil
+}
+
+// File is the identity of a configuration profile file.
+type File struct {
+	// Identifier is the profile's unique reverse-DNS identifier.
+	Identifier string `plist:"PayloadIdentifier"`
+	// DisplayName is the profile's human-readable name.
+	DisplayName string `plist:"PayloadDisplayName"`
+	// UUID identifies the version of the profile.
+	UUID string `plist:"PayloadUUID"`
+	// Type is the type of the profile's top-level payload, which is "Configuration" for configuration profiles.
+	Type string `plist:"PayloadType"`
+}
+
+// ReadFile reads the identity of the configuration profile at path. Signed profiles are wrapped in CMS, which is
+// skipped over by looking for the property list inside of it.
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if start, end := bytes.Index(data, []byte("<?xml")), bytes.LastIndex(data, []byte("</plist>")); start > 0 && end > start {
+		data = data[start : end+len("</plist>")]
+	}
+
+	f := &File{}
+	if _, err := plist.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("profiles: %s isn't a configuration profile: %w", path, err)
+	}
+	if f.Type != "Configuration" || f.Identifier == "" {
+		return nil, fmt.Errorf("profiles: %s isn't a configuration profile", path)
+	}
+
+	return f, nil
+}
+
+// InstallSupported checks if profiles can be installed from the command line on the product. macOS Big Sur and
+// later only install profiles through MDM or after they're approved in System Settings.
+func InstallSupported(p *system.Product) bool {
+	return p.Release == system.Mojave || p.Release == system.Catalina
+}
+
+// Install installs the configuration profile at path for the computer. ErrMDMRequired is returned when the product
+// doesn't allow installing profiles from the command line.
+func Install(ctx context.Context, p *system.Product, path string) error {
+	if !InstallSupported(p) {
+		return fmt.Errorf("%w: macOS %s only installs profiles through MDM or after approval in System Settings", ErrMDMRequired, p.Version.String())
+	}
+
+	// cmdInstall represents the command used for executing macOS's profiles to install a profile.
+	//   * install - install a profile
+	//   * -type configuration - the profile is a configuration profile
+	//   * -path <path> - the path to the profile
+	cmdInstall := []string{"profiles", "install", "-type", "configuration", "-path", path}
+
+	out, err := util.ExecuteCommand(ctx, cmdInstall, "", nil, nil)
+	if err != nil {
+		if requiresMDM(out.Stdout + out.Stderr) {
+			return fmt.Errorf("%w: %s", ErrMDMRequired, strings.TrimSpace(out.Stdout+out.Stderr))
+		}
+		return fmt.Errorf("profiles: failed to install %s, stderr: [%s]: %w", path, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// Remove removes the installed configuration profile with the identifier. ErrMDMRequired is returned when the profile
+// can only be removed by the MDM server that installed it.
+func Remove(ctx context.Context, identifier string) error {
+	// cmdRemove represents the command used for executing macOS's profiles to remove a profile.
+	//   * remove - remove a profile
+	//   * -identifier <identifier> - the identifier of the profile
+	cmdRemove := []string{"profiles", "remove", "-identifier", identifier}
+
+	out, err := util.ExecuteCommand(ctx, cmdRemove, "", nil, nil)
+	if err != nil {
+		if requiresMDM(out.Stdout + out.Stderr) {
+			return fmt.Errorf("%w: %s", ErrMDMRequired, strings.TrimSpace(out.Stdout+out.Stderr))
+		}
+		return fmt.Errorf("profiles: failed to remove %s, stderr: [%s]: %w", identifier, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// requiresMDM checks if the output of profiles says that the change is only possible through MDM or System
+// Settings.
+func requiresMDM(out string) bool {
+	out = strings.ToLower(out)
+	for _, msg := range []string{"no longer supports install", "mdm", "not removable", "removal disallowed", "system settings", "system preferences"} {
+		if strings.Contains(out, msg) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,117 @@
+package profiles
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// showOutput contains the profiles printed by "profiles show -output stdout-xml" for a certificate profile and an
+// MDM enrollment profile.
+//
+//go:embed testdata/show.plist
+var showOutput []byte
+
+func TestParseShow(t *testing.T) {
+	profiles, err := parseShow(showOutput)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Profile{
+		{
+			Identifier:   "com.example.fleet.rootca",
+			DisplayName:  "Build Fleet Root CA",
+			Organization: "Example Corp",
+			UUID:         "8C7A2E5B-4F0D-4A39-9B53-0E2C1D6F9A11",
+			InstallDate:  "2023-10-14 09:12:45 +0000",
+			PayloadTypes: []string{"com.apple.security.root"},
+			Removable:    true,
+		},
+		{
+			Identifier:   "com.example.mdm",
+			DisplayName:  "MDM Profile",
+			UUID:         "1D5E2C3B-7A8F-4E6D-8C9B-2F1A3E4D5C6B",
+			InstallDate:  "2023-10-01 12:00:00 +0000",
+			PayloadTypes: []string{"com.apple.mdm"},
+		},
+	}, profiles)
+}
+
+func TestParseShow_Empty(t *testing.T) {
+	profiles, err := parseShow([]byte("\n"))
+
+	assert.NoError(t, err)
+	assert.Empty(t, profiles)
+}
+
+func TestReadFile(t *testing.T) {
+	f, err := ReadFile("testdata/restrictions.mobileconfig")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &File{
+		Identifier:  "com.example.fleet.restrictions",
+		DisplayName: "Build Fleet Restrictions",
+		UUID:        "9A8B7C6D-5E4F-4A3B-2C1D-0E9F8A7B6C5D",
+		Type:        "Configuration",
+	}, f)
+}
+
+func TestReadFile_NotProfile(t *testing.T) {
+	_, err := ReadFile("testdata/show.plist")
+
+	assert.Error(t, err, "a plist that isn't a configuration profile should fail")
+}
+
+func TestInstall_MDMRequired(t *testing.T) {
+	p := &system.Product{Release: system.Ventura, Version: *semver.MustParse("13.6.1")}
+
+	err := Install(context.Background(), p, "testdata/restrictions.mobileconfig")
+
+	assert.True(t, errors.Is(err, ErrMDMRequired), "installing on Ventura should require MDM")
+}
+
+func TestInstallSupported(t *testing.T) {
+	assert.True(t, InstallSupported(&system.Product{Release: system.Catalina}))
+	assert.False(t, InstallSupported(&system.Product{Release: system.BigSur}))
+	assert.False(t, InstallSupported(&system.Product{Release: system.CompatMode}))
+}
+
+func TestRequiresMDM(t *testing.T) {
+	assert.True(t, requiresMDM("profiles tool no longer supports installs. Use System Settings Profiles to add configuration profiles."))
+	assert.True(t, requiresMDM("profiles: Profile is not removable"))
+	assert.False(t, requiresMDM("profiles: Unable to open file"))
+}
+
+// enrollmentOutput contains the enrollment state printed by "profiles status -type enrollment" for a host enrolled
+// through DEP.
+//
+//go:embed testdata/enrollment.txt
+var enrollmentOutput string
+
+func TestParseEnrollment(t *testing.T) {
+	e, err := parseEnrollment(strings.NewReader(enrollmentOutput))
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Enrollment{DEP: true, MDM: true, UserApproved: true, Server: "https://mdm.example.com/mdm/server"}, e)
+	assert.Equal(t, "enrolled in MDM via DEP (user approved) with https://mdm.example.com/mdm/server", e.String())
+}
+
+func TestParseEnrollment_Unmanaged(t *testing.T) {
+	e, err := parseEnrollment(strings.NewReader("Enrolled via DEP: No\nMDM enrollment: No\n"))
+
+	assert.NoError(t, err)
+	assert.False(t, e.Managed())
+	assert.Equal(t, "not enrolled in MDM", e.String())
+}
+
+func TestParseEnrollment_Invalid(t *testing.T) {
+	_, err := parseEnrollment(strings.NewReader("profiles: unknown verb\n"))
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,278 @@
+// Package purgeable provides the functionality necessary for accounting for the purgeable space on APFS volumes,
+// which macOS counts as free since it can be freed on demand, and for reclaiming it. Purgeable space is mostly held
+// by local snapshots (e.g. Time Machine's) and caches, and it's why the free space seen by df regularly disagrees
+// with the free space reported by diskutil and the Finder.
+package purgeable
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"howett.net/plist"
+
+	"github.com/aws/ec2-macos-utils/internal/mounts"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// timeMachinePrefix is the prefix of the names of the local snapshots created by Time Machine.
+	timeMachinePrefix = "com.apple.TimeMachine."
+	// thinAmount is the amount of space, in bytes, that tmutil is asked to free which is more than any volume holds
+	// so that every local snapshot that can be thinned is.
+	thinAmount = "999999999999999"
+	// thinUrgency is the highest urgency that tmutil thins local snapshots with.
+	thinUrgency = "4"
+)
+
+// CacheDirs are the system cache directories that are measured and can be purged. Their contents are recreated by
+// the apps and services that use them.
+var CacheDirs = []string{"/Library/Caches"}
+
+// Snapshot is an APFS snapshot of a volume.
+type Snapshot struct {
+	// Name is the name of the snapshot (e.g. com.apple.TimeMachine.2023-08-01-120000.local).
+	Name string `plist:"SnapshotName" json:"name"`
+	// UUID is the snapshot's UUID.
+	UUID string `plist:"SnapshotUUID" json:"uuid"`
+	// XID is the transaction ID of the snapshot, which orders snapshots from oldest to newest.
+	XID uint64 `plist:"SnapshotXID" json:"xid"`
+	// Purgeable indicates that macOS deletes the snapshot when space is needed.
+	Purgeable bool `plist:"Purgeable" json:"purgeable"`
+}
+
+// TimeMachine checks if the snapshot is a local snapshot created by Time Machine, which can be thinned with tmutil.
+func (s Snapshot) TimeMachine() bool {
+	return strings.HasPrefix(s.Name, timeMachinePrefix)
+}
+
+// Cache is a cache directory and the space its contents use.
+type Cache struct {
+	// Path is the path to the cache directory.
+	Path string `json:"path"`
+	// Size is the space used by the directory's contents in bytes.
+	Size uint64 `json:"size"`
+}
+
+// Volume is the purgeable space accounting of a mounted volume.
+type Volume struct {
+	// MountPoint is where the volume is mounted.
+	MountPoint string `json:"mount_point"`
+	// FreeSpace is the volume's free space as reported by diskutil, which includes the purgeable space.
+	FreeSpace uint64 `json:"free_space"`
+	// AvailableSpace is the volume's free space as reported by statfs(2), which df reports and which excludes the
+	// purgeable space.
+	AvailableSpace uint64 `json:"available_space"`
+	// Snapshots are the volume's APFS snapshots.
+	Snapshots []Snapshot `json:"snapshots"`
+	// Caches are the cache directories on the volume.
+	Caches []Cache `json:"caches,omitempty"`
+}
+
+// Purgeable estimates the space that macOS can free on demand as the difference between the free space reported by
+// diskutil and the space available to df.
+func (v Volume) Purgeable() uint64 {
+	if v.FreeSpace < v.AvailableSpace {
+		return 0
+	}
+
+	return v.FreeSpace - v.AvailableSpace
+}
+
+// CacheSize sums the space used by the volume's caches.
+func (v Volume) CacheSize() uint64 {
+	var total uint64
+	for _, c := range v.Caches {
+		total += c.Size
+	}
+
+	return total
+}
+
+// Account accounts for the purgeable space of the volume mounted at mountPoint. The cache directories are only
+// measured for the volume that holds them, which is the data volume on macOS releases with a read-only system volume.
+func Account(ctx context.Context, u diskutil.DiskUtil, mountPoint string) (*Volume, error) {
+	info, err := u.Info(ctx, mountPoint)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get volume information: %w", err)
+	}
+	usage, err := mounts.Usage(mountPoint)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := Snapshots(ctx, mountPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for _, dir := range CacheDirs {
+		if fs, err := mounts.Usage(dir); err == nil && fs.MountPoint == usage.MountPoint {
+			dirs = append(dirs, dir)
+		}
+	}
+	caches, err := MeasureCaches(dirs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Volume{
+		MountPoint:     mountPoint,
+		FreeSpace:      info.FreeSpace,
+		AvailableSpace: usage.AvailableBytes,
+		Snapshots:      snapshots,
+		Caches:         caches,
+	}, nil
+}
+
+// snapshotList mirrors the output format of "diskutil apfs listSnapshots -plist".
+type snapshotList struct {
+	Snapshots []Snapshot `plist:"Snapshots"`
+}
+
+// Snapshots lists the APFS snapshots of the volume mounted at mountPoint.
+func Snapshots(ctx context.Context, mountPoint string) ([]Snapshot, error) {
+	// cmdListSnapshots represents the command used for executing macOS's diskutil to list a volume's snapshots.
+	//   * apfs listSnapshots - list the APFS snapshots of the volume
+	//   * -plist - generate the output as a plist
+	cmdListSnapshots := []string{"diskutil", "apfs", "listSnapshots", "-plist", mountPoint}
+
+	out, err := util.ExecuteCommand(ctx, cmdListSnapshots, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("purgeable: failed to list snapshots of %s, stderr: [%s]: %w", mountPoint, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return decodeSnapshots(strings.NewReader(out.Stdout))
+}
+
+// decodeSnapshots decodes the raw plist data from diskutil into the list of snapshots.
+func decodeSnapshots(reader io.ReadSeeker) ([]Snapshot, error) {
+	var list snapshotList
+	if err := plist.NewDecoder(reader).Decode(&list); err != nil {
+		return nil, fmt.Errorf("error decoding snapshots: %w", err)
+	}
+
+	return list.Snapshots, nil
+}
+
+// ThinSnapshots thins the local Time Machine snapshots of the volume mounted at mountPoint with tmutil, as much as
+// tmutil allows, and returns the names of the snapshots that were deleted.
+func ThinSnapshots(ctx context.Context, mountPoint string) ([]string, error) {
+	// cmdThin represents the command used for executing macOS's tmutil to thin local snapshots.
+	//   * thinlocalsnapshots - delete local snapshots until the amount of space is freed
+	//   * thinAmount - the amount of space to free, which is every snapshot that can be thinned
+	//   * thinUrgency - the urgency of the request, which allows the most snapshots to be deleted
+	cmdThin := []string{"tmutil", "thinlocalsnapshots", mountPoint, thinAmount, thinUrgency}
+
+	out, err := util.ExecuteCommand(ctx, cmdThin, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("purgeable: failed to thin snapshots of %s, stderr: [%s]: %w", mountPoint, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseThinned(out.Stdout), nil
+}
+
+// parseThinned parses the names of the deleted snapshots from tmutil's output, for example:
+//
+//	Thinned local snapshots:
+//	2023-08-01-120000
+//	2023-08-01-130000
+func parseThinned(out string) []string {
+	var names []string
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue
+		}
+		names = append(names, line)
+	}
+
+	return names
+}
+
+// MeasureCaches measures the space used by the contents of each of the cache directories, skipping the ones that
+// don't exist.
+func MeasureCaches(dirs []string) ([]Cache, error) {
+	var caches []Cache
+	for _, dir := range dirs {
+		size, err := dirSize(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("purgeable: cannot measure %s: %w", dir, err)
+		}
+		caches = append(caches, Cache{Path: dir, Size: size})
+	}
+
+	return caches, nil
+}
+
+// PurgeCaches removes the contents of each of the cache directories, leaving the directories themselves, and returns
+// the space that the removed contents used. Entries that can't be removed (e.g. ones protected by SIP) are skipped
+// and reported in the error once the rest have been removed.
+func PurgeCaches(ctx context.Context, dirs []string) (uint64, error) {
+	var freed uint64
+	var failed []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return freed, fmt.Errorf("purgeable: cannot read %s: %w", dir, err)
+		}
+
+		for _, entry := range entries {
+			if err := ctx.Err(); err != nil {
+				return freed, err
+			}
+
+			path := filepath.Join(dir, entry.Name())
+			size, err := dirSize(path)
+			if err != nil {
+				failed = append(failed, path)
+				continue
+			}
+			if err := os.RemoveAll(path); err != nil {
+				failed = append(failed, path)
+				continue
+			}
+			freed += size
+		}
+	}
+
+	if len(failed) > 0 {
+		return freed, fmt.Errorf("purgeable: cannot remove %d cache entries: %s", len(failed), strings.Join(failed, ", "))
+	}
+
+	return freed, nil
+}
+
+// dirSize sums the sizes of the regular files under path, which can be a directory or a file. Symbolic links aren't
+// followed.
+func dirSize(path string) (uint64, error) {
+	var size uint64
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += uint64(info.Size())
+
+		return nil
+	})
+
+	return size, err
+}
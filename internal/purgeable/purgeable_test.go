@@ -0,0 +1,70 @@
+package purgeable
+
+import (
+	"context"
+	_ "embed"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// volumeSnapshots contains diskutil output with a Time Machine snapshot and a snapshot created by another tool.
+//
+//go:embed testdata/snapshots.plist
+var volumeSnapshots string
+
+func TestDecodeSnapshots(t *testing.T) {
+	snapshots, err := decodeSnapshots(strings.NewReader(volumeSnapshots))
+
+	assert.NoError(t, err, "should be able to decode diskutil output")
+	if assert.Len(t, snapshots, 2) {
+		assert.Equal(t, Snapshot{
+			Name:      "com.apple.TimeMachine.2023-08-01-120000.local",
+			UUID:      "0F1D7A6E-7C58-4B2C-9E1F-2A0C6D3B4E51",
+			XID:       1042,
+			Purgeable: true,
+		}, snapshots[0])
+		assert.True(t, snapshots[0].TimeMachine())
+		assert.False(t, snapshots[1].TimeMachine())
+		assert.False(t, snapshots[1].Purgeable)
+	}
+}
+
+func TestDecodeSnapshots_WithoutPlistInput(t *testing.T) {
+	_, err := decodeSnapshots(strings.NewReader("this is not a plist"))
+
+	assert.Error(t, err, "shouldn't be able to decode non-plist input")
+}
+
+func TestParseThinned(t *testing.T) {
+	assert.Equal(t, []string{"2023-08-01-120000", "2023-08-01-130000"}, parseThinned("Thinned local snapshots:\n2023-08-01-120000\n2023-08-01-130000\n"))
+	assert.Empty(t, parseThinned("Thinned local snapshots:\n"))
+}
+
+func TestVolume_Purgeable(t *testing.T) {
+	assert.Equal(t, uint64(30), Volume{FreeSpace: 100, AvailableSpace: 70}.Purgeable())
+	assert.Equal(t, uint64(0), Volume{FreeSpace: 70, AvailableSpace: 100}.Purgeable(), "more available space than free space isn't purgeable")
+}
+
+func TestMeasurePurgeCaches(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "com.example.app"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "com.example.app", "cache.db"), make([]byte, 300), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "index"), make([]byte, 200), 0o600))
+	missing := filepath.Join(dir, "missing")
+
+	caches, err := MeasureCaches([]string{dir, missing})
+	assert.NoError(t, err)
+	assert.Equal(t, []Cache{{Path: dir, Size: 500}}, caches, "missing directories should be skipped")
+
+	freed, err := PurgeCaches(context.Background(), []string{dir, missing})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(500), freed)
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err, "the cache directory should be left")
+	assert.Empty(t, entries, "the cache directory's contents should be removed")
+}
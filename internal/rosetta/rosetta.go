@@ -0,0 +1,46 @@
+// Package rosetta provides the functionality necessary for detecting and installing Rosetta 2, which runs Intel
+// (x86_64) binaries on Apple silicon.
+package rosetta
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// Installed checks if Rosetta is installed by running an Intel binary, which fails on Apple silicon until it is.
+// Intel systems run the binary natively so they're always reported as having it.
+func Installed(ctx context.Context) (bool, error) {
+	// cmdTranslate represents the command used for executing macOS's arch to run a binary under Rosetta.
+	//   * -x86_64 - run the following binary's Intel architecture
+	//   * /usr/bin/true - a universal binary which exits successfully
+	cmdTranslate := []string{"arch", "-x86_64", "/usr/bin/true"}
+
+	out, err := util.ExecuteCommand(ctx, cmdTranslate, "", nil, nil)
+	if err != nil {
+		// arch can't run the Intel architecture without Rosetta (e.g. "Bad CPU type in executable")
+		if strings.Contains(out.Stderr, "Bad CPU type") || strings.Contains(out.Stderr, "Unknown architecture") {
+			return false, nil
+		}
+		return false, fmt.Errorf("rosetta: failed to run an Intel binary, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return true, nil
+}
+
+// Install installs Rosetta without prompting, agreeing to its license on behalf of the user.
+func Install(ctx context.Context) error {
+	// cmdInstall represents the command used for executing macOS's softwareupdate to install Rosetta.
+	//   * --install-rosetta - install Rosetta
+	//   * --agree-to-license - agree to the license without prompting
+	cmdInstall := []string{"softwareupdate", "--install-rosetta", "--agree-to-license"}
+
+	out, err := util.ExecuteCommand(ctx, cmdInstall, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("rosetta: failed to install Rosetta, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,260 @@
+// Package schedule provides the functionality necessary for running the utility's subcommands on a schedule with
+// launchd jobs, either on an interval or when the time matches a calendar interval.
+package schedule
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+)
+
+const (
+	// LogDir is the directory that scheduled runs' output is written to.
+	LogDir = "/var/log/ec2-macos-utils"
+
+	// labelName is the name that the labels of scheduled jobs start with, after launchd.LabelPrefix.
+	labelName = "schedule."
+
+	// NameEnv is the environment variable that scheduled runs are started with, set to the name of their schedule,
+	// which tells them apart from runs started by hand.
+	NameEnv = "EC2_MACOS_UTILS_SCHEDULE"
+
+	// minInterval is the shortest interval that subcommands can be scheduled at.
+	minInterval = time.Minute
+)
+
+// namePattern matches valid schedule names, which are used in job labels and log file names.
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// ErrNotScheduled is returned when there's no schedule with the name.
+var ErrNotScheduled = errors.New("schedule: not scheduled")
+
+// Schedule runs a subcommand of the utility on an interval or calendar schedule.
+type Schedule struct {
+	// Name identifies the schedule (e.g. "nightly-thin").
+	Name string `json:"name"`
+	// Args are the subcommand and its arguments (e.g. ["volume", "thin", "--keep", "7"]).
+	Args []string `json:"args"`
+	// Interval runs the subcommand repeatedly with this much time between the starts of each run.
+	Interval time.Duration `json:"interval,omitempty"`
+	// Calendar runs the subcommand whenever the time matches. It's only used when Interval isn't set.
+	Calendar *launchd.CalendarInterval `json:"calendar,omitempty"`
+}
+
+// Label gets the label of the job for the schedule with the name (e.g.
+// "com.amazon.ec2.macos-utils.schedule.nightly-thin").
+func Label(name string) string {
+	return launchd.Label(labelName + name)
+}
+
+// NameFromLabel gets the name of the schedule from the label of its job. ok is false for labels of other jobs.
+func NameFromLabel(label string) (name string, ok bool) {
+	prefix := Label("")
+	if !strings.HasPrefix(label, prefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(label, prefix), true
+}
+
+// LogPath gets the path to the file that the schedule's runs write their output to.
+func LogPath(name string) string {
+	return filepath.Join(LogDir, "schedule."+name+".log")
+}
+
+// Validate checks that the schedule can be installed.
+func (s *Schedule) Validate() error {
+	if !namePattern.MatchString(s.Name) {
+		return fmt.Errorf("invalid schedule name %q: expected lowercase letters, digits, and dashes", s.Name)
+	}
+	if len(s.Args) == 0 {
+		return fmt.Errorf("schedule %s requires a subcommand", s.Name)
+	}
+	if s.Interval == 0 && s.Calendar == nil {
+		return fmt.Errorf("schedule %s requires an interval or a calendar time", s.Name)
+	}
+	if s.Interval != 0 && s.Interval < minInterval {
+		return fmt.Errorf("schedule %s runs too often, the interval must be at least %s", s.Name, minInterval)
+	}
+	if s.Interval%time.Second != 0 {
+		return fmt.Errorf("schedule %s interval must be a whole number of seconds", s.Name)
+	}
+
+	return nil
+}
+
+// Job creates the launchd job which runs the schedule's subcommand with the utility at the path.
+func (s *Schedule) Job(executable string) (*launchd.Job, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	job := &launchd.Job{
+		Label:             Label(s.Name),
+		ProgramArguments:  append([]string{executable}, s.Args...),
+		StandardOutPath:   LogPath(s.Name),
+		StandardErrorPath: LogPath(s.Name),
+		ProcessType:       "Background",
+		EnvironmentVariables: map[string]string{
+			NameEnv: s.Name,
+		},
+	}
+	if s.Interval != 0 {
+		job.StartInterval = int(s.Interval / time.Second)
+	} else {
+		job.StartCalendarInterval = []launchd.CalendarInterval{*s.Calendar}
+	}
+
+	return job, nil
+}
+
+// FromJob gets the schedule that the job was created for.
+func FromJob(job *launchd.Job) (*Schedule, error) {
+	name, ok := NameFromLabel(job.Label)
+	if !ok || len(job.ProgramArguments) == 0 {
+		return nil, fmt.Errorf("job %s isn't a schedule", job.Label)
+	}
+
+	s := &Schedule{
+		Name:     name,
+		Args:     job.ProgramArguments[1:],
+		Interval: time.Duration(job.StartInterval) * time.Second,
+	}
+	if len(job.StartCalendarInterval) != 0 {
+		s.Calendar = &job.StartCalendarInterval[0]
+	}
+
+	return s, nil
+}
+
+// Read reads the schedule with the name from the manager's job definitions. ErrNotScheduled is returned when it
+// isn't installed.
+func Read(m *launchd.Manager, name string) (*Schedule, error) {
+	data, err := os.ReadFile(m.Path(Label(name)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotScheduled, name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	job, err := launchd.DecodeJob(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return FromJob(job)
+}
+
+// List reads the schedules installed in the manager's directory, ordered by name.
+func List(m *launchd.Manager) ([]*Schedule, error) {
+	labels, err := m.Installed()
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := []*Schedule{}
+	for _, label := range labels {
+		name, ok := NameFromLabel(label)
+		if !ok {
+			continue
+		}
+		s, err := Read(m, name)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+
+	return schedules, nil
+}
+
+// ParseCalendar parses a calendar time: a time of day (e.g. "03:30") optionally preceded by a weekday (e.g.
+// "sun 03:30") or a day of the month (e.g. "1 03:30"). The special values "hourly", "daily", and "weekly" run at the
+// start of every hour, at midnight, and at midnight on Sundays.
+func ParseCalendar(s string) (*launchd.CalendarInterval, error) {
+	zero := 0
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "hourly":
+		return &launchd.CalendarInterval{Minute: &zero}, nil
+	case "daily":
+		return &launchd.CalendarInterval{Minute: &zero, Hour: &zero}, nil
+	case "weekly":
+		return &launchd.CalendarInterval{Minute: &zero, Hour: &zero, Weekday: &zero}, nil
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("invalid calendar time %q: expected [weekday|day] HH:MM", s)
+	}
+
+	c := &launchd.CalendarInterval{}
+	hour, minute, ok := strings.Cut(fields[len(fields)-1], ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid calendar time %q: expected [weekday|day] HH:MM", s)
+	}
+	var err error
+	if c.Hour, err = parseField(hour, 0, 23); err != nil {
+		return nil, fmt.Errorf("invalid hour in %q: %w", s, err)
+	}
+	if c.Minute, err = parseField(minute, 0, 59); err != nil {
+		return nil, fmt.Errorf("invalid minute in %q: %w", s, err)
+	}
+
+	if len(fields) == 2 {
+		if weekday, ok := weekdays[strings.ToLower(fields[0])]; ok {
+			c.Weekday = &weekday
+		} else if c.Day, err = parseField(fields[0], 1, 31); err != nil {
+			return nil, fmt.Errorf("invalid weekday or day in %q: %w", s, err)
+		}
+	}
+
+	return c, nil
+}
+
+// weekdays are the weekday names accepted by ParseCalendar, numbered like launchd.plist(5) with Sunday as 0.
+var weekdays = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// parseField parses a calendar field, which must be between min and max.
+func parseField(s string, min, max int) (*int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > max {
+		return nil, fmt.Errorf("expected a number from %d to %d, got %q", min, max, s)
+	}
+
+	return &n, nil
+}
+
+// FormatCalendar describes the calendar interval in the format accepted by ParseCalendar, using "*" for fields that
+// match any value.
+func FormatCalendar(c *launchd.CalendarInterval) string {
+	field := func(n *int) string {
+		if n == nil {
+			return "*"
+		}
+		return fmt.Sprintf("%02d", *n)
+	}
+
+	at := field(c.Hour) + ":" + field(c.Minute)
+	switch {
+	case c.Weekday != nil:
+		for name, n := range weekdays {
+			if n == *c.Weekday%7 {
+				return name + " " + at
+			}
+		}
+	case c.Day != nil:
+		return strconv.Itoa(*c.Day) + " " + at
+	}
+
+	return at
+}
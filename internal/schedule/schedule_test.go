@@ -0,0 +1,107 @@
+package schedule
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+)
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestParseCalendar(t *testing.T) {
+	tests := []struct {
+		in   string
+		want *launchd.CalendarInterval
+	}{
+		{in: "03:30", want: &launchd.CalendarInterval{Hour: intPtr(3), Minute: intPtr(30)}},
+		{in: "sun 03:30", want: &launchd.CalendarInterval{Hour: intPtr(3), Minute: intPtr(30), Weekday: intPtr(0)}},
+		{in: "1 00:15", want: &launchd.CalendarInterval{Hour: intPtr(0), Minute: intPtr(15), Day: intPtr(1)}},
+		{in: "hourly", want: &launchd.CalendarInterval{Minute: intPtr(0)}},
+		{in: "Daily", want: &launchd.CalendarInterval{Hour: intPtr(0), Minute: intPtr(0)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			c, err := ParseCalendar(tt.in)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, c)
+		})
+	}
+}
+
+func TestParseCalendar_Invalid(t *testing.T) {
+	for _, in := range []string{"", "3pm", "24:00", "12:60", "someday 03:00", "32 03:00", "mon tue 03:00"} {
+		_, err := ParseCalendar(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestFormatCalendar(t *testing.T) {
+	for _, in := range []string{"03:30", "sun 03:30", "1 00:15"} {
+		c, err := ParseCalendar(in)
+		assert.NoError(t, err)
+		assert.Equal(t, in, FormatCalendar(c))
+	}
+
+	c, err := ParseCalendar("hourly")
+	assert.NoError(t, err)
+	assert.Equal(t, "*:00", FormatCalendar(c))
+}
+
+func TestSchedule_Job(t *testing.T) {
+	s := &Schedule{Name: "metrics", Args: []string{"metrics", "--textfile", "/tmp/metrics.prom"}, Interval: time.Hour}
+
+	job, err := s.Job("/usr/local/bin/ec2-macos-utils")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "com.amazon.ec2.macos-utils.schedule.metrics", job.Label)
+	assert.Equal(t, []string{"/usr/local/bin/ec2-macos-utils", "metrics", "--textfile", "/tmp/metrics.prom"}, job.ProgramArguments)
+	assert.Equal(t, 3600, job.StartInterval)
+	assert.Equal(t, "/var/log/ec2-macos-utils/schedule.metrics.log", job.StandardOutPath)
+	assert.Equal(t, map[string]string{NameEnv: "metrics"}, job.EnvironmentVariables, "runs should know they're scheduled")
+
+	decoded, err := FromJob(job)
+	assert.NoError(t, err)
+	assert.Equal(t, s, decoded)
+}
+
+func TestSchedule_Validate(t *testing.T) {
+	calendar := &launchd.CalendarInterval{Minute: intPtr(0)}
+
+	assert.NoError(t, (&Schedule{Name: "nightly-thin", Args: []string{"volume"}, Calendar: calendar}).Validate())
+	assert.Error(t, (&Schedule{Name: "Nightly Thin", Args: []string{"volume"}, Calendar: calendar}).Validate(), "names are used in labels and paths")
+	assert.Error(t, (&Schedule{Name: "thin", Calendar: calendar}).Validate(), "a subcommand is required")
+	assert.Error(t, (&Schedule{Name: "thin", Args: []string{"volume"}}).Validate(), "a schedule is required")
+	assert.Error(t, (&Schedule{Name: "thin", Args: []string{"volume"}, Interval: time.Second}).Validate(), "short intervals should be rejected")
+}
+
+func TestList(t *testing.T) {
+	m := &launchd.Manager{Dir: t.TempDir(), Domain: launchd.SystemDomain}
+	s := &Schedule{Name: "metrics", Args: []string{"metrics"}, Interval: time.Hour}
+	job, err := s.Job("/usr/local/bin/ec2-macos-utils")
+	assert.NoError(t, err)
+	data, err := job.Bytes()
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(m.Path(job.Label), data, 0644))
+	other := &launchd.Job{Label: launchd.Label("network"), ProgramArguments: []string{"/usr/local/bin/ec2-macos-utils", "network"}}
+	data, err = other.Bytes()
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(m.Dir, other.Label+".plist"), data, 0644))
+
+	schedules, err := List(m)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*Schedule{s}, schedules, "only schedules should be listed")
+
+	_, err = Read(m, "missing")
+	assert.True(t, errors.Is(err, ErrNotScheduled))
+}
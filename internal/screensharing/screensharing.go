@@ -0,0 +1,189 @@
+// Package screensharing provides the functionality necessary for enabling macOS's Screen Sharing (VNC) service and
+// configuring access to it with the Apple Remote Desktop kickstart tool.
+package screensharing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// KickstartPath is the path to the Apple Remote Desktop kickstart tool.
+	KickstartPath = "/System/Library/CoreServices/RemoteManagement/ARDAgent.app/Contents/Resources/kickstart"
+
+	// ServiceLabel is the label of the Screen Sharing service.
+	ServiceLabel = "com.apple.screensharing"
+	// ServicePath is the path to the Screen Sharing service's job definition.
+	ServicePath = "/System/Library/LaunchDaemons/com.apple.screensharing.plist"
+	// VNCSettingsPath is the path to the obfuscated password of legacy VNC clients.
+	VNCSettingsPath = "/Library/Preferences/com.apple.VNCSettings.txt"
+
+	// MaxVNCPasswordLength is the longest password supported by legacy VNC clients. Longer passwords are silently
+	// truncated by the VNC authentication scheme.
+	MaxVNCPasswordLength = 8
+)
+
+// vncPasswordKey is the fixed key the Screen Sharing agent uses to obfuscate the legacy VNC password.
+var vncPasswordKey = []byte{0x17, 0x34, 0x51, 0x6e, 0x8b, 0xa8, 0xc5, 0xe2, 0xff, 0x1c, 0x39, 0x56, 0x73, 0x90, 0xad, 0xca}
+
+// Status describes the state of Screen Sharing.
+type Status struct {
+	// Loaded indicates that the Screen Sharing service is loaded and accepting connections.
+	Loaded bool `json:"loaded"`
+	// Running indicates that the Screen Sharing service has a running process, which only happens while a
+	// client is connected.
+	Running bool `json:"running"`
+}
+
+// GetStatus fetches the state of the Screen Sharing service.
+func GetStatus(ctx context.Context, m *launchd.Manager) (*Status, error) {
+	s, err := m.Status(ctx, ServiceLabel)
+	if errors.Is(err, launchd.ErrNotLoaded) {
+		return &Status{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &Status{Loaded: true, Running: s.Running()}, nil
+}
+
+// Enable enables the Screen Sharing service and grants the users full access to it. The service is left running
+// when it's already loaded.
+func Enable(ctx context.Context, m *launchd.Manager, users []string) error {
+	if len(users) == 0 {
+		return errors.New("screensharing: at least one user is required")
+	}
+
+	// cmdAllow represents the command used for executing kickstart to limit access to specific users.
+	//   * -configure - change the configuration of the agent
+	//   * -allowAccessFor -specifiedUsers - only allow the users given access to connect
+	cmdAllow := []string{KickstartPath, "-configure", "-allowAccessFor", "-specifiedUsers"}
+	if err := kickstart(ctx, cmdAllow); err != nil {
+		return err
+	}
+
+	// cmdAccess represents the command used for executing kickstart to grant the users access.
+	//   * -configure - change the configuration of the agent
+	//   * -access -on - grant access to the following users
+	//   * -users <users> - the comma separated short names of the users
+	//   * -privs -all - grant all privileges (e.g. control and observe)
+	cmdAccess := []string{KickstartPath, "-configure", "-access", "-on", "-users", strings.Join(users, ","), "-privs", "-all"}
+	if err := kickstart(ctx, cmdAccess); err != nil {
+		return err
+	}
+
+	// cmdActivate represents the command used for executing kickstart to start the agent.
+	//   * -activate - turn on the agent
+	//   * -restart -agent - restart the agent so that the configuration is applied
+	cmdActivate := []string{KickstartPath, "-activate", "-restart", "-agent"}
+	if err := kickstart(ctx, cmdActivate); err != nil {
+		return err
+	}
+
+	if err := m.Enable(ctx, ServiceLabel); err != nil {
+		return fmt.Errorf("screensharing: failed to enable service: %w", err)
+	}
+	status, err := GetStatus(ctx, m)
+	if err != nil {
+		return err
+	}
+	if status.Loaded {
+		logrus.WithField("label", ServiceLabel).Debug("Screen Sharing service already loaded")
+		return nil
+	}
+	if err := m.Bootstrap(ctx, ServicePath); err != nil {
+		return fmt.Errorf("screensharing: failed to load service: %w", err)
+	}
+
+	return nil
+}
+
+// SetVNCPassword enables access for legacy VNC clients, which authenticate with a password rather than a user
+// account, and sets their password. The password is written to VNCSettingsPath rather than given to kickstart so that
+// it doesn't appear in the command's arguments.
+func SetVNCPassword(ctx context.Context, password string) error {
+	if err := ValidateVNCPassword(password); err != nil {
+		return err
+	}
+
+	if err := util.WriteFileAtomic(VNCSettingsPath, EncodeVNCPassword(password), 0o600); err != nil {
+		return fmt.Errorf("screensharing: failed to write %s: %w", VNCSettingsPath, err)
+	}
+
+	// cmdLegacy represents the command used for executing kickstart to allow legacy VNC clients.
+	//   * -configure -clientopts - change the client options of the agent
+	//   * -setvnclegacy -vnclegacy yes - allow legacy VNC clients to connect with the password in VNCSettingsPath
+	cmdLegacy := []string{KickstartPath, "-configure", "-clientopts", "-setvnclegacy", "-vnclegacy", "yes"}
+
+	return kickstart(ctx, cmdLegacy)
+}
+
+// EncodeVNCPassword obfuscates the password in the format of VNCSettingsPath. The password is padded to 16 bytes,
+// XORed with vncPasswordKey, and hex encoded. Note that this is obfuscation and not encryption: the file must only be
+// readable by root.
+func EncodeVNCPassword(password string) []byte {
+	data := make([]byte, len(vncPasswordKey))
+	copy(data, password)
+	for i := range data {
+		data[i] ^= vncPasswordKey[i]
+	}
+
+	return []byte(strings.ToUpper(hex.EncodeToString(data)) + "\n")
+}
+
+// ValidateVNCPassword checks that the password can be used by legacy VNC clients.
+func ValidateVNCPassword(password string) error {
+	switch {
+	case password == "":
+		return errors.New("screensharing: VNC password is empty")
+	case len(password) > MaxVNCPasswordLength:
+		return fmt.Errorf("screensharing: VNC password is longer than %d characters", MaxVNCPasswordLength)
+	default:
+		return nil
+	}
+}
+
+// Disable stops the Screen Sharing service, prevents it from being loaded again, and revokes all access, including
+// the legacy VNC password.
+func Disable(ctx context.Context, m *launchd.Manager) error {
+	// cmdDeactivate represents the command used for executing kickstart to stop the agent.
+	//   * -deactivate - turn off the agent
+	//   * -configure -access -off - revoke access for all users
+	//   * -clientopts -setvnclegacy -vnclegacy no - disallow legacy VNC clients
+	cmdDeactivate := []string{KickstartPath, "-deactivate", "-configure", "-access", "-off",
+		"-clientopts", "-setvnclegacy", "-vnclegacy", "no"}
+	if err := kickstart(ctx, cmdDeactivate); err != nil {
+		return err
+	}
+	if err := os.Remove(VNCSettingsPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("screensharing: failed to remove %s: %w", VNCSettingsPath, err)
+	}
+
+	if err := m.Disable(ctx, ServiceLabel); err != nil {
+		return fmt.Errorf("screensharing: failed to disable service: %w", err)
+	}
+	if err := m.Bootout(ctx, ServiceLabel); err != nil && !errors.Is(err, launchd.ErrNotLoaded) {
+		return fmt.Errorf("screensharing: failed to unload service: %w", err)
+	}
+
+	return nil
+}
+
+// kickstart executes the kickstart command.
+func kickstart(ctx context.Context, c []string) error {
+	out, err := util.ExecuteCommand(ctx, c, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("screensharing: failed to run kickstart %s, stderr: [%s]: %w", strings.Join(c[1:3], " "), strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package screensharing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/launchd"
+)
+
+func TestValidateVNCPassword(t *testing.T) {
+	assert.NoError(t, ValidateVNCPassword("hunter2"))
+	assert.NoError(t, ValidateVNCPassword("12345678"))
+	assert.Error(t, ValidateVNCPassword(""), "empty passwords should be rejected")
+	assert.Error(t, ValidateVNCPassword("123456789"), "passwords would be truncated")
+}
+
+func TestEncodeVNCPassword(t *testing.T) {
+	assert.Equal(t, "6755221DFCC7B786FF1C39567390ADCA\n", string(EncodeVNCPassword("password")))
+	assert.Equal(t, "1734516E8BA8C5E2FF1C39567390ADCA\n", string(EncodeVNCPassword("")), "passwords should be padded to 16 bytes")
+}
+
+func TestEnable_WithoutUsers(t *testing.T) {
+	err := Enable(context.Background(), launchd.NewDaemonManager(), nil)
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,169 @@
+// Package secpolicy provides the functionality necessary for inspecting the boot security policy of the system,
+// including System Integrity Protection (SIP), the sealed system volume, and the security mode of Apple silicon.
+// Several disk operations fail differently depending on these settings so they're reported in typed form.
+package secpolicy
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// State is the state of a security feature.
+type State string
+
+const (
+	// StateEnabled means the feature is on.
+	StateEnabled State = "enabled"
+	// StateDisabled means the feature is off.
+	StateDisabled State = "disabled"
+	// StateCustom means parts of the feature are off, which csrutil reports as a custom configuration.
+	StateCustom State = "custom"
+	// StateUnknown means the feature's state couldn't be determined (e.g. it's unsupported on the release).
+	StateUnknown State = "unknown"
+)
+
+// SecurityMode is the security mode of the local boot policy on Apple silicon.
+type SecurityMode string
+
+const (
+	// SecurityFull only boots the latest signed releases of macOS, which is the default.
+	SecurityFull SecurityMode = "full"
+	// SecurityReduced allows booting any signed release of macOS and enables options like third party kernel
+	// extensions.
+	SecurityReduced SecurityMode = "reduced"
+	// SecurityPermissive allows disabling SIP and sealed system volume checks entirely.
+	SecurityPermissive SecurityMode = "permissive"
+)
+
+// Policy is the boot security policy of the system.
+type Policy struct {
+	// SIP is the state of System Integrity Protection.
+	SIP State `json:"sip"`
+	// AuthenticatedRoot is the state of the signed system volume's authentication, which is only checked on macOS
+	// Big Sur and later.
+	AuthenticatedRoot State `json:"authenticated_root"`
+	// SecurityMode is the security mode of the boot policy, which is only reported on Apple silicon.
+	SecurityMode SecurityMode `json:"security_mode,omitempty"`
+	// ThirdPartyKexts is the state of loading third party kernel extensions, which is only reported on Apple
+	// silicon.
+	ThirdPartyKexts State `json:"third_party_kexts,omitempty"`
+}
+
+// IsDefault checks if the policy is the default policy, with every protection enabled.
+func (p *Policy) IsDefault() bool {
+	return p.SIP == StateEnabled &&
+		(p.AuthenticatedRoot == StateEnabled || p.AuthenticatedRoot == StateUnknown) &&
+		(p.SecurityMode == "" || p.SecurityMode == SecurityFull)
+}
+
+// bputilPattern matches the properties of the local policy displayed by bputil (e.g.
+// "SIP Status                              (sip0): Enabled").
+var bputilPattern = regexp.MustCompile(`^(.*?)\s*\((\w+)\)\s*:\s*(.*)$`)
+
+// Get fetches the policy of the system. The boot policy of Apple silicon is only read when appleSilicon is set since
+// bputil doesn't exist on Intel.
+func Get(ctx context.Context, appleSilicon bool) (*Policy, error) {
+	p := &Policy{}
+
+	// cmdSIP represents the command used for executing macOS's csrutil to get the state of SIP.
+	//   * status - print the state of System Integrity Protection
+	cmdSIP := []string{"csrutil", "status"}
+	out, err := util.ExecuteCommand(ctx, cmdSIP, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("secpolicy: failed to get SIP status, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+	p.SIP = parseCSRStatus(out.Stdout)
+
+	// cmdRoot represents the command used for executing macOS's csrutil to get the state of authenticated root.
+	//   * authenticated-root status - print the state of the system volume's authentication
+	cmdRoot := []string{"csrutil", "authenticated-root", "status"}
+	out, err = util.ExecuteCommand(ctx, cmdRoot, "", nil, nil)
+	if err != nil {
+		// Releases before Big Sur don't have a sealed system volume to authenticate.
+		p.AuthenticatedRoot = StateUnknown
+	} else {
+		p.AuthenticatedRoot = parseCSRStatus(out.Stdout)
+	}
+
+	if !appleSilicon {
+		return p, nil
+	}
+
+	// cmdPolicy represents the command used for executing macOS's bputil to display the local boot policy.
+	//   * -d - display the current local policy
+	cmdPolicy := []string{"bputil", "-d"}
+	out, err = util.ExecuteCommand(ctx, cmdPolicy, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("secpolicy: failed to get boot policy, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+	props := parseBootPolicy(out.Stdout)
+	p.SecurityMode = securityMode(props)
+	p.ThirdPartyKexts = bputilState(props["smb2"])
+
+	return p, nil
+}
+
+// parseCSRStatus parses the state from csrutil's output, for example:
+//
+//	System Integrity Protection status: enabled.
+//	System Integrity Protection status: unknown (Custom Configuration).
+func parseCSRStatus(out string) State {
+	idx := strings.LastIndex(out, "status:")
+	if idx == -1 {
+		return StateUnknown
+	}
+	status := strings.ToLower(strings.TrimSpace(out[idx+len("status:"):]))
+
+	switch {
+	case strings.Contains(status, "custom configuration"):
+		return StateCustom
+	case strings.HasPrefix(status, "enabled"):
+		return StateEnabled
+	case strings.HasPrefix(status, "disabled"):
+		return StateDisabled
+	default:
+		return StateUnknown
+	}
+}
+
+// parseBootPolicy parses the properties of the local policy displayed by bputil, keyed by their four character
+// code (e.g. "sip0").
+func parseBootPolicy(out string) map[string]string {
+	props := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if m := bputilPattern.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			props[m[2]] = strings.TrimSpace(m[3])
+		}
+	}
+
+	return props
+}
+
+// securityMode gets the security mode from the boot policy. Reduced security is recorded with smb0 and permissive
+// security with smb1 while full security leaves both absent.
+func securityMode(props map[string]string) SecurityMode {
+	switch {
+	case bputilState(props["smb1"]) == StateEnabled:
+		return SecurityPermissive
+	case bputilState(props["smb0"]) == StateEnabled:
+		return SecurityReduced
+	default:
+		return SecurityFull
+	}
+}
+
+// bputilState gets the state of a boot policy property. Absent properties are disabled.
+func bputilState(value string) State {
+	switch strings.ToLower(value) {
+	case "enabled":
+		return StateEnabled
+	case "disabled", "absent", "":
+		return StateDisabled
+	default:
+		return StateUnknown
+	}
+}
@@ -0,0 +1,56 @@
+package secpolicy
+
+import (
+	_ "embed"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bputilOutput contains the local policy displayed by bputil on an Apple silicon Mac with reduced security.
+//
+//go:embed testdata/bputil.txt
+var bputilOutput string
+
+func TestParseCSRStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  State
+	}{
+		{name: "enabled", input: "System Integrity Protection status: enabled.\n", want: StateEnabled},
+		{name: "disabled", input: "System Integrity Protection status: disabled.\n", want: StateDisabled},
+		{name: "custom", input: "System Integrity Protection status: unknown (Custom Configuration).\n\nConfiguration:\n\tApple Internal: disabled\n", want: StateCustom},
+		{name: "authenticated root", input: "Authenticated Root status: enabled\n", want: StateEnabled},
+		{name: "unexpected", input: "csrutil: failed to read\n", want: StateUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseCSRStatus(tt.input))
+		})
+	}
+}
+
+func TestParseBootPolicy(t *testing.T) {
+	props := parseBootPolicy(bputilOutput)
+
+	assert.Equal(t, "Enabled", props["sip0"])
+	assert.Equal(t, "absent", props["smb1"])
+	assert.Equal(t, "absent", props["kcph"])
+	assert.Equal(t, SecurityReduced, securityMode(props))
+	assert.Equal(t, StateEnabled, bputilState(props["smb2"]))
+}
+
+func TestSecurityMode(t *testing.T) {
+	assert.Equal(t, SecurityFull, securityMode(map[string]string{"smb0": "absent"}))
+	assert.Equal(t, SecurityReduced, securityMode(map[string]string{"smb0": "Enabled"}))
+	assert.Equal(t, SecurityPermissive, securityMode(map[string]string{"smb0": "Enabled", "smb1": "Enabled"}))
+}
+
+func TestPolicy_IsDefault(t *testing.T) {
+	assert.True(t, (&Policy{SIP: StateEnabled, AuthenticatedRoot: StateEnabled}).IsDefault())
+	assert.True(t, (&Policy{SIP: StateEnabled, AuthenticatedRoot: StateUnknown}).IsDefault(), "releases without authenticated root are default")
+	assert.True(t, (&Policy{SIP: StateEnabled, AuthenticatedRoot: StateEnabled, SecurityMode: SecurityFull}).IsDefault())
+	assert.False(t, (&Policy{SIP: StateCustom, AuthenticatedRoot: StateEnabled}).IsDefault())
+	assert.False(t, (&Policy{SIP: StateEnabled, AuthenticatedRoot: StateEnabled, SecurityMode: SecurityReduced}).IsDefault())
+}
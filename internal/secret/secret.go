@@ -0,0 +1,134 @@
+// Package secret provides the functionality necessary for fetching secrets, Secrets Manager secrets or Parameter
+// Store SecureString parameters, with the instance's credentials and writing them to files readable only by their
+// owner.
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// DefaultMode is the mode that secrets are written with unless another is given.
+const DefaultMode os.FileMode = 0600
+
+// Service is the AWS service that a secret is kept in.
+type Service string
+
+const (
+	// ServiceSecretsManager keeps secrets as Secrets Manager secrets.
+	ServiceSecretsManager Service = "secretsmanager"
+	// ServiceParameterStore keeps secrets as Parameter Store SecureString parameters.
+	ServiceParameterStore Service = "ssm"
+)
+
+// ParseService finds the Service matching s, ignoring case.
+func ParseService(s string) (Service, error) {
+	for _, svc := range []Service{ServiceSecretsManager, ServiceParameterStore} {
+		if strings.EqualFold(s, string(svc)) {
+			return svc, nil
+		}
+	}
+
+	return "", fmt.Errorf("secret: unknown service %q, expected %s or %s", s, ServiceSecretsManager, ServiceParameterStore)
+}
+
+// Client calls the AWS APIs that secrets are fetched with (e.g. *aws.Client).
+type Client interface {
+	GetSecret(ctx context.Context, secretID string) (*aws.Secret, error)
+	GetParameter(ctx context.Context, name string) (string, error)
+}
+
+// Ref identifies a secret's value.
+type Ref struct {
+	// Service is the service the secret is kept in.
+	Service Service
+	// ID is the name or ARN of the secret or parameter.
+	ID string
+	// Field is the field of the secret's JSON object value (e.g. {"password": "..."}) to get. The whole value is used
+	// when empty.
+	Field string
+}
+
+// Get fetches the secret's value, or its field. Binary Secrets Manager secrets are returned as-is and have no fields.
+func Get(ctx context.Context, client Client, ref Ref) ([]byte, error) {
+	var value string
+	switch ref.Service {
+	case ServiceSecretsManager:
+		s, err := client.GetSecret(ctx, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		if s.String == nil {
+			if ref.Field != "" {
+				return nil, fmt.Errorf("secret: %s is binary and has no fields", ref.ID)
+			}
+			return s.Binary, nil
+		}
+		value = *s.String
+	case ServiceParameterStore:
+		var err error
+		if value, err = client.GetParameter(ctx, ref.ID); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("secret: unknown service %q", ref.Service)
+	}
+
+	value, err := aws.SecretField(value, ref.Field)
+	if err != nil {
+		return nil, fmt.Errorf("secret: cannot read %s: %w", ref.ID, err)
+	}
+
+	return []byte(value), nil
+}
+
+// GetString fetches the secret's string value, or its field, rejecting empty values.
+func GetString(ctx context.Context, client Client, ref Ref) (string, error) {
+	value, err := Get(ctx, client, ref)
+	if err != nil {
+		return "", err
+	}
+	if len(value) == 0 {
+		return "", fmt.Errorf("secret: %s is empty", ref.ID)
+	}
+
+	return string(value), nil
+}
+
+// File is where a secret is written to.
+type File struct {
+	// Path is the path of the file.
+	Path string
+	// UID is the user ID of the file's owner, -1 leaves it as the user running the utility.
+	UID int
+	// GID is the group ID of the file's group, -1 leaves it as the group of the user running the utility.
+	GID int
+	// Mode is the file's permissions.
+	Mode os.FileMode
+}
+
+// Write atomically replaces the file with the data, with the file's owner and mode. Files are written with a mode
+// no more permissive than 0600 until their owner is set.
+func Write(f File, data []byte) error {
+	if f.Path == "" {
+		return errors.New("secret: path required")
+	}
+
+	if err := util.WriteFileAtomic(f.Path, data, f.Mode&DefaultMode); err != nil {
+		return fmt.Errorf("secret: cannot write %s: %w", f.Path, err)
+	}
+	if err := os.Chown(f.Path, f.UID, f.GID); err != nil {
+		return fmt.Errorf("secret: cannot change owner of %s: %w", f.Path, err)
+	}
+	if err := os.Chmod(f.Path, f.Mode); err != nil {
+		return fmt.Errorf("secret: cannot change mode of %s: %w", f.Path, err)
+	}
+
+	return nil
+}
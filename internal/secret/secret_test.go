@@ -0,0 +1,83 @@
+package secret
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+)
+
+type fakeClient struct {
+	secrets    map[string]*aws.Secret
+	parameters map[string]string
+}
+
+func (c *fakeClient) GetSecret(ctx context.Context, secretID string) (*aws.Secret, error) {
+	return c.secrets[secretID], nil
+}
+
+func (c *fakeClient) GetParameter(ctx context.Context, name string) (string, error) {
+	return c.parameters[name], nil
+}
+
+func TestParseService(t *testing.T) {
+	svc, err := ParseService("SSM")
+
+	assert.NoError(t, err)
+	assert.Equal(t, ServiceParameterStore, svc)
+
+	_, err = ParseService("vault")
+	assert.Error(t, err)
+}
+
+func TestGet(t *testing.T) {
+	password := `{"password": "hunter2"}`
+	client := &fakeClient{
+		secrets: map[string]*aws.Secret{
+			"ci/runner": {String: &password},
+			"ci/cert":   {Binary: []byte{0x30, 0x82}},
+		},
+		parameters: map[string]string{"/ci/vnc": "swordfish"},
+	}
+
+	for name, tc := range map[string]struct {
+		ref      Ref
+		expected string
+	}{
+		"secret field":  {Ref{Service: ServiceSecretsManager, ID: "ci/runner", Field: "password"}, "hunter2"},
+		"whole secret":  {Ref{Service: ServiceSecretsManager, ID: "ci/runner"}, password},
+		"binary secret": {Ref{Service: ServiceSecretsManager, ID: "ci/cert"}, "\x30\x82"},
+		"parameter":     {Ref{Service: ServiceParameterStore, ID: "/ci/vnc"}, "swordfish"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			value, err := Get(context.Background(), client, tc.ref)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, string(value))
+		})
+	}
+
+	_, err := Get(context.Background(), client, Ref{Service: ServiceSecretsManager, ID: "ci/cert", Field: "password"})
+	assert.Error(t, err, "binary secrets have no fields")
+
+	_, err = GetString(context.Background(), &fakeClient{}, Ref{Service: ServiceParameterStore, ID: "/ci/empty"})
+	assert.Error(t, err, "empty secrets should be rejected")
+}
+
+func TestWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+
+	err := Write(File{Path: path, UID: -1, GID: -1, Mode: 0640}, []byte("hunter2"))
+
+	assert.NoError(t, err)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", string(data))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}
@@ -0,0 +1,316 @@
+// Package selfupdate provides the functionality necessary for updating the utility's own binary from signed release
+// artifacts. Releases are described by a manifest, downloaded from HTTPS or S3, that lists a binary for each
+// architecture along with its SHA-256 checksum and a detached Ed25519 signature. Signatures are made over the
+// release's version, the binary's architecture, and its checksum (see SignedPayload) so that none of them can be
+// changed without the release's key, which keeps older releases from being passed off as the latest one.
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/build"
+	"github.com/aws/ec2-macos-utils/internal/fetch"
+)
+
+const (
+	// DefaultManifestURL is the manifest of the latest release published on GitHub.
+	DefaultManifestURL = build.GitHubLink + "/releases/latest/download/release.json"
+
+	// maxManifestSize limits how much of a manifest is read since they only list a few artifacts.
+	maxManifestSize = 1 << 20
+)
+
+var (
+	// ErrSignatureInvalid is returned when a downloaded binary isn't signed by any of the trusted keys.
+	ErrSignatureInvalid = errors.New("selfupdate: signature verification failed")
+
+	// ErrNoArtifact is returned when a release doesn't have a binary for the running architecture.
+	ErrNoArtifact = errors.New("selfupdate: no artifact for architecture")
+
+	// ErrDowngrade is returned when a release is older than the current version.
+	ErrDowngrade = errors.New("selfupdate: release is older than the current version")
+)
+
+// Manifest describes a release.
+type Manifest struct {
+	// Version is the release's version (e.g. "1.2.0").
+	Version string `json:"version"`
+	// Artifacts are the release's binaries keyed by architecture (arm64 or amd64).
+	Artifacts map[string]Artifact `json:"artifacts"`
+}
+
+// Artifact is a release binary.
+type Artifact struct {
+	// URL is the HTTPS URL or S3 URI of the binary.
+	URL string `json:"url"`
+	// SHA256 is the hex-encoded SHA-256 checksum of the binary.
+	SHA256 string `json:"sha256"`
+	// Signature is the base64-encoded Ed25519 signature of the SignedPayload for the release's version, the
+	// artifact's architecture, and its checksum.
+	Signature string `json:"signature"`
+
+	// Arch is the architecture that the artifact is listed under in the manifest.
+	Arch string `json:"-"`
+}
+
+// Updater downloads and verifies releases.
+type Updater struct {
+	// ManifestURL is the HTTPS URL or S3 URI of the release manifest.
+	ManifestURL string
+	// PublicKeys are the Ed25519 keys trusted to sign release binaries. Binaries signed by any of them are accepted.
+	PublicKeys []ed25519.PublicKey
+	// HTTPClient downloads HTTPS URLs. http.DefaultClient is used when it isn't set.
+	HTTPClient *http.Client
+	// S3 downloads S3 URIs. A client for the instance's region is created when it isn't set.
+	S3 fetch.ObjectGetter
+	// Arch is the architecture of the binary to download. The running architecture is used when it isn't set.
+	Arch string
+	// Current is the version of the running utility. Releases older than it are never downloaded, so that a
+	// manifest can't roll the utility back to a vulnerable release. Versions that can't be compared, like those of
+	// development builds, don't restrict releases.
+	Current string
+}
+
+// SignedPayload is the data that release signatures are made over, which binds the binary's checksum to the
+// release's version and the binary's architecture.
+func SignedPayload(version, arch, sha256 string) []byte {
+	return []byte(fmt.Sprintf("ec2-macos-utils release %s %s sha256:%s\n", version, arch, strings.ToLower(sha256)))
+}
+
+// ParsePublicKey parses a base64-encoded Ed25519 public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key %q: expected a base64-encoded Ed25519 key", s)
+	}
+
+	return ed25519.PublicKey(key), nil
+}
+
+// Newer checks if the release is newer than the current version. Versions that can't be compared, like those of
+// development builds, are never considered up to date.
+func (m *Manifest) Newer(current string) (bool, error) {
+	latest, err := semver.NewVersion(m.Version)
+	if err != nil {
+		return false, fmt.Errorf("selfupdate: invalid release version %q: %w", m.Version, err)
+	}
+	v, err := semver.NewVersion(current)
+	if err != nil {
+		logrus.WithField("version", current).Debug("Current version can't be compared, treating the release as newer")
+		return true, nil
+	}
+
+	return latest.GreaterThan(v), nil
+}
+
+// Older checks if the release is older than the current version. Versions that can't be compared, like those of
+// development builds, are never considered newer than a release.
+func (m *Manifest) Older(current string) (bool, error) {
+	release, err := semver.NewVersion(m.Version)
+	if err != nil {
+		return false, fmt.Errorf("selfupdate: invalid release version %q: %w", m.Version, err)
+	}
+	v, err := semver.NewVersion(current)
+	if err != nil {
+		return false, nil
+	}
+
+	return release.LessThan(v), nil
+}
+
+// Latest downloads the release manifest and gets the artifact for the architecture.
+func (u *Updater) Latest(ctx context.Context) (*Manifest, *Artifact, error) {
+	var buf bytes.Buffer
+	if err := u.download(ctx, u.ManifestURL, &buf, maxManifestSize); err != nil {
+		return nil, nil, fmt.Errorf("selfupdate: cannot get manifest: %w", err)
+	}
+
+	m := &Manifest{}
+	if err := json.Unmarshal(buf.Bytes(), m); err != nil {
+		return nil, nil, fmt.Errorf("selfupdate: invalid manifest %s: %w", u.ManifestURL, err)
+	}
+
+	arch := u.Arch
+	if arch == "" {
+		arch = runtime.GOARCH
+	}
+	a, ok := m.Artifacts[arch]
+	if !ok {
+		return m, nil, fmt.Errorf("%w %s in release %s", ErrNoArtifact, arch, m.Version)
+	}
+	a.Arch = arch
+
+	return m, &a, nil
+}
+
+// Download downloads the release's artifact to a temporary executable file in dir and verifies its checksum and its
+// signature over the release's version and the artifact's architecture. Releases older than the current version
+// aren't downloaded. The file is removed when verification fails, otherwise the caller owns it.
+func (u *Updater) Download(ctx context.Context, m *Manifest, a *Artifact, dir string) (string, error) {
+	if len(u.PublicKeys) == 0 {
+		return "", errors.New("selfupdate: no public keys to verify the release with")
+	}
+	if a.Arch == "" {
+		return "", errors.New("selfupdate: artifact doesn't have an architecture")
+	}
+	older, err := m.Older(u.Current)
+	if err != nil {
+		return "", err
+	}
+	if older {
+		return "", fmt.Errorf("%w: release %s, current %s", ErrDowngrade, m.Version, u.Current)
+	}
+	checksum, err := fetch.ParseChecksum(a.SHA256)
+	if err != nil {
+		return "", err
+	}
+	if checksum == nil {
+		return "", errors.New("selfupdate: artifact doesn't have a checksum")
+	}
+	signature, err := base64.StdEncoding.DecodeString(a.Signature)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return "", fmt.Errorf("%w: invalid signature for %s", ErrSignatureInvalid, a.URL)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".ec2-macos-utils.*")
+	if err != nil {
+		return "", fmt.Errorf("selfupdate: cannot create download: %w", err)
+	}
+	path := tmp.Name()
+	ok := false
+	defer func() {
+		tmp.Close()
+		if !ok {
+			os.Remove(path)
+		}
+	}()
+
+	var buf bytes.Buffer
+	if err = u.download(ctx, a.URL, &buf, -1); err != nil {
+		return "", fmt.Errorf("selfupdate: cannot download %s: %w", a.URL, err)
+	}
+	data := buf.Bytes()
+	if sum := sha256.Sum256(data); !bytes.Equal(sum[:], checksum) {
+		return "", fmt.Errorf("%s: %w: expected sha256:%s, got sha256:%s", a.URL, fetch.ErrChecksumMismatch, hex.EncodeToString(checksum), hex.EncodeToString(sum[:]))
+	}
+	if !verifySignature(u.PublicKeys, SignedPayload(m.Version, a.Arch, hex.EncodeToString(checksum)), signature) {
+		return "", fmt.Errorf("%w: %s isn't signed by a trusted key for release %s (%s)", ErrSignatureInvalid, a.URL, m.Version, a.Arch)
+	}
+
+	if _, err = tmp.Write(data); err != nil {
+		return "", fmt.Errorf("selfupdate: cannot write download: %w", err)
+	}
+	if err = tmp.Chmod(0755); err != nil {
+		return "", fmt.Errorf("selfupdate: cannot make download executable: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return "", fmt.Errorf("selfupdate: cannot write download: %w", err)
+	}
+	ok = true
+
+	return path, nil
+}
+
+// verifySignature checks if any of the keys signed the payload.
+func verifySignature(keys []ed25519.PublicKey, payload, signature []byte) bool {
+	for _, key := range keys {
+		if ed25519.Verify(key, payload, signature) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// download writes the content of the HTTPS URL or S3 URI to w, reading at most limit bytes when limit isn't -1.
+func (u *Updater) download(ctx context.Context, uri string, w io.Writer, limit int64) error {
+	var body io.ReadCloser
+	if aws.IsS3URI(uri) {
+		bucket, key, err := aws.ParseS3URI(uri)
+		if err != nil {
+			return err
+		}
+		if u.S3 == nil {
+			client, err := aws.NewClientFromMetadata(ctx)
+			if err != nil {
+				return fmt.Errorf("cannot create S3 client: %w", err)
+			}
+			u.S3 = client
+		}
+		object, err := u.S3.GetObject(ctx, bucket, key)
+		if err != nil {
+			return err
+		}
+		body = object.Body
+	} else {
+		if !strings.HasPrefix(uri, "https://") {
+			return fmt.Errorf("invalid URL %q: expected an https:// URL or s3:// URI", uri)
+		}
+		client := u.HTTPClient
+		if client == nil {
+			client = http.DefaultClient
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		body = resp.Body
+	}
+	defer body.Close()
+
+	var r io.Reader = body
+	if limit != -1 {
+		r = io.LimitReader(body, limit)
+	}
+	_, err := io.Copy(w, r)
+
+	return err
+}
+
+// Replace atomically replaces the executable at exe with the file at path, which must be on the same filesystem.
+func Replace(path, exe string) error {
+	if filepath.Dir(path) != filepath.Dir(exe) {
+		return fmt.Errorf("selfupdate: %s must be in the same directory as %s", path, exe)
+	}
+	if err := os.Rename(path, exe); err != nil {
+		return fmt.Errorf("selfupdate: cannot replace %s: %w", exe, err)
+	}
+
+	return nil
+}
+
+// Executable gets the path to the running executable with symlinks resolved, which is the file to replace.
+func Executable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("selfupdate: cannot find executable: %w", err)
+	}
+
+	return filepath.EvalSymlinks(exe)
+}
@@ -0,0 +1,247 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/fetch"
+)
+
+// signRelease signs the release's arm64 binary with key.
+func signRelease(key ed25519.PrivateKey, version string, binary []byte) string {
+	sum := sha256.Sum256(binary)
+
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, SignedPayload(version, "arm64", hex.EncodeToString(sum[:]))))
+}
+
+// releaseServer serves a manifest for release 1.2.0 with an arm64 binary signed by key.
+func releaseServer(t *testing.T, key ed25519.PrivateKey, binary []byte) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	sum := sha256.Sum256(binary)
+	manifest := Manifest{
+		Version: "1.2.0",
+		Artifacts: map[string]Artifact{
+			"arm64": {
+				URL:       server.URL + "/ec2-macos-utils_arm64",
+				SHA256:    hex.EncodeToString(sum[:]),
+				Signature: signRelease(key, "1.2.0", binary),
+			},
+		},
+	}
+	mux.HandleFunc("/release.json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(manifest)
+	})
+	mux.HandleFunc("/ec2-macos-utils_arm64", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(binary)
+	})
+
+	return server
+}
+
+func TestUpdater(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	binary := []byte("#!/bin/sh\necho 1.2.0\n")
+	server := releaseServer(t, private, binary)
+	u := &Updater{
+		ManifestURL: server.URL + "/release.json",
+		PublicKeys:  []ed25519.PublicKey{public},
+		HTTPClient:  server.Client(),
+		Arch:        "arm64",
+	}
+
+	m, a, err := u.Latest(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.0", m.Version)
+
+	dir := t.TempDir()
+	assert.Equal(t, "arm64", a.Arch)
+	path, err := u.Download(context.Background(), m, a, dir)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, binary, data)
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	exe := filepath.Join(dir, "ec2-macos-utils")
+	assert.NoError(t, os.WriteFile(exe, []byte("old"), 0755))
+	assert.NoError(t, Replace(path, exe))
+	data, err = os.ReadFile(exe)
+	assert.NoError(t, err)
+	assert.Equal(t, binary, data)
+}
+
+func TestUpdater_UntrustedKey(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	trusted, _, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	server := releaseServer(t, private, []byte("binary"))
+	u := &Updater{
+		ManifestURL: server.URL + "/release.json",
+		PublicKeys:  []ed25519.PublicKey{trusted},
+		HTTPClient:  server.Client(),
+		Arch:        "arm64",
+	}
+
+	m, a, err := u.Latest(context.Background())
+	assert.NoError(t, err)
+	dir := t.TempDir()
+	_, err = u.Download(context.Background(), m, a, dir)
+
+	assert.True(t, errors.Is(err, ErrSignatureInvalid), "binaries signed by other keys should be rejected")
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries, "rejected downloads should be removed")
+}
+
+func TestUpdater_ChecksumMismatch(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	server := releaseServer(t, private, []byte("binary"))
+	u := &Updater{PublicKeys: []ed25519.PublicKey{public}, HTTPClient: server.Client()}
+	a := &Artifact{
+		URL:       server.URL + "/ec2-macos-utils_arm64",
+		SHA256:    hex.EncodeToString(make([]byte, sha256.Size)),
+		Signature: signRelease(private, "1.2.0", []byte("binary")),
+		Arch:      "arm64",
+	}
+
+	_, err = u.Download(context.Background(), &Manifest{Version: "1.2.0"}, a, t.TempDir())
+
+	assert.True(t, errors.Is(err, fetch.ErrChecksumMismatch))
+}
+
+func TestUpdater_Tampered(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	server := releaseServer(t, private, []byte("binary"))
+	u := &Updater{
+		ManifestURL: server.URL + "/release.json",
+		PublicKeys:  []ed25519.PublicKey{public},
+		HTTPClient:  server.Client(),
+		Arch:        "arm64",
+	}
+
+	for name, tamper := range map[string]func(m *Manifest, a *Artifact){
+		"version": func(m *Manifest, a *Artifact) { m.Version = "1.3.0" },
+		"arch":    func(m *Manifest, a *Artifact) { a.Arch = "amd64" },
+	} {
+		m, a, err := u.Latest(context.Background())
+		assert.NoError(t, err)
+		tamper(m, a)
+
+		_, err = u.Download(context.Background(), m, a, t.TempDir())
+
+		assert.True(t, errors.Is(err, ErrSignatureInvalid), "releases with a different %s than was signed should be rejected", name)
+	}
+}
+
+func TestUpdater_Rollback(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	server := releaseServer(t, private, []byte("binary"))
+	u := &Updater{
+		ManifestURL: server.URL + "/release.json",
+		PublicKeys:  []ed25519.PublicKey{public},
+		HTTPClient:  server.Client(),
+		Arch:        "arm64",
+		Current:     "1.3.0",
+	}
+
+	m, a, err := u.Latest(context.Background())
+	assert.NoError(t, err)
+	dir := t.TempDir()
+	_, err = u.Download(context.Background(), m, a, dir)
+
+	assert.True(t, errors.Is(err, ErrDowngrade), "signed releases older than the current version should be rejected")
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	u.Current = "1.2.0"
+	path, err := u.Download(context.Background(), m, a, dir)
+	assert.NoError(t, err, "the current release should be reinstallable")
+	assert.FileExists(t, path)
+}
+
+func TestUpdater_NoArtifact(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	server := releaseServer(t, private, []byte("binary"))
+	u := &Updater{ManifestURL: server.URL + "/release.json", HTTPClient: server.Client(), Arch: "amd64"}
+
+	_, _, err = u.Latest(context.Background())
+
+	assert.True(t, errors.Is(err, ErrNoArtifact))
+}
+
+func TestUpdater_HTTP(t *testing.T) {
+	u := &Updater{ManifestURL: "http://example.com/release.json"}
+
+	_, _, err := u.Latest(context.Background())
+
+	assert.Error(t, err, "manifests shouldn't be downloaded without TLS")
+}
+
+func TestManifestNewer(t *testing.T) {
+	m := &Manifest{Version: "1.2.0"}
+
+	for current, want := range map[string]bool{
+		"1.1.0":       true,
+		"1.2.0":       false,
+		"1.3.0":       false,
+		"1.1.0-3-g1a": true,
+		"1a2b3c4":     true,
+		"":            true,
+	} {
+		newer, err := m.Newer(current)
+		assert.NoError(t, err)
+		assert.Equal(t, want, newer, current)
+	}
+}
+
+func TestManifestOlder(t *testing.T) {
+	m := &Manifest{Version: "1.2.0"}
+
+	for current, want := range map[string]bool{
+		"1.1.0":   false,
+		"1.2.0":   false,
+		"1.3.0":   true,
+		"1a2b3c4": false,
+		"":        false,
+	} {
+		older, err := m.Older(current)
+		assert.NoError(t, err)
+		assert.Equal(t, want, older, current)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	public, _, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+
+	key, err := ParsePublicKey(base64.StdEncoding.EncodeToString(public))
+	assert.NoError(t, err)
+	assert.Equal(t, public, key)
+
+	_, err = ParsePublicKey("bm90IGEga2V5")
+	assert.Error(t, err)
+}
@@ -0,0 +1,261 @@
+// Package sessions provides the functionality necessary for finding and terminating the login sessions (GUI and
+// SSH) on the system with macOS's who, ioreg, and launchctl CLIs.
+package sessions
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// consoleLine is the terminal line of GUI sessions, which are signed in at the console.
+	consoleLine = "console"
+	// oldIdle is printed by who for sessions that have been idle for over a day.
+	oldIdle = "old"
+	// oldIdleDuration is the idle time assumed for sessions that who reports as old.
+	oldIdleDuration = 24 * time.Hour
+	// loginTimeLayout is the format of login times printed by who, which don't include the year.
+	loginTimeLayout = "Jan 2 15:04"
+)
+
+// Session is a login session.
+type Session struct {
+	// User is the name of the user signed in to the session.
+	User string `json:"user"`
+	// Line is the session's terminal (e.g. "ttys000"), or "console" for GUI sessions.
+	Line string `json:"line"`
+	// Host is the host that remote sessions (e.g. SSH) connected from.
+	Host string `json:"host,omitempty"`
+	// Login is when the user signed in.
+	Login time.Time `json:"login"`
+	// Idle is how long the session has been idle.
+	Idle time.Duration `json:"idle"`
+	// PID is the ID of the session's login process.
+	PID int `json:"pid"`
+}
+
+// GUI checks if the session is a GUI session at the console.
+func (s Session) GUI() bool {
+	return s.Line == consoleLine
+}
+
+// List finds the login sessions on the system. The idle time of GUI sessions is the time since the last keyboard or
+// mouse input, since who only tracks terminal input.
+func List(ctx context.Context) ([]Session, error) {
+	// cmdWho represents the command used for executing macOS's who to list login sessions.
+	//   * -u - print each session's idle time and login process ID
+	cmdWho := []string{"who", "-u"}
+
+	out, err := util.ExecuteCommand(ctx, cmdWho, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("sessions: failed to list sessions, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+	sessions, err := parseWho(strings.NewReader(out.Stdout), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		if !sessions[i].GUI() {
+			continue
+		}
+		idle, err := HIDIdle(ctx)
+		if err != nil {
+			return nil, err
+		}
+		sessions[i].Idle = idle
+	}
+
+	return sessions, nil
+}
+
+// whoExp matches a session printed by "who -u" (e.g. "ci  ttys001  Oct 14 07:00 02:15  5678 (10.0.0.6)").
+var whoExp = regexp.MustCompile(`^(\S+)\s+(\S+)\s+(\w{3}\s+\d{1,2}\s+\d{2}:\d{2})\s+(\S+)\s+(\d+)(?:\s+\((.*)\))?\s*$`)
+
+// parseWho parses the sessions printed by "who -u". Login times are in the year of now, or the year before when
+// that would put them in the future.
+func parseWho(r io.Reader, now time.Time) ([]Session, error) {
+	var sessions []Session
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		m := whoExp.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("sessions: unexpected who output %q", line)
+		}
+
+		login, err := time.ParseInLocation(loginTimeLayout, strings.Join(strings.Fields(m[3]), " "), now.Location())
+		if err != nil {
+			return nil, fmt.Errorf("sessions: invalid login time %q: %w", m[3], err)
+		}
+		login = login.AddDate(now.Year(), 0, 0)
+		if login.After(now) {
+			login = login.AddDate(-1, 0, 0)
+		}
+		idle, err := parseIdle(m[4])
+		if err != nil {
+			return nil, err
+		}
+		pid, err := strconv.Atoi(m[5])
+		if err != nil {
+			return nil, fmt.Errorf("sessions: invalid PID %q: %w", m[5], err)
+		}
+
+		sessions = append(sessions, Session{User: m[1], Line: m[2], Host: m[6], Login: login, Idle: idle, PID: pid})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// parseIdle parses an idle time printed by who, which is "." for sessions active in the last minute, "old" for
+// sessions idle for over a day, and hours and minutes (e.g. "02:15") otherwise.
+func parseIdle(idle string) (time.Duration, error) {
+	switch idle {
+	case ".":
+		return 0, nil
+	case oldIdle:
+		return oldIdleDuration, nil
+	}
+
+	hours, minutes, ok := strings.Cut(idle, ":")
+	h, herr := strconv.Atoi(hours)
+	m, merr := strconv.Atoi(minutes)
+	if !ok || herr != nil || merr != nil {
+		return 0, fmt.Errorf("sessions: invalid idle time %q", idle)
+	}
+
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// HIDIdle gets the time since the last keyboard or mouse input, which is how long the GUI session has been idle.
+func HIDIdle(ctx context.Context) (time.Duration, error) {
+	// cmdIOReg represents the command used for executing macOS's ioreg to get the HID system's properties.
+	//   * -c IOHIDSystem - print the registry entries of the HID system's class
+	//   * -d 1 - don't print the entries' children
+	cmdIOReg := []string{"ioreg", "-c", "IOHIDSystem", "-d", "1"}
+
+	out, err := util.ExecuteCommand(ctx, cmdIOReg, "", nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("sessions: failed to get HID idle time, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseHIDIdle(out.Stdout)
+}
+
+// hidIdleExp matches the HID system's idle time, in nanoseconds, in ioreg's output.
+var hidIdleExp = regexp.MustCompile(`"HIDIdleTime" = (\d+)`)
+
+// parseHIDIdle parses the HID system's idle time from ioreg's output.
+func parseHIDIdle(out string) (time.Duration, error) {
+	m := hidIdleExp.FindStringSubmatch(out)
+	if m == nil {
+		return 0, fmt.Errorf("sessions: no HIDIdleTime in ioreg output")
+	}
+	ns, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("sessions: invalid HIDIdleTime %q: %w", m[1], err)
+	}
+
+	return time.Duration(ns), nil
+}
+
+// Idle selects the sessions that have been idle for at least the threshold. GUI sessions are only selected when gui
+// is set, and sessions on the excluded terminal lines (e.g. the one running the utility) are never selected.
+func Idle(sessions []Session, threshold time.Duration, gui bool, exclude ...string) []Session {
+	var idle []Session
+	for _, s := range sessions {
+		if s.Idle < threshold || (s.GUI() && !gui) || contains(exclude, s.Line) {
+			continue
+		}
+		idle = append(idle, s)
+	}
+
+	return idle
+}
+
+// contains checks if the list contains the string.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Terminate ends the session. Terminal sessions are hung up, which ends the shell and the programs started from it,
+// and GUI sessions are logged out by booting out the user's GUI domain from launchd.
+func Terminate(ctx context.Context, s Session, uid int) error {
+	if !s.GUI() {
+		if err := hangUp(s.PID); err != nil {
+			return fmt.Errorf("sessions: failed to hang up %s on %s: %w", s.User, s.Line, err)
+		}
+		return nil
+	}
+
+	// cmdBootout represents the command used for executing macOS's launchctl to log out a GUI session.
+	//   * bootout - stop the following domain and the services in it
+	//   * gui/<uid> - the GUI domain of the user
+	cmdBootout := []string{"launchctl", "bootout", "gui/" + strconv.Itoa(uid)}
+
+	out, err := util.ExecuteCommand(ctx, cmdBootout, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("sessions: failed to log out %s, stderr: [%s]: %w", s.User, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// RestartLoginWindow restarts the login window, which returns the console to the login screen (or signs in the
+// automatic login user again) and releases what was left of the GUI sessions.
+func RestartLoginWindow(ctx context.Context) error {
+	// cmdKill represents the command used for executing macOS's killall to restart the login window.
+	//   * -HUP - send the hangup signal, which launchd restarts the login window after
+	//   * loginwindow - the name of the login window's process
+	cmdKill := []string{"killall", "-HUP", "loginwindow"}
+
+	out, err := util.ExecuteCommand(ctx, cmdKill, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("sessions: failed to restart loginwindow, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// CurrentLine gets the terminal line of the session running the utility (e.g. "ttys000"), which is empty when it
+// isn't run from a terminal (e.g. by launchd).
+func CurrentLine(ctx context.Context) (string, error) {
+	// cmdTTY represents the command used for executing macOS's ps to get the utility's terminal.
+	//   * -o tty= - print the terminal without a header
+	//   * -p <pid> - select the utility's process
+	cmdTTY := []string{"ps", "-o", "tty=", "-p", strconv.Itoa(os.Getpid())}
+
+	out, err := util.ExecuteCommand(ctx, cmdTTY, "", nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("sessions: failed to get terminal, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	tty := strings.TrimSpace(out.Stdout)
+	if tty == "??" || tty == "" {
+		return "", nil
+	}
+
+	return "tty" + strings.TrimPrefix(tty, "tty"), nil
+}
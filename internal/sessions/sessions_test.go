@@ -0,0 +1,79 @@
+package sessions
+
+import (
+	_ "embed"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	// whoOutput contains the sessions printed by "who -u" for a GUI session and three SSH sessions.
+	//
+	//go:embed testdata/who.txt
+	whoOutput string
+
+	// ioregOutput contains the HID system's properties printed by ioreg after 90 minutes without input.
+	//
+	//go:embed testdata/ioreg.txt
+	ioregOutput string
+)
+
+func TestParseWho(t *testing.T) {
+	now := time.Date(2023, 10, 14, 10, 0, 0, 0, time.UTC)
+
+	sessions, err := parseWho(strings.NewReader(whoOutput), now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Session{
+		{User: "ec2-user", Line: "console", Login: time.Date(2023, 10, 14, 8, 1, 0, 0, time.UTC), Idle: 24 * time.Hour, PID: 151},
+		{User: "ec2-user", Line: "ttys000", Host: "10.0.0.5", Login: time.Date(2023, 10, 14, 9, 12, 0, 0, time.UTC), PID: 4321},
+		{User: "ci", Line: "ttys001", Host: "10.0.0.6", Login: time.Date(2023, 10, 14, 7, 0, 0, 0, time.UTC), Idle: 2*time.Hour + 15*time.Minute, PID: 5678},
+		{User: "ci", Line: "ttys002", Host: "10.0.0.6", Login: time.Date(2023, 10, 13, 22, 40, 0, 0, time.UTC), Idle: 24 * time.Hour, PID: 6789},
+	}, sessions)
+}
+
+func TestParseWho_PreviousYear(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC)
+
+	sessions, err := parseWho(strings.NewReader("ci       ttys001  Dec 31 23:00 01:30          5678 (10.0.0.6)\n"), now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC), sessions[0].Login)
+}
+
+func TestParseWho_Invalid(t *testing.T) {
+	_, err := parseWho(strings.NewReader("unexpected\n"), time.Now())
+
+	assert.Error(t, err)
+}
+
+func TestParseHIDIdle(t *testing.T) {
+	idle, err := parseHIDIdle(ioregOutput)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Minute, idle)
+}
+
+func TestIdle(t *testing.T) {
+	sessions := []Session{
+		{User: "ec2-user", Line: "console", Idle: 5 * time.Hour},
+		{User: "ec2-user", Line: "ttys000"},
+		{User: "ci", Line: "ttys001", Idle: 2 * time.Hour},
+		{User: "ci", Line: "ttys002", Idle: 24 * time.Hour},
+	}
+
+	lines := func(sessions []Session) []string {
+		var lines []string
+		for _, s := range sessions {
+			lines = append(lines, s.Line)
+		}
+		return lines
+	}
+
+	assert.Equal(t, []string{"ttys001", "ttys002"}, lines(Idle(sessions, time.Hour, false)))
+	assert.Equal(t, []string{"console", "ttys002"}, lines(Idle(sessions, 4*time.Hour, true)))
+	assert.Equal(t, []string{"ttys001"}, lines(Idle(sessions, time.Hour, false, "ttys002")))
+}
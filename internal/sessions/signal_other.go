@@ -0,0 +1,13 @@
+//go:build !unix
+
+package sessions
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// hangUp isn't supported outside of Unix systems.
+func hangUp(pid int) error {
+	return fmt.Errorf("hanging up processes isn't supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,10 @@
+//go:build unix
+
+package sessions
+
+import "syscall"
+
+// hangUp sends SIGHUP to the process.
+func hangUp(pid int) error {
+	return syscall.Kill(pid, syscall.SIGHUP)
+}
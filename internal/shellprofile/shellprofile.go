@@ -0,0 +1,298 @@
+// Package shellprofile provides the functionality necessary for declaratively managing the PATH entries in
+// /etc/paths.d and blocks of lines in users' shell profiles (e.g. ~/.zprofile). Managed blocks are delimited by
+// markers so that they're replaced in place, rather than appended again, each time they're applied, and so that
+// they can be removed without touching the rest of the profile.
+package shellprofile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/internal/users"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// PathsDir is the directory of files listing directories that path_helper adds to the PATH of login shells.
+	PathsDir = "/etc/paths.d"
+
+	// markerOwner identifies the blocks managed by the utility in their markers.
+	markerOwner = "ec2-macos-utils"
+	// fileMode is the mode of the files that are created.
+	fileMode = 0o644
+)
+
+// PathsTask ensures that the file in the paths directory lists exactly the paths, in order. The file is
+// removed when there aren't any paths.
+type PathsTask struct {
+	// Dir is the paths directory (e.g. PathsDir).
+	Dir string
+	// File is the name of the file (e.g. "ec2-macos-utils-devtools").
+	File string
+	// Paths are the directories added to PATH.
+	Paths []string
+}
+
+// NewPathsTask creates a PathsTask for the file with the name in PathsDir.
+func NewPathsTask(name string, paths ...string) *PathsTask {
+	return &PathsTask{Dir: PathsDir, File: name, Paths: paths}
+}
+
+// Name identifies the task.
+func (t *PathsTask) Name() string {
+	return "paths." + t.File
+}
+
+// Check compares the paths in the file with the desired paths.
+func (t *PathsTask) Check(ctx context.Context) ([]task.Change, error) {
+	path := filepath.Join(t.Dir, t.File)
+	current, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return task.Diff(
+		map[string]string{path: strings.Join(nonEmpty(current), ":")},
+		map[string]string{path: strings.Join(t.Paths, ":")},
+	), nil
+}
+
+// Apply writes the paths to the file, or removes it when there aren't any.
+func (t *PathsTask) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+
+	path := filepath.Join(t.Dir, t.File)
+	if len(t.Paths) == 0 {
+		return changes, RemovePaths(t.Dir, t.File)
+	}
+	if err := os.MkdirAll(t.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("shellprofile: cannot create %s: %w", t.Dir, err)
+	}
+	if err := util.WriteFileAtomic(path, []byte(strings.Join(t.Paths, "\n")+"\n"), fileMode); err != nil {
+		return nil, fmt.Errorf("shellprofile: cannot write %s: %w", path, err)
+	}
+
+	return changes, nil
+}
+
+// RemovePaths removes the file with the name from the paths directory. Files that don't exist are ignored.
+func RemovePaths(dir, name string) error {
+	path := filepath.Join(dir, name)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("shellprofile: cannot remove %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// BlockTask ensures that the file has a managed block with the name containing exactly the lines. The block is
+// replaced in place when its lines differ, or added at the end of the file when it's missing.
+type BlockTask struct {
+	// Path is the path to the file (e.g. a user's ~/.zprofile).
+	Path string
+	// Block is the name of the block, which is unique within the file (e.g. "homebrew").
+	Block string
+	// Lines are the contents of the block.
+	Lines []string
+	// Replaces are lines outside of the block that it supersedes (e.g. ones that were appended before the block
+	// was managed), which are removed when the block is applied.
+	Replaces []string
+	// Owner owns the file once it's written, when set, since files in users' home directories are written as root.
+	Owner *users.User
+}
+
+// Name identifies the task.
+func (t *BlockTask) Name() string {
+	return "profile." + t.Block
+}
+
+// Check compares the block in the file with the desired lines.
+func (t *BlockTask) Check(ctx context.Context) ([]task.Change, error) {
+	lines, err := readLines(t.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	before, block, after, found := splitBlock(lines, t.Block)
+	replaced := len(before)+len(after) != len(t.without(before))+len(t.without(after))
+	if found && !replaced && strings.Join(block, "\n") == strings.Join(t.Lines, "\n") {
+		return nil, nil
+	}
+
+	return []task.Change{{
+		Setting: fmt.Sprintf("%s[%s]", t.Path, t.Block),
+		Current: strings.Join(block, "; "),
+		Desired: strings.Join(t.Lines, "; "),
+	}}, nil
+}
+
+// Apply writes the block to the file, creating the file when it doesn't exist.
+func (t *BlockTask) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+
+	lines, err := readLines(t.Path)
+	if err != nil {
+		return nil, err
+	}
+	before, _, after, found := splitBlock(lines, t.Block)
+	before, after = t.without(before), t.without(after)
+
+	managed := append([]string{beginMarker(t.Block)}, t.Lines...)
+	managed = append(managed, endMarker(t.Block))
+	if !found && len(before) > 0 && before[len(before)-1] != "" {
+		// Blocks added at the end of the file are separated from what comes before them.
+		managed = append([]string{""}, managed...)
+	}
+	lines = append(append(before, managed...), after...)
+
+	if err := writeLines(t.Path, lines, t.Owner); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// without removes the lines that the block replaces.
+func (t *BlockTask) without(lines []string) []string {
+	var kept []string
+	for _, line := range lines {
+		replaced := false
+		for _, r := range t.Replaces {
+			if strings.TrimSpace(line) == r {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			kept = append(kept, line)
+		}
+	}
+
+	return kept
+}
+
+// RemoveBlock removes the managed block with the name from the file, returning false when the file doesn't have it.
+// The file's owner and mode are kept.
+func RemoveBlock(path, block string) (bool, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return false, err
+	}
+	before, _, after, found := splitBlock(lines, block)
+	if !found {
+		return false, nil
+	}
+	// The blank line that separated the block from the lines before it is removed with it.
+	if len(before) > 0 && before[len(before)-1] == "" {
+		before = before[:len(before)-1]
+	}
+
+	return true, writeLines(path, append(before, after...), nil)
+}
+
+// HasBlock checks whether the file has the managed block with the name. A missing file doesn't have it.
+func HasBlock(path, block string) (bool, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return false, err
+	}
+	_, _, _, found := splitBlock(lines, block)
+
+	return found, nil
+}
+
+// beginMarker gets the line that starts the block with the name.
+func beginMarker(block string) string {
+	return fmt.Sprintf("# BEGIN %s: %s", markerOwner, block)
+}
+
+// endMarker gets the line that ends the block with the name.
+func endMarker(block string) string {
+	return fmt.Sprintf("# END %s: %s", markerOwner, block)
+}
+
+// splitBlock splits the lines into those before the block with the name, the block's lines (without its markers),
+// and those after it. All of the lines are before the block when it isn't found. Blocks missing their end marker
+// extend to the end of the lines.
+func splitBlock(lines []string, block string) (before, inside, after []string, found bool) {
+	begin, end := beginMarker(block), endMarker(block)
+	start := -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case begin:
+			if start < 0 {
+				start = i
+			}
+		case end:
+			if start >= 0 {
+				return lines[:start:start], lines[start+1 : i], lines[i+1:], true
+			}
+		}
+	}
+	if start >= 0 {
+		return lines[:start:start], lines[start+1:], nil, true
+	}
+
+	return lines, nil, nil, false
+}
+
+// readLines reads the lines of the file. A missing file has no lines.
+func readLines(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("shellprofile: cannot read %s: %w", path, err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
+
+// writeLines replaces the file with the lines, keeping its mode, and gives it to the owner when set.
+func writeLines(path string, lines []string, owner *users.User) error {
+	mode := os.FileMode(fileMode)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	data := strings.Join(lines, "\n") + "\n"
+	if len(lines) == 0 {
+		data = ""
+	}
+	if err := os.WriteFile(path, []byte(data), mode); err != nil {
+		return fmt.Errorf("shellprofile: cannot write %s: %w", path, err)
+	}
+	if owner != nil {
+		if err := os.Chown(path, owner.UID, owner.GID); err != nil {
+			return fmt.Errorf("shellprofile: cannot change owner of %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// nonEmpty removes the blank lines.
+func nonEmpty(lines []string) []string {
+	var kept []string
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			kept = append(kept, line)
+		}
+	}
+
+	return kept
+}
@@ -0,0 +1,106 @@
+package shellprofile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+func TestPathsTask(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "paths.d")
+	path := filepath.Join(dir, "devtools")
+	tk := &PathsTask{Dir: dir, File: "devtools", Paths: []string{"/Applications/Xcode.app/Contents/Developer/usr/bin"}}
+
+	changes, err := tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []task.Change{{Setting: path, Desired: "/Applications/Xcode.app/Contents/Developer/usr/bin"}}, changes)
+
+	_, err = tk.Apply(context.Background())
+	assert.NoError(t, err)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "/Applications/Xcode.app/Contents/Developer/usr/bin\n", string(data))
+
+	changes, err = tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	tk.Paths = nil
+	_, err = tk.Apply(context.Background())
+	assert.NoError(t, err)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "files without paths should be removed")
+}
+
+func TestBlockTask(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".zprofile")
+	shellenv := `eval "$(/opt/homebrew/bin/brew shellenv)"`
+	assert.NoError(t, os.WriteFile(path, []byte("export LANG=en_US.UTF-8\n"+shellenv+"\n"+shellenv+"\n"), 0o600))
+	tk := &BlockTask{Path: path, Block: "homebrew", Lines: []string{shellenv}, Replaces: []string{shellenv}}
+
+	changes, err := tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+
+	for i := 0; i < 2; i++ {
+		_, err = tk.Apply(context.Background())
+		assert.NoError(t, err)
+	}
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "export LANG=en_US.UTF-8\n\n# BEGIN ec2-macos-utils: homebrew\n"+shellenv+"\n# END ec2-macos-utils: homebrew\n", string(data),
+		"the lines the block replaces should be removed and the block should only be added once")
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "the profile's mode should be kept")
+
+	changes, err = tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	tk.Lines = []string{"export HOMEBREW_NO_ANALYTICS=1", shellenv}
+	_, err = tk.Apply(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, append(mustRead(t, path), "alias ll='ls -l'\n"...), 0o600))
+
+	removed, err := RemoveBlock(path, "homebrew")
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	assert.Equal(t, "export LANG=en_US.UTF-8\nalias ll='ls -l'\n", string(mustRead(t, path)), "the lines around the block should be kept")
+
+	removed, err = RemoveBlock(path, "homebrew")
+	assert.NoError(t, err)
+	assert.False(t, removed)
+}
+
+func TestSplitBlock(t *testing.T) {
+	lines := []string{"a", "# BEGIN ec2-macos-utils: x", "b", "# END ec2-macos-utils: x", "c"}
+	before, inside, after, found := splitBlock(lines, "x")
+	assert.True(t, found)
+	assert.Equal(t, []string{"a"}, before)
+	assert.Equal(t, []string{"b"}, inside)
+	assert.Equal(t, []string{"c"}, after)
+
+	_, inside, after, found = splitBlock(lines[:3], "x")
+	assert.True(t, found, "blocks without an end marker should extend to the end")
+	assert.Equal(t, []string{"b"}, inside)
+	assert.Empty(t, after)
+
+	before, _, _, found = splitBlock(lines, "y")
+	assert.False(t, found)
+	assert.Equal(t, lines, before)
+}
+
+// mustRead reads the file at path.
+func mustRead(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	return data
+}
@@ -0,0 +1,81 @@
+package softwareupdate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/internal/defaults"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// ignoreConstraints identifies the releases where "softwareupdate --ignore" can hide updates without MDM. Apple
+// limited ignoring updates to supervised devices starting with macOS 10.15.5.
+var ignoreConstraints = mustInitConstraint(semver.NewConstraint("< 10.15.5"))
+
+// mustInitConstraint ensures that a semver.Constraints can be initialized and used.
+func mustInitConstraint(c *semver.Constraints, err error) *semver.Constraints {
+	if err != nil {
+		panic(fmt.Errorf("must initialize semver constraint: %w", err))
+	}
+	return c
+}
+
+// CanIgnore checks if the product allows updates to be hidden with "softwareupdate --ignore".
+func CanIgnore(p *system.Product) bool {
+	return p != nil && ignoreConstraints.Check(&p.Version)
+}
+
+// DeferMajorUpgrades prevents macOS from automatically downloading and installing updates, which is how major
+// upgrades are otherwise staged without an administrator's involvement. Available major upgrades are also ignored
+// on releases that support it (see CanIgnore). Security responses and system data files continue to be installed
+// automatically.
+func DeferMajorUpgrades(ctx context.Context, p *system.Product, updates []Update) error {
+	prefs := defaults.NewDomain(defaults.SoftwareUpdateDomain)
+	settings := map[string]bool{
+		"AutomaticDownload":                false,
+		"AutomaticallyInstallMacOSUpdates": false,
+		"CriticalUpdateInstall":            true,
+	}
+	for _, key := range []string{"AutomaticDownload", "AutomaticallyInstallMacOSUpdates", "CriticalUpdateInstall"} {
+		if err := prefs.WriteBool(ctx, key, settings[key]); err != nil {
+			return fmt.Errorf("softwareupdate: failed to write preference %s: %w", key, err)
+		}
+	}
+
+	if !CanIgnore(p) {
+		logrus.WithField("product", p).Debug("Ignoring updates requires MDM, only disabling automatic installs")
+		return nil
+	}
+
+	for _, u := range updates {
+		if u.Category != CategoryMajorUpgrade {
+			continue
+		}
+		if err := Ignore(ctx, u.Title); err != nil {
+			return err
+		}
+		logrus.WithField("title", u.Title).Info("Ignored major upgrade")
+	}
+
+	return nil
+}
+
+// Ignore hides the update with the given title from future scans.
+func Ignore(ctx context.Context, title string) error {
+	// cmdIgnore represents the command used for executing macOS's softwareupdate to ignore an update.
+	//   * --ignore - hide the update with the following title
+	//   * title - the title of the update to be ignored (e.g. "macOS Catalina")
+	cmdIgnore := []string{"softwareupdate", "--ignore", title}
+
+	out, err := util.ExecuteCommand(ctx, cmdIgnore, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("softwareupdate: failed to ignore update, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
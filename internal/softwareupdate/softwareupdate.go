@@ -0,0 +1,273 @@
+// Package softwareupdate provides the functionality necessary for interacting with macOS's softwareupdate CLI.
+package softwareupdate
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"howett.net/plist"
+
+	"github.com/aws/ec2-macos-utils/internal/defaults"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// PreferencesPath is the path to the preferences where softwareupdate caches its recommended updates and stores
+// its automatic update settings.
+const PreferencesPath = defaults.SoftwareUpdateDomain + ".plist"
+
+// Category groups updates by their effect on the system.
+type Category string
+
+const (
+	// CategoryMajorUpgrade is an upgrade to a newer major release of macOS (e.g. Ventura to Sonoma).
+	CategoryMajorUpgrade Category = "major-upgrade"
+	// CategoryOSUpdate is a minor update within the current major release of macOS (e.g. 13.5 to 13.6).
+	CategoryOSUpdate Category = "os-update"
+	// CategorySecurity is a standalone security update (e.g. Security Updates and Rapid Security Responses).
+	CategorySecurity Category = "security"
+	// CategoryOther is any other update (e.g. Command Line Tools and Safari).
+	CategoryOther Category = "other"
+)
+
+// Update is an available software update reported by softwareupdate.
+type Update struct {
+	// Label is the identifier used to install the update.
+	Label string `json:"label"`
+	// Title is the human-readable name of the update.
+	Title string `json:"title"`
+	// Version is the version the update installs.
+	Version string `json:"version,omitempty"`
+	// SizeKB is the download size in kilobytes.
+	SizeKB uint64 `json:"size_kb,omitempty"`
+	// Recommended indicates that Apple recommends installing the update.
+	Recommended bool `json:"recommended"`
+	// Restart indicates that installing the update requires a restart.
+	Restart bool `json:"restart"`
+	// Category groups the update by its effect on the system. See Classify.
+	Category Category `json:"category"`
+}
+
+// IsSecurity checks if the update delivers security fixes for the current release without upgrading it, which
+// includes standalone security updates and minor OS updates.
+func (u Update) IsSecurity() bool {
+	return u.Category == CategorySecurity || u.Category == CategoryOSUpdate
+}
+
+// Classify determines the update's Category relative to the product running on the system.
+func Classify(u Update, p *system.Product) Category {
+	title := strings.ToLower(u.Title)
+	switch {
+	case strings.Contains(title, "security"):
+		return CategorySecurity
+	case strings.HasPrefix(title, "macos"):
+		if isMajorUpgrade(u.Version, p) {
+			return CategoryMajorUpgrade
+		}
+		return CategoryOSUpdate
+	default:
+		return CategoryOther
+	}
+}
+
+// isMajorUpgrade checks if the version belongs to a newer major release than the product. Releases prior to Big Sur
+// are identified by their minor version (e.g. 10.15) rather than their major version.
+func isMajorUpgrade(version string, p *system.Product) bool {
+	v, err := semver.NewVersion(version)
+	if err != nil || p == nil {
+		return false
+	}
+	current := p.Version
+	if v.Major() != current.Major() {
+		return v.Major() > current.Major()
+	}
+
+	return v.Major() == 10 && v.Minor() > current.Minor()
+}
+
+// List scans for available updates with softwareupdate and classifies them for the product.
+func List(ctx context.Context, p *system.Product) ([]Update, error) {
+	// cmdList represents the command used for executing macOS's softwareupdate to list available updates.
+	//   * --list - scan for and list available updates
+	cmdList := []string{"softwareupdate", "--list"}
+
+	out, err := util.ExecuteCommand(ctx, cmdList, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("softwareupdate: failed to list updates, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	updates, err := parseList(strings.NewReader(out.Stdout))
+	if err != nil {
+		return nil, err
+	}
+	for i := range updates {
+		updates[i].Category = Classify(updates[i], p)
+	}
+
+	return updates, nil
+}
+
+// Install installs the updates with the given labels. The system is restarted after installation when restart is
+// set and an update requires it.
+func Install(ctx context.Context, labels []string, restart bool) (string, error) {
+	if len(labels) == 0 {
+		return "", fmt.Errorf("no updates to install")
+	}
+
+	// cmdInstall represents the command used for executing macOS's softwareupdate to install updates.
+	//   * --install - install the updates with the following labels
+	//   * labels - the labels of the updates to be installed
+	//   * --restart - restart the system if an update requires it
+	cmdInstall := append([]string{"softwareupdate", "--install"}, labels...)
+	if restart {
+		cmdInstall = append(cmdInstall, "--restart")
+	}
+
+	out, err := util.ExecuteCommand(ctx, cmdInstall, "", nil, nil)
+	if err != nil {
+		return out.Stdout, fmt.Errorf("softwareupdate: failed to install updates, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return out.Stdout, nil
+}
+
+// catalinaLabelExp matches the label line of an update listed by softwareupdate on Catalina and later.
+var catalinaLabelExp = regexp.MustCompile(`^\s*\* Label: (.+)$`)
+
+// mojaveLabelExp matches the label line of an update listed by softwareupdate on Mojave.
+var mojaveLabelExp = regexp.MustCompile(`^\s*\* (.+)$`)
+
+// mojaveDetailExp matches the details line of an update listed by softwareupdate on Mojave
+// (e.g. "macOS 10.14.6 Supplemental Update (10.14.6), 1155809K [recommended] [restart]").
+var mojaveDetailExp = regexp.MustCompile(`^\s*(.*) \((.*)\), (\d+)K(.*)$`)
+
+// catalinaFieldExp matches the comma separated "Key: Value" fields of an update's details on Catalina and later.
+var catalinaFieldExp = regexp.MustCompile(`(\w+): ([^,]*)`)
+
+// parseList parses the human-readable output of "softwareupdate --list" in either the Catalina and later format or
+// the Mojave format.
+func parseList(r io.Reader) ([]Update, error) {
+	var updates []Update
+	var current *Update
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if m := catalinaLabelExp.FindStringSubmatch(line); m != nil {
+			updates = append(updates, Update{Label: strings.TrimSpace(m[1])})
+			current = &updates[len(updates)-1]
+			continue
+		}
+		if m := mojaveLabelExp.FindStringSubmatch(line); m != nil {
+			updates = append(updates, Update{Label: strings.TrimSpace(m[1])})
+			current = &updates[len(updates)-1]
+			continue
+		}
+		if current == nil || strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if strings.Contains(line, "Title: ") {
+			parseCatalinaDetails(current, line)
+		} else if m := mojaveDetailExp.FindStringSubmatch(line); m != nil {
+			current.Title = strings.TrimSpace(m[1])
+			current.Version = strings.TrimSpace(m[2])
+			current.SizeKB, _ = strconv.ParseUint(m[3], 10, 64)
+			current.Recommended = strings.Contains(m[4], "[recommended]")
+			current.Restart = strings.Contains(m[4], "[restart]")
+		}
+		current = nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return updates, nil
+}
+
+// parseCatalinaDetails parses the details line of an update listed on Catalina and later
+// (e.g. "Title: macOS Ventura 13.6.1, Version: 13.6.1, Size: 1234567K, Recommended: YES, Action: restart,").
+func parseCatalinaDetails(u *Update, line string) {
+	for _, m := range catalinaFieldExp.FindAllStringSubmatch(line, -1) {
+		value := strings.TrimSpace(m[2])
+		switch m[1] {
+		case "Title":
+			u.Title = value
+		case "Version":
+			u.Version = value
+		case "Size":
+			size := strings.TrimRight(value, "KiB")
+			u.SizeKB, _ = strconv.ParseUint(size, 10, 64)
+		case "Recommended":
+			u.Recommended = strings.EqualFold(value, "YES")
+		case "Action":
+			u.Restart = strings.EqualFold(value, "restart")
+		}
+	}
+}
+
+// Preferences mirrors the keys of softwareupdate's preferences that are relevant to the utility.
+type Preferences struct {
+	AutomaticCheckEnabled            *bool               `plist:"AutomaticCheckEnabled,omitempty"`
+	AutomaticDownload                *bool               `plist:"AutomaticDownload,omitempty"`
+	AutomaticallyInstallMacOSUpdates *bool               `plist:"AutomaticallyInstallMacOSUpdates,omitempty"`
+	CriticalUpdateInstall            *bool               `plist:"CriticalUpdateInstall,omitempty"`
+	LastRecommendedUpdatesAvailable  int                 `plist:"LastRecommendedUpdatesAvailable"`
+	RecommendedUpdates               []RecommendedUpdate `plist:"RecommendedUpdates"`
+}
+
+// RecommendedUpdate is an update cached by softwareupdate's last background scan.
+type RecommendedUpdate struct {
+	DisplayName    string `plist:"Display Name"`
+	DisplayVersion string `plist:"Display Version"`
+	Identifier     string `plist:"Identifier"`
+	ProductKey     string `plist:"Product Key"`
+}
+
+// ReadPreferences decodes softwareupdate's preferences from the plist at path.
+func ReadPreferences(path string) (*Preferences, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return decodePreferences(f)
+}
+
+// decodePreferences decodes softwareupdate's preferences from the raw plist data in the reader.
+func decodePreferences(reader io.ReadSeeker) (*Preferences, error) {
+	prefs := &Preferences{}
+	if err := plist.NewDecoder(reader).Decode(prefs); err != nil {
+		return nil, fmt.Errorf("error decoding softwareupdate preferences: %w", err)
+	}
+
+	return prefs, nil
+}
+
+// CachedUpdates converts the recommended updates cached by softwareupdate's last background scan into updates
+// classified for the product. Cached updates don't report their size or whether they require a restart, and they're
+// labeled with their product identifier which can't be passed to Install.
+func (p *Preferences) CachedUpdates(product *system.Product) []Update {
+	var updates []Update
+	for _, r := range p.RecommendedUpdates {
+		u := Update{
+			Label:       r.Identifier,
+			Title:       strings.TrimSpace(r.DisplayName + " " + r.DisplayVersion),
+			Version:     r.DisplayVersion,
+			Recommended: true,
+		}
+		u.Category = Classify(u, product)
+		updates = append(updates, u)
+	}
+
+	return updates
+}
@@ -0,0 +1,109 @@
+package softwareupdate
+
+import (
+	_ "embed"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+var (
+	// listCatalina contains softwareupdate output in the format used by Catalina and later.
+	//
+	//go:embed testdata/list_catalina.txt
+	listCatalina string
+
+	// listMojave contains softwareupdate output in the format used by Mojave.
+	//
+	//go:embed testdata/list_mojave.txt
+	listMojave string
+
+	// preferences contains softwareupdate preferences with a cached major upgrade.
+	//
+	//go:embed testdata/preferences.plist
+	preferences string
+)
+
+// testProduct creates a product for the given version string.
+func testProduct(t *testing.T, release system.Release, version string) *system.Product {
+	v, err := semver.NewVersion(version)
+	assert.NoError(t, err)
+
+	return &system.Product{Release: release, Version: *v}
+}
+
+func TestParseList_Catalina(t *testing.T) {
+	updates, err := parseList(strings.NewReader(listCatalina))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Update{
+		{Label: "Command Line Tools for Xcode-15.0", Title: "Command Line Tools for Xcode", Version: "15.0", SizeKB: 711888, Recommended: true},
+		{Label: "macOS Ventura 13.6.1-22G313", Title: "macOS Ventura 13.6.1", Version: "13.6.1", SizeKB: 1258085, Recommended: true, Restart: true},
+		{Label: "macOS Sonoma 14.1-23B74", Title: "macOS Sonoma 14.1", Version: "14.1", SizeKB: 6444968, Recommended: true, Restart: true},
+		{Label: "Background Security Improvement-22G313a", Title: "Background Security Improvement", Version: "13.6.1 (a)", SizeKB: 84110, Recommended: true, Restart: true},
+	}, updates)
+}
+
+func TestParseList_Mojave(t *testing.T) {
+	updates, err := parseList(strings.NewReader(listMojave))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Update{
+		{Label: "Security Update 2020-004-10.14.6", Title: "Security Update 2020-004", Version: "10.14.6", SizeKB: 1488365, Recommended: true, Restart: true},
+		{Label: "Safari13.1.1MojaveAuto-13.1.1", Title: "Safari", Version: "13.1.1", SizeKB: 67426, Recommended: true},
+	}, updates)
+}
+
+func TestParseList_WithoutUpdates(t *testing.T) {
+	updates, err := parseList(strings.NewReader("Software Update Tool\n\nFinding available software\n"))
+
+	assert.NoError(t, err)
+	assert.Empty(t, updates)
+}
+
+func TestClassify(t *testing.T) {
+	ventura := testProduct(t, system.Ventura, "13.5")
+	mojave := testProduct(t, system.Mojave, "10.14.6")
+
+	tests := []struct {
+		name    string
+		update  Update
+		product *system.Product
+		want    Category
+	}{
+		{name: "minor update", update: Update{Title: "macOS Ventura 13.6.1", Version: "13.6.1"}, product: ventura, want: CategoryOSUpdate},
+		{name: "major upgrade", update: Update{Title: "macOS Sonoma 14.1", Version: "14.1"}, product: ventura, want: CategoryMajorUpgrade},
+		{name: "legacy major upgrade", update: Update{Title: "macOS Catalina", Version: "10.15.7"}, product: mojave, want: CategoryMajorUpgrade},
+		{name: "security", update: Update{Title: "Security Update 2020-004", Version: "10.14.6"}, product: mojave, want: CategorySecurity},
+		{name: "other", update: Update{Title: "Safari", Version: "13.1.1"}, product: mojave, want: CategoryOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Classify(tt.update, tt.product))
+		})
+	}
+}
+
+func TestPreferences_CachedUpdates(t *testing.T) {
+	prefs, err := decodePreferences(strings.NewReader(preferences))
+	assert.NoError(t, err)
+
+	assert.NotNil(t, prefs.AutomaticDownload)
+	assert.False(t, *prefs.AutomaticDownload)
+	assert.Nil(t, prefs.AutomaticallyInstallMacOSUpdates, "unset preferences should be nil")
+
+	updates := prefs.CachedUpdates(testProduct(t, system.Ventura, "13.5"))
+	assert.Equal(t, []Update{
+		{Label: "MSU_UPDATE_23B74_patch_14.1_major", Title: "macOS Sonoma 14.1", Version: "14.1", Recommended: true, Category: CategoryMajorUpgrade},
+	}, updates)
+}
+
+func TestCanIgnore(t *testing.T) {
+	assert.True(t, CanIgnore(testProduct(t, system.Mojave, "10.14.6")))
+	assert.False(t, CanIgnore(testProduct(t, system.Catalina, "10.15.7")))
+	assert.False(t, CanIgnore(testProduct(t, system.Ventura, "13.5")))
+}
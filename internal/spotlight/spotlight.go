@@ -0,0 +1,84 @@
+// Package spotlight provides the functionality necessary for controlling Spotlight indexing of volumes with macOS's
+// mdutil CLI.
+package spotlight
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// Enabled checks if Spotlight indexing is enabled for the volume mounted at path.
+func Enabled(ctx context.Context, path string) (bool, error) {
+	// cmdStatus represents the command used for executing macOS's mdutil to get the indexing status of a volume.
+	//   * -s - print the indexing status of the volume
+	cmdStatus := []string{"mdutil", "-s", path}
+
+	out, err := util.ExecuteCommand(ctx, cmdStatus, "", nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("spotlight: failed to get indexing status of %s, stderr: [%s]: %w", path, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseStatus(out.Stdout)
+}
+
+// SetIndexing enables or disables Spotlight indexing for the volume mounted at path. Disabling indexing also stops
+// any indexing that's in progress.
+func SetIndexing(ctx context.Context, path string, enabled bool) error {
+	state := "off"
+	if enabled {
+		state = "on"
+	}
+
+	// cmdIndexing represents the command used for executing macOS's mdutil to change the indexing status of a volume.
+	//   * -i on|off - turn indexing on or off for the volume
+	cmdIndexing := []string{"mdutil", "-i", state, path}
+
+	out, err := util.ExecuteCommand(ctx, cmdIndexing, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("spotlight: failed to turn indexing %s for %s, stderr: [%s]: %w", state, path, strings.TrimSpace(out.Stderr), err)
+	}
+
+	// mdutil reports some failures, such as volumes it can't index, on stdout with a zero exit status.
+	if strings.Contains(out.Stdout, "Error") {
+		return fmt.Errorf("spotlight: failed to turn indexing %s for %s: %s", state, path, strings.TrimSpace(out.Stdout))
+	}
+
+	return nil
+}
+
+// DisableIndexing disables Spotlight indexing for the volume mounted at path unless it's already disabled.
+// DisableIndexing reports whether indexing was changed.
+func DisableIndexing(ctx context.Context, path string) (bool, error) {
+	enabled, err := Enabled(ctx, path)
+	if err != nil {
+		return false, err
+	}
+	if !enabled {
+		return false, nil
+	}
+
+	if err := SetIndexing(ctx, path, false); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// parseStatus parses whether indexing is enabled from mdutil's status output, for example:
+//
+//	/Volumes/Data:
+//		Indexing enabled.
+func parseStatus(out string) (bool, error) {
+	s := strings.ToLower(out)
+	switch {
+	case strings.Contains(s, "indexing enabled"):
+		return true, nil
+	case strings.Contains(s, "disabled"):
+		return false, nil
+	default:
+		return false, fmt.Errorf("spotlight: unexpected status %q", strings.TrimSpace(out))
+	}
+}
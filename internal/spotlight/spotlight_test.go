@@ -0,0 +1,33 @@
+package spotlight
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    bool
+		wantErr bool
+	}{
+		{name: "enabled", input: "/Volumes/Data:\n\tIndexing enabled. \n", want: true},
+		{name: "disabled", input: "/Volumes/Data:\n\tIndexing disabled.\n", want: false},
+		{name: "searching disabled", input: "/Volumes/Data:\n\tIndexing and searching disabled.\n", want: false},
+		{name: "unknown", input: "/Volumes/Data:\n\tError: unknown indexing state.\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStatus(tt.input)
+
+			assert.Equal(t, tt.want, got)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
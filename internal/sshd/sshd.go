@@ -0,0 +1,319 @@
+// Package sshd provides the functionality necessary for managing the OpenSSH server's configuration with a fragment
+// in sshd_config.d, which is validated with "sshd -t" before it's kept.
+//
+// sshd is started by launchd for each connection on macOS, so new connections use the configuration as soon as it's
+// written without reloading the service, and sessions that are already open aren't interrupted.
+package sshd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// ConfigPath is the path to the server's main configuration file.
+	ConfigPath = "/etc/ssh/sshd_config"
+	// FragmentDir is the directory of configuration fragments included by the main configuration file.
+	FragmentDir = "/etc/ssh/sshd_config.d"
+	// FragmentName is the name of the fragment managed by the utility. sshd uses the first value it reads for each
+	// keyword and reads the fragments in lexical order, so the fragment is named to be read before macOS's own
+	// (100-macos.conf).
+	FragmentName = "010-ec2-macos-utils.conf"
+
+	// sshdPath is the path to the OpenSSH server.
+	sshdPath = "/usr/sbin/sshd"
+	// header is the comment at the top of the fragment.
+	header = "# Managed by ec2-macos-utils, changes are overwritten."
+	// fileMode is the mode of the fragment.
+	fileMode = 0o644
+)
+
+// Settings are the server settings that are managed. Unset settings are left to the rest of the configuration.
+type Settings struct {
+	// PasswordAuthentication allows or denies logging in with passwords. Keyboard-interactive authentication, which
+	// prompts for passwords through PAM on macOS, is allowed or denied with it.
+	PasswordAuthentication *bool
+	// PermitRootLogin allows logging in as root: "yes", "no", "prohibit-password", or "forced-commands-only".
+	PermitRootLogin string
+	// AllowUsers are the only users (or user@host patterns) allowed to log in.
+	AllowUsers []string
+	// ClientAliveInterval is the number of seconds after which idle clients are sent a keepalive message.
+	ClientAliveInterval int
+	// ClientAliveCountMax is the number of keepalive messages that can go unanswered before the client is
+	// disconnected.
+	ClientAliveCountMax int
+}
+
+// Options gets the keywords and values of the settings that are set.
+func (s *Settings) Options() map[string]string {
+	options := map[string]string{}
+	if s.PasswordAuthentication != nil {
+		options["PasswordAuthentication"] = yesNo(*s.PasswordAuthentication)
+		options["KbdInteractiveAuthentication"] = yesNo(*s.PasswordAuthentication)
+	}
+	if s.PermitRootLogin != "" {
+		options["PermitRootLogin"] = s.PermitRootLogin
+	}
+	if len(s.AllowUsers) != 0 {
+		options["AllowUsers"] = strings.Join(s.AllowUsers, " ")
+	}
+	if s.ClientAliveInterval != 0 {
+		options["ClientAliveInterval"] = strconv.Itoa(s.ClientAliveInterval)
+	}
+	if s.ClientAliveCountMax != 0 {
+		options["ClientAliveCountMax"] = strconv.Itoa(s.ClientAliveCountMax)
+	}
+
+	return options
+}
+
+// Render formats the settings as the fragment's contents, with the keywords sorted.
+func (s *Settings) Render() string {
+	options := s.Options()
+	keywords := make([]string, 0, len(options))
+	for k := range options {
+		keywords = append(keywords, k)
+	}
+	sort.Strings(keywords)
+
+	var b strings.Builder
+	b.WriteString(header + "\n")
+	for _, k := range keywords {
+		fmt.Fprintf(&b, "%s %s\n", k, options[k])
+	}
+
+	return b.String()
+}
+
+// Validate checks the server's configuration, including its fragments, with "sshd -t".
+func Validate(ctx context.Context) error {
+	// cmdTest represents the command used for executing sshd to test its configuration.
+	//   * -t - check the validity of the configuration and the host keys, then exit
+	cmdTest := []string{sshdPath, "-t"}
+
+	out, err := util.ExecuteCommand(ctx, cmdTest, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("sshd: invalid configuration, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// Task ensures that the managed fragment holds the settings and that the main configuration file includes the
+// fragments. The configuration is validated after it's written and the previous files are restored when it's invalid.
+type Task struct {
+	// Settings are the desired settings.
+	Settings Settings
+	// ConfigPath is the path to the main configuration file (e.g. ConfigPath).
+	ConfigPath string
+	// FragmentDir is the directory of the fragments (e.g. FragmentDir).
+	FragmentDir string
+	// Validate checks the written configuration (e.g. Validate).
+	Validate func(ctx context.Context) error
+}
+
+// NewTask creates a Task for the system's configuration.
+func NewTask(settings Settings) *Task {
+	return &Task{Settings: settings, ConfigPath: ConfigPath, FragmentDir: FragmentDir, Validate: Validate}
+}
+
+// Name identifies the task.
+func (t *Task) Name() string {
+	return "sshd"
+}
+
+// Check compares the settings in the fragment with the desired settings and checks that the fragments are included.
+func (t *Task) Check(ctx context.Context) ([]task.Change, error) {
+	current, err := readOptions(t.fragmentPath())
+	if err != nil {
+		return nil, err
+	}
+	desired := t.Settings.Options()
+	// Settings that are no longer desired are removed from the fragment.
+	for k := range current {
+		if _, ok := desired[k]; !ok {
+			desired[k] = ""
+		}
+	}
+
+	changes := task.Diff(prefixed(current), prefixed(desired))
+
+	included, err := t.included()
+	if err != nil {
+		return nil, err
+	}
+	if !included {
+		changes = append(changes, task.Change{Setting: t.ConfigPath, Desired: t.include()})
+	}
+
+	return changes, nil
+}
+
+// Apply writes the fragment and includes it from the main configuration file, then validates the configuration.
+// When the configuration is invalid, the files are restored to what they were and the validation error is returned.
+func (t *Task) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+
+	fragment := t.fragmentPath()
+	restoreFragment, err := snapshot(fragment)
+	if err != nil {
+		return nil, err
+	}
+	restoreConfig, err := snapshot(t.ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(t.FragmentDir, 0o755); err != nil {
+		return nil, fmt.Errorf("sshd: cannot create %s: %w", t.FragmentDir, err)
+	}
+	if err := util.WriteFileAtomic(fragment, []byte(t.Settings.Render()), fileMode); err != nil {
+		return nil, fmt.Errorf("sshd: cannot write %s: %w", fragment, err)
+	}
+	if err := t.addInclude(); err != nil {
+		return nil, restore(err, restoreFragment)
+	}
+
+	if err := t.Validate(ctx); err != nil {
+		return nil, restore(err, restoreFragment, restoreConfig)
+	}
+
+	return changes, nil
+}
+
+// fragmentPath gets the path to the managed fragment.
+func (t *Task) fragmentPath() string {
+	return filepath.Join(t.FragmentDir, FragmentName)
+}
+
+// include gets the directive that includes the fragments.
+func (t *Task) include() string {
+	return "Include " + filepath.Join(t.FragmentDir, "*")
+}
+
+// included checks whether the main configuration file includes the fragments.
+func (t *Task) included() (bool, error) {
+	data, err := os.ReadFile(t.ConfigPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("sshd: cannot read %s: %w", t.ConfigPath, err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.Join(strings.Fields(line), " ") == t.include() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// addInclude adds the directive that includes the fragments to the top of the main configuration file, unless it's
+// already there, since sshd uses the first value it reads for each keyword.
+func (t *Task) addInclude() error {
+	if included, err := t.included(); err != nil || included {
+		return err
+	}
+
+	data, err := os.ReadFile(t.ConfigPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("sshd: cannot read %s: %w", t.ConfigPath, err)
+	}
+	data = append([]byte(t.include()+"\n\n"), data...)
+	if err := util.WriteFileAtomic(t.ConfigPath, data, fileMode); err != nil {
+		return fmt.Errorf("sshd: cannot write %s: %w", t.ConfigPath, err)
+	}
+
+	return nil
+}
+
+// snapshot saves the contents of the file and returns a function which restores them, or removes the file when it
+// didn't exist.
+func snapshot(path string) (func() error, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return func() error {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("sshd: cannot remove %s: %w", path, err)
+			}
+			return nil
+		}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("sshd: cannot read %s: %w", path, err)
+	}
+
+	return func() error {
+		if err := util.WriteFileAtomic(path, data, fileMode); err != nil {
+			return fmt.Errorf("sshd: cannot restore %s: %w", path, err)
+		}
+		return nil
+	}, nil
+}
+
+// restore runs the restore functions after err and adds any of their errors to it.
+func restore(err error, restoreFns ...func() error) error {
+	for _, fn := range restoreFns {
+		if restoreErr := fn(); restoreErr != nil {
+			err = fmt.Errorf("%w, %v", err, restoreErr)
+		}
+	}
+
+	return err
+}
+
+// readOptions reads the keywords and values in the configuration file. A missing file has no options.
+func readOptions(path string) (map[string]string, error) {
+	options := map[string]string{}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return options, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("sshd: cannot read %s: %w", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		options[fields[0]] = strings.Join(fields[1:], " ")
+	}
+
+	return options, scanner.Err()
+}
+
+// prefixed names the options' settings in changes (e.g. "sshd.PasswordAuthentication").
+func prefixed(options map[string]string) map[string]string {
+	settings := make(map[string]string, len(options))
+	for k, v := range options {
+		settings["sshd."+k] = v
+	}
+
+	return settings
+}
+
+// yesNo formats the boolean as sshd does.
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
@@ -0,0 +1,85 @@
+package sshd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+func testTask(t *testing.T, settings Settings, validate error) *Task {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "sshd_config")
+	assert.NoError(t, os.WriteFile(config, []byte("UsePAM yes\n"), 0o644))
+
+	return &Task{
+		Settings:    settings,
+		ConfigPath:  config,
+		FragmentDir: filepath.Join(dir, "sshd_config.d"),
+		Validate:    func(context.Context) error { return validate },
+	}
+}
+
+func TestSettings_Render(t *testing.T) {
+	disabled := false
+	s := Settings{PasswordAuthentication: &disabled, AllowUsers: []string{"ec2-user", "ci"}, ClientAliveInterval: 60}
+
+	assert.Equal(t, header+"\n"+
+		"AllowUsers ec2-user ci\n"+
+		"ClientAliveInterval 60\n"+
+		"KbdInteractiveAuthentication no\n"+
+		"PasswordAuthentication no\n", s.Render())
+}
+
+func TestTask_Apply(t *testing.T) {
+	disabled := false
+	tk := testTask(t, Settings{PasswordAuthentication: &disabled, PermitRootLogin: "no"}, nil)
+
+	changes, err := tk.Apply(context.Background())
+
+	assert.NoError(t, err)
+	assert.Contains(t, changes, task.Change{Setting: "sshd.PasswordAuthentication", Desired: "no"})
+	assert.Contains(t, changes, task.Change{Setting: tk.ConfigPath, Desired: tk.include()})
+	config, err := os.ReadFile(tk.ConfigPath)
+	assert.NoError(t, err)
+	assert.Equal(t, tk.include()+"\n\nUsePAM yes\n", string(config))
+	fragment, err := os.ReadFile(tk.fragmentPath())
+	assert.NoError(t, err)
+	assert.Equal(t, tk.Settings.Render(), string(fragment))
+
+	changes, err = tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, changes, "applied settings shouldn't drift")
+}
+
+func TestTask_Check_RemovedSettings(t *testing.T) {
+	tk := testTask(t, Settings{ClientAliveInterval: 30}, nil)
+	assert.NoError(t, os.MkdirAll(tk.FragmentDir, 0o755))
+	assert.NoError(t, os.WriteFile(tk.fragmentPath(), []byte("ClientAliveInterval 60\nAllowUsers ci\n"), 0o644))
+
+	changes, err := tk.Check(context.Background())
+
+	assert.NoError(t, err)
+	assert.Contains(t, changes, task.Change{Setting: "sshd.AllowUsers", Current: "ci"})
+	assert.Contains(t, changes, task.Change{Setting: "sshd.ClientAliveInterval", Current: "60", Desired: "30"})
+}
+
+func TestTask_Apply_InvalidRestores(t *testing.T) {
+	disabled := false
+	tk := testTask(t, Settings{PasswordAuthentication: &disabled}, errors.New("bad configuration"))
+
+	_, err := tk.Apply(context.Background())
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "bad configuration")
+	config, err := os.ReadFile(tk.ConfigPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "UsePAM yes\n", string(config), "config should be restored")
+	_, err = os.Stat(tk.fragmentPath())
+	assert.True(t, os.IsNotExist(err), "new fragment should be removed")
+}
@@ -0,0 +1,139 @@
+// Package startupdisk provides the functionality necessary for choosing the volume that the host boots from with
+// macOS's systemsetup and bless CLIs (e.g. to boot a system volume staged on a secondary disk).
+package startupdisk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// coreServices is the folder that systemsetup identifies bootable volumes by, relative to their mount point.
+const coreServices = "/System/Library/CoreServices"
+
+// Volume is a volume that the host can boot from.
+type Volume struct {
+	// Path is the volume's CoreServices folder, which is how systemsetup identifies startup disks (e.g.
+	// "/Volumes/Macintosh HD 2/System/Library/CoreServices").
+	Path string `json:"path"`
+	// MountPoint is where the volume is mounted (e.g. "/" for the running system).
+	MountPoint string `json:"mount_point"`
+	// Current is whether the volume is the startup disk.
+	Current bool `json:"current"`
+}
+
+// Credentials are the name and password of an administrator, which Apple silicon requires for changing the
+// startup disk.
+type Credentials struct {
+	// User is the short name of the administrator.
+	User string
+	// Password is the administrator's password.
+	Password string
+}
+
+// List fetches the volumes that the host can boot from with systemsetup, marking the current startup disk.
+func List(ctx context.Context) ([]Volume, error) {
+	// cmdList represents the command used for executing macOS's systemsetup to list the startup disks.
+	//   * -liststartupdisks - list the CoreServices folders of the volumes that can be booted from
+	cmdList := []string{"systemsetup", "-liststartupdisks"}
+
+	out, err := util.ExecuteCommand(ctx, cmdList, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("startupdisk: failed to list startup disks, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+	current, err := Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseList(out.Stdout, current)
+}
+
+// Current fetches the CoreServices folder of the startup disk with systemsetup.
+func Current(ctx context.Context) (string, error) {
+	// cmdGet represents the command used for executing macOS's systemsetup to get the startup disk.
+	//   * -getstartupdisk - print the CoreServices folder of the startup disk
+	cmdGet := []string{"systemsetup", "-getstartupdisk"}
+
+	out, err := util.ExecuteCommand(ctx, cmdGet, "", nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("startupdisk: failed to get startup disk, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseCurrent(out.Stdout)
+}
+
+// Set makes the volume mounted at the mount point the startup disk with bless, which takes effect on the next boot.
+// Apple silicon only allows an administrator to change the startup disk so the credentials are required there, the
+// password is given on stdin so that it doesn't appear in the command's arguments.
+func Set(ctx context.Context, mountPoint string, creds *Credentials) error {
+	// cmdBless represents the command used for executing macOS's bless to set the startup disk.
+	//   * --mount - the mount point of the volume to boot from
+	//   * --setBoot - make the volume the startup disk
+	//   * --user - the administrator authorizing the change, on Apple silicon
+	//   * --stdinpass - read the administrator's password from stdin
+	cmdBless := []string{"bless", "--mount", mountPoint, "--setBoot"}
+	var opts []util.Option
+	if creds != nil {
+		cmdBless = append(cmdBless, "--user", creds.User, "--stdinpass")
+		opts = append(opts, util.Input(creds.Password))
+	}
+
+	out, err := util.ExecuteCommand(ctx, cmdBless, "", nil, nil, opts...)
+	if err != nil {
+		return fmt.Errorf("startupdisk: failed to set startup disk to %s, stderr: [%s]: %w", mountPoint, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// MountPoint gets the mount point of the volume with the CoreServices folder.
+func MountPoint(coreServicesPath string) string {
+	mountPoint := strings.TrimSuffix(path.Clean(coreServicesPath), coreServices)
+	if mountPoint == "" {
+		return "/"
+	}
+
+	return mountPoint
+}
+
+// parseList parses the CoreServices folders listed by systemsetup -liststartupdisks, one per line.
+func parseList(output string, current string) ([]Volume, error) {
+	var volumes []Volume
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "/") {
+			if strings.Contains(line, "You need administrator access") {
+				return nil, errors.New("startupdisk: listing startup disks requires root permissions")
+			}
+			continue
+		}
+		volumes = append(volumes, Volume{
+			Path:       line,
+			MountPoint: MountPoint(line),
+			Current:    path.Clean(line) == path.Clean(current),
+		})
+	}
+
+	return volumes, nil
+}
+
+// parseCurrent parses the CoreServices folder printed by systemsetup -getstartupdisk (e.g. "Startup Disk:
+// /System/Library/CoreServices").
+func parseCurrent(output string) (string, error) {
+	output = strings.TrimSpace(output)
+	idx := strings.Index(output, ": ")
+	if idx == -1 {
+		return "", fmt.Errorf("startupdisk: unexpected output %q", output)
+	}
+	value := strings.TrimSpace(output[idx+2:])
+	if !strings.HasPrefix(value, "/") {
+		return "", fmt.Errorf("startupdisk: no startup disk is set: %q", value)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,56 @@
+package startupdisk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseList(t *testing.T) {
+	output := "/System/Library/CoreServices\n/Volumes/Macintosh HD 2/System/Library/CoreServices\n"
+
+	volumes, err := parseList(output, "/Volumes/Macintosh HD 2/System/Library/CoreServices")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Volume{
+		{Path: "/System/Library/CoreServices", MountPoint: "/"},
+		{Path: "/Volumes/Macintosh HD 2/System/Library/CoreServices", MountPoint: "/Volumes/Macintosh HD 2", Current: true},
+	}, volumes)
+}
+
+func TestParseList_NotRoot(t *testing.T) {
+	_, err := parseList("You need administrator access to run this tool... exiting!\n", "")
+
+	assert.Error(t, err)
+}
+
+func TestParseCurrent(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{name: "running system", output: "Startup Disk: /System/Library/CoreServices\n", want: "/System/Library/CoreServices"},
+		{name: "staged volume", output: "Startup Disk: /Volumes/New HD/System/Library/CoreServices\n", want: "/Volumes/New HD/System/Library/CoreServices"},
+		{name: "not set", output: "Startup Disk: Not set\n", wantErr: true},
+		{name: "unexpected", output: "You need administrator access to run this tool... exiting!\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCurrent(tt.output)
+
+			assert.Equal(t, tt.want, got)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMountPoint(t *testing.T) {
+	assert.Equal(t, "/", MountPoint("/System/Library/CoreServices"))
+	assert.Equal(t, "/Volumes/New HD", MountPoint("/Volumes/New HD/System/Library/CoreServices/"))
+}
@@ -0,0 +1,226 @@
+// Package state provides the functionality necessary for keeping the utility's state (e.g. the operation journal and
+// the telemetry counters) in a versioned directory, so that releases can change how their state is kept without
+// breaking hosts that are upgraded in place.
+//
+// The directory's schema version is saved in its VERSION file. Migrations upgrade the directory one version at a
+// time, in order, and the version is saved after each one so that a migration that fails is retried by the next run
+// without repeating the ones that completed.
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/flock"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// DefaultDir is the default state directory.
+	DefaultDir = "/var/lib/ec2-macos-utils"
+	// LegacyDir is where state was kept by releases before the state directory was versioned.
+	LegacyDir = "/usr/local/aws/ec2-macos-utils"
+
+	// JournalDir is the directory of the operation journal within the state directory.
+	JournalDir = "journal"
+	// TelemetryFile is the file of the telemetry counters within the state directory.
+	TelemetryFile = "telemetry.json"
+
+	// versionFile is the file that the schema version is saved in.
+	versionFile = "VERSION"
+	// lockFile is the file that migrations are locked with.
+	lockFile = ".lock"
+	// dirMode is the mode of the state directory, which is readable so that the telemetry counters can be read
+	// without root permissions. Files that aren't meant to be read are kept private by their own modes.
+	dirMode = 0o755
+)
+
+// ErrNewerSchema is returned when the directory was migrated by a newer release than this one, whose state may not
+// be understood.
+var ErrNewerSchema = errors.New("state: directory has a newer schema than this release supports")
+
+// Migration upgrades the state directory from the schema version before Version.
+type Migration struct {
+	// Version is the schema version that the migration upgrades the directory to.
+	Version int `json:"version"`
+	// Description describes the migration's changes.
+	Description string `json:"description"`
+	// Migrate upgrades the directory at the path. Migrations must be safe to run again when they're interrupted.
+	Migrate func(dir string) error `json:"-"`
+}
+
+// Migrations gets the migrations of the state directory, in order. Version 1 adopts the state kept in legacyDir by
+// earlier releases (e.g. LegacyDir).
+func Migrations(legacyDir string) []Migration {
+	return []Migration{
+		{
+			Version:     1,
+			Description: "move the journal and telemetry counters into the state directory",
+			Migrate: func(dir string) error {
+				return adoptLegacy(legacyDir, dir)
+			},
+		},
+	}
+}
+
+// Dir is the versioned state directory.
+type Dir struct {
+	// Path is the state directory, it's created when it's first migrated.
+	Path string
+	// Migrations are the directory's migrations, in order. The version of the last one is the current schema.
+	Migrations []Migration
+}
+
+// New creates the Dir for the path with the utility's migrations.
+func New(path string) *Dir {
+	return &Dir{Path: path, Migrations: Migrations(LegacyDir)}
+}
+
+// Latest gets the schema version that the directory is migrated to.
+func (d *Dir) Latest() int {
+	if len(d.Migrations) == 0 {
+		return 0
+	}
+
+	return d.Migrations[len(d.Migrations)-1].Version
+}
+
+// Version reads the directory's schema version. Directories that haven't been migrated are version 0.
+func (d *Dir) Version() (int, error) {
+	data, err := os.ReadFile(filepath.Join(d.Path, versionFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("state: cannot read schema version: %w", err)
+	}
+
+	version, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("state: invalid schema version %q in %s", strings.TrimSpace(string(data)), d.Path)
+	}
+
+	return version, nil
+}
+
+// Pending gets the migrations that haven't been applied to the directory. ErrNewerSchema is returned when the
+// directory's schema is newer than the latest migration.
+func (d *Dir) Pending() ([]Migration, error) {
+	version, err := d.Version()
+	if err != nil {
+		return nil, err
+	}
+	if version > d.Latest() {
+		return nil, fmt.Errorf("%w (version %d, supported %d)", ErrNewerSchema, version, d.Latest())
+	}
+
+	var pending []Migration
+	for _, m := range d.Migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+
+	return pending, nil
+}
+
+// Migrate applies the migrations that haven't been applied to the directory, creating it when it doesn't exist,
+// and reports the ones that were applied. Migrations run by other processes at the same time are waited for so that
+// each migration is only applied once.
+func (d *Dir) Migrate() ([]Migration, error) {
+	if err := os.MkdirAll(d.Path, dirMode); err != nil {
+		return nil, fmt.Errorf("state: cannot create directory: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(d.Path, lockFile), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("state: cannot open lock: %w", err)
+	}
+	defer f.Close()
+	if err = flock.Lock(f); err != nil {
+		return nil, fmt.Errorf("state: cannot lock directory: %w", err)
+	}
+	defer flock.Unlock(f)
+
+	pending, err := d.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range pending {
+		if err := m.Migrate(d.Path); err != nil {
+			return applied, fmt.Errorf("state: cannot migrate to version %d: %w", m.Version, err)
+		}
+		if err := d.setVersion(m.Version); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m)
+	}
+
+	return applied, nil
+}
+
+// setVersion saves the directory's schema version.
+func (d *Dir) setVersion(version int) error {
+	if err := util.WriteFileAtomic(filepath.Join(d.Path, versionFile), []byte(strconv.Itoa(version)+"\n"), 0o644); err != nil {
+		return fmt.Errorf("state: cannot save schema version: %w", err)
+	}
+
+	return nil
+}
+
+// adoptLegacy moves the journal and telemetry counters from legacyDir into dir. State that's already in dir is kept
+// over the legacy state, whose leftovers are then removed.
+func adoptLegacy(legacyDir, dir string) error {
+	for _, name := range []string{JournalDir, TelemetryFile} {
+		if err := move(filepath.Join(legacyDir, name), filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(filepath.Join(legacyDir, TelemetryFile+".lock")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// move renames the file or directory at src to dst. Missing sources are skipped. Directories are moved entry by
+// entry into existing destinations, whose entries are kept, and files are only moved when dst doesn't exist.
+func move(src, dst string) error {
+	info, err := os.Lstat(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Lstat(dst)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if err := os.Rename(src, dst); err != nil {
+			return fmt.Errorf("cannot move %s: %w", src, err)
+		}
+		return nil
+	case err != nil:
+		return err
+	case !info.IsDir():
+		return os.Remove(src)
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := move(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+			return err
+		}
+	}
+
+	return os.RemoveAll(src)
+}
@@ -0,0 +1,125 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/journal"
+	"github.com/aws/ec2-macos-utils/internal/telemetry"
+)
+
+func TestDefaultPaths(t *testing.T) {
+	assert.Equal(t, filepath.Join(DefaultDir, JournalDir), journal.DefaultDir)
+	assert.Equal(t, filepath.Join(DefaultDir, TelemetryFile), telemetry.DefaultPath)
+}
+
+func TestDir_Migrate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	var runs []int
+	d := &Dir{Path: dir, Migrations: []Migration{
+		{Version: 1, Migrate: func(string) error { runs = append(runs, 1); return nil }},
+		{Version: 2, Migrate: func(string) error { runs = append(runs, 2); return nil }},
+	}}
+
+	version, err := d.Version()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version, "missing directories should be version 0")
+
+	applied, err := d.Migrate()
+	assert.NoError(t, err)
+	assert.Len(t, applied, 2)
+	assert.Equal(t, []int{1, 2}, runs)
+	version, err = d.Version()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+
+	applied, err = d.Migrate()
+	assert.NoError(t, err)
+	assert.Empty(t, applied, "migrations should only be applied once")
+	assert.Equal(t, []int{1, 2}, runs)
+}
+
+func TestDir_Migrate_Failure(t *testing.T) {
+	dir := t.TempDir()
+	failErr := errors.New("failed")
+	fail := true
+	d := &Dir{Path: dir, Migrations: []Migration{
+		{Version: 1, Migrate: func(string) error { return nil }},
+		{Version: 2, Migrate: func(string) error {
+			if fail {
+				return failErr
+			}
+			return nil
+		}},
+	}}
+
+	applied, err := d.Migrate()
+	assert.True(t, errors.Is(err, failErr))
+	assert.Len(t, applied, 1)
+	version, err := d.Version()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version, "completed migrations should be saved")
+
+	fail = false
+	applied, err = d.Migrate()
+	assert.NoError(t, err)
+	assert.Len(t, applied, 1, "only the failed migration should be retried")
+	assert.Equal(t, 2, applied[0].Version)
+}
+
+func TestDir_Pending_NewerSchema(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, versionFile), []byte("3\n"), 0o644))
+	d := &Dir{Path: dir, Migrations: Migrations(t.TempDir())}
+
+	_, err := d.Pending()
+	assert.True(t, errors.Is(err, ErrNewerSchema))
+	_, err = d.Migrate()
+	assert.True(t, errors.Is(err, ErrNewerSchema), "newer state shouldn't be migrated")
+}
+
+func TestDir_Version_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, versionFile), []byte("one\n"), 0o644))
+
+	_, err := (&Dir{Path: dir}).Version()
+	assert.Error(t, err)
+}
+
+func TestMigrations_AdoptLegacy(t *testing.T) {
+	legacy := t.TempDir()
+	dir := filepath.Join(t.TempDir(), "state")
+	assert.NoError(t, os.MkdirAll(filepath.Join(legacy, JournalDir), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(legacy, JournalDir, "a.json"), []byte("legacy"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(legacy, JournalDir, "b.json"), []byte("legacy"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(legacy, TelemetryFile), []byte("{}"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(legacy, TelemetryFile+".lock"), nil, 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(legacy, "config.yaml"), []byte("config"), 0o644))
+	// An entry already in the state directory is kept over the legacy one.
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, JournalDir), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, JournalDir, "b.json"), []byte("current"), 0o600))
+	d := &Dir{Path: dir, Migrations: Migrations(legacy)}
+
+	applied, err := d.Migrate()
+	assert.NoError(t, err)
+	assert.Len(t, applied, 1)
+
+	for name, expected := range map[string]string{
+		filepath.Join(JournalDir, "a.json"): "legacy",
+		filepath.Join(JournalDir, "b.json"): "current",
+		TelemetryFile:                       "{}",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		assert.NoError(t, err, name)
+		assert.Equal(t, expected, string(data), name)
+	}
+	for _, name := range []string{JournalDir, TelemetryFile, TelemetryFile + ".lock"} {
+		_, err := os.Lstat(filepath.Join(legacy, name))
+		assert.True(t, os.IsNotExist(err), name+" should be moved")
+	}
+	assert.FileExists(t, filepath.Join(legacy, "config.yaml"), "the configuration shouldn't be moved")
+}
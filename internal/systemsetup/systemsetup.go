@@ -0,0 +1,92 @@
+// Package systemsetup provides the functionality necessary for managing system settings with macOS's systemsetup CLI.
+package systemsetup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// Setting is a system setting managed with systemsetup's -get<name> and -set<name> flags.
+type Setting string
+
+const (
+	// RemoteLogin enables SSH access to the system.
+	RemoteLogin Setting = "remotelogin"
+	// Timezone is the system's time zone.
+	Timezone Setting = "timezone"
+	// RestartFreeze restarts the system automatically after it freezes.
+	RestartFreeze Setting = "restartfreeze"
+	// UsingNetworkTime synchronizes the system's clock with a network time server.
+	UsingNetworkTime Setting = "usingnetworktime"
+	// NetworkTimeServer is the network time server that the system's clock is synchronized with.
+	NetworkTimeServer Setting = "networktimeserver"
+)
+
+const (
+	// On is the value of enabled settings.
+	On = "On"
+	// Off is the value of disabled settings.
+	Off = "Off"
+)
+
+// OnOff gets the value systemsetup uses for enabled or disabled settings.
+func OnOff(enabled bool) string {
+	if enabled {
+		return On
+	}
+
+	return Off
+}
+
+// Get fetches the value of the setting with systemsetup.
+func Get(ctx context.Context, s Setting) (string, error) {
+	// cmdGet represents the command used for executing macOS's systemsetup to get a setting.
+	//   * -get<setting> - get the value of the setting
+	cmdGet := []string{"systemsetup", "-get" + string(s)}
+
+	out, err := util.ExecuteCommand(ctx, cmdGet, "", nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("systemsetup: failed to get %s, stderr: [%s]: %w", s, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseValue(out.Stdout)
+}
+
+// Set changes the value of the setting with systemsetup.
+func Set(ctx context.Context, s Setting, value string) error {
+	// cmdSet represents the command used for executing macOS's systemsetup to set a setting.
+	//   * -set<setting> - set the setting to the following value
+	//   * value - the value of the setting (e.g. "on", "America/Los_Angeles")
+	cmdSet := []string{"systemsetup", "-set" + string(s), value}
+	if s == RemoteLogin && strings.EqualFold(value, Off) {
+		// Disabling remote login prompts for confirmation unless forced.
+		cmdSet = []string{"systemsetup", "-f", "-set" + string(s), value}
+	}
+
+	out, err := util.ExecuteCommand(ctx, cmdSet, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("systemsetup: failed to set %s, stderr: [%s]: %w", s, strings.TrimSpace(out.Stderr), err)
+	}
+
+	// systemsetup reports some failures, such as missing Full Disk Access, on stdout with a zero exit status.
+	if msg := strings.TrimSpace(out.Stdout); strings.Contains(strings.ToLower(msg), "error") ||
+		strings.Contains(msg, "You need administrator access") {
+		return fmt.Errorf("systemsetup: failed to set %s: %s", s, msg)
+	}
+
+	return nil
+}
+
+// parseValue parses the value from systemsetup's output for a setting (e.g. "Remote Login: On").
+func parseValue(output string) (string, error) {
+	output = strings.TrimSpace(output)
+	idx := strings.Index(output, ": ")
+	if idx == -1 {
+		return "", fmt.Errorf("systemsetup: unexpected output %q", output)
+	}
+
+	return strings.TrimSpace(output[idx+2:]), nil
+}
@@ -0,0 +1,54 @@
+package systemsetup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{name: "remote login", output: "Remote Login: On\n", want: On},
+		{name: "time zone", output: "Time Zone: America/Los_Angeles\n", want: "America/Los_Angeles"},
+		{name: "restart freeze", output: "Restart After Freeze: Off\n", want: Off},
+		{name: "unexpected", output: "You need administrator access to run this tool... exiting!\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseValue(tt.output)
+
+			assert.Equal(t, tt.want, got)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTask_Desired(t *testing.T) {
+	enabled := true
+	disabled := false
+
+	task := &Task{RemoteLogin: &enabled, RestartFreeze: &disabled}
+
+	assert.Equal(t, map[string]string{
+		"remotelogin":   On,
+		"restartfreeze": Off,
+	}, task.desired(), "unset settings shouldn't be applied")
+}
+
+func TestTimezoneForRegion(t *testing.T) {
+	tz, err := TimezoneForRegion("us-west-2")
+	assert.NoError(t, err)
+	assert.Equal(t, "America/Los_Angeles", tz)
+
+	_, err = TimezoneForRegion("mars-north-1")
+	assert.Error(t, err)
+}
@@ -0,0 +1,80 @@
+package systemsetup
+
+import (
+	"context"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+// Task applies the desired systemsetup settings. Settings that aren't set are left as they are on the system.
+type Task struct {
+	// RemoteLogin enables or disables SSH access.
+	RemoteLogin *bool
+	// Timezone is the name of the time zone (e.g. "America/Los_Angeles").
+	Timezone string
+	// RestartFreeze enables or disables restarting the system automatically after it freezes.
+	RestartFreeze *bool
+	// NetworkTime enables or disables synchronizing the clock with a network time server.
+	NetworkTime *bool
+	// NetworkTimeServer is the network time server to synchronize the clock with.
+	NetworkTimeServer string
+}
+
+// Name identifies the task.
+func (t *Task) Name() string {
+	return "setup"
+}
+
+// desired gets the values of the settings to be applied.
+func (t *Task) desired() map[string]string {
+	desired := map[string]string{}
+	if t.RemoteLogin != nil {
+		desired[string(RemoteLogin)] = OnOff(*t.RemoteLogin)
+	}
+	if t.Timezone != "" {
+		desired[string(Timezone)] = t.Timezone
+	}
+	if t.RestartFreeze != nil {
+		desired[string(RestartFreeze)] = OnOff(*t.RestartFreeze)
+	}
+	if t.NetworkTime != nil {
+		desired[string(UsingNetworkTime)] = OnOff(*t.NetworkTime)
+	}
+	if t.NetworkTimeServer != "" {
+		desired[string(NetworkTimeServer)] = t.NetworkTimeServer
+	}
+
+	return desired
+}
+
+// Check compares the system's settings with the desired settings.
+func (t *Task) Check(ctx context.Context) ([]task.Change, error) {
+	desired := t.desired()
+
+	current := map[string]string{}
+	for s := range desired {
+		value, err := Get(ctx, Setting(s))
+		if err != nil {
+			return nil, err
+		}
+		current[s] = value
+	}
+
+	return task.Diff(current, desired), nil
+}
+
+// Apply changes the settings that differ from the desired settings.
+func (t *Task) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range changes {
+		if err := Set(ctx, Setting(c.Setting), c.Desired); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,94 @@
+// Package task provides the common shape of host configuration performed by EC2 macOS Utils so that each setting can
+// be checked for drift and applied in the same way.
+package task
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Change is a difference between a setting's current and desired value.
+type Change struct {
+	// Setting is the name of the setting.
+	Setting string `json:"setting"`
+	// Current is the value of the setting found on the system. An empty value means the setting is unset.
+	Current string `json:"current"`
+	// Desired is the value the setting should have.
+	Desired string `json:"desired"`
+}
+
+// Task is an idempotent unit of host configuration.
+type Task interface {
+	// Name identifies the task.
+	Name() string
+	// Check compares the system's configuration with the desired configuration and returns the changes needed to
+	// bring the system in line. No changes are returned when the system is already configured.
+	Check(ctx context.Context) ([]Change, error)
+	// Apply makes the changes needed to bring the system in line with the desired configuration and returns the
+	// changes that were made.
+	Apply(ctx context.Context) ([]Change, error)
+}
+
+// Diff compares the current values with the desired values and returns a change, sorted by setting, for each
+// desired value that differs. Settings that are only in current are ignored.
+func Diff(current, desired map[string]string) []Change {
+	var changes []Change
+	for setting, want := range desired {
+		if got := current[setting]; got != want {
+			changes = append(changes, Change{Setting: setting, Current: got, Desired: want})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Setting < changes[j].Setting
+	})
+
+	return changes
+}
+
+// Group is a Task made of other tasks, which are checked and applied in order.
+type Group struct {
+	// Tasks are the tasks in the group.
+	Tasks []Task
+
+	name string
+}
+
+// NewGroup creates a new Group with the given name for the tasks.
+func NewGroup(name string, tasks ...Task) *Group {
+	return &Group{Tasks: tasks, name: name}
+}
+
+// Name identifies the group.
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Check checks each task in the group and returns all of their changes.
+func (g *Group) Check(ctx context.Context) ([]Change, error) {
+	return g.each(func(t Task) ([]Change, error) {
+		return t.Check(ctx)
+	})
+}
+
+// Apply applies each task in the group and returns all of the changes that were made. Tasks after one that fails
+// aren't applied.
+func (g *Group) Apply(ctx context.Context) ([]Change, error) {
+	return g.each(func(t Task) ([]Change, error) {
+		return t.Apply(ctx)
+	})
+}
+
+// each runs fn for each task in the group and collects their changes.
+func (g *Group) each(fn func(t Task) ([]Change, error)) ([]Change, error) {
+	var changes []Change
+	for _, t := range g.Tasks {
+		c, err := fn(t)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", t.Name(), err)
+		}
+		changes = append(changes, c...)
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,70 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	current := map[string]string{"sleep": "1", "disksleep": "0", "womp": "1", "ttyskeepawake": "1"}
+	desired := map[string]string{"sleep": "0", "disksleep": "0", "standby": "0"}
+
+	assert.Equal(t, []Change{
+		{Setting: "sleep", Current: "1", Desired: "0"},
+		{Setting: "standby", Current: "", Desired: "0"},
+	}, Diff(current, desired))
+}
+
+func TestDiff_WithoutChanges(t *testing.T) {
+	assert.Empty(t, Diff(map[string]string{"sleep": "0"}, map[string]string{"sleep": "0"}))
+}
+
+// fakeTask is a Task whose changes are fixed, recording whether it was applied.
+type fakeTask struct {
+	name    string
+	changes []Change
+	err     error
+	applied bool
+}
+
+func (f *fakeTask) Name() string {
+	return f.name
+}
+
+func (f *fakeTask) Check(ctx context.Context) ([]Change, error) {
+	return f.changes, f.err
+}
+
+func (f *fakeTask) Apply(ctx context.Context) ([]Change, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.applied = true
+
+	return f.changes, nil
+}
+
+func TestGroup_Apply(t *testing.T) {
+	first := &fakeTask{name: "first", changes: []Change{{Setting: "a", Current: "1", Desired: "0"}}}
+	second := &fakeTask{name: "second", changes: []Change{{Setting: "b", Current: "", Desired: "1"}}}
+
+	changes, err := NewGroup("group", first, second).Apply(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, append(first.changes, second.changes...), changes)
+	assert.True(t, first.applied)
+	assert.True(t, second.applied)
+}
+
+func TestGroup_Apply_Error(t *testing.T) {
+	first := &fakeTask{name: "first", err: errors.New("failed")}
+	second := &fakeTask{name: "second"}
+
+	_, err := NewGroup("group", first, second).Apply(context.Background())
+
+	assert.EqualError(t, err, "first: failed")
+	assert.False(t, second.applied, "tasks after a failure shouldn't be applied")
+}
@@ -0,0 +1,124 @@
+// Package telemetry provides the functionality necessary for counting the runs of each command and how they ended,
+// so that operators can see how often automatic behaviors (e.g. growing the container at boot or syncing SSH keys)
+// actually run and fail. Counters are anonymous, they don't include arguments or error messages, and they're only
+// kept in a file on the host.
+package telemetry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/flock"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// DefaultPath is the default path of the counters' file.
+const DefaultPath = "/var/lib/ec2-macos-utils/telemetry.json"
+
+// OutcomeSuccess is the outcome of runs that succeeded, runs that failed have the class of their failure as their
+// outcome.
+const OutcomeSuccess = "success"
+
+// Counter counts the runs of a command.
+type Counter struct {
+	// Runs is the number of times the command ran.
+	Runs uint64 `json:"runs"`
+	// Successes is the number of runs that succeeded.
+	Successes uint64 `json:"successes"`
+	// Failures is the number of runs that failed by the class of their failure (e.g. "busy").
+	Failures map[diskutil.ErrClass]uint64 `json:"failures,omitempty"`
+	// LastRun is when the command last ran.
+	LastRun time.Time `json:"last_run"`
+	// LastOutcome is how the last run ended, OutcomeSuccess or the class of its failure.
+	LastOutcome string `json:"last_outcome"`
+}
+
+// Counters are the counters of each command, keyed by the command's name (e.g. "ssh sync").
+type Counters map[string]*Counter
+
+// Store is the file that counters are kept in.
+type Store struct {
+	// Path is the counters' file, it's created when the first run is recorded.
+	Path string
+
+	now func() time.Time
+}
+
+// New creates the Store for the file.
+func New(path string) *Store {
+	return &Store{Path: path, now: time.Now}
+}
+
+// Read reads the counters. A missing file has no counters.
+func (s *Store) Read() (Counters, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Counters{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("telemetry: cannot read counters: %w", err)
+	}
+
+	counters := Counters{}
+	if err = json.Unmarshal(data, &counters); err != nil {
+		return nil, fmt.Errorf("telemetry: invalid counters in %s: %w", s.Path, err)
+	}
+
+	return counters, nil
+}
+
+// Record counts a run of the command, which failed with runErr unless it's nil. Runs recorded by other processes at
+// the same time are waited for so that no run goes uncounted.
+func (s *Store) Record(command string, runErr error) error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return fmt.Errorf("telemetry: cannot create directory: %w", err)
+	}
+	f, err := os.OpenFile(s.Path+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("telemetry: cannot open lock: %w", err)
+	}
+	defer f.Close()
+	if err = flock.Lock(f); err != nil {
+		return fmt.Errorf("telemetry: cannot lock counters: %w", err)
+	}
+	defer flock.Unlock(f)
+
+	counters, err := s.Read()
+	if err != nil {
+		return err
+	}
+	c, ok := counters[command]
+	if !ok {
+		c = &Counter{}
+		counters[command] = c
+	}
+	c.Runs++
+	c.LastRun = s.now().UTC()
+	if runErr == nil {
+		c.Successes++
+		c.LastOutcome = OutcomeSuccess
+	} else {
+		class := diskutil.Classify(runErr)
+		if c.Failures == nil {
+			c.Failures = map[diskutil.ErrClass]uint64{}
+		}
+		c.Failures[class]++
+		c.LastOutcome = string(class)
+	}
+
+	data, err := json.MarshalIndent(counters, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Counters aren't sensitive so that they can be read without root permissions (e.g. by a metrics agent).
+	if err = util.WriteFileAtomic(s.Path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("telemetry: cannot save counters: %w", err)
+	}
+
+	return nil
+}
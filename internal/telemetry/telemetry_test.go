@@ -0,0 +1,53 @@
+package telemetry
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+)
+
+func TestStore_Record(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := New(filepath.Join(t.TempDir(), "state", "telemetry.json"))
+	s.now = func() time.Time { return now }
+
+	assert.NoError(t, s.Record("grow", nil))
+	assert.NoError(t, s.Record("grow", diskutil.ErrBusy))
+	assert.NoError(t, s.Record("grow", errors.New("failed")))
+	assert.NoError(t, s.Record("ssh sync", nil))
+
+	counters, err := s.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, Counters{
+		"grow": {
+			Runs:        3,
+			Successes:   1,
+			Failures:    map[diskutil.ErrClass]uint64{diskutil.ClassBusy: 1, diskutil.ClassUnknown: 1},
+			LastRun:     now,
+			LastOutcome: string(diskutil.ClassUnknown),
+		},
+		"ssh sync": {Runs: 1, Successes: 1, LastRun: now, LastOutcome: OutcomeSuccess},
+	}, counters)
+}
+
+func TestStore_Read_Missing(t *testing.T) {
+	counters, err := New(filepath.Join(t.TempDir(), "telemetry.json")).Read()
+
+	assert.NoError(t, err)
+	assert.Empty(t, counters)
+}
+
+func TestStore_Read_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telemetry.json")
+	assert.NoError(t, os.WriteFile(path, []byte("{"), 0o644))
+
+	_, err := New(path).Read()
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,124 @@
+// Package trim provides the functionality necessary for finding whether storage devices support TRIM (deallocate for
+// NVMe devices) and whether macOS's storage drivers have it enabled.
+package trim
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"howett.net/plist"
+
+	"github.com/aws/ec2-macos-utils/internal/ebs"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// Device is the TRIM status of an NVMe device.
+type Device struct {
+	// ID is the device identifier of the device's whole disk (e.g. disk2).
+	ID string `json:"id"`
+	// Model is the NVMe model name (e.g. "Amazon Elastic Block Store").
+	Model string `json:"model"`
+	// VolumeID is the ID of the EBS volume when the device is one.
+	VolumeID string `json:"volume_id,omitempty"`
+	// Status is whether the device supports TRIM and has it enabled.
+	Status types.TRIMStatus `json:"status"`
+}
+
+// storageDriver mirrors the IOBlockStorageDriver entries emitted by "ioreg -a -r -c IOBlockStorageDriver -d 2" where
+// the driver's children are the media it serves.
+type storageDriver struct {
+	Features struct {
+		Unmap bool `plist:"Unmap"`
+	} `plist:"IOStorageFeatures"`
+	Children []struct {
+		BSDName string `plist:"BSD Name"`
+	} `plist:"IORegistryEntryChildren"`
+}
+
+// Devices fetches the TRIM status of every NVMe device. Support is reported by the device while the storage
+// driver's unmap feature shows whether macOS issues TRIM commands to it.
+func Devices(ctx context.Context) ([]Device, error) {
+	nvme, err := ebs.NVMeDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// cmdIOReg represents the command used for executing macOS's ioreg to list the block storage drivers.
+	//   * -a - output the registry entries in the plist format
+	//   * -r -c IOBlockStorageDriver - show the subtrees rooted at the block storage drivers
+	//   * -d 2 - limit the subtrees to the drivers and the media they serve
+	cmdIOReg := []string{"ioreg", "-a", "-r", "-c", "IOBlockStorageDriver", "-d", "2"}
+
+	out, err := util.ExecuteCommand(ctx, cmdIOReg, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("trim: failed to run ioreg to list storage drivers, stderr: [%s]: %w", out.Stderr, err)
+	}
+	unmap, err := decodeUnmapFeatures(strings.NewReader(out.Stdout))
+	if err != nil {
+		return nil, err
+	}
+
+	return devicesFrom(nvme, unmap), nil
+}
+
+// decodeUnmapFeatures decodes the raw plist data from ioreg into whether the driver of each whole disk has the
+// unmap feature, keyed by the disk's device identifier.
+func decodeUnmapFeatures(reader io.ReadSeeker) (map[string]bool, error) {
+	var drivers []storageDriver
+	if err := plist.NewDecoder(reader).Decode(&drivers); err != nil {
+		return nil, fmt.Errorf("error decoding storage drivers: %w", err)
+	}
+
+	unmap := make(map[string]bool)
+	for _, d := range drivers {
+		for _, media := range d.Children {
+			if media.BSDName != "" {
+				unmap[media.BSDName] = d.Features.Unmap
+			}
+		}
+	}
+
+	return unmap, nil
+}
+
+// devicesFrom combines the NVMe devices with their drivers' unmap features.
+func devicesFrom(nvme []ebs.NVMeDevice, unmap map[string]bool) []Device {
+	devices := make([]Device, 0, len(nvme))
+	for _, d := range nvme {
+		devices = append(devices, Device{
+			ID:       d.BSD,
+			Model:    d.Model,
+			VolumeID: d.VolumeID(),
+			Status: types.TRIMStatus{
+				Supported: strings.EqualFold(d.TRIM, "yes"),
+				Enabled:   unmap[d.BSD],
+			},
+		})
+	}
+
+	return devices
+}
+
+// Resolve sets the TRIM status of the disk from the device backing it, which is left unset when the disk isn't
+// backed by one of the devices (e.g. disk images).
+func Resolve(ctx context.Context, u diskutil.DiskUtil, disk *types.DiskInfo, devices []Device) error {
+	whole, err := diskutil.PhysicalDisk(ctx, u, disk)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range devices {
+		if strings.EqualFold(d.ID, whole.DeviceIdentifier) {
+			status := d.Status
+			disk.TRIM = &status
+
+			return nil
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package trim
+
+import (
+	"context"
+	_ "embed"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/ebs"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/diskutilfakes"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+)
+
+// storageDrivers contains ioreg output with the drivers of two EBS volumes, only the first of which has unmap enabled.
+//
+//go:embed testdata/ioreg.plist
+var storageDrivers string
+
+func TestDecodeUnmapFeatures(t *testing.T) {
+	unmap, err := decodeUnmapFeatures(strings.NewReader(storageDrivers))
+
+	assert.NoError(t, err, "should be able to decode ioreg output")
+	assert.Equal(t, map[string]bool{"disk0": true, "disk2": false}, unmap)
+}
+
+func TestDecodeUnmapFeatures_WithoutPlistInput(t *testing.T) {
+	_, err := decodeUnmapFeatures(strings.NewReader("this is not a plist"))
+
+	assert.Error(t, err, "shouldn't be able to decode non-plist input")
+}
+
+func TestDevicesFrom(t *testing.T) {
+	devices := devicesFrom([]ebs.NVMeDevice{
+		{BSD: "disk0", Model: "Amazon Elastic Block Store", Serial: "vol0aaaaaaaaaaaaaaaa", TRIM: "Yes"},
+		{BSD: "disk2", Model: "Amazon Elastic Block Store", Serial: "vol0bbbbbbbbbbbbbbbb", TRIM: "Yes"},
+		{BSD: "disk4", Model: "APPLE SSD AP0256M", TRIM: "No"},
+	}, map[string]bool{"disk0": true, "disk2": false})
+
+	assert.Equal(t, []Device{
+		{ID: "disk0", Model: "Amazon Elastic Block Store", VolumeID: "vol-0aaaaaaaaaaaaaaaa", Status: types.TRIMStatus{Supported: true, Enabled: true}},
+		{ID: "disk2", Model: "Amazon Elastic Block Store", VolumeID: "vol-0bbbbbbbbbbbbbbbb", Status: types.TRIMStatus{Supported: true}},
+		{ID: "disk4", Model: "APPLE SSD AP0256M"},
+	}, devices)
+	assert.Equal(t, "supported but disabled", devices[1].Status.String())
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	fake := diskutilfakes.New(diskutilfakes.Disk{
+		ID:        "disk0",
+		Size:      150_000_000_000,
+		Internal:  true,
+		MediaName: "Amazon Elastic Block Store",
+		Partitions: []diskutilfakes.Partition{
+			{Content: "EFI", Size: 209_715_200, VolumeName: "EFI"},
+			{Content: "Apple_APFS", Size: 100_000_000_000, Container: &diskutilfakes.Container{
+				ID:      "disk3",
+				Volumes: []diskutilfakes.Volume{{Name: "Macintosh HD", Size: 20_000_000_000, MountPoint: "/"}},
+			}},
+		},
+	})
+	devices := []Device{{ID: "disk0", Status: types.TRIMStatus{Supported: true, Enabled: true}}}
+
+	root, err := fake.Info(ctx, "/")
+	assert.NoError(t, err)
+	assert.NoError(t, Resolve(ctx, fake, root, devices))
+	assert.Equal(t, &types.TRIMStatus{Supported: true, Enabled: true}, root.TRIM, "volumes should get their physical disk's status")
+
+	root.TRIM = nil
+	assert.NoError(t, Resolve(ctx, fake, root, nil))
+	assert.Nil(t, root.TRIM, "disks without a device shouldn't get a status")
+}
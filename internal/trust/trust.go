@@ -0,0 +1,437 @@
+// Package trust provides the functionality necessary for installing private certificate authorities into the System
+// keychain as trusted roots with macOS's security(1) tool, e.g. for networks whose proxies intercept TLS. Only the
+// admin trust settings, which apply to every user, are managed.
+package trust
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// SystemKeychain is the keychain holding the certificates trusted by every user.
+	SystemKeychain = "/Library/Keychains/System.keychain"
+	// securityPath is the path to macOS's security tool.
+	securityPath = "/usr/bin/security"
+
+	// pemType is the type of PEM blocks holding certificates.
+	pemType = "CERTIFICATE"
+)
+
+// trustedCertPattern matches the certificates listed by security's dump-trust-settings command, capturing their
+// names (e.g. "Cert 0: Example Corp Root CA").
+var trustedCertPattern = regexp.MustCompile(`^Cert \d+: (.*)$`)
+
+// Certificate is a certificate authority in the keychain.
+type Certificate struct {
+	// SHA1 is the certificate's SHA-1 fingerprint, which security identifies certificates by.
+	SHA1 string `json:"sha1"`
+	// Name is the certificate's subject common name, or its whole subject when it has no common name.
+	Name string `json:"name"`
+	// NotAfter is when the certificate expires.
+	NotAfter time.Time `json:"not_after"`
+	// Trusted indicates that the certificate is trusted as a root by the admin trust settings.
+	Trusted bool `json:"trusted"`
+}
+
+// ParseCertificates parses the certificates in PEM data, which may hold several, or in DER data. Only certificate
+// authorities are accepted since other certificates can't be trusted as roots.
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != pemType {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("trust: invalid certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 && !bytes.Contains(data, []byte("-----BEGIN")) {
+		parsed, err := x509.ParseCertificates(data)
+		if err != nil {
+			return nil, fmt.Errorf("trust: invalid certificate: %w", err)
+		}
+		certs = parsed
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("trust: no certificates found")
+	}
+
+	for _, cert := range certs {
+		if !cert.IsCA {
+			return nil, fmt.Errorf("trust: %s isn't a certificate authority", Name(cert))
+		}
+	}
+
+	return certs, nil
+}
+
+// Fingerprint gets the certificate's SHA-1 fingerprint as upper case hex, like security prints it.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha1.Sum(cert.Raw)
+
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// Name gets the certificate's subject common name, or its whole subject when it has no common name.
+func Name(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+
+	return cert.Subject.String()
+}
+
+// Store is a keychain holding trusted certificate authorities.
+type Store struct {
+	// Keychain is the path to the keychain (e.g. SystemKeychain).
+	Keychain string
+}
+
+// NewSystemStore creates a Store for the System keychain.
+func NewSystemStore() *Store {
+	return &Store{Keychain: SystemKeychain}
+}
+
+// List gets the certificate authorities in the keychain and whether they're trusted, ordered by name.
+func (s *Store) List(ctx context.Context) ([]Certificate, error) {
+	certs, err := s.certificates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	trusted, err := s.trustedNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []Certificate{}
+	for _, cert := range certs {
+		if !cert.IsCA {
+			continue
+		}
+		list = append(list, Certificate{
+			SHA1:     Fingerprint(cert),
+			Name:     Name(cert),
+			NotAfter: cert.NotAfter,
+			Trusted:  trusted[Name(cert)],
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return list, nil
+}
+
+// Lookup finds the certificate with the SHA-1 fingerprint in the keychain, returning nil when it isn't there.
+func (s *Store) Lookup(ctx context.Context, fingerprint string) (*x509.Certificate, error) {
+	certs, err := s.certificates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint = strings.ToUpper(strings.ReplaceAll(fingerprint, ":", ""))
+	for _, cert := range certs {
+		if Fingerprint(cert) == fingerprint {
+			return cert, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Add adds the certificate to the keychain and trusts it as a root for every user. Certificates that are already in
+// the keychain only have their trust settings updated.
+func (s *Store) Add(ctx context.Context, cert *x509.Certificate) error {
+	return withStagedCertificate(cert, func(path string) error {
+		// cmdAdd represents the command used for executing security to add a trusted certificate.
+		//   * add-trusted-cert - add the certificate and its trust settings
+		//   * -d - add to the admin trust settings, which apply to every user
+		//   * -r trustRoot - trust the certificate as a root
+		//   * -k <keychain> - the keychain to add the certificate to
+		cmdAdd := []string{securityPath, "add-trusted-cert", "-d", "-r", "trustRoot", "-k", s.Keychain, path}
+		_, err := security(ctx, cmdAdd, "trust "+Name(cert))
+
+		return err
+	})
+}
+
+// Remove removes the certificate's trust settings and deletes it from the keychain.
+func (s *Store) Remove(ctx context.Context, cert *x509.Certificate) error {
+	err := withStagedCertificate(cert, func(path string) error {
+		// cmdUntrust represents the command used for executing security to remove a certificate's trust settings.
+		//   * remove-trusted-cert - remove the certificate's trust settings
+		//   * -d - remove from the admin trust settings
+		cmdUntrust := []string{securityPath, "remove-trusted-cert", "-d", path}
+		out, err := util.ExecuteCommand(ctx, cmdUntrust, "", nil, nil)
+		// Certificates that were never trusted have no trust settings to remove.
+		if err != nil && !strings.Contains(out.Stderr, "could not be found") {
+			return fmt.Errorf("trust: failed to untrust %s, stderr: [%s]: %w", Name(cert), strings.TrimSpace(out.Stderr), err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// cmdDelete represents the command used for executing security to delete a certificate.
+	//   * delete-certificate - delete the certificate from the keychain
+	//   * -Z <sha1> - the SHA-1 fingerprint of the certificate to delete
+	cmdDelete := []string{securityPath, "delete-certificate", "-Z", Fingerprint(cert), s.Keychain}
+	_, err = security(ctx, cmdDelete, "delete "+Name(cert))
+
+	return err
+}
+
+// certificates gets all of the certificates in the keychain.
+func (s *Store) certificates(ctx context.Context) ([]*x509.Certificate, error) {
+	// cmdFind represents the command used for executing security to find certificates.
+	//   * find-certificate - find certificates
+	//   * -a - find all matching certificates, not just the first
+	//   * -p - print the certificates as PEM
+	cmdFind := []string{securityPath, "find-certificate", "-a", "-p", s.Keychain}
+	out, err := security(ctx, cmdFind, "list certificates")
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	rest := []byte(out)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		// Certificates that Go can't parse (e.g. with unusual extensions) can't be managed, so they're skipped.
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+
+	return certs, nil
+}
+
+// trustedNames gets the names of the certificates that have admin trust settings.
+func (s *Store) trustedNames(ctx context.Context) (map[string]bool, error) {
+	// cmdDump represents the command used for executing security to print the trust settings.
+	//   * dump-trust-settings - print the certificates with trust settings
+	//   * -d - print the admin trust settings
+	cmdDump := []string{securityPath, "dump-trust-settings", "-d"}
+	out, err := util.ExecuteCommand(ctx, cmdDump, "", nil, nil)
+	// security fails when there aren't any trust settings at all.
+	if err != nil && strings.Contains(out.Stderr, "No Trust Settings were found") {
+		return map[string]bool{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("trust: failed to dump trust settings, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseTrustedNames(out.Stdout), nil
+}
+
+// parseTrustedNames parses the names of the certificates printed by security's dump-trust-settings command.
+func parseTrustedNames(out string) map[string]bool {
+	names := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		if m := trustedCertPattern.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			names[m[1]] = true
+		}
+	}
+
+	return names
+}
+
+// withStagedCertificate writes the certificate to a temporary PEM file, since security only reads certificates from
+// files, and runs fn with its path.
+func withStagedCertificate(cert *x509.Certificate, fn func(path string) error) error {
+	f, err := os.CreateTemp("", "ec2-macos-utils-ca-*.pem")
+	if err != nil {
+		return fmt.Errorf("trust: cannot stage certificate: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	err = pem.Encode(f, &pem.Block{Type: pemType, Bytes: cert.Raw})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("trust: cannot stage certificate: %w", err)
+	}
+
+	return fn(f.Name())
+}
+
+// security runs the security command and returns its output.
+func security(ctx context.Context, c []string, action string) (string, error) {
+	out, err := util.ExecuteCommand(ctx, c, "", nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("trust: failed to %s, stderr: [%s]: %w", action, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return out.Stdout, nil
+}
+
+// AddTask ensures that the certificates are in the keychain and trusted as roots.
+type AddTask struct {
+	// Store is the keychain the certificates are added to.
+	Store *Store
+	// Certificates are the certificate authorities to trust.
+	Certificates []*x509.Certificate
+}
+
+// Name identifies the task.
+func (t *AddTask) Name() string {
+	return "trust-add"
+}
+
+// Check finds the certificates that are missing from the keychain or aren't trusted.
+func (t *AddTask) Check(ctx context.Context) ([]task.Change, error) {
+	installed, err := installedBySHA1(ctx, t.Store)
+	if err != nil {
+		return nil, err
+	}
+
+	current := map[string]string{}
+	desired := map[string]string{}
+	for _, cert := range t.Certificates {
+		setting := certSetting(cert)
+		current[setting] = certState(installed, cert)
+		desired[setting] = "trusted"
+	}
+
+	return task.Diff(current, desired), nil
+}
+
+// Apply adds and trusts the certificates that are missing or aren't trusted.
+func (t *AddTask) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+
+	for _, cert := range changesFor(changes, t.Certificates) {
+		if err := t.Store.Add(ctx, cert); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
+
+// RemoveTask ensures that the certificates aren't in the keychain.
+type RemoveTask struct {
+	// Store is the keychain the certificates are removed from.
+	Store *Store
+	// Certificates are the certificate authorities to remove.
+	Certificates []*x509.Certificate
+}
+
+// Name identifies the task.
+func (t *RemoveTask) Name() string {
+	return "trust-remove"
+}
+
+// Check finds the certificates that are still in the keychain.
+func (t *RemoveTask) Check(ctx context.Context) ([]task.Change, error) {
+	installed, err := installedBySHA1(ctx, t.Store)
+	if err != nil {
+		return nil, err
+	}
+
+	current := map[string]string{}
+	desired := map[string]string{}
+	for _, cert := range t.Certificates {
+		setting := certSetting(cert)
+		current[setting] = certState(installed, cert)
+		desired[setting] = "absent"
+	}
+
+	return task.Diff(current, desired), nil
+}
+
+// Apply removes the certificates that are still in the keychain.
+func (t *RemoveTask) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+
+	for _, cert := range changesFor(changes, t.Certificates) {
+		if err := t.Store.Remove(ctx, cert); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
+
+// installedBySHA1 gets the certificate authorities in the store keyed by their fingerprints.
+func installedBySHA1(ctx context.Context, s *Store) (map[string]Certificate, error) {
+	list, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	installed := map[string]Certificate{}
+	for _, c := range list {
+		installed[c.SHA1] = c
+	}
+
+	return installed, nil
+}
+
+// certState describes the certificate's state in the store: absent, untrusted, or trusted.
+func certState(installed map[string]Certificate, cert *x509.Certificate) string {
+	c, ok := installed[Fingerprint(cert)]
+	switch {
+	case !ok:
+		return "absent"
+	case !c.Trusted:
+		return "untrusted"
+	default:
+		return "trusted"
+	}
+}
+
+// certSetting names the certificate in changes (e.g. "trust.Example Corp Root CA[0123...]").
+func certSetting(cert *x509.Certificate) string {
+	return fmt.Sprintf("trust.%s[%s]", Name(cert), Fingerprint(cert))
+}
+
+// changesFor gets the certificates that the changes are for.
+func changesFor(changes []task.Change, certs []*x509.Certificate) []*x509.Certificate {
+	changed := map[string]bool{}
+	for _, c := range changes {
+		changed[c.Setting] = true
+	}
+
+	var matched []*x509.Certificate
+	for _, cert := range certs {
+		if changed[certSetting(cert)] {
+			matched = append(matched, cert)
+		}
+	}
+
+	return matched
+}
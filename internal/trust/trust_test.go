@@ -0,0 +1,92 @@
+package trust
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+// testCertificate creates a self-signed certificate with the common name, which is a certificate authority when ca is
+// set, and returns its DER encoding.
+func testCertificate(t *testing.T, name string, ca bool) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	return der
+}
+
+func TestParseCertificates(t *testing.T) {
+	root := testCertificate(t, "Example Root CA", true)
+	intermediate := testCertificate(t, "Example Intermediate CA", true)
+	data := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root}), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: intermediate})...)
+
+	certs, err := ParseCertificates(data)
+	assert.NoError(t, err)
+	assert.Len(t, certs, 2)
+	assert.Equal(t, "Example Root CA", Name(certs[0]))
+
+	certs, err = ParseCertificates(root)
+	assert.NoError(t, err, "DER certificates should be parsed")
+	assert.Len(t, certs, 1)
+	assert.Len(t, Fingerprint(certs[0]), 40)
+
+	_, err = ParseCertificates(testCertificate(t, "host.example.com", false))
+	assert.Error(t, err, "only certificate authorities should be accepted")
+	_, err = ParseCertificates(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("key")}))
+	assert.Error(t, err)
+}
+
+func TestParseTrustedNames(t *testing.T) {
+	out := `Number of trusted certs = 2
+Cert 0: Example Root CA
+   Number of trust settings : 1
+   Trust Setting 0:
+      Result Type    : kSecTrustSettingsResultTrustRoot
+Cert 1: Example Intermediate CA
+   Number of trust settings : 0
+`
+
+	assert.Equal(t, map[string]bool{"Example Root CA": true, "Example Intermediate CA": true}, parseTrustedNames(out))
+}
+
+func TestCertState(t *testing.T) {
+	certs, err := ParseCertificates(testCertificate(t, "Example Root CA", true))
+	assert.NoError(t, err)
+	cert := certs[0]
+
+	assert.Equal(t, "absent", certState(map[string]Certificate{}, cert))
+	assert.Equal(t, "untrusted", certState(map[string]Certificate{Fingerprint(cert): {}}, cert))
+	assert.Equal(t, "trusted", certState(map[string]Certificate{Fingerprint(cert): {Trusted: true}}, cert))
+}
+
+func TestChangesFor(t *testing.T) {
+	root, err := ParseCertificates(testCertificate(t, "Example Root CA", true))
+	assert.NoError(t, err)
+	other, err := ParseCertificates(testCertificate(t, "Other Root CA", true))
+	assert.NoError(t, err)
+
+	changes := []task.Change{{Setting: certSetting(other[0]), Current: "absent", Desired: "trusted"}}
+
+	assert.Equal(t, other, changesFor(changes, append(root, other...)))
+}
@@ -0,0 +1,75 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+// accountPasswordLength is the length of the random passwords of accounts created by AccountTask.
+const accountPasswordLength = 32
+
+// AccountTask ensures that the local user exists, creating it with a random password when it doesn't since the
+// account is meant to log in with SSH keys. Existing accounts are only changed to match Admin, when it's set.
+type AccountTask struct {
+	// Options are the attributes the account is created with. The password is generated.
+	Options CreateOptions
+	// Admin is whether the account should be an administrator. Existing accounts' memberships are left as they are
+	// when unset.
+	Admin *bool
+}
+
+// Name identifies the task.
+func (t *AccountTask) Name() string {
+	return "account"
+}
+
+// Check reports whether the account needs to be created or its admin membership changed.
+func (t *AccountTask) Check(ctx context.Context) ([]task.Change, error) {
+	name := t.Options.Name
+	u, err := Lookup(ctx, name)
+	if errors.Is(err, ErrNotFound) {
+		return []task.Change{{Setting: "user." + name, Desired: "created"}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if u.IsSystem() {
+		return nil, fmt.Errorf("users: refusing to manage system user %s", name)
+	}
+	if t.Admin == nil {
+		return nil, nil
+	}
+
+	admin, err := IsAdmin(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if admin == *t.Admin {
+		return nil, nil
+	}
+
+	return []task.Change{{Setting: "user." + name + ".admin", Current: strconv.FormatBool(admin), Desired: strconv.FormatBool(*t.Admin)}}, nil
+}
+
+// Apply creates the account or changes its admin membership.
+func (t *AccountTask) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+
+	if changes[0].Desired == "created" {
+		opts := t.Options
+		opts.Admin = t.Admin != nil && *t.Admin
+		if opts.Password, err = GeneratePassword(accountPasswordLength); err != nil {
+			return nil, err
+		}
+
+		return changes, Create(ctx, opts)
+	}
+
+	return changes, SetAdmin(ctx, t.Options.Name, *t.Admin)
+}
@@ -0,0 +1,157 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/defaults"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// KCPasswordPath is the path to the obfuscated password used by the login window to sign in automatically.
+	KCPasswordPath = "/etc/kcpassword"
+
+	// autoLoginUserKey is the login window preference naming the user that's signed in automatically.
+	autoLoginUserKey = "autoLoginUser"
+
+	// kcPasswordBlockSize is the size of the blocks that kcpassword files are padded to.
+	kcPasswordBlockSize = 12
+)
+
+// kcPasswordKey is the fixed key the login window uses to obfuscate the automatic login password.
+var kcPasswordKey = []byte{0x7d, 0x89, 0x52, 0x23, 0xd2, 0xbc, 0xdd, 0xea, 0xa3, 0xb9, 0x1f}
+
+// EncodeKCPassword obfuscates the password in the format of KCPasswordPath. The password is null terminated, padded
+// to a multiple of 12 bytes, and XORed with kcPasswordKey. Note that this is obfuscation and not encryption: the file
+// must only be readable by root.
+func EncodeKCPassword(password string) []byte {
+	size := (len(password)/kcPasswordBlockSize + 1) * kcPasswordBlockSize
+	data := make([]byte, size)
+	copy(data, password)
+	for i := range data {
+		data[i] ^= kcPasswordKey[i%len(kcPasswordKey)]
+	}
+
+	return data
+}
+
+// DecodeKCPassword recovers the password from data in the format of KCPasswordPath.
+func DecodeKCPassword(data []byte) string {
+	decoded := make([]byte, len(data))
+	for i := range data {
+		decoded[i] = data[i] ^ kcPasswordKey[i%len(kcPasswordKey)]
+	}
+	if idx := strings.IndexByte(string(decoded), 0); idx != -1 {
+		decoded = decoded[:idx]
+	}
+
+	return string(decoded)
+}
+
+// AutoLoginUser fetches the name of the user that's signed in automatically. An empty name is returned when
+// automatic login is disabled.
+func AutoLoginUser(ctx context.Context) (string, error) {
+	name, err := defaults.NewDomain(defaults.LoginWindowDomain).ReadString(ctx, autoLoginUserKey)
+	if errors.Is(err, defaults.ErrNotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(KCPasswordPath); errors.Is(err, os.ErrNotExist) {
+		// The login window can't sign in without the password so automatic login is effectively disabled.
+		return "", nil
+	}
+
+	return name, nil
+}
+
+// EnableAutoLogin configures the login window to sign in as the user automatically after boot. The password must
+// be the user's current password, which is checked before it's stored.
+func EnableAutoLogin(ctx context.Context, name string, password string) error {
+	if err := Authenticate(ctx, name, password); err != nil {
+		return err
+	}
+
+	if err := util.WriteFileAtomic(KCPasswordPath, EncodeKCPassword(password), 0600); err != nil {
+		return fmt.Errorf("users: failed to write %s: %w", KCPasswordPath, err)
+	}
+	if err := defaults.NewDomain(defaults.LoginWindowDomain).WriteString(ctx, autoLoginUserKey, name); err != nil {
+		return fmt.Errorf("users: failed to set automatic login user: %w", err)
+	}
+
+	return nil
+}
+
+// DisableAutoLogin stops the login window from signing in automatically and removes the stored password.
+func DisableAutoLogin(ctx context.Context) error {
+	if err := defaults.NewDomain(defaults.LoginWindowDomain).Delete(ctx, autoLoginUserKey); err != nil {
+		return fmt.Errorf("users: failed to unset automatic login user: %w", err)
+	}
+	if err := os.Remove(KCPasswordPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("users: failed to remove %s: %w", KCPasswordPath, err)
+	}
+
+	return nil
+}
+
+// Authenticate checks the user's password. dscl is run interactively with its command, and so the password, given on
+// stdin so that the password doesn't appear in the command's arguments.
+func Authenticate(ctx context.Context, name string, password string) error {
+	// cmdAuth represents the command used for executing macOS's dscl interactively to check a password.
+	//   * . - use the local directory node
+	//
+	// The command read from stdin is:
+	//   * authonly <name> <password> - authenticate the user without changing anything
+	cmdAuth := []string{"dscl", "."}
+
+	out, err := util.ExecuteCommand(ctx, cmdAuth, "", nil, nil, util.Input(dsclAuthCommand(name, password)))
+	if err != nil {
+		return fmt.Errorf("users: failed to authenticate %s, stderr: [%s]: %w", name, strings.TrimSpace(out.Stderr), err)
+	}
+	// Interactive dscl reports failures of its commands but still exits successfully.
+	if msg := dsclError(out.Stdout + "\n" + out.Stderr); msg != "" {
+		return fmt.Errorf("users: failed to authenticate %s: %s", name, msg)
+	}
+
+	return nil
+}
+
+// dsclAuthCommand formats the interactive dscl command that authenticates the user, quoting its arguments.
+func dsclAuthCommand(name string, password string) string {
+	return "authonly " + dsclQuote(name) + " " + dsclQuote(password)
+}
+
+// dsclQuote quotes the argument of an interactive dscl command so that it's read as a single argument.
+func dsclQuote(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+// dsclError finds the first failure reported by interactive dscl, without its prompt.
+func dsclError(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.Contains(line, "DS Error") || strings.Contains(line, "failed") {
+			return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), ">"))
+		}
+	}
+
+	return ""
+}
+
+// FileVaultEnabled checks if FileVault is on. The login window can't sign in automatically on FileVault protected
+// systems since the disk is unlocked before macOS boots.
+func FileVaultEnabled(ctx context.Context) (bool, error) {
+	// cmdStatus represents the command used for executing macOS's fdesetup to check FileVault.
+	//   * status - print whether FileVault is on
+	cmdStatus := []string{"fdesetup", "status"}
+
+	out, err := util.ExecuteCommand(ctx, cmdStatus, "", nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("users: failed to get FileVault status, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return strings.Contains(out.Stdout, "FileVault is On"), nil
+}
@@ -0,0 +1,46 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeKCPassword(t *testing.T) {
+	encoded := EncodeKCPassword("password")
+
+	assert.Equal(t, []byte{0x0d, 0xe8, 0x21, 0x50, 0xa5, 0xd3, 0xaf, 0x8e, 0xa3, 0xb9, 0x1f, 0x7d}, encoded)
+}
+
+func TestEncodeKCPassword_Padding(t *testing.T) {
+	tests := []struct {
+		password string
+		size     int
+	}{
+		{password: "", size: 12},
+		{password: "hunter2", size: 12},
+		{password: "elevenchars", size: 12},
+		{password: "twelve chars", size: 24},
+		{password: "a much longer password", size: 24},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			encoded := EncodeKCPassword(tt.password)
+
+			assert.Len(t, encoded, tt.size, "passwords should be null terminated and padded to 12 bytes")
+			assert.Equal(t, tt.password, DecodeKCPassword(encoded))
+		})
+	}
+}
+
+func TestDsclAuthCommand(t *testing.T) {
+	assert.Equal(t, `authonly "ec2-user" "hunter2"`, dsclAuthCommand("ec2-user", "hunter2"))
+	assert.Equal(t, `authonly "ec2-user" "p@ss \"word\" \\n"`, dsclAuthCommand("ec2-user", `p@ss "word" \n`))
+}
+
+func TestDsclError(t *testing.T) {
+	assert.Equal(t, "Authentication for node /Local/Default failed. (-14090, eDSAuthFailed)",
+		dsclError(" > Authentication for node /Local/Default failed. (-14090, eDSAuthFailed)\n > \n"))
+	assert.Equal(t, "<dscl_cmd> DS Error: -14136 (eDSRecordNotFound)", dsclError("<dscl_cmd> DS Error: -14136 (eDSRecordNotFound)\n"))
+	assert.Empty(t, dsclError(" > \n"))
+}
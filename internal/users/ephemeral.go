@@ -0,0 +1,207 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+const (
+	// ephemeralMarker ends the comment of the authorized keys installed by EphemeralKeysTask so that they're told
+	// apart from the user's own keys.
+	ephemeralMarker = "ec2-macos-utils-ephemeral"
+	// expiryTimeLayout is the layout of OpenSSH's expiry-time option in UTC.
+	expiryTimeLayout = "20060102150405Z"
+)
+
+// EphemeralKey is an OpenSSH public key that's authorized until it expires.
+type EphemeralKey struct {
+	// Key is the OpenSSH public key (e.g. "ssh-ed25519 AAAA... name").
+	Key string `json:"key"`
+	// Expires is when the key stops being authorized.
+	Expires time.Time `json:"expires"`
+}
+
+// EphemeralKeysTask ensures that the ephemeral keys that haven't expired, and only those, are authorized to log in
+// as the user. The keys are written with OpenSSH's expiry-time option so that sshd refuses them once they expire even
+// if the task isn't applied again, and the user's own keys are left as they are.
+type EphemeralKeysTask struct {
+	// User is the user the keys are authorized for.
+	User *User
+	// Keys are the ephemeral keys that should be authorized. Keys that have expired are skipped.
+	Keys []EphemeralKey
+
+	// now gets the current time, which is time.Now when unset.
+	now func() time.Time
+}
+
+// Name identifies the task.
+func (t *EphemeralKeysTask) Name() string {
+	return "ephemeral-ssh-keys"
+}
+
+// Check compares the ephemeral keys in the user's authorized keys file with the desired keys. Keys that expired or
+// are no longer desired are reported for removal.
+func (t *EphemeralKeysTask) Check(ctx context.Context) ([]task.Change, error) {
+	_, current, err := readEphemeralKeys(t.path())
+	if err != nil {
+		return nil, err
+	}
+	desired, err := t.desired()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []task.Change
+	for line, key := range desired {
+		if _, ok := current[line]; !ok {
+			changes = append(changes, task.Change{Setting: t.setting(key), Desired: authorizedUntil(key.Expires)})
+		}
+	}
+	for line, key := range current {
+		if _, ok := desired[line]; !ok {
+			changes = append(changes, task.Change{Setting: t.setting(key), Current: authorizedUntil(key.Expires)})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Setting < changes[j].Setting
+	})
+
+	return changes, nil
+}
+
+// Apply rewrites the user's authorized keys file with the desired ephemeral keys in place of the ones it held,
+// creating it with the permissions that sshd requires when it doesn't exist.
+func (t *EphemeralKeysTask) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+
+	kept, _, err := readEphemeralKeys(t.path())
+	if err != nil {
+		return nil, err
+	}
+	desired, err := t.desired()
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0, len(desired))
+	for line := range desired {
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+
+	if err := writeAuthorizedKeys(t.User, append(kept, lines...)); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// EphemeralKeys reads the ephemeral keys in the user's authorized keys file, including ones that have expired but
+// haven't been removed yet, sorted by when they expire.
+func EphemeralKeys(u *User) ([]EphemeralKey, error) {
+	_, current, err := readEphemeralKeys(filepath.Join(u.Home, authorizedKeysPath))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]EphemeralKey, 0, len(current))
+	for _, key := range current {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Expires.Before(keys[j].Expires)
+	})
+
+	return keys, nil
+}
+
+// path gets the path to the user's authorized keys file.
+func (t *EphemeralKeysTask) path() string {
+	return filepath.Join(t.User.Home, authorizedKeysPath)
+}
+
+// setting names the key in changes by the user and the key's comment (e.g. "ephemeral-key.ec2-user.alice").
+func (t *EphemeralKeysTask) setting(key EphemeralKey) string {
+	return "ephemeral-key." + t.User.Name + "." + strings.TrimPrefix(keySetting(withoutOptions(key.Key)), "ssh-key.")
+}
+
+// desired renders the authorized keys lines of the keys that haven't expired.
+func (t *EphemeralKeysTask) desired() (map[string]EphemeralKey, error) {
+	now := time.Now
+	if t.now != nil {
+		now = t.now
+	}
+
+	desired := map[string]EphemeralKey{}
+	for _, key := range t.Keys {
+		if !key.Expires.After(now()) {
+			continue
+		}
+		line, err := ephemeralLine(key)
+		if err != nil {
+			return nil, err
+		}
+		desired[line] = key
+	}
+
+	return desired, nil
+}
+
+// ephemeralLine formats the key as an authorized keys line that expires with the key and is marked as ephemeral.
+// Options given with the key are dropped so that they can't override the expiry.
+func ephemeralLine(key EphemeralKey) (string, error) {
+	if _, err := keyID(key.Key); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("expiry-time=%q %s %s", key.Expires.UTC().Format(expiryTimeLayout), withoutOptions(key.Key), ephemeralMarker), nil
+}
+
+// readEphemeralKeys reads the user's authorized keys file, splitting it into the lines that it keeps as they are and
+// the ephemeral keys, keyed by their lines. A missing file has no lines.
+func readEphemeralKeys(path string) (kept []string, ephemeral map[string]EphemeralKey, err error) {
+	kept, lines, err := readMarkedLines(path, func(line string) bool {
+		_, ok := parseEphemeralLine(line)
+		return ok
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ephemeral = make(map[string]EphemeralKey, len(lines))
+	for _, line := range lines {
+		ephemeral[line], _ = parseEphemeralLine(line)
+	}
+
+	return kept, ephemeral, nil
+}
+
+// parseEphemeralLine parses an authorized keys line written by ephemeralLine.
+func parseEphemeralLine(line string) (EphemeralKey, bool) {
+	if !strings.HasSuffix(line, " "+ephemeralMarker) || !strings.HasPrefix(line, `expiry-time="`) {
+		return EphemeralKey{}, false
+	}
+	option, key, ok := strings.Cut(strings.TrimSuffix(line, " "+ephemeralMarker), " ")
+	if !ok {
+		return EphemeralKey{}, false
+	}
+	expires, err := time.Parse(expiryTimeLayout, strings.Trim(strings.TrimPrefix(option, "expiry-time="), `"`))
+	if err != nil {
+		return EphemeralKey{}, false
+	}
+
+	return EphemeralKey{Key: key, Expires: expires}, true
+}
+
+// authorizedUntil describes when a key stops being authorized in changes.
+func authorizedUntil(expires time.Time) string {
+	return "authorized until " + expires.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,61 @@
+package users
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+func TestEphemeralKeysTask(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	home := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(home, ".ssh"), 0700))
+	path := filepath.Join(home, authorizedKeysPath)
+	expired := `expiry-time="20261015115900Z" ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQDlaunchkey bob ` + ephemeralMarker
+	assert.NoError(t, os.WriteFile(path, []byte(fleetKey+"\n"+expired+"\n"), 0600))
+	u := &User{Name: "ec2-user", UID: os.Getuid(), GID: os.Getgid(), Home: home}
+	alice := `command="/bin/sh" ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIalice alice@example.com`
+	tk := &EphemeralKeysTask{User: u, Keys: []EphemeralKey{
+		{Key: alice, Expires: now.Add(15 * time.Minute)},
+		{Key: launchKey, Expires: now.Add(-time.Minute)},
+	}, now: func() time.Time { return now }}
+
+	changes, err := tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []task.Change{
+		{Setting: "ephemeral-key.ec2-user.alice@example.com", Desired: "authorized until 2026-10-15T12:15:00Z"},
+		{Setting: "ephemeral-key.ec2-user.bob", Current: "authorized until 2026-10-15T11:59:00Z"},
+	}, changes)
+
+	_, err = tk.Apply(context.Background())
+	assert.NoError(t, err)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, fleetKey+"\n"+
+		`expiry-time="20261015121500Z" ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIalice alice@example.com `+ephemeralMarker+"\n", string(data),
+		"options should be dropped and expired keys removed")
+
+	keys, err := EphemeralKeys(u)
+	assert.NoError(t, err)
+	assert.Equal(t, []EphemeralKey{{Key: "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIalice alice@example.com", Expires: now.Add(15 * time.Minute)}}, keys)
+
+	changes, err = tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestReadAuthorizedKeys_IgnoresEphemeralKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	assert.NoError(t, os.WriteFile(path, []byte(`expiry-time="20261015121500Z" `+fleetKey+" "+ephemeralMarker+"\n"), 0600))
+
+	keys, err := readAuthorizedKeys(path)
+
+	assert.NoError(t, err)
+	assert.Empty(t, keys, "ephemeral keys shouldn't count as authorized")
+}
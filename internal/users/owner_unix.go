@@ -0,0 +1,18 @@
+//go:build unix
+
+package users
+
+import (
+	"os"
+	"syscall"
+)
+
+// owner gets the IDs of the user and group that own the file.
+func owner(info os.FileInfo) (uid, gid int, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+
+	return int(st.Uid), int(st.Gid), true
+}
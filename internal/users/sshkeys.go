@@ -0,0 +1,277 @@
+package users
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// authorizedKeysPath is the path to the authorized keys file in a user's home directory.
+const authorizedKeysPath = ".ssh/authorized_keys"
+
+// AuthorizedKeysTask ensures that the OpenSSH public keys are authorized to log in as the user. Keys that are already
+// authorized, including ones that aren't in Keys, are left as they are.
+type AuthorizedKeysTask struct {
+	// User is the user the keys are authorized for.
+	User *User
+	// Username is the name of the user the keys are authorized for when User is nil. The user is looked up when the
+	// task is checked or applied so that it can follow the task that creates the user.
+	Username string
+	// Keys are the OpenSSH public keys (e.g. "ssh-ed25519 AAAA... name").
+	Keys []string
+
+	// lookup fetches the user named Username, which is Lookup when unset.
+	lookup func(ctx context.Context, name string) (*User, error)
+}
+
+// Name identifies the task.
+func (t *AuthorizedKeysTask) Name() string {
+	return "ssh-keys"
+}
+
+// Check compares the user's authorized keys with the desired keys. All of the desired keys are reported when the
+// user doesn't exist yet.
+func (t *AuthorizedKeysTask) Check(ctx context.Context) ([]task.Change, error) {
+	authorized := map[string]bool{}
+	u, err := t.user(ctx)
+	switch {
+	case errors.Is(err, ErrNotFound):
+	case err != nil:
+		return nil, err
+	default:
+		if authorized, err = readAuthorizedKeys(filepath.Join(u.Home, authorizedKeysPath)); err != nil {
+			return nil, err
+		}
+	}
+
+	var changes []task.Change
+	for _, key := range t.Keys {
+		id, err := keyID(key)
+		if err != nil {
+			return nil, err
+		}
+		if !authorized[id] {
+			changes = append(changes, task.Change{Setting: keySetting(key), Desired: "authorized"})
+		}
+	}
+
+	return changes, nil
+}
+
+// Apply appends the desired keys that aren't authorized to the user's authorized keys file, creating it with the
+// permissions that sshd requires when it doesn't exist.
+func (t *AuthorizedKeysTask) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+
+	u, err := t.user(ctx)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(u.Home, authorizedKeysPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("users: cannot create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.Chown(filepath.Dir(path), u.UID, u.GID); err != nil {
+		return nil, fmt.Errorf("users: cannot change owner of %s: %w", filepath.Dir(path), err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("users: cannot open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	authorized, err := readAuthorizedKeys(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range t.Keys {
+		if id, _ := keyID(key); !authorized[id] {
+			if _, err := fmt.Fprintln(f, strings.TrimSpace(key)); err != nil {
+				return nil, fmt.Errorf("users: cannot write %s: %w", path, err)
+			}
+			authorized[id] = true
+		}
+	}
+	if err := f.Chown(u.UID, u.GID); err != nil {
+		return nil, fmt.Errorf("users: cannot change owner of %s: %w", path, err)
+	}
+
+	return changes, f.Close()
+}
+
+// user gets the user, looking it up by Username when User isn't set.
+func (t *AuthorizedKeysTask) user(ctx context.Context) (*User, error) {
+	if t.User != nil {
+		return t.User, nil
+	}
+
+	lookup := Lookup
+	if t.lookup != nil {
+		lookup = t.lookup
+	}
+
+	return lookup(ctx, t.Username)
+}
+
+// readAuthorizedKeys reads the keys in the authorized keys file, identified by keyID. A missing file has no keys.
+func readAuthorizedKeys(path string) (map[string]bool, error) {
+	keys := map[string]bool{}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return keys, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("users: cannot read %s: %w", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Ephemeral and synced keys are removed when they expire or are revoked, so they don't count as authorized.
+		if _, ok := parseEphemeralLine(line); ok || isSyncedLine(line) {
+			continue
+		}
+		if id, err := keyID(line); err == nil {
+			keys[id] = true
+		}
+	}
+
+	return keys, scanner.Err()
+}
+
+// readMarkedLines reads the authorized keys file, splitting it into the lines that are kept as they are and the lines
+// that are marked as managed by a task. A missing file has no lines.
+func readMarkedLines(path string, marked func(line string) bool) (kept []string, managed []string, err error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("users: cannot read %s: %w", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); marked(line) {
+			managed = append(managed, line)
+		} else {
+			kept = append(kept, line)
+		}
+	}
+
+	return kept, managed, scanner.Err()
+}
+
+// writeAuthorizedKeys replaces the user's authorized keys file with the lines, creating it with the permissions that
+// sshd requires. The user controls their home directory so the .ssh directory and the file are refused when they're
+// symbolic links or owned by another user, which would otherwise have the files they point at changed by root.
+func writeAuthorizedKeys(u *User, lines []string) error {
+	path := filepath.Join(u.Home, authorizedKeysPath)
+	dir := filepath.Dir(path)
+	if err := os.Mkdir(dir, 0o700); err == nil {
+		if err := os.Lchown(dir, u.UID, u.GID); err != nil {
+			return fmt.Errorf("users: cannot change owner of %s: %w", dir, err)
+		}
+	} else if !errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("users: cannot create %s: %w", dir, err)
+	}
+	if err := checkOwned(u, dir); err != nil {
+		return err
+	}
+	if err := checkOwned(u, path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line + "\n")
+	}
+	if err := util.WriteFileAtomic(path, []byte(b.String()), 0o600); err != nil {
+		return fmt.Errorf("users: cannot write %s: %w", path, err)
+	}
+	if err := os.Lchown(path, u.UID, u.GID); err != nil {
+		return fmt.Errorf("users: cannot change owner of %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// checkOwned checks that the file at path isn't a symbolic link and is owned by the user.
+func checkOwned(u *User, path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("users: cannot read %s: %w", path, err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("users: refusing to write through symbolic link %s", path)
+	}
+	if uid, _, ok := owner(info); ok && uid != u.UID {
+		return fmt.Errorf("users: refusing to write %s, it's owned by UID %d rather than %s", path, uid, u.Name)
+	}
+
+	return nil
+}
+
+// withoutOptions drops the options before the key's type from an authorized keys line.
+func withoutOptions(line string) string {
+	fields := strings.Fields(line)
+	for i := range fields {
+		if isKeyType(fields[i]) {
+			return strings.Join(fields[i:], " ")
+		}
+	}
+
+	return strings.TrimSpace(line)
+}
+
+// keyID identifies an OpenSSH public key, or an authorized_keys line, by its type and base64-encoded key so that
+// options and comments don't matter.
+func keyID(line string) (string, error) {
+	fields := strings.Fields(line)
+	for i := 0; i < len(fields)-1; i++ {
+		if isKeyType(fields[i]) {
+			return fields[i] + " " + fields[i+1], nil
+		}
+	}
+
+	return "", fmt.Errorf("users: invalid OpenSSH public key %q", line)
+}
+
+// isKeyType checks if the field is an OpenSSH key type (e.g. "ssh-ed25519" or "ecdsa-sha2-nistp256").
+func isKeyType(field string) bool {
+	for _, prefix := range []string{"ssh-", "ecdsa-", "sk-"} {
+		if strings.HasPrefix(field, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keySetting names the key in changes by its comment, falling back to the end of the key.
+func keySetting(key string) string {
+	fields := strings.Fields(key)
+	if len(fields) > 2 {
+		return "ssh-key." + strings.Join(fields[2:], " ")
+	}
+	if len(fields) == 2 && len(fields[1]) > 12 {
+		return "ssh-key." + fields[0] + " ..." + fields[1][len(fields[1])-12:]
+	}
+
+	return "ssh-key." + key
+}
@@ -0,0 +1,101 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+const (
+	fleetKey   = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIHePm1cYz0sEYlw6KwP8PxLk1n0oQm9u2x9vJ1aG7bQx fleet"
+	launchKey  = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQDlaunchkey my-key-pair"
+	optionsKey = `from="10.0.0.0/8" ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQDlaunchkey old comment`
+)
+
+func TestAuthorizedKeysTask(t *testing.T) {
+	home := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(home, ".ssh"), 0700))
+	path := filepath.Join(home, authorizedKeysPath)
+	assert.NoError(t, os.WriteFile(path, []byte("# keys\n"+optionsKey+"\n"), 0600))
+	u := &User{Name: "ec2-user", UID: os.Getuid(), GID: os.Getgid(), Home: home}
+	tk := &AuthorizedKeysTask{User: u, Keys: []string{launchKey, fleetKey}}
+
+	changes, err := tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []task.Change{{Setting: "ssh-key.fleet", Desired: "authorized"}}, changes, "keys with options should match")
+
+	changes, err = tk.Apply(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "# keys\n"+optionsKey+"\n"+fleetKey+"\n", string(data))
+
+	changes, err = tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestAuthorizedKeysTask_InvalidKey(t *testing.T) {
+	tk := &AuthorizedKeysTask{User: &User{Home: t.TempDir()}, Keys: []string{"not a key"}}
+
+	_, err := tk.Check(context.Background())
+
+	assert.Error(t, err)
+}
+
+func TestAuthorizedKeysTask_UserNotCreated(t *testing.T) {
+	tk := &AuthorizedKeysTask{Username: "runner", Keys: []string{fleetKey}, lookup: func(ctx context.Context, name string) (*User, error) {
+		return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+	}}
+
+	changes, err := tk.Check(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []task.Change{{Setting: "ssh-key.fleet", Desired: "authorized"}}, changes, "keys for users that will be created should be reported")
+}
+
+func TestWriteAuthorizedKeys_Symlink(t *testing.T) {
+	home := t.TempDir()
+	target := t.TempDir()
+	assert.NoError(t, os.Symlink(target, filepath.Join(home, ".ssh")))
+	u := &User{Name: "ec2-user", UID: os.Getuid(), GID: os.Getgid(), Home: home}
+
+	err := writeAuthorizedKeys(u, []string{fleetKey})
+
+	assert.Error(t, err, "symlinked .ssh directories should be refused")
+	_, err = os.Stat(filepath.Join(target, "authorized_keys"))
+	assert.True(t, os.IsNotExist(err), "nothing should be written through the link")
+}
+
+func TestWriteAuthorizedKeys_SymlinkFile(t *testing.T) {
+	home := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(home, ".ssh"), 0o700))
+	target := filepath.Join(t.TempDir(), "passwd")
+	assert.NoError(t, os.WriteFile(target, []byte("root\n"), 0o644))
+	assert.NoError(t, os.Symlink(target, filepath.Join(home, authorizedKeysPath)))
+	u := &User{Name: "ec2-user", UID: os.Getuid(), GID: os.Getgid(), Home: home}
+
+	err := writeAuthorizedKeys(u, []string{fleetKey})
+
+	assert.Error(t, err, "symlinked authorized_keys files should be refused")
+	data, err := os.ReadFile(target)
+	assert.NoError(t, err)
+	assert.Equal(t, "root\n", string(data))
+}
+
+func TestWriteAuthorizedKeys_OtherOwner(t *testing.T) {
+	home := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(home, ".ssh"), 0o700))
+	u := &User{Name: "ec2-user", UID: os.Getuid() + 1, GID: os.Getgid(), Home: home}
+
+	err := writeAuthorizedKeys(u, []string{fleetKey})
+
+	assert.Error(t, err, ".ssh directories owned by another user should be refused")
+}
@@ -0,0 +1,211 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+// syncMarker ends the comment of the authorized keys installed by SyncedKeysTask so that they're told apart from the
+// user's own keys.
+const syncMarker = "ec2-macos-utils-sync"
+
+// SyncedKeysTask ensures that the keys synced from a central source (e.g. IAM) are, and are the only synced keys,
+// authorized to log in as the user. Synced keys that are no longer desired are removed, while the user's own keys are
+// left as they are.
+type SyncedKeysTask struct {
+	// User is the name of the user the keys are authorized for. The user is looked up when the task is checked or
+	// applied so that it can follow the task that creates the user.
+	User string
+	// Keys are the OpenSSH public keys (e.g. "ssh-ed25519 AAAA... name"). An empty list removes all synced keys.
+	Keys []string
+
+	// lookup fetches the user, which is Lookup when unset.
+	lookup func(ctx context.Context, name string) (*User, error)
+}
+
+// Name identifies the task.
+func (t *SyncedKeysTask) Name() string {
+	return "synced-ssh-keys"
+}
+
+// Check compares the synced keys in the user's authorized keys file with the desired keys. All of the desired keys
+// are reported when the user doesn't exist yet.
+func (t *SyncedKeysTask) Check(ctx context.Context) ([]task.Change, error) {
+	var current []string
+	u, err := t.user(ctx)
+	switch {
+	case errors.Is(err, ErrNotFound):
+	case err != nil:
+		return nil, err
+	default:
+		if _, current, err = readMarkedLines(filepath.Join(u.Home, authorizedKeysPath), isSyncedLine); err != nil {
+			return nil, err
+		}
+	}
+	desired, err := t.desired()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []task.Change
+	for _, line := range desired {
+		if !contains(current, line) {
+			changes = append(changes, task.Change{Setting: t.setting(line), Desired: "authorized"})
+		}
+	}
+	for _, line := range current {
+		if !contains(desired, line) {
+			changes = append(changes, task.Change{Setting: t.setting(line), Current: "authorized"})
+		}
+	}
+
+	return changes, nil
+}
+
+// Apply rewrites the user's authorized keys file with the desired synced keys in place of the ones it held.
+func (t *SyncedKeysTask) Apply(ctx context.Context) ([]task.Change, error) {
+	changes, err := t.Check(ctx)
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+
+	u, err := t.user(ctx)
+	if err != nil {
+		return nil, err
+	}
+	kept, _, err := readMarkedLines(filepath.Join(u.Home, authorizedKeysPath), isSyncedLine)
+	if err != nil {
+		return nil, err
+	}
+	desired, err := t.desired()
+	if err != nil {
+		return nil, err
+	}
+	if err := writeAuthorizedKeys(u, append(kept, desired...)); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// SyncedUsers finds the users that have synced keys by the names of their home directories in HomeRoot.
+func SyncedUsers() ([]string, error) {
+	return syncedUsers(HomeRoot)
+}
+
+// syncedUsers finds the users with synced keys by the names of their home directories in root.
+func syncedUsers(root string) ([]string, error) {
+	return markedUsers(root, isSyncedLine)
+}
+
+// ManagedKeyUsers finds the users that have synced or ephemeral keys by the names of their home directories in root
+// (e.g. HomeRoot).
+func ManagedKeyUsers(root string) ([]string, error) {
+	return markedUsers(root, isManagedLine)
+}
+
+// RemoveManagedKeys removes the synced and ephemeral keys from the user's authorized keys file, leaving the keys that
+// weren't installed by SyncedKeysTask or EphemeralKeysTask. It reports whether any keys were removed.
+func RemoveManagedKeys(u *User) (bool, error) {
+	kept, managed, err := readMarkedLines(filepath.Join(u.Home, authorizedKeysPath), isManagedLine)
+	if err != nil || len(managed) == 0 {
+		return false, err
+	}
+
+	return true, writeAuthorizedKeys(u, kept)
+}
+
+// markedUsers finds the users whose authorized keys files have marked lines by the names of their home directories
+// in root. Files that can't be read are skipped.
+func markedUsers(root string, marked func(line string) bool) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(root, "*", authorizedKeysPath))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, path := range paths {
+		_, lines, err := readMarkedLines(path, marked)
+		if errors.Is(err, os.ErrPermission) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if len(lines) != 0 {
+			names = append(names, filepath.Base(filepath.Dir(filepath.Dir(path))))
+		}
+	}
+
+	return names, nil
+}
+
+// isManagedLine checks if the authorized keys line was installed by SyncedKeysTask or EphemeralKeysTask.
+func isManagedLine(line string) bool {
+	_, ephemeral := parseEphemeralLine(line)
+
+	return ephemeral || isSyncedLine(line)
+}
+
+// user looks up the user, refusing accounts that belong to macOS.
+func (t *SyncedKeysTask) user(ctx context.Context) (*User, error) {
+	lookup := Lookup
+	if t.lookup != nil {
+		lookup = t.lookup
+	}
+
+	u, err := lookup(ctx, t.User)
+	if err != nil {
+		return nil, err
+	}
+	if u.IsSystem() {
+		return nil, fmt.Errorf("users: refusing to sync keys for system user %s", u.Name)
+	}
+
+	return u, nil
+}
+
+// desired renders the sorted authorized keys lines of the desired keys, without their options.
+func (t *SyncedKeysTask) desired() ([]string, error) {
+	lines := make([]string, 0, len(t.Keys))
+	for _, key := range t.Keys {
+		if _, err := keyID(key); err != nil {
+			return nil, err
+		}
+		if line := withoutOptions(key) + " " + syncMarker; !contains(lines, line) {
+			lines = append(lines, line)
+		}
+	}
+	sort.Strings(lines)
+
+	return lines, nil
+}
+
+// setting names the key in changes by the user and the key's comment (e.g. "synced-key.alice.alice@laptop").
+func (t *SyncedKeysTask) setting(line string) string {
+	key := strings.TrimSpace(strings.TrimSuffix(line, syncMarker))
+
+	return "synced-key." + t.User + "." + strings.TrimPrefix(keySetting(key), "ssh-key.")
+}
+
+// isSyncedLine checks if the authorized keys line was written by SyncedKeysTask.
+func isSyncedLine(line string) bool {
+	return strings.HasSuffix(line, " "+syncMarker)
+}
+
+// contains checks if the lines include the line.
+func contains(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+
+	return false
+}
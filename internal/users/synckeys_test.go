@@ -0,0 +1,89 @@
+package users
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/task"
+)
+
+func TestSyncedKeysTask(t *testing.T) {
+	root := t.TempDir()
+	home := filepath.Join(root, "alice")
+	assert.NoError(t, os.MkdirAll(filepath.Join(home, ".ssh"), 0700))
+	path := filepath.Join(home, authorizedKeysPath)
+	revoked := "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQDlaunchkey old " + syncMarker
+	assert.NoError(t, os.WriteFile(path, []byte(fleetKey+"\n"+revoked+"\n"), 0600))
+	u := &User{Name: "alice", UID: os.Getuid(), GID: os.Getgid(), Home: home}
+	if u.IsSystem() {
+		// Running as root, which can hand the file to any user.
+		u.UID = MinUID
+	}
+	// The user's files are owned by them, as they are on hosts.
+	assert.NoError(t, os.Chown(filepath.Join(home, ".ssh"), u.UID, u.GID))
+	assert.NoError(t, os.Chown(path, u.UID, u.GID))
+	lookup := func(ctx context.Context, name string) (*User, error) {
+		return u, nil
+	}
+	tk := &SyncedKeysTask{User: "alice", Keys: []string{`from="10.0.0.0/8" ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIalice alice@laptop`}, lookup: lookup}
+
+	changes, err := tk.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []task.Change{
+		{Setting: "synced-key.alice.alice@laptop", Desired: "authorized"},
+		{Setting: "synced-key.alice.old", Current: "authorized"},
+	}, changes)
+
+	names, err := syncedUsers(root)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, names)
+
+	_, err = tk.Apply(context.Background())
+	assert.NoError(t, err)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, fleetKey+"\nssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIalice alice@laptop "+syncMarker+"\n", string(data))
+}
+
+func TestSyncedKeysTask_SystemUser(t *testing.T) {
+	tk := &SyncedKeysTask{User: "root", lookup: func(ctx context.Context, name string) (*User, error) {
+		return &User{Name: "root", UID: 0}, nil
+	}}
+
+	_, err := tk.Check(context.Background())
+
+	assert.Error(t, err, "keys shouldn't be synced for system users")
+}
+
+func TestRemoveManagedKeys(t *testing.T) {
+	root := t.TempDir()
+	home := filepath.Join(root, "alice")
+	assert.NoError(t, os.MkdirAll(filepath.Join(home, ".ssh"), 0700))
+	path := filepath.Join(home, authorizedKeysPath)
+	synced := "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQDlaunchkey old " + syncMarker
+	ephemeral := `expiry-time="20261015121500Z" ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIalice alice ` + ephemeralMarker
+	assert.NoError(t, os.WriteFile(path, []byte(fleetKey+"\n"+synced+"\n"+ephemeral+"\n"), 0600))
+	u := &User{Name: "alice", UID: os.Getuid(), GID: os.Getgid(), Home: home}
+
+	names, err := ManagedKeyUsers(root)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, names)
+
+	removed, err := RemoveManagedKeys(u)
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, fleetKey+"\n", string(data), "only synced and ephemeral keys should be removed")
+
+	removed, err = RemoveManagedKeys(u)
+	assert.NoError(t, err)
+	assert.False(t, removed)
+	names, err = ManagedKeyUsers(root)
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+}
@@ -0,0 +1,326 @@
+// Package users provides the functionality necessary for managing local user accounts with sysadminctl, dscl, and
+// dseditgroup.
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"howett.net/plist"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// AdminGroup is the group whose members are administrators.
+	AdminGroup = "admin"
+	// HomeRoot is the directory holding the home directories of local users.
+	HomeRoot = "/Users"
+	// MinUID is the lowest UID of accounts created for people and services. Accounts with lower UIDs belong to
+	// macOS and are never modified.
+	MinUID = 500
+)
+
+// ErrNotFound is returned when the user doesn't exist.
+var ErrNotFound = errors.New("users: user not found")
+
+// User is a local user account.
+type User struct {
+	// Name is the account's short name.
+	Name string `json:"name"`
+	// RealName is the account's full name.
+	RealName string `json:"real_name"`
+	// UID is the account's user ID.
+	UID int `json:"uid"`
+	// GID is the ID of the account's primary group.
+	GID int `json:"gid"`
+	// Home is the path to the account's home directory.
+	Home string `json:"home"`
+	// Shell is the account's login shell.
+	Shell string `json:"shell"`
+}
+
+// IsSystem checks if the account belongs to macOS rather than a person or service.
+func (u *User) IsSystem() bool {
+	return u.UID < MinUID
+}
+
+// Lookup fetches the local user with the given name.
+func Lookup(ctx context.Context, name string) (*User, error) {
+	// cmdRead represents the command used for executing macOS's dscl to read a user record.
+	//   * -plist - print the record as a plist
+	//   * . - use the local directory node
+	//   * -read /Users/<name> - read the following attributes of the user's record
+	cmdRead := []string{"dscl", "-plist", ".", "-read", "/Users/" + name,
+		"RecordName", "RealName", "UniqueID", "PrimaryGroupID", "NFSHomeDirectory", "UserShell"}
+
+	out, err := util.ExecuteCommand(ctx, cmdRead, "", nil, nil)
+	if err != nil {
+		if strings.Contains(out.Stderr, "eDSRecordNotFound") || strings.Contains(out.Stderr, "eDSUnknownNodeName") {
+			return nil, fmt.Errorf("%s: %w", name, ErrNotFound)
+		}
+		return nil, fmt.Errorf("users: failed to read user %s, stderr: [%s]: %w", name, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return decodeUser(strings.NewReader(out.Stdout))
+}
+
+// decodeUser decodes a user from a record printed by "dscl -plist".
+func decodeUser(r io.ReadSeeker) (*User, error) {
+	attrs := map[string][]string{}
+	if err := plist.NewDecoder(r).Decode(&attrs); err != nil {
+		return nil, fmt.Errorf("error decoding user record: %w", err)
+	}
+
+	first := func(attr string) string {
+		values := attrs["dsAttrTypeStandard:"+attr]
+		if len(values) == 0 {
+			return ""
+		}
+		return values[0]
+	}
+
+	u := &User{
+		Name:     first("RecordName"),
+		RealName: first("RealName"),
+		Home:     first("NFSHomeDirectory"),
+		Shell:    first("UserShell"),
+	}
+	var err error
+	if u.UID, err = strconv.Atoi(first("UniqueID")); err != nil {
+		return nil, fmt.Errorf("invalid UID for user %s: %w", u.Name, err)
+	}
+	if u.GID, err = strconv.Atoi(first("PrimaryGroupID")); err != nil {
+		return nil, fmt.Errorf("invalid GID for user %s: %w", u.Name, err)
+	}
+
+	return u, nil
+}
+
+// CreateOptions are the attributes of a new user.
+type CreateOptions struct {
+	// Name is the account's short name.
+	Name string
+	// FullName is the account's full name. The short name is used when empty.
+	FullName string
+	// Password is the account's password.
+	Password string
+	// Shell is the account's login shell. macOS's default shell is used when empty.
+	Shell string
+	// UID is the account's user ID. The next available ID is used when 0.
+	UID int
+	// Admin adds the account to the admin group.
+	Admin bool
+}
+
+// Create creates a new local user with a home directory in HomeRoot. The password is given on stdin so that it
+// doesn't appear in the command's arguments.
+func Create(ctx context.Context, opts CreateOptions) error {
+	if opts.Name == "" || opts.Password == "" {
+		return errors.New("users: name and password are required")
+	}
+
+	// cmdCreate represents the command used for executing macOS's sysadminctl to create a user.
+	//   * -addUser <name> - create a user with the following short name
+	//   * -fullName <name> - the full name of the user
+	//   * -password - - read the password of the user from stdin
+	//   * -home <path> - the home directory of the user
+	//   * -UID <uid> - the user ID of the user (optional)
+	//   * -shell <path> - the login shell of the user (optional)
+	//   * -admin - make the user an administrator (optional)
+	fullName := opts.FullName
+	if fullName == "" {
+		fullName = opts.Name
+	}
+	cmdCreate := []string{"sysadminctl", "-addUser", opts.Name, "-fullName", fullName,
+		"-password", "-", "-home", filepath.Join(HomeRoot, opts.Name)}
+	if opts.UID != 0 {
+		cmdCreate = append(cmdCreate, "-UID", strconv.Itoa(opts.UID))
+	}
+	if opts.Shell != "" {
+		cmdCreate = append(cmdCreate, "-shell", opts.Shell)
+	}
+	if opts.Admin {
+		cmdCreate = append(cmdCreate, "-admin")
+	}
+
+	if err := runSysadminctl(ctx, cmdCreate, util.Input(opts.Password)); err != nil {
+		return fmt.Errorf("users: failed to create user %s: %w", opts.Name, err)
+	}
+
+	// sysadminctl doesn't create the home directory until the user first logs in, which is too late for services.
+	//
+	// cmdHome represents the command used for executing macOS's createhomedir to create a home directory.
+	//   * -c - create the home directory on the local node
+	//   * -u <name> - the user whose home directory is created
+	cmdHome := []string{"createhomedir", "-c", "-u", opts.Name}
+	if out, err := util.ExecuteCommand(ctx, cmdHome, "", nil, nil); err != nil {
+		return fmt.Errorf("users: failed to create home directory for %s, stderr: [%s]: %w", opts.Name, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// SetPassword changes the user's password. The password is given on stdin so that it doesn't appear in the command's
+// arguments.
+func SetPassword(ctx context.Context, name string, password string) error {
+	// cmdReset represents the command used for executing macOS's sysadminctl to reset a password.
+	//   * -resetPasswordFor <name> - reset the password of the following user
+	//   * -newPassword - - read the new password of the user from stdin
+	cmdReset := []string{"sysadminctl", "-resetPasswordFor", name, "-newPassword", "-"}
+
+	if err := runSysadminctl(ctx, cmdReset, util.Input(password)); err != nil {
+		return fmt.Errorf("users: failed to set password for %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// SetShell changes the user's login shell.
+func SetShell(ctx context.Context, name string, shell string) error {
+	// cmdShell represents the command used for executing macOS's dscl to change a login shell.
+	//   * . - use the local directory node
+	//   * -create /Users/<name> UserShell <shell> - set the user's shell attribute
+	cmdShell := []string{"dscl", ".", "-create", "/Users/" + name, "UserShell", shell}
+
+	out, err := util.ExecuteCommand(ctx, cmdShell, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("users: failed to set shell for %s, stderr: [%s]: %w", name, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// IsAdmin checks if the user is a member of the admin group.
+func IsAdmin(ctx context.Context, name string) (bool, error) {
+	// cmdCheck represents the command used for executing macOS's dseditgroup to check group membership.
+	//   * -o checkmember - check if the following user is a member of the group
+	//   * -m <name> - the user to be checked
+	//   * admin - the group to be checked
+	cmdCheck := []string{"dseditgroup", "-o", "checkmember", "-m", name, AdminGroup}
+
+	out, err := util.ExecuteCommand(ctx, cmdCheck, "", nil, nil)
+	switch {
+	case strings.HasPrefix(out.Stdout, "yes"):
+		return true, nil
+	case strings.HasPrefix(out.Stdout, "no"):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("users: failed to check admin membership for %s, stderr: [%s]: %w", name, strings.TrimSpace(out.Stderr), err)
+	default:
+		return false, fmt.Errorf("users: unexpected membership output %q", strings.TrimSpace(out.Stdout))
+	}
+}
+
+// SetAdmin adds the user to or removes the user from the admin group.
+func SetAdmin(ctx context.Context, name string, admin bool) error {
+	// cmdEdit represents the command used for executing macOS's dseditgroup to edit group membership.
+	//   * -o edit - edit the group's membership
+	//   * -a|-d <name> - add or delete the following member
+	//   * -t user - the member is a user
+	//   * admin - the group to be edited
+	op := "-a"
+	if !admin {
+		op = "-d"
+	}
+	cmdEdit := []string{"dseditgroup", "-o", "edit", op, name, "-t", "user", AdminGroup}
+
+	out, err := util.ExecuteCommand(ctx, cmdEdit, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("users: failed to edit admin membership for %s, stderr: [%s]: %w", name, strings.TrimSpace(out.Stderr), err)
+	}
+
+	return nil
+}
+
+// Delete deletes the user. The user's home directory is removed as well unless keepHome is set.
+func Delete(ctx context.Context, u *User, keepHome bool) error {
+	if u.IsSystem() {
+		return fmt.Errorf("users: refusing to delete system user %s", u.Name)
+	}
+
+	// cmdDelete represents the command used for executing macOS's sysadminctl to delete a user.
+	//   * -deleteUser <name> - delete the following user
+	//   * -keepHome - keep the user's home directory (optional)
+	cmdDelete := []string{"sysadminctl", "-deleteUser", u.Name}
+	if keepHome {
+		cmdDelete = append(cmdDelete, "-keepHome")
+	}
+
+	if err := runSysadminctl(ctx, cmdDelete); err != nil {
+		return fmt.Errorf("users: failed to delete user %s: %w", u.Name, err)
+	}
+	if keepHome {
+		return nil
+	}
+
+	// sysadminctl leaves home directories behind when they hold files it can't remove (e.g. files created by root).
+	if !isRemovableHome(u) {
+		return nil
+	}
+	if err := os.RemoveAll(u.Home); err != nil {
+		return fmt.Errorf("users: failed to remove home directory %s: %w", u.Home, err)
+	}
+
+	return nil
+}
+
+// isRemovableHome checks that the user's home directory is their own directory in HomeRoot so that directories
+// shared with other users (e.g. a home of /var/empty) are never removed.
+func isRemovableHome(u *User) bool {
+	home := filepath.Clean(u.Home)
+
+	return filepath.Dir(home) == HomeRoot && filepath.Base(home) == u.Name
+}
+
+// runSysadminctl executes the sysadminctl command. sysadminctl logs its failures on stderr and often exits
+// successfully anyway, so its output is checked for errors as well.
+func runSysadminctl(ctx context.Context, c []string, opts ...util.Option) error {
+	out, err := util.ExecuteCommand(ctx, c, "", nil, nil, opts...)
+	if err != nil {
+		return fmt.Errorf("stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+	if msg := sysadminctlError(out.Stderr); msg != "" {
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// sysadminctlError finds the first error logged by sysadminctl.
+func sysadminctlError(stderr string) string {
+	for _, line := range strings.Split(stderr, "\n") {
+		if strings.Contains(line, "Error:") || strings.Contains(line, "not permitted") {
+			return strings.TrimSpace(line)
+		}
+	}
+
+	return ""
+}
+
+// passwordAlphabet are the characters used in generated passwords. Characters that are hard to tell apart or that
+// need quoting in shells are left out.
+const passwordAlphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789-_.+="
+
+// GeneratePassword generates a random password of the given length.
+func GeneratePassword(length int) (string, error) {
+	var b strings.Builder
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("users: cannot generate password: %w", err)
+		}
+		b.WriteByte(passwordAlphabet[n.Int64()])
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,80 @@
+package users
+
+import (
+	"bytes"
+	_ "embed"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// userRecord contains the record of a user printed by dscl.
+//
+//go:embed testdata/user.plist
+var userRecord []byte
+
+func TestDecodeUser(t *testing.T) {
+	u, err := decodeUser(bytes.NewReader(userRecord))
+
+	assert.NoError(t, err)
+	assert.Equal(t, &User{
+		Name:     "runner",
+		RealName: "CI Runner",
+		UID:      502,
+		GID:      20,
+		Home:     "/Users/runner",
+		Shell:    "/bin/zsh",
+	}, u)
+	assert.False(t, u.IsSystem())
+}
+
+func TestUser_IsSystem(t *testing.T) {
+	assert.True(t, (&User{Name: "_www", UID: 70}).IsSystem())
+	assert.True(t, (&User{Name: "root", UID: 0}).IsSystem())
+	assert.False(t, (&User{Name: "ec2-user", UID: 501}).IsSystem())
+}
+
+func TestIsRemovableHome(t *testing.T) {
+	tests := []struct {
+		name string
+		user User
+		want bool
+	}{
+		{name: "own home", user: User{Name: "runner", Home: "/Users/runner"}, want: true},
+		{name: "unclean path", user: User{Name: "runner", Home: "/Users/runner/"}, want: true},
+		{name: "home root", user: User{Name: "runner", Home: "/Users"}, want: false},
+		{name: "other user's home", user: User{Name: "runner", Home: "/Users/ec2-user"}, want: false},
+		{name: "shared home", user: User{Name: "runner", Home: "/var/empty"}, want: false},
+		{name: "relative path", user: User{Name: "runner", Home: "/Users/../runner"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isRemovableHome(&tt.user))
+		})
+	}
+}
+
+func TestSysadminctlError(t *testing.T) {
+	stderr := strings.Join([]string{
+		"2023-10-14 12:00:00.000 sysadminctl[1234:5678] Creating user record…",
+		"2023-10-14 12:00:00.000 sysadminctl[1234:5678] ----------------------------",
+		"2023-10-14 12:00:00.000 sysadminctl[1234:5678] Error:-14135 <no underlying error>",
+	}, "\n")
+
+	assert.Equal(t, "2023-10-14 12:00:00.000 sysadminctl[1234:5678] Error:-14135 <no underlying error>", sysadminctlError(stderr))
+	assert.Empty(t, sysadminctlError("2023-10-14 12:00:00.000 sysadminctl[1234:5678] Creating user record…\n"))
+}
+
+func TestGeneratePassword(t *testing.T) {
+	a, err := GeneratePassword(24)
+	assert.NoError(t, err)
+	b, err := GeneratePassword(24)
+	assert.NoError(t, err)
+
+	assert.Len(t, a, 24)
+	assert.NotEqual(t, a, b)
+	for _, c := range a {
+		assert.True(t, strings.ContainsRune(passwordAlphabet, c))
+	}
+}
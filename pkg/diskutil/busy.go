@@ -0,0 +1,167 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// Holder is a process that has a disk's device node open.
+type Holder struct {
+	// PID is the process's ID.
+	PID int `json:"pid"`
+	// Command is the name of the process's command.
+	Command string `json:"command"`
+	// Path is the device node that the process has open (e.g. "/dev/rdisk0s2").
+	Path string `json:"path"`
+}
+
+func (h Holder) String() string {
+	return fmt.Sprintf("%s (pid %d) on %s", h.Command, h.PID, h.Path)
+}
+
+// BusyError is returned when an operation fails because the disk is busy, with what was found to be using it.
+type BusyError struct {
+	// DeviceIdentifier is the disk that's busy.
+	DeviceIdentifier string
+	// Holders are the processes that have the disk's device nodes open.
+	Holders []Holder
+	// Mounted are the volumes on the disk (other than the system's) that are mounted.
+	Mounted []string
+	// Err is the operation's failure.
+	Err error
+}
+
+func (e *BusyError) Error() string {
+	var users []string
+	if len(e.Holders) > 0 {
+		holders := make([]string, 0, len(e.Holders))
+		for _, h := range e.Holders {
+			holders = append(holders, h.String())
+		}
+		users = append(users, "held open by "+strings.Join(holders, ", "))
+	}
+	if len(e.Mounted) > 0 {
+		users = append(users, "mounted volumes "+strings.Join(e.Mounted, ", "))
+	}
+
+	return fmt.Sprintf("disk [%s] is in use (%s): %v", e.DeviceIdentifier, strings.Join(users, "; "), e.Err)
+}
+
+func (e *BusyError) Unwrap() error {
+	return e.Err
+}
+
+// busyReport is what was found to be using a disk before it's mutated.
+type busyReport struct {
+	holders []Holder
+	mounted []string
+}
+
+// empty is whether nothing was found to be using the disk.
+func (r busyReport) empty() bool {
+	return len(r.holders) == 0 && len(r.mounted) == 0
+}
+
+// findHolders finds the processes that have the device nodes open, it's replaced in tests.
+var findHolders = FindHolders
+
+// FindHolders finds the processes that have any of the device nodes (e.g. "/dev/disk0") open with lsof(8).
+func FindHolders(ctx context.Context, paths []string) ([]Holder, error) {
+	// cmdLsof represents the command used for executing lsof to list the processes that have the paths open.
+	//   * -F pcn - print the process ID, command name, and file name fields in lsof's machine readable format
+	//   * paths - the device nodes to find open files for
+	cmdLsof := append([]string{"lsof", "-F", "pcn", "--"}, paths...)
+
+	out, err := util.ExecuteCommand(ctx, cmdLsof, "", nil, nil)
+	// lsof exits with 1 when none of the paths are open (or some don't exist), which isn't an error here
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("diskutil: failed to run lsof, stderr: [%s]: %w", out.Stderr, err)
+	}
+
+	return parseHolders(out.Stdout), nil
+}
+
+// parseHolders parses the output of "lsof -F pcn". Each process's "p" (process ID) and "c" (command) fields are
+// followed by an "n" (name) field for each of the files it has open.
+func parseHolders(out string) []Holder {
+	var holders []Holder
+	var current Holder
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+
+		value := line[1:]
+		switch line[0] {
+		case 'p':
+			pid, err := strconv.Atoi(value)
+			if err != nil {
+				continue
+			}
+			current = Holder{PID: pid}
+		case 'c':
+			current.Command = value
+		case 'n':
+			h := current
+			h.Path = value
+			holders = append(holders, h)
+		}
+	}
+
+	return holders
+}
+
+// detectBusy finds what's using the physical disk and the container on it, which makes resizing the container fail
+// with "Resource busy". Processes that have the device nodes of the disk, its partitions, the container, or the
+// container's volumes open are holders. Partitions on the disk other than the container's physical store, and the
+// container's volumes mounted outside of the system's paths, are reported as mounted.
+func detectBusy(ctx context.Context, partitions *types.SystemPartitions, phy, container *types.DiskInfo) (busyReport, error) {
+	var report busyReport
+
+	stores := make(map[string]bool, len(container.APFSPhysicalStores))
+	for _, store := range container.APFSPhysicalStores {
+		stores[store.DeviceIdentifier] = true
+	}
+
+	ids := []string{phy.DeviceIdentifier}
+	for _, part := range partitions.AllDisksAndPartitions {
+		switch {
+		case part.DeviceIdentifier == phy.DeviceIdentifier:
+			for _, p := range part.Partitions {
+				ids = append(ids, p.DeviceIdentifier)
+				if p.MountPoint != "" && !stores[p.DeviceIdentifier] {
+					report.mounted = append(report.mounted, p.DeviceIdentifier)
+				}
+			}
+		case part.DeviceIdentifier == container.DeviceIdentifier && part.DeviceIdentifier != phy.DeviceIdentifier:
+			ids = append(ids, part.DeviceIdentifier)
+			for _, v := range part.APFSVolumes {
+				ids = append(ids, v.DeviceIdentifier)
+				if strings.HasPrefix(v.MountPoint, "/Volumes/") {
+					report.mounted = append(report.mounted, v.DeviceIdentifier)
+				}
+			}
+		}
+	}
+
+	paths := make([]string, 0, 2*len(ids))
+	for _, id := range ids {
+		paths = append(paths, "/dev/"+id, "/dev/r"+id)
+	}
+
+	holders, err := findHolders(ctx, paths)
+	if err != nil {
+		return report, err
+	}
+	report.holders = holders
+
+	return report, nil
+}
@@ -0,0 +1,161 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mock_diskutil "github.com/aws/ec2-macos-utils/pkg/diskutil/mocks"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubHolders makes findHolders return the holders for the rest of the test, recording the paths it's given.
+func stubHolders(t *testing.T, holders []Holder) *[]string {
+	var searched []string
+	prev := findHolders
+	findHolders = func(ctx context.Context, paths []string) ([]Holder, error) {
+		searched = paths
+		return holders, nil
+	}
+	t.Cleanup(func() { findHolders = prev })
+
+	return &searched
+}
+
+// busyTestParts are the partitions of a physical disk (disk0) with an APFS container (disk2) on its second
+// partition, and a mounted data partition after it.
+var busyTestParts = types.SystemPartitions{
+	AllDisksAndPartitions: []types.DiskPart{
+		{
+			DeviceIdentifier: "disk0",
+			Size:             3_000_000_000,
+			Partitions: []types.Partition{
+				{DeviceIdentifier: "disk0s1", Size: 100_000},
+				{DeviceIdentifier: "disk0s2", Size: 1_000_000_000},
+				{DeviceIdentifier: "disk0s3", Size: 100_000, MountPoint: "/Volumes/Data"},
+			},
+		},
+		{
+			DeviceIdentifier:   "disk2",
+			APFSPhysicalStores: []types.APFSPhysicalStoreID{{DeviceIdentifier: "disk0s2"}},
+			APFSVolumes: []types.APFSVolume{
+				{DeviceIdentifier: "disk2s1", MountPoint: "/"},
+				{DeviceIdentifier: "disk2s2", MountPoint: "/Volumes/Scratch"},
+			},
+		},
+	},
+}
+
+// busyTestContainer is the APFS container in busyTestParts.
+var busyTestContainer = types.DiskInfo{
+	APFSPhysicalStores: []types.APFSPhysicalStore{{DeviceIdentifier: "disk0s2"}},
+	ContainerInfo:      types.ContainerInfo{FilesystemType: "apfs"},
+	DeviceIdentifier:   "disk2",
+	ParentWholeDisk:    "disk0",
+}
+
+// busyTestPhysical is the physical disk in busyTestParts.
+var busyTestPhysical = types.DiskInfo{
+	APFSPhysicalStores: []types.APFSPhysicalStore{{DeviceIdentifier: "disk0s2"}},
+	DeviceIdentifier:   "disk0",
+	VirtualOrPhysical:  "Physical",
+}
+
+func TestParseHolders(t *testing.T) {
+	out := "p123\ncfsck_apfs\nn/dev/rdisk0s2\np456\ncdd\nn/dev/disk0\nn/dev/rdisk0\n"
+
+	holders := parseHolders(out)
+
+	assert.Equal(t, []Holder{
+		{PID: 123, Command: "fsck_apfs", Path: "/dev/rdisk0s2"},
+		{PID: 456, Command: "dd", Path: "/dev/disk0"},
+		{PID: 456, Command: "dd", Path: "/dev/rdisk0"},
+	}, holders)
+	assert.Empty(t, parseHolders(""), "should parse no holders without output")
+}
+
+func TestDetectBusy(t *testing.T) {
+	holders := []Holder{{PID: 123, Command: "fsck_apfs", Path: "/dev/rdisk2s2"}}
+	searched := stubHolders(t, holders)
+	report, err := detectBusy(context.Background(), &busyTestParts, &busyTestPhysical, &busyTestContainer)
+
+	assert.NoError(t, err)
+	assert.Equal(t, holders, report.holders)
+	assert.Equal(t, []string{"disk0s3", "disk2s2"}, report.mounted, "should report the volumes mounted outside of the system's paths")
+	assert.Contains(t, *searched, "/dev/rdisk0", "should search the physical disk")
+	assert.Contains(t, *searched, "/dev/disk2s1", "should search the container's volumes")
+}
+
+func TestGrowContainer_BusyError(t *testing.T) {
+	var ctx = context.Background()
+	stubHolders(t, []Holder{{PID: 123, Command: "fsck_apfs", Path: "/dev/rdisk2s2"}})
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	resizeErr := newCommandError("Error: -69877: Couldn't open device (Resource busy)", errors.New("exit status 1"))
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	gomock.InOrder(
+		mockUtility.EXPECT().Info(ctx, "disk0").Return(&busyTestPhysical, nil),
+		mockUtility.EXPECT().RepairDisk(ctx, "disk0").Return("", nil),
+		mockUtility.EXPECT().List(ctx, nil).Return(&busyTestParts, nil),
+		mockUtility.EXPECT().ResizeContainer(ctx, "disk0", "0").Return("", resizeErr),
+	)
+
+	container := busyTestContainer
+	err := GrowContainer(ctx, mockUtility, &container)
+
+	var busyErr *BusyError
+	assert.True(t, errors.As(err, &busyErr), "should report what's using the disk")
+	assert.Equal(t, "disk0", busyErr.DeviceIdentifier)
+	assert.Len(t, busyErr.Holders, 1)
+	assert.Equal(t, []string{"disk0s3", "disk2s2"}, busyErr.Mounted)
+	assert.Equal(t, ClassBusy, Classify(err), "should still be classified as busy")
+}
+
+func TestGrowContainer_WithUnmountBusy(t *testing.T) {
+	var ctx = context.Background()
+	stubHolders(t, nil)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	gomock.InOrder(
+		mockUtility.EXPECT().Info(ctx, "disk0").Return(&busyTestPhysical, nil),
+		mockUtility.EXPECT().RepairDisk(ctx, "disk0").Return("", nil),
+		mockUtility.EXPECT().List(ctx, nil).Return(&busyTestParts, nil),
+		mockUtility.EXPECT().Unmount(ctx, "disk0s3").Return("", nil),
+		mockUtility.EXPECT().Unmount(ctx, "disk2s2").Return("", nil),
+		mockUtility.EXPECT().ResizeContainer(ctx, "disk0", "0").Return("", nil),
+	)
+
+	container := busyTestContainer
+	err := GrowContainer(ctx, mockUtility, &container, WithUnmountBusy())
+
+	assert.NoError(t, err, "should be able to grow container after unmounting the volumes using it")
+}
+
+func TestGrowContainer_WithUnmountBusyErr(t *testing.T) {
+	var ctx = context.Background()
+	stubHolders(t, nil)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	gomock.InOrder(
+		mockUtility.EXPECT().Info(ctx, "disk0").Return(&busyTestPhysical, nil),
+		mockUtility.EXPECT().RepairDisk(ctx, "disk0").Return("", nil),
+		mockUtility.EXPECT().List(ctx, nil).Return(&busyTestParts, nil),
+		mockUtility.EXPECT().Unmount(ctx, "disk0s3").Return("", errors.New("error")),
+	)
+
+	container := busyTestContainer
+	err := GrowContainer(ctx, mockUtility, &container, WithUnmountBusy())
+
+	assert.Error(t, err, "shouldn't resize the container when the volumes using it can't be unmounted")
+}
@@ -0,0 +1,207 @@
+package diskutil
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+)
+
+// Type assertion to ensure cachingWrapper implements the DiskUtil interface.
+var _ DiskUtil = (*cachingWrapper)(nil)
+
+// cachingWrapper provides a typed implementation for DiskUtil that caches the results of List and Info.
+type cachingWrapper struct {
+	// impl is the DiskUtil implementation whose results are cached.
+	impl DiskUtil
+	// ttl is how long results are cached for.
+	ttl time.Duration
+	// now gets the time that results are cached at.
+	now func() time.Time
+
+	mu    sync.Mutex
+	lists map[string]cacheEntry
+	infos map[string]cacheEntry
+}
+
+// cacheEntry is a cached result, encoded so that callers modifying their results can't change the cache.
+type cacheEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// Cached takes a DiskUtil implementation and caches the results of its List and Info methods for the ttl. The whole
+// cache is invalidated by the mutating methods, whether or not they succeed, since they can change any disk's
+// information. Failures are never cached.
+//
+// Changes made outside of the DiskUtil (e.g. attaching an EBS volume) are only seen once the cached results
+// expire, so the ttl should be short.
+func Cached(impl DiskUtil, ttl time.Duration) *cachingWrapper {
+	return &cachingWrapper{
+		impl:  impl,
+		ttl:   ttl,
+		now:   time.Now,
+		lists: make(map[string]cacheEntry),
+		infos: make(map[string]cacheEntry),
+	}
+}
+
+// Invalidate removes all cached results.
+func (c *cachingWrapper) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lists = make(map[string]cacheEntry)
+	c.infos = make(map[string]cacheEntry)
+}
+
+// entries gets the maps holding the cached results. Results are put in the maps that were current when their command
+// started so that a result fetched while the cache is invalidated isn't cached past the invalidation.
+func (c *cachingWrapper) entries() (lists, infos map[string]cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lists, c.infos
+}
+
+// get decodes the cached result for the key into v, if there's one that hasn't expired.
+func (c *cachingWrapper) get(cache map[string]cacheEntry, key string, v interface{}) bool {
+	c.mu.Lock()
+	entry, ok := cache[key]
+	c.mu.Unlock()
+	if !ok || !c.now().Before(entry.expires) {
+		return false
+	}
+
+	return json.Unmarshal(entry.data, v) == nil
+}
+
+// put caches the result for the key.
+func (c *cachingWrapper) put(cache map[string]cacheEntry, key string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cache[key] = cacheEntry{data: data, expires: c.now().Add(c.ttl)}
+}
+
+func (c *cachingWrapper) Info(ctx context.Context, id string) (*types.DiskInfo, error) {
+	_, infos := c.entries()
+	var disk types.DiskInfo
+	if c.get(infos, id, &disk) {
+		return &disk, nil
+	}
+
+	info, err := c.impl.Info(ctx, id)
+	if err != nil {
+		return info, err
+	}
+	c.put(infos, id, info)
+
+	return info, nil
+}
+
+func (c *cachingWrapper) List(ctx context.Context, args []string) (*types.SystemPartitions, error) {
+	key := strings.Join(args, "\x00")
+	lists, _ := c.entries()
+	var partitions types.SystemPartitions
+	if c.get(lists, key, &partitions) {
+		return &partitions, nil
+	}
+
+	list, err := c.impl.List(ctx, args)
+	if err != nil {
+		return list, err
+	}
+	c.put(lists, key, list)
+
+	return list, nil
+}
+
+func (c *cachingWrapper) ResizeContainer(ctx context.Context, id string, size string) (string, error) {
+	c.Invalidate()
+	defer c.Invalidate()
+
+	return c.impl.ResizeContainer(ctx, id, size)
+}
+
+func (c *cachingWrapper) CreateContainer(ctx context.Context, id string) (string, error) {
+	c.Invalidate()
+	defer c.Invalidate()
+
+	return c.impl.CreateContainer(ctx, id)
+}
+
+func (c *cachingWrapper) AddVolume(ctx context.Context, id string, format string, name string, passphrase string) (string, error) {
+	c.Invalidate()
+	defer c.Invalidate()
+
+	return c.impl.AddVolume(ctx, id, format, name, passphrase)
+}
+
+func (c *cachingWrapper) UnlockVolume(ctx context.Context, id string, passphrase string) (string, error) {
+	c.Invalidate()
+	defer c.Invalidate()
+
+	return c.impl.UnlockVolume(ctx, id, passphrase)
+}
+
+func (c *cachingWrapper) DeleteContainer(ctx context.Context, id string) (string, error) {
+	c.Invalidate()
+	defer c.Invalidate()
+
+	return c.impl.DeleteContainer(ctx, id)
+}
+
+func (c *cachingWrapper) RepairDisk(ctx context.Context, id string) (string, error) {
+	c.Invalidate()
+	defer c.Invalidate()
+
+	return c.impl.RepairDisk(ctx, id)
+}
+
+// ResetUserPermissions only changes the files on the volume, which isn't part of the cached disk information.
+func (c *cachingWrapper) ResetUserPermissions(ctx context.Context, id string, uid int) (string, error) {
+	return c.impl.ResetUserPermissions(ctx, id, uid)
+}
+
+func (c *cachingWrapper) EraseDisk(ctx context.Context, format string, name string, id string) (string, error) {
+	c.Invalidate()
+	defer c.Invalidate()
+
+	return c.impl.EraseDisk(ctx, format, name, id)
+}
+
+func (c *cachingWrapper) NewFS(ctx context.Context, format string, name string, id string) (string, error) {
+	c.Invalidate()
+	defer c.Invalidate()
+
+	return c.impl.NewFS(ctx, format, name, id)
+}
+
+func (c *cachingWrapper) Mount(ctx context.Context, id string, mountPoint string) (string, error) {
+	c.Invalidate()
+	defer c.Invalidate()
+
+	return c.impl.Mount(ctx, id, mountPoint)
+}
+
+func (c *cachingWrapper) Unmount(ctx context.Context, id string) (string, error) {
+	c.Invalidate()
+	defer c.Invalidate()
+
+	return c.impl.Unmount(ctx, id)
+}
+
+func (c *cachingWrapper) UnmountDisk(ctx context.Context, id string) (string, error) {
+	c.Invalidate()
+	defer c.Invalidate()
+
+	return c.impl.UnmountDisk(ctx, id)
+}
@@ -0,0 +1,171 @@
+package diskutil
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	mock_diskutil "github.com/aws/ec2-macos-utils/pkg/diskutil/mocks"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCached_Info(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	mockUtility.EXPECT().Info(ctx, "disk0").Return(&types.DiskInfo{DeviceIdentifier: "disk0"}, nil).Times(1)
+	mockUtility.EXPECT().Info(ctx, "disk1").Return(&types.DiskInfo{DeviceIdentifier: "disk1"}, nil).Times(1)
+
+	c := Cached(mockUtility, time.Minute)
+
+	first, err := c.Info(ctx, "disk0")
+	assert.NoError(t, err)
+	first.DeviceIdentifier = "changed"
+
+	second, err := c.Info(ctx, "disk0")
+	assert.NoError(t, err)
+	assert.Equal(t, "disk0", second.DeviceIdentifier, "callers shouldn't be able to change cached results")
+
+	other, err := c.Info(ctx, "disk1")
+	assert.NoError(t, err)
+	assert.Equal(t, "disk1", other.DeviceIdentifier)
+}
+
+func TestCached_List(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	mockUtility.EXPECT().List(ctx, nil).Return(&types.SystemPartitions{AllDisks: []string{"disk0"}}, nil).Times(1)
+	mockUtility.EXPECT().List(ctx, []string{"physical"}).Return(&types.SystemPartitions{AllDisks: []string{"disk0", "disk4"}}, nil).Times(1)
+
+	c := Cached(mockUtility, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		all, err := c.List(ctx, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"disk0"}, all.AllDisks)
+
+		physical, err := c.List(ctx, []string{"physical"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"disk0", "disk4"}, physical.AllDisks)
+	}
+}
+
+func TestCached_Expires(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	mockUtility.EXPECT().Info(ctx, "disk0").Return(&types.DiskInfo{DeviceIdentifier: "disk0"}, nil).Times(2)
+
+	now := time.Now()
+	c := Cached(mockUtility, time.Minute)
+	c.now = func() time.Time { return now }
+
+	_, err := c.Info(ctx, "disk0")
+	assert.NoError(t, err)
+
+	now = now.Add(30 * time.Second)
+	_, err = c.Info(ctx, "disk0")
+	assert.NoError(t, err)
+
+	now = now.Add(30 * time.Second)
+	_, err = c.Info(ctx, "disk0")
+	assert.NoError(t, err)
+}
+
+func TestCached_NoErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	gomock.InOrder(
+		mockUtility.EXPECT().Info(ctx, "disk0").Return(nil, fmt.Errorf("error")),
+		mockUtility.EXPECT().Info(ctx, "disk0").Return(&types.DiskInfo{DeviceIdentifier: "disk0"}, nil),
+	)
+
+	c := Cached(mockUtility, time.Minute)
+
+	_, err := c.Info(ctx, "disk0")
+	assert.Error(t, err)
+
+	disk, err := c.Info(ctx, "disk0")
+	assert.NoError(t, err)
+	assert.Equal(t, "disk0", disk.DeviceIdentifier)
+}
+
+func TestCached_Invalidate(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		expect func(m *mock_diskutil.MockDiskUtil)
+		mutate func(d DiskUtil) (string, error)
+	}{
+		{
+			name:   "ResizeContainer",
+			expect: func(m *mock_diskutil.MockDiskUtil) { m.EXPECT().ResizeContainer(ctx, "disk1", "0").Return("", nil) },
+			mutate: func(d DiskUtil) (string, error) { return d.ResizeContainer(ctx, "disk1", "0") },
+		},
+		{
+			name:   "RepairDisk",
+			expect: func(m *mock_diskutil.MockDiskUtil) { m.EXPECT().RepairDisk(ctx, "disk0").Return("", nil) },
+			mutate: func(d DiskUtil) (string, error) { return d.RepairDisk(ctx, "disk0") },
+		},
+		{
+			name: "EraseDisk",
+			expect: func(m *mock_diskutil.MockDiskUtil) {
+				m.EXPECT().EraseDisk(ctx, "APFS", "Data", "disk0").Return("", nil)
+			},
+			mutate: func(d DiskUtil) (string, error) { return d.EraseDisk(ctx, "APFS", "Data", "disk0") },
+		},
+		{
+			name:   "NewFS",
+			expect: func(m *mock_diskutil.MockDiskUtil) { m.EXPECT().NewFS(ctx, "APFS", "Data", "disk0").Return("", nil) },
+			mutate: func(d DiskUtil) (string, error) { return d.NewFS(ctx, "APFS", "Data", "disk0") },
+		},
+		{
+			name:   "Mount",
+			expect: func(m *mock_diskutil.MockDiskUtil) { m.EXPECT().Mount(ctx, "disk0", "/Volumes/Data").Return("", nil) },
+			mutate: func(d DiskUtil) (string, error) { return d.Mount(ctx, "disk0", "/Volumes/Data") },
+		},
+		{
+			name:   "Unmount failure",
+			expect: func(m *mock_diskutil.MockDiskUtil) { m.EXPECT().Unmount(ctx, "disk0").Return("", fmt.Errorf("error")) },
+			mutate: func(d DiskUtil) (string, error) { return d.Unmount(ctx, "disk0") },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+			mockUtility.EXPECT().Info(ctx, "disk0").Return(&types.DiskInfo{DeviceIdentifier: "disk0"}, nil).Times(2)
+			mockUtility.EXPECT().List(ctx, nil).Return(&types.SystemPartitions{}, nil).Times(2)
+			tt.expect(mockUtility)
+
+			c := Cached(mockUtility, time.Minute)
+			_, err := c.Info(ctx, "disk0")
+			assert.NoError(t, err)
+			_, err = c.List(ctx, nil)
+			assert.NoError(t, err)
+
+			_, _ = tt.mutate(c)
+
+			_, err = c.Info(ctx, "disk0")
+			assert.NoError(t, err)
+			_, err = c.List(ctx, nil)
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package diskutil
+
+import (
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// ReleaseCapabilities describes what diskutil and the related storage tools support on a macOS release so that
+// callers can check for features instead of comparing releases.
+type ReleaseCapabilities struct {
+	// ListPhysicalStores is whether the list and info verbs report the physical stores of APFS volumes. The
+	// DiskUtil fetches them separately when they aren't reported (i.e. on Mojave).
+	ListPhysicalStores bool `json:"list_physical_stores"`
+	// SnapshotsPlist is whether "apfs listSnapshots" can generate plist output.
+	SnapshotsPlist bool `json:"snapshots_plist"`
+	// ResizeLimits is whether "apfs resizeContainer" accepts "limits" in place of a size to report how far the
+	// container can be resized.
+	ResizeLimits bool `json:"resize_limits"`
+	// ReadOnlySystemVolume is whether the system is installed on a read-only volume, separate from the data
+	// volume, so that paths at the root of the filesystem can only be created with synthetic.conf(5).
+	ReadOnlySystemVolume bool `json:"read_only_system_volume"`
+	// SyntheticStitch is whether apfs.util can create synthetic objects without waiting for a reboot.
+	SyntheticStitch bool `json:"synthetic_stitch"`
+	// SignedSystemVolume is whether the system volume is sealed and booted from a snapshot.
+	SignedSystemVolume bool `json:"signed_system_volume"`
+}
+
+// Capabilities gets the capabilities of the product's release. An UnknownReleaseError is returned for releases
+// that ForProduct doesn't support either.
+func Capabilities(p *system.Product) (ReleaseCapabilities, error) {
+	c := ReleaseCapabilities{
+		ListPhysicalStores:   true,
+		SnapshotsPlist:       true,
+		ResizeLimits:         true,
+		ReadOnlySystemVolume: true,
+		SyntheticStitch:      true,
+		SignedSystemVolume:   true,
+	}
+
+	switch p.Release {
+	case system.Mojave:
+		c.ListPhysicalStores = false
+		c.ReadOnlySystemVolume = false
+		c.SyntheticStitch = false
+		c.SignedSystemVolume = false
+	case system.Catalina:
+		c.SyntheticStitch = false
+		c.SignedSystemVolume = false
+	case system.BigSur, system.Monterey, system.Ventura, system.Sonoma:
+	default:
+		return ReleaseCapabilities{}, &UnknownReleaseError{Product: *p}
+	}
+
+	return c, nil
+}
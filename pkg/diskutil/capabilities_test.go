@@ -0,0 +1,54 @@
+package diskutil
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+func TestCapabilities(t *testing.T) {
+	mojave, err := Capabilities(&system.Product{Release: system.Mojave, Version: *semver.MustParse("10.14.6")})
+	assert.NoError(t, err)
+	assert.False(t, mojave.ListPhysicalStores)
+	assert.False(t, mojave.ReadOnlySystemVolume)
+
+	catalina, err := Capabilities(&system.Product{Release: system.Catalina, Version: *semver.MustParse("10.15.7")})
+	assert.NoError(t, err)
+	assert.True(t, catalina.ReadOnlySystemVolume)
+	assert.False(t, catalina.SyntheticStitch, "Catalina only creates synthetic objects when booting")
+
+	sonoma, err := Capabilities(&system.Product{Release: system.Sonoma, Version: *semver.MustParse("14.1.0")})
+	assert.NoError(t, err)
+	assert.Equal(t, ReleaseCapabilities{
+		ListPhysicalStores:   true,
+		SnapshotsPlist:       true,
+		ResizeLimits:         true,
+		ReadOnlySystemVolume: true,
+		SyntheticStitch:      true,
+		SignedSystemVolume:   true,
+	}, sonoma)
+}
+
+func TestCapabilities_UnknownRelease(t *testing.T) {
+	_, err := Capabilities(&system.Product{Release: system.CompatMode, Version: *semver.MustParse("10.16.0")})
+
+	var releaseErr *UnknownReleaseError
+	assert.True(t, errors.As(err, &releaseErr))
+}
+
+func TestCapabilities_MatchForProduct(t *testing.T) {
+	for _, r := range []system.Release{system.Mojave, system.Catalina, system.BigSur, system.Monterey, system.Ventura, system.Sonoma} {
+		p := &system.Product{Release: r}
+		caps, err := Capabilities(p)
+		assert.NoError(t, err)
+
+		u, err := ForProduct(p)
+		assert.NoError(t, err)
+		_, fetchesStores := u.(*diskutilMojave)
+		assert.Equal(t, !caps.ListPhysicalStores, fetchesStores, "%s should only fetch physical stores separately when they aren't listed", r)
+	}
+}
@@ -0,0 +1,164 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/identifier"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+)
+
+// CreateContainer creates an empty APFS container on the whole disk or partition with the given device identifier
+// and gets the new container's information. Volumes can then be added to the container. Before creating it, the
+// device is checked to make sure it isn't on the boot disk or the host's internal SSD, and that it doesn't hold any
+// data: whole disks must be blank, and partitions must not be mounted or be the physical store of another container.
+//
+// In dry-run mode the container isn't created and nil is returned for its information.
+func CreateContainer(ctx context.Context, u DiskUtil, deviceID string) (*types.DiskInfo, error) {
+	device, err := u.Info(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get device information: %w", err)
+	}
+	disk := device
+	if !device.WholeDisk {
+		if disk, err = u.Info(ctx, identifier.ParseDiskID(device.ParentWholeDisk)); err != nil {
+			return nil, fmt.Errorf("unable to get disk information: %w", err)
+		}
+	}
+
+	logrus.WithField("device_id", device.DeviceIdentifier).Info("Checking that device isn't on the boot disk...")
+	if err := AssertNotBootDisk(ctx, u, disk); err != nil {
+		return nil, err
+	}
+	if err := AssertNotInternalDisk(disk); err != nil {
+		return nil, err
+	}
+
+	partitions, err := u.List(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list partitions: %w", err)
+	}
+	switch {
+	case device.WholeDisk && !isBlankDisk(partitions, device.DeviceIdentifier):
+		return nil, fmt.Errorf("device [%s] is not blank, refusing to erase", device.DeviceIdentifier)
+	case device.MountPoint != "":
+		return nil, fmt.Errorf("device [%s] is mounted at %s, refusing to erase", device.DeviceIdentifier, device.MountPoint)
+	case findStoredContainer(partitions, device.DeviceIdentifier) != nil:
+		return nil, fmt.Errorf("device [%s] is already an APFS physical store", device.DeviceIdentifier)
+	}
+
+	logrus.WithField("device_id", device.DeviceIdentifier).Info("Creating APFS container...")
+	out, err := u.CreateContainer(ctx, device.DeviceIdentifier)
+	logrus.WithField("out", out).Debug("CreateContainer output")
+	if errors.Is(err, ErrReadOnly) {
+		logrus.WithError(err).Warn("Would have created container")
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// The new container is found through its physical store, which is the partition or one of the disk's partitions
+	partitions, err = u.List(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list partitions: %w", err)
+	}
+	container := findStoredContainer(partitions, device.DeviceIdentifier)
+	if container == nil {
+		return nil, fmt.Errorf("cannot find container created on [%s]", device.DeviceIdentifier)
+	}
+	logrus.WithFields(logrus.Fields{
+		"device_id":    device.DeviceIdentifier,
+		"container_id": container.DeviceIdentifier,
+	}).Info("Successfully created container")
+
+	return u.Info(ctx, container.DeviceIdentifier)
+}
+
+// DeleteContainer deletes the APFS container with the given device identifier, or stored on the given physical
+// store, along with all of its volumes, leaving its physical stores as free space. Before deleting it, the container
+// is checked to make sure it isn't the boot container and that none of its physical stores are on the boot disk or
+// the host's internal SSD, which also hold the containers that the host needs to boot and recover (e.g. the
+// iBoot System Container on Apple silicon).
+//
+// In dry-run mode the container isn't deleted.
+func DeleteContainer(ctx context.Context, u DiskUtil, containerID string) error {
+	partitions, err := u.List(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot list partitions: %w", err)
+	}
+	container := findContainer(partitions, strings.TrimPrefix(containerID, "/dev/"))
+	if container == nil {
+		return fmt.Errorf("device [%s] is not an APFS container or physical store", containerID)
+	}
+
+	logrus.WithField("container_id", container.DeviceIdentifier).Info("Checking that container isn't on the boot disk...")
+	root, err := u.Info(ctx, "/")
+	if err != nil {
+		return fmt.Errorf("unable to identify boot container: %w", err)
+	}
+	if strings.EqualFold(identifier.ParseDiskID(root.ParentWholeDisk), container.DeviceIdentifier) {
+		return fmt.Errorf("container [%s] is the boot container", container.DeviceIdentifier)
+	}
+	for _, store := range container.APFSPhysicalStores {
+		disk, err := u.Info(ctx, identifier.ParseDiskID(store.DeviceIdentifier))
+		if err != nil {
+			return fmt.Errorf("unable to get physical store's disk information: %w", err)
+		}
+		if err := AssertNotBootDisk(ctx, u, disk); err != nil {
+			return fmt.Errorf("container [%s] is on the boot disk: %w", container.DeviceIdentifier, err)
+		}
+		if err := AssertNotInternalDisk(disk); err != nil {
+			return fmt.Errorf("container [%s] is on the internal SSD: %w", container.DeviceIdentifier, err)
+		}
+	}
+
+	logrus.WithField("container_id", container.DeviceIdentifier).Info("Deleting APFS container...")
+	out, err := u.DeleteContainer(ctx, container.DeviceIdentifier)
+	logrus.WithField("out", out).Debug("DeleteContainer output")
+	if errors.Is(err, ErrReadOnly) {
+		logrus.WithError(err).Warn("Would have deleted container")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	logrus.WithField("container_id", container.DeviceIdentifier).Info("Successfully deleted container")
+
+	return nil
+}
+
+// findContainer finds the container with the device identifier, or whose physical store has the device identifier.
+func findContainer(partitions *types.SystemPartitions, id string) *types.DiskPart {
+	for i, part := range partitions.AllDisksAndPartitions {
+		if len(part.APFSPhysicalStores) == 0 {
+			continue
+		}
+		if strings.EqualFold(part.DeviceIdentifier, id) {
+			return &partitions.AllDisksAndPartitions[i]
+		}
+		for _, store := range part.APFSPhysicalStores {
+			if strings.EqualFold(store.DeviceIdentifier, id) {
+				return &partitions.AllDisksAndPartitions[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+// findStoredContainer finds the container with a physical store on the device, which is either the physical store or
+// the whole disk that holds it.
+func findStoredContainer(partitions *types.SystemPartitions, id string) *types.DiskPart {
+	for i, part := range partitions.AllDisksAndPartitions {
+		for _, store := range part.APFSPhysicalStores {
+			if strings.EqualFold(store.DeviceIdentifier, id) || strings.EqualFold(identifier.ParseDiskID(store.DeviceIdentifier), id) {
+				return &partitions.AllDisksAndPartitions[i]
+			}
+		}
+	}
+
+	return nil
+}
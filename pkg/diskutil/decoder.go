@@ -1,10 +1,9 @@
 package diskutil
 
 import (
-	"fmt"
 	"io"
 
-	"github.com/aws/ec2-macos-utils/internal/diskutil/types"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
 
 	"howett.net/plist"
 )
@@ -32,7 +31,7 @@ func (d *PlistDecoder) DecodeSystemPartitions(reader io.ReadSeeker) (*types.Syst
 	// Decode the plist output from diskutil into a SystemPartitions struct for easier access
 	err := decoder.Decode(partitions)
 	if err != nil {
-		return nil, fmt.Errorf("error decoding list: %w", err)
+		return nil, &DecodeError{Kind: "list", Err: err}
 	}
 
 	return partitions, nil
@@ -47,7 +46,7 @@ func (d *PlistDecoder) DecodeDiskInfo(reader io.ReadSeeker) (*types.DiskInfo, er
 	// Decode the plist output from diskutil into a DiskInfo struct for easier access
 	err := decoder.Decode(disk)
 	if err != nil {
-		return nil, fmt.Errorf("error decoding disk info: %w", err)
+		return nil, &DecodeError{Kind: "disk info", Err: err}
 	}
 
 	return disk, nil
@@ -2,10 +2,11 @@ package diskutil
 
 import (
 	_ "embed"
+	"errors"
 	"strings"
 	"testing"
 
-	"github.com/aws/ec2-macos-utils/internal/diskutil/types"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -54,7 +55,10 @@ func TestPlistDecoder_DecodeDiskInfo_WithoutPlistInput(t *testing.T) {
 
 	actualDisk, err := d.DecodeDiskInfo(reader)
 
+	var decodeErr *DecodeError
 	assert.Error(t, err, "shouldn't be able to decode non-plist input")
+	assert.True(t, errors.As(err, &decodeErr), "should get DecodeError since decode failed")
+	assert.Equal(t, "disk info", decodeErr.Kind)
 	assert.Nil(t, actualDisk, "should get nil since decode failed")
 }
 
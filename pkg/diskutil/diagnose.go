@@ -0,0 +1,77 @@
+package diskutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/pkg/unifiedlog"
+)
+
+const (
+	// diagnosticsPredicate selects the unified log entries from DiskManagement, which diskutil is built on, and from
+	// the APFS kernel extension, which logs the reason most container operations fail.
+	diagnosticsPredicate = `subsystem BEGINSWITH "com.apple.DiskManagement" OR subsystem == "com.apple.apfs" OR process == "diskmanagementd" OR senderImagePath ENDSWITH "/apfs"`
+
+	// diagnosticsTimeout is the maximum duration for collecting log entries after a failure.
+	diagnosticsTimeout = 30 * time.Second
+
+	// maxDiagnosticEntries is the maximum number of log entries attached to an error, keeping the latest ones since
+	// they're the closest to the failure.
+	maxDiagnosticEntries = 50
+)
+
+// DiagnosedError is a diskutil failure with the unified log entries that were logged while the command ran.
+type DiagnosedError struct {
+	// Err is the failure.
+	Err error
+	// Logs are the DiskManagement and APFS log entries from the failure window.
+	Logs []unifiedlog.Entry
+}
+
+func (e *DiagnosedError) Error() string {
+	lines := make([]string, 0, len(e.Logs))
+	for _, entry := range e.Logs {
+		lines = append(lines, "  "+entry.String())
+	}
+
+	return fmt.Sprintf("%s\nrelated system log entries:\n%s", e.Err, strings.Join(lines, "\n"))
+}
+
+func (e *DiagnosedError) Unwrap() error {
+	return e.Err
+}
+
+// diagnose attaches the log entries logged since start to err. A separate context is used for reading the log
+// since failures are often caused by the command's own context expiring. err is returned as-is when no entries can
+// be read.
+func diagnose(start time.Time, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout)
+	defer cancel()
+
+	// The window is padded since log timestamps only have a resolution of one second in queries.
+	entries, logErr := unifiedlog.Show(ctx, unifiedlog.Query{
+		Predicate: diagnosticsPredicate,
+		Start:     start.Add(-time.Second),
+		End:       time.Now().Add(time.Second),
+	})
+	if logErr != nil {
+		logrus.WithError(logErr).Debug("Unable to collect log entries for failure")
+		return err
+	}
+	if len(entries) == 0 {
+		return err
+	}
+	if len(entries) > maxDiagnosticEntries {
+		entries = entries[len(entries)-maxDiagnosticEntries:]
+	}
+
+	return &DiagnosedError{Err: err, Logs: entries}
+}
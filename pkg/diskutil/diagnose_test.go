@@ -0,0 +1,37 @@
+package diskutil
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/pkg/unifiedlog"
+)
+
+func TestDiagnosedError(t *testing.T) {
+	cause := FreeSpaceError{FreeSpaceBytes: 0}
+	err := &DiagnosedError{
+		Err: cause,
+		Logs: []unifiedlog.Entry{
+			{
+				Timestamp:        "2023-06-01 12:00:02.000001+0000",
+				ProcessImagePath: "/kernel",
+				Message:          "apfs_container_resize:3718: disk1 resize failed",
+			},
+		},
+	}
+	expected := "0 bytes available\n" +
+		"related system log entries:\n" +
+		"  2023-06-01 12:00:02.000001+0000 kernel apfs_container_resize:3718: disk1 resize failed"
+
+	assert.Equal(t, expected, err.Error())
+
+	var freeSpaceErr FreeSpaceError
+	assert.True(t, errors.As(err, &freeSpaceErr), "should unwrap to the failure")
+}
+
+func TestDiagnose_WithoutError(t *testing.T) {
+	assert.NoError(t, diagnose(time.Now(), nil))
+}
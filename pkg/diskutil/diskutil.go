@@ -1,7 +1,17 @@
 // Package diskutil provides the functionality necessary for interacting with macOS's diskutil CLI.
+//
+// ForProduct creates the DiskUtil for a macOS release, which decodes diskutil's output into the structs in the types
+// package and fills in what older releases don't report (e.g. the physical stores on Mojave). GrowContainer and
+// ProvisionVolume build the tool's operations on top of it. Operations publish their lifecycle to the events Bus in
+// their context (see events.NewContext) and report the progress of long-running commands to the progress Starter in
+// it (see progress.NewContext).
+//
+// The exported API of this package and its subpackages follows semantic versioning with the module's releases:
+// breaking changes are only made in new major versions. Fields are only added to the types as diskutil reports new
+// information.
 package diskutil
 
-//go:generate mockgen -destination mocks/mock_diskutil.go github.com/aws/ec2-macos-utils/internal/diskutil DiskUtil
+//go:generate mockgen -destination mocks/mock_diskutil.go github.com/aws/ec2-macos-utils/pkg/diskutil DiskUtil
 
 import (
 	"context"
@@ -9,8 +19,9 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/aws/ec2-macos-utils/internal/diskutil/types"
-	"github.com/aws/ec2-macos-utils/internal/system"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+	"github.com/aws/ec2-macos-utils/pkg/util"
 
 	"github.com/Masterminds/semver"
 )
@@ -26,33 +37,62 @@ var ErrReadOnly = errors.New("read-only mode")
 
 // FreeSpaceError defines an error to distinguish when there's not enough space to grow the specified container.
 type FreeSpaceError struct {
-	freeSpaceBytes uint64
+	// FreeSpaceBytes is the free space that was available to grow the container.
+	FreeSpaceBytes uint64
 }
 
 func (e FreeSpaceError) Error() string {
-	return fmt.Sprintf("%d bytes available", e.freeSpaceBytes)
+	return fmt.Sprintf("%d bytes available", e.FreeSpaceBytes)
 }
 
 // DiskUtil outlines the functionality necessary for wrapping macOS's diskutil tool.
 type DiskUtil interface {
 	// APFS outlines the functionality necessary for wrapping diskutil's "apfs" verb.
 	APFS
+	// EraseDisk erases the whole disk for the specified device identifier and formats it with a single volume.
+	// This process requires root access.
+	EraseDisk(ctx context.Context, format string, name string, id string) (string, error)
 	// Info fetches raw disk information for the specified device identifier.
 	Info(ctx context.Context, id string) (*types.DiskInfo, error)
 	// List fetches all disk and partition information for the system.
 	// This output will be filtered based on the args provided.
 	List(ctx context.Context, args []string) (*types.SystemPartitions, error)
+	// Mount mounts the volume for the specified device identifier at the given mount point.
+	Mount(ctx context.Context, id string, mountPoint string) (string, error)
+	// NewFS formats the whole disk for the specified device identifier with a single filesystem using the
+	// format's newfs tool directly, without writing a partition map. This process requires root access.
+	NewFS(ctx context.Context, format string, name string, id string) (string, error)
 	// RepairDisk attempts to repair the disk for the specified device identifier.
 	// This process requires root access.
 	RepairDisk(ctx context.Context, id string) (string, error)
+	// ResetUserPermissions resets the permissions of the files on the mounted volume for the specified device
+	// identifier so that they're owned by the user with the given UID. This process requires root access.
+	ResetUserPermissions(ctx context.Context, id string, uid int) (string, error)
+	// Unmount unmounts the volume for the specified device identifier.
+	Unmount(ctx context.Context, id string) (string, error)
+	// UnmountDisk unmounts all of the volumes on the whole disk for the specified device identifier.
+	UnmountDisk(ctx context.Context, id string) (string, error)
 }
 
 // APFS outlines the functionality necessary for wrapping diskutil's "apfs" verb.
 type APFS interface {
+	// AddVolume adds a volume, named name, with the given format to the APFS container with the given device
+	// identifier without mounting it. The volume is encrypted with the passphrase unless it's empty.
+	// This process requires root access.
+	AddVolume(ctx context.Context, id string, format string, name string, passphrase string) (string, error)
+	// CreateContainer creates an empty APFS container on the whole disk or partition with the given device
+	// identifier, erasing it. This process requires root access.
+	CreateContainer(ctx context.Context, id string) (string, error)
+	// DeleteContainer deletes the APFS container with the given device identifier, or stored on the given
+	// physical store, along with all of its volumes. This process requires root access.
+	DeleteContainer(ctx context.Context, id string) (string, error)
 	// ResizeContainer attempts to grow the APFS container with the given device identifier
 	// to the specified size. If the given size is 0, ResizeContainer will attempt to grow
 	// the disk to its maximum size.
 	ResizeContainer(ctx context.Context, id string, size string) (string, error)
+	// UnlockVolume unlocks and mounts the encrypted APFS volume with the given device identifier with the
+	// passphrase. This process requires root access.
+	UnlockVolume(ctx context.Context, id string, passphrase string) (string, error)
 }
 
 // readonlyWrapper provides a typed implementation for DiskUtil that substitutes mutating
@@ -66,6 +106,22 @@ func (r readonlyWrapper) ResizeContainer(ctx context.Context, id string, size st
 	return "", fmt.Errorf("skip resize container: %w", ErrReadOnly)
 }
 
+func (r readonlyWrapper) CreateContainer(ctx context.Context, id string) (string, error) {
+	return "", fmt.Errorf("skip create container: %w", ErrReadOnly)
+}
+
+func (r readonlyWrapper) DeleteContainer(ctx context.Context, id string) (string, error) {
+	return "", fmt.Errorf("skip delete container: %w", ErrReadOnly)
+}
+
+func (r readonlyWrapper) AddVolume(ctx context.Context, id string, format string, name string, passphrase string) (string, error) {
+	return "", fmt.Errorf("skip add volume: %w", ErrReadOnly)
+}
+
+func (r readonlyWrapper) UnlockVolume(ctx context.Context, id string, passphrase string) (string, error) {
+	return "", fmt.Errorf("skip unlock volume: %w", ErrReadOnly)
+}
+
 func (r readonlyWrapper) Info(ctx context.Context, id string) (*types.DiskInfo, error) {
 	return r.impl.Info(ctx, id)
 }
@@ -78,6 +134,30 @@ func (r readonlyWrapper) RepairDisk(ctx context.Context, id string) (string, err
 	return "", fmt.Errorf("skip repair disk: %w", ErrReadOnly)
 }
 
+func (r readonlyWrapper) ResetUserPermissions(ctx context.Context, id string, uid int) (string, error) {
+	return "", fmt.Errorf("skip reset user permissions: %w", ErrReadOnly)
+}
+
+func (r readonlyWrapper) EraseDisk(ctx context.Context, format string, name string, id string) (string, error) {
+	return "", fmt.Errorf("skip erase disk: %w", ErrReadOnly)
+}
+
+func (r readonlyWrapper) NewFS(ctx context.Context, format string, name string, id string) (string, error) {
+	return "", fmt.Errorf("skip newfs: %w", ErrReadOnly)
+}
+
+func (r readonlyWrapper) Mount(ctx context.Context, id string, mountPoint string) (string, error) {
+	return "", fmt.Errorf("skip mount: %w", ErrReadOnly)
+}
+
+func (r readonlyWrapper) Unmount(ctx context.Context, id string) (string, error) {
+	return "", fmt.Errorf("skip unmount: %w", ErrReadOnly)
+}
+
+func (r readonlyWrapper) UnmountDisk(ctx context.Context, id string) (string, error) {
+	return "", fmt.Errorf("skip unmount disk: %w", ErrReadOnly)
+}
+
 // Type assertion to ensure readonlyWrapper implements the DiskUtil interface.
 var _ DiskUtil = (*readonlyWrapper)(nil)
 
@@ -86,81 +166,84 @@ func Dryrun(impl DiskUtil) *readonlyWrapper {
 	return &readonlyWrapper{impl}
 }
 
-// ForProduct creates a new diskutil controller for the given product.
-func ForProduct(p *system.Product) (DiskUtil, error) {
+// ForProduct creates a new diskutil controller for the given product, customized by the options.
+func ForProduct(p *system.Product, opts ...Option) (DiskUtil, error) {
+	c := newConfig(opts)
+
 	switch p.Release {
 	case system.Mojave:
-		return newMojave(p.Version)
+		return newMojave(p.Version, c)
 	case system.Catalina:
-		return newCatalina(p.Version)
+		return newCatalina(p.Version, c)
 	case system.BigSur:
-		return newBigSur(p.Version)
+		return newBigSur(p.Version, c)
 	case system.Monterey:
-		return newMonterey(p.Version)
+		return newMonterey(p.Version, c)
 	case system.Ventura:
-		return newVentura(p.Version)
+		return newVentura(p.Version, c)
 	case system.Sonoma:
-		return newSonoma(p.Version)
+		return newSonoma(p.Version, c)
 	default:
-		return nil, errors.New("unknown release")
+		return nil, &UnknownReleaseError{Product: *p}
 	}
 }
 
 // newMojave configures the DiskUtil for the specified Mojave version.
-func newMojave(version semver.Version) (*diskutilMojave, error) {
+func newMojave(version semver.Version, c *config) (*diskutilMojave, error) {
 	du := &diskutilMojave{
-		embeddedDiskutil: &DiskUtilityCmd{},
-		dec:              &PlistDecoder{},
+		embeddedDiskutil: &DiskUtilityCmd{Executor: c.executor},
+		dec:              c.decoder,
+		exec:             c.executor,
 	}
 
 	return du, nil
 }
 
 // newCatalina configures the DiskUtil for the specified Catalina version.
-func newCatalina(version semver.Version) (*diskutilCatalina, error) {
+func newCatalina(version semver.Version, c *config) (*diskutilCatalina, error) {
 	du := &diskutilCatalina{
-		embeddedDiskutil: &DiskUtilityCmd{},
-		dec:              &PlistDecoder{},
+		embeddedDiskutil: &DiskUtilityCmd{Executor: c.executor},
+		dec:              c.decoder,
 	}
 
 	return du, nil
 }
 
 // newBigSur configures the DiskUtil for the specified Big Sur version.
-func newBigSur(version semver.Version) (*diskutilBigSur, error) {
+func newBigSur(version semver.Version, c *config) (*diskutilBigSur, error) {
 	du := &diskutilBigSur{
-		embeddedDiskutil: &DiskUtilityCmd{},
-		dec:              &PlistDecoder{},
+		embeddedDiskutil: &DiskUtilityCmd{Executor: c.executor},
+		dec:              c.decoder,
 	}
 
 	return du, nil
 }
 
 // newMonterey configures the DiskUtil for the specified Monterey version.
-func newMonterey(version semver.Version) (*diskutilMonterey, error) {
+func newMonterey(version semver.Version, c *config) (*diskutilMonterey, error) {
 	du := &diskutilMonterey{
-		embeddedDiskutil: &DiskUtilityCmd{},
-		dec:              &PlistDecoder{},
+		embeddedDiskutil: &DiskUtilityCmd{Executor: c.executor},
+		dec:              c.decoder,
 	}
 
 	return du, nil
 }
 
 // newVentura configures the DiskUtil for the specified Ventura version.
-func newVentura(version semver.Version) (*diskutilMonterey, error) {
+func newVentura(version semver.Version, c *config) (*diskutilMonterey, error) {
 	du := &diskutilMonterey{
-		embeddedDiskutil: &DiskUtilityCmd{},
-		dec:              &PlistDecoder{},
+		embeddedDiskutil: &DiskUtilityCmd{Executor: c.executor},
+		dec:              c.decoder,
 	}
 
 	return du, nil
 }
 
 // newSonoma configures the DiskUtil for the specified Sonoma version.
-func newSonoma(version semver.Version) (*diskutilSonoma, error) {
+func newSonoma(version semver.Version, c *config) (*diskutilSonoma, error) {
 	du := &diskutilSonoma{
-		embeddedDiskutil: &DiskUtilityCmd{},
-		dec:              &PlistDecoder{},
+		embeddedDiskutil: &DiskUtilityCmd{Executor: c.executor},
+		dec:              c.decoder,
 	}
 
 	return du, nil
@@ -180,21 +263,25 @@ type diskutilMojave struct {
 
 	// dec is the Decoder used to decode the raw output from UtilImpl into usable structs.
 	dec Decoder
+
+	// exec is the Executor used for the separate fetches of physical store information.
+	exec util.Executor
 }
 
 // List utilizes the UtilImpl.List method to fetch the raw list output from diskutil and returns the decoded
 // output in a SystemPartitions struct. List also attempts to update each APFS Volume's physical store via a separate
 // fetch method since the version of diskutil on Mojave doesn't provide that information in its List verb.
 //
-// It is possible for List to fail when updating the physical stores, but it will still return the original data
-// that was decoded into the SystemPartitions struct.
+// It is possible for List to fail when updating some of the physical stores, but it will still return the decoded
+// data with the physical stores that could be fetched along with a PartialDataError listing the volumes that
+// couldn't be resolved.
 func (d *diskutilMojave) List(ctx context.Context, args []string) (*types.SystemPartitions, error) {
 	partitions, err := list(ctx, d.embeddedDiskutil, d.dec, args)
 	if err != nil {
 		return nil, err
 	}
 
-	err = updatePhysicalStores(ctx, partitions)
+	err = updatePhysicalStores(ctx, d.exec, partitions)
 	if err != nil {
 		return partitions, err
 	}
@@ -207,14 +294,14 @@ func (d *diskutilMojave) List(ctx context.Context, args []string) (*types.System
 // fetch method since the version of diskutil on Mojave doesn't provide that information in its Info verb.
 //
 // It is possible for Info to fail when updating the physical stores, but it will still return the original data
-// that was decoded into the DiskInfo struct.
+// that was decoded into the DiskInfo struct along with a PartialDataError.
 func (d *diskutilMojave) Info(ctx context.Context, id string) (*types.DiskInfo, error) {
 	disk, err := info(ctx, d.embeddedDiskutil, d.dec, id)
 	if err != nil {
 		return nil, err
 	}
 
-	err = updatePhysicalStore(ctx, disk)
+	err = updatePhysicalStore(ctx, d.exec, disk)
 	if err != nil {
 		return disk, err
 	}
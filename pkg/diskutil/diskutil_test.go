@@ -0,0 +1,38 @@
+package diskutil
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+func TestMinimumGrowSpaceError_Error(t *testing.T) {
+	const expectedSize uint64 = 0
+
+	e := FreeSpaceError{
+		FreeSpaceBytes: expectedSize,
+	}
+
+	expectedErrorMessage := fmt.Sprintf("%d bytes available", 0)
+
+	actualErrorMessage := e.Error()
+
+	assert.Equal(t, expectedErrorMessage, actualErrorMessage, "expected message to include metadata")
+}
+
+func TestForProduct_UnknownRelease(t *testing.T) {
+	p := &system.Product{Release: system.Unknown, Version: *semver.MustParse("9.0.0")}
+
+	u, err := ForProduct(p)
+
+	var releaseErr *UnknownReleaseError
+	assert.Nil(t, u, "should get nil since the release is unknown")
+	assert.True(t, errors.As(err, &releaseErr), "should get UnknownReleaseError since the release is unknown")
+	assert.Equal(t, *p, releaseErr.Product)
+	assert.Equal(t, ClassUnsupported, Classify(err))
+}
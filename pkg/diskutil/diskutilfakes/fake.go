@@ -0,0 +1,907 @@
+// Package diskutilfakes provides an in-memory diskutil.DiskUtil for testing automation against realistic disk
+// behavior rather than hand-written mock expectations. The fake's disks are seeded by the test and mutated by the
+// operations run against it: resizing a container grows its physical store, erasing a disk replaces its partitions,
+// and mounting a volume updates its mount point.
+package diskutilfakes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/identifier"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+)
+
+const (
+	// efiSize is the size of the EFI partition that diskutil creates when erasing a disk.
+	efiSize = 209715200
+
+	// contentGUID is the content of disks with a GUID partition map.
+	contentGUID = "GUID_partition_scheme"
+	// contentMBR is the content of disks with an MBR partition map.
+	contentMBR = "FDisk_partition_scheme"
+	// contentEFI is the content of EFI partitions.
+	contentEFI = "EFI"
+	// contentAPFS is the content of partitions that are APFS physical stores.
+	contentAPFS = "Apple_APFS"
+)
+
+// Disk is a whole physical disk.
+type Disk struct {
+	// ID is the disk's device identifier (e.g. "disk0").
+	ID string
+	// Size is the size of the disk.
+	Size uint64
+	// Internal indicates that the disk is internal to the host rather than attached (e.g. an EBS volume).
+	Internal bool
+	// MediaName is the disk's media name, which identifies the hardware backing it (e.g. "Amazon Elastic Block
+	// Store" or "APPLE SSD AP0256M").
+	MediaName string
+	// BusProtocol is the protocol of the bus that the disk is attached to (e.g. "PCI-Express" or "Apple Fabric").
+	BusProtocol string
+	// SMARTStatus is the disk's SMART status (e.g. "Verified" or "Failing"), EBS volumes report "Not Supported".
+	SMARTStatus string
+	// Content is the disk's partition scheme, which defaults to GUID_partition_scheme when the disk has
+	// partitions. Disks formatted without a partition map hold their filesystem's content instead.
+	Content string
+	// VolumeName is the name of the filesystem on disks formatted without a partition map.
+	VolumeName string
+	// MountPoint is where the filesystem on disks formatted without a partition map is mounted.
+	MountPoint string
+	// Partitions are the disk's partitions, in order. Their device identifiers are derived from their position
+	// (e.g. the first partition of disk0 is disk0s1).
+	Partitions []Partition
+}
+
+// Partition is a partition of a whole disk.
+type Partition struct {
+	// Content is the partition type (e.g. "EFI", "Apple_APFS", or "Apple_HFS").
+	Content string
+	// Size is the size of the partition.
+	Size uint64
+	// VolumeName is the name of the partition's filesystem.
+	VolumeName string
+	// MountPoint is where the partition's filesystem is mounted.
+	MountPoint string
+	// Container is the APFS container stored on the partition, for partitions that are APFS physical stores.
+	Container *Container
+}
+
+// Container is an APFS container stored on a partition.
+type Container struct {
+	// ID is the container's device identifier (e.g. "disk3").
+	ID string
+	// Volumes are the container's volumes, in order. Their device identifiers are derived from their position
+	// (e.g. the first volume of disk3 is disk3s1).
+	Volumes []Volume
+}
+
+// Volume is an APFS volume in a container.
+type Volume struct {
+	// Name is the name of the volume.
+	Name string
+	// Size is the space used by the volume.
+	Size uint64
+	// MountPoint is where the volume is mounted. The boot volume is mounted at "/".
+	MountPoint string
+	// UUID is the volume's UUID.
+	UUID string
+	// Passphrase is the passphrase that the volume is encrypted with, it isn't encrypted when it's empty.
+	Passphrase string
+	// Locked indicates that the encrypted volume hasn't been unlocked, locked volumes can't be mounted.
+	Locked bool
+}
+
+// Call is an invocation of one of the fake's methods.
+type Call struct {
+	// Method is the name of the method (e.g. "ResizeContainer").
+	Method string
+	// Args are the method's arguments, without the context.
+	Args []string
+}
+
+// DiskUtil is an in-memory diskutil.DiskUtil. It's safe for concurrent use.
+type DiskUtil struct {
+	mu       sync.Mutex
+	disks    []*Disk
+	calls    []Call
+	failures map[string][]error
+}
+
+// Type assertion to ensure DiskUtil implements the diskutil.DiskUtil interface.
+var _ diskutil.DiskUtil = (*DiskUtil)(nil)
+
+// New creates a DiskUtil seeded with copies of the disks.
+func New(disks ...Disk) *DiskUtil {
+	f := &DiskUtil{failures: make(map[string][]error)}
+	for _, d := range disks {
+		f.disks = append(f.disks, copyDisk(d))
+	}
+
+	return f
+}
+
+// Disks gets copies of the fake's disks in their current state.
+func (f *DiskUtil) Disks() []Disk {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	disks := make([]Disk, 0, len(f.disks))
+	for _, d := range f.disks {
+		disks = append(disks, *copyDisk(*d))
+	}
+
+	return disks
+}
+
+// Calls gets the invocations of the fake's methods in the order they were made.
+func (f *DiskUtil) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Call(nil), f.calls...)
+}
+
+// FailNext makes the next calls of the method fail with the errors, one call per error. Calls after the errors are
+// used up behave normally, which allows retries to be tested.
+func (f *DiskUtil) FailNext(method string, errs ...error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures[method] = append(f.failures[method], errs...)
+}
+
+// AddVolume adds an unmounted volume, named name, to the container with the given device identifier. Volumes are
+// given a UUID derived from their device identifier and are encrypted with the passphrase, unlocked, unless it's empty.
+func (f *DiskUtil) AddVolume(ctx context.Context, id string, format string, name string, passphrase string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("AddVolume", id, format, name); err != nil {
+		return "", err
+	}
+
+	_, part := f.findContainer(id)
+	if part == nil {
+		return "", notFound(id)
+	}
+	if !strings.Contains(strings.ToUpper(format), "APFS") {
+		return "", commandError(diskutil.ClassUnsupported, fmt.Sprintf("%s does not appear to be a valid APFS volume format", format))
+	}
+	c := part.Container
+	vid := volumeID(c, len(c.Volumes))
+	n, _ := strconv.Atoi(strings.NewReplacer("disk", "", "s", "").Replace(vid))
+	c.Volumes = append(c.Volumes, Volume{
+		Name:       name,
+		UUID:       fmt.Sprintf("00000000-0000-0000-0000-%012d", n),
+		Passphrase: passphrase,
+	})
+
+	return fmt.Sprintf("Started APFS operation on %s\nPreparing to add APFS Volume to APFS Container %s\nCreating APFS Volume\nCreated new APFS Volume %s\nFinished APFS operation on %s\n", c.ID, c.ID, vid, c.ID), nil
+}
+
+// CreateContainer creates an empty container on the whole disk or partition with the given device identifier. Whole
+// disks are replaced with an EFI partition and a physical store in the remaining space, like erasing them.
+func (f *DiskUtil) CreateContainer(ctx context.Context, id string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("CreateContainer", id); err != nil {
+		return "", err
+	}
+
+	container := &Container{ID: f.nextDiskID()}
+	if disk := f.findDisk(id); disk != nil {
+		if disk.Size < efiSize {
+			return "", commandError(diskutil.ClassInsufficientSpace, "Error: -69519: The target disk is too small for this operation")
+		}
+		disk.Content = contentGUID
+		disk.VolumeName = ""
+		disk.MountPoint = ""
+		disk.Partitions = []Partition{
+			{Content: contentEFI, Size: efiSize, VolumeName: "EFI"},
+			{Content: contentAPFS, Size: disk.Size - efiSize, Container: container},
+		}
+	} else if part := f.findPartition(id); part != nil {
+		*part = Partition{Content: contentAPFS, Size: part.Size, Container: container}
+	} else {
+		return "", notFound(id)
+	}
+
+	return fmt.Sprintf("Started APFS operation on %s\nCreating a new empty APFS Container\nCreated new APFS Container %s\nDisk from APFS operation: %s\nFinished APFS operation on %s\n", id, container.ID, container.ID, id), nil
+}
+
+// DeleteContainer deletes the container with the given device identifier, or stored on the given physical store,
+// by removing its physical store, which leaves free space where it was. Partitions after the store are renumbered.
+func (f *DiskUtil) DeleteContainer(ctx context.Context, id string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("DeleteContainer", id); err != nil {
+		return "", err
+	}
+
+	disk, part := f.findContainer(id)
+	if part == nil {
+		return "", notFound(id)
+	}
+	container := part.Container.ID
+	for i := range disk.Partitions {
+		if &disk.Partitions[i] == part {
+			disk.Partitions = append(disk.Partitions[:i], disk.Partitions[i+1:]...)
+			break
+		}
+	}
+
+	return fmt.Sprintf("Started APFS operation on %s\nDeleting APFS Container with all of its APFS Volumes\nRemoving APFS Container %s\nFinished APFS operation on %s\n", container, container, container), nil
+}
+
+// ResizeContainer resizes the container with the given device identifier, or stored on the given physical store,
+// by resizing its physical store. A size of "0" grows the store into all of the free space after it.
+func (f *DiskUtil) ResizeContainer(ctx context.Context, id string, size string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("ResizeContainer", id, size); err != nil {
+		return "", err
+	}
+
+	disk, part := f.findContainer(id)
+	if part == nil {
+		return "", notFound(id)
+	}
+
+	free := freeSpace(disk)
+	target := part.Size + free
+	if size != "0" {
+		n, err := parseSize(size)
+		if err != nil {
+			return "", commandError(diskutil.ClassUnknown, fmt.Sprintf("Error: invalid size [%s]", size))
+		}
+		target = n
+	}
+
+	switch {
+	case target > part.Size+free:
+		return "", commandError(diskutil.ClassInsufficientSpace, "Error: -69519: The target disk is too small for this operation")
+	case target < usedSpace(part.Container):
+		return "", commandError(diskutil.ClassInsufficientSpace, "Error: -69743: The new size must be larger than the used space")
+	}
+	part.Size = target
+
+	return fmt.Sprintf("Started APFS operation\nResizing APFS Container [%s] to %d bytes\nFinished APFS operation\n", part.Container.ID, target), nil
+}
+
+// UnlockVolume unlocks the encrypted volume with the given device identifier with the passphrase and mounts it in
+// /Volumes. Volumes that aren't encrypted or are unlocked fail like diskutil.
+func (f *DiskUtil) UnlockVolume(ctx context.Context, id string, passphrase string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("UnlockVolume", id); err != nil {
+		return "", err
+	}
+
+	v := f.findVolume(id)
+	switch {
+	case v == nil:
+		return "", notFound(id)
+	case v.Passphrase == "":
+		return "", commandError(diskutil.ClassUnsupported, fmt.Sprintf("Error: -69594: The given APFS Volume %s is not encrypted", id))
+	case !v.Locked:
+		return "", commandError(diskutil.ClassUnknown, fmt.Sprintf("Error: -69563: The given APFS Volume %s is already unlocked", id))
+	case v.Passphrase != passphrase:
+		return "", commandError(diskutil.ClassUnknown, "Error: -69749: Passphrase incorrect")
+	}
+	v.Locked = false
+	v.MountPoint = "/Volumes/" + v.Name
+
+	return fmt.Sprintf("Unlocking any cryptographic user on APFS Volume %s\nUnlocked and mounted APFS Volume\n", id), nil
+}
+
+// EraseDisk replaces the partitions of the whole disk with an EFI partition and a single volume, named name, in the
+// remaining space. APFS volumes are created in a new container. The volume is mounted in /Volumes. Erasing with the
+// "free" format leaves only the EFI partition, and FAT32 volumes fill an MBR partition map without an EFI partition.
+func (f *DiskUtil) EraseDisk(ctx context.Context, format string, name string, id string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("EraseDisk", format, name, id); err != nil {
+		return "", err
+	}
+
+	disk := f.findDisk(id)
+	if disk == nil {
+		return "", notFound(id)
+	}
+	if disk.Size < efiSize {
+		return "", commandError(diskutil.ClassInsufficientSpace, "Error: -69519: The target disk is too small for this operation")
+	}
+
+	efi := Partition{Content: contentEFI, Size: efiSize, VolumeName: "EFI"}
+	data := Partition{Size: disk.Size - efiSize, VolumeName: name, MountPoint: "/Volumes/" + name}
+	switch vf, _ := diskutil.ParseVolumeFormat(format); {
+	case strings.EqualFold(format, "free"):
+		data = Partition{}
+	case vf == diskutil.FormatAPFS:
+		data = Partition{Content: contentAPFS, Size: disk.Size - efiSize, Container: &Container{
+			ID:      f.nextDiskID(),
+			Volumes: []Volume{{Name: name, MountPoint: "/Volumes/" + name}},
+		}}
+	case vf == diskutil.FormatJHFS:
+		data.Content = "Apple_HFS"
+	case vf == diskutil.FormatExFAT:
+		data.Content = "Microsoft Basic Data"
+	case vf == diskutil.FormatFAT32:
+		data.Content = "DOS_FAT_32"
+		data.Size = disk.Size
+	default:
+		return "", commandError(diskutil.ClassUnsupported, fmt.Sprintf("%s does not appear to be a valid file system format", format))
+	}
+
+	disk.Content = contentGUID
+	disk.VolumeName = ""
+	disk.MountPoint = ""
+	disk.Partitions = []Partition{efi}
+	if data.Content == "DOS_FAT_32" {
+		disk.Content = contentMBR
+		disk.Partitions = nil
+	}
+	if data.Content != "" {
+		disk.Partitions = append(disk.Partitions, data)
+	}
+
+	return fmt.Sprintf("Started erase on %s\nFinished erase on %s\n", disk.ID, disk.ID), nil
+}
+
+// Info gets the information of the disk, partition, container, or volume with the given device identifier, device
+// node (e.g. "/dev/disk2"), or mount point (e.g. "/" for the boot volume).
+func (f *DiskUtil) Info(ctx context.Context, id string) (*types.DiskInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("Info", id); err != nil {
+		return nil, err
+	}
+
+	info := f.info(id)
+	if info == nil {
+		return nil, notFound(id)
+	}
+
+	return info, nil
+}
+
+// List gets the partitions of every disk followed by the volumes of every container. Other than a device identifier
+// to list only that disk, the args are ignored.
+func (f *DiskUtil) List(ctx context.Context, args []string) (*types.SystemPartitions, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("List", args...); err != nil {
+		return nil, err
+	}
+
+	filter := ""
+	for _, arg := range args {
+		if identifier.ParseDiskID(arg) != "" {
+			filter = identifier.ParseDiskID(arg)
+		}
+	}
+
+	partitions := &types.SystemPartitions{}
+	var containers []types.DiskPart
+	for _, d := range f.disks {
+		part := types.DiskPart{DeviceIdentifier: d.ID, Size: d.Size, Content: diskContent(d), MountPoint: d.MountPoint}
+		for i, p := range d.Partitions {
+			pid := partitionID(d, i)
+			part.Partitions = append(part.Partitions, types.Partition{
+				Content:          p.Content,
+				DeviceIdentifier: pid,
+				MountPoint:       p.MountPoint,
+				Size:             p.Size,
+				VolumeName:       p.VolumeName,
+			})
+			if p.Container == nil {
+				continue
+			}
+
+			c := types.DiskPart{
+				DeviceIdentifier:   p.Container.ID,
+				Size:               p.Size,
+				APFSPhysicalStores: []types.APFSPhysicalStoreID{{DeviceIdentifier: pid}},
+			}
+			for j, v := range p.Container.Volumes {
+				c.APFSVolumes = append(c.APFSVolumes, types.APFSVolume{
+					DeviceIdentifier: volumeID(p.Container, j),
+					MountPoint:       v.MountPoint,
+					Size:             v.Size,
+					VolumeName:       v.Name,
+					VolumeUUID:       v.UUID,
+				})
+			}
+			if filter == "" || filter == c.DeviceIdentifier {
+				containers = append(containers, c)
+			}
+		}
+		if filter == "" || filter == d.ID {
+			partitions.AllDisksAndPartitions = append(partitions.AllDisksAndPartitions, part)
+		}
+	}
+	partitions.AllDisksAndPartitions = append(partitions.AllDisksAndPartitions, containers...)
+
+	for _, part := range partitions.AllDisksAndPartitions {
+		partitions.WholeDisks = append(partitions.WholeDisks, part.DeviceIdentifier)
+		partitions.AllDisks = append(partitions.AllDisks, part.DeviceIdentifier)
+		for _, p := range part.Partitions {
+			partitions.AllDisks = append(partitions.AllDisks, p.DeviceIdentifier)
+			if p.VolumeName != "" {
+				partitions.VolumesFromDisks = append(partitions.VolumesFromDisks, p.VolumeName)
+			}
+		}
+		for _, v := range part.APFSVolumes {
+			partitions.AllDisks = append(partitions.AllDisks, v.DeviceIdentifier)
+			partitions.VolumesFromDisks = append(partitions.VolumesFromDisks, v.VolumeName)
+		}
+	}
+
+	return partitions, nil
+}
+
+// Mount mounts the volume or partition with the given device identifier at the mount point, which defaults to
+// /Volumes/<name>. Volumes that are already mounted fail with a busy error like diskutil.
+func (f *DiskUtil) Mount(ctx context.Context, id string, mountPoint string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("Mount", id, mountPoint); err != nil {
+		return "", err
+	}
+
+	name, current := f.mountPoint(id)
+	if current == nil {
+		return "", notFound(id)
+	}
+	if v := f.findVolume(id); v != nil && v.Locked {
+		return "", commandError(diskutil.ClassUnknown, fmt.Sprintf("Volume on %s failed to mount: the volume is locked", id))
+	}
+	if *current != "" {
+		return "", commandError(diskutil.ClassBusy, fmt.Sprintf("Volume on %s failed to mount: already mounted at %s", id, *current))
+	}
+	if mountPoint == "" {
+		mountPoint = "/Volumes/" + name
+	}
+	*current = mountPoint
+
+	return fmt.Sprintf("Volume %s on %s mounted\n", name, id), nil
+}
+
+// NewFS formats the whole disk with a single filesystem, named name, without a partition map.
+func (f *DiskUtil) NewFS(ctx context.Context, format string, name string, id string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("NewFS", format, name, id); err != nil {
+		return "", err
+	}
+
+	disk := f.findDisk(id)
+	if disk == nil {
+		return "", notFound(id)
+	}
+	vf, err := diskutil.ParseVolumeFormat(format)
+	if err != nil {
+		return "", commandError(diskutil.ClassUnsupported, err.Error())
+	}
+	// newfs writes to the raw device, which can't be opened while the disk's volumes are mounted
+	if len(mountPoints(disk)) > 0 {
+		return "", commandError(diskutil.ClassBusy, fmt.Sprintf("newfs: /dev/r%s: Resource busy", disk.ID))
+	}
+
+	disk.Content = vf.FilesystemType()
+	disk.VolumeName = name
+	disk.MountPoint = ""
+	disk.Partitions = nil
+
+	return fmt.Sprintf("Initialized /dev/r%s as a %s volume named %s\n", disk.ID, vf, name), nil
+}
+
+// RepairDisk repairs the partition map of the whole disk, which is a no-op for the fake.
+func (f *DiskUtil) RepairDisk(ctx context.Context, id string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("RepairDisk", id); err != nil {
+		return "", err
+	}
+
+	disk := f.findDisk(id)
+	if disk == nil {
+		return "", notFound(id)
+	}
+
+	return fmt.Sprintf("Started partition map verification/repair on %s\nAdjusting partition map to fit whole disk as required\nThe partition map appears to be OK\nFinished partition map verification/repair on %s\n", disk.ID, disk.ID), nil
+}
+
+// ResetUserPermissions resets the permissions of the files on the mounted volume with the given device identifier,
+// which is a no-op for the fake.
+func (f *DiskUtil) ResetUserPermissions(ctx context.Context, id string, uid int) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("ResetUserPermissions", id, strconv.Itoa(uid)); err != nil {
+		return "", err
+	}
+
+	name, current := f.mountPoint(id)
+	if current == nil {
+		return "", notFound(id)
+	}
+	if *current == "" {
+		return "", commandError(diskutil.ClassUnknown, fmt.Sprintf("Volume %s on %s is not mounted", name, id))
+	}
+	if uid < 0 {
+		return "", commandError(diskutil.ClassUnknown, fmt.Sprintf("Invalid UID %d", uid))
+	}
+
+	return fmt.Sprintf("Started reset of user permissions on %s\nFinished reset of user permissions on %s\n", id, id), nil
+}
+
+// Unmount unmounts the volume or partition with the given device identifier.
+func (f *DiskUtil) Unmount(ctx context.Context, id string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("Unmount", id); err != nil {
+		return "", err
+	}
+
+	name, current := f.mountPoint(id)
+	if current == nil {
+		return "", notFound(id)
+	}
+	if *current == "" {
+		return "", commandError(diskutil.ClassUnknown, fmt.Sprintf("Volume %s on %s is not mounted", name, id))
+	}
+	*current = ""
+
+	return fmt.Sprintf("Volume %s on %s unmounted\n", name, id), nil
+}
+
+// UnmountDisk unmounts all of the volumes on the whole disk with the given device identifier.
+func (f *DiskUtil) UnmountDisk(ctx context.Context, id string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("UnmountDisk", id); err != nil {
+		return "", err
+	}
+
+	disk := f.findDisk(id)
+	if disk == nil {
+		return "", notFound(id)
+	}
+	for _, mp := range mountPoints(disk) {
+		*mp = ""
+	}
+
+	return fmt.Sprintf("Unmount of all volumes on %s was successful\n", disk.ID), nil
+}
+
+// record records the call and returns the next failure queued for the method.
+func (f *DiskUtil) record(method string, args ...string) error {
+	f.calls = append(f.calls, Call{Method: method, Args: args})
+
+	queued := f.failures[method]
+	if len(queued) == 0 {
+		return nil
+	}
+	f.failures[method] = queued[1:]
+
+	return queued[0]
+}
+
+// findDisk finds the whole physical disk with the device identifier.
+func (f *DiskUtil) findDisk(id string) *Disk {
+	id = strings.TrimPrefix(id, "/dev/")
+	for _, d := range f.disks {
+		if d.ID == id {
+			return d
+		}
+	}
+
+	return nil
+}
+
+// findPartition finds the partition with the device identifier.
+func (f *DiskUtil) findPartition(id string) *Partition {
+	id = strings.TrimPrefix(id, "/dev/")
+	for _, d := range f.disks {
+		for i := range d.Partitions {
+			if partitionID(d, i) == id {
+				return &d.Partitions[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+// findContainer finds the partition storing the container with the device identifier, or the partition with the
+// device identifier when it's a physical store.
+func (f *DiskUtil) findContainer(id string) (*Disk, *Partition) {
+	id = strings.TrimPrefix(id, "/dev/")
+	for _, d := range f.disks {
+		for i := range d.Partitions {
+			p := &d.Partitions[i]
+			if p.Container != nil && (p.Container.ID == id || partitionID(d, i) == id) {
+				return d, p
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// findVolume finds the APFS volume with the device identifier.
+func (f *DiskUtil) findVolume(id string) *Volume {
+	id = strings.TrimPrefix(id, "/dev/")
+	for _, d := range f.disks {
+		for i := range d.Partitions {
+			c := d.Partitions[i].Container
+			if c == nil {
+				continue
+			}
+			for j := range c.Volumes {
+				if volumeID(c, j) == id {
+					return &c.Volumes[j]
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// mountPoint finds the name and mount point of the volume or partition with the device identifier. A nil mount
+// point is returned when there's no volume or partition.
+func (f *DiskUtil) mountPoint(id string) (string, *string) {
+	id = strings.TrimPrefix(id, "/dev/")
+	for _, d := range f.disks {
+		if d.ID == id && d.VolumeName != "" {
+			return d.VolumeName, &d.MountPoint
+		}
+		for i := range d.Partitions {
+			p := &d.Partitions[i]
+			if partitionID(d, i) == id && p.Container == nil {
+				return p.VolumeName, &p.MountPoint
+			}
+			if p.Container == nil {
+				continue
+			}
+			for j := range p.Container.Volumes {
+				v := &p.Container.Volumes[j]
+				if volumeID(p.Container, j) == id {
+					return v.Name, &v.MountPoint
+				}
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// info builds the information for the device with the device identifier, device node, or mount point.
+func (f *DiskUtil) info(id string) *types.DiskInfo {
+	byMountPoint := strings.HasPrefix(id, "/") && !strings.HasPrefix(id, "/dev/")
+	id = strings.TrimPrefix(id, "/dev/")
+
+	for _, d := range f.disks {
+		if d.ID == id || (byMountPoint && d.MountPoint == id && d.MountPoint != "") {
+			return &types.DiskInfo{
+				DeviceIdentifier:  d.ID,
+				DeviceNode:        "/dev/" + d.ID,
+				Content:           diskContent(d),
+				Internal:          d.Internal,
+				BusProtocol:       d.BusProtocol,
+				IOKitSize:         d.Size,
+				MediaName:         d.MediaName,
+				SMARTStatus:       d.SMARTStatus,
+				MountPoint:        d.MountPoint,
+				ParentWholeDisk:   d.ID,
+				Size:              d.Size,
+				TotalSize:         d.Size,
+				VirtualOrPhysical: "Physical",
+				VolumeName:        d.VolumeName,
+				WholeDisk:         true,
+			}
+		}
+
+		for i, p := range d.Partitions {
+			pid := partitionID(d, i)
+			if pid == id || (byMountPoint && p.MountPoint == id && p.MountPoint != "") {
+				return &types.DiskInfo{
+					DeviceIdentifier:  pid,
+					DeviceNode:        "/dev/" + pid,
+					Content:           p.Content,
+					Internal:          d.Internal,
+					MountPoint:        p.MountPoint,
+					ParentWholeDisk:   d.ID,
+					Size:              p.Size,
+					TotalSize:         p.Size,
+					VirtualOrPhysical: "Physical",
+					VolumeName:        p.VolumeName,
+				}
+			}
+			if p.Container == nil {
+				continue
+			}
+
+			c := p.Container
+			container := types.ContainerInfo{
+				APFSContainerFree: p.Size - usedSpace(c),
+				APFSContainerSize: p.Size,
+				FilesystemName:    "APFS",
+				FilesystemType:    "apfs",
+			}
+			stores := []types.APFSPhysicalStore{{DeviceIdentifier: pid}}
+			if c.ID == id {
+				return &types.DiskInfo{
+					ContainerInfo:      container,
+					APFSPhysicalStores: stores,
+					DeviceIdentifier:   c.ID,
+					DeviceNode:         "/dev/" + c.ID,
+					Internal:           d.Internal,
+					ParentWholeDisk:    c.ID,
+					Size:               p.Size,
+					TotalSize:          p.Size,
+					VirtualOrPhysical:  "Virtual",
+					WholeDisk:          true,
+				}
+			}
+			for j, v := range c.Volumes {
+				vid := volumeID(c, j)
+				if vid == id || (byMountPoint && v.MountPoint == id && v.MountPoint != "") {
+					container.VolumeUUID = v.UUID
+					container.Encryption = v.Passphrase != ""
+					container.FileVault = v.Passphrase != ""
+					container.Locked = v.Locked
+					return &types.DiskInfo{
+						ContainerInfo:          container,
+						APFSContainerReference: c.ID,
+						APFSPhysicalStores:     stores,
+						DeviceIdentifier:       vid,
+						DeviceNode:             "/dev/" + vid,
+						Internal:               d.Internal,
+						MountPoint:             v.MountPoint,
+						ParentWholeDisk:        c.ID,
+						Size:                   p.Size,
+						TotalSize:              p.Size,
+						VirtualOrPhysical:      "Virtual",
+						VolumeName:             v.Name,
+						VolumeSize:             v.Size,
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// nextDiskID gets the device identifier after the highest one in use, which is what new containers are given.
+func (f *DiskUtil) nextDiskID() string {
+	next := 0
+	for _, d := range f.disks {
+		ids := []string{d.ID}
+		for _, p := range d.Partitions {
+			if p.Container != nil {
+				ids = append(ids, p.Container.ID)
+			}
+		}
+		for _, id := range ids {
+			if n, err := strconv.Atoi(strings.TrimPrefix(id, "disk")); err == nil && n >= next {
+				next = n + 1
+			}
+		}
+	}
+
+	return fmt.Sprintf("disk%d", next)
+}
+
+// partitionID gets the device identifier of the disk's partition at index i.
+func partitionID(d *Disk, i int) string {
+	return fmt.Sprintf("%ss%d", d.ID, i+1)
+}
+
+// volumeID gets the device identifier of the container's volume at index i.
+func volumeID(c *Container, i int) string {
+	return fmt.Sprintf("%ss%d", c.ID, i+1)
+}
+
+// diskContent gets the content of the disk, defaulting to a GUID partition map for disks with partitions.
+func diskContent(d *Disk) string {
+	if d.Content == "" && len(d.Partitions) > 0 {
+		return contentGUID
+	}
+
+	return d.Content
+}
+
+// freeSpace calculates the space on the disk that isn't allocated to a partition.
+func freeSpace(d *Disk) uint64 {
+	var allocated uint64
+	for _, p := range d.Partitions {
+		allocated += p.Size
+	}
+	if allocated > d.Size {
+		return 0
+	}
+
+	return d.Size - allocated
+}
+
+// usedSpace calculates the space used by the container's volumes.
+func usedSpace(c *Container) uint64 {
+	var used uint64
+	for _, v := range c.Volumes {
+		used += v.Size
+	}
+
+	return used
+}
+
+// parseSize parses a size given to diskutil, either in bytes or with a unit (e.g. "100g").
+func parseSize(s string) (uint64, error) {
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return n, nil
+	}
+
+	return humanize.ParseBytes(s)
+}
+
+// copyDisk makes a deep copy of the disk so that the fake's state can't be changed from outside.
+func copyDisk(d Disk) *Disk {
+	c := d
+	if d.Partitions == nil {
+		return &c
+	}
+	c.Partitions = make([]Partition, len(d.Partitions))
+	for i, p := range d.Partitions {
+		c.Partitions[i] = p
+		if p.Container != nil {
+			container := *p.Container
+			container.Volumes = append([]Volume(nil), p.Container.Volumes...)
+			c.Partitions[i].Container = &container
+		}
+	}
+
+	return &c
+}
+
+// commandError creates the error diskutil would fail with for the stderr.
+func commandError(class diskutil.ErrClass, stderr string) error {
+	return &diskutil.CommandError{Class: class, Stderr: stderr, Err: errors.New(stderr)}
+}
+
+// notFound creates the error diskutil fails with when the device doesn't exist.
+func notFound(id string) error {
+	return commandError(diskutil.ClassUnknown, fmt.Sprintf("Could not find disk: %s", id))
+}
+
+// mountPoints gets the mount points of the disk's mounted filesystems, partitions, and volumes.
+func mountPoints(d *Disk) []*string {
+	var mps []*string
+	if d.MountPoint != "" {
+		mps = append(mps, &d.MountPoint)
+	}
+	for i := range d.Partitions {
+		p := &d.Partitions[i]
+		if p.MountPoint != "" {
+			mps = append(mps, &p.MountPoint)
+		}
+		if p.Container == nil {
+			continue
+		}
+		for j := range p.Container.Volumes {
+			if v := &p.Container.Volumes[j]; v.MountPoint != "" {
+				mps = append(mps, &v.MountPoint)
+			}
+		}
+	}
+
+	return mps
+}
@@ -0,0 +1,387 @@
+package diskutilfakes
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+)
+
+func init() {
+	logrus.SetOutput(ioutil.Discard)
+}
+
+// bootDisk is an internal disk whose APFS container, disk3, holds the boot volume and has 50GB to grow into.
+func bootDisk() Disk {
+	return Disk{
+		ID:       "disk0",
+		Size:     150_000_000_000,
+		Internal: true,
+		Partitions: []Partition{
+			{Content: "EFI", Size: efiSize, VolumeName: "EFI"},
+			{Content: "Apple_APFS", Size: 100_000_000_000, Container: &Container{
+				ID:      "disk3",
+				Volumes: []Volume{{Name: "Macintosh HD", Size: 20_000_000_000, MountPoint: "/"}},
+			}},
+		},
+	}
+}
+
+func TestDiskUtil_GrowContainer(t *testing.T) {
+	ctx := context.Background()
+	f := New(bootDisk())
+
+	root, err := f.Info(ctx, "/")
+	assert.NoError(t, err)
+	assert.Equal(t, "disk3s1", root.DeviceIdentifier)
+
+	assert.NoError(t, diskutil.GrowContainer(ctx, f, root))
+
+	container, err := f.Info(ctx, "disk3")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(150_000_000_000-efiSize), container.APFSContainerSize, "the container should fill the disk")
+
+	err = diskutil.GrowContainer(ctx, f, root)
+	var freeSpaceErr diskutil.FreeSpaceError
+	assert.True(t, errors.As(err, &freeSpaceErr), "there should be nothing left to grow into")
+}
+
+func TestDiskUtil_ProvisionVolume(t *testing.T) {
+	ctx := context.Background()
+	f := New(bootDisk(), Disk{ID: "disk4", Size: 500_000_000_000})
+
+	volume, err := diskutil.ProvisionVolume(ctx, f, "disk4", diskutil.FormatAPFS, "data", "/Volumes/builds")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "disk5s1", volume.DeviceIdentifier)
+	assert.Equal(t, "/Volumes/builds", volume.MountPoint)
+	assert.Equal(t, "data", volume.VolumeName)
+
+	_, err = diskutil.ProvisionVolume(ctx, f, "disk0", diskutil.FormatAPFS, "data", "/Volumes/builds")
+	assert.Error(t, err, "the boot disk should never be provisioned")
+}
+
+func TestDiskUtil_ProvisionVolume_FAT32(t *testing.T) {
+	ctx := context.Background()
+	f := New(bootDisk(), Disk{ID: "disk4", Size: 64_000_000_000})
+
+	volume, err := diskutil.ProvisionVolume(ctx, f, "disk4", diskutil.FormatFAT32, "shared", "/Volumes/shared")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "disk4s1", volume.DeviceIdentifier)
+	assert.Equal(t, "SHARED", volume.VolumeName, "FAT32 labels should be upper case")
+	assert.Equal(t, "FDisk_partition_scheme", f.Disks()[1].Content, "FAT32 disks should use an MBR partition map")
+	for _, c := range f.Calls() {
+		if c.Method == "EraseDisk" {
+			assert.Equal(t, []string{"FAT32", "SHARED", "disk4"}, c.Args)
+		}
+	}
+
+	f = New(bootDisk(), Disk{ID: "disk4", Size: 4_000_000_000_000})
+	_, err = diskutil.ProvisionVolume(ctx, f, "disk4", diskutil.FormatFAT32, "shared", "/Volumes/shared")
+	assert.Error(t, err, "FAT32 volumes can't be larger than 2 TiB")
+	assert.Empty(t, f.Disks()[1].Partitions)
+}
+
+func TestDiskUtil_ProvisionVolume_InternalSSD(t *testing.T) {
+	ctx := context.Background()
+	f := New(bootDisk(), Disk{ID: "disk4", Size: 250_000_000_000, Internal: true, MediaName: "APPLE SSD AP0256M"})
+
+	_, err := diskutil.ProvisionVolume(ctx, f, "disk4", diskutil.FormatAPFS, "data", "/Volumes/builds")
+
+	assert.EqualError(t, err, "device [disk4] is the host's internal SSD")
+	assert.Len(t, f.Disks()[1].Partitions, 0, "the internal SSD shouldn't be erased")
+}
+
+func TestClassifyDisks(t *testing.T) {
+	ctx := context.Background()
+	boot := bootDisk()
+	boot.MediaName = "Amazon Elastic Block Store"
+	f := New(boot, Disk{ID: "disk4", Size: 250_000_000_000, Internal: true, MediaName: "APPLE SSD AP0256M"})
+
+	partitions, err := f.List(ctx, nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, diskutil.ClassifyDisks(ctx, f, partitions))
+	kinds := make(map[string]types.DiskKind)
+	for _, part := range partitions.AllDisksAndPartitions {
+		kinds[part.DeviceIdentifier] = part.Kind
+	}
+	assert.Equal(t, map[string]types.DiskKind{"disk0": types.KindEBS, "disk3": types.KindEBS, "disk4": types.KindInternal}, kinds)
+
+	root, err := f.Info(ctx, "/")
+	assert.NoError(t, err)
+	kind, err := diskutil.ResolveKind(ctx, f, root)
+	assert.NoError(t, err)
+	assert.Equal(t, types.KindEBS, kind)
+}
+
+func TestDiskUtil_ResizeContainer(t *testing.T) {
+	ctx := context.Background()
+	f := New(bootDisk())
+
+	_, err := f.ResizeContainer(ctx, "disk3", "200GB")
+	assert.Equal(t, diskutil.ClassInsufficientSpace, diskutil.Classify(err))
+
+	_, err = f.ResizeContainer(ctx, "disk3", "10GB")
+	assert.Equal(t, diskutil.ClassInsufficientSpace, diskutil.Classify(err), "containers can't shrink below their used space")
+
+	_, err = f.ResizeContainer(ctx, "disk0s2", "120GB")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(120_000_000_000), f.Disks()[0].Partitions[1].Size)
+}
+
+func TestDiskUtil_EraseDisk(t *testing.T) {
+	ctx := context.Background()
+	f := New(Disk{ID: "disk2", Size: 100_000_000_000, Partitions: []Partition{{Content: "Apple_HFS", Size: 1000, VolumeName: "old"}}})
+
+	_, err := f.EraseDisk(ctx, "JHFS+", "data", "disk2")
+	assert.NoError(t, err)
+
+	partitions, err := f.List(ctx, []string{"disk2"})
+	assert.NoError(t, err)
+	assert.Len(t, partitions.AllDisksAndPartitions, 1)
+	assert.Equal(t, "disk2s2", partitions.AllDisksAndPartitions[0].Partitions[1].DeviceIdentifier)
+	assert.Equal(t, "data", partitions.AllDisksAndPartitions[0].Partitions[1].VolumeName)
+
+	_, err = f.EraseDisk(ctx, "NTFS", "data", "disk2")
+	assert.Equal(t, diskutil.ClassUnsupported, diskutil.Classify(err))
+}
+
+func TestDiskUtil_MountUnmount(t *testing.T) {
+	ctx := context.Background()
+	f := New(bootDisk())
+
+	_, err := f.Mount(ctx, "disk3s1", "/mnt")
+	assert.Equal(t, diskutil.ClassBusy, diskutil.Classify(err), "mounted volumes can't be mounted again")
+
+	_, err = f.Unmount(ctx, "disk3s1")
+	assert.NoError(t, err)
+	_, err = f.Unmount(ctx, "disk3s1")
+	assert.Error(t, err)
+
+	_, err = f.Mount(ctx, "disk3s1", "")
+	assert.NoError(t, err)
+	info, err := f.Info(ctx, "/Volumes/Macintosh HD")
+	assert.NoError(t, err)
+	assert.Equal(t, "disk3s1", info.DeviceIdentifier)
+}
+
+func TestDiskUtil_ResetUserPermissions(t *testing.T) {
+	ctx := context.Background()
+	f := New(bootDisk())
+
+	_, err := f.ResetUserPermissions(ctx, "disk3s1", 501)
+	assert.NoError(t, err)
+	assert.Equal(t, []Call{{Method: "ResetUserPermissions", Args: []string{"disk3s1", "501"}}}, f.Calls())
+
+	_, err = f.Unmount(ctx, "disk3s1")
+	assert.NoError(t, err)
+	_, err = f.ResetUserPermissions(ctx, "disk3s1", 501)
+	assert.Error(t, err, "permissions can only be reset on mounted volumes")
+}
+
+func TestDiskUtil_FailNext(t *testing.T) {
+	ctx := context.Background()
+	f := New(bootDisk())
+	busy := &diskutil.CommandError{Class: diskutil.ClassBusy, Err: errors.New("resource busy")}
+	f.FailNext("RepairDisk", busy)
+
+	_, err := f.RepairDisk(ctx, "disk0")
+	assert.Equal(t, busy, err)
+	_, err = f.RepairDisk(ctx, "disk0")
+	assert.NoError(t, err, "failures should only be used once")
+
+	assert.Equal(t, []Call{{Method: "RepairDisk", Args: []string{"disk0"}}, {Method: "RepairDisk", Args: []string{"disk0"}}}, f.Calls())
+}
+
+func TestNew_Copies(t *testing.T) {
+	d := bootDisk()
+	f := New(d)
+
+	d.Partitions[1].Container.Volumes[0].Name = "changed"
+
+	assert.Equal(t, "Macintosh HD", f.Disks()[0].Partitions[1].Container.Volumes[0].Name)
+}
+
+func TestDiskUtil_CreateDeleteContainer(t *testing.T) {
+	ctx := context.Background()
+	f := New(bootDisk(), Disk{ID: "disk4", Size: 500_000_000_000})
+
+	container, err := diskutil.CreateContainer(ctx, f, "disk4")
+	assert.NoError(t, err)
+	if assert.NotNil(t, container) {
+		assert.Equal(t, "disk5", container.DeviceIdentifier)
+		assert.Equal(t, []types.APFSPhysicalStore{{DeviceIdentifier: "disk4s2"}}, container.APFSPhysicalStores)
+	}
+
+	_, err = diskutil.CreateContainer(ctx, f, "disk4")
+	assert.Error(t, err, "disks that aren't blank shouldn't be erased")
+	_, err = diskutil.CreateContainer(ctx, f, "disk4s2")
+	assert.Error(t, err, "physical stores shouldn't be erased")
+
+	assert.NoError(t, diskutil.DeleteContainer(ctx, f, "disk5"))
+	assert.Len(t, f.Disks()[1].Partitions, 1, "only the EFI partition should be left")
+	assert.Error(t, diskutil.DeleteContainer(ctx, f, "disk5"), "the container should be gone")
+}
+
+func TestDiskUtil_DeleteContainer_Boot(t *testing.T) {
+	ctx := context.Background()
+	f := New(bootDisk())
+
+	for _, id := range []string{"disk3", "disk0s2"} {
+		assert.Error(t, diskutil.DeleteContainer(ctx, f, id), "the boot container should never be deleted")
+	}
+	_, err := diskutil.CreateContainer(ctx, f, "disk0")
+	assert.Error(t, err, "the boot disk should never be erased")
+
+	for _, c := range f.Calls() {
+		assert.NotContains(t, []string{"CreateContainer", "DeleteContainer"}, c.Method)
+	}
+}
+
+func TestDiskUtil_CreateContainer_DryRun(t *testing.T) {
+	ctx := context.Background()
+	f := New(bootDisk(), Disk{ID: "disk4", Size: 500_000_000_000})
+
+	container, err := diskutil.CreateContainer(ctx, diskutil.Dryrun(f), "disk4")
+
+	assert.NoError(t, err)
+	assert.Nil(t, container, "no container should be created")
+	assert.Empty(t, f.Disks()[1].Partitions)
+}
+
+// memoryEscrow keeps passphrases in memory by the volume's UUID.
+type memoryEscrow struct {
+	passphrases map[string]string
+	err         error
+}
+
+func (e *memoryEscrow) Put(ctx context.Context, uuid string, passphrase string) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.passphrases[uuid] = passphrase
+
+	return nil
+}
+
+func (e *memoryEscrow) Get(ctx context.Context, uuid string) (string, error) {
+	passphrase, ok := e.passphrases[uuid]
+	if !ok {
+		return "", errors.New("not found")
+	}
+
+	return passphrase, nil
+}
+
+// lockedDisk is an attached disk whose APFS container, disk5, holds a locked volume encrypted with "secret".
+func lockedDisk() Disk {
+	return Disk{
+		ID:   "disk4",
+		Size: 500_000_000_000,
+		Partitions: []Partition{
+			{Content: "EFI", Size: efiSize, VolumeName: "EFI"},
+			{Content: "Apple_APFS", Size: 500_000_000_000 - efiSize, Container: &Container{
+				ID:      "disk5",
+				Volumes: []Volume{{Name: "Data", UUID: "F1B2C3D4-0000-0000-0000-000000000001", Passphrase: "secret", Locked: true}},
+			}},
+		},
+	}
+}
+
+func TestDiskUtil_PrepareEncryptedVolume(t *testing.T) {
+	ctx := context.Background()
+	f := New(bootDisk(), Disk{ID: "disk4", Size: 500_000_000_000})
+	e := &memoryEscrow{passphrases: make(map[string]string)}
+
+	volume, err := diskutil.PrepareEncryptedVolume(ctx, f, "disk4", "Data", "secret", e)
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, volume) {
+		assert.Equal(t, "disk5s1", volume.DeviceIdentifier)
+		assert.True(t, volume.Encryption)
+		assert.False(t, volume.Locked)
+		assert.Equal(t, "secret", e.passphrases[volume.VolumeUUID], "the passphrase should be escrowed")
+	}
+	for _, c := range f.Calls() {
+		assert.NotContains(t, c.Args, "secret", "the passphrase shouldn't be passed as an argument")
+	}
+
+	volume, err = diskutil.ProvisionVolume(ctx, f, "disk4", diskutil.FormatAPFS, "Data", "/Volumes/data")
+	assert.NoError(t, err)
+	if assert.NotNil(t, volume) {
+		assert.Equal(t, "/Volumes/data", volume.MountPoint, "the encrypted volume should be reused")
+	}
+
+	_, err = diskutil.PrepareEncryptedVolume(ctx, f, "disk4", "Data", "other", e)
+	assert.NoError(t, err, "preparing should be repeatable")
+	_, err = diskutil.PrepareEncryptedVolume(ctx, f, "disk4", "Data", "other", &memoryEscrow{passphrases: map[string]string{}})
+	assert.Error(t, err, "volumes without an escrowed passphrase should be reported")
+}
+
+func TestDiskUtil_PrepareEncryptedVolume_EscrowFails(t *testing.T) {
+	ctx := context.Background()
+	f := New(bootDisk(), Disk{ID: "disk4", Size: 500_000_000_000})
+	e := &memoryEscrow{err: errors.New("access denied")}
+
+	_, err := diskutil.PrepareEncryptedVolume(ctx, f, "disk4", "Data", "secret", e)
+
+	assert.Error(t, err)
+	assert.Len(t, f.Disks()[1].Partitions, 1, "the container should be deleted when the passphrase can't be escrowed")
+}
+
+func TestDiskUtil_PrepareEncryptedVolume_Unencrypted(t *testing.T) {
+	ctx := context.Background()
+	f := New(bootDisk(), Disk{ID: "disk4", Size: 500_000_000_000})
+	_, err := diskutil.ProvisionVolume(ctx, f, "disk4", diskutil.FormatAPFS, "Data", "/Volumes/data")
+	assert.NoError(t, err)
+
+	_, err = diskutil.PrepareEncryptedVolume(ctx, f, "disk4", "Data", "secret", &memoryEscrow{passphrases: map[string]string{}})
+
+	assert.Error(t, err, "unencrypted volumes shouldn't be reused for encryption")
+}
+
+func TestDiskUtil_UnlockVolume(t *testing.T) {
+	ctx := context.Background()
+	f := New(bootDisk(), lockedDisk())
+	e := &memoryEscrow{passphrases: map[string]string{"F1B2C3D4-0000-0000-0000-000000000001": "secret"}}
+
+	locked, err := diskutil.LockedVolumes(ctx, f)
+	assert.NoError(t, err)
+	if assert.Len(t, locked, 1) {
+		assert.Equal(t, "disk5s1", locked[0].DeviceIdentifier)
+	}
+	_, err = f.Mount(ctx, "disk5s1", "")
+	assert.Error(t, err, "locked volumes can't be mounted")
+
+	assert.NoError(t, diskutil.UnlockVolume(ctx, diskutil.Dryrun(f), "disk5s1", e))
+	volume, err := f.Info(ctx, "disk5s1")
+	assert.NoError(t, err)
+	assert.True(t, volume.Locked, "dry runs shouldn't unlock the volume")
+
+	assert.NoError(t, diskutil.UnlockVolume(ctx, f, "disk5s1", e))
+	volume, err = f.Info(ctx, "disk5s1")
+	assert.NoError(t, err)
+	assert.False(t, volume.Locked)
+	assert.Equal(t, "/Volumes/Data", volume.MountPoint, "unlocking should mount the volume")
+
+	assert.NoError(t, diskutil.UnlockVolume(ctx, f, "disk5s1", &memoryEscrow{}), "unlocked volumes should be left as-is")
+	locked, err = diskutil.LockedVolumes(ctx, f)
+	assert.NoError(t, err)
+	assert.Empty(t, locked)
+}
+
+func TestDiskUtil_UnlockVolume_WrongPassphrase(t *testing.T) {
+	ctx := context.Background()
+	f := New(bootDisk(), lockedDisk())
+	e := &memoryEscrow{passphrases: map[string]string{"F1B2C3D4-0000-0000-0000-000000000001": "wrong"}}
+
+	assert.Error(t, diskutil.UnlockVolume(ctx, f, "disk5s1", e))
+}
@@ -0,0 +1,199 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+)
+
+// Escrow keeps the passphrases of encrypted volumes by the volume's UUID so that they can be unlocked later.
+type Escrow interface {
+	// Put stores the passphrase of the volume with the UUID.
+	Put(ctx context.Context, uuid string, passphrase string) error
+	// Get fetches the passphrase of the volume with the UUID.
+	Get(ctx context.Context, uuid string) (string, error)
+}
+
+// PrepareEncryptedVolume prepares an encrypted APFS data volume on the whole disk with the given device identifier
+// so that ProvisionVolume can mount it. Blank disks are given a container with a single volume, named label, which
+// is encrypted with the passphrase. The passphrase is put in escrow once the volume's UUID is known, and the
+// container is deleted again when that fails so that no volume is left without a way to unlock it. Disks that already
+// hold an encrypted data volume are reused, unlocking the volume with its passphrase from escrow when it's locked and
+// otherwise checking that its passphrase is in escrow, while disks that hold an unencrypted data volume are refused.
+//
+// The types.DiskInfo for the data volume is returned on success. No information is returned when the volume would
+// have been created in dry-run mode since there's no volume to inspect.
+func PrepareEncryptedVolume(ctx context.Context, u DiskUtil, id string, label string, passphrase string, e Escrow) (*types.DiskInfo, error) {
+	disk, err := u.Info(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get disk information: %w", err)
+	}
+	if !disk.WholeDisk {
+		return nil, fmt.Errorf("device [%s] is not a whole disk", disk.DeviceIdentifier)
+	}
+	if err := FormatAPFS.Validate(label, disk.TotalSize); err != nil {
+		return nil, err
+	}
+
+	logrus.WithField("device_id", disk.DeviceIdentifier).Info("Checking that device isn't the boot disk...")
+	if err := AssertNotBootDisk(ctx, u, disk); err != nil {
+		return nil, err
+	}
+	if err := AssertNotInternalDisk(disk); err != nil {
+		return nil, err
+	}
+
+	partitions, err := u.List(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list partitions: %w", err)
+	}
+
+	if volumeID := findDataVolume(partitions, disk.DeviceIdentifier); volumeID != "" {
+		volume, err := u.Info(ctx, volumeID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get volume information: %w", err)
+		}
+		if !volume.Encryption {
+			return nil, fmt.Errorf("device [%s] already has an unencrypted data volume [%s]", disk.DeviceIdentifier, volume.DeviceIdentifier)
+		}
+		logrus.WithFields(logrus.Fields{
+			"device_id": disk.DeviceIdentifier,
+			"volume_id": volume.DeviceIdentifier,
+		}).Info("Device already has an encrypted data volume, skipping format")
+
+		if volume.Locked {
+			if err := UnlockVolume(ctx, u, volume.DeviceIdentifier, e); err != nil {
+				return nil, err
+			}
+		} else if _, err := e.Get(ctx, volume.VolumeUUID); err != nil {
+			// Volumes created by provisioning that was interrupted before escrowing can't be unlocked again
+			return nil, fmt.Errorf("encrypted volume [%s] has no passphrase in escrow, it can't be unlocked once it's locked: %w", volume.DeviceIdentifier, err)
+		}
+
+		return u.Info(ctx, volume.DeviceIdentifier)
+	}
+
+	volume, err := createEncryptedVolume(ctx, u, disk.DeviceIdentifier, label, passphrase)
+	if err != nil || volume == nil {
+		return nil, err
+	}
+
+	logrus.WithField("volume_uuid", volume.VolumeUUID).Info("Escrowing volume passphrase...")
+	if err := e.Put(ctx, volume.VolumeUUID, passphrase); err != nil {
+		logrus.WithError(err).Error("Failed to escrow passphrase, deleting the volume's container")
+		if derr := DeleteContainer(ctx, u, volume.APFSContainerReference); derr != nil {
+			logrus.WithError(derr).Error("Failed to delete the volume's container")
+		}
+
+		return nil, fmt.Errorf("cannot escrow passphrase of volume [%s]: %w", volume.VolumeUUID, err)
+	}
+	logrus.WithField("volume_uuid", volume.VolumeUUID).Info("Successfully escrowed volume passphrase")
+
+	return volume, nil
+}
+
+// createEncryptedVolume creates a container on the blank disk, with CreateContainer, and adds a single volume to it
+// that's encrypted with the passphrase. The container is deleted again when the volume can't be added.
+func createEncryptedVolume(ctx context.Context, u DiskUtil, id string, label string, passphrase string) (*types.DiskInfo, error) {
+	container, err := CreateContainer(ctx, u, id)
+	if err != nil {
+		return nil, err
+	}
+	if container == nil {
+		logrus.WithField("label", label).Warn("Would have added encrypted volume")
+		return nil, nil
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"container_id": container.DeviceIdentifier,
+		"label":        label,
+	}).Info("Adding encrypted volume...")
+	out, err := u.AddVolume(ctx, container.DeviceIdentifier, string(FormatAPFS), label, passphrase)
+	logrus.WithField("out", out).Debug("AddVolume output")
+	if err != nil {
+		if derr := DeleteContainer(ctx, u, container.DeviceIdentifier); derr != nil {
+			logrus.WithError(derr).Error("Failed to delete the container")
+		}
+
+		return nil, err
+	}
+
+	partitions, err := u.List(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list partitions: %w", err)
+	}
+	volumeID := findDataVolume(partitions, id)
+	if volumeID == "" {
+		return nil, fmt.Errorf("no data volume found on device [%s] after adding it", id)
+	}
+	volume, err := u.Info(ctx, volumeID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get volume information: %w", err)
+	}
+	logrus.WithField("volume_id", volume.DeviceIdentifier).Info("Successfully added encrypted volume")
+
+	return volume, nil
+}
+
+// UnlockVolume unlocks the encrypted APFS volume with the given device identifier with its passphrase from escrow,
+// which also mounts it. Volumes that are already unlocked are left as-is without fetching their passphrase.
+//
+// In dry-run mode the volume isn't unlocked.
+func UnlockVolume(ctx context.Context, u DiskUtil, id string, e Escrow) error {
+	volume, err := u.Info(ctx, id)
+	if err != nil {
+		return fmt.Errorf("unable to get volume information: %w", err)
+	}
+	if !volume.Encryption {
+		return fmt.Errorf("volume [%s] is not encrypted", volume.DeviceIdentifier)
+	}
+	if !volume.Locked {
+		logrus.WithField("volume_id", volume.DeviceIdentifier).Info("Volume is already unlocked")
+		return nil
+	}
+
+	passphrase, err := e.Get(ctx, volume.VolumeUUID)
+	if err != nil {
+		return fmt.Errorf("cannot get passphrase of volume [%s]: %w", volume.DeviceIdentifier, err)
+	}
+
+	logrus.WithField("volume_id", volume.DeviceIdentifier).Info("Unlocking volume...")
+	out, err := u.UnlockVolume(ctx, volume.DeviceIdentifier, passphrase)
+	logrus.WithField("out", out).Debug("UnlockVolume output")
+	if errors.Is(err, ErrReadOnly) {
+		logrus.WithError(err).Warn("Would have unlocked volume")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	logrus.WithField("volume_id", volume.DeviceIdentifier).Info("Successfully unlocked volume")
+
+	return nil
+}
+
+// LockedVolumes finds the encrypted APFS volumes on the host that are locked.
+func LockedVolumes(ctx context.Context, u DiskUtil) ([]*types.DiskInfo, error) {
+	partitions, err := u.List(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list partitions: %w", err)
+	}
+
+	var locked []*types.DiskInfo
+	for _, part := range partitions.AllDisksAndPartitions {
+		for _, v := range part.APFSVolumes {
+			volume, err := u.Info(ctx, v.DeviceIdentifier)
+			if err != nil {
+				return nil, fmt.Errorf("unable to get volume information: %w", err)
+			}
+			if volume.Encryption && volume.Locked {
+				locked = append(locked, volume)
+			}
+		}
+	}
+
+	return locked, nil
+}
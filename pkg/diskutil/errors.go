@@ -0,0 +1,257 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+// ErrClass is a category of failures that callers can handle without matching error messages.
+type ErrClass string
+
+const (
+	// ClassUnknown is a failure that doesn't match any other class.
+	ClassUnknown ErrClass = "unknown"
+	// ClassBusy is a failure because the disk or volume is in use (e.g. it couldn't be unmounted). These failures
+	// are transient and usually succeed when retried.
+	ClassBusy ErrClass = "busy"
+	// ClassUnsupported is a failure because the operation isn't supported for the disk, its format, or the release.
+	ClassUnsupported ErrClass = "unsupported"
+	// ClassInsufficientSpace is a failure because there isn't enough space for the operation.
+	ClassInsufficientSpace ErrClass = "insufficient-space"
+	// ClassPermissionDenied is a failure because the operation requires more privileges (e.g. root).
+	ClassPermissionDenied ErrClass = "permission-denied"
+)
+
+// Transient checks if failures of the class may succeed when retried.
+func (c ErrClass) Transient() bool {
+	return c == ClassBusy
+}
+
+// ExitCode maps the class to the sysexits(3) code that the process should exit with.
+func (c ErrClass) ExitCode() int {
+	switch c {
+	case ClassBusy:
+		return 75 // EX_TEMPFAIL
+	case ClassUnsupported:
+		return 69 // EX_UNAVAILABLE
+	case ClassInsufficientSpace:
+		return 73 // EX_CANTCREAT
+	case ClassPermissionDenied:
+		return 77 // EX_NOPERM
+	default:
+		return 1
+	}
+}
+
+// ErrBusy is matched by errors from outside of diskutil that mean the disk is in use (e.g. another operation holding
+// the disk lock), so that they're classified as ClassBusy.
+var ErrBusy = errors.New("disk is busy")
+
+// classPatterns are the stderr patterns, matched without case, that identify each class of diskutil failure. The
+// numbers are DiskManagement's error codes which diskutil prints alongside its messages (e.g. "Error: -69888:").
+var classPatterns = []struct {
+	class    ErrClass
+	patterns []string
+}{
+	{class: ClassPermissionDenied, patterns: []string{"permission denied", "not permitted", "must be run as root", "requires root"}},
+	{class: ClassBusy, patterns: []string{"-69877", "-69888", "resource busy", "in use", "couldn't unmount", "could not unmount", "busy"}},
+	{class: ClassInsufficientSpace, patterns: []string{"not enough", "insufficient space", "too small", "no space left"}},
+	{class: ClassUnsupported, patterns: []string{"not supported", "unsupported", "unrecognized", "unknown verb"}},
+}
+
+// classifyStderr finds the class of a failure from the stderr of the command that failed.
+func classifyStderr(stderr string) ErrClass {
+	stderr = strings.ToLower(stderr)
+	for _, c := range classPatterns {
+		for _, p := range c.patterns {
+			if strings.Contains(stderr, p) {
+				return c.class
+			}
+		}
+	}
+
+	return ClassUnknown
+}
+
+// CommandError is a failed diskutil (or related) command along with the class of the failure.
+type CommandError struct {
+	// Class is the category of the failure.
+	Class ErrClass
+	// Stderr is the failed command's standard error.
+	Stderr string
+	// Err is the failure.
+	Err error
+}
+
+// newCommandError creates a CommandError for the failure, classified by the command's stderr.
+func newCommandError(stderr string, err error) *CommandError {
+	return &CommandError{Class: classifyStderr(stderr), Stderr: stderr, Err: err}
+}
+
+func (e *CommandError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// UnknownReleaseError is returned when there's no diskutil controller for the product's release.
+type UnknownReleaseError struct {
+	// Product is the product that isn't supported.
+	Product system.Product
+}
+
+func (e *UnknownReleaseError) Error() string {
+	return fmt.Sprintf("unknown release for %s", e.Product)
+}
+
+// DecodeError is returned when diskutil's plist output can't be decoded.
+type DecodeError struct {
+	// Kind describes the output that was being decoded (e.g. "list" or "disk info").
+	Kind string
+	// Err is the decoder's failure.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("error decoding %s: %v", e.Kind, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// NotAPFSError is returned when an operation that requires APFS (e.g. resizing a container) is given a disk with
+// another format.
+type NotAPFSError struct {
+	// DeviceIdentifier is the disk that isn't APFS.
+	DeviceIdentifier string
+}
+
+func (e *NotAPFSError) Error() string {
+	if e.DeviceIdentifier == "" {
+		return "disk is not apfs"
+	}
+
+	return fmt.Sprintf("disk [%s] is not apfs", e.DeviceIdentifier)
+}
+
+// RepairError is returned when repairing a disk finds problems with its partition map that diskutil didn't repair.
+type RepairError struct {
+	// DeviceIdentifier is the disk that was repaired.
+	DeviceIdentifier string
+	// Problems are the problems that diskutil reported.
+	Problems []string
+}
+
+func (e *RepairError) Error() string {
+	return fmt.Sprintf("partition map of [%s] has problems that weren't repaired: %s", e.DeviceIdentifier, strings.Join(e.Problems, "; "))
+}
+
+// PartialDataError is returned along with results that are missing some of their data because it couldn't be fetched
+// for some of the volumes (e.g. their APFS physical stores on Mojave). The rest of the results are still usable.
+type PartialDataError struct {
+	// Kind describes the data that's missing (e.g. "physical stores").
+	Kind string
+	// Total is the number of volumes that the data was fetched for.
+	Total int
+	// Unresolved are the volumes that the data couldn't be fetched for, in order.
+	Unresolved []UnresolvedVolume
+}
+
+// UnresolvedVolume is a volume that data couldn't be fetched for.
+type UnresolvedVolume struct {
+	// DeviceIdentifier is the volume's device identifier (e.g. "disk1").
+	DeviceIdentifier string
+	// Err is the failure to fetch the volume's data.
+	Err error
+}
+
+// DeviceIdentifiers gets the device identifiers of the unresolved volumes.
+func (e *PartialDataError) DeviceIdentifiers() []string {
+	ids := make([]string, 0, len(e.Unresolved))
+	for _, u := range e.Unresolved {
+		ids = append(ids, u.DeviceIdentifier)
+	}
+
+	return ids
+}
+
+func (e *PartialDataError) Error() string {
+	failures := make([]string, 0, len(e.Unresolved))
+	for _, u := range e.Unresolved {
+		failures = append(failures, fmt.Sprintf("%s: %v", u.DeviceIdentifier, u.Err))
+	}
+
+	return fmt.Sprintf("failed to fetch %d of %d %s: %s", len(e.Unresolved), e.Total, e.Kind, strings.Join(failures, "; "))
+}
+
+// Unwrap gets the first volume's failure so that the error is classified by it.
+func (e *PartialDataError) Unwrap() error {
+	if len(e.Unresolved) == 0 {
+		return nil
+	}
+
+	return e.Unresolved[0].Err
+}
+
+// Classify finds the class of the error. Errors that weren't classified when they were created (e.g. those that
+// didn't come from a command) are classified by their type where possible.
+func Classify(err error) ErrClass {
+	var cmdErr *CommandError
+	var freeSpaceErr FreeSpaceError
+	var notAPFSErr *NotAPFSError
+	var releaseErr *UnknownReleaseError
+	switch {
+	case err == nil:
+		return ClassUnknown
+	case errors.As(err, &cmdErr):
+		return cmdErr.Class
+	case errors.As(err, &freeSpaceErr):
+		return ClassInsufficientSpace
+	case errors.As(err, &notAPFSErr), errors.As(err, &releaseErr):
+		return ClassUnsupported
+	case errors.Is(err, ErrBusy):
+		return ClassBusy
+	case errors.Is(err, os.ErrPermission):
+		return ClassPermissionDenied
+	default:
+		return ClassUnknown
+	}
+}
+
+const (
+	// busyRetryAttempts is the number of times operations that fail because the disk is busy are attempted.
+	busyRetryAttempts = 3
+	// busyRetryDelay is the time waited between attempts of operations that failed because the disk is busy.
+	busyRetryDelay = 2 * time.Second
+)
+
+// retryTransient runs fn until it succeeds, fails with an error that isn't transient, or has been attempted the
+// given number of times. The last error is returned when all attempts fail.
+func retryTransient(ctx context.Context, attempts int, delay time.Duration, fn func() error) error {
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(delay):
+			}
+		}
+
+		err = fn()
+		if err == nil || !Classify(err).Transient() {
+			return err
+		}
+	}
+
+	return err
+}
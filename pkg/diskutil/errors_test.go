@@ -0,0 +1,96 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyStderr(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  ErrClass
+	}{
+		{name: "busy", input: "Error: -69888: Couldn't unmount disk", want: ClassBusy},
+		{name: "open", input: "Error: -69877: Couldn't open device", want: ClassBusy},
+		{name: "space", input: "Error: The target disk is too small for this operation", want: ClassInsufficientSpace},
+		{name: "unsupported", input: "Resizing is not supported for this volume", want: ClassUnsupported},
+		{name: "permission", input: "Error: Permission denied", want: ClassPermissionDenied},
+		{name: "unknown", input: "Error: -69808: Some information was unavailable", want: ClassUnknown},
+		{name: "empty", want: ClassUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, classifyStderr(tt.input))
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	busy := newCommandError("Error: -69888: Couldn't unmount disk", errors.New("unmount failed"))
+
+	assert.Equal(t, ClassBusy, Classify(busy))
+	assert.Equal(t, ClassBusy, Classify(fmt.Errorf("cannot provision: %w", &DiagnosedError{Err: busy})))
+	assert.Equal(t, ClassInsufficientSpace, Classify(fmt.Errorf("not enough space: %w", FreeSpaceError{})))
+	assert.Equal(t, ClassPermissionDenied, Classify(&os.PathError{Op: "open", Path: "/dev/rdisk2", Err: os.ErrPermission}))
+	assert.Equal(t, ClassUnknown, Classify(errors.New("error")))
+	assert.Equal(t, ClassUnknown, Classify(nil))
+}
+
+func TestErrClass_ExitCode(t *testing.T) {
+	assert.Equal(t, 1, ClassUnknown.ExitCode())
+	assert.Equal(t, 75, ClassBusy.ExitCode())
+	assert.Equal(t, 77, ClassPermissionDenied.ExitCode())
+}
+
+func TestRetryTransient(t *testing.T) {
+	busy := newCommandError("Resource busy", errors.New("busy"))
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "success", errs: []error{nil}, wantCalls: 1},
+		{name: "busy then success", errs: []error{busy, nil}, wantCalls: 2},
+		{name: "always busy", errs: []error{busy, busy, busy}, wantCalls: 3, wantErr: busy},
+		{name: "permanent", errs: []error{FreeSpaceError{}}, wantCalls: 1, wantErr: FreeSpaceError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := retryTransient(context.Background(), 3, 0, func() error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+
+			assert.Equal(t, tt.wantCalls, calls)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestPartialDataError(t *testing.T) {
+	busy := newCommandError("Error: -69877: Couldn't open device", errors.New("exit status 1"))
+	err := fmt.Errorf("list: %w", &PartialDataError{
+		Kind:  "physical stores",
+		Total: 3,
+		Unresolved: []UnresolvedVolume{
+			{DeviceIdentifier: "disk2", Err: busy},
+			{DeviceIdentifier: "disk3", Err: errors.New("physical store not found")},
+		},
+	})
+
+	var partialErr *PartialDataError
+	assert.True(t, errors.As(err, &partialErr))
+	assert.Equal(t, []string{"disk2", "disk3"}, partialErr.DeviceIdentifiers())
+	assert.EqualError(t, partialErr, "failed to fetch 2 of 3 physical stores: disk2: exit status 1; disk3: physical store not found")
+	assert.Equal(t, ClassBusy, Classify(err), "the error should be classified by the first failure")
+}
@@ -0,0 +1,23 @@
+package events
+
+import "context"
+
+// busKey is used to set and retrieve context held values for the Bus.
+type busKey struct{}
+
+// NewContext extends the context to provide the Bus that operations run with it publish their events to.
+func NewContext(ctx context.Context, bus *Bus) context.Context {
+	return context.WithValue(ctx, busKey{}, bus)
+}
+
+// FromContext fetches the Bus provided in ctx. A nil Bus, which discards events, is returned when none is set.
+func FromContext(ctx context.Context) *Bus {
+	if val := ctx.Value(busKey{}); val != nil {
+		if v, ok := val.(*Bus); ok {
+			return v
+		}
+		panic("incoherent context")
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+// Package events provides an event bus which the diskutil package's major operations (e.g. growing a container)
+// publish their lifecycle to, so that observability (e.g. logs, metrics, and notifications) is kept out of the
+// operations themselves. Operations publish to the Bus in their context, see NewContext.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Operation is a major operation that publishes events.
+type Operation string
+
+const (
+	// OperationGrow is growing an APFS container to its maximum size.
+	OperationGrow Operation = "grow"
+	// OperationRepair is repairing a disk, which also updates the kernel's view of its partitions.
+	OperationRepair Operation = "repair"
+	// OperationResize is resizing an APFS container.
+	OperationResize Operation = "resize"
+	// OperationProvision is provisioning a data volume.
+	OperationProvision Operation = "provision"
+)
+
+// Kind is the stage of an operation's lifecycle that an event describes.
+type Kind string
+
+const (
+	// KindStarted is published when an operation starts.
+	KindStarted Kind = "started"
+	// KindFinished is published when an operation finishes successfully.
+	KindFinished Kind = "finished"
+	// KindFailed is published when an operation fails.
+	KindFailed Kind = "failed"
+)
+
+// Event is a change in an operation's lifecycle.
+type Event struct {
+	// Operation is the operation that published the event.
+	Operation Operation `json:"operation"`
+	// Kind is the stage of the operation's lifecycle.
+	Kind Kind `json:"kind"`
+	// Device is the device identifier that the operation was run on.
+	Device string `json:"device,omitempty"`
+	// Time is when the event was published.
+	Time time.Time `json:"time"`
+	// Duration is how long the operation ran for, which is only set when it finished or failed.
+	Duration time.Duration `json:"duration,omitempty"`
+	// Err is why the operation failed.
+	Err error `json:"-"`
+}
+
+// Subscriber receives the events published to a Bus.
+type Subscriber interface {
+	// Handle is called with each event as it's published. Events are delivered synchronously so subscribers that
+	// block (e.g. on the network) should hand events off rather than handling them inline.
+	Handle(e Event)
+}
+
+// SubscriberFunc adapts a function to a Subscriber.
+type SubscriberFunc func(e Event)
+
+// Handle calls f with the event.
+func (f SubscriberFunc) Handle(e Event) {
+	f(e)
+}
+
+// Bus delivers published events to its subscribers. Methods on a nil Bus do nothing so that operations can publish
+// events without checking whether anything is subscribed.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []Subscriber
+}
+
+// NewBus creates a Bus with the subscribers.
+func NewBus(subscribers ...Subscriber) *Bus {
+	return &Bus{subscribers: subscribers}
+}
+
+// Subscribe adds the subscriber to the bus.
+func (b *Bus) Subscribe(s Subscriber) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, s)
+}
+
+// Publish delivers the event to each subscriber in the order they subscribed. The event's time is set when it
+// isn't already.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+
+	for _, s := range subscribers {
+		s.Handle(e)
+	}
+}
+
+// Span tracks a running operation so that its outcome and duration are published when it ends.
+type Span struct {
+	bus       *Bus
+	operation Operation
+	device    string
+	start     time.Time
+}
+
+// Start publishes that the operation started on the device and returns a Span for ending it.
+func (b *Bus) Start(operation Operation, device string) *Span {
+	s := &Span{bus: b, operation: operation, device: device, start: time.Now()}
+	b.Publish(Event{Operation: operation, Kind: KindStarted, Device: device, Time: s.start})
+
+	return s
+}
+
+// End publishes that the operation finished, or that it failed when err is set.
+func (s *Span) End(err error) {
+	e := Event{
+		Operation: s.operation,
+		Kind:      KindFinished,
+		Device:    s.device,
+		Duration:  time.Since(s.start),
+		Err:       err,
+	}
+	if err != nil {
+		e.Kind = KindFailed
+	}
+	s.bus.Publish(e)
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder is a Subscriber which records the events it's delivered.
+type recorder struct {
+	events []Event
+}
+
+func (r *recorder) Handle(e Event) {
+	r.events = append(r.events, e)
+}
+
+func TestBus_Span(t *testing.T) {
+	r := &recorder{}
+	bus := NewBus(r)
+
+	bus.Start(OperationGrow, "disk1").End(nil)
+	bus.Start(OperationResize, "disk0").End(errors.New("resize failed"))
+
+	assert.Len(t, r.events, 4)
+	assert.Equal(t, []Kind{KindStarted, KindFinished, KindStarted, KindFailed},
+		[]Kind{r.events[0].Kind, r.events[1].Kind, r.events[2].Kind, r.events[3].Kind})
+	assert.Equal(t, OperationResize, r.events[3].Operation)
+	assert.Equal(t, "disk0", r.events[3].Device)
+	assert.EqualError(t, r.events[3].Err, "resize failed")
+	assert.False(t, r.events[0].Time.IsZero())
+}
+
+func TestBus_Subscribe(t *testing.T) {
+	var got []Operation
+	bus := NewBus()
+	bus.Subscribe(SubscriberFunc(func(e Event) {
+		got = append(got, e.Operation)
+	}))
+
+	bus.Publish(Event{Operation: OperationRepair, Kind: KindStarted})
+
+	assert.Equal(t, []Operation{OperationRepair}, got)
+}
+
+func TestBus_Nil(t *testing.T) {
+	var bus *Bus
+
+	assert.NotPanics(t, func() {
+		bus.Subscribe(LogSubscriber{})
+		bus.Start(OperationGrow, "disk1").End(nil)
+	})
+}
+
+func TestTracker(t *testing.T) {
+	tracker := &Tracker{}
+	bus := NewBus(tracker)
+
+	grow := bus.Start(OperationGrow, "disk1")
+	bus.Start(OperationRepair, "disk0").End(nil)
+	bus.Start(OperationResize, "disk0").End(errors.New("resize failed"))
+	bus.Start(OperationResize, "disk0")
+
+	records := tracker.Records()
+	assert.Len(t, records, 4)
+	assert.Equal(t, []Kind{KindStarted, KindFinished, KindFailed, KindStarted},
+		[]Kind{records[0].Kind, records[1].Kind, records[2].Kind, records[3].Kind})
+	assert.EqualError(t, records[2].Err, "resize failed")
+
+	grow.End(nil)
+	assert.Equal(t, KindFinished, tracker.Records()[0].Kind, "should update the running operation")
+}
@@ -0,0 +1,25 @@
+package events
+
+import "github.com/sirupsen/logrus"
+
+// LogSubscriber logs events. Failures are logged as warnings since the operation's caller reports the error itself.
+type LogSubscriber struct{}
+
+// Handle logs the event.
+func (LogSubscriber) Handle(e Event) {
+	entry := logrus.WithFields(logrus.Fields{
+		"operation": e.Operation,
+		"kind":      e.Kind,
+		"device_id": e.Device,
+	})
+	if e.Duration != 0 {
+		entry = entry.WithField("duration", e.Duration)
+	}
+
+	switch e.Kind {
+	case KindFailed:
+		entry.WithError(e.Err).Warn("Operation failed")
+	default:
+		entry.Debug("Operation " + string(e.Kind))
+	}
+}
@@ -0,0 +1,79 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Record is the latest state of an operation seen by a Tracker.
+type Record struct {
+	// Operation is the operation that was run.
+	Operation Operation
+	// Device is the device identifier that the operation was run on.
+	Device string
+	// Kind is the latest stage of the operation's lifecycle, which is KindStarted while it's still running.
+	Kind Kind
+	// Started is when the operation started.
+	Started time.Time
+	// Duration is how long the operation ran for, which is only set when it finished or failed.
+	Duration time.Duration
+	// Err is why the operation failed.
+	Err error
+}
+
+// Tracker is a Subscriber that records the state of each operation so that what had and hadn't completed can be
+// reported when the process is interrupted.
+type Tracker struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+// Handle records the event. Finished and failed events update the latest running operation on the same device.
+func (t *Tracker) Handle(e Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if e.Kind == KindStarted {
+		t.records = append(t.records, Record{Operation: e.Operation, Device: e.Device, Kind: KindStarted, Started: e.Time})
+		return
+	}
+
+	for i := len(t.records) - 1; i >= 0; i-- {
+		r := &t.records[i]
+		if r.Operation == e.Operation && r.Device == e.Device && r.Kind == KindStarted {
+			r.Kind = e.Kind
+			r.Duration = e.Duration
+			r.Err = e.Err
+			return
+		}
+	}
+}
+
+// Records returns the operations in the order they started.
+func (t *Tracker) Records() []Record {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]Record(nil), t.records...)
+}
+
+// LogSummary logs the state of each operation, warning about those that were still running.
+func (t *Tracker) LogSummary() {
+	for _, r := range t.Records() {
+		entry := logrus.WithFields(logrus.Fields{
+			"operation": r.Operation,
+			"device_id": r.Device,
+		})
+
+		switch r.Kind {
+		case KindStarted:
+			entry.WithField("running_for", time.Since(r.Started).Round(time.Millisecond)).Warn("Operation interrupted before it finished")
+		case KindFailed:
+			entry.WithError(r.Err).WithField("duration", r.Duration).Warn("Operation failed")
+		default:
+			entry.WithField("duration", r.Duration).Info("Operation finished")
+		}
+	}
+}
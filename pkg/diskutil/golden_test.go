@@ -0,0 +1,68 @@
+package diskutil
+
+import (
+	"flag"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/ec2-macos-utils/internal/fixture"
+)
+
+// update is set to rewrite the golden files with the values decoded from the fixture corpus.
+var update = flag.Bool("update", false, "update the golden files with the decoded fixtures")
+
+// TestDecoder_Golden decodes the plist output in the fixture corpus and compares it with the golden files in
+// testdata/golden so that changes to the types are reviewed as changes to the data they decode. Run the tests with
+// -update to accept the changes.
+func TestDecoder_Golden(t *testing.T) {
+	fsys, err := fs.Sub(fixturesFS, "testdata/fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := &PlistDecoder{}
+	for _, p := range fixtureProducts {
+		release := fixture.ReleaseDir(p.Release)
+		fixtures, err := fixture.Load(fsys, release)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, f := range fixtures {
+			name := fixture.Name(f.Command)
+			t.Run(release+"/"+name, func(t *testing.T) {
+				var v interface{}
+				var err error
+				switch {
+				case isCommand(f.Command, "diskutil", "list", "-plist"):
+					v, err = decoder.DecodeSystemPartitions(strings.NewReader(f.Stdout))
+				case isCommand(f.Command, "diskutil", "info", "-plist"):
+					v, err = decoder.DecodeDiskInfo(strings.NewReader(f.Stdout))
+				default:
+					t.Skip("fixture isn't plist output")
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				fixture.AssertGolden(t, filepath.Join("testdata", "golden", release, name+".json"), v, *update)
+			})
+		}
+	}
+}
+
+// isCommand checks if the command starts with the prefix and has at most one more argument.
+func isCommand(c []string, prefix ...string) bool {
+	if len(c) < len(prefix) || len(c) > len(prefix)+1 {
+		return false
+	}
+	for i := range prefix {
+		if c[i] != prefix[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,329 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/events"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sirupsen/logrus"
+)
+
+// GrowContainer grows a container to its maximum size by applying the Plan from PlanGrow, which performs the
+// following operations:
+//  1. Verify that the given types.DiskInfo is an APFS container that can be resized. APFS volumes are resolved to
+//     the container that holds them.
+//  2. Fetch the types.DiskInfo for the underlying physical disk (if the container isn't a physical device).
+//  3. Repair the parent disk to force the kernel to get the latest GPT information for the disk. Problems with the
+//     partition map that the repair reports but doesn't fix are returned as a RepairError.
+//  4. Check if there's enough free space on the disk to perform an APFS.ResizeContainer.
+//  5. Find the processes and mounted volumes using the disk, unmounting the volumes with WithUnmountBusy. Resizes
+//     that fail because the disk is busy return a BusyError with what was found.
+//  6. Resize the container to its maximum size.
+//
+// The grow, repair, and resize operations are published to the events Bus in ctx.
+func GrowContainer(ctx context.Context, u DiskUtil, container *types.DiskInfo, opts ...GrowOption) error {
+	cfg := &growConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	var device string
+	if container != nil {
+		device = container.DeviceIdentifier
+	}
+
+	span := events.FromContext(ctx).Start(events.OperationGrow, device)
+	err := growContainer(ctx, u, container, cfg)
+	span.End(err)
+
+	return err
+}
+
+// GrowOption customizes how GrowContainer grows a container.
+type GrowOption func(c *growConfig)
+
+// growConfig holds the configuration set by GrowOptions.
+type growConfig struct {
+	unmountBusy bool
+}
+
+// WithUnmountBusy unmounts the volumes that are using the disk (other than the system's) before the container is
+// resized. Processes that have the disk open aren't stopped, they're only reported.
+func WithUnmountBusy() GrowOption {
+	return func(c *growConfig) {
+		c.unmountBusy = true
+	}
+}
+
+// PlanGrow computes the Plan for growing the container as described by GrowContainer without changing any disks. The
+// container is checked and its physical disk resolved while planning, the free space on the disk is only checked
+// when the plan is applied since it's the repair that makes space added to the disk available.
+func PlanGrow(ctx context.Context, u DiskUtil, container *types.DiskInfo, opts ...GrowOption) (*Plan, error) {
+	cfg := &growConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return planGrow(ctx, u, container, cfg)
+}
+
+// growContainer grows the container as described by GrowContainer.
+func growContainer(ctx context.Context, u DiskUtil, container *types.DiskInfo, cfg *growConfig) error {
+	plan, err := planGrow(ctx, u, container, cfg)
+	if err != nil {
+		return err
+	}
+
+	return plan.Apply(ctx, u)
+}
+
+// planGrow computes the Plan for growing the container as described by PlanGrow.
+func planGrow(ctx context.Context, u DiskUtil, container *types.DiskInfo, cfg *growConfig) (*Plan, error) {
+	if container == nil {
+		return nil, fmt.Errorf("unable to resize nil container")
+	}
+	plan := &Plan{Operation: "grow", Device: container.DeviceIdentifier}
+
+	// Volumes are resized by resizing the container that holds them, which diskutil won't do for them
+	if ref := container.APFSContainerReference; ref != "" && !strings.EqualFold(ref, container.DeviceIdentifier) {
+		logrus.WithFields(logrus.Fields{
+			"device_id":    container.DeviceIdentifier,
+			"container_id": ref,
+		}).Info("Device is an APFS volume, resizing the container that holds it instead")
+		c, err := u.Info(ctx, ref)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get container information: %w", err)
+		}
+		container = c
+	}
+
+	logrus.WithField("device_id", container.DeviceIdentifier).Info("Checking if device can be APFS resized...")
+	if err := canAPFSResize(container); err != nil {
+		return nil, fmt.Errorf("unable to resize container: %w", err)
+	}
+	logrus.Info("Device can be resized")
+
+	// We'll need to mutate the container's underlying physical disk, so resolve that if that's not what we have
+	// (which is basically guaranteed to not have physical disk for container resizes, should be the virtual APFS
+	// container).
+	phy := container
+	if !phy.IsPhysical() {
+		parent, err := u.Info(ctx, phy.ParentWholeDisk)
+		if err != nil {
+			return nil, fmt.Errorf("unable to determine physical disk: %w", err)
+		}
+		// using the parent disk of provided disk (probably a container)
+		phy = parent
+	}
+	logrus.WithFields(logrus.Fields{
+		"device_id": phy.DeviceIdentifier,
+		"kind":      phy.Kind(),
+	}).Info("Resolved physical disk")
+
+	repairID, err := phy.ParentDeviceID()
+	if err != nil {
+		repairID = phy.DeviceIdentifier
+	}
+	plan.add(ActionRepair, repairID, "update the partition map with any space added to the disk", func(ctx context.Context, u DiskUtil) error {
+		return repairForGrow(ctx, u, phy)
+	})
+
+	reason := fmt.Sprintf("grow container [%s] to its maximum size", container.DeviceIdentifier)
+	if cfg.unmountBusy {
+		reason += ", unmounting the volumes using the disk first"
+	}
+	plan.add(ActionResize, phy.DeviceIdentifier, reason, func(ctx context.Context, u DiskUtil) error {
+		return resizeForGrow(ctx, u, phy, container, cfg)
+	})
+
+	return plan, nil
+}
+
+// repairForGrow repairs the physical disk's parent to capture any free space on a resized disk. Problems with the
+// partition map that the repair reports but doesn't fix are returned as a RepairError.
+func repairForGrow(ctx context.Context, u DiskUtil, phy *types.DiskInfo) error {
+	logrus.Info("Repairing the parent disk...")
+	repair, err := repairParentDisk(ctx, u, phy)
+	if err != nil {
+		return fmt.Errorf("cannot update free space on disk: %w", err)
+	}
+	switch repair.Outcome {
+	case RepairErrorsFound:
+		return fmt.Errorf("cannot update free space on disk: %w", &RepairError{DeviceIdentifier: phy.DeviceIdentifier, Problems: repair.Problems})
+	case RepairRepaired:
+		logrus.WithField("problems", repair.Problems).Info("Successfully repaired the parent disk")
+	case RepairClean:
+		logrus.Info("Parent disk is already clean")
+	default:
+		logrus.Info("Finished repairing the parent disk")
+	}
+	if repair.Outcome != RepairUnknown && !repair.FreeSpaceUpdated {
+		logrus.Warn("Repair didn't adjust the partition map to fit the disk, free space may not include recently added space")
+	}
+
+	return nil
+}
+
+// resizeForGrow checks that there's enough free space on the physical disk and resizes the container to its maximum
+// size. What's using the disk is found first so that it can be unmounted or reported when the disk is busy.
+func resizeForGrow(ctx context.Context, u DiskUtil, phy, container *types.DiskInfo, cfg *growConfig) error {
+	// Minimum free space to resize required - bail if we don't have enough.
+	logrus.WithField("device_id", phy.DeviceIdentifier).Info("Fetching amount of free space on device...")
+	partitions, err := u.List(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot determine available space on disk: %w", err)
+	}
+	totalFree, err := diskFreeSpace(partitions, phy)
+	if err != nil {
+		return fmt.Errorf("cannot determine available space on disk: %w", err)
+	}
+	logrus.WithField("freed_bytes", humanize.Bytes(totalFree)).Trace("updated free space on disk")
+	if totalFree < minimumGrowFreeSpace {
+		logrus.WithFields(logrus.Fields{
+			"total_free":       humanize.Bytes(totalFree),
+			"required_minimum": humanize.Bytes(minimumGrowFreeSpace),
+		}).Warn("Available free space does not meet required minimum to grow")
+		return fmt.Errorf("not enough space to resize container: %w", FreeSpaceError{FreeSpaceBytes: totalFree})
+	}
+
+	// Resizing fails with "Resource busy" while the disk is in use, so find what's using it to unmount or report it
+	busy, err := detectBusy(ctx, partitions, phy, container)
+	if err != nil {
+		logrus.WithError(err).Warn("Unable to find the processes using the disk, they won't be reported")
+	}
+	if len(busy.mounted) > 0 && cfg.unmountBusy {
+		if busy.mounted, err = unmountBusy(ctx, u, busy.mounted); err != nil {
+			return fmt.Errorf("unable to unmount volumes using the disk: %w", err)
+		}
+	}
+	if !busy.empty() {
+		logrus.WithFields(logrus.Fields{
+			"holders": busy.holders,
+			"mounted": busy.mounted,
+		}).Warn("Disk is in use, resizing the container may fail")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"device_id":  phy.DeviceIdentifier,
+		"free_space": humanize.Bytes(totalFree),
+	}).Info("Resizing container to maximum size...")
+	span := events.FromContext(ctx).Start(events.OperationResize, phy.DeviceIdentifier)
+	out, err := u.ResizeContainer(ctx, phy.DeviceIdentifier, "0")
+	logrus.WithField("out", out).Debug("Resize output")
+	if errors.Is(err, ErrReadOnly) {
+		span.End(nil)
+		logrus.WithError(err).Warn("Would have resized container to max size")
+	} else if err != nil {
+		if Classify(err) == ClassBusy && !busy.empty() {
+			err = &BusyError{DeviceIdentifier: phy.DeviceIdentifier, Holders: busy.holders, Mounted: busy.mounted, Err: err}
+		}
+		span.End(err)
+		return err
+	}
+	span.End(nil)
+
+	return nil
+}
+
+// canAPFSResize does some basic checking on a types.DiskInfo to see if it matches the criteria necessary for
+// APFS.ResizeContainer to succeed. It checks that the types.ContainerInfo is not empty and that the
+// types.ContainerInfo's FilesystemType is "apfs".
+func canAPFSResize(disk *types.DiskInfo) error {
+	if disk == nil {
+		return errors.New("no disk information")
+	}
+
+	// If the disk has ContainerInfo, check the FilesystemType
+	if (disk.ContainerInfo != types.ContainerInfo{}) {
+		if disk.ContainerInfo.FilesystemType == "apfs" {
+			return nil
+		}
+	}
+
+	// Check if the disk has an APFS Container reference and APFS Physical Stores
+	if disk.APFSContainerReference != "" && len(disk.APFSPhysicalStores) > 0 {
+		return nil
+	}
+
+	return &NotAPFSError{DeviceIdentifier: disk.DeviceIdentifier}
+}
+
+// getDiskFreeSpace calculates the amount of free space a disk has available by summing the sizes of each partition
+// and then subtracting that from the total size. See types.SystemPartitions for more information.
+func getDiskFreeSpace(ctx context.Context, util DiskUtil, disk *types.DiskInfo) (uint64, error) {
+	partitions, err := util.List(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return diskFreeSpace(partitions, disk)
+}
+
+// diskFreeSpace calculates the amount of free space the disk has available in the partitions.
+func diskFreeSpace(partitions *types.SystemPartitions, disk *types.DiskInfo) (uint64, error) {
+	parentDiskID, err := disk.ParentDeviceID()
+	if err != nil {
+		return 0, err
+	}
+
+	return partitions.AvailableDiskSpace(parentDiskID)
+}
+
+// repairParentDisk attempts to find and repair the parent device for the given disk in order to update the current
+// amount of free space available. The repair's output is parsed with ParseRepair, the zero RepairResult is returned
+// when the repair is skipped by a dry run.
+func repairParentDisk(ctx context.Context, utility DiskUtil, disk *types.DiskInfo) (RepairResult, error) {
+	// Get the device identifier for the parent disk
+	parentDiskID, err := disk.ParentDeviceID()
+	if err != nil {
+		return RepairResult{}, fmt.Errorf("failed to get the parent disk ID for container [%s]: %w", disk.DeviceIdentifier, err)
+	}
+
+	// Attempt to repair the container's parent disk
+	logrus.WithField("parent_id", parentDiskID).Info("Repairing parent disk...")
+	span := events.FromContext(ctx).Start(events.OperationRepair, parentDiskID)
+	// Repairs fail while another process has the disk open so they're retried when the disk is busy
+	var out string
+	err = retryTransient(ctx, busyRetryAttempts, busyRetryDelay, func() error {
+		var err error
+		out, err = utility.RepairDisk(ctx, parentDiskID)
+		return err
+	})
+	logrus.WithField("out", out).Debug("RepairDisk output")
+	if errors.Is(err, ErrReadOnly) {
+		span.End(nil)
+		logrus.WithError(err).Warn("Would have repaired parent disk")
+		return RepairResult{}, nil
+	} else if err != nil {
+		span.End(err)
+		return ParseRepair(out), err
+	}
+	span.End(nil)
+
+	return ParseRepair(out), nil
+}
+
+// unmountBusy unmounts the volumes, returning the ones that are still mounted (i.e. all of them in a dry run).
+func unmountBusy(ctx context.Context, u DiskUtil, volumes []string) ([]string, error) {
+	var mounted []string
+	for _, id := range volumes {
+		logrus.WithField("device_id", id).Info("Unmounting volume using the disk...")
+		out, err := u.Unmount(ctx, id)
+		logrus.WithField("out", out).Debug("Unmount output")
+		if errors.Is(err, ErrReadOnly) {
+			logrus.WithError(err).WithField("device_id", id).Warn("Would have unmounted volume")
+			mounted = append(mounted, id)
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to unmount [%s]: %w", id, err)
+		}
+		logrus.WithField("device_id", id).Info("Successfully unmounted volume")
+	}
+
+	return mounted, nil
+}
@@ -2,12 +2,14 @@ package diskutil
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"testing"
 
-	mock_diskutil "github.com/aws/ec2-macos-utils/internal/diskutil/mocks"
-	"github.com/aws/ec2-macos-utils/internal/diskutil/types"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/events"
+	mock_diskutil "github.com/aws/ec2-macos-utils/pkg/diskutil/mocks"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
 
 	"github.com/golang/mock/gomock"
 	"github.com/sirupsen/logrus"
@@ -16,6 +18,8 @@ import (
 
 func init() {
 	logrus.SetOutput(ioutil.Discard)
+	// Don't look for the processes using the disks with lsof, the tests' disks aren't the host's
+	findHolders = func(ctx context.Context, paths []string) ([]Holder, error) { return nil, nil }
 }
 
 func TestGrowContainer_WithoutContainer(t *testing.T) {
@@ -39,7 +43,9 @@ func TestGrowContainer_WithEmptyContainer(t *testing.T) {
 
 	err := GrowContainer(context.Background(), mockUtility, &disk)
 
+	var notAPFSErr *NotAPFSError
 	assert.Error(t, err, "shouldn't be able to grow container with empty container")
+	assert.True(t, errors.As(err, &notAPFSErr), "should get NotAPFSError since the container isn't apfs")
 }
 
 func TestGrowContainer_WithInfoErr(t *testing.T) {
@@ -91,6 +97,35 @@ func TestGrowContainer_WithRepairDiskErr(t *testing.T) {
 	assert.Error(t, err, "shouldn't be able to grow container with repair disk error")
 }
 
+func TestGrowContainer_WithRepairProblems(t *testing.T) {
+	const testDiskID = "disk1"
+	var ctx = context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	mockUtility.EXPECT().RepairDisk(ctx, testDiskID).Return("Problems were found with the partition map which might prevent booting\n", nil)
+
+	disk := types.DiskInfo{
+		APFSPhysicalStores: []types.APFSPhysicalStore{
+			{DeviceIdentifier: testDiskID},
+		},
+		ContainerInfo: types.ContainerInfo{
+			FilesystemType: "apfs",
+		},
+		DeviceIdentifier:  testDiskID,
+		ParentWholeDisk:   testDiskID,
+		VirtualOrPhysical: "Physical",
+	}
+
+	err := GrowContainer(ctx, mockUtility, &disk)
+
+	var repairErr *RepairError
+	assert.True(t, errors.As(err, &repairErr), "should fail with the repair's problems")
+	assert.Equal(t, testDiskID, repairErr.DeviceIdentifier)
+}
+
 func TestGrowContainer_WithListError(t *testing.T) {
 	const testDiskID = "disk1"
 	var ctx = context.Background()
@@ -165,7 +200,7 @@ func TestGrowContainer_WithoutFreeSpace(t *testing.T) {
 		VirtualOrPhysical: "Physical",
 	}
 
-	expectedErr := fmt.Errorf("not enough space to resize container: %w", FreeSpaceError{expectedFreeSpace})
+	expectedErr := fmt.Errorf("not enough space to resize container: %w", FreeSpaceError{FreeSpaceBytes: expectedFreeSpace})
 
 	actualErr := GrowContainer(context.Background(), mockUtility, &disk)
 
@@ -491,12 +526,11 @@ func TestRepairParentDisk_WithoutDiskInfo(t *testing.T) {
 	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
 
 	disk := types.DiskInfo{}
-	expectedMessage := fmt.Sprintf("failed to get the parent disk ID for container [%s]", disk.DeviceIdentifier)
 
-	actualMessage, err := repairParentDisk(context.Background(), mockUtility, &disk)
+	result, err := repairParentDisk(context.Background(), mockUtility, &disk)
 
 	assert.Error(t, err, "shouldn't be able to repair disk without disk info")
-	assert.Equal(t, expectedMessage, actualMessage, "should see error message for device")
+	assert.Equal(t, RepairResult{}, result, "shouldn't have a repair result")
 }
 
 func TestRepairParentDisk_WithRepairDiskErr(t *testing.T) {
@@ -514,18 +548,17 @@ func TestRepairParentDisk_WithRepairDiskErr(t *testing.T) {
 			{DeviceIdentifier: testDiskID},
 		},
 	}
-	expectedMessage := "error"
 
-	actualMessage, err := repairParentDisk(context.Background(), mockUtility, &disk)
+	result, err := repairParentDisk(context.Background(), mockUtility, &disk)
 
 	assert.Error(t, err, "shouldn't be able to repair parent disk with repair disk error")
-	assert.Equal(t, expectedMessage, actualMessage, "should see error message for device")
+	assert.Equal(t, "error", result.Output, "should see output of the repair")
 }
 
 func TestRepairParentDisk_Success(t *testing.T) {
 	const (
-		testDiskID      = "disk0"
-		expectedMessage = ""
+		testDiskID = "disk0"
+		testOutput = "Adjusting partition map to fit whole disk as required\nThe partition map appears to be OK\n"
 	)
 	var ctx = context.Background()
 
@@ -533,7 +566,7 @@ func TestRepairParentDisk_Success(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
-	mockUtility.EXPECT().RepairDisk(ctx, testDiskID).Return("", nil)
+	mockUtility.EXPECT().RepairDisk(ctx, testDiskID).Return(testOutput, nil)
 
 	disk := types.DiskInfo{
 		APFSPhysicalStores: []types.APFSPhysicalStore{
@@ -541,8 +574,63 @@ func TestRepairParentDisk_Success(t *testing.T) {
 		},
 	}
 
-	actualMessage, err := repairParentDisk(context.Background(), mockUtility, &disk)
+	result, err := repairParentDisk(context.Background(), mockUtility, &disk)
 
 	assert.NoError(t, err, "should be able to repair parent with valid data")
-	assert.Equal(t, expectedMessage, actualMessage, "should see expected message")
+	assert.Equal(t, RepairClean, result.Outcome, "should parse the repair's outcome")
+	assert.True(t, result.FreeSpaceUpdated, "should parse the free space update")
+}
+
+func TestGrowContainer_PublishesEvents(t *testing.T) {
+	const testDiskID = "disk1"
+
+	var published []events.Event
+	bus := events.NewBus(events.SubscriberFunc(func(e events.Event) {
+		published = append(published, e)
+	}))
+	ctx := events.NewContext(context.Background(), bus)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	mockUtility.EXPECT().RepairDisk(ctx, testDiskID).Return("", fmt.Errorf("error"))
+
+	disk := types.DiskInfo{
+		APFSPhysicalStores: []types.APFSPhysicalStore{
+			{DeviceIdentifier: testDiskID},
+		},
+		ContainerInfo: types.ContainerInfo{
+			FilesystemType: "apfs",
+		},
+		DeviceIdentifier:  testDiskID,
+		ParentWholeDisk:   testDiskID,
+		VirtualOrPhysical: "Physical",
+	}
+
+	err := GrowContainer(ctx, mockUtility, &disk)
+
+	assert.Error(t, err)
+	assert.Len(t, published, 4)
+	assert.Equal(t, events.OperationGrow, published[0].Operation)
+	assert.Equal(t, events.KindStarted, published[0].Kind)
+	assert.Equal(t, events.OperationRepair, published[2].Operation)
+	assert.Equal(t, events.KindFailed, published[2].Kind)
+	assert.Equal(t, events.OperationGrow, published[3].Operation)
+	assert.Equal(t, events.KindFailed, published[3].Kind)
+	assert.Equal(t, testDiskID, published[3].Device)
+}
+
+func TestGrowContainer_WithVolume(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	volume := &types.DiskInfo{DeviceIdentifier: "disk1s5", APFSContainerReference: "disk1", ParentWholeDisk: "disk1"}
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	mockUtility.EXPECT().Info(gomock.Any(), "disk1").Return(nil, errors.New("error"))
+
+	err := GrowContainer(context.Background(), mockUtility, volume)
+
+	assert.EqualError(t, err, "unable to get container information: error", "should resolve the volume's container")
 }
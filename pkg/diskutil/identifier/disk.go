@@ -0,0 +1,32 @@
+package identifier
+
+import (
+	"regexp"
+	"strings"
+)
+
+// diskIDExp is the regexp expression for device identifiers.
+var diskIDExp = regexp.MustCompile("disk[0-9]+")
+
+// ParseDiskID parses a supported disk identifier from a string.
+func ParseDiskID(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return ""
+	}
+	return diskIDExp.FindString(s)
+}
+
+// canonicalIDExp matches device identifiers (e.g. "disk2s1") and their device nodes, raw or not (e.g.
+// "/dev/rdisk2s1"), capturing the device identifier.
+var canonicalIDExp = regexp.MustCompile(`^(?:/dev/)?r?(disk[0-9]+(?:s[0-9]+)*)$`)
+
+// Normalize gets the canonical device identifier (e.g. "disk2s1") from a device identifier or a device node, raw or
+// not (e.g. "/dev/rdisk2s1"). Normalize reports whether s is one of them.
+func Normalize(s string) (string, bool) {
+	m := canonicalIDExp.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return "", false
+	}
+
+	return m[1], true
+}
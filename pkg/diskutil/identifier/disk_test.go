@@ -52,3 +52,28 @@ func TestParseDiskID(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   string
+		wantOK bool
+	}{
+		{input: "disk2", want: "disk2", wantOK: true},
+		{input: "disk3s1s1", want: "disk3s1s1", wantOK: true},
+		{input: "/dev/disk2s1", want: "disk2s1", wantOK: true},
+		{input: "/dev/rdisk2s1", want: "disk2s1", wantOK: true},
+		{input: "rdisk0", want: "disk0", wantOK: true},
+		{input: "/Volumes/disk2", want: "", wantOK: false},
+		{input: "Data", want: "", wantOK: false},
+		{input: "", want: "", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := Normalize(tt.input)
+
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,197 @@
+//go:build integration && darwin
+
+package diskutil
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"howett.net/plist"
+
+	"github.com/aws/ec2-macos-utils/pkg/system"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// The integration tests run the diskutil wrappers against the live system and are only built with the integration
+// tag on macOS:
+//
+//	go test -tags integration ./pkg/diskutil
+//
+// They only run read-only commands unless integrationContainerEnv names a scratch APFS container. The boot disk is
+// never modified: tests which would modify a container refuse to run when it's on the same disk as the root volume.
+
+// integrationContainerEnv is the environment variable naming a scratch APFS container that tests may resize.
+const integrationContainerEnv = "EC2_MACOS_UTILS_INTEGRATION_CONTAINER"
+
+// integrationTimeout is the maximum run duration of each integration test.
+const integrationTimeout = 5 * time.Minute
+
+// apfsList mirrors the subset of the output of "diskutil apfs list -plist" used by the integration tests.
+type apfsList struct {
+	Containers []struct {
+		ContainerReference      string `plist:"ContainerReference"`
+		CapacityCeiling         uint64 `plist:"CapacityCeiling"`
+		CapacityFree            uint64 `plist:"CapacityFree"`
+		DesignatedPhysicalStore string `plist:"DesignatedPhysicalStore"`
+		PhysicalStores          []struct {
+			DeviceIdentifier string `plist:"DeviceIdentifier"`
+		} `plist:"PhysicalStores"`
+		Volumes []struct {
+			DeviceIdentifier string `plist:"DeviceIdentifier"`
+			Name             string `plist:"Name"`
+		} `plist:"Volumes"`
+	} `plist:"Containers"`
+}
+
+// resizeLimits mirrors the output of "diskutil apfs resizeContainer <id> limits -plist".
+type resizeLimits struct {
+	CurrentSize        uint64 `plist:"CurrentSize"`
+	MinimumSizeNoGuard uint64 `plist:"MinimumSizeNoGuard"`
+	MaximumSize        uint64 `plist:"MaximumSize"`
+}
+
+// integrationSetup creates a DiskUtil for the running release and a context which is cancelled at the end of the test.
+func integrationSetup(t *testing.T) (context.Context, DiskUtil) {
+	t.Helper()
+
+	sys, err := system.Scan()
+	require.NoError(t, err)
+	u, err := ForProduct(sys.Product())
+	require.NoError(t, err, "release [%s] should be supported", sys.Product())
+
+	ctx, cancel := context.WithTimeout(context.Background(), integrationTimeout)
+	t.Cleanup(cancel)
+
+	return ctx, u
+}
+
+// runPlist runs the command and decodes its plist output into v.
+func runPlist(ctx context.Context, t *testing.T, c []string, v interface{}) {
+	t.Helper()
+
+	out, err := util.SystemExecutor{}.Execute(ctx, c)
+	require.NoError(t, err, "stderr: %s", out.Stderr)
+	_, err = plist.Unmarshal([]byte(out.Stdout), v)
+	require.NoError(t, err)
+}
+
+// bootDisk gets the whole disk holding the root volume's physical store.
+func bootDisk(ctx context.Context, t *testing.T, u DiskUtil) string {
+	t.Helper()
+
+	root, err := u.Info(ctx, "/")
+	require.NoError(t, err)
+	parent, err := root.ParentDeviceID()
+	require.NoError(t, err)
+
+	return parent
+}
+
+func TestIntegration_List(t *testing.T) {
+	ctx, u := integrationSetup(t)
+
+	partitions, err := u.List(ctx, nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, partitions.AllDisks)
+	assert.NotEmpty(t, partitions.WholeDisks)
+
+	for _, part := range partitions.AllDisksAndPartitions {
+		if part.APFSVolumes == nil {
+			continue
+		}
+		assert.NotEmpty(t, part.APFSPhysicalStores, "container [%s] should have a physical store", part.DeviceIdentifier)
+	}
+}
+
+func TestIntegration_Info(t *testing.T) {
+	ctx, u := integrationSetup(t)
+
+	root, err := u.Info(ctx, "/")
+	require.NoError(t, err)
+	assert.Equal(t, "apfs", root.FilesystemType)
+	assert.NotEmpty(t, root.APFSContainerReference)
+
+	disk, err := u.Info(ctx, bootDisk(ctx, t, u))
+	require.NoError(t, err)
+	assert.True(t, disk.WholeDisk)
+	assert.NotZero(t, disk.Size)
+
+	partitions, err := u.List(ctx, nil)
+	require.NoError(t, err)
+	for _, id := range partitions.AllDisks {
+		_, err := u.Info(ctx, id)
+		assert.NoError(t, err, "info for [%s] should decode", id)
+	}
+}
+
+func TestIntegration_APFSList(t *testing.T) {
+	ctx, u := integrationSetup(t)
+
+	var list apfsList
+	runPlist(ctx, t, []string{"diskutil", "apfs", "list", "-plist"}, &list)
+	require.NotEmpty(t, list.Containers)
+
+	root, err := u.Info(ctx, "/")
+	require.NoError(t, err)
+
+	found := false
+	for _, c := range list.Containers {
+		assert.NotEmpty(t, c.PhysicalStores, "container [%s] should have a physical store", c.ContainerReference)
+		assert.True(t, c.CapacityFree <= c.CapacityCeiling, "container [%s] should have less free space than capacity", c.ContainerReference)
+		if c.ContainerReference != root.APFSContainerReference {
+			continue
+		}
+		found = true
+
+		// The physical stores reported by apfs list should match the ones found by the wrappers.
+		container, err := u.Info(ctx, c.ContainerReference)
+		require.NoError(t, err)
+		parent, err := container.ParentDeviceID()
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(c.DesignatedPhysicalStore, parent+"s"), "designated store [%s] should be on [%s]", c.DesignatedPhysicalStore, parent)
+	}
+	assert.True(t, found, "root container [%s] should be listed", root.APFSContainerReference)
+}
+
+func TestIntegration_ResizeContainerLimits(t *testing.T) {
+	ctx, u := integrationSetup(t)
+
+	root, err := u.Info(ctx, "/")
+	require.NoError(t, err)
+
+	var limits resizeLimits
+	runPlist(ctx, t, []string{"diskutil", "apfs", "resizeContainer", root.APFSContainerReference, "limits", "-plist"}, &limits)
+	assert.NotZero(t, limits.CurrentSize)
+	assert.True(t, limits.MinimumSizeNoGuard <= limits.CurrentSize, "minimum size should be at most the current size")
+	assert.True(t, limits.CurrentSize <= limits.MaximumSize, "current size should be at most the maximum size")
+}
+
+func TestIntegration_ResizeScratchContainer(t *testing.T) {
+	id := os.Getenv(integrationContainerEnv)
+	if id == "" {
+		t.Skipf("%s isn't set to a scratch APFS container", integrationContainerEnv)
+	}
+	ctx, u := integrationSetup(t)
+
+	// Never resize a container on the boot disk.
+	container, err := u.Info(ctx, id)
+	require.NoError(t, err)
+	parent, err := container.ParentDeviceID()
+	require.NoError(t, err)
+	boot := bootDisk(ctx, t, u)
+	require.NotEqual(t, boot, parent, "container [%s] is on the boot disk and must not be resized", id)
+
+	var limits resizeLimits
+	runPlist(ctx, t, []string{"diskutil", "apfs", "resizeContainer", id, "limits", "-plist"}, &limits)
+
+	_, err = u.ResizeContainer(ctx, id, "0")
+	require.NoError(t, err)
+
+	runPlist(ctx, t, []string{"diskutil", "apfs", "resizeContainer", id, "limits", "-plist"}, &limits)
+	assert.Equal(t, limits.MaximumSize, limits.CurrentSize, "container should fill its disk after resizing")
+}
@@ -0,0 +1,85 @@
+package diskutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/identifier"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+)
+
+// ClassifyDisks sets the Kind of each disk in the partitions. Physical disks are classified from their information
+// while APFS containers get the kind of the physical disk holding their physical store. Every disk that can be
+// classified is, the first failure is returned.
+func ClassifyDisks(ctx context.Context, u DiskUtil, partitions *types.SystemPartitions) error {
+	var firstErr error
+	kinds := make(map[string]types.DiskKind)
+	for i, part := range partitions.AllDisksAndPartitions {
+		if len(part.APFSPhysicalStores) > 0 {
+			continue
+		}
+
+		disk, err := u.Info(ctx, part.DeviceIdentifier)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("unable to classify disk [%s]: %w", part.DeviceIdentifier, err)
+			}
+			continue
+		}
+		kinds[part.DeviceIdentifier] = disk.Kind()
+		partitions.AllDisksAndPartitions[i].Kind = disk.Kind()
+	}
+
+	for i, part := range partitions.AllDisksAndPartitions {
+		if len(part.APFSPhysicalStores) > 0 {
+			partitions.AllDisksAndPartitions[i].Kind = kinds[identifier.ParseDiskID(part.APFSPhysicalStores[0].DeviceIdentifier)]
+		}
+	}
+
+	return firstErr
+}
+
+// ResolveKind finds the kind of the physical disk backing the disk, partition, APFS container, or APFS volume.
+func ResolveKind(ctx context.Context, u DiskUtil, disk *types.DiskInfo) (types.DiskKind, error) {
+	whole, err := PhysicalDisk(ctx, u, disk)
+	if err != nil {
+		return types.KindUnknown, err
+	}
+
+	return whole.Kind(), nil
+}
+
+// PhysicalDisk gets the information of the physical whole disk backing the disk, partition, APFS container, or APFS
+// volume. The disk itself is returned when it's a physical whole disk.
+func PhysicalDisk(ctx context.Context, u DiskUtil, disk *types.DiskInfo) (*types.DiskInfo, error) {
+	var wholeID string
+	switch {
+	case !disk.IsPhysical():
+		id, err := disk.ParentDeviceID()
+		if err != nil {
+			return nil, fmt.Errorf("unable to determine physical disk: %w", err)
+		}
+		wholeID = id
+	case !disk.WholeDisk:
+		wholeID = disk.ParentWholeDisk
+	default:
+		return disk, nil
+	}
+
+	whole, err := u.Info(ctx, wholeID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get physical disk information: %w", err)
+	}
+
+	return whole, nil
+}
+
+// AssertNotInternalDisk checks that the disk isn't the host's internal SSD. The internal SSD isn't erased by the
+// operations meant for EBS volumes since it holds the host's own data (e.g. on mac1.metal instances).
+func AssertNotInternalDisk(disk *types.DiskInfo) error {
+	if disk.Kind() == types.KindInternal {
+		return fmt.Errorf("device [%s] is the host's internal SSD", disk.DeviceIdentifier)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package diskutil
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// physicalStoreWorkers is the maximum number of physical stores fetched at the same time.
+	physicalStoreWorkers = 8
+	// physicalStoresKind is the Kind of the PartialDataErrors returned when physical stores can't be fetched.
+	physicalStoresKind = "physical stores"
+)
+
+// updatePhysicalStores provides separate functionality for fetching APFS physical stores for SystemPartitions. The
+// physical stores are fetched concurrently by a bounded pool of workers since each one requires a separate diskutil
+// call. Failures to fetch some of the physical stores don't prevent the others from being updated, they're returned
+// in a PartialDataError.
+func updatePhysicalStores(ctx context.Context, exec util.Executor, partitions *types.SystemPartitions) error {
+	// Find the APFS disks/partitions whose physical stores need to be fetched
+	var apfs []int
+	for i, part := range partitions.AllDisksAndPartitions {
+		if isAPFSVolume(part) {
+			apfs = append(apfs, i)
+		}
+	}
+	if len(apfs) == 0 {
+		return nil
+	}
+
+	// Fetch the physical stores, each worker writes to the results of the disks/partitions it's given
+	type result struct {
+		id  string
+		err error
+	}
+	results := make([]result, len(partitions.AllDisksAndPartitions))
+	jobs := make(chan int)
+	workers := physicalStoreWorkers
+	if len(apfs) < workers {
+		workers = len(apfs)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				id, err := fetchPhysicalStore(ctx, exec, partitions.AllDisksAndPartitions[i].DeviceIdentifier)
+				results[i] = result{id: id, err: err}
+			}
+		}()
+	}
+	for _, i := range apfs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Add the physical stores to the disks/partitions in order, collecting the failures
+	partialErr := &PartialDataError{Kind: physicalStoresKind, Total: len(apfs)}
+	for _, i := range apfs {
+		part := &partitions.AllDisksAndPartitions[i]
+		if results[i].err != nil {
+			partialErr.Unresolved = append(partialErr.Unresolved, UnresolvedVolume{DeviceIdentifier: part.DeviceIdentifier, Err: results[i].err})
+			continue
+		}
+
+		physicalStore := types.APFSPhysicalStoreID{DeviceIdentifier: results[i].id}
+		part.APFSPhysicalStores = append(part.APFSPhysicalStores, physicalStore)
+	}
+	if len(partialErr.Unresolved) > 0 {
+		return partialErr
+	}
+
+	return nil
+}
+
+// isAPFSVolume checks if a given DiskPart is an APFS container.
+func isAPFSVolume(part types.DiskPart) bool {
+	return part.APFSVolumes != nil
+}
+
+// fetchPhysicalStore parses the human-readable output of the list verb for the given ID in order to fetch its
+// physical store. This function is limited to returning only one physical store so the behavior might cause problems
+// for fusion devices that have more than one APFS physical store.
+func fetchPhysicalStore(ctx context.Context, exec util.Executor, id string) (string, error) {
+	// Create the command for running diskutil and parsing the output to retrieve the desired info (physical store)
+	//   * list - specifies the diskutil 'list' verb for a specific device ID and returns the human-readable output
+	cmdPhysicalStore := []string{"diskutil", "list", id}
+
+	// Execute the command to parse output from diskutil list
+	out, err := exec.Execute(ctx, cmdPhysicalStore)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", out.Stderr, err)
+	}
+
+	return parsePhysicalStoreId(out.Stdout)
+}
+
+// parsePhysicalStoreId searches a raw string for the string "Physical Store disk[0-9]+(s[0-9]+)*". The regular
+// expression "disk[0-9]+(s[0-9]+)*" matches any disk ID without the "/dev/" prefix.
+func parsePhysicalStoreId(raw string) (string, error) {
+	physicalStoreExp := regexp.MustCompile("\\s*Physical Store disk[0-9]+(s[0-9]+)*")
+	diskIdExp := regexp.MustCompile("disk[0-9]+(s[0-9]+)*")
+
+	physicalStore := physicalStoreExp.FindString(raw)
+	diskId := diskIdExp.FindString(physicalStore)
+	if diskId == "" {
+		return "", fmt.Errorf("physical store not found")
+	}
+
+	return diskId, nil
+}
+
+// updatePhysicalStore provides separate functionality for fetching APFS physical stores for DiskInfo. Failures are
+// returned in a PartialDataError since the rest of the DiskInfo is still usable.
+func updatePhysicalStore(ctx context.Context, exec util.Executor, disk *types.DiskInfo) error {
+	if isAPFSMedia(disk) {
+		physicalStoreId, err := fetchPhysicalStore(ctx, exec, disk.DeviceIdentifier)
+		if err != nil {
+			return &PartialDataError{
+				Kind:       physicalStoresKind,
+				Total:      1,
+				Unresolved: []UnresolvedVolume{{DeviceIdentifier: disk.DeviceIdentifier, Err: err}},
+			}
+		}
+
+		physicalStore := types.APFSPhysicalStore{DeviceIdentifier: physicalStoreId}
+
+		disk.APFSPhysicalStores = append(disk.APFSPhysicalStores, physicalStore)
+	}
+
+	return nil
+}
+
+// isAPFSMedia checks if the given DiskInfo is an APFS container or volume.
+func isAPFSMedia(disk *types.DiskInfo) bool {
+	return disk.FilesystemType == "apfs" || disk.IORegistryEntryName == "AppleAPFSMedia"
+}
@@ -0,0 +1,111 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// physicalStoreExecutor is a util.Executor which answers "diskutil list <id>" with the physical stores and tracks how
+// many commands run at the same time.
+type physicalStoreExecutor struct {
+	// stores maps the IDs of the APFS volumes to their physical stores, volumes without one fail.
+	stores map[string]string
+
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (e *physicalStoreExecutor) Execute(ctx context.Context, c []string, opts ...util.Option) (util.CommandOutput, error) {
+	e.mu.Lock()
+	e.running++
+	if e.running > e.peak {
+		e.peak = e.running
+	}
+	e.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	e.mu.Lock()
+	e.running--
+	e.mu.Unlock()
+
+	store, ok := e.stores[c[2]]
+	if !ok {
+		return util.CommandOutput{Stderr: "Could not find disk: " + c[2]}, fmt.Errorf("exit status 1")
+	}
+
+	out := fmt.Sprintf("/dev/%s (synthesized):\n   #:                       TYPE NAME                    SIZE       IDENTIFIER\n"+
+		"   0:      APFS Container Scheme -                      +60.0 GB    %s\n                                 Physical Store %s\n", c[2], c[2], store)
+
+	return util.CommandOutput{Stdout: out}, nil
+}
+
+// mojavePartitions creates SystemPartitions with a physical disk and n APFS containers.
+func mojavePartitions(n int) *types.SystemPartitions {
+	partitions := &types.SystemPartitions{AllDisksAndPartitions: []types.DiskPart{{DeviceIdentifier: "disk0"}}}
+	for i := 1; i <= n; i++ {
+		partitions.AllDisksAndPartitions = append(partitions.AllDisksAndPartitions, types.DiskPart{
+			DeviceIdentifier: fmt.Sprintf("disk%d", i),
+			APFSVolumes:      []types.APFSVolume{{DeviceIdentifier: fmt.Sprintf("disk%ds1", i)}},
+		})
+	}
+
+	return partitions
+}
+
+func TestUpdatePhysicalStores(t *testing.T) {
+	partitions := mojavePartitions(20)
+	exec := &physicalStoreExecutor{stores: make(map[string]string)}
+	for i := 1; i <= 20; i++ {
+		exec.stores[fmt.Sprintf("disk%d", i)] = fmt.Sprintf("disk0s%d", i+1)
+	}
+
+	err := updatePhysicalStores(context.Background(), exec, partitions)
+
+	assert.NoError(t, err)
+	assert.Nil(t, partitions.AllDisksAndPartitions[0].APFSPhysicalStores, "disks that aren't APFS shouldn't be updated")
+	for i := 1; i <= 20; i++ {
+		want := []types.APFSPhysicalStoreID{{DeviceIdentifier: fmt.Sprintf("disk0s%d", i+1)}}
+		assert.Equal(t, want, partitions.AllDisksAndPartitions[i].APFSPhysicalStores)
+	}
+	assert.True(t, exec.peak > 1, "the physical stores should be fetched concurrently")
+	assert.True(t, exec.peak <= physicalStoreWorkers, "at most %d physical stores should be fetched at once, got %d", physicalStoreWorkers, exec.peak)
+}
+
+func TestUpdatePhysicalStores_PartialFailure(t *testing.T) {
+	partitions := mojavePartitions(3)
+	exec := &physicalStoreExecutor{stores: map[string]string{"disk1": "disk0s2", "disk3": "disk0s4"}}
+
+	err := updatePhysicalStores(context.Background(), exec, partitions)
+
+	var partialErr *PartialDataError
+	assert.True(t, errors.As(err, &partialErr))
+	assert.Equal(t, 3, partialErr.Total)
+	assert.Equal(t, []string{"disk2"}, partialErr.DeviceIdentifiers())
+	assert.EqualError(t, err, "failed to fetch 1 of 3 physical stores: disk2: Could not find disk: disk2: exit status 1")
+	assert.Equal(t, []types.APFSPhysicalStoreID{{DeviceIdentifier: "disk0s2"}}, partitions.AllDisksAndPartitions[1].APFSPhysicalStores)
+	assert.Nil(t, partitions.AllDisksAndPartitions[2].APFSPhysicalStores)
+	assert.Equal(t, []types.APFSPhysicalStoreID{{DeviceIdentifier: "disk0s4"}}, partitions.AllDisksAndPartitions[3].APFSPhysicalStores)
+}
+
+func TestUpdatePhysicalStore_Failure(t *testing.T) {
+	disk := &types.DiskInfo{DeviceIdentifier: "disk1", IORegistryEntryName: "AppleAPFSMedia"}
+	exec := &physicalStoreExecutor{}
+
+	err := updatePhysicalStore(context.Background(), exec, disk)
+
+	var partialErr *PartialDataError
+	assert.True(t, errors.As(err, &partialErr))
+	assert.Equal(t, []string{"disk1"}, partialErr.DeviceIdentifiers())
+	assert.Nil(t, disk.APFSPhysicalStores)
+}
@@ -0,0 +1,147 @@
+package diskutil
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// Option customizes the DiskUtil created by ForProduct.
+type Option func(c *config)
+
+// config holds the configuration set by Options.
+type config struct {
+	executor util.Executor
+	decoder  Decoder
+	logger   logrus.FieldLogger
+	retry    RetryPolicy
+	timeout  time.Duration
+}
+
+// RetryPolicy defines how commands that fail because the disk is busy are retried.
+type RetryPolicy struct {
+	// Attempts is the number of times a command is attempted, commands are only attempted once when it's less than 2.
+	Attempts int
+	// Delay is the time waited between attempts.
+	Delay time.Duration
+}
+
+// WithExecutor runs the commands with the executor (e.g. to record or replay them) instead of on the system.
+func WithExecutor(exec util.Executor) Option {
+	return func(c *config) {
+		c.executor = exec
+	}
+}
+
+// WithDecoder decodes the commands' plist output with the decoder.
+func WithDecoder(dec Decoder) Option {
+	return func(c *config) {
+		c.decoder = dec
+	}
+}
+
+// WithLogger logs the commands that are run, and how long they took, at the debug level to the logger.
+func WithLogger(logger logrus.FieldLogger) Option {
+	return func(c *config) {
+		c.logger = logger
+	}
+}
+
+// WithRetryPolicy retries commands that fail because the disk is busy with the policy. Commands aren't retried by
+// default since operations like GrowContainer retry the steps that are safe to retry themselves.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *config) {
+		c.retry = policy
+	}
+}
+
+// WithTimeout limits how long each attempt of a command can run before it's killed.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.timeout = timeout
+	}
+}
+
+// newConfig creates the config for the options, with an Executor that applies them.
+func newConfig(opts []Option) *config {
+	c := &config{
+		executor: util.SystemExecutor{},
+		decoder:  &PlistDecoder{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.timeout > 0 {
+		c.executor = &timeoutExecutor{Executor: c.executor, timeout: c.timeout}
+	}
+	if c.retry.Attempts > 1 {
+		c.executor = &retryExecutor{Executor: c.executor, policy: c.retry}
+	}
+	if c.logger != nil {
+		c.executor = &loggingExecutor{Executor: c.executor, logger: c.logger}
+	}
+
+	return c
+}
+
+// timeoutExecutor is a util.Executor which limits how long commands can run.
+type timeoutExecutor struct {
+	util.Executor
+	timeout time.Duration
+}
+
+func (e *timeoutExecutor) Execute(ctx context.Context, c []string, opts ...util.Option) (util.CommandOutput, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	return e.Executor.Execute(ctx, c, opts...)
+}
+
+// retryExecutor is a util.Executor which retries commands that fail because the disk is busy.
+type retryExecutor struct {
+	util.Executor
+	policy RetryPolicy
+}
+
+func (e *retryExecutor) Execute(ctx context.Context, c []string, opts ...util.Option) (util.CommandOutput, error) {
+	var out util.CommandOutput
+	var err error
+	retryTransient(ctx, e.policy.Attempts, e.policy.Delay, func() error {
+		out, err = e.Executor.Execute(ctx, c, opts...)
+		if err != nil {
+			// The failure is only classified to decide whether to retry, the commands' callers classify it themselves.
+			return newCommandError(out.Stderr, err)
+		}
+
+		return nil
+	})
+
+	return out, err
+}
+
+// loggingExecutor is a util.Executor which logs the commands that are run.
+type loggingExecutor struct {
+	util.Executor
+	logger logrus.FieldLogger
+}
+
+func (e *loggingExecutor) Execute(ctx context.Context, c []string, opts ...util.Option) (util.CommandOutput, error) {
+	start := time.Now()
+	out, err := e.Executor.Execute(ctx, c, opts...)
+
+	entry := e.logger.WithFields(logrus.Fields{
+		"command":  strings.Join(c, " "),
+		"duration": time.Since(start).Round(time.Millisecond),
+	})
+	if err != nil {
+		entry = entry.WithError(err)
+	}
+	entry.Debug("Ran command")
+
+	return out, err
+}
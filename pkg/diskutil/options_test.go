@@ -0,0 +1,118 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// scriptedExecutor is a util.Executor which returns its outputs and errors in order.
+type scriptedExecutor struct {
+	outs     []util.CommandOutput
+	errs     []error
+	calls    int
+	deadline bool
+}
+
+func (e *scriptedExecutor) Execute(ctx context.Context, c []string, opts ...util.Option) (util.CommandOutput, error) {
+	_, e.deadline = ctx.Deadline()
+	i := e.calls
+	e.calls++
+	if i >= len(e.outs) {
+		return util.CommandOutput{}, nil
+	}
+
+	return e.outs[i], e.errs[i]
+}
+
+// stubDecoder is a Decoder which returns fixed values.
+type stubDecoder struct {
+	info *types.DiskInfo
+}
+
+func (d stubDecoder) DecodeSystemPartitions(reader io.ReadSeeker) (*types.SystemPartitions, error) {
+	return &types.SystemPartitions{}, nil
+}
+
+func (d stubDecoder) DecodeDiskInfo(reader io.ReadSeeker) (*types.DiskInfo, error) {
+	return d.info, nil
+}
+
+// venturaProduct is the product used by the options tests.
+var venturaProduct = &system.Product{Release: system.Ventura, Version: *semver.MustParse("13.6.1")}
+
+func TestForProduct_WithDecoder(t *testing.T) {
+	want := &types.DiskInfo{DeviceIdentifier: "disk9"}
+
+	u, err := ForProduct(venturaProduct, WithExecutor(&scriptedExecutor{}), WithDecoder(stubDecoder{info: want}))
+	assert.NoError(t, err)
+
+	got, err := u.Info(context.Background(), "disk9")
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestForProduct_WithRetryPolicy(t *testing.T) {
+	exec := &scriptedExecutor{
+		outs: []util.CommandOutput{{Stderr: "Error: -69877: Couldn't open device"}, {Stdout: "Finished"}},
+		errs: []error{errors.New("exit status 1"), nil},
+	}
+
+	u, err := ForProduct(venturaProduct, WithExecutor(exec), WithRetryPolicy(RetryPolicy{Attempts: 3}))
+	assert.NoError(t, err)
+
+	out, err := u.ResizeContainer(context.Background(), "disk1", "0")
+	assert.NoError(t, err)
+	assert.Equal(t, "Finished", out)
+	assert.Equal(t, 2, exec.calls, "busy failure should be retried")
+}
+
+func TestForProduct_WithRetryPolicy_NotTransient(t *testing.T) {
+	exec := &scriptedExecutor{
+		outs: []util.CommandOutput{{Stderr: "Error: not enough space"}},
+		errs: []error{errors.New("exit status 1")},
+	}
+
+	u, err := ForProduct(venturaProduct, WithExecutor(exec), WithRetryPolicy(RetryPolicy{Attempts: 3}))
+	assert.NoError(t, err)
+
+	_, err = u.ResizeContainer(context.Background(), "disk1", "0")
+	assert.Equal(t, ClassInsufficientSpace, Classify(err))
+	assert.Equal(t, 1, exec.calls, "failures that aren't transient shouldn't be retried")
+}
+
+func TestForProduct_WithTimeout(t *testing.T) {
+	exec := &scriptedExecutor{}
+
+	u, err := ForProduct(venturaProduct, WithExecutor(exec), WithDecoder(stubDecoder{}), WithTimeout(time.Minute))
+	assert.NoError(t, err)
+
+	_, err = u.List(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.True(t, exec.deadline, "command should be run with a deadline")
+}
+
+func TestForProduct_WithLogger(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+
+	u, err := ForProduct(venturaProduct, WithExecutor(&scriptedExecutor{}), WithDecoder(stubDecoder{}), WithLogger(logger))
+	assert.NoError(t, err)
+
+	_, err = u.List(context.Background(), nil)
+	assert.NoError(t, err)
+	if assert.Len(t, hook.AllEntries(), 1) {
+		assert.Equal(t, "diskutil list -plist", hook.LastEntry().Data["command"])
+	}
+}
@@ -0,0 +1,105 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ActionKind is the kind of change that an Action makes to a disk.
+type ActionKind string
+
+const (
+	// ActionRepair repairs a disk's partition map.
+	ActionRepair ActionKind = "repair"
+	// ActionResize resizes an APFS container.
+	ActionResize ActionKind = "resize"
+	// ActionErase erases a disk and formats it with a single volume.
+	ActionErase ActionKind = "erase"
+	// ActionUnmount unmounts a volume.
+	ActionUnmount ActionKind = "unmount"
+	// ActionMount mounts a volume.
+	ActionMount ActionKind = "mount"
+)
+
+// errStopPlan is returned by an Action when the rest of the Plan can't be applied without its changes, which
+// happens when they're skipped in dry-run mode (e.g. there's no volume to mount when erasing the disk was skipped).
+var errStopPlan = errors.New("plan stopped")
+
+// Action is a change that a Plan makes to a disk, with the reason that it's made.
+type Action struct {
+	// Kind is the kind of change.
+	Kind ActionKind `json:"kind"`
+	// Device is the device identifier of the disk, container, or volume that's changed.
+	Device string `json:"device"`
+	// Reason describes why the change is made.
+	Reason string `json:"reason"`
+
+	// run makes the change.
+	run func(ctx context.Context, u DiskUtil) error
+}
+
+func (a Action) String() string {
+	return fmt.Sprintf("%s %s: %s", a.Kind, a.Device, a.Reason)
+}
+
+// Plan is the changes that an operation (e.g. growing a container) makes to the disks, computed from their current
+// state without changing them. Plans are created by PlanGrow and PlanProvision and applied with Apply, which is
+// what GrowContainer and ProvisionVolume do.
+type Plan struct {
+	// Operation is the operation that the plan is for (e.g. "grow" or "provision").
+	Operation string `json:"operation"`
+	// Device is the device identifier of the disk or container that the operation was given.
+	Device string `json:"device"`
+	// Actions are the changes that are made, in order. Operations that have nothing to change have no actions.
+	Actions []Action `json:"actions"`
+}
+
+// add adds the action to the plan.
+func (p *Plan) add(kind ActionKind, device string, reason string, run func(ctx context.Context, u DiskUtil) error) {
+	p.Actions = append(p.Actions, Action{Kind: kind, Device: device, Reason: reason, run: run})
+}
+
+// Empty is whether the plan doesn't change anything.
+func (p *Plan) Empty() bool {
+	return len(p.Actions) == 0
+}
+
+func (p *Plan) String() string {
+	if p.Empty() {
+		return fmt.Sprintf("%s %s: nothing to do", p.Operation, p.Device)
+	}
+
+	lines := []string{fmt.Sprintf("%s %s:", p.Operation, p.Device)}
+	for i, a := range p.Actions {
+		lines = append(lines, fmt.Sprintf("  %d. %s", i+1, a))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Apply makes the plan's changes with the DiskUtil, in order, stopping at the first that fails. The DiskUtil can
+// differ from the one the plan was computed with (e.g. to apply it in dry-run mode). Actions whose changes are
+// needed by the rest of the plan stop it without an error when they're skipped in dry-run mode.
+func (p *Plan) Apply(ctx context.Context, u DiskUtil) error {
+	_, err := p.apply(ctx, u)
+
+	return err
+}
+
+// apply applies the plan as described by Apply, reporting whether it ran to completion.
+func (p *Plan) apply(ctx context.Context, u DiskUtil) (completed bool, err error) {
+	for _, a := range p.Actions {
+		if a.run == nil {
+			return false, fmt.Errorf("action [%s] can't be applied", a)
+		}
+		if err := a.run(ctx, u); errors.Is(err, errStopPlan) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,116 @@
+package diskutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	mock_diskutil "github.com/aws/ec2-macos-utils/pkg/diskutil/mocks"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanGrow(t *testing.T) {
+	var ctx = context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	mockUtility.EXPECT().Info(ctx, "disk0").Return(&busyTestPhysical, nil)
+
+	container := busyTestContainer
+	plan, err := PlanGrow(ctx, mockUtility, &container, WithUnmountBusy())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "grow", plan.Operation)
+	assert.Equal(t, "disk2", plan.Device)
+	if assert.Len(t, plan.Actions, 2) {
+		assert.Equal(t, ActionRepair, plan.Actions[0].Kind)
+		assert.Equal(t, "disk0", plan.Actions[0].Device)
+		assert.Equal(t, ActionResize, plan.Actions[1].Kind)
+		assert.Equal(t, "grow container [disk2] to its maximum size, unmounting the volumes using the disk first", plan.Actions[1].Reason)
+	}
+}
+
+func TestPlanGrow_NotAPFS(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+
+	_, err := PlanGrow(context.Background(), mockUtility, &types.DiskInfo{DeviceIdentifier: "disk4"})
+
+	var notAPFSErr *NotAPFSError
+	assert.True(t, errors.As(err, &notAPFSErr), "shouldn't plan growing a disk that isn't APFS")
+}
+
+func TestPlanProvision_AlreadyMounted(t *testing.T) {
+	var ctx = context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	parts := types.SystemPartitions{
+		AllDisksAndPartitions: []types.DiskPart{
+			{
+				DeviceIdentifier: "disk4",
+				Partitions:       []types.Partition{{DeviceIdentifier: "disk4s1", Content: "Apple_HFS", VolumeName: "Data"}},
+			},
+		},
+	}
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	gomock.InOrder(
+		mockUtility.EXPECT().Info(ctx, "disk4").Return(&types.DiskInfo{DeviceIdentifier: "disk4", WholeDisk: true}, nil),
+		mockUtility.EXPECT().Info(ctx, "/").Return(&types.DiskInfo{ParentWholeDisk: "disk1"}, nil),
+		mockUtility.EXPECT().List(ctx, nil).Return(&parts, nil),
+		mockUtility.EXPECT().Info(ctx, "disk4s1").Return(&types.DiskInfo{DeviceIdentifier: "disk4s1", MountPoint: "/Volumes/Data"}, nil),
+	)
+
+	plan, err := PlanProvision(ctx, mockUtility, "disk4", FormatJHFS, "Data", "/Volumes/Data")
+
+	assert.NoError(t, err)
+	assert.True(t, plan.Empty(), "should have nothing to do for a volume that's already mounted")
+	assert.Equal(t, "provision disk4: nothing to do", plan.String())
+}
+
+func TestPlan_String(t *testing.T) {
+	plan := &Plan{Operation: "grow", Device: "disk2"}
+	plan.add(ActionRepair, "disk0", "update the partition map", nil)
+	plan.add(ActionResize, "disk0", "grow container [disk2] to its maximum size", nil)
+
+	assert.Equal(t, "grow disk2:\n  1. repair disk0: update the partition map\n  2. resize disk0: grow container [disk2] to its maximum size", plan.String())
+}
+
+func TestPlan_Apply(t *testing.T) {
+	var ran []string
+	plan := &Plan{Operation: "test"}
+	plan.add(ActionUnmount, "disk2s1", "", func(ctx context.Context, u DiskUtil) error {
+		ran = append(ran, "unmount")
+		return nil
+	})
+	plan.add(ActionErase, "disk2", "", func(ctx context.Context, u DiskUtil) error {
+		ran = append(ran, "erase")
+		return errStopPlan
+	})
+	plan.add(ActionMount, "disk2s1", "", func(ctx context.Context, u DiskUtil) error {
+		ran = append(ran, "mount")
+		return nil
+	})
+
+	assert.NoError(t, plan.Apply(context.Background(), nil), "stopping a plan shouldn't fail it")
+	assert.Equal(t, []string{"unmount", "erase"}, ran, "actions after a stop shouldn't run")
+}
+
+func TestPlan_ApplyDecoded(t *testing.T) {
+	b, err := json.Marshal(&Plan{Operation: "grow", Device: "disk2", Actions: []Action{{Kind: ActionResize, Device: "disk0"}}})
+	assert.NoError(t, err)
+
+	var plan Plan
+	assert.NoError(t, json.Unmarshal(b, &plan))
+
+	assert.Error(t, plan.Apply(context.Background(), nil), "decoded plans can't be applied")
+}
@@ -0,0 +1,462 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/events"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/identifier"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// VolumeFormat is a filesystem personality that diskutil can format a data volume with.
+type VolumeFormat string
+
+const (
+	// FormatAPFS formats the disk as an APFS container with a single volume.
+	FormatAPFS VolumeFormat = "APFS"
+	// FormatJHFS formats the disk with a single journaled HFS+ volume.
+	FormatJHFS VolumeFormat = "JHFS+"
+	// FormatExFAT formats the disk with a single ExFAT volume.
+	FormatExFAT VolumeFormat = "ExFAT"
+	// FormatFAT32 formats the disk with a single MS-DOS FAT32 volume on an MBR partition map, which is readable by
+	// the widest range of other systems.
+	FormatFAT32 VolumeFormat = "FAT32"
+)
+
+const (
+	// fatLabelMaxLength is the maximum length of FAT32 and ExFAT volume labels.
+	fatLabelMaxLength = 11
+	// fatLabelInvalidChars are the characters that aren't allowed in FAT32 and ExFAT volume labels.
+	fatLabelInvalidChars = `"*+,./:;<=>?[\]|`
+	// mbrMaxSize is the largest disk that can be addressed by an MBR partition map with 512 byte sectors (2 TiB).
+	mbrMaxSize = 1 << 41
+)
+
+// volumeFormatAliases are the other names that diskutil uses for the formats.
+var volumeFormatAliases = map[string]VolumeFormat{
+	"MS-DOS":       FormatFAT32,
+	"MS-DOS FAT32": FormatFAT32,
+}
+
+// ParseVolumeFormat finds the VolumeFormat matching s, ignoring case.
+func ParseVolumeFormat(s string) (VolumeFormat, error) {
+	s = strings.TrimSpace(s)
+	for _, f := range []VolumeFormat{FormatAPFS, FormatJHFS, FormatExFAT, FormatFAT32} {
+		if strings.EqualFold(string(f), s) {
+			return f, nil
+		}
+	}
+	for alias, f := range volumeFormatAliases {
+		if strings.EqualFold(alias, s) {
+			return f, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported volume format %q", s)
+}
+
+// FilesystemType returns the mount(8) filesystem type for volumes of the VolumeFormat.
+func (f VolumeFormat) FilesystemType() string {
+	switch f {
+	case FormatAPFS:
+		return "apfs"
+	case FormatJHFS:
+		return "hfs"
+	case FormatExFAT:
+		return "exfat"
+	case FormatFAT32:
+		return "msdos"
+	default:
+		return ""
+	}
+}
+
+// Validate checks that a volume of the VolumeFormat can be named label and created on a disk of the given size.
+// FAT32 and ExFAT labels are limited to 11 characters without FAT's reserved characters, and FAT32's MBR partition
+// map can't address disks larger than 2 TiB.
+func (f VolumeFormat) Validate(label string, size uint64) error {
+	if f != FormatFAT32 && f != FormatExFAT {
+		return nil
+	}
+
+	if n := utf8.RuneCountInString(label); n > fatLabelMaxLength {
+		return fmt.Errorf("%s volume label %q is %d characters, the maximum is %d", f, label, n, fatLabelMaxLength)
+	}
+	if i := strings.IndexAny(label, fatLabelInvalidChars); i >= 0 {
+		return fmt.Errorf("%s volume label %q contains the invalid character %q", f, label, label[i])
+	}
+	if f == FormatFAT32 && size > mbrMaxSize {
+		return fmt.Errorf("FAT32 volumes can't be larger than 2 TiB, use ExFAT instead")
+	}
+
+	return nil
+}
+
+// volumeLabel gets the label that the volume will be given, FAT32 labels are stored in upper case.
+func (f VolumeFormat) volumeLabel(label string) string {
+	if f == FormatFAT32 {
+		return strings.ToUpper(label)
+	}
+
+	return label
+}
+
+// ProvisionVolume prepares a data volume on the whole disk with the given device identifier by applying the Plan
+// from PlanProvision, which performs the following operations:
+//  1. Verify that the disk is a whole disk, doesn't back the OS's root volume, and isn't the host's internal SSD.
+//  2. Erase and format the disk with a single volume, named label, if the disk is blank.
+//  3. Mount the disk's data volume at mountPoint (if it isn't already mounted there).
+//
+// Disks that already contain a data volume are not erased so that provisioning can safely be repeated. Disks that
+// have partitions but no recognizable data volume are left untouched and an error is returned.
+//
+// The types.DiskInfo for the mounted data volume is returned on success. No information is returned when the disk
+// would have been erased in dry-run mode since there's no volume to inspect. The operation is published to the events
+// Bus in ctx.
+func ProvisionVolume(ctx context.Context, u DiskUtil, id string, format VolumeFormat, label string, mountPoint string) (*types.DiskInfo, error) {
+	span := events.FromContext(ctx).Start(events.OperationProvision, id)
+	volume, err := provisionVolume(ctx, u, id, format, label, mountPoint)
+	span.End(err)
+
+	return volume, err
+}
+
+// PlanProvision computes the Plan for provisioning the data volume as described by ProvisionVolume without changing
+// any disks. Disks that can't be provisioned fail while planning.
+func PlanProvision(ctx context.Context, u DiskUtil, id string, format VolumeFormat, label string, mountPoint string) (*Plan, error) {
+	plan, _, err := planProvision(ctx, u, id, format, label, mountPoint)
+
+	return plan, err
+}
+
+// provisionState is the data volume that a provisioning Plan is applied to, which is only known once the disk has
+// been formatted when it's blank.
+type provisionState struct {
+	volume *types.DiskInfo
+}
+
+// provisionVolume provisions the data volume as described by ProvisionVolume.
+func provisionVolume(ctx context.Context, u DiskUtil, id string, format VolumeFormat, label string, mountPoint string) (*types.DiskInfo, error) {
+	plan, state, err := planProvision(ctx, u, id, format, label, mountPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	completed, err := plan.apply(ctx, u)
+	if err != nil || !completed {
+		return nil, err
+	}
+
+	return u.Info(ctx, state.volume.DeviceIdentifier)
+}
+
+// planProvision computes the Plan for provisioning the data volume as described by PlanProvision, along with the
+// state that its actions are applied to.
+func planProvision(ctx context.Context, u DiskUtil, id string, format VolumeFormat, label string, mountPoint string) (*Plan, *provisionState, error) {
+	disk, err := u.Info(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to get disk information: %w", err)
+	}
+	if !disk.WholeDisk {
+		return nil, nil, fmt.Errorf("device [%s] is not a whole disk", disk.DeviceIdentifier)
+	}
+	if err := format.Validate(label, disk.TotalSize); err != nil {
+		return nil, nil, err
+	}
+	label = format.volumeLabel(label)
+
+	logrus.WithField("device_id", disk.DeviceIdentifier).Info("Checking that device isn't the boot disk...")
+	if err := AssertNotBootDisk(ctx, u, disk); err != nil {
+		return nil, nil, err
+	}
+	if err := AssertNotInternalDisk(disk); err != nil {
+		return nil, nil, err
+	}
+
+	partitions, err := u.List(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot list partitions: %w", err)
+	}
+
+	plan := &Plan{Operation: "provision", Device: disk.DeviceIdentifier}
+	state := &provisionState{}
+
+	volumeID := findDataVolume(partitions, disk.DeviceIdentifier)
+	if volumeID == "" {
+		if !isBlankDisk(partitions, disk.DeviceIdentifier) {
+			return nil, nil, fmt.Errorf("device [%s] is not blank and has no data volume, refusing to erase", disk.DeviceIdentifier)
+		}
+
+		reason := fmt.Sprintf("disk is blank, format it with a single %s volume named %q", format, label)
+		plan.add(ActionErase, disk.DeviceIdentifier, reason, func(ctx context.Context, u DiskUtil) error {
+			volume, err := eraseForProvision(ctx, u, disk.DeviceIdentifier, format, label)
+			state.volume = volume
+			return err
+		})
+		plan.add(ActionMount, disk.DeviceIdentifier, "mount the new volume at "+mountPoint, func(ctx context.Context, u DiskUtil) error {
+			return mountVolume(ctx, u, state.volume, mountPoint)
+		})
+
+		return plan, state, nil
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"device_id": disk.DeviceIdentifier,
+		"volume_id": volumeID,
+	}).Info("Device already has a data volume, skipping format")
+	volume, err := u.Info(ctx, volumeID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to get volume information: %w", err)
+	}
+	state.volume = volume
+
+	if volume.MountPoint == mountPoint {
+		logrus.WithField("mount_point", mountPoint).Info("Volume already mounted at mount point")
+		return plan, state, nil
+	}
+	if volume.MountPoint != "" {
+		plan.add(ActionUnmount, volume.DeviceIdentifier, "volume is mounted at "+volume.MountPoint, func(ctx context.Context, u DiskUtil) error {
+			return unmountVolume(ctx, u, volume)
+		})
+	}
+	plan.add(ActionMount, volume.DeviceIdentifier, "mount the data volume at "+mountPoint, func(ctx context.Context, u DiskUtil) error {
+		return mountAt(ctx, u, volume.DeviceIdentifier, mountPoint)
+	})
+
+	return plan, state, nil
+}
+
+// eraseForProvision formats the blank disk with a single volume and gets the volume's information. errStopPlan is
+// returned when formatting is skipped in dry-run mode since there's no volume to mount.
+func eraseForProvision(ctx context.Context, u DiskUtil, id string, format VolumeFormat, label string) (*types.DiskInfo, error) {
+	logrus.WithFields(logrus.Fields{
+		"device_id": id,
+		"format":    format,
+		"label":     label,
+	}).Info("Formatting blank device...")
+	out, err := u.EraseDisk(ctx, string(format), label, id)
+	logrus.WithField("out", out).Debug("EraseDisk output")
+	if errors.Is(err, ErrReadOnly) {
+		logrus.WithError(err).Warn("Would have formatted device")
+		return nil, errStopPlan
+	} else if err != nil {
+		return nil, err
+	}
+
+	partitions, err := u.List(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list partitions: %w", err)
+	}
+	volumeID := findDataVolume(partitions, id)
+	if volumeID == "" {
+		return nil, fmt.Errorf("no data volume found on device [%s] after formatting", id)
+	}
+
+	volume, err := u.Info(ctx, volumeID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get volume information: %w", err)
+	}
+
+	return volume, nil
+}
+
+// FormatDevice formats the whole disk with the given device identifier with a single volume, named label, using
+// the format's newfs tool instead of diskutil eraseDisk. Disks that back the OS's root volume and the host's internal
+// SSD are never formatted and disks that aren't blank are only formatted when force is set, once their volumes have
+// been unmounted.
+func FormatDevice(ctx context.Context, u DiskUtil, id string, format VolumeFormat, label string, force bool) error {
+	disk, err := u.Info(ctx, id)
+	if err != nil {
+		return fmt.Errorf("unable to get disk information: %w", err)
+	}
+	if !disk.WholeDisk {
+		return fmt.Errorf("device [%s] is not a whole disk", disk.DeviceIdentifier)
+	}
+	if err := format.Validate(label, disk.TotalSize); err != nil {
+		return err
+	}
+	label = format.volumeLabel(label)
+
+	logrus.WithField("device_id", disk.DeviceIdentifier).Info("Checking that device isn't the boot disk...")
+	if err := AssertNotBootDisk(ctx, u, disk); err != nil {
+		return err
+	}
+	if err := AssertNotInternalDisk(disk); err != nil {
+		return err
+	}
+
+	if !force {
+		partitions, err := u.List(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("cannot list partitions: %w", err)
+		}
+		if !isBlankDisk(partitions, disk.DeviceIdentifier) {
+			return fmt.Errorf("device [%s] is not blank, refusing to format", disk.DeviceIdentifier)
+		}
+	} else if err := unmountDisk(ctx, u, disk.DeviceIdentifier); err != nil {
+		// Disks that aren't blank may have mounted volumes, which newfs can't format underneath
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"device_id": disk.DeviceIdentifier,
+		"format":    format,
+		"label":     label,
+	}).Info("Formatting device...")
+	out, err := u.NewFS(ctx, string(format), label, disk.DeviceIdentifier)
+	logrus.WithField("out", out).Debug("NewFS output")
+	if errors.Is(err, ErrReadOnly) {
+		logrus.WithError(err).Warn("Would have formatted device")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// mountVolume mounts the volume at mountPoint. Volumes already mounted at mountPoint are left as-is while volumes
+// mounted elsewhere are unmounted first.
+func mountVolume(ctx context.Context, u DiskUtil, volume *types.DiskInfo, mountPoint string) error {
+	if volume.MountPoint == mountPoint {
+		logrus.WithField("mount_point", mountPoint).Info("Volume already mounted at mount point")
+		return nil
+	}
+
+	if volume.MountPoint != "" {
+		if err := unmountVolume(ctx, u, volume); err != nil {
+			return err
+		}
+	}
+
+	return mountAt(ctx, u, volume.DeviceIdentifier, mountPoint)
+}
+
+// mountAt mounts the unmounted volume with the given device identifier at mountPoint.
+func mountAt(ctx context.Context, u DiskUtil, id string, mountPoint string) error {
+	logrus.WithFields(logrus.Fields{
+		"volume_id":   id,
+		"mount_point": mountPoint,
+	}).Info("Mounting volume...")
+	out, err := u.Mount(ctx, id, mountPoint)
+	logrus.WithField("out", out).Debug("Mount output")
+	if errors.Is(err, ErrReadOnly) {
+		logrus.WithError(err).Warn("Would have mounted volume")
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// unmountVolume unmounts the volume from its current mount point.
+func unmountVolume(ctx context.Context, u DiskUtil, volume *types.DiskInfo) error {
+	logrus.WithField("mount_point", volume.MountPoint).Info("Unmounting volume from current mount point...")
+	// Unmounting fails while files on the volume are open so it's retried when the volume is busy
+	var out string
+	err := retryTransient(ctx, busyRetryAttempts, busyRetryDelay, func() error {
+		var err error
+		out, err = u.Unmount(ctx, volume.DeviceIdentifier)
+		return err
+	})
+	logrus.WithField("out", out).Debug("Unmount output")
+	if errors.Is(err, ErrReadOnly) {
+		logrus.WithError(err).Warn("Would have unmounted volume")
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// unmountDisk unmounts all of the volumes on the whole disk with the given device identifier.
+func unmountDisk(ctx context.Context, u DiskUtil, id string) error {
+	logrus.WithField("device_id", id).Info("Unmounting device's volumes...")
+	// Unmounting fails while files on the volumes are open so it's retried when the disk is busy
+	var out string
+	err := retryTransient(ctx, busyRetryAttempts, busyRetryDelay, func() error {
+		var err error
+		out, err = u.UnmountDisk(ctx, id)
+		return err
+	})
+	logrus.WithField("out", out).Debug("UnmountDisk output")
+	if errors.Is(err, ErrReadOnly) {
+		logrus.WithError(err).Warn("Would have unmounted device's volumes")
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AssertNotBootDisk checks that the whole disk isn't the container or physical disk that holds the OS's root volume.
+func AssertNotBootDisk(ctx context.Context, u DiskUtil, disk *types.DiskInfo) error {
+	root, err := u.Info(ctx, "/")
+	if err != nil {
+		return fmt.Errorf("unable to identify boot disk: %w", err)
+	}
+
+	bootDisks := []string{identifier.ParseDiskID(root.ParentWholeDisk)}
+	if phy, err := root.ParentDeviceID(); err == nil {
+		bootDisks = append(bootDisks, phy)
+	}
+
+	for _, id := range bootDisks {
+		if strings.EqualFold(id, disk.DeviceIdentifier) {
+			return fmt.Errorf("device [%s] is the boot disk", disk.DeviceIdentifier)
+		}
+	}
+
+	return nil
+}
+
+// findDataVolume searches the partitions for the first volume stored on the whole disk with the given device
+// identifier. APFS volumes are found through the containers whose physical stores are on the disk while other
+// volumes are found through the disk's own partitions. An empty string is returned when no volume is found.
+func findDataVolume(partitions *types.SystemPartitions, diskID string) string {
+	for _, part := range partitions.AllDisksAndPartitions {
+		for _, store := range part.APFSPhysicalStores {
+			if !strings.EqualFold(identifier.ParseDiskID(store.DeviceIdentifier), diskID) {
+				continue
+			}
+			for _, volume := range part.APFSVolumes {
+				if volume.DeviceIdentifier != "" {
+					return volume.DeviceIdentifier
+				}
+			}
+		}
+	}
+
+	for _, part := range partitions.AllDisksAndPartitions {
+		if !strings.EqualFold(part.DeviceIdentifier, diskID) {
+			continue
+		}
+		for _, p := range part.Partitions {
+			switch p.Content {
+			case "", "EFI", "Apple_APFS", "Apple_Boot":
+				continue
+			default:
+				return p.DeviceIdentifier
+			}
+		}
+	}
+
+	return ""
+}
+
+// isBlankDisk checks that the whole disk with the given device identifier has no partitions.
+func isBlankDisk(partitions *types.SystemPartitions, diskID string) bool {
+	for _, part := range partitions.AllDisksAndPartitions {
+		if strings.EqualFold(part.DeviceIdentifier, diskID) {
+			return len(part.Partitions) == 0 && len(part.APFSVolumes) == 0
+		}
+	}
+
+	return false
+}
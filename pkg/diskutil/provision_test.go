@@ -0,0 +1,339 @@
+package diskutil
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	mock_diskutil "github.com/aws/ec2-macos-utils/pkg/diskutil/mocks"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// provisionRootDisk is the root volume's information used by ProvisionVolume's boot disk check.
+var provisionRootDisk = types.DiskInfo{
+	APFSPhysicalStores: []types.APFSPhysicalStore{
+		{DeviceIdentifier: "disk0s2"},
+	},
+	DeviceIdentifier: "disk1s5",
+	ParentWholeDisk:  "disk1",
+}
+
+func TestParseVolumeFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    VolumeFormat
+		wantErr bool
+	}{
+		{name: "apfs", input: "apfs", want: FormatAPFS},
+		{name: "jhfs+", input: "JHFS+", want: FormatJHFS},
+		{name: "exfat", input: " ExFAT ", want: FormatExFAT},
+		{name: "fat32", input: "fat32", want: FormatFAT32},
+		{name: "ms-dos alias", input: "MS-DOS FAT32", want: FormatFAT32},
+		{name: "unsupported", input: "ntfs", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVolumeFormat(tt.input)
+
+			assert.Equal(t, tt.want, got)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestVolumeFormat_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  VolumeFormat
+		label   string
+		size    uint64
+		wantErr bool
+	}{
+		{name: "apfs long label", format: FormatAPFS, label: "A Very Long Volume Name", size: 4 << 40},
+		{name: "fat32", format: FormatFAT32, label: "SHARED", size: 64 << 30},
+		{name: "fat32 long label", format: FormatFAT32, label: "Shared Volume", size: 64 << 30, wantErr: true},
+		{name: "fat32 invalid character", format: FormatFAT32, label: "A/B", size: 64 << 30, wantErr: true},
+		{name: "fat32 too large", format: FormatFAT32, label: "SHARED", size: 4 << 40, wantErr: true},
+		{name: "exfat large", format: FormatExFAT, label: "Shared", size: 4 << 40},
+		{name: "exfat long label", format: FormatExFAT, label: "Shared Volume", size: 64 << 30, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.format.Validate(tt.label, tt.size)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestProvisionVolume_WithoutWholeDisk(t *testing.T) {
+	const testDiskID = "disk2s1"
+	var ctx = context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	mockUtility.EXPECT().Info(ctx, testDiskID).Return(&types.DiskInfo{DeviceIdentifier: testDiskID}, nil)
+
+	di, err := ProvisionVolume(ctx, mockUtility, testDiskID, FormatAPFS, "Data", "/Volumes/Data")
+
+	assert.Error(t, err, "shouldn't provision a partition")
+	assert.Nil(t, di)
+}
+
+func TestProvisionVolume_WithBootDisk(t *testing.T) {
+	const testDiskID = "disk0"
+	var ctx = context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	gomock.InOrder(
+		mockUtility.EXPECT().Info(ctx, testDiskID).Return(&types.DiskInfo{DeviceIdentifier: testDiskID, WholeDisk: true}, nil),
+		mockUtility.EXPECT().Info(ctx, "/").Return(&provisionRootDisk, nil),
+	)
+
+	di, err := ProvisionVolume(ctx, mockUtility, testDiskID, FormatAPFS, "Data", "/Volumes/Data")
+
+	assert.Error(t, err, "shouldn't provision the boot disk")
+	assert.Nil(t, di)
+}
+
+func TestProvisionVolume_WithoutBlankDisk(t *testing.T) {
+	const testDiskID = "disk2"
+	var ctx = context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	parts := types.SystemPartitions{
+		AllDisksAndPartitions: []types.DiskPart{
+			{
+				DeviceIdentifier: testDiskID,
+				Partitions: []types.Partition{
+					{Content: "EFI", DeviceIdentifier: "disk2s1"},
+				},
+			},
+		},
+	}
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	gomock.InOrder(
+		mockUtility.EXPECT().Info(ctx, testDiskID).Return(&types.DiskInfo{DeviceIdentifier: testDiskID, WholeDisk: true}, nil),
+		mockUtility.EXPECT().Info(ctx, "/").Return(&provisionRootDisk, nil),
+		mockUtility.EXPECT().List(ctx, nil).Return(&parts, nil),
+	)
+
+	di, err := ProvisionVolume(ctx, mockUtility, testDiskID, FormatAPFS, "Data", "/Volumes/Data")
+
+	assert.Error(t, err, "shouldn't erase a disk that isn't blank")
+	assert.Nil(t, di)
+}
+
+func TestProvisionVolume_WithEraseErr(t *testing.T) {
+	const testDiskID = "disk2"
+	var ctx = context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	parts := types.SystemPartitions{
+		AllDisksAndPartitions: []types.DiskPart{
+			{DeviceIdentifier: testDiskID},
+		},
+	}
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	gomock.InOrder(
+		mockUtility.EXPECT().Info(ctx, testDiskID).Return(&types.DiskInfo{DeviceIdentifier: testDiskID, WholeDisk: true}, nil),
+		mockUtility.EXPECT().Info(ctx, "/").Return(&provisionRootDisk, nil),
+		mockUtility.EXPECT().List(ctx, nil).Return(&parts, nil),
+		mockUtility.EXPECT().EraseDisk(ctx, "APFS", "Data", testDiskID).Return("", fmt.Errorf("error")),
+	)
+
+	di, err := ProvisionVolume(ctx, mockUtility, testDiskID, FormatAPFS, "Data", "/Volumes/Data")
+
+	assert.Error(t, err, "should fail with erase error")
+	assert.Nil(t, di)
+}
+
+func TestProvisionVolume_BlankDisk(t *testing.T) {
+	const (
+		testDiskID     = "disk2"
+		testVolumeID   = "disk3s1"
+		testMountPoint = "/Volumes/Data"
+	)
+	var ctx = context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	blank := types.SystemPartitions{
+		AllDisksAndPartitions: []types.DiskPart{
+			{DeviceIdentifier: testDiskID},
+		},
+	}
+	formatted := types.SystemPartitions{
+		AllDisksAndPartitions: []types.DiskPart{
+			{
+				DeviceIdentifier: testDiskID,
+				Partitions: []types.Partition{
+					{Content: "EFI", DeviceIdentifier: "disk2s1"},
+					{Content: "Apple_APFS", DeviceIdentifier: "disk2s2"},
+				},
+			},
+			{
+				DeviceIdentifier: "disk3",
+				APFSPhysicalStores: []types.APFSPhysicalStoreID{
+					{DeviceIdentifier: "disk2s2"},
+				},
+				APFSVolumes: []types.APFSVolume{
+					{DeviceIdentifier: testVolumeID},
+				},
+			},
+		},
+	}
+	unmounted := types.DiskInfo{DeviceIdentifier: testVolumeID}
+	mounted := types.DiskInfo{DeviceIdentifier: testVolumeID, MountPoint: testMountPoint}
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	gomock.InOrder(
+		mockUtility.EXPECT().Info(ctx, testDiskID).Return(&types.DiskInfo{DeviceIdentifier: testDiskID, WholeDisk: true}, nil),
+		mockUtility.EXPECT().Info(ctx, "/").Return(&provisionRootDisk, nil),
+		mockUtility.EXPECT().List(ctx, nil).Return(&blank, nil),
+		mockUtility.EXPECT().EraseDisk(ctx, "APFS", "Data", testDiskID).Return("", nil),
+		mockUtility.EXPECT().List(ctx, nil).Return(&formatted, nil),
+		mockUtility.EXPECT().Info(ctx, testVolumeID).Return(&unmounted, nil),
+		mockUtility.EXPECT().Mount(ctx, testVolumeID, testMountPoint).Return("", nil),
+		mockUtility.EXPECT().Info(ctx, testVolumeID).Return(&mounted, nil),
+	)
+
+	di, err := ProvisionVolume(ctx, mockUtility, testDiskID, FormatAPFS, "Data", testMountPoint)
+
+	assert.NoError(t, err, "should be able to provision a blank disk")
+	assert.Equal(t, &mounted, di, "should get the mounted volume's information")
+}
+
+func TestProvisionVolume_FormattedDisk(t *testing.T) {
+	const (
+		testDiskID     = "disk2"
+		testVolumeID   = "disk2s2"
+		testMountPoint = "/Volumes/Data"
+	)
+	var ctx = context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	parts := types.SystemPartitions{
+		AllDisksAndPartitions: []types.DiskPart{
+			{
+				DeviceIdentifier: testDiskID,
+				Partitions: []types.Partition{
+					{Content: "EFI", DeviceIdentifier: "disk2s1"},
+					{Content: "Apple_HFS", DeviceIdentifier: testVolumeID},
+				},
+			},
+		},
+	}
+	mountedElsewhere := types.DiskInfo{DeviceIdentifier: testVolumeID, MountPoint: "/Volumes/Untitled"}
+	mounted := types.DiskInfo{DeviceIdentifier: testVolumeID, MountPoint: testMountPoint}
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	gomock.InOrder(
+		mockUtility.EXPECT().Info(ctx, testDiskID).Return(&types.DiskInfo{DeviceIdentifier: testDiskID, WholeDisk: true}, nil),
+		mockUtility.EXPECT().Info(ctx, "/").Return(&provisionRootDisk, nil),
+		mockUtility.EXPECT().List(ctx, nil).Return(&parts, nil),
+		mockUtility.EXPECT().Info(ctx, testVolumeID).Return(&mountedElsewhere, nil),
+		mockUtility.EXPECT().Unmount(ctx, testVolumeID).Return("", nil),
+		mockUtility.EXPECT().Mount(ctx, testVolumeID, testMountPoint).Return("", nil),
+		mockUtility.EXPECT().Info(ctx, testVolumeID).Return(&mounted, nil),
+	)
+
+	di, err := ProvisionVolume(ctx, mockUtility, testDiskID, FormatJHFS, "Data", testMountPoint)
+
+	assert.NoError(t, err, "should reuse the existing data volume")
+	assert.Equal(t, &mounted, di, "should get the mounted volume's information")
+}
+
+func TestFormatDevice_WithBootDisk(t *testing.T) {
+	const testDiskID = "disk1"
+	var ctx = context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	gomock.InOrder(
+		mockUtility.EXPECT().Info(ctx, testDiskID).Return(&types.DiskInfo{DeviceIdentifier: testDiskID, WholeDisk: true}, nil),
+		mockUtility.EXPECT().Info(ctx, "/").Return(&provisionRootDisk, nil),
+	)
+
+	err := FormatDevice(ctx, mockUtility, testDiskID, FormatAPFS, "Data", true)
+
+	assert.Error(t, err, "shouldn't format the boot disk even when forced")
+}
+
+func TestFormatDevice_WithoutBlankDisk(t *testing.T) {
+	const testDiskID = "disk2"
+	var ctx = context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	parts := types.SystemPartitions{
+		AllDisksAndPartitions: []types.DiskPart{
+			{
+				DeviceIdentifier: testDiskID,
+				Partitions: []types.Partition{
+					{Content: "Apple_HFS", DeviceIdentifier: "disk2s1"},
+				},
+			},
+		},
+	}
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	gomock.InOrder(
+		mockUtility.EXPECT().Info(ctx, testDiskID).Return(&types.DiskInfo{DeviceIdentifier: testDiskID, WholeDisk: true}, nil),
+		mockUtility.EXPECT().Info(ctx, "/").Return(&provisionRootDisk, nil),
+		mockUtility.EXPECT().List(ctx, nil).Return(&parts, nil),
+	)
+
+	err := FormatDevice(ctx, mockUtility, testDiskID, FormatJHFS, "Data", false)
+
+	assert.Error(t, err, "shouldn't format a disk that isn't blank")
+}
+
+func TestFormatDevice_Forced(t *testing.T) {
+	const testDiskID = "disk2"
+	var ctx = context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	gomock.InOrder(
+		mockUtility.EXPECT().Info(ctx, testDiskID).Return(&types.DiskInfo{DeviceIdentifier: testDiskID, WholeDisk: true}, nil),
+		mockUtility.EXPECT().Info(ctx, "/").Return(&provisionRootDisk, nil),
+		mockUtility.EXPECT().UnmountDisk(ctx, testDiskID).Return("", nil),
+		mockUtility.EXPECT().NewFS(ctx, "APFS", "Data", testDiskID).Return("", nil),
+	)
+
+	err := FormatDevice(ctx, mockUtility, testDiskID, FormatAPFS, "Data", true)
+
+	assert.NoError(t, err)
+}
@@ -0,0 +1,103 @@
+package diskutil
+
+import (
+	"strings"
+)
+
+// RepairOutcome is how the repair of a partition map ended.
+type RepairOutcome string
+
+const (
+	// RepairUnknown is the outcome when diskutil didn't report one (e.g. the repair was skipped by a dry run).
+	RepairUnknown RepairOutcome = ""
+	// RepairClean is the outcome when the partition map didn't need to be repaired.
+	RepairClean RepairOutcome = "clean"
+	// RepairRepaired is the outcome when problems with the partition map were found and repaired.
+	RepairRepaired RepairOutcome = "repaired"
+	// RepairErrorsFound is the outcome when problems with the partition map were found that weren't repaired.
+	RepairErrorsFound RepairOutcome = "errors-found"
+)
+
+// RepairResult is the result of repairing a disk's partition map.
+type RepairResult struct {
+	// Outcome is how the repair ended.
+	Outcome RepairOutcome `json:"outcome"`
+	// FreeSpaceUpdated is whether the partition map was adjusted to fit the whole disk, which is how space added
+	// to the disk (e.g. by increasing the size of its EBS volume) becomes available to its partitions.
+	FreeSpaceUpdated bool `json:"free_space_updated"`
+	// Problems are the problems that diskutil reported, in order.
+	Problems []string `json:"problems,omitempty"`
+	// Output is diskutil's output.
+	Output string `json:"-"`
+}
+
+// repairFitWholeDisk is the step of a repair that adjusts the partition map to fit the whole disk.
+const repairFitWholeDisk = "adjusting partition map to fit whole disk"
+
+// repairCleanLines are the lines that diskutil reports when the partition map didn't need to be repaired.
+var repairCleanLines = []string{
+	"the partition map appears to be ok",
+}
+
+// repairRepairedLines are the lines that diskutil reports when the partition map was repaired.
+var repairRepairedLines = []string{
+	"the partition map was repaired",
+	"the partition map needed repair and was repaired",
+	"partition map repair complete",
+}
+
+// repairProblemLines are the lines that diskutil reports when it finds problems with the partition map.
+var repairProblemLines = []string{
+	"problems were found",
+	"could not be repaired",
+	"couldn't be repaired",
+	"needs to be repaired",
+	"error:",
+}
+
+// ParseRepair parses the output of diskutil repairDisk into a RepairResult. diskutil can't report repairs as a
+// plist (unlike most of its verbs), so the lines that end each step are matched instead. Output without any of
+// them parses to the RepairUnknown outcome.
+func ParseRepair(out string) RepairResult {
+	result := RepairResult{Output: out}
+
+	var clean, repaired bool
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		lower := strings.ToLower(line)
+		switch {
+		case lower == "":
+		case strings.HasPrefix(lower, repairFitWholeDisk):
+			result.FreeSpaceUpdated = true
+		case containsAny(lower, repairRepairedLines):
+			repaired = true
+		case containsAny(lower, repairCleanLines):
+			clean = true
+		case containsAny(lower, repairProblemLines):
+			result.Problems = append(result.Problems, line)
+		}
+	}
+
+	switch {
+	case len(result.Problems) > 0 && !repaired:
+		result.Outcome = RepairErrorsFound
+		result.FreeSpaceUpdated = false
+	case repaired:
+		result.Outcome = RepairRepaired
+	case clean:
+		result.Outcome = RepairClean
+	}
+
+	return result
+}
+
+// containsAny is whether s contains any of the substrings.
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,74 @@
+package diskutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRepair(t *testing.T) {
+	tests := []struct {
+		name             string
+		out              string
+		wantOutcome      RepairOutcome
+		wantFreeSpace    bool
+		wantProblemCount int
+	}{
+		{
+			name: "Clean",
+			out: `Started partition map verification/repair on disk0
+Checking prerequisites
+Checking the partition list
+Adjusting partition map to fit whole disk as required
+Checking for an EFI system partition
+The partition map appears to be OK
+Finished partition map verification/repair on disk0
+`,
+			wantOutcome:   RepairClean,
+			wantFreeSpace: true,
+		},
+		{
+			name: "Repaired",
+			out: `Started partition map verification/repair on disk0
+Adjusting partition map to fit whole disk as required
+Problems were found with the partition map which might prevent booting
+The partition map was repaired
+Finished partition map verification/repair on disk0
+`,
+			wantOutcome:      RepairRepaired,
+			wantFreeSpace:    true,
+			wantProblemCount: 1,
+		},
+		{
+			name: "ErrorsFound",
+			out: `Started partition map verification/repair on disk0
+Adjusting partition map to fit whole disk as required
+Error: -69808: The partition map needs to be repaired because there are problems
+`,
+			wantOutcome:      RepairErrorsFound,
+			wantProblemCount: 1,
+		},
+		{
+			name: "WithoutFitWholeDisk",
+			out: `Started partition map verification/repair on disk0
+The partition map appears to be OK
+`,
+			wantOutcome: RepairClean,
+		},
+		{
+			name:        "Empty",
+			wantOutcome: RepairUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ParseRepair(tt.out)
+
+			assert.Equal(t, tt.wantOutcome, result.Outcome, "should parse the outcome")
+			assert.Equal(t, tt.wantFreeSpace, result.FreeSpaceUpdated, "should parse the free space update")
+			assert.Len(t, result.Problems, tt.wantProblemCount, "should parse the problems")
+			assert.Equal(t, tt.out, result.Output, "should keep the output")
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package diskutil
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/fixture"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+	"github.com/aws/ec2-macos-utils/pkg/system"
+)
+
+var (
+	// fixturesFS contains the diskutil output recorded for each release by the hidden "fixtures record" command.
+	//
+	//go:embed testdata/fixtures
+	fixturesFS embed.FS
+)
+
+// fixtureProducts are the products that the fixture corpus covers.
+var fixtureProducts = []system.Product{
+	{Release: system.Mojave, Version: *semver.MustParse("10.14.6")},
+	{Release: system.Catalina, Version: *semver.MustParse("10.15.7")},
+	{Release: system.BigSur, Version: *semver.MustParse("11.7.10")},
+	{Release: system.Monterey, Version: *semver.MustParse("12.7.1")},
+	{Release: system.Ventura, Version: *semver.MustParse("13.6.1")},
+	{Release: system.Sonoma, Version: *semver.MustParse("14.1.1")},
+}
+
+// replayerForProduct creates a DiskUtil for the product which replays its release's fixtures.
+func replayerForProduct(t *testing.T, p system.Product) DiskUtil {
+	t.Helper()
+
+	fsys, err := fs.Sub(fixturesFS, "testdata/fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer, err := fixture.LoadReplayer(fsys, p.Release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := ForProduct(&p, WithExecutor(replayer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
+
+func TestReplay_RootParentDevice(t *testing.T) {
+	for _, p := range fixtureProducts {
+		t.Run(p.Release.String(), func(t *testing.T) {
+			u := replayerForProduct(t, p)
+
+			root, err := u.Info(context.Background(), "/")
+			assert.NoError(t, err)
+
+			parent, err := root.ParentDeviceID()
+			assert.NoError(t, err)
+			assert.Equal(t, "disk0", parent, "root should be on the boot disk")
+		})
+	}
+}
+
+func TestReplay_ListPhysicalStores(t *testing.T) {
+	for _, p := range fixtureProducts {
+		t.Run(p.Release.String(), func(t *testing.T) {
+			u := replayerForProduct(t, p)
+
+			partitions, err := u.List(context.Background(), nil)
+			assert.NoError(t, err)
+
+			for _, part := range partitions.AllDisksAndPartitions {
+				if part.APFSVolumes == nil {
+					continue
+				}
+				if assert.Len(t, part.APFSPhysicalStores, 1, "container [%s] should have one physical store", part.DeviceIdentifier) {
+					assert.Equal(t, "disk0s2", part.APFSPhysicalStores[0].DeviceIdentifier)
+				}
+			}
+
+			free, err := partitions.AvailableDiskSpace("disk0")
+			assert.NoError(t, err)
+			assert.NotZero(t, free)
+		})
+	}
+}
+
+func TestReplay_ContainerInfo(t *testing.T) {
+	for _, p := range fixtureProducts {
+		t.Run(p.Release.String(), func(t *testing.T) {
+			u := replayerForProduct(t, p)
+
+			container, err := u.Info(context.Background(), "disk1")
+			assert.NoError(t, err)
+
+			parent, err := container.ParentDeviceID()
+			assert.NoError(t, err)
+			assert.Equal(t, "disk0", parent)
+
+			disk, err := u.Info(context.Background(), parent)
+			assert.NoError(t, err)
+			assert.True(t, disk.Internal)
+		})
+	}
+}
+
+func TestReplay_ClassifyDisks(t *testing.T) {
+	for _, p := range fixtureProducts {
+		t.Run(p.Release.String(), func(t *testing.T) {
+			u := replayerForProduct(t, p)
+
+			partitions, err := u.List(context.Background(), nil)
+			assert.NoError(t, err)
+
+			assert.NoError(t, ClassifyDisks(context.Background(), u, partitions))
+			for _, part := range partitions.AllDisksAndPartitions {
+				assert.Equal(t, types.KindEBS, part.Kind, "disk [%s] should be on the EBS boot volume", part.DeviceIdentifier)
+			}
+
+			root, err := u.Info(context.Background(), "/")
+			assert.NoError(t, err)
+			kind, err := ResolveKind(context.Background(), u, root)
+			assert.NoError(t, err)
+			assert.Equal(t, types.KindEBS, kind)
+		})
+	}
+}
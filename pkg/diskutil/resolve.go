@@ -0,0 +1,144 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/identifier"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+)
+
+// ErrNotFound is returned when a reference doesn't match any disk or volume.
+var ErrNotFound = errors.New("no matching disk or volume")
+
+// Resolve finds the canonical device identifier (e.g. "disk2s1") of the disk, partition, APFS container, or APFS
+// volume that ref refers to. ref can be a device identifier, a device node (e.g. "/dev/disk2s1" or
+// "/dev/rdisk2s1"), a volume UUID, a volume label, or a mount point. Device identifiers and nodes are normalized
+// without running diskutil, the other references are matched against the disks that List reports.
+func Resolve(ctx context.Context, u DiskUtil, ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return "", errors.New("empty disk reference")
+	}
+	if id, ok := identifier.Normalize(ref); ok {
+		return id, nil
+	}
+
+	partitions, err := u.List(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot list disks: %w", err)
+	}
+
+	matches := matchVolumes(partitions, ref)
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w for [%s]", ErrNotFound, ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("[%s] matches more than one volume: %s", ref, strings.Join(matches, ", "))
+	}
+}
+
+// ResolveContainer resolves ref like Resolve and then finds the APFS container that holds it: APFS volumes and
+// their snapshots are held by their container and APFS physical stores hold the container stored on them.
+// Containers resolve to themselves.
+func ResolveContainer(ctx context.Context, u DiskUtil, ref string) (string, error) {
+	id, err := Resolve(ctx, u, ref)
+	if err != nil {
+		return "", err
+	}
+
+	partitions, err := u.List(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot list disks: %w", err)
+	}
+
+	for _, part := range partitions.AllDisksAndPartitions {
+		if len(part.APFSPhysicalStores) == 0 {
+			continue
+		}
+		if strings.EqualFold(part.DeviceIdentifier, id) {
+			return part.DeviceIdentifier, nil
+		}
+		for _, store := range part.APFSPhysicalStores {
+			if strings.EqualFold(store.DeviceIdentifier, id) {
+				return part.DeviceIdentifier, nil
+			}
+		}
+		for _, volume := range part.APFSVolumes {
+			if strings.EqualFold(volume.DeviceIdentifier, id) {
+				return part.DeviceIdentifier, nil
+			}
+			for _, snapshot := range volume.MountedSnapshots {
+				if strings.EqualFold(snapshot.SnapshotBSD, id) || strings.EqualFold(snapshot.SnapshotBSD, "/dev/"+id) {
+					return part.DeviceIdentifier, nil
+				}
+			}
+		}
+	}
+
+	return "", fmt.Errorf("[%s] is not an APFS container, volume, or physical store", id)
+}
+
+// matchVolumes finds the device identifiers of the partitions and APFS volumes that ref refers to. Absolute paths
+// are matched against the mount points (including those of mounted snapshots, such as "/" on the signed system
+// volume). Other references are matched against the volume UUIDs and then against the volume labels.
+func matchVolumes(partitions *types.SystemPartitions, ref string) []string {
+	type volume struct {
+		id, uuid, name string
+		mountPoints    []string
+	}
+
+	var volumes []volume
+	for _, part := range partitions.AllDisksAndPartitions {
+		if part.MountPoint != "" {
+			volumes = append(volumes, volume{id: part.DeviceIdentifier, mountPoints: []string{part.MountPoint}})
+		}
+		for _, p := range part.Partitions {
+			volumes = append(volumes, volume{id: p.DeviceIdentifier, uuid: p.VolumeUUID, name: p.VolumeName, mountPoints: []string{p.MountPoint}})
+		}
+		for _, v := range part.APFSVolumes {
+			mountPoints := []string{v.MountPoint}
+			for _, s := range v.MountedSnapshots {
+				mountPoints = append(mountPoints, s.SnapshotMountPoint)
+			}
+			volumes = append(volumes, volume{id: v.DeviceIdentifier, uuid: v.VolumeUUID, name: v.VolumeName, mountPoints: mountPoints})
+		}
+	}
+
+	var matches []string
+	if filepath.IsAbs(ref) {
+		ref = filepath.Clean(ref)
+		for _, v := range volumes {
+			for _, mp := range v.mountPoints {
+				if mp != "" && filepath.Clean(mp) == ref {
+					matches = append(matches, v.id)
+					break
+				}
+			}
+		}
+
+		return matches
+	}
+
+	for _, v := range volumes {
+		if v.uuid != "" && strings.EqualFold(v.uuid, ref) {
+			matches = append(matches, v.id)
+		}
+	}
+	if len(matches) > 0 {
+		return matches
+	}
+
+	for _, v := range volumes {
+		if v.name != "" && v.name == ref {
+			matches = append(matches, v.id)
+		}
+	}
+
+	return matches
+}
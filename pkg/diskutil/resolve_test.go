@@ -0,0 +1,135 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mock_diskutil "github.com/aws/ec2-macos-utils/pkg/diskutil/mocks"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// resolvePartitions has a boot container, disk3, on disk0s2 whose system volume is mounted from a sealed snapshot
+// and a data container, disk5, on the EBS volume disk4.
+var resolvePartitions = &types.SystemPartitions{
+	AllDisksAndPartitions: []types.DiskPart{
+		{DeviceIdentifier: "disk0", Partitions: []types.Partition{
+			{DeviceIdentifier: "disk0s1", Content: "EFI", VolumeName: "EFI", VolumeUUID: "0E239BC6-F960-3107-89CF-1C97F78BB46B"},
+			{DeviceIdentifier: "disk0s2", Content: "Apple_APFS"},
+		}},
+		{DeviceIdentifier: "disk3", APFSPhysicalStores: []types.APFSPhysicalStoreID{{DeviceIdentifier: "disk0s2"}}, APFSVolumes: []types.APFSVolume{
+			{DeviceIdentifier: "disk3s1", VolumeName: "Macintosh HD", MountedSnapshots: []types.Snapshot{{SnapshotBSD: "disk3s1s1", SnapshotMountPoint: "/"}}},
+			{DeviceIdentifier: "disk3s5", VolumeName: "Data", MountPoint: "/System/Volumes/Data", VolumeUUID: "A1B2C3D4-0000-4000-8000-000000000001"},
+		}},
+		{DeviceIdentifier: "disk4", Partitions: []types.Partition{
+			{DeviceIdentifier: "disk4s2", Content: "Apple_APFS"},
+		}},
+		{DeviceIdentifier: "disk5", APFSPhysicalStores: []types.APFSPhysicalStoreID{{DeviceIdentifier: "disk4s2"}}, APFSVolumes: []types.APFSVolume{
+			{DeviceIdentifier: "disk5s1", VolumeName: "Data", MountPoint: "/Volumes/Data", VolumeUUID: "A1B2C3D4-0000-4000-8000-000000000002"},
+		}},
+	},
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{ref: "/System/Volumes/Data/", want: "disk3s5"},
+		{ref: "/", want: "disk3s1"},
+		{ref: "a1b2c3d4-0000-4000-8000-000000000002", want: "disk5s1"},
+		{ref: "Macintosh HD", want: "disk3s1"},
+		{ref: "EFI", want: "disk0s1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+			mockUtility.EXPECT().List(gomock.Any(), nil).Return(resolvePartitions, nil)
+
+			id, err := Resolve(context.Background(), mockUtility, tt.ref)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, id)
+		})
+	}
+}
+
+func TestResolve_DeviceNode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+
+	id, err := Resolve(context.Background(), mockUtility, "/dev/rdisk4s2")
+
+	assert.NoError(t, err, "should normalize device nodes without listing disks")
+	assert.Equal(t, "disk4s2", id)
+}
+
+func TestResolve_AmbiguousLabel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	mockUtility.EXPECT().List(gomock.Any(), nil).Return(resolvePartitions, nil)
+
+	_, err := Resolve(context.Background(), mockUtility, "Data")
+
+	assert.EqualError(t, err, "[Data] matches more than one volume: disk3s5, disk5s1")
+}
+
+func TestResolve_NotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	mockUtility.EXPECT().List(gomock.Any(), nil).Return(resolvePartitions, nil)
+
+	_, err := Resolve(context.Background(), mockUtility, "/Volumes/Missing")
+
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestResolveContainer(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{ref: "disk5", want: "disk5"},
+		{ref: "disk5s1", want: "disk5"},
+		{ref: "/dev/disk4s2", want: "disk5"},
+		{ref: "disk3s1s1", want: "disk3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+			mockUtility.EXPECT().List(gomock.Any(), nil).Return(resolvePartitions, nil)
+
+			id, err := ResolveContainer(context.Background(), mockUtility, tt.ref)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, id)
+		})
+	}
+}
+
+func TestResolveContainer_NotAPFS(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUtility := mock_diskutil.NewMockDiskUtil(ctrl)
+	mockUtility.EXPECT().List(gomock.Any(), nil).Return(resolvePartitions, nil)
+
+	_, err := ResolveContainer(context.Background(), mockUtility, "disk0s1")
+
+	assert.EqualError(t, err, "[disk0s1] is not an APFS container, volume, or physical store")
+}
@@ -0,0 +1,195 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/events"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// scratchFreeFormat is diskutil's personality for free space, which leaves the disk without a volume.
+	scratchFreeFormat = "free"
+	// scratchFreeName is the volume name diskutil expects when no volume is formatted.
+	scratchFreeName = "%noformat%"
+	// appleFabricProtocol is the bus protocol of the internal SSDs of Apple silicon hosts.
+	appleFabricProtocol = "Apple Fabric"
+)
+
+// FindScratchDisk finds the host's internal SSD that can be used as a scratch disk. Only the internal SSD of Intel
+// hosts (e.g. mac1.metal) can be used since the internal SSD of Apple silicon hosts holds their boot policy, and
+// never when it's the boot disk.
+func FindScratchDisk(ctx context.Context, u DiskUtil) (*types.DiskInfo, error) {
+	partitions, err := u.List(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list partitions: %w", err)
+	}
+	classifyErr := ClassifyDisks(ctx, u, partitions)
+
+	var candidates []string
+	for _, part := range partitions.AllDisksAndPartitions {
+		if part.Kind == types.KindInternal && len(part.APFSPhysicalStores) == 0 {
+			candidates = append(candidates, part.DeviceIdentifier)
+		}
+	}
+	switch {
+	case len(candidates) == 0 && classifyErr != nil:
+		return nil, fmt.Errorf("no internal SSD found: %w", classifyErr)
+	case len(candidates) == 0:
+		return nil, errors.New("no internal SSD found")
+	case len(candidates) > 1:
+		return nil, fmt.Errorf("found more than one internal SSD: %s", strings.Join(candidates, ", "))
+	}
+
+	disk, err := u.Info(ctx, candidates[0])
+	if err != nil {
+		return nil, fmt.Errorf("unable to get disk information: %w", err)
+	}
+	if strings.EqualFold(disk.BusProtocol, appleFabricProtocol) {
+		return nil, fmt.Errorf("device [%s] is the internal SSD of an Apple silicon host, which holds its boot policy", disk.DeviceIdentifier)
+	}
+	if err := AssertNotBootDisk(ctx, u, disk); err != nil {
+		return nil, err
+	}
+
+	return disk, nil
+}
+
+// ProvisionScratch erases the host's internal SSD (see FindScratchDisk) and formats it with a single APFS volume,
+// named label, which is mounted at mountPoint. The SSD is only erased when it doesn't already hold a volume named
+// label so that provisioning can safely be repeated; any other data on it is lost.
+//
+// The types.DiskInfo for the mounted scratch volume is returned on success. No information is returned when the
+// disk would have been erased in dry-run mode. The operation is published to the events Bus in ctx.
+func ProvisionScratch(ctx context.Context, u DiskUtil, label string, mountPoint string) (*types.DiskInfo, error) {
+	span := events.FromContext(ctx).Start(events.OperationProvision, "")
+	volume, err := provisionScratch(ctx, u, label, mountPoint)
+	span.End(err)
+
+	return volume, err
+}
+
+// provisionScratch provisions the scratch volume as described by ProvisionScratch.
+func provisionScratch(ctx context.Context, u DiskUtil, label string, mountPoint string) (*types.DiskInfo, error) {
+	disk, err := FindScratchDisk(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	volumeID, err := findScratchVolume(ctx, u, disk, label)
+	if err != nil {
+		return nil, err
+	}
+	if volumeID == "" {
+		logrus.WithFields(logrus.Fields{
+			"device_id": disk.DeviceIdentifier,
+			"label":     label,
+		}).Info("Erasing internal SSD...")
+		out, err := u.EraseDisk(ctx, string(FormatAPFS), label, disk.DeviceIdentifier)
+		logrus.WithField("out", out).Debug("EraseDisk output")
+		if errors.Is(err, ErrReadOnly) {
+			logrus.WithError(err).Warn("Would have erased internal SSD")
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if volumeID, err = findScratchVolume(ctx, u, disk, label); err != nil {
+			return nil, err
+		}
+		if volumeID == "" {
+			return nil, fmt.Errorf("no scratch volume found on device [%s] after erasing", disk.DeviceIdentifier)
+		}
+	} else {
+		logrus.WithFields(logrus.Fields{
+			"device_id": disk.DeviceIdentifier,
+			"volume_id": volumeID,
+		}).Info("Internal SSD already has a scratch volume, skipping erase")
+	}
+
+	volume, err := u.Info(ctx, volumeID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get volume information: %w", err)
+	}
+	if err := mountVolume(ctx, u, volume, mountPoint); err != nil {
+		return nil, err
+	}
+
+	return u.Info(ctx, volumeID)
+}
+
+// findScratchVolume finds the device identifier of the volume named label on the disk. An empty string is returned
+// when the disk has no volume or its volume has another name.
+func findScratchVolume(ctx context.Context, u DiskUtil, disk *types.DiskInfo, label string) (string, error) {
+	partitions, err := u.List(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot list partitions: %w", err)
+	}
+
+	volumeID := findDataVolume(partitions, disk.DeviceIdentifier)
+	if volumeID == "" {
+		return "", nil
+	}
+	volume, err := u.Info(ctx, volumeID)
+	if err != nil {
+		return "", fmt.Errorf("unable to get volume information: %w", err)
+	}
+	if volume.VolumeName != label {
+		return "", nil
+	}
+
+	return volumeID, nil
+}
+
+// RemoveScratch unmounts the scratch volume on the host's internal SSD (see FindScratchDisk) and erases the SSD to
+// free space, leaving only its partition map. The types.DiskInfo for the scratch volume that was removed is returned
+// so that its mount can be removed from /etc/fstab, nil is returned when the SSD had no volume. The operation is
+// published to the events Bus in ctx.
+func RemoveScratch(ctx context.Context, u DiskUtil) (*types.DiskInfo, error) {
+	span := events.FromContext(ctx).Start(events.OperationProvision, "")
+	volume, err := removeScratch(ctx, u)
+	span.End(err)
+
+	return volume, err
+}
+
+// removeScratch removes the scratch volume as described by RemoveScratch.
+func removeScratch(ctx context.Context, u DiskUtil) (*types.DiskInfo, error) {
+	disk, err := FindScratchDisk(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	partitions, err := u.List(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list partitions: %w", err)
+	}
+	var volume *types.DiskInfo
+	if volumeID := findDataVolume(partitions, disk.DeviceIdentifier); volumeID != "" {
+		if volume, err = u.Info(ctx, volumeID); err != nil {
+			return nil, fmt.Errorf("unable to get volume information: %w", err)
+		}
+	}
+
+	if volume != nil && volume.MountPoint != "" {
+		if err := unmountVolume(ctx, u, volume); err != nil {
+			return nil, err
+		}
+	}
+
+	logrus.WithField("device_id", disk.DeviceIdentifier).Info("Erasing internal SSD to free space...")
+	out, err := u.EraseDisk(ctx, scratchFreeFormat, scratchFreeName, disk.DeviceIdentifier)
+	logrus.WithField("out", out).Debug("EraseDisk output")
+	if errors.Is(err, ErrReadOnly) {
+		logrus.WithError(err).Warn("Would have erased internal SSD")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return volume, nil
+}
@@ -0,0 +1,96 @@
+package types
+
+// Changes are the differences between two SystemPartitions snapshots. Disks, partitions, and APFS volumes are all
+// compared by their device identifiers.
+type Changes struct {
+	// Added are the device identifiers that are only in the later snapshot, in its order.
+	Added []string
+	// Removed are the device identifiers that are only in the earlier snapshot, in its order.
+	Removed []string
+	// Resized are the devices whose size changed.
+	Resized []SizeChange
+	// Remounted are the devices whose mount point changed, including those that were mounted or unmounted.
+	Remounted []MountChange
+}
+
+// SizeChange is a change in the size of a device.
+type SizeChange struct {
+	DeviceIdentifier string
+	Before           uint64
+	After            uint64
+}
+
+// MountChange is a change in the mount point of a device. An empty mount point means the device wasn't mounted.
+type MountChange struct {
+	DeviceIdentifier string
+	Before           string
+	After            string
+}
+
+// Empty checks if there are no changes.
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Resized) == 0 && len(c.Remounted) == 0
+}
+
+// device is the information about a disk, partition, or APFS volume that's compared between snapshots.
+type device struct {
+	size       uint64
+	mountPoint string
+}
+
+// Diff finds the changes between the before and after snapshots. A nil snapshot has no devices.
+func Diff(before, after *SystemPartitions) Changes {
+	beforeIDs, beforeDevices := devices(before)
+	afterIDs, afterDevices := devices(after)
+
+	var changes Changes
+	for _, id := range beforeIDs {
+		if _, ok := afterDevices[id]; !ok {
+			changes.Removed = append(changes.Removed, id)
+		}
+	}
+	for _, id := range afterIDs {
+		a := afterDevices[id]
+		b, ok := beforeDevices[id]
+		if !ok {
+			changes.Added = append(changes.Added, id)
+			continue
+		}
+		if b.size != a.size {
+			changes.Resized = append(changes.Resized, SizeChange{DeviceIdentifier: id, Before: b.size, After: a.size})
+		}
+		if b.mountPoint != a.mountPoint {
+			changes.Remounted = append(changes.Remounted, MountChange{DeviceIdentifier: id, Before: b.mountPoint, After: a.mountPoint})
+		}
+	}
+
+	return changes
+}
+
+// devices gets the device identifiers of the disks, partitions, and APFS volumes in the snapshot, in order, and
+// their information.
+func devices(p *SystemPartitions) ([]string, map[string]device) {
+	var ids []string
+	found := make(map[string]device)
+	add := func(id string, d device) {
+		if _, ok := found[id]; !ok {
+			ids = append(ids, id)
+		}
+		found[id] = d
+	}
+
+	if p == nil {
+		return ids, found
+	}
+	for _, disk := range p.AllDisksAndPartitions {
+		add(disk.DeviceIdentifier, device{size: disk.Size, mountPoint: disk.MountPoint})
+		for _, part := range disk.Partitions {
+			add(part.DeviceIdentifier, device{size: part.Size, mountPoint: part.MountPoint})
+		}
+		for _, volume := range disk.APFSVolumes {
+			add(volume.DeviceIdentifier, device{size: volume.Size, mountPoint: volume.MountPoint})
+		}
+	}
+
+	return ids, found
+}
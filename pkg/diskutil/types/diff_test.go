@@ -0,0 +1,59 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	before := &SystemPartitions{
+		AllDisksAndPartitions: []DiskPart{
+			{DeviceIdentifier: "disk0", Size: 150, Partitions: []Partition{
+				{DeviceIdentifier: "disk0s1", Size: 1},
+				{DeviceIdentifier: "disk0s2", Size: 100},
+			}},
+			{DeviceIdentifier: "disk2", Size: 500, MountPoint: "/Volumes/scratch"},
+			{DeviceIdentifier: "disk3", Size: 100, APFSVolumes: []APFSVolume{
+				{DeviceIdentifier: "disk3s1", MountPoint: "/", Size: 20},
+				{DeviceIdentifier: "disk3s2", MountPoint: "/Volumes/data", Size: 5},
+			}},
+		},
+	}
+	after := &SystemPartitions{
+		AllDisksAndPartitions: []DiskPart{
+			{DeviceIdentifier: "disk0", Size: 150, Partitions: []Partition{
+				{DeviceIdentifier: "disk0s1", Size: 1},
+				{DeviceIdentifier: "disk0s2", Size: 149},
+			}},
+			{DeviceIdentifier: "disk3", Size: 149, APFSVolumes: []APFSVolume{
+				{DeviceIdentifier: "disk3s1", MountPoint: "/", Size: 20},
+				{DeviceIdentifier: "disk3s2", MountPoint: "/Volumes/builds", Size: 5},
+			}},
+			{DeviceIdentifier: "disk4", Size: 1000},
+		},
+	}
+
+	changes := Diff(before, after)
+
+	assert.Equal(t, Changes{
+		Added:   []string{"disk4"},
+		Removed: []string{"disk2"},
+		Resized: []SizeChange{
+			{DeviceIdentifier: "disk0s2", Before: 100, After: 149},
+			{DeviceIdentifier: "disk3", Before: 100, After: 149},
+		},
+		Remounted: []MountChange{
+			{DeviceIdentifier: "disk3s2", Before: "/Volumes/data", After: "/Volumes/builds"},
+		},
+	}, changes)
+	assert.False(t, changes.Empty())
+}
+
+func TestDiff_NoChanges(t *testing.T) {
+	p := &SystemPartitions{AllDisksAndPartitions: []DiskPart{{DeviceIdentifier: "disk0", Size: 150}}}
+
+	assert.True(t, Diff(p, p).Empty())
+	assert.True(t, Diff(nil, nil).Empty())
+	assert.Equal(t, []string{"disk0"}, Diff(nil, p).Added)
+}
@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/aws/ec2-macos-utils/internal/diskutil/identifier"
+	"github.com/aws/ec2-macos-utils/pkg/diskutil/identifier"
 )
 
 // DiskInfo mirrors the output format of the command "diskutil info -plist <disk>" to store information about a disk.
@@ -57,6 +57,10 @@ type DiskInfo struct {
 	Writable                                    bool                `plist:"Writable"`
 	WritableMedia                               bool                `plist:"WritableMedia"`
 	WritableVolume                              bool                `plist:"WritableVolume"`
+
+	// TRIM is whether the disk's physical device supports TRIM and has it enabled, which isn't part of diskutil's
+	// output. It's only set once it's been resolved from the host's storage drivers.
+	TRIM *TRIMStatus `plist:"-"`
 }
 
 // IsPhysical checks if the disk is physical or virtual.
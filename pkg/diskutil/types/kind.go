@@ -0,0 +1,51 @@
+package types
+
+import (
+	"strings"
+)
+
+// DiskKind is the kind of hardware backing a physical disk. diskutil reports EBS volumes as internal disks since
+// they're attached over PCIe so the kind is identified from the disk's media instead.
+type DiskKind string
+
+const (
+	// KindUnknown is the kind of disks that haven't been classified, such as virtual disks whose physical disk
+	// wasn't resolved.
+	KindUnknown DiskKind = ""
+	// KindInternal is the Apple SSD built into the host (e.g. the internal SSD of mac1.metal instances).
+	KindInternal DiskKind = "internal"
+	// KindEBS is an EBS volume attached to the instance as an NVMe device.
+	KindEBS DiskKind = "ebs"
+	// KindOther is any other physical disk (e.g. a disk image attached with hdiutil).
+	KindOther DiskKind = "other"
+)
+
+const (
+	// ebsMediaName is the NVMe model name, reported as the media name, of EBS volumes.
+	ebsMediaName = "amazon elastic block store"
+	// appleSSDMediaPrefix is the prefix of the NVMe model name of Apple's internal SSDs (e.g. "APPLE SSD AP0256M").
+	appleSSDMediaPrefix = "apple ssd"
+	// appleFabricProtocol is the bus protocol of the internal SSDs of Apple silicon hosts.
+	appleFabricProtocol = "apple fabric"
+)
+
+// Kind classifies the physical disk by its media name and IORegistry entry, which hold the NVMe device's model, and
+// its bus protocol. Virtual disks (e.g. APFS containers and volumes) are KindUnknown since their media is synthesized,
+// their physical disk needs to be classified instead.
+func (d *DiskInfo) Kind() DiskKind {
+	if !d.IsPhysical() {
+		return KindUnknown
+	}
+
+	media := strings.ToLower(d.MediaName)
+	entry := strings.ToLower(d.IORegistryEntryName)
+	switch {
+	case strings.Contains(media, ebsMediaName), strings.Contains(entry, ebsMediaName):
+		return KindEBS
+	case strings.HasPrefix(media, appleSSDMediaPrefix), strings.HasPrefix(entry, appleSSDMediaPrefix),
+		strings.EqualFold(d.BusProtocol, appleFabricProtocol):
+		return KindInternal
+	default:
+		return KindOther
+	}
+}
@@ -0,0 +1,46 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskInfo_Kind(t *testing.T) {
+	tests := []struct {
+		name string
+		disk DiskInfo
+		want DiskKind
+	}{
+		{
+			name: "ebs",
+			disk: DiskInfo{MediaName: "Amazon Elastic Block Store", BusProtocol: "PCI-Express", Internal: true, VirtualOrPhysical: "Physical"},
+			want: KindEBS,
+		},
+		{
+			name: "mac1 internal ssd",
+			disk: DiskInfo{MediaName: "APPLE SSD AP0256M", BusProtocol: "PCI-Express", Internal: true, VirtualOrPhysical: "Physical"},
+			want: KindInternal,
+		},
+		{
+			name: "apple silicon internal ssd",
+			disk: DiskInfo{BusProtocol: "Apple Fabric", Internal: true, VirtualOrPhysical: "Physical"},
+			want: KindInternal,
+		},
+		{
+			name: "disk image",
+			disk: DiskInfo{MediaName: "Apple UDIF read-write Media", BusProtocol: "Disk Image", VirtualOrPhysical: "Physical"},
+			want: KindOther,
+		},
+		{
+			name: "apfs container",
+			disk: DiskInfo{MediaName: "AppleAPFSMedia", VirtualOrPhysical: "Virtual"},
+			want: KindUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.disk.Kind())
+		})
+	}
+}
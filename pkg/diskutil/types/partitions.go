@@ -50,9 +50,14 @@ type DiskPart struct {
 	APFSVolumes        []APFSVolume          `plist:"APFSVolumes"`
 	Content            string                `plist:"Content"`
 	DeviceIdentifier   string                `plist:"DeviceIdentifier"`
+	MountPoint         string                `plist:"MountPoint"`
 	OSInternal         bool                  `plist:"OSInternal"`
 	Partitions         []Partition           `plist:"Partitions"`
 	Size               uint64                `plist:"Size"`
+
+	// Kind is the kind of hardware backing the disk, which isn't part of diskutil's output. It's only set once
+	// the partitions have been classified (see diskutil.ClassifyDisks).
+	Kind DiskKind `plist:"-"`
 }
 
 // Partition stores relevant information about a partition in macOS.
@@ -60,6 +65,7 @@ type Partition struct {
 	Content          string `plist:"Content"`
 	DeviceIdentifier string `plist:"DeviceIdentifier"`
 	DiskUUID         string `plist:"DiskUUID"`
+	MountPoint       string `plist:"MountPoint"`
 	Size             uint64 `plist:"Size"`
 	VolumeName       string `plist:"VolumeName"`
 	VolumeUUID       string `plist:"VolumeUUID"`
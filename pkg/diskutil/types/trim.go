@@ -0,0 +1,23 @@
+package types
+
+// TRIMStatus describes whether a device discards the blocks that are no longer in use, with TRIM for SATA devices
+// and deallocate (unmap) for NVMe devices. Flash-backed and thin-provisioned devices, such as EBS volumes, perform
+// better when the blocks freed by the filesystem are discarded.
+type TRIMStatus struct {
+	// Supported indicates that the device reports support for TRIM.
+	Supported bool
+	// Enabled indicates that the storage driver issues TRIM commands to the device.
+	Enabled bool
+}
+
+// String describes the status (e.g. "enabled" or "supported but disabled").
+func (s TRIMStatus) String() string {
+	switch {
+	case s.Enabled:
+		return "enabled"
+	case s.Supported:
+		return "supported but disabled"
+	default:
+		return "unsupported"
+	}
+}
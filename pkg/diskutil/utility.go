@@ -0,0 +1,403 @@
+package diskutil
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/pkg/progress"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// UtilImpl outlines the functionality necessary for wrapping macOS's diskutil tool. The methods are intentionally
+// named to correspond to diskutil(8)'s subcommand names as its API.
+type UtilImpl interface {
+	// APFSImpl outlines the functionality necessary for wrapping diskutil's APFS verb.
+	APFSImpl
+	// EraseDisk erases the whole disk for the specified device identifier and formats it with a single volume.
+	// This process requires root access.
+	EraseDisk(ctx context.Context, format string, name string, id string) (string, error)
+	// Info fetches raw disk information for the specified device identifier.
+	Info(ctx context.Context, id string) (string, error)
+	// List fetches all disk and partition information for the system.
+	// This output will be filtered based on the args provided.
+	List(ctx context.Context, args []string) (string, error)
+	// Mount mounts the volume for the specified device identifier at the given mount point.
+	Mount(ctx context.Context, id string, mountPoint string) (string, error)
+	// NewFS formats the whole disk for the specified device identifier with a single filesystem using the
+	// format's newfs tool directly, without writing a partition map. This process requires root access.
+	NewFS(ctx context.Context, format string, name string, id string) (string, error)
+	// RepairDisk attempts to repair the disk for the specified device identifier.
+	// This process requires root access.
+	RepairDisk(ctx context.Context, id string) (string, error)
+	// ResetUserPermissions resets the permissions of the files on the mounted volume for the specified device
+	// identifier so that they're owned by the user with the given UID. This process requires root access.
+	ResetUserPermissions(ctx context.Context, id string, uid int) (string, error)
+	// Unmount unmounts the volume for the specified device identifier.
+	Unmount(ctx context.Context, id string) (string, error)
+	// UnmountDisk unmounts all of the volumes on the whole disk for the specified device identifier.
+	UnmountDisk(ctx context.Context, id string) (string, error)
+}
+
+// APFSImpl outlines the functionality necessary for wrapping diskutil's APFS verb.
+type APFSImpl interface {
+	// AddVolume adds a volume, named name, with the given format to the APFS container with the given device
+	// identifier without mounting it. The volume is encrypted with the passphrase unless it's empty.
+	// This process requires root access.
+	AddVolume(ctx context.Context, id string, format string, name string, passphrase string) (string, error)
+	// CreateContainer creates an empty APFS container on the whole disk or partition with the given device
+	// identifier, erasing it. This process requires root access.
+	CreateContainer(ctx context.Context, id string) (string, error)
+	// DeleteContainer deletes the APFS container with the given device identifier, or stored on the given
+	// physical store, along with all of its volumes. This process requires root access.
+	DeleteContainer(ctx context.Context, id string) (string, error)
+	// ResizeContainer attempts to grow the APFS container with the given device identifier
+	// to the specified size. If the given size is 0, ResizeContainer will attempt to grow
+	// the disk to its maximum size.
+	ResizeContainer(ctx context.Context, id string, size string) (string, error)
+	// UnlockVolume unlocks and mounts the encrypted APFS volume with the given device identifier with the
+	// passphrase. This process requires root access.
+	UnlockVolume(ctx context.Context, id string, passphrase string) (string, error)
+}
+
+// DiskUtilityCmd provides the implementation for the DiskUtility interface.
+// Failures of the mutating commands are returned as a DiagnosedError when related system log entries are found.
+type DiskUtilityCmd struct {
+	// Executor runs the commands, which are run on the system when it's nil.
+	Executor util.Executor
+}
+
+// executor gets the Executor that runs the commands.
+func (d *DiskUtilityCmd) executor() util.Executor {
+	if d.Executor == nil {
+		return util.SystemExecutor{}
+	}
+
+	return d.Executor
+}
+
+// List uses the macOS diskutil list command to list disks and partitions in a plist format by passing the -plist arg.
+// List also appends any given args to fully support the diskutil list verb.
+func (d *DiskUtilityCmd) List(ctx context.Context, args []string) (string, error) {
+	// Create the diskutil command for retrieving all disk and partition information
+	//   * -plist converts diskutil's output from human-readable to the plist format
+	cmdListDisks := []string{"diskutil", "list", "-plist"}
+
+	// Append arguments to the diskutil list verb
+	if len(args) > 0 {
+		cmdListDisks = append(cmdListDisks, args...)
+	}
+
+	// Execute the diskutil list command and store the output
+	cmdOut, err := d.executor().Execute(ctx, cmdListDisks)
+	if err != nil {
+		return cmdOut.Stdout, newCommandError(cmdOut.Stderr, fmt.Errorf("diskutil: failed to run diskutil command to list all disks, stderr: [%s]: %w", cmdOut.Stderr, err))
+	}
+
+	return cmdOut.Stdout, nil
+}
+
+// Info uses the macOS diskutil info command to get detailed information about a disk, partition, or container
+// format by passing the -plist arg.
+func (d *DiskUtilityCmd) Info(ctx context.Context, id string) (string, error) {
+	// Create the diskutil command for retrieving disk information given a device identifier
+	//   * -plist converts diskutil's output from human-readable to the plist format
+	//   * id - the device identifier for the disk to be fetched
+	cmdDiskInfo := []string{"diskutil", "info", "-plist", id}
+
+	// Execute the diskutil info command and store the output
+	cmdOut, err := d.executor().Execute(ctx, cmdDiskInfo)
+	if err != nil {
+		return cmdOut.Stdout, newCommandError(cmdOut.Stderr, fmt.Errorf("diskutil: failed to run diskutil command to fetch disk information, stderr: [%s]: %w", cmdOut.Stderr, err))
+	}
+
+	return cmdOut.Stdout, nil
+}
+
+// RepairDisk uses the macOS diskutil diskRepair command to repair the specified volume and get updated information
+// (e.g. amount of free space).
+func (d *DiskUtilityCmd) RepairDisk(ctx context.Context, id string) (string, error) {
+	// cmdRepairDisk represents the command used for executing macOS's diskutil to repair a disk.
+	// The repairDisk command requires interactive-input ("yes"/"no") but is automated with util.AnswerYes.
+	// Repairs can take a long time on large disks so the system is kept awake until they finish.
+	//   * repairDisk - indicates that a disk is going to be repaired (used to fetch amount of free space)
+	//   * id - the device identifier for the disk to be repaired
+	cmdRepairDisk := []string{"diskutil", "repairDisk", id}
+
+	// Execute the diskutil repairDisk command and store the output
+	start := time.Now()
+	report := startProgress(ctx, "Repairing "+id)
+	cmdOut, err := d.executor().Execute(ctx, cmdRepairDisk, util.AnswerYes(), util.PreventSleep(), report.option())
+	report.Done()
+	if err != nil {
+		return cmdOut.Stdout, diagnose(start, newCommandError(cmdOut.Stderr, fmt.Errorf("diskutil: failed to run repairDisk command, stderr: [%s]: %w", cmdOut.Stderr, err)))
+	}
+
+	return cmdOut.Stdout, nil
+}
+
+// ResetUserPermissions uses the macOS diskutil resetUserPermissions command to make the user with the UID the owner
+// of the files on the mounted volume, e.g. after restoring an image whose home directories belonged to another user.
+func (d *DiskUtilityCmd) ResetUserPermissions(ctx context.Context, id string, uid int) (string, error) {
+	// cmdResetUserPermissions represents the command used for executing macOS's diskutil to reset permissions.
+	// Resetting permissions walks every file on the volume so the system is kept awake until it finishes.
+	//   * resetUserPermissions - indicates that the ownership and permissions of the files are going to be reset
+	//   * id - the device identifier for the mounted volume
+	//   * uid - the UID of the user that becomes the owner of the files
+	cmdResetUserPermissions := []string{"diskutil", "resetUserPermissions", id, strconv.Itoa(uid)}
+
+	// Execute the diskutil resetUserPermissions command and store the output
+	start := time.Now()
+	report := startProgress(ctx, "Resetting permissions on "+id)
+	cmdOut, err := d.executor().Execute(ctx, cmdResetUserPermissions, util.PreventSleep(), report.option())
+	report.Done()
+	if err != nil {
+		return cmdOut.Stdout, diagnose(start, newCommandError(cmdOut.Stderr, fmt.Errorf("diskutil: failed to run diskutil command to reset user permissions, stderr [%s]: %w", cmdOut.Stderr, err)))
+	}
+
+	return cmdOut.Stdout, nil
+}
+
+// EraseDisk uses the macOS diskutil eraseDisk command to erase the whole disk for the specified device identifier
+// and create a single volume on it with the given format and name.
+func (d *DiskUtilityCmd) EraseDisk(ctx context.Context, format string, name string, id string) (string, error) {
+	// cmdEraseDisk represents the command used for executing macOS's diskutil to erase a disk.
+	//   * eraseDisk - indicates that a whole disk is going to be erased
+	//   * format - the personality of the new filesystem (e.g. "APFS", "JHFS+", "ExFAT", and "FAT32")
+	//   * name - the name (label) of the new volume
+	//   * scheme - the partition scheme for the disk, GPT unless the disk is formatted as FAT32 which uses MBR for
+	//     compatibility with systems that can't read GPT disks
+	//   * id - the device identifier for the disk to be erased
+	scheme := "GPT"
+	if strings.EqualFold(format, "FAT32") {
+		scheme = "MBR"
+	}
+	cmdEraseDisk := []string{"diskutil", "eraseDisk", format, name, scheme, id}
+
+	// Execute the diskutil eraseDisk command and store the output
+	start := time.Now()
+	cmdOut, err := d.executor().Execute(ctx, cmdEraseDisk)
+	if err != nil {
+		return cmdOut.Stdout, diagnose(start, newCommandError(cmdOut.Stderr, fmt.Errorf("diskutil: failed to run diskutil command to erase the disk, stderr [%s]: %w", cmdOut.Stderr, err)))
+	}
+
+	return cmdOut.Stdout, nil
+}
+
+// Mount uses the macOS diskutil mount command to mount the volume for the specified device identifier at the
+// given mount point.
+func (d *DiskUtilityCmd) Mount(ctx context.Context, id string, mountPoint string) (string, error) {
+	// cmdMount represents the command used for executing macOS's diskutil to mount a volume.
+	//   * mount - indicates that a volume is going to be mounted
+	//   * -mountPoint - mount the volume at the given path instead of the default under /Volumes
+	//   * id - the device identifier for the volume
+	cmdMount := []string{"diskutil", "mount", "-mountPoint", mountPoint, id}
+
+	// Execute the diskutil mount command and store the output
+	start := time.Now()
+	cmdOut, err := d.executor().Execute(ctx, cmdMount)
+	if err != nil {
+		return cmdOut.Stdout, diagnose(start, newCommandError(cmdOut.Stderr, fmt.Errorf("diskutil: failed to run diskutil command to mount the volume, stderr [%s]: %w", cmdOut.Stderr, err)))
+	}
+
+	return cmdOut.Stdout, nil
+}
+
+// NewFS uses the newfs tool for the format (e.g. newfs_apfs) to create a filesystem on the raw device of the whole
+// disk for the specified device identifier. Unlike diskutil eraseDisk, no partition map is written and diskutil's
+// prompts and disk arbitration round trips are skipped, which makes formatting large volumes faster.
+func (d *DiskUtilityCmd) NewFS(ctx context.Context, format string, name string, id string) (string, error) {
+	// The raw device (e.g. /dev/rdisk2) is used since it isn't buffered through the kernel's block cache.
+	device := "/dev/r" + strings.TrimPrefix(id, "/dev/")
+
+	// cmdNewFS represents the command used for executing the newfs tool to create a filesystem.
+	//   * newfs_apfs - create an APFS container with a single volume
+	//   * newfs_hfs -J - create a journaled HFS+ volume
+	//   * newfs_exfat - create an ExFAT volume
+	//   * newfs_msdos -F 32 - create an MS-DOS FAT32 volume
+	//   * -v <name> - the name (label) of the new volume
+	//   * device - the raw device for the disk to be formatted
+	var cmdNewFS []string
+	switch format {
+	case "APFS":
+		cmdNewFS = []string{"newfs_apfs", "-v", name, device}
+	case "JHFS+":
+		cmdNewFS = []string{"newfs_hfs", "-J", "-v", name, device}
+	case "ExFAT":
+		cmdNewFS = []string{"newfs_exfat", "-v", name, device}
+	case "FAT32":
+		cmdNewFS = []string{"newfs_msdos", "-F", "32", "-v", name, device}
+	default:
+		return "", &CommandError{Class: ClassUnsupported, Err: fmt.Errorf("diskutil: newfs does not support format %q", format)}
+	}
+
+	// Execute the newfs command and store the output
+	start := time.Now()
+	cmdOut, err := d.executor().Execute(ctx, cmdNewFS)
+	if err != nil {
+		return cmdOut.Stdout, diagnose(start, newCommandError(cmdOut.Stderr, fmt.Errorf("diskutil: failed to run %s to format the disk, stderr [%s]: %w", cmdNewFS[0], cmdOut.Stderr, err)))
+	}
+
+	return cmdOut.Stdout, nil
+}
+
+// Unmount uses the macOS diskutil unmount command to unmount the volume for the specified device identifier.
+func (d *DiskUtilityCmd) Unmount(ctx context.Context, id string) (string, error) {
+	// cmdUnmount represents the command used for executing macOS's diskutil to unmount a volume.
+	//   * unmount - indicates that a volume is going to be unmounted
+	//   * id - the device identifier for the volume
+	cmdUnmount := []string{"diskutil", "unmount", id}
+
+	// Execute the diskutil unmount command and store the output
+	start := time.Now()
+	cmdOut, err := d.executor().Execute(ctx, cmdUnmount)
+	if err != nil {
+		return cmdOut.Stdout, diagnose(start, newCommandError(cmdOut.Stderr, fmt.Errorf("diskutil: failed to run diskutil command to unmount the volume, stderr [%s]: %w", cmdOut.Stderr, err)))
+	}
+
+	return cmdOut.Stdout, nil
+}
+
+// UnmountDisk uses the macOS diskutil unmountDisk command to unmount all of the volumes on the whole disk for the
+// specified device identifier.
+func (d *DiskUtilityCmd) UnmountDisk(ctx context.Context, id string) (string, error) {
+	// cmdUnmountDisk represents the command used for executing macOS's diskutil to unmount a disk's volumes.
+	//   * unmountDisk - indicates that all of the disk's volumes are going to be unmounted
+	//   * id - the device identifier for the whole disk
+	cmdUnmountDisk := []string{"diskutil", "unmountDisk", id}
+
+	// Execute the diskutil unmountDisk command and store the output
+	start := time.Now()
+	cmdOut, err := d.executor().Execute(ctx, cmdUnmountDisk)
+	if err != nil {
+		return cmdOut.Stdout, diagnose(start, newCommandError(cmdOut.Stderr, fmt.Errorf("diskutil: failed to run diskutil command to unmount the disk, stderr [%s]: %w", cmdOut.Stderr, err)))
+	}
+
+	return cmdOut.Stdout, nil
+}
+
+// ResizeContainer uses the macOS diskutil apfs resizeContainer command to change the size of the specific container ID.
+func (d *DiskUtilityCmd) ResizeContainer(ctx context.Context, id string, size string) (string, error) {
+	// cmdResizeContainer represents the command used for executing macOS's diskutil to resize a container
+	//   * apfs - specifies that a virtual APFS volume is going to be modified
+	//   * resizeContainer - indicates that a container is going to be resized
+	//   * id - the device identifier for the container
+	//   * size - the size which can be in a human-readable format (e.g. "0", "110g", and "1.5t")
+	cmdResizeContainer := []string{"diskutil", "apfs", "resizeContainer", id, size}
+
+	// Execute the diskutil apfs resizeContainer command and store the output
+	start := time.Now()
+	report := startProgress(ctx, "Resizing "+id)
+	cmdOut, err := d.executor().Execute(ctx, cmdResizeContainer, report.option())
+	report.Done()
+	if err != nil {
+		return cmdOut.Stdout, diagnose(start, newCommandError(cmdOut.Stderr, fmt.Errorf("diskutil: failed to run diskutil command to resize the container, stderr [%s]: %w", cmdOut.Stderr, err)))
+	}
+
+	return cmdOut.Stdout, nil
+}
+
+// CreateContainer uses the macOS diskutil apfs createContainer command to create an empty APFS container on the
+// device. Whole disks are given a GUID partition map with a single APFS physical store.
+func (d *DiskUtilityCmd) CreateContainer(ctx context.Context, id string) (string, error) {
+	// cmdCreateContainer represents the command used for executing macOS's diskutil to create a container
+	//   * apfs - specifies that a virtual APFS volume is going to be modified
+	//   * createContainer - indicates that a container is going to be created, without any volumes
+	//   * id - the device identifier for the whole disk or partition that becomes the container's physical store
+	cmdCreateContainer := []string{"diskutil", "apfs", "createContainer", id}
+
+	// Execute the diskutil apfs createContainer command and store the output
+	start := time.Now()
+	cmdOut, err := d.executor().Execute(ctx, cmdCreateContainer)
+	if err != nil {
+		return cmdOut.Stdout, diagnose(start, newCommandError(cmdOut.Stderr, fmt.Errorf("diskutil: failed to run diskutil command to create the container, stderr [%s]: %w", cmdOut.Stderr, err)))
+	}
+
+	return cmdOut.Stdout, nil
+}
+
+// DeleteContainer uses the macOS diskutil apfs deleteContainer command to delete the container, which leaves its
+// physical stores as free space.
+func (d *DiskUtilityCmd) DeleteContainer(ctx context.Context, id string) (string, error) {
+	// cmdDeleteContainer represents the command used for executing macOS's diskutil to delete a container
+	//   * apfs - specifies that a virtual APFS volume is going to be modified
+	//   * deleteContainer - indicates that a container, and all of its volumes, are going to be deleted
+	//   * id - the device identifier for the container or one of its physical stores
+	cmdDeleteContainer := []string{"diskutil", "apfs", "deleteContainer", id}
+
+	// Execute the diskutil apfs deleteContainer command and store the output
+	start := time.Now()
+	cmdOut, err := d.executor().Execute(ctx, cmdDeleteContainer)
+	if err != nil {
+		return cmdOut.Stdout, diagnose(start, newCommandError(cmdOut.Stderr, fmt.Errorf("diskutil: failed to run diskutil command to delete the container, stderr [%s]: %w", cmdOut.Stderr, err)))
+	}
+
+	return cmdOut.Stdout, nil
+}
+
+// AddVolume uses the macOS diskutil apfs addVolume command to add a volume to the container. Encrypted volumes are
+// given their passphrase on stdin so that it doesn't appear in the command's arguments.
+func (d *DiskUtilityCmd) AddVolume(ctx context.Context, id string, format string, name string, passphrase string) (string, error) {
+	// cmdAddVolume represents the command used for executing macOS's diskutil to add a volume
+	//   * apfs - specifies that a virtual APFS volume is going to be modified
+	//   * addVolume - indicates that a volume is going to be added to the container
+	//   * id - the device identifier for the container
+	//   * format - the format of the volume (e.g. "APFS" or "Case-sensitive APFS")
+	//   * name - the name of the volume
+	//   * -nomount - leaves the volume unmounted so that it can be mounted where it's needed
+	//   * -stdinpassphrase - encrypts the volume with the passphrase read from stdin
+	cmdAddVolume := []string{"diskutil", "apfs", "addVolume", id, format, name, "-nomount"}
+	var opts []util.Option
+	if passphrase != "" {
+		cmdAddVolume = append(cmdAddVolume, "-stdinpassphrase")
+		opts = append(opts, util.Input(passphrase))
+	}
+
+	// Execute the diskutil apfs addVolume command and store the output
+	start := time.Now()
+	cmdOut, err := d.executor().Execute(ctx, cmdAddVolume, opts...)
+	if err != nil {
+		return cmdOut.Stdout, diagnose(start, newCommandError(cmdOut.Stderr, fmt.Errorf("diskutil: failed to run diskutil command to add the volume, stderr [%s]: %w", cmdOut.Stderr, err)))
+	}
+
+	return cmdOut.Stdout, nil
+}
+
+// UnlockVolume uses the macOS diskutil apfs unlockVolume command to unlock the encrypted volume, which also mounts
+// it. The passphrase is given on stdin so that it doesn't appear in the command's arguments.
+func (d *DiskUtilityCmd) UnlockVolume(ctx context.Context, id string, passphrase string) (string, error) {
+	// cmdUnlockVolume represents the command used for executing macOS's diskutil to unlock a volume
+	//   * apfs - specifies that a virtual APFS volume is going to be modified
+	//   * unlockVolume - indicates that an encrypted volume is going to be unlocked and mounted
+	//   * id - the device identifier for the volume
+	//   * -stdinpassphrase - unlocks the volume with the passphrase read from stdin
+	cmdUnlockVolume := []string{"diskutil", "apfs", "unlockVolume", id, "-stdinpassphrase"}
+
+	// Execute the diskutil apfs unlockVolume command and store the output
+	start := time.Now()
+	cmdOut, err := d.executor().Execute(ctx, cmdUnlockVolume, util.Input(passphrase))
+	if err != nil {
+		return cmdOut.Stdout, diagnose(start, newCommandError(cmdOut.Stderr, fmt.Errorf("diskutil: failed to run diskutil command to unlock the volume, stderr [%s]: %w", cmdOut.Stderr, err)))
+	}
+
+	return cmdOut.Stdout, nil
+}
+
+// commandProgress reports the progress of a long-running diskutil command from its output.
+type commandProgress struct {
+	progress.Reporter
+}
+
+// startProgress starts reporting the titled command's progress with the Starter provided in ctx.
+func startProgress(ctx context.Context, title string) commandProgress {
+	return commandProgress{Reporter: progress.FromContext(ctx)(title)}
+}
+
+// option streams the command's output to the Reporter.
+func (p commandProgress) option() util.Option {
+	return util.OnLine(func(line string) {
+		progress.Line(p.Reporter, line)
+	})
+}
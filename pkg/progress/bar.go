@@ -0,0 +1,127 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// barWidth is the number of characters between the brackets of a bar.
+	barWidth = 30
+	// maxStatusLength is the number of characters of the status that are drawn, longer statuses are truncated so
+	// that the bar's line doesn't wrap.
+	maxStatusLength = 60
+	// redrawInterval is the time between redraws of a bar, which animates its spinner.
+	redrawInterval = 100 * time.Millisecond
+)
+
+// spinnerFrames are drawn in turn while the percentage of an operation is unknown.
+var spinnerFrames = []string{"|", "/", "-", `\`}
+
+// bar is a Reporter that redraws the operation's progress in place on a terminal. A spinner is drawn until the
+// operation reports a percentage.
+type bar struct {
+	mu      sync.Mutex
+	w       io.Writer
+	title   string
+	status  string
+	percent float64
+	frame   int
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// newBar creates a bar which is drawn on w until it's done.
+func newBar(w io.Writer, title string) *bar {
+	b := &bar{w: w, title: title, percent: -1, stop: make(chan struct{})}
+	go b.animate()
+
+	return b
+}
+
+// Status redraws the bar with the status.
+func (b *bar) Status(message string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.status = message
+	b.draw()
+}
+
+// Percent redraws the bar with the percentage complete.
+func (b *bar) Percent(percent float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.percent = percent
+	b.draw()
+}
+
+// Done stops animating the bar and clears its line so that the output that follows isn't mixed with it.
+func (b *bar) Done() {
+	b.stopOnce.Do(func() {
+		close(b.stop)
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		fmt.Fprint(b.w, "\r\033[K")
+	})
+}
+
+// animate redraws the bar until it's done.
+func (b *bar) animate() {
+	ticker := time.NewTicker(redrawInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			b.mu.Lock()
+			b.frame++
+			b.draw()
+			b.mu.Unlock()
+		}
+	}
+}
+
+// draw replaces the terminal's current line with the bar. The caller must hold mu.
+func (b *bar) draw() {
+	select {
+	case <-b.stop:
+		return
+	default:
+	}
+
+	fmt.Fprint(b.w, "\r\033[K"+b.line())
+}
+
+// line renders the bar as it's drawn, without clearing the line. The caller must hold mu.
+func (b *bar) line() string {
+	var sb strings.Builder
+	if b.percent < 0 {
+		sb.WriteString(spinnerFrames[b.frame%len(spinnerFrames)])
+	} else {
+		filled := int(b.percent / 100 * barWidth)
+		if filled > barWidth {
+			filled = barWidth
+		}
+		fmt.Fprintf(&sb, "[%s%s] %3.0f%%", strings.Repeat("=", filled), strings.Repeat(" ", barWidth-filled), b.percent)
+	}
+
+	sb.WriteString(" " + b.title)
+	if b.status != "" {
+		status := b.status
+		if len(status) > maxStatusLength {
+			status = status[:maxStatusLength-3] + "..."
+		}
+		sb.WriteString(": " + status)
+	}
+
+	return sb.String()
+}
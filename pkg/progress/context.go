@@ -0,0 +1,23 @@
+package progress
+
+import "context"
+
+// starterKey is used to set and retrieve context held values for the Starter.
+type starterKey struct{}
+
+// NewContext extends the context to provide the Starter that operations run with it report their progress with.
+func NewContext(ctx context.Context, starter Starter) context.Context {
+	return context.WithValue(ctx, starterKey{}, starter)
+}
+
+// FromContext fetches the Starter provided in ctx. Discard is returned when none is set.
+func FromContext(ctx context.Context) Starter {
+	if val := ctx.Value(starterKey{}); val != nil {
+		if v, ok := val.(Starter); ok {
+			return v
+		}
+		panic("incoherent context")
+	}
+
+	return Discard
+}
@@ -0,0 +1,66 @@
+package progress
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// logStep is the percentage of an operation between log messages.
+	logStep = 10
+	// logInterval is the minimum time between log messages for status changes, so that tools which print many
+	// lines don't flood the logs.
+	logInterval = 10 * time.Second
+)
+
+// logger is a Reporter that logs each time the operation passes another logStep percent, and its status at most
+// once per logInterval.
+type logger struct {
+	mu     sync.Mutex
+	entry  *logrus.Entry
+	next   float64
+	logged time.Time
+	now    func() time.Time
+}
+
+// newLogger creates a logger for the titled operation.
+func newLogger(title string) *logger {
+	return &logger{
+		entry: logrus.WithField("operation", title),
+		next:  logStep,
+		now:   time.Now,
+	}
+}
+
+// Status logs the status when it's been logInterval since progress was last logged.
+func (l *logger) Status(message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if !l.logged.IsZero() && now.Sub(l.logged) < logInterval {
+		return
+	}
+	l.logged = now
+	l.entry.WithField("status", message).Info("Progress")
+}
+
+// Percent logs the percentage complete when it passes another logStep percent.
+func (l *logger) Percent(percent float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if percent < l.next {
+		return
+	}
+	for l.next <= percent {
+		l.next += logStep
+	}
+	l.logged = l.now()
+	l.entry.WithField("percent", int(percent)).Info("Progress")
+}
+
+// Done does nothing since the operation's caller logs its outcome.
+func (l *logger) Done() {}
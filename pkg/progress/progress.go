@@ -0,0 +1,69 @@
+// Package progress reports the progress of long-running operations (e.g. repairs, resizes, and restores) as bars and
+// spinners on interactive terminals, or as periodic log messages otherwise.
+package progress
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Reporter reports the progress of an operation.
+type Reporter interface {
+	// Status reports what the operation is currently doing (e.g. the phase that the tool printed).
+	Status(message string)
+	// Percent reports how much of the operation is complete.
+	Percent(percent float64)
+	// Done ends the report once the operation has finished or failed.
+	Done()
+}
+
+// Starter starts reporting the progress of the titled operation.
+type Starter func(title string) Reporter
+
+// Discard is a Starter whose Reporters do nothing.
+func Discard(title string) Reporter {
+	return discard{}
+}
+
+// discard is a Reporter that does nothing.
+type discard struct{}
+
+func (discard) Status(string)   {}
+func (discard) Percent(float64) {}
+func (discard) Done()           {}
+
+// New creates the Starter for f, which draws bars when f is an interactive terminal and logs otherwise.
+func New(f *os.File) Starter {
+	if isTerminal(f) {
+		return func(title string) Reporter {
+			return newBar(f, title)
+		}
+	}
+
+	return func(title string) Reporter {
+		return newLogger(title)
+	}
+}
+
+// percentPattern matches percentages in tools' output (e.g. "42%" or "42.5%").
+var percentPattern = regexp.MustCompile(`(\d{1,3}(?:\.\d+)?)%`)
+
+// Line reports a line of a tool's output to r. Lines with percentages (e.g. diskutil's "[ / 0%..10%..20%.. ]")
+// report the last one, other lines are reported as the status.
+func Line(r Reporter, line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	if matches := percentPattern.FindAllStringSubmatch(line, -1); matches != nil {
+		if percent, err := strconv.ParseFloat(matches[len(matches)-1][1], 64); err == nil && percent <= 100 {
+			r.Percent(percent)
+			return
+		}
+	}
+
+	r.Status(line)
+}
@@ -0,0 +1,85 @@
+package progress
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder is a Reporter which records what it's reported.
+type recorder struct {
+	statuses []string
+	percents []float64
+}
+
+func (r *recorder) Status(message string)   { r.statuses = append(r.statuses, message) }
+func (r *recorder) Percent(percent float64) { r.percents = append(r.percents, percent) }
+func (r *recorder) Done()                   {}
+
+func TestLine(t *testing.T) {
+	r := &recorder{}
+
+	Line(r, "Started partition map repair on disk0")
+	Line(r, "  ")
+	Line(r, "[ / 0%..10%..20%.. ]")
+	Line(r, "Growing APFS Physical Store disk0s2 from 107374182400 to 214748364800 bytes")
+	Line(r, "Copied 42.5%")
+
+	assert.Equal(t, []string{
+		"Started partition map repair on disk0",
+		"Growing APFS Physical Store disk0s2 from 107374182400 to 214748364800 bytes",
+	}, r.statuses)
+	assert.Equal(t, []float64{20, 42.5}, r.percents)
+}
+
+func TestBar_Line(t *testing.T) {
+	b := &bar{title: "Repairing disk0", percent: -1, frame: 1}
+	assert.Equal(t, "/ Repairing disk0", b.line())
+
+	b.status = "Checking prerequisites"
+	assert.Equal(t, "/ Repairing disk0: Checking prerequisites", b.line())
+
+	b.percent = 50
+	assert.Equal(t, "[===============               ]  50% Repairing disk0: Checking prerequisites", b.line())
+}
+
+func TestBar_Done(t *testing.T) {
+	var buf bytes.Buffer
+	// The bar isn't animated so that only what's reported is drawn
+	b := &bar{w: &buf, title: "Restoring disk3s1", percent: -1, stop: make(chan struct{})}
+
+	b.Percent(100)
+	b.Done()
+	b.Done()
+	b.Percent(100)
+
+	assert.Equal(t, "\r\033[K[==============================] 100% Restoring disk3s1\r\033[K", buf.String(),
+		"should clear the line once done and stop drawing")
+}
+
+func TestLogger(t *testing.T) {
+	log, hook := test.NewNullLogger()
+	now := time.Now()
+	l := newLogger("Restoring disk3s1")
+	l.entry = logrus.NewEntry(log)
+	l.now = func() time.Time { return now }
+
+	l.Percent(5)
+	l.Percent(12)
+	l.Percent(15)
+	l.Percent(38)
+	assert.Len(t, hook.AllEntries(), 2, "should log each time another step is passed")
+	assert.Equal(t, 38, hook.LastEntry().Data["percent"])
+
+	l.Status("Validating target")
+	assert.Len(t, hook.AllEntries(), 2, "shouldn't log the status soon after progress")
+
+	now = now.Add(logInterval)
+	l.Status("Copying")
+	assert.Len(t, hook.AllEntries(), 3)
+	assert.Equal(t, "Copying", hook.LastEntry().Data["status"])
+}
@@ -0,0 +1,13 @@
+package progress
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// isTerminal checks if f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	_, err := unix.IoctlGetTermios(int(f.Fd()), unix.TIOCGETA)
+	return err == nil
+}
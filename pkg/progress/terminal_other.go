@@ -0,0 +1,10 @@
+//go:build !darwin
+
+package progress
+
+import "os"
+
+// isTerminal isn't supported outside of macOS, progress is always logged instead.
+func isTerminal(f *os.File) bool {
+	return false
+}
@@ -0,0 +1,19 @@
+// Package remote provides util.Executors which run commands on other Macs so that a central controller can manage a
+// fleet of EC2 Mac instances with the same DiskUtil and grow APIs used on the instances themselves:
+//
+//	d, err := diskutil.ForProduct(product, diskutil.WithExecutor(&remote.SSHExecutor{Host: "mac1.example.com", Sudo: true}))
+//
+// The product must be the remote host's, not the controller's.
+package remote
+
+import "fmt"
+
+// ExitError is returned when a remote command exited with a non-zero status.
+type ExitError struct {
+	// Code is the command's exit status.
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("remote command exit status %d", e.Code)
+}
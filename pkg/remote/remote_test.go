@@ -0,0 +1,175 @@
+package remote
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+func TestSSHExecutor_Command(t *testing.T) {
+	s := &SSHExecutor{Host: "mac1.example.com", User: "ec2-user", Port: 2222, IdentityFile: "/keys/fleet", Options: []string{"StrictHostKeyChecking=accept-new"}}
+
+	c := s.command([]string{"diskutil", "info", "-plist", "/"}, nil)
+
+	assert.Equal(t, []string{
+		sshPath, "-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=accept-new", "-p", "2222", "-l", "ec2-user", "-i", "/keys/fleet",
+		"mac1.example.com", "--", "diskutil info -plist /",
+	}, c)
+}
+
+func TestSSHExecutor_CommandSudo(t *testing.T) {
+	s := &SSHExecutor{Host: "mac1.example.com", Sudo: true}
+
+	c := s.command([]string{"diskutil", "repairDisk", "disk0"}, []util.Option{util.AnswerYes()})
+
+	assert.Equal(t, []string{sshPath, "-o", "BatchMode=yes", "mac1.example.com", "--", `sudo -n sh -c '/usr/bin/yes | diskutil repairDisk disk0'`}, c)
+}
+
+func TestSSHExecutor_CommandInput(t *testing.T) {
+	s := &SSHExecutor{Host: "mac1.example.com", Sudo: true}
+
+	c := s.command([]string{"diskutil", "apfs", "unlockVolume", "disk3s1", "-stdinpassphrase"}, []util.Option{util.Input("secret passphrase")})
+
+	assert.Equal(t, []string{sshPath, "-o", "BatchMode=yes", "mac1.example.com", "--", `sudo -n sh -c 'diskutil apfs unlockVolume disk3s1 -stdinpassphrase'`}, c)
+	for _, arg := range c {
+		assert.NotContains(t, arg, "secret passphrase", "input should only be written to stdin")
+	}
+}
+
+// encodeOutput encodes the output like the script from ssmScript.
+func encodeOutput(t *testing.T, out string) string {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(out))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()) + "\n"
+}
+
+// newTestSSMExecutor creates an SSMExecutor which sends its requests to the handler.
+func newTestSSMExecutor(t *testing.T, handler http.HandlerFunc) *SSMExecutor {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := aws.NewClient("us-east-1", nil)
+	client.Credentials = aws.EnvProvider{}
+	client.HTTPClient = server.Client()
+	client.Endpoint = server.URL
+
+	return &SSMExecutor{InstanceID: "i-0123456789abcdef0", PollInterval: time.Millisecond, Client: ssmClient{client}}
+}
+
+func TestSSMExecutor_Execute(t *testing.T) {
+	polls := 0
+	s := newTestSSMExecutor(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X-Amz-Target") {
+		case "AmazonSSM.SendCommand":
+			var in struct {
+				Parameters map[string][]string
+			}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+			assert.Equal(t, []string{ssmScript("diskutil list -plist")}, in.Parameters["commands"])
+			assert.Equal(t, []string{"3600"}, in.Parameters["executionTimeout"])
+			w.Write([]byte(`{"Command":{"CommandId":"cmd-1"}}`))
+		case "AmazonSSM.GetCommandInvocation":
+			polls++
+			switch polls {
+			case 1:
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type":"InvocationDoesNotExist"}`))
+			case 2:
+				w.Write([]byte(`{"Status":"InProgress","ResponseCode":-1}`))
+			default:
+				out, _ := json.Marshal(map[string]interface{}{"Status": "Success", "ResponseCode": 0, "StandardOutputContent": encodeOutput(t, "<plist/>\n")})
+				w.Write(out)
+			}
+		}
+	})
+
+	out, err := s.Execute(context.Background(), []string{"diskutil", "list", "-plist"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "<plist/>\n", out.Stdout)
+	assert.Equal(t, 3, polls)
+}
+
+func TestSSMExecutor_ExecuteExitStatus(t *testing.T) {
+	s := newTestSSMExecutor(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X-Amz-Target") {
+		case "AmazonSSM.SendCommand":
+			w.Write([]byte(`{"Command":{"CommandId":"cmd-1"}}`))
+		case "AmazonSSM.GetCommandInvocation":
+			out, _ := json.Marshal(map[string]interface{}{"Status": "Failed", "ResponseCode": 1, "StandardOutputContent": encodeOutput(t, ""), "StandardErrorContent": "Could not find disk: disk9"})
+			w.Write(out)
+		}
+	})
+
+	out, err := s.Execute(context.Background(), []string{"diskutil", "info", "-plist", "disk9"})
+
+	var exitErr *ExitError
+	assert.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, 1, exitErr.Code)
+	assert.Equal(t, "Could not find disk: disk9", out.Stderr)
+}
+
+func TestSSMExecutor_ExecuteTimedOut(t *testing.T) {
+	s := newTestSSMExecutor(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("X-Amz-Target") {
+		case "AmazonSSM.SendCommand":
+			w.Write([]byte(`{"Command":{"CommandId":"cmd-1"}}`))
+		case "AmazonSSM.GetCommandInvocation":
+			w.Write([]byte(`{"Status":"TimedOut","ResponseCode":-1}`))
+		}
+	})
+
+	_, err := s.Execute(context.Background(), []string{"diskutil", "repairDisk", "disk0"})
+
+	assert.EqualError(t, err, "remote: command cmd-1 on i-0123456789abcdef0 didn't finish: TimedOut")
+}
+
+func TestSSMScript(t *testing.T) {
+	for _, tool := range []string{"/bin/sh", "/usr/bin/gzip", "/usr/bin/base64"} {
+		if _, err := os.Stat(tool); err != nil {
+			t.Skipf("%s is not available", tool)
+		}
+	}
+
+	cmd := exec.Command("/bin/sh", "-c", ssmScript(util.ShellCommand([]string{"sh", "-c", "echo 'it works'; exit 3"})))
+	encoded, err := cmd.Output()
+
+	var exitErr *exec.ExitError
+	assert.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, 3, exitErr.ExitCode())
+	out, err := decodeOutput(string(encoded))
+	assert.NoError(t, err)
+	assert.Equal(t, "it works\n", out)
+}
+
+func TestSSMExecutor_ExecuteInput(t *testing.T) {
+	s := newTestSSMExecutor(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("commands with input shouldn't be sent: %s", r.Header.Get("X-Amz-Target"))
+	})
+
+	_, err := s.Execute(context.Background(), []string{"diskutil", "apfs", "unlockVolume", "disk3s1", "-stdinpassphrase"}, util.Input("secret passphrase"))
+
+	assert.True(t, errors.Is(err, ErrInputUnsupported))
+}
@@ -0,0 +1,89 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// sshPath is the path to the OpenSSH client.
+	sshPath = "/usr/bin/ssh"
+	// sshConnectionFailed is the exit status of ssh when it couldn't connect to the host.
+	sshConnectionFailed = 255
+)
+
+// SSHExecutor runs commands on a remote host with the OpenSSH client. Authentication is left to ssh so keys, agents
+// and ssh_config(5) are used as usual, but ssh is run in batch mode so it never prompts.
+type SSHExecutor struct {
+	// Host is the host to connect to.
+	Host string
+	// User is the user to log in as, ssh's default is used when it's empty.
+	User string
+	// Port is the port to connect to, ssh's default is used when it's 0.
+	Port int
+	// IdentityFile is the private key to authenticate with, ssh's defaults are used when it's empty.
+	IdentityFile string
+	// Sudo runs the commands with sudo(8), which must not require a password.
+	Sudo bool
+	// Options are additional ssh options (e.g. "StrictHostKeyChecking=accept-new").
+	Options []string
+}
+
+// Execute runs the command on the host. Input is written to ssh's stdin, which the remote command reads, so that it's
+// kept out of the command lines of both hosts.
+func (s *SSHExecutor) Execute(ctx context.Context, c []string, opts ...util.Option) (util.CommandOutput, error) {
+	var stdin io.ReadCloser
+	if input, ok := util.InputOf(opts...); ok {
+		stdin = io.NopCloser(strings.NewReader(input + "\n"))
+	}
+	out, err := util.ExecuteCommand(ctx, s.command(c, opts), "", nil, stdin)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.ExitCode() == sshConnectionFailed {
+			return out, fmt.Errorf("remote: cannot run command on %s: %w", s.Host, err)
+		}
+
+		return out, &ExitError{Code: exitErr.ExitCode()}
+	}
+
+	return out, err
+}
+
+// command creates the ssh command which runs the command on the host.
+func (s *SSHExecutor) command(c []string, opts []util.Option) []string {
+	// cmdSSH represents the command used for executing the command on the host with ssh.
+	//   * -o BatchMode=yes - fail instead of prompting for passwords or passphrases
+	//   * -p port - the port to connect to
+	//   * -l user - the user to log in as
+	//   * -i identity - the private key to authenticate with
+	//   * host - the host to connect to
+	//   * -- - the end of ssh's options, followed by the command line run by the remote shell
+	cmdSSH := []string{sshPath, "-o", "BatchMode=yes"}
+	for _, o := range s.Options {
+		cmdSSH = append(cmdSSH, "-o", o)
+	}
+	if s.Port != 0 {
+		cmdSSH = append(cmdSSH, "-p", strconv.Itoa(s.Port))
+	}
+	if s.User != "" {
+		cmdSSH = append(cmdSSH, "-l", s.User)
+	}
+	if s.IdentityFile != "" {
+		cmdSSH = append(cmdSSH, "-i", s.IdentityFile)
+	}
+
+	line := util.ShellCommand(c, opts...)
+	if s.Sudo {
+		line = "sudo -n sh -c " + util.ShellCommand([]string{line})
+	}
+
+	return append(cmdSSH, s.Host, "--", line)
+}
@@ -0,0 +1,193 @@
+package remote
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/internal/aws"
+	"github.com/aws/ec2-macos-utils/internal/imds"
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// ssmDefaultPollInterval is the default time waited between checks of whether a command has finished.
+	ssmDefaultPollInterval = 2 * time.Second
+	// ssmDefaultTimeout is the default time after which Systems Manager cancels a command.
+	ssmDefaultTimeout = time.Hour
+)
+
+var (
+	// ErrInputUnsupported is returned when a command is given input (see util.Input), which can't be sent with Run
+	// Command without keeping it in the command's parameters and Systems Manager's command history.
+	ErrInputUnsupported = errors.New("remote: commands with input can't be run with Systems Manager")
+	// ErrInvocationNotFound is returned by SSMClients when the command's invocation doesn't exist, which it
+	// doesn't until shortly after the command is sent.
+	ErrInvocationNotFound = errors.New("remote: command invocation not found")
+)
+
+// CommandInvocation is the status and output of a command sent to an instance with Run Command.
+type CommandInvocation struct {
+	// Status is the status of the command (e.g. "InProgress", "Success", or "Failed").
+	Status string
+	// ResponseCode is the command's exit status, which is -1 until it finishes.
+	ResponseCode int
+	// StandardOutputContent is the first 24,000 characters of the command's standard output.
+	StandardOutputContent string
+	// StandardErrorContent is the first 8,000 characters of the command's standard error.
+	StandardErrorContent string
+}
+
+// SSMClient sends commands to instances with Systems Manager's Run Command, so that SSMExecutors can use any AWS
+// client (e.g. the AWS SDK's).
+type SSMClient interface {
+	// SendCommand runs the shell commands on the instance with the AWS-RunShellScript document and returns the ID
+	// of the command. The command is cancelled when it hasn't finished after timeoutSeconds.
+	SendCommand(ctx context.Context, instanceID string, commands []string, timeoutSeconds int) (string, error)
+	// GetCommandInvocation fetches the status and output of the command sent to the instance.
+	// ErrInvocationNotFound is returned when the invocation doesn't exist yet.
+	GetCommandInvocation(ctx context.Context, commandID string, instanceID string) (*CommandInvocation, error)
+}
+
+// SSMExecutor runs commands on an instance with AWS Systems Manager's Run Command, as root. The instance must be
+// managed by Systems Manager and the controller's credentials must allow ssm:SendCommand and
+// ssm:GetCommandInvocation.
+//
+// Systems Manager only returns the first 24,000 characters of a command's output. Since diskutil's plist output can
+// be longer, the output is compressed and encoded on the instance and decoded by Execute.
+type SSMExecutor struct {
+	// InstanceID is the ID of the instance to run the commands on.
+	InstanceID string
+	// PollInterval is the time waited between checks of whether a command has finished, the default is used when
+	// it's 0.
+	PollInterval time.Duration
+	// Timeout is the time after which Systems Manager cancels a command, the default is used when it's 0.
+	Timeout time.Duration
+	// Client sends the commands.
+	Client SSMClient
+}
+
+// NewSSMExecutor creates an SSMExecutor for the instance in the region, using the credentials from the
+// environment or the controller's instance profile.
+func NewSSMExecutor(region string, instanceID string) *SSMExecutor {
+	return &SSMExecutor{
+		InstanceID:   instanceID,
+		PollInterval: ssmDefaultPollInterval,
+		Timeout:      ssmDefaultTimeout,
+		Client:       ssmClient{aws.NewClient(region, imds.NewClient())},
+	}
+}
+
+// Execute runs the command on the instance and waits for it to finish. Commands that are still running when ctx is
+// done are left to finish or be cancelled by Systems Manager's timeout. Commands with input aren't run, see
+// ErrInputUnsupported.
+func (s *SSMExecutor) Execute(ctx context.Context, c []string, opts ...util.Option) (util.CommandOutput, error) {
+	if _, ok := util.InputOf(opts...); ok {
+		return util.CommandOutput{}, ErrInputUnsupported
+	}
+
+	timeout, interval := s.Timeout, s.PollInterval
+	if timeout <= 0 {
+		timeout = ssmDefaultTimeout
+	}
+	if interval <= 0 {
+		interval = ssmDefaultPollInterval
+	}
+
+	id, err := s.Client.SendCommand(ctx, s.InstanceID, []string{ssmScript(util.ShellCommand(c, opts...))}, int(timeout.Seconds()))
+	if err != nil {
+		return util.CommandOutput{}, fmt.Errorf("remote: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return util.CommandOutput{}, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		inv, err := s.Client.GetCommandInvocation(ctx, id, s.InstanceID)
+		if errors.Is(err, ErrInvocationNotFound) {
+			// Invocations can't be fetched immediately after the command is sent.
+			continue
+		}
+		if err != nil {
+			return util.CommandOutput{}, fmt.Errorf("remote: %w", err)
+		}
+
+		switch inv.Status {
+		case "Pending", "InProgress", "Delayed", "Cancelling":
+			continue
+		case "Success", "Failed":
+			// Commands that exit with a non-zero status fail, which is also reported with a ResponseCode of -1
+			// when the command couldn't be run.
+			out := util.CommandOutput{Stderr: inv.StandardErrorContent}
+			if inv.Status == "Failed" && inv.ResponseCode <= 0 {
+				return out, fmt.Errorf("remote: command %s failed on %s: %s", id, s.InstanceID, inv.StandardErrorContent)
+			}
+			out.Stdout, err = decodeOutput(inv.StandardOutputContent)
+			if err != nil {
+				return out, fmt.Errorf("remote: cannot decode output of command %s: %w", id, err)
+			}
+			if inv.ResponseCode != 0 {
+				return out, &ExitError{Code: inv.ResponseCode}
+			}
+
+			return out, nil
+		default:
+			return util.CommandOutput{Stderr: inv.StandardErrorContent}, fmt.Errorf("remote: command %s on %s didn't finish: %s", id, s.InstanceID, inv.Status)
+		}
+	}
+}
+
+// ssmClient is the SSMClient for the utility's own AWS client.
+type ssmClient struct {
+	*aws.Client
+}
+
+func (c ssmClient) GetCommandInvocation(ctx context.Context, commandID string, instanceID string) (*CommandInvocation, error) {
+	inv, err := c.Client.GetCommandInvocation(ctx, commandID, instanceID)
+	var apiErr *aws.APIError
+	if errors.As(err, &apiErr) && strings.HasSuffix(apiErr.Code, "InvocationDoesNotExist") {
+		return nil, fmt.Errorf("%w: %v", ErrInvocationNotFound, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return (*CommandInvocation)(inv), nil
+}
+
+// ssmScript creates the script which runs the command line and prints its output compressed and encoded, exiting
+// with the command's exit status.
+func ssmScript(line string) string {
+	return fmt.Sprintf(`out=$(mktemp) && { %s > "$out"; rc=$?; /usr/bin/gzip -c "$out" | /usr/bin/base64; rm -f "$out"; exit $rc; }`, line)
+}
+
+// decodeOutput decodes the output printed by the script from ssmScript.
+func decodeOutput(encoded string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+	if err != nil {
+		return "", err
+	}
+	if len(data) == 0 {
+		return "", nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if _, err := io.Copy(&out, r); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
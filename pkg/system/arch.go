@@ -0,0 +1,28 @@
+package system
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+// AppleSilicon checks if the system has an Apple silicon (arm64) processor, as mac2 instances do. The processor
+// is detected with sysctl rather than the utility's own architecture since Intel binaries can run under Rosetta.
+func AppleSilicon(ctx context.Context) (bool, error) {
+	// cmdArm64 represents the command used for executing macOS's sysctl to check for an arm64 processor.
+	//   * -n - only print the value
+	//   * hw.optional.arm64 - set to 1 on Apple silicon, and missing on older Intel releases
+	cmdArm64 := []string{"sysctl", "-n", "hw.optional.arm64"}
+
+	out, err := util.ExecuteCommand(ctx, cmdArm64, "", nil, nil)
+	if err != nil {
+		if strings.Contains(out.Stderr, "unknown oid") {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return strings.TrimSpace(out.Stdout) == "1", nil
+}
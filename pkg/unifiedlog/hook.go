@@ -0,0 +1,61 @@
+package unifiedlog
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Subsystem is the unified logging subsystem of the utility's own logs, which can be followed with:
+//
+//	log stream --predicate 'subsystem == "com.amazon.ec2.macos-utils"'
+const Subsystem = "com.amazon.ec2.macos-utils"
+
+// ErrUnsupported is returned when the unified log can't be written to, which requires macOS and cgo.
+var ErrUnsupported = errors.New("unifiedlog: writing to the unified log is not supported in this build")
+
+// logType mirrors os_log_type_t, the level of unified log entries.
+type logType uint8
+
+const (
+	typeDefault logType = 0x00
+	typeInfo    logType = 0x01
+	typeDebug   logType = 0x02
+	typeError   logType = 0x10
+	typeFault   logType = 0x11
+)
+
+// typeForLevel maps logrus levels to unified log types. Info and warning entries are logged with the default type
+// since the unified log only keeps info entries in memory.
+func typeForLevel(level logrus.Level) logType {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return typeFault
+	case logrus.ErrorLevel:
+		return typeError
+	case logrus.WarnLevel, logrus.InfoLevel:
+		return typeDefault
+	case logrus.DebugLevel:
+		return typeInfo
+	default:
+		return typeDebug
+	}
+}
+
+// hookFormatter formats entries for the unified log, which records the time, level, and process itself.
+var hookFormatter = &logrus.TextFormatter{
+	DisableColors:    true,
+	DisableTimestamp: true,
+	DisableQuote:     true,
+}
+
+// formatEntry formats the entry's message and fields as a single line.
+func formatEntry(e *logrus.Entry) (string, error) {
+	b, err := hookFormatter.Format(e)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
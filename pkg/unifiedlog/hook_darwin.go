@@ -0,0 +1,52 @@
+//go:build darwin && cgo
+
+package unifiedlog
+
+/*
+#include <stdlib.h>
+#include <os/log.h>
+
+// ec2_os_log logs the message as a public string, os_log requires its format to be a string literal.
+static void ec2_os_log(os_log_t log, os_log_type_t type, const char *msg) {
+	os_log_with_type(log, type, "%{public}s", msg);
+}
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Hook is a logrus hook which writes entries to the unified log with os_log(3).
+type Hook struct {
+	log C.os_log_t
+}
+
+// NewHook creates a Hook that logs entries with the subsystem and category.
+func NewHook(subsystem string, category string) (*Hook, error) {
+	// The strings are kept for the life of the process since the log object refers to them.
+	log := C.os_log_create(C.CString(subsystem), C.CString(category))
+
+	return &Hook{log: log}, nil
+}
+
+// Levels gets the levels that are written to the unified log.
+func (h *Hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire writes the entry to the unified log.
+func (h *Hook) Fire(e *logrus.Entry) error {
+	msg, err := formatEntry(e)
+	if err != nil {
+		return err
+	}
+
+	cmsg := C.CString(msg)
+	defer C.free(unsafe.Pointer(cmsg))
+	C.ec2_os_log(h.log, C.os_log_type_t(typeForLevel(e.Level)), cmsg)
+
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build !darwin || !cgo
+
+package unifiedlog
+
+import "github.com/sirupsen/logrus"
+
+// Hook is a logrus hook which writes entries to the unified log. The unified log can only be written to on macOS
+// with cgo enabled.
+type Hook struct{}
+
+// NewHook always fails with ErrUnsupported since the unified log can't be written to in this build.
+func NewHook(subsystem string, category string) (*Hook, error) {
+	return nil, ErrUnsupported
+}
+
+// Levels gets the levels that are written to the unified log.
+func (h *Hook) Levels() []logrus.Level {
+	return nil
+}
+
+// Fire does nothing since the unified log can't be written to in this build.
+func (h *Hook) Fire(e *logrus.Entry) error {
+	return nil
+}
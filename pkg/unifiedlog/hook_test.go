@@ -0,0 +1,38 @@
+package unifiedlog
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypeForLevel(t *testing.T) {
+	tests := []struct {
+		level logrus.Level
+		want  logType
+	}{
+		{level: logrus.FatalLevel, want: typeFault},
+		{level: logrus.ErrorLevel, want: typeError},
+		{level: logrus.WarnLevel, want: typeDefault},
+		{level: logrus.InfoLevel, want: typeDefault},
+		{level: logrus.DebugLevel, want: typeInfo},
+		{level: logrus.TraceLevel, want: typeDebug},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			assert.Equal(t, tt.want, typeForLevel(tt.level))
+		})
+	}
+}
+
+func TestFormatEntry(t *testing.T) {
+	e := logrus.NewEntry(logrus.New()).WithField("device_id", "disk2")
+	e.Level = logrus.InfoLevel
+	e.Message = "Formatting device..."
+
+	got, err := formatEntry(e)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "level=info msg=Formatting device... device_id=disk2", got)
+}
@@ -0,0 +1,109 @@
+// Package unifiedlog provides the functionality necessary for reading macOS's unified log with the log(1) CLI and for
+// writing the utility's logs to it.
+package unifiedlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/ec2-macos-utils/pkg/util"
+)
+
+const (
+	// LogPath is the path to macOS's log tool.
+	LogPath = "/usr/bin/log"
+
+	// timeFormat is the local time format accepted by log show's --start and --end flags.
+	timeFormat = "2006-01-02 15:04:05"
+)
+
+// Entry is a single entry in the unified log.
+type Entry struct {
+	// Timestamp is when the entry was logged (e.g. "2023-06-01 12:00:00.000000+0000").
+	Timestamp string `json:"timestamp"`
+	// Type is the level of the entry (e.g. Default, Info, Error, Fault).
+	Type string `json:"messageType"`
+	// Subsystem is the subsystem that logged the entry (e.g. com.apple.DiskManagement).
+	Subsystem string `json:"subsystem"`
+	// Category is the subsystem's category for the entry.
+	Category string `json:"category"`
+	// ProcessImagePath is the path to the executable of the process that logged the entry.
+	ProcessImagePath string `json:"processImagePath"`
+	// Message is the text of the entry.
+	Message string `json:"eventMessage"`
+}
+
+// String formats the entry like log show's default style.
+func (e Entry) String() string {
+	source := filepath.Base(e.ProcessImagePath)
+	if e.Subsystem != "" {
+		source += " [" + e.Subsystem
+		if e.Category != "" {
+			source += ":" + e.Category
+		}
+		source += "]"
+	}
+
+	return fmt.Sprintf("%s %s %s", e.Timestamp, source, e.Message)
+}
+
+// Query selects the entries read by Show.
+type Query struct {
+	// Predicate is the NSPredicate filtering entries (e.g. `subsystem == "com.apple.apfs"`).
+	Predicate string
+	// Start is the time of the first entry, which must be set.
+	Start time.Time
+	// End is the time of the last entry, the end of the log is used when zero.
+	End time.Time
+}
+
+// Show reads the entries matching the query from the unified log. Info and debug entries are included since they
+// often hold the detail that explains a failure.
+func Show(ctx context.Context, q Query) ([]Entry, error) {
+	// cmdShow represents the command used for executing macOS's log to read the unified log.
+	//   * show - read entries from the log
+	//   * --style ndjson - print each entry as a line of JSON
+	//   * --info, --debug - include info and debug entries
+	//   * --start <time> - the time of the first entry
+	//   * --end <time> - the time of the last entry
+	//   * --predicate <predicate> - filter the entries
+	cmdShow := []string{LogPath, "show", "--style", "ndjson", "--info", "--debug", "--start", q.Start.Format(timeFormat)}
+	if !q.End.IsZero() {
+		cmdShow = append(cmdShow, "--end", q.End.Format(timeFormat))
+	}
+	if q.Predicate != "" {
+		cmdShow = append(cmdShow, "--predicate", q.Predicate)
+	}
+
+	out, err := util.ExecuteCommand(ctx, cmdShow, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unifiedlog: failed to read log, stderr: [%s]: %w", strings.TrimSpace(out.Stderr), err)
+	}
+
+	return parseEntries(out.Stdout), nil
+}
+
+// parseEntries parses the entries printed by log show in the ndjson style. Lines that aren't entries (e.g. the
+// header and the trailing summary) are skipped.
+func parseEntries(out string) []Entry {
+	var entries []Entry
+	for _, line := range bytes.Split([]byte(out), []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil || e.Timestamp == "" {
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	return entries
+}
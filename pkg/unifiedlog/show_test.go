@@ -0,0 +1,41 @@
+package unifiedlog
+
+import (
+	_ "embed"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// showOutput contains the ndjson output of log show for a failed container resize.
+//
+//go:embed testdata/show.ndjson
+var showOutput string
+
+func TestParseEntries(t *testing.T) {
+	expected := []Entry{
+		{
+			Timestamp:        "2023-06-01 12:00:01.123456+0000",
+			Type:             "Default",
+			Subsystem:        "com.apple.DiskManagement",
+			Category:         "default",
+			ProcessImagePath: "/usr/sbin/diskutil",
+			Message:          "DMAPFS: Resize container disk1 to 0 requested",
+		},
+		{
+			Timestamp:        "2023-06-01 12:00:02.000001+0000",
+			Type:             "Error",
+			ProcessImagePath: "/kernel",
+			Message:          "apfs_container_resize:3718: disk1 resize failed: No space left on device (28)",
+		},
+	}
+
+	assert.Equal(t, expected, parseEntries(showOutput))
+}
+
+func TestEntry_String(t *testing.T) {
+	entries := parseEntries(showOutput)
+
+	assert.Equal(t, "2023-06-01 12:00:01.123456+0000 diskutil [com.apple.DiskManagement:default] DMAPFS: Resize container disk1 to 0 requested", entries[0].String())
+	assert.Equal(t, "2023-06-01 12:00:02.000001+0000 kernel apfs_container_resize:3718: disk1 resize failed: No space left on device (28)", entries[1].String())
+}
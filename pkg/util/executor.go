@@ -0,0 +1,67 @@
+package util
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// Executor runs commands on behalf of the wrappers of macOS's tools so that the commands can be recorded or
+// replayed (e.g. in tests) instead of always being run on the system.
+type Executor interface {
+	// Execute runs the command as the current user and returns its output.
+	Execute(ctx context.Context, c []string, opts ...Option) (CommandOutput, error)
+}
+
+// SystemExecutor is the Executor that runs commands on the system.
+type SystemExecutor struct{}
+
+// Execute runs the command with ExecuteCommand, or with ExecuteCommandYes when AnswerYes is given.
+func (SystemExecutor) Execute(ctx context.Context, c []string, opts ...Option) (CommandOutput, error) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.answerYes {
+		return ExecuteCommandYes(ctx, c, "", nil, opts...)
+	}
+
+	return ExecuteCommand(ctx, c, "", nil, nil, opts...)
+}
+
+// shellSafe matches arguments that don't need to be quoted for the shell.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// ShellCommand renders the command, with the options applied, as a line for sh(1) so that Executors can run it on
+// other hosts (e.g. over SSH). AnswerYes pipes yes(1) into the command and PreventSleep wraps it with caffeinate(8).
+// Input is never rendered since the line can be seen in process lists and command histories, Executors must write it
+// to the command's stdin (see InputOf) or refuse to run the command.
+func ShellCommand(c []string, opts ...Option) string {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.preventSleep {
+		c = append([]string{caffeinatePath, "-i", "-m"}, c...)
+	}
+
+	quoted := make([]string, len(c))
+	for i, arg := range c {
+		quoted[i] = shellQuote(arg)
+	}
+	line := strings.Join(quoted, " ")
+	if o.answerYes {
+		line = "/usr/bin/yes | " + line
+	}
+
+	return line
+}
+
+// shellQuote quotes the argument for the shell when it contains characters the shell would interpret.
+func shellQuote(arg string) string {
+	if shellSafe.MatchString(arg) {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
@@ -0,0 +1,34 @@
+//go:build !unix
+
+package util
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// setProcessGroup does nothing since process groups aren't supported outside of Unix systems, only the command
+// itself is stopped when its context is done.
+func setProcessGroup(cmd *exec.Cmd, newSession bool) {}
+
+// setCredential isn't supported outside of Unix systems, commands can only be run as the current user.
+func setCredential(cmd *exec.Cmd, uid, gid int) error {
+	return fmt.Errorf("running commands as another user isn't supported on %s", runtime.GOOS)
+}
+
+// terminateGroup kills the group's leader, which is the only process that can be signalled.
+func terminateGroup(pgid int) error {
+	return killGroup(pgid)
+}
+
+// killGroup kills the group's leader, which is the only process that can be signalled.
+func killGroup(pgid int) error {
+	p, err := os.FindProcess(pgid)
+	if err != nil {
+		return err
+	}
+
+	return p.Kill()
+}
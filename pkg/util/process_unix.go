@@ -0,0 +1,35 @@
+//go:build unix
+
+package util
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the command as the leader of its own process group. With newSession, the command leads its
+// own session as well, which detaches it from the controlling terminal.
+func setProcessGroup(cmd *exec.Cmd, newSession bool) {
+	if newSession {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+		return
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// setCredential runs the command as the user and group IDs.
+func setCredential(cmd *exec.Cmd, uid, gid int) error {
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+
+	return nil
+}
+
+// terminateGroup sends SIGTERM to the process group.
+func terminateGroup(pgid int) error {
+	return syscall.Kill(-pgid, syscall.SIGTERM)
+}
+
+// killGroup sends SIGKILL to the process group.
+func killGroup(pgid int) error {
+	return syscall.Kill(-pgid, syscall.SIGKILL)
+}
@@ -0,0 +1,406 @@
+// Package util provides the functionality necessary for running the commands that wrap macOS's tools.
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// caffeinatePath is the path to macOS's caffeinate tool.
+const caffeinatePath = "/usr/bin/caffeinate"
+
+// terminateGracePeriod is how long a command's process group is given to exit after SIGTERM before it's killed.
+const terminateGracePeriod = 5 * time.Second
+
+// Option configures how a command is executed.
+type Option func(o *options)
+
+// options holds the configuration set by Options.
+type options struct {
+	preventSleep bool
+	answerYes    bool
+	onLine       func(line string)
+	input        *string
+}
+
+// PreventSleep holds power assertions with caffeinate(8) for as long as the command runs so that the system can't
+// idle sleep (or let its disks sleep) in the middle of long operations like repairs and restores.
+func PreventSleep() Option {
+	return func(o *options) {
+		o.preventSleep = true
+	}
+}
+
+// AnswerYes answers "y" to every prompt of the command, like ExecuteCommandYes. It's only used by Executors since
+// the Execute functions are given the command's input directly.
+func AnswerYes() Option {
+	return func(o *options) {
+		o.answerYes = true
+	}
+}
+
+// OnLine passes each line of the command's Stdout to onLine as it's written, like ExecuteCommandLines, so that the
+// progress of long-running commands can be reported while their output is still returned. Carriage returns also end
+// lines since tools redraw their progress in place with them.
+func OnLine(onLine func(line string)) Option {
+	return func(o *options) {
+		o.onLine = onLine
+	}
+}
+
+// Input writes input to the command's stdin, followed by a newline, so that secrets (e.g. passphrases) can be given
+// to commands without them appearing in their arguments, where other users can see them with ps(1). The command is
+// started in its own session, without a controlling terminal, so that tools which prompt on the terminal (e.g.
+// security(1)) read the input instead.
+func Input(input string) Option {
+	return func(o *options) {
+		o.input = &input
+	}
+}
+
+// InputOf gets the input set with Input, if any, so that Executors can write it to the command's stdin.
+func InputOf(opts ...Option) (string, bool) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.input == nil {
+		return "", false
+	}
+
+	return *o.input, true
+}
+
+// CommandOutput wraps the output from an exec command as strings.
+type CommandOutput struct {
+	Stdout string
+	Stderr string
+}
+
+// ExecError is returned when a command couldn't be started or didn't exit successfully. It's kept in the chain of
+// the errors that wrap it so that the failed command can be reported along with them.
+type ExecError struct {
+	// Command is the command that failed.
+	Command []string
+	// Stderr is the failed command's standard error.
+	Stderr string
+	// Err is the failure.
+	Err error
+}
+
+func (e *ExecError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// ExecuteCommand executes the command and returns Stdout and Stderr as strings.
+func ExecuteCommand(ctx context.Context, c []string, runAsUser string, envVars []string, stdin io.ReadCloser, opts ...Option) (output CommandOutput, err error) {
+	cmd, err := newCommand(c, runAsUser, envVars, opts)
+	if err != nil {
+		return CommandOutput{}, err
+	}
+
+	// Set command and create output buffers, Stdout is also split into lines when OnLine is given
+	var stdoutb, stderrb bytes.Buffer
+	cmd.Stdout = &stdoutb
+	cmd.Stderr = &stderrb
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.onLine != nil {
+		lines := &lineWriter{onLine: o.onLine}
+		defer lines.Flush()
+		cmd.Stdout = io.MultiWriter(&stdoutb, lines)
+	}
+
+	// Set command stdin if the stdin parameter or Input is provided
+	if stdin != nil {
+		cmd.Stdin = stdin
+	} else if o.input != nil {
+		cmd.Stdin = strings.NewReader(*o.input + "\n")
+	}
+
+	// Start the command's execution
+	g, err := startGroup(ctx, cmd)
+	if err != nil {
+		return CommandOutput{Stdout: stdoutb.String(), Stderr: stderrb.String()}, &ExecError{Command: c, Stderr: stderrb.String(), Err: fmt.Errorf("error starting specified command: %w", err)}
+	}
+
+	// Wait for the command to exit
+	if err = g.Wait(); err != nil {
+		return CommandOutput{Stdout: stdoutb.String(), Stderr: stderrb.String()}, &ExecError{Command: c, Stderr: stderrb.String(), Err: fmt.Errorf("error waiting for specified command to exit: %w", err)}
+	}
+
+	return CommandOutput{Stdout: stdoutb.String(), Stderr: stderrb.String()}, err
+}
+
+// ExecuteCommandLines executes the command like ExecuteCommand but also passes each line of Stdout to onLine as
+// it's written. This allows progress to be reported for long-running commands.
+func ExecuteCommandLines(ctx context.Context, c []string, runAsUser string, envVars []string, onLine func(line string), opts ...Option) (output CommandOutput, err error) {
+	cmd, err := newCommand(c, runAsUser, envVars, opts)
+	if err != nil {
+		return CommandOutput{}, err
+	}
+
+	// Set command and create output buffers, Stdout is copied into its buffer as it's scanned
+	var stdoutb, stderrb bytes.Buffer
+	cmd.Stderr = &stderrb
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return CommandOutput{}, fmt.Errorf("error creating pipe for command output: %w", err)
+	}
+
+	// Start the command's execution
+	g, err := startGroup(ctx, cmd)
+	if err != nil {
+		return CommandOutput{Stdout: stdoutb.String(), Stderr: stderrb.String()}, &ExecError{Command: c, Stderr: stderrb.String(), Err: fmt.Errorf("error starting specified command: %w", err)}
+	}
+
+	// Scan the output until the command closes it
+	scanner := bufio.NewScanner(io.TeeReader(stdout, &stdoutb))
+	for scanner.Scan() {
+		onLine(scanner.Text())
+	}
+
+	// Wait for the command to exit
+	if err = g.Wait(); err != nil {
+		return CommandOutput{Stdout: stdoutb.String(), Stderr: stderrb.String()}, &ExecError{Command: c, Stderr: stderrb.String(), Err: fmt.Errorf("error waiting for specified command to exit: %w", err)}
+	}
+
+	return CommandOutput{Stdout: stdoutb.String(), Stderr: stderrb.String()}, err
+}
+
+// newCommand creates the command to be run as runAsUser with the environment variables appended to the current
+// environment and the options applied. The command is run in its own process group so that startGroup can
+// terminate it along with any processes it spawns.
+func newCommand(c []string, runAsUser string, envVars []string, opts []Option) (*exec.Cmd, error) {
+	// Separate name and args, plus catch a few error cases
+	var name string
+	var args []string
+
+	// Check the empty struct case ([]string{}) for the command
+	if len(c) == 0 {
+		return nil, fmt.Errorf("must provide a command")
+	}
+
+	// Wrap the command with caffeinate, which holds its assertions until the command exits
+	//   * -i - prevent the system from idle sleeping
+	//   * -m - prevent the disks from idle sleeping
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.preventSleep {
+		c = append([]string{caffeinatePath, "-i", "-m"}, c...)
+	}
+
+	// Set the name of the command and check if args are also provided
+	name = c[0]
+	if len(c) > 1 {
+		args = c[1:]
+	}
+
+	cmd := exec.Command(name, args...)
+	setProcessGroup(cmd, o.input != nil)
+
+	// Set runAsUser, if defined, otherwise will run as root
+	if runAsUser != "" {
+		uid, gid, err := getUIDandGID(runAsUser)
+		if err != nil {
+			return nil, fmt.Errorf("error looking up user: %s\n", err)
+		}
+		if err := setCredential(cmd, uid, gid); err != nil {
+			return nil, err
+		}
+	}
+
+	// Append environment variables
+	cmd.Env = os.Environ()
+	cmd.Env = append(cmd.Env, envVars...)
+
+	return cmd, nil
+}
+
+// lineWriter calls onLine with each non-empty line written to it, ended by either a newline or a carriage return.
+type lineWriter struct {
+	onLine func(line string)
+	buf    []byte
+}
+
+// Write buffers p and passes on the lines that it completes.
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		if i > 0 {
+			w.onLine(string(w.buf[:i]))
+		}
+		w.buf = w.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// Flush passes on the last line when the output didn't end with a newline.
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.onLine(string(w.buf))
+		w.buf = nil
+	}
+}
+
+// processGroup is a started command whose process group is terminated when its context is done before it exits.
+type processGroup struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+}
+
+// startGroup starts the command, which must have been created by newCommand, and watches ctx for as long as it
+// runs. When ctx is done the command's process group is sent SIGTERM, then SIGKILL if it hasn't exited within
+// terminateGracePeriod. Signalling the whole group stops the children that tools like caffeinate(8) and
+// diskutil(8) spawn rather than leaving them running after the CLI exits.
+func startGroup(ctx context.Context, cmd *exec.Cmd) (*processGroup, error) {
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	g := &processGroup{cmd: cmd, done: make(chan struct{})}
+	go g.watch(ctx)
+
+	return g, nil
+}
+
+// watch terminates the process group when ctx is done before the command exits.
+func (g *processGroup) watch(ctx context.Context) {
+	select {
+	case <-g.done:
+		return
+	case <-ctx.Done():
+	}
+
+	// The group's ID is the command's PID since it was started as the group's leader
+	pgid := g.cmd.Process.Pid
+	_ = terminateGroup(pgid)
+
+	select {
+	case <-g.done:
+	case <-time.After(terminateGracePeriod):
+		_ = killGroup(pgid)
+	}
+}
+
+// Wait waits for the command to exit and stops watching its context.
+func (g *processGroup) Wait() error {
+	defer close(g.done)
+
+	return g.cmd.Wait()
+}
+
+// ExecuteCommandYes wraps ExecuteCommand with the yes binary in order to bypass user input states in automation.
+func ExecuteCommandYes(ctx context.Context, c []string, runAsUser string, envVars []string, opts ...Option) (output CommandOutput, err error) {
+	// Set exec commands, one for yes and another for the specified command
+	cmdYes := exec.Command("/usr/bin/yes")
+
+	// Pipe cmdYes into cmd
+	stdin, err := cmdYes.StdoutPipe()
+	if err != nil {
+		return CommandOutput{}, fmt.Errorf("error creating pipe between commands")
+	}
+
+	// Start the command to run /usr/bin/yes
+	if err = cmdYes.Start(); err != nil {
+		return CommandOutput{}, fmt.Errorf("error starting /usr/bin/yes command: %w", err)
+	}
+
+	return ExecuteCommand(ctx, c, runAsUser, envVars, stdin, opts...)
+}
+
+// getUIDandGID takes a username and returns the uid and gid for that user.
+// While testing UID/GID lookup for a user, it was found that the user.Lookup() function does not always return
+// information for a new user on first boot. In the case that user.Lookup() fails, try dscacheutil, which has a
+// higher success rate. If that fails, return an error. Any successful case returns the UID and GID as ints.
+func getUIDandGID(username string) (uid int, gid int, err error) {
+	var uidstr, gidstr string
+	// Preference is user.Lookup(), if it works
+	u, lookuperr := user.Lookup(username)
+	if lookuperr != nil {
+		// user.Lookup() has failed, second try by checking the DS cache
+		out, cmderr := ExecuteCommand(context.Background(), []string{"dscacheutil", "-q", "user", "-a", "name", username}, "", []string{}, nil)
+		if cmderr != nil {
+			// dscacheutil has failed with an error
+			return 0, 0, fmt.Errorf("error while looking up user %s: \n"+
+				"user.Lookup() error: %s \ndscacheutil error: %s\ndscacheutil Stderr: %s\n",
+				username, lookuperr, cmderr, out.Stderr)
+		}
+		// Check length of Stdout - dscacheutil returns nothing if user is not found
+		if len(out.Stdout) > 0 { // dscacheutil has returned something
+			// Command output from dscacheutil should look like:
+			//   name: ec2-user
+			//   password: ********
+			//   uid: 501
+			//   gid: 20
+			//   dir: /Users/ec2-user
+			//   shell: /bin/bash
+			//   gecos: ec2-user
+			dsSplit := strings.Split(out.Stdout, "\n") // split on newline to separate uid and gid
+			for _, e := range dsSplit {
+				eSplit := strings.Fields(e) // split into fields to separate tag with id
+				// Find UID and GID and set them
+				if strings.HasPrefix(e, "uid") {
+					if len(eSplit) != 2 {
+						// dscacheutil has returned some sort of weird output that can't be split
+						return 0, 0, fmt.Errorf("error while splitting dscacheutil uid output for user %s: %s\n"+
+							"user.Lookup() error: %s \ndscacheutil error: %s\ndscacheutil Stderr: %s\n",
+							username, out.Stdout, lookuperr, cmderr, out.Stderr)
+					}
+					uidstr = eSplit[1]
+				} else if strings.HasPrefix(e, "gid") {
+					if len(eSplit) != 2 {
+						// dscacheutil has returned some sort of weird output that can't be split
+						return 0, 0, fmt.Errorf("error while splitting dscacheutil gid output for user %s: %s\n"+
+							"user.Lookup() error: %s \ndscacheutil error: %s\ndscacheutil Stderr: %s\n",
+							username, out.Stdout, lookuperr, cmderr, out.Stderr)
+					}
+					gidstr = eSplit[1]
+				}
+			}
+		} else {
+			// dscacheutil has returned nothing, user is not found
+			return 0, 0, fmt.Errorf("user %s not found: \n"+
+				"user.Lookup() error: %s \ndscacheutil error: %s\ndscacheutil Stderr: %s\n",
+				username, lookuperr, cmderr, out.Stderr)
+		}
+	} else {
+		// user.Lookup() was successful, use the returned UID/GID
+		uidstr = u.Uid
+		gidstr = u.Gid
+	}
+
+	// Convert UID and GID to int
+	uid, err = strconv.Atoi(uidstr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error while converting UID to int: %s\n", err)
+	}
+	gid, err = strconv.Atoi(gidstr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error while converting GID to int: %s\n", err)
+	}
+
+	return uid, gid, nil
+}
@@ -0,0 +1,91 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCommand_PreventSleep(t *testing.T) {
+	cmd, err := newCommand([]string{"diskutil", "repairDisk", "disk0"}, "", nil, []Option{PreventSleep()})
+
+	assert.NoError(t, err)
+	assert.Equal(t, caffeinatePath, cmd.Path)
+	assert.Equal(t, []string{caffeinatePath, "-i", "-m", "diskutil", "repairDisk", "disk0"}, cmd.Args)
+}
+
+func TestNewCommand_WithoutOptions(t *testing.T) {
+	cmd, err := newCommand([]string{"/usr/sbin/diskutil", "list"}, "", nil, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/usr/sbin/diskutil", "list"}, cmd.Args)
+}
+
+func TestNewCommand_WithoutCommand(t *testing.T) {
+	_, err := newCommand(nil, "", nil, []Option{PreventSleep()})
+
+	assert.Error(t, err, "should require a command")
+}
+
+func TestShellCommand(t *testing.T) {
+	assert.Equal(t, "diskutil info -plist /", ShellCommand([]string{"diskutil", "info", "-plist", "/"}))
+	assert.Equal(t, `diskutil eraseDisk APFS 'Build Data' GPT disk2`, ShellCommand([]string{"diskutil", "eraseDisk", "APFS", "Build Data", "GPT", "disk2"}))
+	assert.Equal(t, `echo 'it'\''s' ''`, ShellCommand([]string{"echo", "it's", ""}))
+	assert.Equal(t, "/usr/bin/yes | /usr/bin/caffeinate -i -m diskutil repairDisk disk0",
+		ShellCommand([]string{"diskutil", "repairDisk", "disk0"}, AnswerYes(), PreventSleep()))
+}
+
+func TestShellCommand_Input(t *testing.T) {
+	line := ShellCommand([]string{"diskutil", "apfs", "unlockVolume", "disk3s1", "-stdinpassphrase"}, Input("p@ss word"))
+
+	assert.Equal(t, "diskutil apfs unlockVolume disk3s1 -stdinpassphrase", line)
+	assert.NotContains(t, line, "p@ss word", "input shouldn't be rendered in the line")
+	input, ok := InputOf(Input("p@ss word"))
+	assert.True(t, ok)
+	assert.Equal(t, "p@ss word", input)
+}
+
+func TestExecuteCommand_TerminatesProcessGroup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	// The backgrounded sleep holds the command's output open, so the command only returns promptly when the
+	// whole group is terminated rather than just the shell.
+	start := time.Now()
+	_, err := ExecuteCommand(ctx, []string{"/bin/sh", "-c", "sleep 30 & wait"}, "", nil, nil)
+
+	assert.Error(t, err, "should fail when interrupted")
+	assert.True(t, time.Since(start) < terminateGracePeriod, "should terminate the command's children")
+}
+
+func TestExecuteCommand_OnLine(t *testing.T) {
+	var lines []string
+	out, err := ExecuteCommand(context.Background(), []string{"/bin/sh", "-c", `printf 'Started\n10%%\r20%%\rFinished'`}, "", nil, nil, OnLine(func(line string) {
+		lines = append(lines, line)
+	}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Started", "10%", "20%", "Finished"}, lines)
+	assert.Equal(t, "Started\n10%\r20%\rFinished", out.Stdout, "should still return the whole output")
+}
+
+func TestExecuteCommand_Input(t *testing.T) {
+	out, err := ExecuteCommand(context.Background(), []string{"/bin/sh", "-c", "read -r line; echo \"got $line\""}, "", nil, nil, Input("secret"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "got secret\n", out.Stdout, "input should be written to stdin")
+}
+
+func TestExecuteCommand_ExecError(t *testing.T) {
+	c := []string{"/bin/sh", "-c", "echo 'Error: -69888: Resource busy' >&2; exit 1"}
+	_, err := ExecuteCommand(context.Background(), c, "", nil, nil)
+
+	var execErr *ExecError
+	assert.True(t, errors.As(fmt.Errorf("wrapped: %w", err), &execErr), "should be found when wrapped")
+	assert.Equal(t, c, execErr.Command)
+	assert.Equal(t, "Error: -69888: Resource busy\n", execErr.Stderr)
+}